@@ -0,0 +1,497 @@
+package mysqllog
+
+import (
+	"container/list"
+	"math"
+	"sort"
+	"time"
+)
+
+// HistogramBuckets is the number of buckets in QueryStats.Histogram.
+const HistogramBuckets = 8
+
+// HistogramLabels names the QueryStats.Histogram buckets. Each bucket
+// holds the Query_times from its label up to the next one.
+var HistogramLabels = [HistogramBuckets]string{"1us", "10us", "100us", "1ms", "10ms", "100ms", "1s", "10s+"}
+
+// QueryStats summarizes the events sharing a fingerprint.
+// Times are in seconds.
+type QueryStats struct {
+	Fingerprint string
+	Count       int64
+	// Logged is the number of events in the log. With
+	// WithRateLimitScaling, Count and the totals are estimates scaled up
+	// from them; see Estimated.
+	Logged int64
+
+	TotalTime float64
+	MinTime   float64
+	MaxTime   float64
+	// LockTime counts each event's Lock_time up to its Query_time.
+	LockTime float64
+
+	RowsSent     int64
+	RowsExamined int64
+	// FullScans is the number of events flagged as full scans.
+	FullScans int64
+	// UnboundedWrites is the number of events that are an UnboundedWrite.
+	UnboundedWrites int64
+	// StatementBytes is the total length of the statements, and
+	// MaxValuesTuples the most rows inserted by a single statement.
+	StatementBytes  int64
+	MaxValuesTuples int64
+
+	// Histogram counts events by Query_time; see HistogramLabels.
+	Histogram [HistogramBuckets]int64
+	// attributes has the Distribution of each of DistributionAttributes
+	// seen, with quantiles of the given accuracy.
+	attributes map[string]*Distribution
+	accuracy   float64
+
+	// FirstSeen and LastSeen are zero if no event had a timestamp.
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// QPS is Count per second over the time spanned by all the events
+	// of the Aggregator, and ActiveQPS over the time from FirstSeen to
+	// LastSeen, or QPS if that's zero, as for a single event. They're
+	// set by Aggregator.Results, and zero if the events span no time.
+	QPS       float64
+	ActiveQPS float64
+
+	// Sample is the slowest event seen.
+	Sample LogEvent
+}
+
+// Estimated reports whether Count and the totals are estimates, scaled
+// up from sampled events by WithRateLimitScaling. Distributions are of
+// the logged events.
+func (s *QueryStats) Estimated() bool {
+	return s.Logged > 0 && s.Count != s.Logged
+}
+
+// MeanTime returns the average Query_time.
+func (s *QueryStats) MeanTime() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.TotalTime / float64(s.Count)
+}
+
+// StddevTime returns the standard deviation of Query_time.
+func (s *QueryStats) StddevTime() float64 {
+	if d := s.Attribute("Query_time"); d != nil {
+		return d.Stddev()
+	}
+	return 0
+}
+
+// Quantile returns the approximate q-quantile of Query_time, within
+// the accuracy set by WithQuantileAccuracy.
+func (s *QueryStats) Quantile(q float64) float64 {
+	if d := s.Attribute("Query_time"); d != nil {
+		return d.Quantile(q)
+	}
+	return 0
+}
+
+// Attribute returns the Distribution of key, one of
+// DistributionAttributes, or nil if no event had it. Query_time counts
+// every event, as zero if it's missing.
+func (s *QueryStats) Attribute(key string) *Distribution {
+	return s.attributes[key]
+}
+
+// addAttribute adds v to the Distribution of key.
+func (s *QueryStats) addAttribute(key string, v float64) {
+	d := s.attributes[key]
+	if d == nil {
+		if s.attributes == nil {
+			s.attributes = map[string]*Distribution{}
+		}
+		d = newDistribution(s.accuracy)
+		s.attributes[key] = d
+	}
+	d.Add(v)
+}
+
+// P95Time returns the approximate 95th percentile Query_time.
+func (s *QueryStats) P95Time() float64 {
+	return s.Quantile(0.95)
+}
+
+// FullScanPercent returns the percentage of events flagged as full scans.
+func (s *QueryStats) FullScanPercent() float64 {
+	return percent(float64(s.FullScans), float64(s.Count))
+}
+
+// MeanStatementBytes returns the average length of the statements.
+func (s *QueryStats) MeanStatementBytes() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.StatementBytes) / float64(s.Count)
+}
+
+// AttributeCount returns the number of events that had key, one of
+// DistributionAttributes. Events without it don't count as zero in its
+// Distribution.
+func (s *QueryStats) AttributeCount(key string) int64 {
+	if d := s.Attribute(key); d != nil {
+		return d.Count
+	}
+	return 0
+}
+
+// BytesPerRow returns the average Bytes_sent per row sent of the events
+// with Bytes_sent, counting zero rows sent as one, or zero if none had
+// it. A high one points at wide rows shipped to the client, such as
+// with SELECT *.
+func (s *QueryStats) BytesPerRow() float64 {
+	if d := s.Attribute(BytesPerRow); d != nil {
+		return d.Mean()
+	}
+	return 0
+}
+
+// ExaminedPerSent returns the rows examined per row sent, counting
+// zero rows sent as one.
+func (s *QueryStats) ExaminedPerSent() float64 {
+	sent := s.RowsSent
+	if sent < 1 {
+		sent = 1
+	}
+	return float64(s.RowsExamined) / float64(sent)
+}
+
+// add accumulates an event into s, standing for weight events, as with
+// WithRateLimitScaling.
+func (s *QueryStats) add(e LogEvent, weight int64) {
+	queryTime, _ := e.Float64("Query_time")
+	if s.Count == 0 || queryTime < s.MinTime {
+		s.MinTime = queryTime
+	}
+	if s.Count == 0 || queryTime > s.MaxTime {
+		s.MaxTime = queryTime
+	}
+	if s.Count == 0 || slowerSample(e, queryTime, s.Sample) {
+		s.Sample = e
+	}
+	s.Count += weight
+	s.Logged++
+	s.TotalTime += queryTime * float64(weight)
+	s.Histogram[histogramBucket(queryTime)] += weight
+	s.addAttribute("Query_time", queryTime)
+	for _, key := range DistributionAttributes[1:] {
+		if v, ok := distributionValue(e, key); ok {
+			s.addAttribute(key, v)
+		}
+	}
+
+	s.LockTime += clampedLockTime(e, queryTime) * float64(weight)
+	rowsSent, _ := e.Int64("Rows_sent")
+	s.RowsSent += rowsSent * weight
+	rowsExamined, _ := e.Int64("Rows_examined")
+	s.RowsExamined += rowsExamined * weight
+	if isFullScan(e) {
+		s.FullScans += weight
+	}
+	if isUnboundedWrite(e) {
+		s.UnboundedWrites += weight
+	}
+	bytes, tuples := statementSize(e)
+	s.StatementBytes += bytes * weight
+	if tuples > s.MaxValuesTuples {
+		s.MaxValuesTuples = tuples
+	}
+
+	if ts, ok := EventTime(e); ok {
+		if s.FirstSeen.IsZero() || ts.Before(s.FirstSeen) {
+			s.FirstSeen = ts
+		}
+		if ts.After(s.LastSeen) {
+			s.LastSeen = ts
+		}
+	}
+}
+
+// slowerSample reports whether e, taking queryTime, is a better Sample
+// than sample: the slowest event wins, and ties go to the earliest one,
+// then to the first statement in byte order, so the Sample is the same
+// however the events were split between merged Aggregators.
+func slowerSample(e LogEvent, queryTime float64, sample LogEvent) bool {
+	if e == nil {
+		return false
+	}
+	if sample == nil {
+		return true
+	}
+	max, _ := sample.Float64("Query_time")
+	if queryTime != max {
+		return queryTime > max
+	}
+	t, ok := EventTime(e)
+	sampleTime, sampleOK := EventTime(sample)
+	if ok && sampleOK && !t.Equal(sampleTime) {
+		return t.Before(sampleTime)
+	}
+	statement, _ := e["Statement"].(string)
+	sampleStatement, _ := sample["Statement"].(string)
+	return statement < sampleStatement
+}
+
+// snapshot returns a copy of s that isn't affected by later events.
+func (s *QueryStats) snapshot() QueryStats {
+	result := *s
+	if s.attributes != nil {
+		result.attributes = make(map[string]*Distribution, len(s.attributes))
+		for key, d := range s.attributes {
+			result.attributes[key] = d.clone()
+		}
+	}
+	return result
+}
+
+// Merge adds the stats of other, for the same fingerprint, to s.
+func (s *QueryStats) Merge(other QueryStats) error {
+	if other.Count == 0 {
+		return nil
+	}
+	for key, o := range other.attributes {
+		d := s.attributes[key]
+		if d == nil {
+			if s.attributes == nil {
+				s.attributes = map[string]*Distribution{}
+			}
+			d = &Distribution{}
+			s.attributes[key] = d
+		}
+		if err := d.Merge(o); err != nil {
+			return err
+		}
+	}
+	if s.Count == 0 || other.MinTime < s.MinTime {
+		s.MinTime = other.MinTime
+	}
+	if s.Count == 0 || other.MaxTime > s.MaxTime {
+		s.MaxTime = other.MaxTime
+	}
+	if s.Count == 0 || slowerSample(other.Sample, other.MaxTime, s.Sample) {
+		s.Sample = other.Sample
+	}
+	s.Count += other.Count
+	s.Logged += other.Logged
+	s.TotalTime += other.TotalTime
+	s.LockTime += other.LockTime
+	s.RowsSent += other.RowsSent
+	s.RowsExamined += other.RowsExamined
+	s.FullScans += other.FullScans
+	s.UnboundedWrites += other.UnboundedWrites
+	s.StatementBytes += other.StatementBytes
+	if other.MaxValuesTuples > s.MaxValuesTuples {
+		s.MaxValuesTuples = other.MaxValuesTuples
+	}
+	for i, n := range other.Histogram {
+		s.Histogram[i] += n
+	}
+	if !other.FirstSeen.IsZero() && (s.FirstSeen.IsZero() || other.FirstSeen.Before(s.FirstSeen)) {
+		s.FirstSeen = other.FirstSeen
+	}
+	if other.LastSeen.After(s.LastSeen) {
+		s.LastSeen = other.LastSeen
+	}
+	return nil
+}
+
+// Aggregator groups events by the fingerprint of their statement, and
+// rolls them up by the values of other attributes (see WithRollups).
+type Aggregator struct {
+	stats map[string]*QueryStats
+
+	dimensions []string
+	rollups    map[string]map[string]*Rollup
+	count      int64
+	totalTime  float64
+	accuracy   float64
+	// scaleRateLimit is set by WithRateLimitScaling.
+	scaleRateLimit bool
+
+	maxFingerprints int
+	policy          EvictionPolicy
+	evictions       int64
+	other           *QueryStats
+	byTime          statsHeap
+	recent          *list.List
+	recentElements  map[string]*list.Element
+
+	lockMinutes map[int64]*LockMinute
+	summary     Summary
+
+	tables           map[string]*tableEntry
+	tableAttribution TableAttribution
+}
+
+// AggregatorOption configures an Aggregator.
+type AggregatorOption func(*Aggregator)
+
+// NewAggregator returns an empty Aggregator.
+// Events are rolled up by Database, User and Host unless WithRollups is given.
+func NewAggregator(opts ...AggregatorOption) *Aggregator {
+	a := &Aggregator{
+		stats:       map[string]*QueryStats{},
+		lockMinutes: map[int64]*LockMinute{},
+		dimensions:  []string{"Database", "User", "Host"},
+		accuracy:    DefaultSketchAccuracy,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.rollups = make(map[string]map[string]*Rollup, len(a.dimensions))
+	for _, key := range a.dimensions {
+		a.rollups[key] = map[string]*Rollup{}
+	}
+	return a
+}
+
+// Add accumulates e into the stats for its fingerprint and its rollups.
+// Control events are ignored.
+func (a *Aggregator) Add(e LogEvent) {
+	if IsControlEvent(e) {
+		return
+	}
+	queryTime, _ := e.Float64("Query_time")
+	weight := a.weight(e)
+	a.count += weight
+	a.totalTime += queryTime * float64(weight)
+	a.addRollups(e, weight)
+	a.addLockMinute(e, queryTime)
+	a.summary.Add(e)
+
+	fingerprint := eventFingerprint(e)
+	if a.tables != nil {
+		a.addTables(e, fingerprint, queryTime)
+	}
+	s := a.statsFor(fingerprint)
+	s.add(e, weight)
+	a.touched(s)
+}
+
+// WithQuantileAccuracy sets the relative accuracy of QueryStats.Quantile,
+// DefaultSketchAccuracy by default. See Sketch.
+func WithQuantileAccuracy(accuracy float64) AggregatorOption {
+	return func(a *Aggregator) {
+		a.accuracy = accuracy
+	}
+}
+
+// Merge adds the events accumulated by other to a, so aggregators fed
+// in parallel, such as one per worker goroutine, can be combined: an
+// Aggregator isn't safe for concurrent use. Both must have the same
+// rollup dimensions and quantile accuracy.
+//
+// Merging is associative and commutative, so the results don't depend
+// on how the events were split, except for sums of floating point
+// values, which may differ in their last digits, and for fingerprints
+// evicted with WithMaxFingerprints, which depend on the order of each
+// Aggregator's events.
+func (a *Aggregator) Merge(other *Aggregator) error {
+	for fingerprint, o := range other.stats {
+		s := a.statsFor(fingerprint)
+		if err := s.Merge(*o); err != nil {
+			return err
+		}
+		a.touched(s)
+	}
+	if other.other != nil {
+		if a.other == nil {
+			a.other = &QueryStats{Fingerprint: OtherFingerprint, accuracy: a.accuracy}
+		}
+		if err := a.other.Merge(*other.other); err != nil {
+			return err
+		}
+	}
+	a.count += other.count
+	a.totalTime += other.totalTime
+	a.evictions += other.evictions
+	a.mergeLockMinutes(other)
+	a.mergeTables(other)
+	a.summary.Merge(&other.summary)
+	for key, values := range other.rollups {
+		if a.rollups[key] == nil {
+			continue
+		}
+		for value, o := range values {
+			r := a.rollups[key][value]
+			if r == nil {
+				r = &Rollup{Value: value}
+				a.rollups[key][value] = r
+			}
+			r.Count += o.Count
+			r.TotalTime += o.TotalTime
+			r.RowsExamined += o.RowsExamined
+		}
+	}
+	return nil
+}
+
+// QPS returns the events per second over the time spanned by their
+// timestamps, or 0 if it's zero.
+func (a *Aggregator) QPS() float64 {
+	span := a.summary.Span().Seconds()
+	if span <= 0 {
+		return 0
+	}
+	return float64(a.count) / span
+}
+
+// Summary returns the Summary of the events added, including those of
+// evicted fingerprints. Its Bytes is zero.
+func (a *Aggregator) Summary() *Summary {
+	s := &Summary{}
+	s.Merge(&a.summary)
+	return s
+}
+
+// Results returns the stats for every fingerprint, sorted by total
+// Query_time in descending order. With WithMaxFingerprints, evicted
+// fingerprints are included as OtherFingerprint.
+func (a *Aggregator) Results() []QueryStats {
+	results := make([]QueryStats, 0, len(a.stats)+1)
+	for _, s := range a.stats {
+		results = append(results, s.snapshot())
+	}
+	if a.other != nil {
+		results = append(results, a.other.snapshot())
+	}
+	if span := a.summary.Span().Seconds(); span > 0 {
+		for i := range results {
+			s := &results[i]
+			s.QPS = float64(s.Count) / span
+			s.ActiveQPS = s.QPS
+			if active := s.LastSeen.Sub(s.FirstSeen).Seconds(); active > 0 {
+				s.ActiveQPS = float64(s.Count) / active
+			}
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].TotalTime != results[j].TotalTime {
+			return results[i].TotalTime > results[j].TotalTime
+		}
+		return results[i].Fingerprint < results[j].Fingerprint
+	})
+	return results
+}
+
+// histogramBucket returns the Histogram bucket for a Query_time.
+func histogramBucket(seconds float64) int {
+	if seconds <= 0 {
+		return 0
+	}
+	bucket := int(math.Floor(math.Log10(seconds))) + 6
+	if bucket < 0 {
+		return 0
+	}
+	if bucket >= HistogramBuckets {
+		return HistogramBuckets - 1
+	}
+	return bucket
+}
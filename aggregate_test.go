@@ -0,0 +1,194 @@
+package mysqllog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestAggregator(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/rds.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAggregator()
+	events := parseAll(&Parser{}, string(b))
+	for _, e := range events {
+		a.Add(e)
+	}
+
+	results := a.Results()
+	var count int64
+	var totalTime float64
+	for i, s := range results {
+		count += s.Count
+		totalTime += s.TotalTime
+		if i > 0 && s.TotalTime > results[i-1].TotalTime {
+			t.Errorf("results not sorted by total time at %d", i)
+		}
+		if s.MinTime > s.MaxTime {
+			t.Errorf("min %f greater than max %f for %q", s.MinTime, s.MaxTime, s.Fingerprint)
+		}
+		if s.Sample["Query_time"] != s.MaxTime {
+			t.Errorf("expected the slowest event as sample for %q", s.Fingerprint)
+		}
+	}
+	if count != int64(len(events)) {
+		t.Errorf("expected %d events in total, got %d", len(events), count)
+	}
+
+	var selectOne *QueryStats
+	for i := range results {
+		if results[i].Fingerprint == "select ?" {
+			selectOne = &results[i]
+		}
+	}
+	if selectOne == nil {
+		t.Fatal(`expected a "select ?" fingerprint`)
+	}
+	if selectOne.FirstSeen.IsZero() || selectOne.LastSeen.Before(selectOne.FirstSeen) {
+		t.Errorf("unexpected time range %v to %v", selectOne.FirstSeen, selectOne.LastSeen)
+	}
+}
+
+func TestAggregatorQPS(t *testing.T) {
+	a := NewAggregator()
+	if a.QPS() != 0 {
+		t.Errorf("expected no QPS without events, got %v", a.QPS())
+	}
+	for _, e := range []LogEvent{
+		{"Statement": "SELECT 1", "Query_time": 0.05, "Timestamp": int64(1000)},
+		{"Statement": "SELECT 2", "Query_time": 0.05, "Timestamp": int64(1010)},
+		{"Statement": "SELECT 3", "Query_time": 0.05, "Timestamp": int64(1020)},
+		{"Statement": "SELECT SLEEP(30)", "Query_time": 30.0, "Timestamp": int64(1100)},
+		{"Statement": "SELECT 4", "Query_time": 0.05},
+	} {
+		a.Add(e)
+	}
+	if a.QPS() != 0.05 {
+		t.Errorf("expected 5 events over 100s, got %v", a.QPS())
+	}
+	results := a.Results()
+	// Seen once, so the rate while seen is the overall one.
+	if s := results[0]; s.QPS != 0.01 || s.ActiveQPS != 0.01 {
+		t.Errorf("unexpected rates %v, %v for %q", s.QPS, s.ActiveQPS, s.Fingerprint)
+	}
+	if s := results[1]; s.QPS != 0.04 || s.ActiveQPS != 0.2 {
+		t.Errorf("unexpected rates %v, %v for %q", s.QPS, s.ActiveQPS, s.Fingerprint)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"# Overall: 5 total, 2 unique, 30.200000s total query time, 0.0500 QPS\n",
+		"# Rate: 0.0400 QPS overall, 0.2000 QPS while seen\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected the report to contain %q, got\n%s", expected, buf.String())
+		}
+	}
+
+	// A single timestamp spans no time.
+	a = NewAggregator()
+	a.Add(LogEvent{"Statement": "SELECT 1", "Query_time": 1.0, "Timestamp": int64(1000)})
+	if s := a.Results()[0]; a.QPS() != 0 || s.QPS != 0 || s.ActiveQPS != 0 {
+		t.Errorf("expected no rates, got %v, %v, %v", a.QPS(), s.QPS, s.ActiveQPS)
+	}
+}
+
+func TestAggregatorMergePartitions(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/rds.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := parseAll(NewParser(), string(b))
+	newAggregator := func() *Aggregator {
+		return NewAggregator(WithRollups("Database", "User", "Verb"), WithTableStats(TableSplitTime))
+	}
+	single := newAggregator()
+	for _, e := range events {
+		single.Add(e)
+	}
+
+	// report renders everything an Aggregator has.
+	report := func(a *Aggregator) string {
+		var buf bytes.Buffer
+		summary := a.Summary()
+		err := WriteReport(&buf, a.Results(), WithTopN(0), WithSummary(summary),
+			WithLockContention(a.LockContention(0)), WithTables(a.ByTable(0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range a.Dimensions() {
+			for _, r := range a.Rollup(key) {
+				fmt.Fprintf(&buf, "%s %s %d %.6f %d\n", key, r.Value, r.Count, r.TotalTime, r.RowsExamined)
+			}
+		}
+		return buf.String()
+	}
+	want := report(single)
+	results := single.Results()
+
+	rng := rand.New(rand.NewSource(1))
+	partitions := map[string]func(i int) int{
+		"contiguous":  func(i int) int { return i * 4 / len(events) },
+		"round robin": func(i int) int { return i % 3 },
+		"random":      func(int) int { return rng.Intn(5) },
+	}
+	for name, shard := range partitions {
+		var shards []*Aggregator
+		for i, e := range events {
+			n := shard(i)
+			for len(shards) <= n {
+				shards = append(shards, newAggregator())
+			}
+			shards[n].Add(e)
+		}
+
+		// Left to right, and right to left as a different grouping.
+		leftToRight := newAggregator()
+		for _, s := range shards {
+			if err := leftToRight.Merge(s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		rightToLeft := newAggregator()
+		for i := len(shards) - 1; i >= 0; i-- {
+			merged := newAggregator()
+			for _, a := range []*Aggregator{shards[i], rightToLeft} {
+				if err := merged.Merge(a); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rightToLeft = merged
+		}
+
+		for order, merged := range map[string]*Aggregator{"left to right": leftToRight, "right to left": rightToLeft} {
+			if got := report(merged); got != want {
+				t.Errorf("%s, %s: merged report differs:\n%s\nwant:\n%s", name, order, got, want)
+			}
+			got := merged.Results()
+			if len(got) != len(results) {
+				t.Errorf("%s, %s: expected %d results, got %d", name, order, len(results), len(got))
+				continue
+			}
+			for i := range results {
+				w, g := results[i], got[i]
+				if g.Fingerprint != w.Fingerprint || g.Count != w.Count || g.MinTime != w.MinTime || g.MaxTime != w.MaxTime ||
+					g.Histogram != w.Histogram || !g.Sample.Equal(w.Sample) {
+					t.Errorf("%s, %s: result %d differs: %+v, want %+v", name, order, i, g, w)
+				}
+				for _, q := range []float64{0.5, 0.95, 0.99} {
+					if g.Quantile(q) != w.Quantile(q) {
+						t.Errorf("%s, %s: result %d: expected quantile %v of %v, got %v", name, order, i, q, w.Quantile(q), g.Quantile(q))
+					}
+				}
+			}
+		}
+	}
+}
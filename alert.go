@@ -0,0 +1,159 @@
+package mysqllog
+
+import (
+	"container/list"
+	"time"
+)
+
+// DefaultAlertFingerprints is the number of fingerprints an Alerter
+// tracks unless MaxFingerprints is set.
+const DefaultAlertFingerprints = 10000
+
+// Alert describes a triggered alert rule.
+type Alert struct {
+	// Rule is the name of the rule that triggered.
+	Rule        string
+	Fingerprint string
+	// Event is the event that triggered the alert.
+	Event LogEvent
+	// Count is the number of occurrences within the window for count
+	// rules, and 1 for event rules.
+	Count int
+}
+
+// AlertRule triggers on every event matched by Match.
+type AlertRule struct {
+	Name  string
+	Match func(LogEvent) bool
+}
+
+// CountRule triggers when a fingerprint occurs more than Count times
+// within Window.
+type CountRule struct {
+	Name   string
+	Count  int
+	Window time.Duration
+}
+
+// Alerter is a Sink that calls Notify when events match its rules.
+// After a rule triggers for a fingerprint, further alerts for the same
+// rule and fingerprint are suppressed for Cooldown. At most
+// MaxFingerprints fingerprints are tracked; the least recently seen are
+// forgotten first. An Alerter is not safe for concurrent use; combine it
+// with other outputs using MultiSink.
+type Alerter struct {
+	EventRules      []AlertRule
+	CountRules      []CountRule
+	Cooldown        time.Duration
+	MaxFingerprints int
+	Notify          func(Alert)
+	// Clock tells the time of each event, for windows and cooldowns,
+	// RealClock if nil. See WithEventClock to replay a log.
+	Clock Clock
+
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type alertEntry struct {
+	fingerprint string
+	// rings holds recent occurrence times for each count rule.
+	rings     []timeRing
+	lastAlert map[string]time.Time
+}
+
+// Write checks e against the rules.
+func (a *Alerter) Write(e LogEvent) error {
+	now := clockOf(a.Clock).Now()
+	statement, _ := e["Statement"].(string)
+	entry := a.entry(Fingerprint(statement))
+
+	for _, rule := range a.EventRules {
+		if rule.Match != nil && rule.Match(e) {
+			a.fire(entry, Alert{Rule: rule.Name, Fingerprint: entry.fingerprint, Event: e, Count: 1}, now)
+		}
+	}
+	for i, rule := range a.CountRules {
+		ring := &entry.rings[i]
+		if ring.times == nil {
+			ring.times = make([]time.Time, rule.Count+1)
+		}
+		ring.push(now)
+		if ring.full() && now.Sub(ring.oldest()) <= rule.Window {
+			a.fire(entry, Alert{Rule: rule.Name, Fingerprint: entry.fingerprint, Event: e, Count: ring.len()}, now)
+		}
+	}
+	return nil
+}
+
+// Close implements Sink. It does nothing.
+func (a *Alerter) Close() error {
+	return nil
+}
+
+func (a *Alerter) fire(entry *alertEntry, alert Alert, now time.Time) {
+	if last, ok := entry.lastAlert[alert.Rule]; ok && now.Sub(last) < a.Cooldown {
+		return
+	}
+	entry.lastAlert[alert.Rule] = now
+	if a.Notify != nil {
+		a.Notify(alert)
+	}
+}
+
+// entry returns the state for fingerprint, marking it recently used.
+func (a *Alerter) entry(fingerprint string) *alertEntry {
+	if a.entries == nil {
+		a.entries = map[string]*list.Element{}
+		a.lru = list.New()
+	}
+	if el, ok := a.entries[fingerprint]; ok {
+		a.lru.MoveToFront(el)
+		return el.Value.(*alertEntry)
+	}
+
+	max := a.MaxFingerprints
+	if max <= 0 {
+		max = DefaultAlertFingerprints
+	}
+	for a.lru.Len() >= max {
+		oldest := a.lru.Back()
+		a.lru.Remove(oldest)
+		delete(a.entries, oldest.Value.(*alertEntry).fingerprint)
+	}
+	entry := &alertEntry{
+		fingerprint: fingerprint,
+		rings:       make([]timeRing, len(a.CountRules)),
+		lastAlert:   map[string]time.Time{},
+	}
+	a.entries[fingerprint] = a.lru.PushFront(entry)
+	return entry
+}
+
+// timeRing is a fixed-size ring buffer of times.
+type timeRing struct {
+	times []time.Time
+	next  int
+	n     int
+}
+
+func (r *timeRing) push(t time.Time) {
+	r.times[r.next] = t
+	r.next = (r.next + 1) % len(r.times)
+	if r.n < len(r.times) {
+		r.n++
+	}
+}
+
+func (r *timeRing) full() bool {
+	return r.n == len(r.times)
+}
+
+func (r *timeRing) len() int {
+	return r.n
+}
+
+// oldest returns the earliest time in a full ring.
+func (r *timeRing) oldest() time.Time {
+	return r.times[r.next]
+}
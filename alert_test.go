@@ -0,0 +1,97 @@
+package mysqllog
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAlerterCountRule(t *testing.T) {
+	clock := NewManualClock(time.Date(2017, 12, 24, 2, 42, 0, 0, time.UTC))
+	var alerts []Alert
+	a := &Alerter{
+		CountRules: []CountRule{{Name: "burst", Count: 100, Window: 5 * time.Minute}},
+		Cooldown:   10 * time.Minute,
+		Notify:     func(alert Alert) { alerts = append(alerts, alert) },
+		Clock:      clock,
+	}
+
+	burst := func(n int) {
+		for i := 0; i < n; i++ {
+			a.Write(LogEvent{"Statement": fmt.Sprintf("SELECT * FROM t WHERE id = %d", i)})
+			clock.Add(time.Second)
+		}
+	}
+
+	// 100 occurrences within the window don't exceed the count.
+	burst(100)
+	if len(alerts) != 0 {
+		t.Fatalf("expected no alerts, got %d", len(alerts))
+	}
+	burst(150)
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert within the cooldown, got %d", len(alerts))
+	}
+	if alerts[0].Rule != "burst" || alerts[0].Fingerprint != "select * from t where id = ?" || alerts[0].Count != 101 {
+		t.Errorf("unexpected alert %+v", alerts[0])
+	}
+
+	// After the cooldown, a new burst alerts again.
+	clock.Add(10 * time.Minute)
+	burst(101)
+	if len(alerts) != 2 {
+		t.Errorf("expected 2 alerts after the cooldown, got %d", len(alerts))
+	}
+
+	// Slow trickles never alert.
+	alerts = nil
+	for i := 0; i < 300; i++ {
+		a.Write(LogEvent{"Statement": "SELECT 1"})
+		clock.Add(5 * time.Second)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("expected no alerts for a slow trickle, got %d", len(alerts))
+	}
+}
+
+func TestAlerterEventRule(t *testing.T) {
+	clock := NewManualClock(time.Date(2017, 12, 24, 2, 42, 0, 0, time.UTC))
+	var alerts []Alert
+	a := &Alerter{
+		EventRules: []AlertRule{{Name: "slow", Match: Above("Query_time", 30)}},
+		Cooldown:   time.Minute,
+		Notify:     func(alert Alert) { alerts = append(alerts, alert) },
+		Clock:      clock,
+	}
+
+	for i := 0; i < 5; i++ {
+		a.Write(LogEvent{"Statement": "SELECT SLEEP(40)", "Query_time": 40.0})
+		a.Write(LogEvent{"Statement": "SELECT SLEEP(1)", "Query_time": 1.0})
+		clock.Add(10 * time.Second)
+	}
+	// A different fingerprint has its own cooldown.
+	a.Write(LogEvent{"Statement": "SELECT * FROM big", "Query_time": 31.0})
+
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %d", len(alerts))
+	}
+	if alerts[0].Fingerprint != "select sleep(?)" || alerts[1].Fingerprint != "select * from big" {
+		t.Errorf("unexpected alerts %+v", alerts)
+	}
+}
+
+func TestAlerterMaxFingerprints(t *testing.T) {
+	a := &Alerter{
+		CountRules:      []CountRule{{Name: "burst", Count: 1, Window: time.Hour}},
+		MaxFingerprints: 2,
+	}
+	for i := 0; i < 10; i++ {
+		a.Write(LogEvent{"Statement": fmt.Sprintf("SELECT * FROM t%d", i)})
+	}
+	if len(a.entries) != 2 || a.lru.Len() != 2 {
+		t.Errorf("expected 2 tracked fingerprints, got %d", len(a.entries))
+	}
+	if _, ok := a.entries["select * from t9"]; !ok {
+		t.Error("expected the most recent fingerprint to be tracked")
+	}
+}
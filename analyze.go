@@ -0,0 +1,160 @@
+package mysqllog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"time"
+)
+
+// AnalyzeOptions configures AnalyzeFile. The zero value analyzes every
+// event and details the DefaultTopN fingerprints.
+type AnalyzeOptions struct {
+	// TopN is the number of fingerprints detailed in the report, as
+	// with WithTopN, or DefaultTopN if zero. Less than zero details
+	// every fingerprint.
+	TopN int
+	// MinQueryTime leaves out the events faster than it.
+	MinQueryTime time.Duration
+	// Since and Until, if not zero, leave out the events before Since,
+	// and at or after Until, by EventTime. Events without a timestamp
+	// are left out along with them.
+	Since time.Time
+	Until time.Time
+	// Redact replaces the literals of the statements with "?", as
+	// WithRedaction does, so the samples of the report don't give away
+	// the data of the queries.
+	Redact bool
+	// ParserOptions are applied after those of AnalyzeFile, and
+	// ReportOptions to every format of the report.
+	ParserOptions []Option
+	ReportOptions []ReportOption
+}
+
+// Report is the analysis of a slow query log by AnalyzeFile,
+// which writes as text, Markdown, HTML or JSON.
+type Report struct {
+	// Results are the stats of every fingerprint, as returned by
+	// Aggregator.Results, and Summary the summary of the events
+	// analyzed, with the Bytes of the file.
+	Results []QueryStats
+	Summary *Summary
+	// Stats are those of the Parser, and Errors its ParseErrors, in
+	// input order.
+	Stats  Stats
+	Errors []*ParseError
+	// Skipped is the number of events left out by MinQueryTime, Since
+	// and Until.
+	Skipped int64
+
+	opts []ReportOption
+}
+
+// AnalyzeFile reads the slow query log at path, gzipped or not, and
+// aggregates its events by fingerprint into a Report, for programs that
+// attach the analysis of a log to something else, such as an email.
+// Events are fingerprinted as by the Aggregator. The Parser is strict,
+// and the ParseErrors are collected in the report rather than stopping
+// the analysis; errors opening or reading the file are returned.
+func AnalyzeFile(path string, opts AnalyzeOptions) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	if magic, _ := reader.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, &os.PathError{Op: "analyze", Path: path, Err: err}
+		}
+		defer zr.Close()
+		reader = bufio.NewReader(zr)
+	}
+
+	parserOpts := []Option{WithStrict()}
+	if opts.Redact {
+		parserOpts = append(parserOpts, WithRedaction(nil))
+	}
+	p := NewParser(append(parserOpts, opts.ParserOptions...)...)
+	report := &Report{}
+	a := NewAggregator()
+	add := func(e LogEvent) {
+		report.Errors = append(report.Errors, p.Errors()...)
+		if e == nil || IsControlEvent(e) {
+			return
+		}
+		if !opts.admit(e) {
+			report.Skipped++
+			return
+		}
+		a.Add(e)
+	}
+	var read int64
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			add(p.ConsumeLine(line))
+			read += int64(len(line))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "analyze", Path: path, Err: &ReadError{Offset: read, Err: err}}
+		}
+	}
+	add(p.Flush())
+
+	report.Results = a.Results()
+	report.Stats = p.Stats()
+	report.Summary = a.Summary()
+	report.Summary.Bytes = report.Stats.Bytes
+	if opts.TopN != 0 {
+		report.opts = append(report.opts, WithTopN(opts.TopN))
+	}
+	report.opts = append(report.opts, opts.ReportOptions...)
+	return report, nil
+}
+
+// admit reports whether e passes MinQueryTime, Since and Until.
+func (o AnalyzeOptions) admit(e LogEvent) bool {
+	if o.MinQueryTime > 0 {
+		if d, ok := e.Duration("Query_time"); !ok || d < o.MinQueryTime {
+			return false
+		}
+	}
+	if o.Since.IsZero() && o.Until.IsZero() {
+		return true
+	}
+	t, ok := EventTime(e)
+	return ok && (o.Since.IsZero() || !t.Before(o.Since)) && (o.Until.IsZero() || t.Before(o.Until))
+}
+
+// reportOptions returns the options of the text, Markdown and HTML
+// reports of r.
+func (r *Report) reportOptions() []ReportOption {
+	return append([]ReportOption{WithSummary(r.Summary)}, r.opts...)
+}
+
+// WriteText writes r as WriteReport does.
+func (r *Report) WriteText(w io.Writer) error {
+	return WriteReport(w, r.Results, r.reportOptions()...)
+}
+
+// WriteMarkdown writes r as WriteMarkdownReport does.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	return WriteMarkdownReport(w, r.Results, r.reportOptions()...)
+}
+
+// WriteHTML writes r as WriteHTMLReport does.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return WriteHTMLReport(w, r.Results, r.reportOptions()...)
+}
+
+// WriteJSON writes r as the JSON of pt-query-digest, as
+// WritePTDigestJSON does, which has no summary.
+func (r *Report) WriteJSON(w io.Writer) error {
+	return WritePTDigestJSON(w, r.Results, r.opts...)
+}
@@ -0,0 +1,119 @@
+package mysqllog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeFile(t *testing.T) {
+	// The times of the reports are in local time.
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = time.UTC
+
+	opts := AnalyzeOptions{
+		TopN:         3,
+		MinQueryTime: 100 * time.Microsecond,
+		Until:        time.Date(2017, 12, 24, 2, 47, 0, 0, time.UTC),
+		Redact:       true,
+	}
+	report, err := AnalyzeFile("./_test/rds.txt", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Skipped == 0 || report.Summary.Events+report.Skipped != report.Stats.Events {
+		t.Errorf("expected some of the %d events to be skipped, got %d skipped and %d analyzed",
+			report.Stats.Events, report.Skipped, report.Summary.Events)
+	}
+	if !report.Summary.LastEvent.Before(opts.Until) {
+		t.Errorf("expected the events before %v, got one at %v", opts.Until, report.Summary.LastEvent)
+	}
+	for _, s := range report.Results {
+		if statement, _ := s.Sample["Statement"].(string); strings.Contains(statement, "'") {
+			t.Errorf("expected the samples to be redacted, got %q", statement)
+		}
+		if s.MinTime < opts.MinQueryTime.Seconds() {
+			t.Errorf("expected no event faster than %v, got %v for %q", opts.MinQueryTime, s.MinTime, s.Fingerprint)
+		}
+	}
+
+	formats := []struct {
+		golden string
+		write  func(*Report, io.Writer) error
+	}{
+		{"analyze.txt", (*Report).WriteText},
+		{"analyze.md", (*Report).WriteMarkdown},
+		{"analyze.html", (*Report).WriteHTML},
+		{"analyze.json", (*Report).WriteJSON},
+	}
+	for _, f := range formats {
+		var buf bytes.Buffer
+		if err := f.write(report, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if *update {
+			if err := ioutil.WriteFile("./_test/"+f.golden, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		golden, err := ioutil.ReadFile("./_test/" + f.golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), golden) {
+			t.Errorf("report differs from _test/%s (rerun with -update), got\n%s", f.golden, buf.String())
+		}
+	}
+
+	// A gzipped log is the same.
+	log, err := ioutil.ReadFile("./_test/rds.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(log)
+	w.Close()
+	path := filepath.Join(dir, "slow.log.gz")
+	if err := ioutil.WriteFile(path, gz.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gzipped, err := AnalyzeFile(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got bytes.Buffer
+	report.WriteText(&want)
+	gzipped.WriteText(&got)
+	if got.String() != want.String() {
+		t.Errorf("expected the same report for the gzipped log:\n%s", lineDiff(want.String(), got.String()))
+	}
+
+	if _, err := AnalyzeFile(filepath.Join(dir, "missing.log"), opts); !os.IsNotExist(err) {
+		t.Errorf("expected the file not to exist, got %v", err)
+	}
+}
+
+func TestAnalyzeFileErrors(t *testing.T) {
+	report, err := AnalyzeFile("./_test/corrupted.txt", AnalyzeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) == 0 {
+		t.Error("expected the parse errors of the corrupted log")
+	}
+	if len(report.Results) == 0 {
+		t.Error("expected the events to be analyzed past the errors")
+	}
+}
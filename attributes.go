@@ -1,40 +1,239 @@
 package mysqllog
 
+// AttributeKind is the Go type a header attribute is converted to.
+type AttributeKind int
+
 const (
-	attributeTypeFloat = iota
-	attributeTypeInt
-	attributeTypeString
-	attributeTypeBool
+	// AttributeFloat values are float64.
+	AttributeFloat AttributeKind = iota
+	// AttributeInt values are int64.
+	AttributeInt
+	// AttributeString values are kept as they are.
+	AttributeString
+	// AttributeBool values are bools, from "Yes" and "No" or
+	// strconv.ParseBool.
+	AttributeBool
+	// AttributeDuration values are time.Durations, from seconds with
+	// up to nanosecond precision. See WithDurations.
+	AttributeDuration
 )
 
-var attributeTypes = map[string]int{
-	"Thread_id":             attributeTypeInt,
-	"Schema":                attributeTypeString,
-	"Last_errno":            attributeTypeInt,
-	"Killed":                attributeTypeInt,
-	"Query_time":            attributeTypeFloat,
-	"Lock_time":             attributeTypeFloat,
-	"Rows_sent":             attributeTypeInt,
-	"Rows_examined":         attributeTypeInt,
-	"Rows_affected":         attributeTypeInt,
-	"Rows_read":             attributeTypeInt,
-	"Bytes_sent":            attributeTypeInt,
-	"Tmp_tables":            attributeTypeInt,
-	"Tmp_disk_tables":       attributeTypeInt,
-	"Tmp_table_sizes":       attributeTypeInt,
-	"InnoDB_trx_id":         attributeTypeString,
-	"QC_Hit":                attributeTypeBool,
-	"Full_scan":             attributeTypeBool,
-	"Full_join":             attributeTypeBool,
-	"Tmp_table":             attributeTypeBool,
-	"Tmp_table_on_disk":     attributeTypeBool,
-	"Filesort":              attributeTypeBool,
-	"Filesort_on_disk":      attributeTypeBool,
-	"Merge_passes":          attributeTypeInt,
-	"InnoDB_IO_r_ops":       attributeTypeInt,
-	"InnoDB_IO_r_bytes":     attributeTypeInt,
-	"InnoDB_IO_r_wait":      attributeTypeFloat,
-	"InnoDB_rec_lock_wait":  attributeTypeFloat,
-	"InnoDB_queue_wait":     attributeTypeFloat,
-	"InnoDB_pages_distinct": attributeTypeInt,
+func (k AttributeKind) String() string {
+	switch k {
+	case AttributeFloat:
+		return "float"
+	case AttributeInt:
+		return "int"
+	case AttributeString:
+		return "string"
+	case AttributeBool:
+		return "bool"
+	case AttributeDuration:
+		return "duration"
+	}
+	return "unknown"
+}
+
+// WithAttribute converts the attribute name to kind, overriding the
+// built-in type, if any. For example, Thread_id can be kept as a string
+// for proxies whose ids overflow int64. It only changes the types of
+// the Parser it's given to.
+func WithAttribute(name string, kind AttributeKind) Option {
+	return func(p *Parser) {
+		p.attributes.setKind(name, kind)
+	}
+}
+
+// WithAttributeParser converts the attribute name with fn, overriding
+// the built-in type, if any. The attribute is left out of the event if
+// fn returns an error.
+func WithAttributeParser(name string, fn func(string) (interface{}, error)) Option {
+	return func(p *Parser) {
+		p.attributes.setParser(name, fn)
+	}
+}
+
+// attributeTable is the attribute types of a Parser, along with the
+// functions given with WithAttributeParser and the units given with
+// WithAttributeUnit. It reads the package
+// defaults until an option changes a type, and copies them then, so
+// Parsers never see each other's types.
+type attributeTable struct {
+	kinds     map[string]AttributeKind
+	tidbKinds map[string]AttributeKind
+	parsers   map[string]func(string) (interface{}, error)
+	units     map[string]Unit
+}
+
+// kind returns the type of the attribute key, and whether it's known.
+func (t *attributeTable) kind(key string) (AttributeKind, bool) {
+	kinds := t.kinds
+	if kinds == nil {
+		kinds = attributeTypes
+	}
+	kind, ok := kinds[key]
+	return kind, ok
+}
+
+// tidbKind returns the type of a TiDB attribute, falling back to the
+// MySQL attribute types, and whether it's known.
+func (t *attributeTable) tidbKind(key string) (AttributeKind, bool) {
+	kinds := t.tidbKinds
+	if kinds == nil {
+		kinds = tidbAttributeTypes
+	}
+	if kind, ok := kinds[key]; ok {
+		return kind, true
+	}
+	return t.kind(key)
+}
+
+// parser returns the function given for the attribute key, if any.
+func (t *attributeTable) parser(key string) (func(string) (interface{}, error), bool) {
+	fn, ok := t.parsers[key]
+	return fn, ok
+}
+
+// setKind sets the type of the attribute key, for TiDB too.
+func (t *attributeTable) setKind(key string, kind AttributeKind) {
+	if t.kinds == nil {
+		t.kinds = copyKinds(attributeTypes)
+		t.tidbKinds = copyKinds(tidbAttributeTypes)
+	}
+	t.kinds[key] = kind
+	if _, ok := t.tidbKinds[key]; ok {
+		t.tidbKinds[key] = kind
+	}
+	delete(t.parsers, key)
+}
+
+func (t *attributeTable) setParser(key string, fn func(string) (interface{}, error)) {
+	if t.parsers == nil {
+		t.parsers = map[string]func(string) (interface{}, error){}
+	}
+	t.parsers[key] = fn
+}
+
+func copyKinds(kinds map[string]AttributeKind) map[string]AttributeKind {
+	c := make(map[string]AttributeKind, len(kinds))
+	for key, kind := range kinds {
+		c[key] = kind
+	}
+	return c
+}
+
+// convertAttribute converts the value of the attribute key with the
+// parser's registered attributes, falling back to the built-in kind. It
+// returns nil if the value can't be converted.
+func (p *Parser) convertAttribute(key, value string, builtin AttributeKind) interface{} {
+	if fn, ok := p.attributes.parser(key); ok {
+		v, err := fn(value)
+		if err != nil {
+			return nil
+		}
+		return v
+	}
+	if p.durations && builtin == AttributeFloat && isDurationAttribute(key) {
+		builtin = AttributeDuration
+	}
+	if unit, ok := p.attributes.units[key]; ok {
+		return convertUnit(builtin, unit, value)
+	}
+	return convertAttribute(builtin, value)
+}
+
+// UnknownAttributes is what a Parser does with header attributes it
+// has no type for.
+type UnknownAttributes int
+
+const (
+	// DropUnknown leaves unknown attributes out of events, except for
+	// numeric values, which are kept as float64 as in earlier versions.
+	DropUnknown UnknownAttributes = iota
+	// KeepUnknown stores unknown attributes as raw strings in a
+	// map[string]string under the "Unknown" key, so they can't collide
+	// with known ones.
+	KeepUnknown
+)
+
+// WithUnknownAttributes sets what to do with header attributes that
+// have neither a built-in type nor one registered with WithAttribute or
+// WithAttributeParser. The default is DropUnknown.
+func WithUnknownAttributes(mode UnknownAttributes) Option {
+	return func(p *Parser) {
+		p.unknown = mode
+	}
+}
+
+// keepUnknown stores the attribute key in the "Unknown" map of event if
+// it's unknown and p keeps unknown attributes. It reports whether it
+// did.
+func (p *Parser) keepUnknown(event LogEvent, key, value string, known bool) bool {
+	if known || p.unknown != KeepUnknown {
+		return false
+	}
+	if _, ok := p.attributes.parser(key); ok {
+		return false
+	}
+	unknown, _ := event["Unknown"].(map[string]string)
+	if unknown == nil {
+		unknown = map[string]string{}
+		event["Unknown"] = unknown
+	}
+	unknown[key] = value
+	return true
+}
+
+var attributeTypes = map[string]AttributeKind{
+	"Thread_id":             AttributeInt,
+	"Schema":                AttributeString,
+	"Last_errno":            AttributeInt,
+	"Killed":                AttributeInt,
+	"Query_time":            AttributeFloat,
+	"Lock_time":             AttributeFloat,
+	"Rows_sent":             AttributeInt,
+	"Rows_examined":         AttributeInt,
+	"Rows_affected":         AttributeInt,
+	"Rows_read":             AttributeInt,
+	"Bytes_sent":            AttributeInt,
+	"Tmp_tables":            AttributeInt,
+	"Tmp_disk_tables":       AttributeInt,
+	"Tmp_table_sizes":       AttributeInt,
+	"InnoDB_trx_id":         AttributeString,
+	"QC_Hit":                AttributeBool,
+	"Full_scan":             AttributeBool,
+	"Full_join":             AttributeBool,
+	"Tmp_table":             AttributeBool,
+	"Tmp_table_on_disk":     AttributeBool,
+	"Filesort":              AttributeBool,
+	"Filesort_on_disk":      AttributeBool,
+	"Merge_passes":          AttributeInt,
+	"InnoDB_IO_r_ops":       AttributeInt,
+	"InnoDB_IO_r_bytes":     AttributeInt,
+	"InnoDB_IO_r_wait":      AttributeFloat,
+	"InnoDB_rec_lock_wait":  AttributeFloat,
+	"InnoDB_queue_wait":     AttributeFloat,
+	"InnoDB_pages_distinct": AttributeInt,
+	"Log_slow_rate_type":    AttributeString,
+	"Log_slow_rate_limit":   AttributeInt,
+	// MariaDB
+	"QC_hit": AttributeBool,
+	// MySQL 8 with log_slow_extra
+	"Errno":                   AttributeInt,
+	"Bytes_received":          AttributeInt,
+	"Read_first":              AttributeInt,
+	"Read_last":               AttributeInt,
+	"Read_key":                AttributeInt,
+	"Read_next":               AttributeInt,
+	"Read_prev":               AttributeInt,
+	"Read_rnd":                AttributeInt,
+	"Read_rnd_next":           AttributeInt,
+	"Sort_merge_passes":       AttributeInt,
+	"Sort_range_count":        AttributeInt,
+	"Sort_rows":               AttributeInt,
+	"Sort_scan_count":         AttributeInt,
+	"Created_tmp_disk_tables": AttributeInt,
+	"Created_tmp_tables":      AttributeInt,
+	"Start":                   AttributeString,
+	"End":                     AttributeString,
 }
@@ -0,0 +1,113 @@
+package mysqllog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttributeRegistry(t *testing.T) {
+	p := NewParser(
+		WithAttributeParser("Queue_wait", func(value string) (interface{}, error) {
+			return time.ParseDuration(value)
+		}),
+		WithAttribute("Thread_id", AttributeString),
+		WithAttribute("Rows_sent", AttributeBool),
+	)
+	events := parseAll(p, "# Thread_id: 18446744073709551617  Queue_wait: 1.5ms  Rows_sent: 10  Rows_examined: 3\nSELECT 1;\n")
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	e := events[0]
+	if e["Queue_wait"] != 1500*time.Microsecond {
+		t.Errorf("expected a parsed duration, got %#v", e["Queue_wait"])
+	}
+	if e["Thread_id"] != "18446744073709551617" {
+		t.Errorf("expected Thread_id as a string, got %#v", e["Thread_id"])
+	}
+	if _, ok := e["Rows_sent"]; ok {
+		t.Errorf("expected an invalid value to be left out, got %#v", e["Rows_sent"])
+	}
+	if e["Rows_examined"] != int64(3) {
+		t.Errorf("expected built-in types by default, got %#v", e["Rows_examined"])
+	}
+
+	// Registrations don't leak into other parsers.
+	events = parseAll(&Parser{}, "# Thread_id: 7\nSELECT 1;\n")
+	if events[0]["Thread_id"] != int64(7) {
+		t.Errorf("expected the built-in type, got %#v", events[0]["Thread_id"])
+	}
+}
+
+func TestUnknownAttributes(t *testing.T) {
+	log := "# Time: 2018-03-01T10:00:00.000000Z\n# Query_time: 1.5  Lock_time: 0.1  Foo_bar: 7  Foo_mode: fast\nSELECT 1;\n"
+
+	events := parseAll(NewParser(WithUnknownAttributes(KeepUnknown)), log)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	unknown, _ := events[0]["Unknown"].(map[string]string)
+	if unknown["Foo_bar"] != "7" || unknown["Foo_mode"] != "fast" {
+		t.Errorf("expected unknown attributes as strings, got %#v", events[0]["Unknown"])
+	}
+	if _, ok := events[0]["Foo_bar"]; ok {
+		t.Errorf("expected Foo_bar only under Unknown")
+	}
+	if events[0]["Query_time"] != 1.5 {
+		t.Errorf("expected known attributes to be typed, got %#v", events[0]["Query_time"])
+	}
+
+	// A registered attribute isn't unknown.
+	events = parseAll(NewParser(WithUnknownAttributes(KeepUnknown), WithAttribute("Foo_bar", AttributeInt)), log)
+	if events[0]["Foo_bar"] != int64(7) {
+		t.Errorf("expected Foo_bar to be promoted, got %#v", events[0]["Foo_bar"])
+	}
+	if unknown, _ := events[0]["Unknown"].(map[string]string); len(unknown) != 1 {
+		t.Errorf("expected only Foo_mode to be unknown, got %#v", unknown)
+	}
+
+	events = parseAll(&Parser{}, log)
+	if _, ok := events[0]["Unknown"]; ok {
+		t.Errorf("expected unknown attributes to be dropped by default")
+	}
+	if _, ok := events[0]["Foo_mode"]; ok {
+		t.Errorf("expected Foo_mode to be dropped by default")
+	}
+}
+
+func TestAttributeTablesConcurrent(t *testing.T) {
+	mysqlLog := "# Thread_id: 7  Query_time: 1.5  Rows_sent: 1\nSELECT 1;\n"
+	tidbLog := "# Time: 2023-08-01T10:00:00.123456789Z\n# Txn_start_ts: 442  Conn_ID: 9\n# Query_time: 1.5\nSELECT 1;\n"
+	type TestCase struct {
+		name     string
+		opts     []Option
+		log      string
+		key      string
+		expected interface{}
+	}
+	cases := []TestCase{
+		{"mysql", []Option{WithAttribute("Thread_id", AttributeString)}, mysqlLog, "Thread_id", "7"},
+		{"mysql default", nil, mysqlLog, "Thread_id", int64(7)},
+		{"tidb", []Option{WithDialect(TiDB), WithAttribute("Conn_ID", AttributeString)}, tidbLog, "Conn_ID", "9"},
+		{"tidb default", []Option{WithDialect(TiDB), WithAttribute("Thread_id", AttributeFloat)}, tidbLog, "Conn_ID", int64(9)},
+	}
+	done := make(chan bool)
+	for _, c := range cases {
+		go func(c TestCase) {
+			defer func() { done <- true }()
+			for i := 0; i < 100; i++ {
+				// Options are applied while the other parsers run.
+				events := parseAll(NewParser(append(c.opts, WithStrict())...), c.log)
+				if len(events) != 1 || events[0][c.key] != c.expected {
+					t.Errorf("%s: expected %s %#v, got %v", c.name, c.key, c.expected, events)
+					return
+				}
+			}
+		}(c)
+	}
+	for range cases {
+		<-done
+	}
+	if attributeTypes["Thread_id"] != AttributeInt || tidbAttributeTypes["Conn_ID"] != AttributeInt {
+		t.Error("expected the package defaults to be unchanged")
+	}
+}
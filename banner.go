@@ -0,0 +1,113 @@
+package mysqllog
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ServerInfo is what the startup banners of a log tell about the server,
+// from a banner such as:
+//
+//	/usr/sbin/mysqld, Version: 8.0.33-25 (Percona Server (GPL), Release 25, Revision 60c9e2c5). started with:
+//	Tcp port: 3306  Unix socket: /var/run/mysqld/mysqld.sock
+type ServerInfo struct {
+	// Binary is the path of the server, such as "/usr/sbin/mysqld".
+	Binary string
+	// Version is the server version without the "-log" suffix, such as
+	// "8.0.33-25" or "10.6.12-MariaDB".
+	Version string
+	// Flavor is the dialect the banner is from.
+	Flavor Dialect
+	// Comment is the text in parentheses after the version, such as
+	// "Percona Server (GPL), Release 25, Revision 60c9e2c5".
+	Comment string
+	// Port and Socket are from the line after the banner, if any.
+	Port   int
+	Socket string
+
+	// Banners is the number of banners seen, one per server start, and
+	// Line the line number of the last one. The other fields are from
+	// the last one.
+	Banners int
+	Line    int
+}
+
+// Labels for events of WithServerLabels.
+const (
+	ServerVersionLabel = "server_version"
+	ServerFlavorLabel  = "server_flavor"
+)
+
+// ServerInfo returns what the startup banners seen so far tell about
+// the server. It's the zero ServerInfo if there was none.
+func (p *Parser) ServerInfo() ServerInfo {
+	return p.server
+}
+
+// WithServerLabels adds the Version and Flavor of the ServerInfo of the
+// banner before each event to its "Labels", as ServerVersionLabel and
+// ServerFlavorLabel. Events before the first banner don't have them, and
+// labels given with WithLabels win.
+func WithServerLabels() Option {
+	return func(p *Parser) {
+		p.serverLabels = true
+	}
+}
+
+// isBanner reports whether line is a server startup banner.
+func isBanner(line string) bool {
+	return strings.HasSuffix(strings.TrimRight(line, "\r\n"), "started with:")
+}
+
+// bannerDialect returns the dialect of the server of a banner.
+func bannerDialect(line string) Dialect {
+	switch {
+	case strings.Contains(line, "MariaDB"):
+		return MariaDB
+	case strings.Contains(line, "Percona"):
+		return Percona
+	case strings.Contains(line, "TiDB"):
+		return TiDB
+	}
+	return MySQL
+}
+
+// checkServer updates the ServerInfo of p from line, the current one,
+// if it's a banner or the port line after one.
+func (p *Parser) checkServer(line string) {
+	if isBanner(line) {
+		p.server = parseBanner(line, p.server.Banners+1, p.line)
+		return
+	}
+	if p.server.Line > 0 && p.server.Line == p.line-1 && strings.HasPrefix(line, "Tcp port:") {
+		rest := strings.TrimRight(line[len("Tcp port:"):], "\r\n")
+		if i := strings.Index(rest, "Unix socket:"); i >= 0 {
+			p.server.Socket = strings.TrimSpace(rest[i+len("Unix socket:"):])
+			rest = rest[:i]
+		}
+		p.server.Port, _ = strconv.Atoi(strings.TrimSpace(rest))
+	}
+}
+
+// parseBanner parses a banner, the nth, on line number lineNumber.
+func parseBanner(line string, n, lineNumber int) ServerInfo {
+	info := ServerInfo{Flavor: bannerDialect(line), Banners: n, Line: lineNumber}
+	text := strings.TrimSuffix(strings.TrimRight(line, "\r\n"), "started with:")
+	text = strings.TrimSuffix(strings.TrimSpace(text), ".")
+	i := strings.Index(text, ", Version: ")
+	if i < 0 {
+		return info
+	}
+	info.Binary = text[:i]
+	text = text[i+len(", Version: "):]
+	version := text
+	if i := strings.IndexByte(text, ' '); i >= 0 {
+		version = text[:i]
+		comment := strings.TrimSpace(text[i:])
+		if strings.HasPrefix(comment, "(") && strings.HasSuffix(comment, ")") {
+			info.Comment = comment[1 : len(comment)-1]
+		}
+	}
+	info.Version = strings.TrimSuffix(version, "-log")
+	return info
+}
@@ -0,0 +1,98 @@
+package mysqllog
+
+import (
+	"bufio"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBanner(t *testing.T) {
+	type TestCase struct {
+		line     string
+		expected ServerInfo
+	}
+	for _, c := range []TestCase{
+		{
+			"/usr/sbin/mysqld, Version: 8.0.33 (MySQL Community Server - GPL). started with:\n",
+			ServerInfo{Binary: "/usr/sbin/mysqld", Version: "8.0.33", Flavor: MySQL, Comment: "MySQL Community Server - GPL"},
+		},
+		{
+			"/rdsdbbin/mysql/bin/mysqld, Version: 5.7.16-log (MySQL Community Server (GPL)). started with:\n",
+			ServerInfo{Binary: "/rdsdbbin/mysql/bin/mysqld", Version: "5.7.16", Flavor: MySQL, Comment: "MySQL Community Server (GPL)"},
+		},
+		{
+			"/usr/sbin/mysqld, Version: 8.0.33-25 (Percona Server (GPL), Release 25, Revision 60c9e2c5). started with:\n",
+			ServerInfo{Binary: "/usr/sbin/mysqld", Version: "8.0.33-25", Flavor: Percona, Comment: "Percona Server (GPL), Release 25, Revision 60c9e2c5"},
+		},
+		{
+			"/usr/sbin/mariadbd, Version: 10.6.12-MariaDB-log (MariaDB Server). started with:\n",
+			ServerInfo{Binary: "/usr/sbin/mariadbd", Version: "10.6.12-MariaDB", Flavor: MariaDB, Comment: "MariaDB Server"},
+		},
+		{
+			"/usr/sbin/mysqld, Version: 5.6.51-log. started with:\r\n",
+			ServerInfo{Binary: "/usr/sbin/mysqld", Version: "5.6.51", Flavor: MySQL},
+		},
+	} {
+		c.expected.Banners, c.expected.Line = 1, 1
+		if info := parseBanner(c.line, 1, 1); info != c.expected {
+			t.Errorf("%q: expected %+v, got %+v", c.line, c.expected, info)
+		}
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/banners.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewParser(WithServerLabels(), WithLabels(map[string]string{"env": "prod"}))
+	var events []LogEvent
+	reader := bufio.NewReader(strings.NewReader(string(b)))
+	for line, err := reader.ReadString('\n'); err == nil; line, err = reader.ReadString('\n') {
+		if e := p.ConsumeLine(line); e != nil {
+			events = append(events, e)
+		}
+	}
+	if e := p.Flush(); e != nil {
+		events = append(events, e)
+	}
+
+	expected := ServerInfo{
+		Binary:  "/usr/sbin/mariadbd",
+		Version: "10.6.12-MariaDB",
+		Flavor:  MariaDB,
+		Comment: "MariaDB Server",
+		Socket:  "/run/mysqld/mysqld.sock",
+		Banners: 3,
+		Line:    22,
+	}
+	if info := p.ServerInfo(); info != expected {
+		t.Errorf("expected %+v, got %+v", expected, info)
+	}
+	first := NewParser()
+	parseAll(first, strings.Join(strings.SplitAfter(string(b), "\n")[:8], ""))
+	if info := first.ServerInfo(); info.Port != 3306 || info.Socket != "/var/run/mysqld/mysqld.sock" || info.Banners != 1 {
+		t.Errorf("expected the port and socket of the first banner, got %+v", info)
+	}
+
+	labels := []map[string]string{
+		{"env": "prod"},
+		{"env": "prod", ServerVersionLabel: "8.0.33", ServerFlavorLabel: "MySQL"},
+		{"env": "prod", ServerVersionLabel: "8.0.33-25", ServerFlavorLabel: "Percona"},
+		{"env": "prod", ServerVersionLabel: "10.6.12-MariaDB", ServerFlavorLabel: "MariaDB"},
+	}
+	if len(events) != len(labels) {
+		t.Fatalf("expected %d events, got %d", len(labels), len(events))
+	}
+	for i, want := range labels {
+		if !reflect.DeepEqual(events[i]["Labels"], want) {
+			t.Errorf("event %d: expected labels %v, got %v", i, want, events[i]["Labels"])
+		}
+	}
+
+	if info := NewParser().ServerInfo(); info != (ServerInfo{}) {
+		t.Errorf("expected no ServerInfo without a banner, got %+v", info)
+	}
+}
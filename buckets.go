@@ -0,0 +1,177 @@
+package mysqllog
+
+import (
+	"sort"
+	"time"
+)
+
+// BucketStats summarizes the events of a fingerprint within one time bucket.
+type BucketStats struct {
+	Start       time.Time
+	Fingerprint string
+	Count       int64
+	TotalTime   float64
+	MeanTime    float64
+	// P95Time is approximate; see WithBucketAccuracy.
+	P95Time float64
+}
+
+// TimeBuckets aggregates events per fingerprint per fixed time interval,
+// keyed by event timestamp, for trend analysis.
+type TimeBuckets struct {
+	interval  time.Duration
+	retention time.Duration
+	location  *time.Location
+	accuracy  float64
+
+	buckets map[time.Time]map[string]*bucketEntry
+	newest  time.Time
+
+	// Skipped counts events without a usable timestamp or older than the
+	// retention window.
+	Skipped int64
+}
+
+type bucketEntry struct {
+	totalTime float64
+	times     *Sketch
+}
+
+// TimeBucketsOption configures TimeBuckets.
+type TimeBucketsOption func(*TimeBuckets)
+
+// WithBucketRetention evicts buckets starting more than d before the
+// newest bucket, bounding memory when streaming.
+func WithBucketRetention(d time.Duration) TimeBucketsOption {
+	return func(b *TimeBuckets) {
+		b.retention = d
+	}
+}
+
+// WithBucketAccuracy sets the relative accuracy of BucketStats.P95Time,
+// DefaultSketchAccuracy by default, as WithQuantileAccuracy does for an
+// Aggregator. See Sketch.
+func WithBucketAccuracy(accuracy float64) TimeBucketsOption {
+	return func(b *TimeBuckets) {
+		b.accuracy = accuracy
+	}
+}
+
+// WithBucketLocation aligns buckets to local time in loc: intervals of
+// whole days start at local midnight even across DST transitions, and
+// shorter intervals are counted from local midnight. By default buckets
+// are aligned to UTC.
+func WithBucketLocation(loc *time.Location) TimeBucketsOption {
+	return func(b *TimeBuckets) {
+		b.location = loc
+	}
+}
+
+// DefaultBucketInterval is the interval of TimeBuckets created with an
+// interval of zero or less.
+const DefaultBucketInterval = time.Minute
+
+// NewTimeBuckets returns TimeBuckets with the given interval, or
+// DefaultBucketInterval if it isn't positive.
+func NewTimeBuckets(interval time.Duration, opts ...TimeBucketsOption) *TimeBuckets {
+	if interval <= 0 {
+		interval = DefaultBucketInterval
+	}
+	b := &TimeBuckets{
+		interval: interval,
+		buckets:  map[time.Time]map[string]*bucketEntry{},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// bucketStart returns the start of the bucket containing t.
+func (b *TimeBuckets) bucketStart(t time.Time) time.Time {
+	if b.location == nil {
+		return t.UTC().Truncate(b.interval)
+	}
+	t = t.In(b.location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, b.location)
+	const day = 24 * time.Hour
+	if b.interval%day == 0 {
+		// Count calendar days so DST days of 23 or 25 hours still
+		// map to a single bucket.
+		days := int(b.interval / day)
+		n := int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+		return midnight.AddDate(0, 0, -(n % days))
+	}
+	return midnight.Add(t.Sub(midnight) / b.interval * b.interval)
+}
+
+// Add accumulates e into the bucket for its timestamp.
+func (b *TimeBuckets) Add(e LogEvent) {
+	ts, ok := EventTime(e)
+	if !ok {
+		b.Skipped++
+		return
+	}
+	start := b.bucketStart(ts)
+	if start.After(b.newest) {
+		b.newest = start
+		b.evict()
+	}
+	if b.retention > 0 && b.newest.Sub(start) > b.retention {
+		b.Skipped++
+		return
+	}
+
+	fingerprints := b.buckets[start]
+	if fingerprints == nil {
+		fingerprints = map[string]*bucketEntry{}
+		b.buckets[start] = fingerprints
+	}
+	statement, _ := e["Statement"].(string)
+	fingerprint := Fingerprint(statement)
+	entry := fingerprints[fingerprint]
+	if entry == nil {
+		entry = &bucketEntry{times: NewSketch(b.accuracy)}
+		fingerprints[fingerprint] = entry
+	}
+	queryTime, _ := e.Float64("Query_time")
+	entry.totalTime += queryTime
+	entry.times.Add(queryTime)
+}
+
+func (b *TimeBuckets) evict() {
+	if b.retention <= 0 {
+		return
+	}
+	for start := range b.buckets {
+		if b.newest.Sub(start) > b.retention {
+			delete(b.buckets, start)
+		}
+	}
+}
+
+// Series returns the stats of every fingerprint in every bucket, ordered
+// by bucket start and then fingerprint.
+func (b *TimeBuckets) Series() []BucketStats {
+	var series []BucketStats
+	for start, fingerprints := range b.buckets {
+		for fingerprint, entry := range fingerprints {
+			count := entry.times.Count()
+			series = append(series, BucketStats{
+				Start:       start,
+				Fingerprint: fingerprint,
+				Count:       count,
+				TotalTime:   entry.totalTime,
+				MeanTime:    entry.totalTime / float64(count),
+				P95Time:     entry.times.Quantile(0.95),
+			})
+		}
+	}
+	sort.Slice(series, func(i, j int) bool {
+		if !series[i].Start.Equal(series[j].Start) {
+			return series[i].Start.Before(series[j].Start)
+		}
+		return series[i].Fingerprint < series[j].Fingerprint
+	})
+	return series
+}
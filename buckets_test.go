@@ -0,0 +1,108 @@
+package mysqllog
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTimeBucketsBoundaries(t *testing.T) {
+	base := time.Date(2017, 12, 24, 10, 0, 0, 0, time.UTC)
+	b := NewTimeBuckets(time.Minute)
+	for _, e := range []LogEvent{
+		{"Statement": "SELECT 1", "Query_time": 1.0, "Timestamp": base},
+		{"Statement": "SELECT 2", "Query_time": 3.0, "Timestamp": base.Add(59*time.Second + 999*time.Millisecond)},
+		{"Statement": "SELECT 3", "Query_time": 2.0, "Timestamp": base.Add(time.Minute)},
+		{"Statement": "UPDATE t SET x = 1", "Query_time": 5.0, "Timestamp": base.Add(90 * time.Second)},
+		{"Statement": "SELECT 4", "Query_time": 5.0},
+	} {
+		b.Add(e)
+	}
+
+	series := b.Series()
+	if len(series) != 3 {
+		t.Fatalf("expected 3 points, got %+v", series)
+	}
+	first := series[0]
+	if !first.Start.Equal(base) || first.Fingerprint != "select ?" || first.Count != 2 || first.MeanTime != 2 || math.Abs(first.P95Time-3) > 3*DefaultSketchAccuracy {
+		t.Errorf("unexpected first point %+v", first)
+	}
+	if !series[1].Start.Equal(base.Add(time.Minute)) || series[1].Count != 1 || series[1].Fingerprint != "select ?" {
+		t.Errorf("unexpected second point %+v", series[1])
+	}
+	if !series[2].Start.Equal(base.Add(time.Minute)) || series[2].Fingerprint != "update t set x = ?" {
+		t.Errorf("unexpected third point %+v", series[2])
+	}
+	if b.Skipped != 1 {
+		t.Errorf("expected 1 skipped event, got %d", b.Skipped)
+	}
+}
+
+func TestTimeBucketsRetention(t *testing.T) {
+	base := time.Date(2017, 12, 24, 10, 0, 0, 0, time.UTC)
+	b := NewTimeBuckets(time.Minute, WithBucketRetention(2*time.Minute))
+	for i := 0; i < 10; i++ {
+		b.Add(LogEvent{"Statement": "SELECT 1", "Query_time": 1.0, "Timestamp": base.Add(time.Duration(i) * time.Minute)})
+	}
+	// Too old for the retention window.
+	b.Add(LogEvent{"Statement": "SELECT 1", "Query_time": 1.0, "Timestamp": base})
+
+	series := b.Series()
+	if len(series) != 3 || !series[0].Start.Equal(base.Add(7*time.Minute)) {
+		t.Errorf("expected the last 3 buckets, got %+v", series)
+	}
+	if b.Skipped != 1 {
+		t.Errorf("expected 1 skipped event, got %d", b.Skipped)
+	}
+}
+
+func TestTimeBucketsDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// November 5th 2023 is 25 hours long in New York.
+	days := NewTimeBuckets(24*time.Hour, WithBucketLocation(loc))
+	midnight := time.Date(2023, 11, 5, 0, 0, 0, 0, loc)
+	for _, ts := range []time.Time{
+		midnight.Add(30 * time.Minute),
+		time.Date(2023, 11, 5, 23, 30, 0, 0, loc),
+		time.Date(2023, 11, 6, 0, 0, 0, 0, loc),
+	} {
+		days.Add(LogEvent{"Statement": "SELECT 1", "Query_time": 1.0, "Timestamp": ts})
+	}
+	series := days.Series()
+	if len(series) != 2 || !series[0].Start.Equal(midnight) || series[0].Count != 2 {
+		t.Fatalf("expected the 25 hour day in one bucket, got %+v", series)
+	}
+	if !series[1].Start.Equal(time.Date(2023, 11, 6, 0, 0, 0, 0, loc)) {
+		t.Errorf("expected the next day to start at local midnight, got %v", series[1].Start)
+	}
+
+	// 1:30 happens twice; each is its own hourly bucket.
+	hours := NewTimeBuckets(time.Hour, WithBucketLocation(loc))
+	firstOneThirty := midnight.Add(90 * time.Minute)
+	for _, ts := range []time.Time{firstOneThirty, firstOneThirty.Add(time.Hour)} {
+		hours.Add(LogEvent{"Statement": "SELECT 1", "Query_time": 1.0, "Timestamp": ts})
+	}
+	series = hours.Series()
+	if len(series) != 2 || !series[0].Start.Equal(midnight.Add(time.Hour)) || !series[1].Start.Equal(midnight.Add(2*time.Hour)) {
+		t.Errorf("unexpected hourly buckets %+v", series)
+	}
+}
+
+func TestTimeBucketsZeroInterval(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+	base := time.Date(2017, 12, 24, 10, 0, 30, 0, time.UTC)
+	for _, b := range []*TimeBuckets{NewTimeBuckets(0), NewTimeBuckets(-time.Hour, WithBucketLocation(loc))} {
+		b.Add(LogEvent{"Statement": "SELECT 1", "Query_time": 1.0, "Timestamp": base})
+		b.Add(LogEvent{"Statement": "SELECT 2", "Query_time": 1.0, "Timestamp": base.Add(10 * time.Second)})
+		if series := b.Series(); len(series) != 1 || !series[0].Start.Equal(base.Truncate(DefaultBucketInterval)) || series[0].Count != 2 {
+			t.Errorf("expected a bucket of %v, got %+v", DefaultBucketInterval, series)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package mysqllog
+
+import (
+	"container/list"
+	"time"
+)
+
+// Defaults for BurstDetector.
+const (
+	DefaultBurstConnections  = 10000
+	DefaultBurstFingerprints = 8
+)
+
+// Burst describes a run of identical fingerprints on one connection,
+// typical of N+1 query patterns.
+type Burst struct {
+	// Connection is the connection Id of the events.
+	Connection  int64
+	Fingerprint string
+	// Count is the number of occurrences within the window when the
+	// burst was detected.
+	Count int
+	Start time.Time
+	End   time.Time
+	// Sample is the event that completed the burst.
+	Sample LogEvent
+}
+
+// BurstDetector is a Sink that calls Notify when a connection runs the
+// same fingerprint more than Threshold times within Window. Events
+// without an "Id" are ignored, and events without a timestamp are
+// attributed to the time they're written. Once a burst is reported, the
+// connection and fingerprint isn't reported again until it has been
+// idle for Window.
+//
+// Memory is bounded: each connection keeps ring buffers for at most
+// MaxFingerprints recent fingerprints, and at most MaxConnections
+// connections are tracked, forgetting the least recently seen first.
+// A BurstDetector is not safe for concurrent use.
+type BurstDetector struct {
+	Threshold int
+	Window    time.Duration
+	// MaxConnections is DefaultBurstConnections if zero.
+	MaxConnections int
+	// MaxFingerprints is DefaultBurstFingerprints if zero.
+	MaxFingerprints int
+	Notify          func(Burst)
+	// Clock tells the time of events without a timestamp, RealClock if
+	// nil.
+	Clock Clock
+
+	lru         *list.List
+	connections map[int64]*list.Element
+}
+
+type burstConnection struct {
+	id           int64
+	fingerprints []burstFingerprint
+}
+
+type burstFingerprint struct {
+	fingerprint string
+	ring        timeRing
+	last        time.Time
+	reported    bool
+}
+
+// Write records e and checks its connection for a burst.
+func (d *BurstDetector) Write(e LogEvent) error {
+	id, ok := e.Int64("Id")
+	if !ok || d.Threshold <= 0 {
+		return nil
+	}
+	ts, ok := EventTime(e)
+	if !ok {
+		ts = clockOf(d.Clock).Now()
+	}
+	statement, _ := e["Statement"].(string)
+	f := d.connection(id).fingerprint(Fingerprint(statement), d.Threshold, d.maxFingerprints())
+
+	if f.reported && ts.Sub(f.last) > d.Window {
+		f.reported = false
+	}
+	f.last = ts
+	f.ring.push(ts)
+	if f.reported || !f.ring.full() || ts.Sub(f.ring.oldest()) > d.Window {
+		return nil
+	}
+	f.reported = true
+	if d.Notify != nil {
+		d.Notify(Burst{
+			Connection:  id,
+			Fingerprint: f.fingerprint,
+			Count:       f.ring.len(),
+			Start:       f.ring.oldest(),
+			End:         ts,
+			Sample:      e,
+		})
+	}
+	return nil
+}
+
+// Close implements Sink. It does nothing.
+func (d *BurstDetector) Close() error {
+	return nil
+}
+
+func (d *BurstDetector) maxFingerprints() int {
+	if d.MaxFingerprints > 0 {
+		return d.MaxFingerprints
+	}
+	return DefaultBurstFingerprints
+}
+
+// connection returns the state for id, marking it recently used.
+func (d *BurstDetector) connection(id int64) *burstConnection {
+	if d.connections == nil {
+		d.connections = map[int64]*list.Element{}
+		d.lru = list.New()
+	}
+	if el, ok := d.connections[id]; ok {
+		d.lru.MoveToFront(el)
+		return el.Value.(*burstConnection)
+	}
+
+	max := d.MaxConnections
+	if max <= 0 {
+		max = DefaultBurstConnections
+	}
+	for d.lru.Len() >= max {
+		oldest := d.lru.Back()
+		d.lru.Remove(oldest)
+		delete(d.connections, oldest.Value.(*burstConnection).id)
+	}
+	c := &burstConnection{id: id}
+	d.connections[id] = d.lru.PushFront(c)
+	return c
+}
+
+// fingerprint returns the state for fingerprint, keeping it first.
+// When max fingerprints are tracked the least recently seen is replaced.
+func (c *burstConnection) fingerprint(fingerprint string, threshold, max int) *burstFingerprint {
+	i := 0
+	for i < len(c.fingerprints) && c.fingerprints[i].fingerprint != fingerprint {
+		i++
+	}
+	if i == len(c.fingerprints) {
+		f := burstFingerprint{fingerprint: fingerprint, ring: timeRing{times: make([]time.Time, threshold+1)}}
+		if len(c.fingerprints) < max {
+			c.fingerprints = append(c.fingerprints, f)
+		} else {
+			i = len(c.fingerprints) - 1
+			c.fingerprints[i] = f
+		}
+	}
+	f := c.fingerprints[i]
+	copy(c.fingerprints[1:i+1], c.fingerprints[:i])
+	c.fingerprints[0] = f
+	return &c.fingerprints[0]
+}
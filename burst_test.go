@@ -0,0 +1,62 @@
+package mysqllog
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBurstDetector(t *testing.T) {
+	start := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	var bursts []Burst
+	d := &BurstDetector{
+		Threshold: 10,
+		Window:    2 * time.Second,
+		Notify:    func(b Burst) { bursts = append(bursts, b) },
+	}
+
+	write := func(id int64, statement string, offset time.Duration) {
+		d.Write(LogEvent{"Id": id, "Statement": statement, "Timestamp": start.Add(offset)})
+	}
+	// An N+1 pattern on connection 1, interleaved with its parent query,
+	// and the same query spread out over time on connection 2.
+	write(1, "SELECT * FROM orders WHERE customer = 1", 0)
+	for i := 0; i < 50; i++ {
+		write(1, fmt.Sprintf("SELECT * FROM items WHERE order_id = %d", i), time.Duration(i)*10*time.Millisecond)
+		write(2, fmt.Sprintf("SELECT * FROM items WHERE order_id = %d", i), time.Duration(i)*time.Second)
+	}
+
+	if len(bursts) != 1 {
+		t.Fatalf("expected 1 burst, got %+v", bursts)
+	}
+	b := bursts[0]
+	if b.Connection != 1 || b.Fingerprint != "select * from items where order_id = ?" || b.Count != 11 ||
+		b.End.Sub(b.Start) != 100*time.Millisecond || b.Sample["Statement"] != "SELECT * FROM items WHERE order_id = 10" {
+		t.Errorf("unexpected burst %+v", b)
+	}
+
+	// After the connection is idle for the window, the burst is reported again.
+	for i := 0; i < 11; i++ {
+		write(1, "SELECT * FROM items WHERE order_id = 1", 10*time.Second)
+	}
+	if len(bursts) != 2 {
+		t.Errorf("expected a second burst, got %d", len(bursts))
+	}
+}
+
+func TestBurstDetectorBounded(t *testing.T) {
+	d := &BurstDetector{Threshold: 3, Window: time.Second, MaxConnections: 2, MaxFingerprints: 2}
+	for id := int64(0); id < 5; id++ {
+		for i := 0; i < 5; i++ {
+			d.Write(LogEvent{"Id": id, "Statement": fmt.Sprintf("SELECT * FROM t%d", i)})
+		}
+	}
+	if len(d.connections) != 2 {
+		t.Errorf("expected 2 connections, got %d", len(d.connections))
+	}
+	for _, el := range d.connections {
+		if c := el.Value.(*burstConnection); len(c.fingerprints) != 2 || c.fingerprints[0].fingerprint != "select * from t4" {
+			t.Errorf("unexpected fingerprints for connection %d: %+v", c.id, c.fingerprints)
+		}
+	}
+}
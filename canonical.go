@@ -0,0 +1,78 @@
+package mysqllog
+
+import "sort"
+
+// canonicalKeys maps each canonical attribute to the names MySQL,
+// Percona Server, MariaDB and TiDB use for it, in order of precedence.
+var canonicalKeys = []struct {
+	key     string
+	sources []string
+}{
+	// Database is set from a "use" line, or from DB in TiDB mode.
+	{"Database", []string{"Database", "Schema"}},
+	// Id is set from the "# User@Host" line.
+	{"Id", []string{"Id", "Thread_id", "Conn_ID"}},
+	{"Last_errno", []string{"Last_errno", "Errno"}},
+	{"Rows_sent", []string{"Rows_sent", "Result_rows"}},
+	{"Merge_passes", []string{"Merge_passes", "Sort_merge_passes"}},
+	{"Tmp_tables", []string{"Tmp_tables", "Created_tmp_tables"}},
+	{"Tmp_disk_tables", []string{"Tmp_disk_tables", "Created_tmp_disk_tables"}},
+	{"QC_Hit", []string{"QC_Hit", "QC_hit"}},
+}
+
+// WithCanonicalKeys stores attributes that forks name differently under
+// one canonical key, so events from any of them can be handled alike:
+//
+//	Database         use line, Schema (Percona, MariaDB), DB (TiDB)
+//	Id               Id (User@Host line), Thread_id, Conn_ID (TiDB)
+//	Last_errno       Last_errno, Errno (MySQL 8)
+//	Rows_sent        Rows_sent, Result_rows (TiDB)
+//	Merge_passes     Merge_passes, Sort_merge_passes (MySQL 8)
+//	Tmp_tables       Tmp_tables, Created_tmp_tables (MySQL 8)
+//	Tmp_disk_tables  Tmp_disk_tables, Created_tmp_disk_tables (MySQL 8)
+//	QC_Hit           QC_Hit, QC_hit (MariaDB)
+//
+// The first name found, in the order listed, gives the value. If
+// another one has a different value, the canonical key is listed in
+// "CanonicalConflicts" as a sorted []string. The other names are
+// removed unless keepOriginals is set.
+func WithCanonicalKeys(keepOriginals bool) Option {
+	return func(p *Parser) {
+		p.canonical = true
+		p.keepOriginals = keepOriginals
+	}
+}
+
+// canonicalize renames the attributes of e to their canonical keys.
+func canonicalize(e LogEvent, keepOriginals bool) {
+	var conflicts []string
+	for _, c := range canonicalKeys {
+		var value interface{}
+		found, conflict := false, false
+		for _, source := range c.sources {
+			v, ok := e[source]
+			if !ok {
+				continue
+			}
+			if !found {
+				value, found = v, true
+			} else if v != value {
+				conflict = true
+			}
+			if source != c.key && !keepOriginals {
+				delete(e, source)
+			}
+		}
+		if !found {
+			continue
+		}
+		e[c.key] = value
+		if conflict {
+			conflicts = append(conflicts, c.key)
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		e["CanonicalConflicts"] = conflicts
+	}
+}
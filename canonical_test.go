@@ -0,0 +1,72 @@
+package mysqllog
+
+import (
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCanonicalKeys(t *testing.T) {
+	type TestCase struct {
+		File    string
+		Dialect Dialect
+		// Keys are the canonical keys expected besides Database, Id and
+		// Rows_sent.
+		Keys []string
+	}
+	for _, c := range []TestCase{
+		{"canonical_mysql8.txt", MySQL, []string{"Last_errno", "Merge_passes", "Tmp_disk_tables", "Tmp_tables"}},
+		{"canonical_percona.txt", MySQL, []string{"Last_errno", "Merge_passes", "Tmp_disk_tables", "Tmp_tables"}},
+		{"canonical_mariadb.txt", MySQL, []string{"Merge_passes", "QC_Hit", "Tmp_disk_tables", "Tmp_tables"}},
+		{"canonical_tidb.txt", TiDB, nil},
+	} {
+		b, err := ioutil.ReadFile("./_test/" + c.File)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := parseAll(NewParser(WithDialect(c.Dialect), WithCanonicalKeys(false)), string(b))[0]
+		if e["Database"] != "shop" || e["Id"] != int64(42) || e["Rows_sent"] != int64(3) {
+			t.Errorf("%s: unexpected canonical values %v", c.File, e)
+		}
+		if e["Merge_passes"] != nil && e["Merge_passes"] != int64(1) || e["Tmp_tables"] != nil && e["Tmp_tables"] != int64(1) {
+			t.Errorf("%s: unexpected canonical values %v", c.File, e)
+		}
+
+		var keys []string
+		for _, k := range canonicalKeys {
+			for _, source := range k.sources {
+				if _, ok := e[source]; !ok {
+					continue
+				}
+				if source != k.key {
+					t.Errorf("%s: expected %s to be removed", c.File, source)
+				}
+				if source != "Database" && source != "Id" && source != "Rows_sent" {
+					keys = append(keys, source)
+				}
+			}
+		}
+		sort.Strings(keys)
+		if !reflect.DeepEqual(keys, c.Keys) {
+			t.Errorf("%s: expected canonical keys %v, got %v", c.File, c.Keys, keys)
+		}
+		if _, ok := e["CanonicalConflicts"]; ok {
+			t.Errorf("%s: unexpected conflicts %v", c.File, e["CanonicalConflicts"])
+		}
+	}
+}
+
+func TestCanonicalKeysConflicts(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/canonical_percona.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := parseAll(NewParser(WithCanonicalKeys(true)), string(b))[1]
+	if e["Database"] != "shop" || e["Schema"] != "other" || e["Thread_id"] != int64(42) {
+		t.Errorf("expected the use line to win and the originals to be kept, got %v", e)
+	}
+	if conflicts, _ := e["CanonicalConflicts"].([]string); !reflect.DeepEqual(conflicts, []string{"Database"}) {
+		t.Errorf("expected a Database conflict, got %v", e["CanonicalConflicts"])
+	}
+}
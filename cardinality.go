@@ -0,0 +1,124 @@
+package mysqllog
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// OtherFingerprint is the fingerprint of the stats accumulating events
+// of fingerprints evicted by WithMaxFingerprints.
+const OtherFingerprint = "(other)"
+
+// EvictionPolicy chooses the fingerprint to evict when an Aggregator
+// reaches its WithMaxFingerprints limit.
+type EvictionPolicy int
+
+const (
+	// EvictLeastTime evicts the fingerprint with the least total Query_time.
+	EvictLeastTime EvictionPolicy = iota
+	// EvictLeastRecent evicts the fingerprint seen least recently.
+	EvictLeastRecent
+)
+
+// WithMaxFingerprints bounds the number of fingerprints an Aggregator
+// tracks to n. When a new fingerprint would exceed n, one is evicted
+// according to policy and its stats are merged into the OtherFingerprint
+// stats, so overall counts and total times are preserved. See
+// Aggregator.Evictions.
+func WithMaxFingerprints(n int, policy EvictionPolicy) AggregatorOption {
+	return func(a *Aggregator) {
+		a.maxFingerprints = n
+		a.policy = policy
+	}
+}
+
+// Evictions returns the number of fingerprints evicted by WithMaxFingerprints.
+func (a *Aggregator) Evictions() int64 {
+	return a.evictions
+}
+
+// statsFor returns the stats for fingerprint, creating them and
+// evicting others as needed. Call touched after updating them.
+func (a *Aggregator) statsFor(fingerprint string) *QueryStats {
+	if s := a.stats[fingerprint]; s != nil {
+		return s
+	}
+	if a.maxFingerprints > 0 && len(a.stats) >= a.maxFingerprints {
+		a.evict()
+	}
+	s := &QueryStats{Fingerprint: fingerprint, accuracy: a.accuracy}
+	a.stats[fingerprint] = s
+	if a.maxFingerprints > 0 {
+		switch a.policy {
+		case EvictLeastRecent:
+			if a.recent == nil {
+				a.recent = list.New()
+				a.recentElements = map[string]*list.Element{}
+			}
+			a.recentElements[fingerprint] = a.recent.PushFront(s)
+		default:
+			heap.Push(&a.byTime, s)
+		}
+	}
+	return s
+}
+
+// touched records that s was updated.
+func (a *Aggregator) touched(s *QueryStats) {
+	if a.maxFingerprints <= 0 {
+		return
+	}
+	switch a.policy {
+	case EvictLeastRecent:
+		a.recent.MoveToFront(a.recentElements[s.Fingerprint])
+	default:
+		heap.Fix(&a.byTime, a.byTime.index[s.Fingerprint])
+	}
+}
+
+func (a *Aggregator) evict() {
+	var victim *QueryStats
+	switch a.policy {
+	case EvictLeastRecent:
+		el := a.recent.Back()
+		a.recent.Remove(el)
+		victim = el.Value.(*QueryStats)
+		delete(a.recentElements, victim.Fingerprint)
+	default:
+		victim = heap.Pop(&a.byTime).(*QueryStats)
+	}
+	delete(a.stats, victim.Fingerprint)
+	if a.other == nil {
+		a.other = &QueryStats{Fingerprint: OtherFingerprint, accuracy: a.accuracy}
+	}
+	a.other.Merge(*victim)
+	a.evictions++
+}
+
+// statsHeap is a min-heap of stats by total Query_time.
+type statsHeap struct {
+	stats []*QueryStats
+	index map[string]int
+}
+
+func (h statsHeap) Len() int           { return len(h.stats) }
+func (h statsHeap) Less(i, j int) bool { return h.stats[i].TotalTime < h.stats[j].TotalTime }
+func (h statsHeap) Swap(i, j int) {
+	h.stats[i], h.stats[j] = h.stats[j], h.stats[i]
+	h.index[h.stats[i].Fingerprint] = i
+	h.index[h.stats[j].Fingerprint] = j
+}
+func (h *statsHeap) Push(x interface{}) {
+	if h.index == nil {
+		h.index = map[string]int{}
+	}
+	s := x.(*QueryStats)
+	h.index[s.Fingerprint] = len(h.stats)
+	h.stats = append(h.stats, s)
+}
+func (h *statsHeap) Pop() interface{} {
+	s := h.stats[len(h.stats)-1]
+	h.stats = h.stats[:len(h.stats)-1]
+	delete(h.index, s.Fingerprint)
+	return s
+}
@@ -0,0 +1,48 @@
+package mysqllog
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestWithMaxFingerprints(t *testing.T) {
+	for _, policy := range []EvictionPolicy{EvictLeastTime, EvictLeastRecent} {
+		a := NewAggregator(WithMaxFingerprints(10, policy))
+		var totalTime float64
+		for i := 0; i < 1000; i++ {
+			// One heavy, frequent fingerprint among many unique ones.
+			statement := fmt.Sprintf("SELECT * FROM t%d", i)
+			queryTime := 0.01
+			if i%2 == 0 {
+				statement, queryTime = "SELECT * FROM heavy", 1
+			}
+			totalTime += queryTime
+			a.Add(LogEvent{"Statement": statement, "Query_time": queryTime})
+		}
+
+		results := a.Results()
+		if len(results) != 11 {
+			t.Errorf("policy %d: expected 10 fingerprints and %s, got %d results", policy, OtherFingerprint, len(results))
+		}
+		var count int64
+		var sum float64
+		var other *QueryStats
+		for i := range results {
+			count += results[i].Count
+			sum += results[i].TotalTime
+			if results[i].Fingerprint == OtherFingerprint {
+				other = &results[i]
+			}
+		}
+		if count != 1000 || math.Abs(sum-totalTime) > 1e-9 {
+			t.Errorf("policy %d: expected totals of 1000 events and %fs, got %d and %fs", policy, totalTime, count, sum)
+		}
+		if results[0].Fingerprint != "select * from heavy" || results[0].Count != 500 {
+			t.Errorf("policy %d: expected the heavy fingerprint first, got %+v", policy, results[0])
+		}
+		if other == nil || other.Count != 491 || a.Evictions() != 491 {
+			t.Errorf("policy %d: expected 491 evicted events, got %+v and %d evictions", policy, other, a.Evictions())
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package mysqllog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CheckpointStore keeps how far each source, such as a tailed file, has
+// been read, so a restarted agent resumes where it left off. The
+// FileCheckpointStore keeps it in a JSON file; others can keep it
+// elsewhere, such as in a database.
+type CheckpointStore interface {
+	// Load returns the state saved for source, and false if there's
+	// none.
+	Load(source string) (CheckpointState, bool, error)
+	// Save records the state of source durably.
+	Save(source string, state CheckpointState) error
+	// Delete forgets source.
+	Delete(source string) error
+}
+
+// CheckpointState is the position of a source in a CheckpointStore.
+type CheckpointState struct {
+	// ID is the identity of the file read (see FileIdentity), or of the
+	// content at a URL, such as its ETag.
+	ID string
+	// Fingerprint is a hash of the first bytes of the file, up to 4 KB
+	// and Offset, recognizing it where identities aren't known or have
+	// been reused.
+	Fingerprint string
+	// Offset is where reading resumes, at an event boundary.
+	Offset int64
+	// LastEvent is the time of the last event before Offset, if known.
+	LastEvent time.Time
+	// Connections is the state of the connections before Offset, with
+	// WithConnectionTracking.
+	Connections []ConnectionState
+}
+
+// equal reports whether s and other are the same state.
+func (s CheckpointState) equal(other CheckpointState) bool {
+	if s.ID != other.ID || s.Fingerprint != other.Fingerprint ||
+		s.Offset != other.Offset || !s.LastEvent.Equal(other.LastEvent) ||
+		len(s.Connections) != len(other.Connections) {
+		return false
+	}
+	for i, c := range s.Connections {
+		o := other.Connections[i]
+		if c.ID != o.ID || c.User != o.User || c.Host != o.Host || c.Database != o.Database ||
+			!c.LastEvent.Equal(o.LastEvent) || c.Events != o.Events {
+			return false
+		}
+	}
+	return true
+}
+
+// checkpointJSON is how a CheckpointState is stored.
+type checkpointJSON struct {
+	ID          string     `json:"id,omitempty"`
+	Fingerprint string     `json:"fingerprint,omitempty"`
+	Offset      int64      `json:"offset"`
+	LastEvent   *time.Time `json:"last_event,omitempty"`
+
+	Connections []ConnectionState `json:"connections,omitempty"`
+}
+
+func (s CheckpointState) MarshalJSON() ([]byte, error) {
+	v := checkpointJSON{ID: s.ID, Fingerprint: s.Fingerprint, Offset: s.Offset, Connections: s.Connections}
+	if !s.LastEvent.IsZero() {
+		v.LastEvent = &s.LastEvent
+	}
+	return json.Marshal(v)
+}
+
+func (s *CheckpointState) UnmarshalJSON(data []byte) error {
+	*s = CheckpointState{}
+	if len(data) > 0 && data[0] != '{' {
+		// Checkpoints written before identities were recorded have
+		// the offset only.
+		return json.Unmarshal(data, &s.Offset)
+	}
+	var v checkpointJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	s.ID, s.Fingerprint, s.Offset = v.ID, v.Fingerprint, v.Offset
+	s.Connections = v.Connections
+	if v.LastEvent != nil {
+		s.LastEvent = *v.LastEvent
+	}
+	return nil
+}
+
+// Checkpoint records how far each log file has been read, so a
+// restarted watcher resumes where it left off. Offsets are at event
+// boundaries, and are recorded with the identity of the file (see
+// FileIdentity) so a file replaced at the same path isn't resumed at
+// the old one's offset. It's stored as JSON and safe for concurrent use.
+//
+// The previous version of the file is kept with a ".prev" suffix, and
+// loaded instead of a file that's missing or was torn by a crash.
+type Checkpoint struct {
+	path string
+
+	mu      sync.Mutex
+	offsets map[string]CheckpointState
+	dirty   bool
+	// corrupt is set if the file at path couldn't be loaded, so it
+	// doesn't replace the previous version.
+	corrupt bool
+}
+
+// OpenCheckpoint loads the checkpoint stored at path, or its previous
+// version if the file is missing or unreadable JSON. It returns an
+// empty checkpoint if neither can be loaded, such as the first time.
+func OpenCheckpoint(path string) (*Checkpoint, error) {
+	c := &Checkpoint{path: path, offsets: map[string]CheckpointState{}}
+	offsets, err := loadCheckpoint(path)
+	if err == nil {
+		c.offsets = offsets
+		return c, nil
+	}
+	if isReadError(err) {
+		return nil, err
+	}
+	c.corrupt = !os.IsNotExist(err)
+	offsets, err = loadCheckpoint(path + ".prev")
+	if err == nil {
+		c.offsets = offsets
+	} else if isReadError(err) {
+		return nil, err
+	}
+	return c, nil
+}
+
+// isReadError reports whether err is an error reading a checkpoint
+// file that exists, rather than one missing or with bad contents.
+func isReadError(err error) bool {
+	_, ok := err.(*os.PathError)
+	return ok && !os.IsNotExist(err)
+}
+
+// loadCheckpoint reads the offsets stored at path.
+func loadCheckpoint(path string) (map[string]CheckpointState, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	offsets := map[string]CheckpointState{}
+	if err := json.Unmarshal(data, &offsets); err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
+
+// Offset returns the offset recorded for file.
+func (c *Checkpoint) Offset(file string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.offsets[file]
+	return entry.Offset, ok
+}
+
+// FileOffset returns the offset recorded for file if it was recorded
+// for the file with identity id. ok is false if another file was at
+// that path. An empty identity on either side matches.
+func (c *Checkpoint) FileOffset(file, id string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.offsets[file]
+	if !ok || (id != "" && entry.ID != "" && id != entry.ID) {
+		return 0, false
+	}
+	return entry.Offset, true
+}
+
+// State returns the state recorded for file.
+func (c *Checkpoint) State(file string) (CheckpointState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state, ok := c.offsets[file]
+	return state, ok
+}
+
+// Files returns the files with a recorded offset.
+func (c *Checkpoint) Files() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	files := make([]string, 0, len(c.offsets))
+	for file := range c.offsets {
+		files = append(files, file)
+	}
+	return files
+}
+
+// Set records the offset of file. It's kept in memory until Save.
+func (c *Checkpoint) Set(file string, offset int64) {
+	c.SetFile(file, "", offset)
+}
+
+// SetFile records the offset of file along with its identity id.
+func (c *Checkpoint) SetFile(file, id string, offset int64) {
+	c.SetState(file, CheckpointState{ID: id, Offset: offset})
+}
+
+// SetState records the state of file.
+func (c *Checkpoint) SetState(file string, state CheckpointState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.offsets[file]; !ok || !old.equal(state) {
+		c.offsets[file] = state
+		c.dirty = true
+	}
+}
+
+// Delete forgets file, such as once it has been read completely.
+func (c *Checkpoint) Delete(file string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.offsets[file]; ok {
+		delete(c.offsets, file)
+		c.dirty = true
+	}
+}
+
+// Save writes the checkpoint if it changed. The file is synced and
+// replaced atomically, with the old one kept as the previous version,
+// so a crash leaves either the old or the new offsets.
+func (c *Checkpoint) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	data, err := json.Marshal(c.offsets)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && !c.corrupt {
+		// The current file becomes the previous version, which is
+		// loaded if the new one turns out torn.
+		if rerr := os.Rename(c.path, c.path+".prev"); rerr != nil && !os.IsNotExist(rerr) {
+			err = rerr
+		}
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	c.dirty, c.corrupt = false, false
+	return nil
+}
+
+// FileCheckpointStore is a CheckpointStore kept in the JSON file of a
+// Checkpoint, which is written on every Save that changes it.
+type FileCheckpointStore struct {
+	Checkpoint *Checkpoint
+}
+
+// OpenFileCheckpointStore opens the store at path, as OpenCheckpoint
+// does.
+func OpenFileCheckpointStore(path string) (*FileCheckpointStore, error) {
+	c, err := OpenCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileCheckpointStore{Checkpoint: c}, nil
+}
+
+// Load implements CheckpointStore.
+func (s *FileCheckpointStore) Load(source string) (CheckpointState, bool, error) {
+	state, ok := s.Checkpoint.State(source)
+	return state, ok, nil
+}
+
+// Save implements CheckpointStore.
+func (s *FileCheckpointStore) Save(source string, state CheckpointState) error {
+	s.Checkpoint.SetState(source, state)
+	return s.Checkpoint.Save()
+}
+
+// Delete implements CheckpointStore.
+func (s *FileCheckpointStore) Delete(source string) error {
+	s.Checkpoint.Delete(source)
+	return s.Checkpoint.Save()
+}
@@ -0,0 +1,73 @@
+package mysqllog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpointTornWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint.json")
+	store, err := OpenFileCheckpointStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := CheckpointState{ID: "1:2", Fingerprint: "abc", Offset: 7, LastEvent: time.Unix(1690884000, 0)}
+	if err := store.Save("slow.log", first); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("slow.log", CheckpointState{ID: "1:2", Offset: 9}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash tore the last write: the previous version is loaded, and
+	// kept until a good one replaces the torn one.
+	if err := ioutil.WriteFile(path, []byte(`{"slow.log":{"id":"1:2","off`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		store, err = OpenFileCheckpointStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state, ok, err := store.Load("slow.log")
+		if err != nil || !ok || !state.equal(first) {
+			t.Errorf("expected the previous state %+v, got %+v, %v, %v", first, state, ok, err)
+		}
+	}
+	second := CheckpointState{ID: "1:2", Offset: 11}
+	if err := store.Save("slow.log", second); err != nil {
+		t.Fatal(err)
+	}
+	if prev, err := loadCheckpoint(path + ".prev"); err != nil || !prev["slow.log"].equal(first) {
+		t.Errorf("expected the previous version to be kept, got %v, %v", prev, err)
+	}
+	store, err = OpenFileCheckpointStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state, _, _ := store.Load("slow.log"); !state.equal(second) {
+		t.Errorf("expected %+v, got %+v", second, state)
+	}
+
+	// With neither version readable, everything starts over.
+	for _, file := range []string{path, path + ".prev"} {
+		if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store, err = OpenFileCheckpointStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := store.Load("slow.log"); ok {
+		t.Error("expected no state")
+	}
+}
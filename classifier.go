@@ -0,0 +1,70 @@
+package mysqllog
+
+// Severity levels commonly used with a Classifier.
+const (
+	SeverityInfo     = "info"
+	SeverityWarn     = "warn"
+	SeverityCritical = "critical"
+)
+
+// Rule assigns Severity to events matched by Match.
+type Rule struct {
+	Severity string
+	Match    func(LogEvent) bool
+}
+
+// Classifier assigns a severity to events using ordered rules.
+// The first matching rule wins, so more severe rules should come first.
+// Events matching no rule get Default, which may be empty.
+type Classifier struct {
+	Rules   []Rule
+	Default string
+}
+
+// Classify returns the severity for event.
+func (c *Classifier) Classify(event LogEvent) string {
+	for _, rule := range c.Rules {
+		if rule.Match != nil && rule.Match(event) {
+			return rule.Severity
+		}
+	}
+	return c.Default
+}
+
+// WithClassifier sets a "Severity" key on each event using c.
+// No key is set when c classifies an event with an empty severity.
+func WithClassifier(c *Classifier) Option {
+	return func(p *Parser) {
+		p.classifier = c
+	}
+}
+
+// Above returns a predicate matching events whose numeric attribute
+// key is greater than threshold. Events missing the attribute don't match.
+func Above(key string, threshold float64) func(LogEvent) bool {
+	return func(e LogEvent) bool {
+		v, ok := e.Float64(key)
+		return ok && v > threshold
+	}
+}
+
+// NonZero returns a predicate matching events whose numeric attribute
+// key is present and not zero.
+func NonZero(key string) func(LogEvent) bool {
+	return func(e LogEvent) bool {
+		v, ok := e.Float64(key)
+		return ok && v != 0
+	}
+}
+
+// Any returns a predicate matching events matched by any of preds.
+func Any(preds ...func(LogEvent) bool) func(LogEvent) bool {
+	return func(e LogEvent) bool {
+		for _, pred := range preds {
+			if pred(e) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,51 @@
+package mysqllog
+
+import "testing"
+
+func TestClassifier(t *testing.T) {
+	c := &Classifier{
+		Rules: []Rule{
+			{Severity: SeverityCritical, Match: Any(Above("Query_time", 10), Above("Rows_examined", 1e6), NonZero("Killed"))},
+			{Severity: SeverityWarn, Match: Above("Query_time", 1)},
+		},
+		Default: SeverityInfo,
+	}
+
+	type TestCase struct {
+		Event    LogEvent
+		Expected string
+	}
+
+	cases := []TestCase{
+		{Event: LogEvent{"Query_time": 0.5}, Expected: SeverityInfo},
+		{Event: LogEvent{"Query_time": 2.0}, Expected: SeverityWarn},
+		// Matches both rules; the first one wins.
+		{Event: LogEvent{"Query_time": 20.0}, Expected: SeverityCritical},
+		{Event: LogEvent{"Query_time": 2.0, "Rows_examined": int64(2000000)}, Expected: SeverityCritical},
+		{Event: LogEvent{"Query_time": 0.1, "Killed": int64(1)}, Expected: SeverityCritical},
+		{Event: LogEvent{"Query_time": 0.1, "Killed": int64(0)}, Expected: SeverityInfo},
+		// Missing attributes never match.
+		{Event: LogEvent{"Statement": "SELECT 1"}, Expected: SeverityInfo},
+		{Event: LogEvent{"Query_time": "oops"}, Expected: SeverityInfo},
+	}
+
+	for _, tc := range cases {
+		if result := c.Classify(tc.Event); result != tc.Expected {
+			t.Errorf("expected %q for %v, got %q", tc.Expected, tc.Event, result)
+		}
+	}
+}
+
+func TestWithClassifier(t *testing.T) {
+	c := &Classifier{Rules: []Rule{{Severity: SeverityWarn, Match: Above("Query_time", 0.01)}}}
+	events := parseAll(NewParser(WithClassifier(c)), content+"# Query_time: 0.001  Lock_time: 0.0 Rows_sent: 0  Rows_examined: 0\nSELECT 1;\n")
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if events[0]["Severity"] != SeverityWarn {
+		t.Errorf("expected severity %q, got %v", SeverityWarn, events[0]["Severity"])
+	}
+	if _, ok := events[1]["Severity"]; ok {
+		t.Errorf("expected no severity, got %v", events[1]["Severity"])
+	}
+}
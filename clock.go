@@ -0,0 +1,184 @@
+package mysqllog
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time to the features that depend on it, such as
+// WindowStats, Alerter cooldowns, rate limits, idle flushing and
+// checkpoint intervals, so they can be tested, or replay history, with
+// a ManualClock. A nil Clock is RealClock.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a Timer sending the time on its channel after d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event Timer of a Clock, like time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	// Stop prevents the Timer from firing, reporting whether it stopped
+	// it.
+	Stop() bool
+}
+
+// RealClock is the Clock of the time package.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+
+func (t realTimer) Stop() bool { return t.t.Stop() }
+
+// clockOf returns c, or RealClock if it's nil.
+func clockOf(c Clock) Clock {
+	if c == nil {
+		return RealClock
+	}
+	return c
+}
+
+// ManualClock is a Clock whose time only changes with Set, Add and
+// Advance, which fire the timers that are due. Driven by WithEventClock,
+// it follows the timestamps of the events parsed, so windows, cooldowns
+// and rate limits behave the same replaying a log as they did live.
+// A ManualClock is safe for concurrent use.
+type ManualClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*manualTimer
+}
+
+// NewManualClock returns a ManualClock at t.
+func NewManualClock(t time.Time) *ManualClock {
+	c := &ManualClock{now: t}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the time of c.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer returns a Timer firing once c is at least d later.
+func (c *ManualClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &manualTimer{c: c, at: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Set moves c to t, firing the timers due by then.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(t)
+}
+
+// Add moves c forward by d.
+func (c *ManualClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(c.now.Add(d))
+}
+
+// Advance moves c to the time of e, if it has one and it's later, and
+// reports whether it did. Late events don't move it back.
+func (c *ManualClock) Advance(e LogEvent) bool {
+	ts, ok := EventTime(e)
+	if !ok {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !ts.After(c.now) {
+		return false
+	}
+	c.set(ts)
+	return true
+}
+
+// WaitTimers blocks until at least n timers are waiting to fire, such as
+// when a goroutine under test is waiting for the next poll.
+func (c *ManualClock) WaitTimers(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// set moves c to t, which c.mu must protect, firing the timers due.
+func (c *ManualClock) set(t time.Time) {
+	c.now = t
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(t) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.ch <- t
+	}
+	for i := len(pending); i < len(c.timers); i++ {
+		c.timers[i] = nil
+	}
+	c.timers = pending
+}
+
+type manualTimer struct {
+	c  *ManualClock
+	at time.Time
+	ch chan time.Time
+}
+
+func (t *manualTimer) C() <-chan time.Time { return t.ch }
+
+func (t *manualTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	for i, timer := range t.c.timers {
+		if timer == t {
+			t.c.timers = append(t.c.timers[:i], t.c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// WithClock makes the Parser tell the time, for WithRateLimit, with c in
+// place of RealClock.
+func WithClock(c Clock) Option {
+	return func(p *Parser) {
+		p.now = clockOf(c).Now
+	}
+}
+
+// WithEventClock advances c to the timestamp of each event, as it's
+// parsed and before any filter, for the event-time mode of the features
+// sharing c: replaying a log with it, WindowStats, an Alerter or
+// TailFile see the time of the log rather than the time of the replay.
+func WithEventClock(c *ManualClock) Option {
+	return func(p *Parser) {
+		p.eventClock = c
+	}
+}
@@ -0,0 +1,81 @@
+package mysqllog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	fired := func(timer Timer) bool {
+		select {
+		case <-timer.C():
+			return true
+		default:
+			return false
+		}
+	}
+	short, long, stopped := clock.NewTimer(time.Second), clock.NewTimer(time.Minute), clock.NewTimer(time.Second)
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("expected Stop to stop the timer once")
+	}
+	clock.WaitTimers(2)
+	clock.Add(30 * time.Second)
+	if !fired(short) || fired(long) || fired(stopped) {
+		t.Error("expected only the short timer to fire")
+	}
+	if !clock.Now().Equal(start.Add(30 * time.Second)) {
+		t.Errorf("unexpected time %v", clock.Now())
+	}
+	if !fired(clock.NewTimer(0)) {
+		t.Error("expected a timer of 0 to fire at once")
+	}
+
+	// Advance follows event timestamps, but not back in time.
+	if !clock.Advance(LogEvent{"Timestamp": start.Add(time.Hour)}) || !fired(long) {
+		t.Error("expected the clock to advance to the event and fire the long timer")
+	}
+	if clock.Advance(LogEvent{"Timestamp": start}) || clock.Advance(LogEvent{}) || !clock.Now().Equal(start.Add(time.Hour)) {
+		t.Errorf("expected late events and events without a time not to move the clock, got %v", clock.Now())
+	}
+}
+
+func TestEventClockReplay(t *testing.T) {
+	// An event a second for 10 minutes, long ago.
+	log := generateEvents(600, 1)
+	replay := func(clock Clock, opts ...Option) (int, WindowSnapshot) {
+		alerts := 0
+		alerter := &Alerter{
+			CountRules: []CountRule{{Name: "burst", Count: 100, Window: time.Minute}},
+			Cooldown:   time.Hour,
+			Notify:     func(Alert) { alerts++ },
+			Clock:      clock,
+		}
+		window := NewWindowStats(time.Minute)
+		window.Clock = clock
+		p := NewParser(opts...)
+		for _, line := range strings.SplitAfter(log, "\n") {
+			if e := p.ConsumeLine(line); e != nil {
+				alerter.Write(e)
+				window.Write(e)
+			}
+		}
+		return alerts, window.Snapshot()[0]
+	}
+
+	// Live, the whole log arrives within the minute, and is too old for
+	// the window.
+	if alerts, window := replay(nil); alerts != 1 || window.Events != 0 {
+		t.Errorf("expected a burst and an empty window live, got %d alerts and %d events", alerts, window.Events)
+	}
+	// Replayed on the time of the log, it's as it was.
+	clock := NewManualClock(time.Time{})
+	if alerts, window := replay(clock, WithEventClock(clock)); alerts != 0 || window.Events != 60 {
+		t.Errorf("expected no burst and a minute of events replayed, got %d alerts and %d events", alerts, window.Events)
+	}
+	if want := time.Unix(1514083320+599, 0); !clock.Now().Equal(want) {
+		t.Errorf("expected the clock at the last event %v, got %v", want, clock.Now())
+	}
+}
@@ -2,22 +2,359 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Preetam/mysqllog"
+	"github.com/Preetam/mysqllog/gen"
 )
 
 func main() {
-	p := &mysqllog.Parser{}
+	progress := flag.Bool("progress", false, "show a progress bar on stderr")
+	timeline := flag.Duration("timeline", 0, "write the slowest event of each period, such as 1m, instead of every event")
+	output := flag.String("o", "", "write to this file instead of stdout; a .json timeline is written as JSON, otherwise CSV")
+	selftest := flag.Int("selftest", 0, "parse this many generated events of each flavor instead of stdin, and report the speed")
+	minRows := flag.Int64("min-rows-examined", 0, "only print events that examined at least this many rows")
+	limit := flag.Int("limit", 0, "stop after this many events")
+	reverse := flag.Bool("reverse", false, "read the events newest first, from the end of stdin, which must be a file; with -limit, only the end is read")
+	pretty := flag.Bool("pretty", false, "print events for reading in a terminal instead of as JSON, in color unless NO_COLOR is set")
+	full := flag.Bool("full", false, "with -pretty, print statements whole instead of truncating them to the terminal width")
+	format := flag.String("format", "json", "print every event as JSON (json), one JSON object summarizing the input (summary), "+
+		"or the JSON of pt-query-digest --output json (pt-query-digest)")
+	verify := flag.Bool("verify", false, "check that the input parses cleanly instead of printing events, as text or with -format json as JSON; "+
+		"exits with 1 for warnings and 2 for errors")
+	coverage := flag.String("coverage", "", "with -verify, warn about attributes in fewer events than these rates, such as Query_time=1,Rows_examined=0.99, instead of the default ones")
+	push := flag.String("push", "", "also push every event to this sink, such as loki+http://localhost:3100?job=mysql, "+
+		"influx+http://localhost:8086?org=ops&bucket=mysql, clickhouse+http://localhost:8123/slow_queries or statsd://127.0.0.1:8125")
+	pushDigest := flag.Duration("push-digest", 0, "with -push, push a digest of the events aggregated over each period, such as 5m, instead of every event")
+	collectShapes := flag.Bool("collect-shapes", false, "print the distinct shapes of the header lines of the input as JSON, with a count and an example each, "+
+		"instead of printing events, to report a dialect that isn't fully supported")
+	printSchema := flag.String("print-schema", "", "print the fields events can have as JSON (json) or a Markdown table (markdown) instead of reading stdin")
+	flag.Parse()
+	if *printSchema != "" {
+		if err := runPrintSchema(os.Stdout, *printSchema); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	switch *format {
+	case "json", "summary", "pt-query-digest":
+	default:
+		fatal(fmt.Errorf("unknown format %q", *format))
+	}
+
+	if *verify {
+		jsonReport := false
+		flag.Visit(func(f *flag.Flag) {
+			jsonReport = jsonReport || (f.Name == "format" && *format == "json")
+		})
+		expected, err := parseCoverage(*coverage)
+		if err != nil {
+			fatal(err)
+		}
+		os.Exit(runVerify(os.Stdin, os.Stdout, jsonReport, expected))
+	}
+
+	if *collectShapes {
+		if err := runCollectShapes(os.Stdin, os.Stdout); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if *selftest > 0 {
+		if err := runSelftest(*selftest); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	out := bufio.NewWriter(w)
+	jsonOut := mysqllog.NewJSONWriter(out, mysqllog.Original)
+	var prettyOpts []mysqllog.PrettyOption
+	if *full {
+		prettyOpts = append(prettyOpts, mysqllog.WithFullStatements())
+	}
+	printer := mysqllog.NewPrettyWriter(w, prettyOpts...)
+
+	var parserOpts []mysqllog.Option
+	if *minRows > 0 {
+		parserOpts = append(parserOpts, mysqllog.WithMinRowsExamined(*minRows))
+	}
+	p := mysqllog.NewParser(parserOpts...)
+	opts := []mysqllog.ReadOption{mysqllog.WithParser(p)}
+	if *progress {
+		opts = append(opts, mysqllog.WithProgress(progressBar(time.Now())))
+	}
+	if *limit > 0 {
+		opts = append(opts, mysqllog.WithMaxEvents(*limit))
+	}
+
+	var pushSink mysqllog.Sink
+	if *push != "" {
+		var err error
+		if pushSink, err = newPushSink(*push); err != nil {
+			fatal(err)
+		}
+		if *pushDigest > 0 {
+			pushSink = mysqllog.NewForwarder(pushSink, mysqllog.WithForwarderInterval(*pushDigest))
+		}
+	}
+
+	var entries []mysqllog.TimelineEntry
+	t := &mysqllog.Timeline{
+		Resolution: *timeline,
+		Emit:       func(e []mysqllog.TimelineEntry) { entries = append(entries, e...) },
+	}
+	summary := &mysqllog.Summary{}
+	aggregator := mysqllog.NewAggregator()
+	var pushErr error
+	handle := func(event mysqllog.LogEvent) {
+		if pushSink != nil {
+			if err := pushSink.Write(event); err != nil && pushErr == nil {
+				pushErr = err
+			}
+		}
+		switch *format {
+		case "summary":
+			summary.Add(event)
+			return
+		case "pt-query-digest":
+			aggregator.Add(event)
+			return
+		}
+		if *timeline > 0 {
+			t.Write(event)
+			return
+		}
+		if *pretty {
+			printer.Write(event)
+			return
+		}
+		jsonOut.Write(event)
+	}
+	var err error
+	if *reverse {
+		err = readReverse(os.Stdin, *limit, handle, parserOpts...)
+	} else {
+		err = mysqllog.ParseReader(os.Stdin, handle, opts...)
+	}
+	if *progress {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if pushSink != nil {
+		if err := pushSink.Close(); pushErr == nil {
+			pushErr = err
+		}
+		if pushErr != nil {
+			fatal(pushErr)
+		}
+	}
+	switch {
+	case *format == "summary":
+		summary.Bytes = p.Stats().Bytes
+		b, err := json.Marshal(summary)
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(out, "%s\n", b)
+	case *format == "pt-query-digest":
+		if err := mysqllog.WritePTDigestJSON(out, aggregator.Results()); err != nil {
+			fatal(err)
+		}
+	case *timeline > 0:
+		t.Close()
+		if strings.HasSuffix(*output, ".json") {
+			err = mysqllog.WriteTimelineJSON(out, entries)
+		} else {
+			err = mysqllog.WriteTimelineCSV(out, entries)
+		}
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if err := out.Flush(); err != nil {
+		fatal(err)
+	}
+}
 
-	reader := bufio.NewReader(os.Stdin)
-	for line, err := reader.ReadString('\n'); err == nil; line, err = reader.ReadString('\n') {
-		event := p.ConsumeLine(line)
-		if event != nil {
-			b, _ := json.Marshal(event)
-			fmt.Printf("%s\n", b)
+// readReverse calls fn with the events of f newest first, stopping after
+// limit events if it's more than zero.
+func readReverse(f *os.File, limit int, fn func(mysqllog.LogEvent), opts ...mysqllog.Option) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("-reverse needs stdin to be a file, not a pipe")
+	}
+	r := mysqllog.NewReverseReader(f, info.Size(), opts...)
+	for n := 0; limit <= 0 || n < limit; n++ {
+		event, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(event)
+	}
+	return nil
+}
+
+// runSelftest parses n generated events of each flavor, checking that
+// they all come back.
+func runSelftest(n int) error {
+	for _, flavor := range []gen.Flavor{gen.MySQL56, gen.MySQL57, gen.MySQL80, gen.Percona, gen.MariaDB} {
+		var buf bytes.Buffer
+		if err := gen.Generate(&buf, gen.GenSpec{Events: n, Flavor: flavor, BannerEvery: 1000}); err != nil {
+			return err
+		}
+		size := buf.Len()
+		events := 0
+		start := time.Now()
+		err := mysqllog.ParseReader(&buf, func(mysqllog.LogEvent) { events++ },
+			mysqllog.WithParser(mysqllog.NewParser(mysqllog.WithStrict())))
+		elapsed := time.Since(start)
+		if err != nil {
+			return fmt.Errorf("%s: %v", flavor, err)
+		}
+		if events != n {
+			return fmt.Errorf("%s: parsed %d of %d events", flavor, events, n)
+		}
+		fmt.Fprintf(os.Stderr, "%-10s %d events in %s, %.1f MB/s\n", flavor, events, elapsed.Truncate(time.Millisecond),
+			float64(size)/(1<<20)/elapsed.Seconds())
+	}
+	return nil
+}
+
+// runVerify prints the VerifyReport of r to w and returns the exit
+// code: 0 if the log is clean, 1 for warnings and 2 for errors.
+func runVerify(r io.Reader, w io.Writer, jsonReport bool, expected map[string]float64) int {
+	report, err := mysqllog.Verify(r)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if expected != nil {
+		report.CheckCoverage(expected)
+	}
+	if jsonReport {
+		b, err := json.Marshal(report)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		fmt.Fprintf(w, "%s\n", b)
+	} else if err := report.Write(w); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	switch {
+	case report.HasErrors():
+		return 2
+	case report.HasWarnings():
+		return 1
+	}
+	return 0
+}
+
+// runCollectShapes writes the shapes of the header lines of r to w.
+func runCollectShapes(r io.Reader, w io.Writer) error {
+	shapes := mysqllog.NewShapeCollector(0)
+	p := mysqllog.NewParser(mysqllog.WithHeaderLines())
+	err := mysqllog.ParseReader(r, func(e mysqllog.LogEvent) { shapes.Write(e) }, mysqllog.WithParser(p))
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(shapes, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// parseCoverage parses the presence rates of -coverage, or returns nil
+// for the default ones.
+func parseCoverage(s string) (map[string]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	expected := map[string]float64{}
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.IndexByte(pair, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("bad -coverage %q: expected key=rate", pair)
+		}
+		rate, err := strconv.ParseFloat(pair[i+1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad -coverage %q: %v", pair, err)
+		}
+		expected[strings.TrimSpace(pair[:i])] = rate
+	}
+	return expected, nil
+}
+
+// runPrintSchema writes mysqllog.Schema to w in format, json or
+// markdown.
+func runPrintSchema(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(mysqllog.Schema(), "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	case "markdown":
+		return mysqllog.WriteSchemaMarkdown(w, mysqllog.Schema())
+	}
+	return fmt.Errorf("unknown schema format %q", format)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// progressBar returns a progress callback that redraws a bar on stderr.
+// Without a known size, it shows the bytes and events read so far.
+func progressBar(start time.Time) func(bytesRead, totalBytes int64, events int) {
+	const width = 30
+	return func(bytesRead, totalBytes int64, events int) {
+		if totalBytes <= 0 {
+			fmt.Fprintf(os.Stderr, "\r%d MB, %d events", bytesRead>>20, events)
+			return
+		}
+		done := int(width * bytesRead / totalBytes)
+		if done > width {
+			done = width
+		}
+		line := fmt.Sprintf("\r[%s%s] %5.1f%% %d events", strings.Repeat("#", done), strings.Repeat(" ", width-done),
+			100*float64(bytesRead)/float64(totalBytes), events)
+		if eta, ok := mysqllog.ETA(time.Since(start), bytesRead, totalBytes); ok {
+			line += fmt.Sprintf(", ETA %s", eta.Truncate(time.Second))
 		}
+		fmt.Fprint(os.Stderr, line+"  ")
 	}
 }
@@ -0,0 +1,438 @@
+package mysqllog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for Collector.
+const (
+	DefaultCollectorIdle    = 10 * time.Minute
+	DefaultCollectorSources = 1000
+)
+
+// CollectorOption configures a Collector.
+type CollectorOption func(*Collector)
+
+// WithCollectorIdle drops the state of a source after d without data,
+// flushing its pending event. Zero or less keeps idle sources.
+func WithCollectorIdle(d time.Duration) CollectorOption {
+	return func(c *Collector) {
+		c.idle = d
+	}
+}
+
+// WithCollectorMaxSources keeps the state of at most n sources, flushing
+// and dropping the least recently seen one to make room. Zero or less
+// keeps every source.
+func WithCollectorMaxSources(n int) CollectorOption {
+	return func(c *Collector) {
+		c.maxSources = n
+	}
+}
+
+// ErrCollectorFull is returned by Collector.Consume for data refused
+// because of the total limit of WithCollectorMemoryLimit, to be sent
+// again later.
+var ErrCollectorFull = errors.New("mysqllog: collector buffers full")
+
+// WithCollectorMemoryLimit bounds the memory buffered for the sources:
+// the unterminated end of their last chunk and their pending event. A
+// source buffering more than perSource bytes, such as with a giant
+// statement or a corrupted chunk without newlines, has that data
+// dropped, up to the next event, and a BufferOverflow control event
+// written in its place. While the sources buffer more than total bytes
+// in all, Consume refuses the data of those buffering more than their
+// share of it with ErrCollectorFull, so the others keep going. The
+// total is checked before each chunk, so it may be exceeded by a chunk
+// per source. Zero or less doesn't limit them.
+//
+// Events are written to the sink as they're parsed, so none are queued
+// past the Parser.
+func WithCollectorMemoryLimit(perSource, total int64) CollectorOption {
+	return func(c *Collector) {
+		c.maxSourceBytes, c.maxBytes = perSource, total
+	}
+}
+
+// WithCollectorParserOptions configures the Parser of each source.
+func WithCollectorParserOptions(opts ...Option) CollectorOption {
+	return func(c *Collector) {
+		c.parserOpts = opts
+	}
+}
+
+// WithCollectorClock tells the time for WithCollectorIdle with c in
+// place of RealClock.
+func WithCollectorClock(clock Clock) CollectorOption {
+	return func(c *Collector) {
+		c.clock = clock
+	}
+}
+
+// WithCollectorAggregator adds the events of every source to one
+// Aggregator made with opts, rolled up by "Source" besides its other
+// dimensions. See Collector.Aggregate.
+func WithCollectorAggregator(opts ...AggregatorOption) CollectorOption {
+	return func(c *Collector) {
+		c.aggregator = NewAggregator(append(opts, withRollup("Source"))...)
+	}
+}
+
+// withRollup adds key to the rollup dimensions, if it isn't one.
+func withRollup(key string) AggregatorOption {
+	return func(a *Aggregator) {
+		for _, dimension := range a.dimensions {
+			if dimension == key {
+				return
+			}
+		}
+		a.dimensions = append(a.dimensions, key)
+	}
+}
+
+// Collector parses the logs of many sources, such as the databases
+// streaming to a central collector, into one pipeline: each source has
+// its own Parser, created on first use, while the events of all of them,
+// with "Source" set to the id of theirs, go to the same Sink and
+// Aggregator. IngestHandler and the gRPC server are built on it.
+//
+// The memory used is bounded by the number of sources kept, see
+// WithCollectorMaxSources, and by what each of them buffers, see
+// WithCollectorMemoryLimit, WithStatementLimit and WithHeaderLineLimit.
+//
+// A Collector is safe for concurrent use. Data of different sources is
+// parsed concurrently; data of the same source is serialized, as are
+// writes to the sink and the Aggregator.
+type Collector struct {
+	// buffered is the number of bytes buffered for the sources, accessed
+	// atomically, first for alignment.
+	buffered int64
+
+	sink       Sink
+	idle       time.Duration
+	maxSources int
+	parserOpts []Option
+	clock      Clock
+
+	maxSourceBytes int64
+	maxBytes       int64
+
+	// pool has the Parser of each source, with its sourceState as Data.
+	pool *ParserPool
+
+	// mu serializes sink and aggregator.
+	mu         sync.Mutex
+	aggregator *Aggregator
+}
+
+// NewCollector returns a Collector writing the events of every source to
+// sink, which may be nil if they're only aggregated.
+func NewCollector(sink Sink, opts ...CollectorOption) *Collector {
+	c := &Collector{
+		sink:       sink,
+		idle:       DefaultCollectorIdle,
+		maxSources: DefaultCollectorSources,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.pool = NewParserPool(c.parserOpts...)
+	c.pool.Idle = c.idle
+	c.pool.MaxSources = c.maxSources
+	c.pool.Clock = c.clock
+	c.pool.Evicted = func(src *Source) {
+		c.flush(src, func(e LogEvent) error { return c.write(src, e, nil) })
+	}
+	return c
+}
+
+// Consume parses the lines of r, the next data of source id, and returns
+// the number of events written. A line or event split across calls is
+// put back together: the end of r after its last newline is kept for
+// the next call, and the pending event is written when the next one
+// starts, when the source is dropped, or with Flush. emit, if not nil,
+// is called with each event of this call after it's written to the sink
+// and the Aggregator.
+func (c *Collector) Consume(id string, r io.Reader, emit func(LogEvent) error) (int, error) {
+	var (
+		events int
+		err    error
+	)
+	c.pool.Do(id, func(src *Source) {
+		events, err = c.consume(src, r, emit)
+	})
+	return events, err
+}
+
+// Flush writes the pending event of source id, and the line it was in
+// the middle of, as for the end of a file, and returns the number of
+// events written. emit is as for Consume.
+func (c *Collector) Flush(id string, emit func(LogEvent) error) (int, error) {
+	var (
+		events int
+		err    error
+	)
+	c.pool.Do(id, func(src *Source) {
+		err = c.flush(src, func(e LogEvent) error {
+			if e != nil {
+				events++
+			}
+			return c.write(src, e, emit)
+		})
+	})
+	return events, err
+}
+
+// Expire flushes and drops the sources idle for longer than
+// WithCollectorIdle, which is otherwise only checked when data comes
+// in, so it's worth calling periodically.
+func (c *Collector) Expire() {
+	c.pool.Expire()
+}
+
+// Close flushes and drops every source, then closes the sink.
+func (c *Collector) Close() error {
+	c.pool.Close()
+	if c.sink == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sink.Close()
+}
+
+// Aggregate calls fn with the Aggregator of WithCollectorAggregator,
+// while no event is added to it. fn isn't called without one.
+func (c *Collector) Aggregate(fn func(a *Aggregator)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.aggregator != nil {
+		fn(c.aggregator)
+	}
+}
+
+// SourceStatus describes a source of a Collector.
+type SourceStatus struct {
+	ID string `json:"id"`
+	// LastSeen is when the source last had data.
+	LastSeen time.Time `json:"last_seen"`
+	// Stats are those of the Parser of the source since it was created.
+	Stats Stats `json:"stats"`
+	// Buffered is the number of bytes buffered for the source, see
+	// WithCollectorMemoryLimit. Overflows is the number of times it had
+	// data dropped, DroppedBytes the bytes dropped, and Refused the
+	// number of chunks refused with ErrCollectorFull.
+	Buffered     int64 `json:"buffered_bytes"`
+	Overflows    int64 `json:"overflows,omitempty"`
+	DroppedBytes int64 `json:"dropped_bytes,omitempty"`
+	Refused      int64 `json:"refused,omitempty"`
+}
+
+// Buffered returns the number of bytes buffered for all the sources.
+func (c *Collector) Buffered() int64 {
+	return atomic.LoadInt64(&c.buffered)
+}
+
+// Sources returns the status of the sources kept, sorted by id. Sources
+// dropped for being idle or to make room aren't listed, and start over
+// with new Stats when they send data again.
+func (c *Collector) Sources() []SourceStatus {
+	type entry struct {
+		src      *Source
+		lastSeen time.Time
+	}
+	pp := c.pool
+	pp.mu.Lock()
+	entries := make([]entry, 0, pp.lru.Len())
+	for el := pp.lru.Front(); el != nil; el = el.Next() {
+		src := el.Value.(*Source)
+		entries = append(entries, entry{src, src.lastSeen})
+	}
+	pp.mu.Unlock()
+
+	statuses := make([]SourceStatus, 0, len(entries))
+	for _, entry := range entries {
+		src := entry.src
+		src.mu.Lock()
+		if !src.removed {
+			state := stateOf(src)
+			statuses = append(statuses, SourceStatus{
+				ID:           src.ID,
+				LastSeen:     entry.lastSeen,
+				Stats:        src.Parser.Stats(),
+				Buffered:     state.buffered,
+				Overflows:    state.overflows,
+				DroppedBytes: state.droppedBytes,
+				Refused:      state.refused,
+			})
+		}
+		src.mu.Unlock()
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
+}
+
+// SourceStats returns the Stats of the Parser of source id, if it's
+// kept.
+func (c *Collector) SourceStats(id string) (Stats, bool) {
+	for _, status := range c.Sources() {
+		if status.ID == id {
+			return status.Stats, true
+		}
+	}
+	return Stats{}, false
+}
+
+// consume parses the lines of r for src, which must be locked, and
+// returns the number of events written.
+func (c *Collector) consume(src *Source, r io.Reader, emit func(LogEvent) error) (int, error) {
+	state := stateOf(src)
+	if c.full(state) {
+		state.refused++
+		return 0, ErrCollectorFull
+	}
+	events := 0
+	write := func(e LogEvent) error {
+		if e != nil {
+			events++
+		}
+		return c.write(src, e, emit)
+	}
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		switch {
+		case state.discarding:
+			// The rest of a line dropped for the limit.
+			state.droppedBytes += int64(len(line))
+			state.discarding = err != nil
+		case err != nil:
+			// Keeps the unterminated end of the chunk for the next one.
+			state.partial += line
+		default:
+			line = state.partial + line
+			state.partial = ""
+			if err := write(src.Parser.ConsumeLine(line)); err != nil {
+				return events, err
+			}
+		}
+		if err := write(c.limit(src, state)); err != nil {
+			return events, err
+		}
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+	}
+}
+
+// flush writes the pending event of src, which must be locked.
+func (c *Collector) flush(src *Source, write func(LogEvent) error) error {
+	state := stateOf(src)
+	defer c.account(state, 0)
+	state.discarding = false
+	if line := state.partial; line != "" {
+		state.partial = ""
+		if err := write(src.Parser.ConsumeLine(line)); err != nil {
+			return err
+		}
+	}
+	return write(src.Parser.Flush())
+}
+
+// sourceState is what a Collector keeps for a source besides its
+// Parser, as its Data.
+type sourceState struct {
+	// partial is the unterminated end of the last chunk, and discarding
+	// is set while the rest of a line dropped for the limit is skipped.
+	partial    string
+	discarding bool
+	// buffered is the number of bytes buffered, as counted in
+	// Collector.buffered.
+	buffered     int64
+	overflows    int64
+	droppedBytes int64
+	refused      int64
+}
+
+// stateOf returns the sourceState of src, which must be locked.
+func stateOf(src *Source) *sourceState {
+	state, ok := src.Data.(*sourceState)
+	if !ok {
+		state = &sourceState{}
+		src.Data = state
+	}
+	return state
+}
+
+// full reports whether data of the source with state must be refused
+// because of the total limit.
+func (c *Collector) full(state *sourceState) bool {
+	if c.maxBytes <= 0 || atomic.LoadInt64(&c.buffered) <= c.maxBytes {
+		return false
+	}
+	pp := c.pool
+	pp.mu.Lock()
+	sources := int64(pp.lru.Len())
+	pp.mu.Unlock()
+	return state.buffered > c.maxBytes/sources
+}
+
+// limit counts the bytes buffered for src, which must be locked, and
+// drops them if they're over the limit, returning the BufferOverflow
+// event to write then.
+func (c *Collector) limit(src *Source, state *sourceState) LogEvent {
+	buffered := int64(len(state.partial) + src.Parser.pendingBytes)
+	if c.maxSourceBytes <= 0 || buffered <= c.maxSourceBytes {
+		c.account(state, buffered)
+		return nil
+	}
+	src.Parser.dropPending()
+	if state.partial != "" {
+		state.partial = ""
+		state.discarding = true
+	}
+	state.overflows++
+	state.droppedBytes += buffered
+	c.account(state, 0)
+	return LogEvent{ControlTypeKey: BufferOverflow, "DroppedBytes": buffered}
+}
+
+// account sets the number of bytes buffered for the source with state.
+func (c *Collector) account(state *sourceState, buffered int64) {
+	if delta := buffered - state.buffered; delta != 0 {
+		atomic.AddInt64(&c.buffered, delta)
+		state.buffered = buffered
+	}
+}
+
+// write writes e, if not nil, tagged with its source, then passes it to
+// emit, if not nil.
+func (c *Collector) write(src *Source, e LogEvent, emit func(LogEvent) error) error {
+	if e == nil {
+		return nil
+	}
+	e["Source"] = src.ID
+	c.mu.Lock()
+	if c.aggregator != nil {
+		c.aggregator.Add(e)
+	}
+	var err error
+	if c.sink != nil {
+		err = c.sink.Write(e)
+	}
+	c.mu.Unlock()
+	if err != nil || emit == nil {
+		return err
+	}
+	return emit(e)
+}
@@ -0,0 +1,290 @@
+package mysqllog
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sourceLog returns the log of source i, with n events.
+func sourceLog(i, n int) string {
+	var b strings.Builder
+	for j := 0; j < n; j++ {
+		fmt.Fprintf(&b, "# Time: 2023-08-01T10:00:%02d.000000Z\n# User@Host: app[app] @ db%d []  Id: %d\n", j, i, i)
+		fmt.Fprintf(&b, "# Query_time: 0.5  Lock_time: 0.0 Rows_sent: 1  Rows_examined: %d\nSELECT %d FROM t%d;\n", j, j, i)
+	}
+	return b.String()
+}
+
+func TestCollector(t *testing.T) {
+	const sources, events = 1000, 3
+	sink := &recordingSink{}
+	c := NewCollector(sink, WithCollectorAggregator(WithRollups("User")))
+
+	// Chunks split lines and events, and the sources take turns.
+	chunks := make([][]string, sources)
+	for i := range chunks {
+		log := sourceLog(i, events)
+		for len(log) > 0 {
+			n := 29 + i%13
+			if n > len(log) {
+				n = len(log)
+			}
+			chunks[i] = append(chunks[i], log[:n])
+			log = log[n:]
+		}
+	}
+	total := 0
+	for left := true; left; {
+		left = false
+		for i := range chunks {
+			if len(chunks[i]) == 0 {
+				continue
+			}
+			n, err := c.Consume(fmt.Sprint("db", i), strings.NewReader(chunks[i][0]), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			total += n
+			chunks[i] = chunks[i][1:]
+			left = true
+		}
+	}
+
+	status := c.Sources()
+	if len(status) != sources {
+		t.Fatalf("expected %d sources, got %d", sources, len(status))
+	}
+	if s, ok := c.SourceStats("db7"); !ok || s.Events != events-1 {
+		t.Errorf("expected the last event of db7 to be pending, got %+v", s)
+	}
+	for i := 0; i < sources; i++ {
+		n, err := c.Flush(fmt.Sprint("db", i), nil)
+		if err != nil || n != 1 {
+			t.Fatalf("expected to flush 1 event, got %d, %v", n, err)
+		}
+		total += n
+	}
+	if total != sources*events || len(sink.events) != total {
+		t.Fatalf("expected %d events, got %d and %d written", sources*events, total, len(sink.events))
+	}
+
+	bySource := map[string][]string{}
+	for _, e := range sink.events {
+		source := e["Source"].(string)
+		bySource[source] = append(bySource[source], e["Statement"].(string))
+	}
+	for i := 0; i < sources; i++ {
+		var expected []string
+		for j := 0; j < events; j++ {
+			expected = append(expected, fmt.Sprintf("SELECT %d FROM t%d;", j, i))
+		}
+		if got := bySource[fmt.Sprint("db", i)]; strings.Join(got, "\n") != strings.Join(expected, "\n") {
+			t.Fatalf("db%d: expected %q, got %q", i, expected, got)
+		}
+	}
+
+	c.Aggregate(func(a *Aggregator) {
+		rollups := a.Rollup("Source")
+		if len(rollups) != sources || rollups[0].Count != events {
+			t.Errorf("expected %d sources of %d events, got %d %+v", sources, events, len(rollups), rollups[0])
+		}
+		if dimensions := a.Dimensions(); len(dimensions) != 2 || dimensions[0] != "User" {
+			t.Errorf("unexpected dimensions %v", dimensions)
+		}
+	})
+	if err := c.Close(); err != nil || !sink.closed || len(c.Sources()) != 0 {
+		t.Errorf("expected Close to drop every source and close the sink, got %v", err)
+	}
+}
+
+func TestCollectorConcurrent(t *testing.T) {
+	const sources, events = 1000, 2
+	sink := &recordingSink{}
+	c := NewCollector(sink, WithCollectorMaxSources(0))
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// Each source is fed by a single worker, in order, while the
+			// workers interleave.
+			for i := w; i < sources; i += 8 {
+				log := sourceLog(i, events)
+				half := len(log) / 2
+				for _, chunk := range []string{log[:half], log[half:]} {
+					if _, err := c.Consume(fmt.Sprint("db", i), strings.NewReader(chunk), nil); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if len(c.Sources()) != sources {
+		t.Errorf("expected %d sources, got %d", sources, len(c.Sources()))
+	}
+	c.Close()
+	if len(sink.events) != sources*events {
+		t.Errorf("expected %d events, got %d", sources*events, len(sink.events))
+	}
+}
+
+func TestCollectorExpiry(t *testing.T) {
+	sink := &recordingSink{}
+	clock := NewManualClock(time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC))
+	c := NewCollector(sink, WithCollectorIdle(time.Minute), WithCollectorMaxSources(10), WithCollectorClock(clock))
+
+	var emitted []LogEvent
+	emit := func(e LogEvent) error {
+		emitted = append(emitted, e)
+		return nil
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := c.Consume(fmt.Sprint("db", i), strings.NewReader(sourceLog(i, 1)), emit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The least recently seen sources were dropped to make room,
+	// flushing their event to the sink only.
+	if len(c.Sources()) != 10 || len(sink.events) != 90 || len(emitted) != 0 {
+		t.Fatalf("expected 10 sources and 90 events, got %d and %d, %d emitted", len(c.Sources()), len(sink.events), len(emitted))
+	}
+	if sink.events[0]["Source"] != "db0" {
+		t.Errorf("expected db0 to be dropped first, got %v", sink.events[0]["Source"])
+	}
+
+	clock.Add(30 * time.Second)
+	c.Consume("db99", strings.NewReader(sourceLog(99, 1)), emit)
+	clock.Add(45 * time.Second)
+	c.Expire()
+	if status := c.Sources(); len(status) != 1 || status[0].ID != "db99" || !status[0].LastSeen.Equal(clock.Now().Add(-45*time.Second)) {
+		t.Errorf("expected idle sources to be dropped, got %+v", status)
+	}
+	if len(sink.events) != 100 || len(emitted) != 1 {
+		t.Errorf("expected 100 events, got %d, %d emitted", len(sink.events), len(emitted))
+	}
+}
+
+func TestCollectorMemoryLimit(t *testing.T) {
+	const sources, events = 10, 5
+	sink := &recordingSink{}
+	c := NewCollector(sink, WithCollectorMemoryLimit(1024, 0))
+
+	chunks := map[string][]string{}
+	split := func(id, log string, n int) {
+		for len(log) > n {
+			chunks[id] = append(chunks[id], log[:n])
+			log = log[n:]
+		}
+		chunks[id] = append(chunks[id], log)
+	}
+	for i := 0; i < sources; i++ {
+		split(fmt.Sprint("db", i), sourceLog(i, events), 40)
+	}
+	// The hostile source sends a giant statement, then a corrupted chunk
+	// without newlines, then events again.
+	giant := "# Time: 2023-08-01T10:00:00.000000Z\n# Query_time: 9.0  Lock_time: 0.0 Rows_sent: 1  Rows_examined: 1\nSELECT\n" +
+		strings.Repeat(strings.Repeat("x", 99)+"\n", 30) + ";\n"
+	split("hostile", giant+strings.Repeat("\x00garbage", 625)+"\n"+sourceLog(99, 2), 500)
+
+	for left := true; left; {
+		left = false
+		for id, list := range chunks {
+			if len(list) == 0 {
+				continue
+			}
+			if _, err := c.Consume(id, strings.NewReader(list[0]), nil); err != nil {
+				t.Fatal(err)
+			}
+			chunks[id] = list[1:]
+			left = true
+		}
+	}
+
+	var total int64
+	for _, status := range c.Sources() {
+		total += status.Buffered
+		switch {
+		case status.ID == "hostile":
+			if status.Overflows != 2 || status.DroppedBytes < 1024+1024 {
+				t.Errorf("expected the hostile source to overflow twice, got %+v", status)
+			}
+		case status.Overflows != 0 || status.Buffered == 0 || status.Buffered > 1024:
+			t.Errorf("expected %s to buffer its last event only, got %+v", status.ID, status)
+		}
+	}
+	if total != c.Buffered() {
+		t.Errorf("expected %d bytes buffered in all, got %d", total, c.Buffered())
+	}
+	c.Close()
+	if c.Buffered() != 0 {
+		t.Errorf("expected nothing buffered after Close, got %d", c.Buffered())
+	}
+
+	bySource := map[string][]string{}
+	for _, e := range sink.events {
+		statement, _ := e["Statement"].(string)
+		if e[ControlTypeKey] == BufferOverflow {
+			statement = fmt.Sprint("overflow of ", e["DroppedBytes"], " bytes")
+		}
+		bySource[e["Source"].(string)] = append(bySource[e["Source"].(string)], statement)
+	}
+	for i := 0; i < sources; i++ {
+		if got := bySource[fmt.Sprint("db", i)]; len(got) != events || got[events-1] != fmt.Sprintf("SELECT %d FROM t%d;", events-1, i) {
+			t.Errorf("db%d: expected its %d events, got %q", i, events, got)
+		}
+	}
+	if got := bySource["hostile"]; len(got) != 4 || !strings.HasPrefix(got[0], "overflow of ") || !strings.HasPrefix(got[1], "overflow of ") ||
+		got[2] != "SELECT 0 FROM t99;" || got[3] != "SELECT 1 FROM t99;" {
+		t.Errorf("expected 2 overflows and the events after them, got %q", got)
+	}
+}
+
+func TestCollectorTotalMemoryLimit(t *testing.T) {
+	sink := &recordingSink{}
+	c := NewCollector(sink, WithCollectorMemoryLimit(0, 2048))
+
+	if _, err := c.Consume("hostile", strings.NewReader(strings.Repeat("x", 3000)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Consume("hostile", strings.NewReader("more"), nil); err != ErrCollectorFull {
+		t.Errorf("expected the hostile source to be refused, got %v", err)
+	}
+	// The others still have their share.
+	for i := 0; i < 3; i++ {
+		log := sourceLog(i, 3)
+		for _, chunk := range []string{log[:len(log)/2], log[len(log)/2:]} {
+			if _, err := c.Consume(fmt.Sprint("db", i), strings.NewReader(chunk), nil); err != nil {
+				t.Fatalf("db%d: %v", i, err)
+			}
+		}
+	}
+	if len(sink.events) != 3*2 {
+		t.Errorf("expected the events of the others, got %d", len(sink.events))
+	}
+	if _, err := c.Consume("hostile", strings.NewReader("more"), nil); err != ErrCollectorFull {
+		t.Errorf("expected the hostile source to be refused, got %v", err)
+	}
+	for _, status := range c.Sources() {
+		if status.ID == "hostile" && (status.Refused != 2 || status.Buffered != 3000) {
+			t.Errorf("expected 2 chunks refused, got %+v", status)
+		}
+	}
+
+	handler := IngestHandler(nil, WithCollector(c))
+	if code, _ := upload(t, handler, "hostile", "/", []byte("more\n"), false); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", code)
+	}
+	if code, n := upload(t, handler, "db0", "/", []byte(sourceLog(0, 1)), false); code != http.StatusOK || n != 1 {
+		t.Errorf("expected the others to be served, got %d with %d events", code, n)
+	}
+
+	c.Flush("hostile", nil)
+	if _, err := c.Consume("hostile", strings.NewReader(sourceLog(9, 1)), nil); err != nil {
+		t.Errorf("expected the hostile source to be served once flushed, got %v", err)
+	}
+}
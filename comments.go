@@ -0,0 +1,150 @@
+package mysqllog
+
+import (
+	"net/url"
+	"strings"
+)
+
+// WithCommentMetadata sets "Meta" on each event to a map[string]string
+// of the key/value pairs in the comments before or after its statement,
+// as written by marginalia, "/*app:shop,controller:cart*/", or
+// sqlcommenter and similar tools,
+// "/*app='shop',traceparent='00-4bf9...-01'*/". Keys can be separated
+// from values by "=" or ":", quoted values are unquoted and URL-decoded,
+// and bare tags, such as "/*nightly,batch*/", have an empty value. If a
+// key is repeated, the last one wins.
+//
+// Optimizer hints, versioned comments, free-text comments and anything
+// malformed are ignored. The statement is left as it is; Fingerprint
+// already leaves comments out.
+func WithCommentMetadata() Option {
+	return func(p *Parser) {
+		p.commentMetadata = true
+	}
+}
+
+// CommentMetadata returns the metadata in the leading and trailing
+// comments of statement, as set by WithCommentMetadata, or nil if there
+// is none.
+func CommentMetadata(statement string) map[string]string {
+	var meta map[string]string
+	for _, comment := range edgeComments(statement) {
+		pairs, ok := parseCommentPairs(comment)
+		if !ok {
+			continue
+		}
+		if meta == nil {
+			meta = map[string]string{}
+		}
+		for _, kv := range pairs {
+			meta[kv[0]] = kv[1]
+		}
+	}
+	return meta
+}
+
+// edgeComments returns the bodies of the /* */ comments at the start
+// and the end of statement, in order.
+func edgeComments(statement string) []string {
+	var leading, trailing []string
+	s := strings.TrimSpace(statement)
+	for strings.HasPrefix(s, "/*") {
+		end := strings.Index(s[2:], "*/")
+		if end < 0 {
+			return leading
+		}
+		leading = append(leading, s[2:2+end])
+		s = strings.TrimSpace(s[end+4:])
+	}
+	for {
+		s = strings.TrimSpace(strings.TrimRight(s, "; \t\r\n"))
+		if !strings.HasSuffix(s, "*/") {
+			break
+		}
+		start := strings.LastIndex(s[:len(s)-2], "/*")
+		if start < 0 {
+			break
+		}
+		trailing = append([]string{s[start+2 : len(s)-2]}, trailing...)
+		s = s[:start]
+	}
+	return append(leading, trailing...)
+}
+
+// parseCommentPairs splits a comment body into key/value pairs, and
+// returns false if it isn't a list of pairs and tags.
+func parseCommentPairs(comment string) ([][2]string, bool) {
+	comment = strings.TrimSpace(comment)
+	if comment == "" || comment[0] == '+' || comment[0] == '!' {
+		return nil, false
+	}
+	var pairs [][2]string
+	for _, item := range splitCommentItems(comment) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.IndexAny(item, "=:")
+		if i < 0 {
+			if strings.ContainsAny(item, " \t\r\n'\"") {
+				return nil, false
+			}
+			pairs = append(pairs, [2]string{item, ""})
+			continue
+		}
+		key := strings.TrimSpace(item[:i])
+		if key == "" || strings.ContainsAny(key, " \t\r\n'\"") {
+			return nil, false
+		}
+		if k, err := url.PathUnescape(key); err == nil {
+			key = k
+		}
+		value, ok := commentValue(strings.TrimSpace(item[i+1:]))
+		if !ok {
+			return nil, false
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs, len(pairs) > 0
+}
+
+// splitCommentItems splits s at commas outside of quotes.
+func splitCommentItems(s string) []string {
+	var items []string
+	start := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+// commentValue unquotes and URL-decodes a quoted value, and returns
+// false for an unterminated quote or an unquoted value with spaces.
+func commentValue(v string) (string, bool) {
+	if v == "" || (v[0] != '\'' && v[0] != '"') {
+		return v, !strings.ContainsAny(v, " \t\r\n")
+	}
+	quote := v[0]
+	if len(v) < 2 || v[len(v)-1] != quote {
+		return "", false
+	}
+	v = strings.Replace(v[1:len(v)-1], `\`+string(quote), string(quote), -1)
+	if decoded, err := url.PathUnescape(v); err == nil {
+		v = decoded
+	}
+	return v, true
+}
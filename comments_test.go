@@ -0,0 +1,65 @@
+package mysqllog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommentMetadata(t *testing.T) {
+	type TestCase struct {
+		Statement string
+		Meta      map[string]string
+	}
+	for _, c := range []TestCase{
+		{
+			"SELECT * FROM carts WHERE id = 1 /*application:shop,controller:CartController,action:show*/",
+			map[string]string{"application": "shop", "controller": "CartController", "action": "show"},
+		},
+		{
+			"/*app=checkout,controller=CartController,traceparent=00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01*/ SELECT 1;",
+			map[string]string{"app": "checkout", "controller": "CartController", "traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		},
+		{
+			// sqlcommenter
+			"SELECT * FROM t /*action='%2Fparam*d',controller='index',framework='spring',route='%2Fpolls%201000'*/;",
+			map[string]string{"action": "/param*d", "controller": "index", "framework": "spring", "route": "/polls 1000"},
+		},
+		{
+			"/* nightly,batch */ /*job='it\\'s, fine'*/ DELETE FROM sessions",
+			map[string]string{"nightly": "", "batch": "", "job": "it's, fine"},
+		},
+		// Plain, hint, versioned and malformed comments are ignored.
+		{"/* nightly report job */ SELECT 1", nil},
+		{"/* note: this is slow */ SELECT 1", nil},
+		{"SELECT /*+ MAX_EXECUTION_TIME(1000) */ * FROM t /*!40001 SQL_NO_CACHE */", nil},
+		{"/*app='unterminated*/ SELECT 1", nil},
+		{"/*app=shop SELECT 1", nil},
+		{"SELECT 1 */", nil},
+		{"SELECT '/*app=shop*/' FROM t WHERE a = 1", nil},
+		{"", nil},
+	} {
+		if meta := CommentMetadata(c.Statement); !reflect.DeepEqual(meta, c.Meta) {
+			t.Errorf("%q: expected %v, got %v", c.Statement, c.Meta, meta)
+		}
+	}
+}
+
+func TestWithCommentMetadata(t *testing.T) {
+	log := "# Time: 2017-12-24T02:41:51.316510Z\n# Query_time: 1.0  Lock_time: 0.0 Rows_sent: 0  Rows_examined: 0\n" +
+		"SELECT * FROM carts WHERE id = 1 /*app=shop,controller=cart*/;\n" +
+		"# Time: 2017-12-24T02:41:52.316510Z\n# Query_time: 1.0  Lock_time: 0.0 Rows_sent: 0  Rows_examined: 0\n" +
+		"SELECT * FROM carts WHERE id = 2 /*app=shop,controller=checkout*/;\n"
+	events := parseAll(NewParser(WithCommentMetadata()), log)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if meta, _ := events[1]["Meta"].(map[string]string); meta["controller"] != "checkout" {
+		t.Errorf("unexpected metadata %v", events[1]["Meta"])
+	}
+	if events[0]["Statement"] != "SELECT * FROM carts WHERE id = 1 /*app=shop,controller=cart*/;" {
+		t.Errorf("expected the statement to be kept, got %q", events[0]["Statement"])
+	}
+	if Fingerprint(events[0]["Statement"].(string)) != Fingerprint(events[1]["Statement"].(string)) {
+		t.Errorf("expected annotations not to split fingerprints")
+	}
+}
@@ -0,0 +1,133 @@
+package mysqllog
+
+import (
+	"io"
+	"sort"
+)
+
+// QueryDelta compares the stats of a fingerprint in two sets of results.
+type QueryDelta struct {
+	Fingerprint string
+	// Before and After are zero if the fingerprint is missing from that side.
+	Before QueryStats
+	After  QueryStats
+	// New is set for fingerprints only in after, and Gone for
+	// fingerprints only in before.
+	New  bool
+	Gone bool
+
+	// CountDelta and TimeDelta are the changes in calls and total
+	// Query_time, per 1000 events if PerThousandEvents is given.
+	CountDelta float64
+	TimeDelta  float64
+
+	// Percentages are zero when the fingerprint is new.
+	MeanDelta   float64
+	MeanPercent float64
+	P95Delta    float64
+	P95Percent  float64
+}
+
+// CompareOption configures CompareAggregates.
+type CompareOption func(*compareOptions)
+
+type compareOptions struct {
+	perThousand bool
+}
+
+// PerThousandEvents normalizes counts and total times to the number of
+// events in each set, so logs covering different amounts of traffic can
+// be compared.
+func PerThousandEvents() CompareOption {
+	return func(o *compareOptions) {
+		o.perThousand = true
+	}
+}
+
+// CompareAggregates joins before and after on fingerprint. Deltas are
+// sorted by TimeDelta, so the largest regressions come first and the
+// largest improvements last.
+func CompareAggregates(before, after []QueryStats, opts ...CompareOption) []QueryDelta {
+	o := &compareOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	beforeScale, afterScale := 1.0, 1.0
+	if o.perThousand {
+		beforeScale = perThousand(before)
+		afterScale = perThousand(after)
+	}
+
+	deltas := map[string]*QueryDelta{}
+	for _, s := range before {
+		deltas[s.Fingerprint] = &QueryDelta{Fingerprint: s.Fingerprint, Before: s, Gone: true}
+	}
+	for _, s := range after {
+		d := deltas[s.Fingerprint]
+		if d == nil {
+			d = &QueryDelta{Fingerprint: s.Fingerprint, New: true}
+			deltas[s.Fingerprint] = d
+		}
+		d.After = s
+		d.Gone = false
+	}
+
+	results := make([]QueryDelta, 0, len(deltas))
+	for _, d := range deltas {
+		d.CountDelta = float64(d.After.Count)*afterScale - float64(d.Before.Count)*beforeScale
+		d.TimeDelta = d.After.TotalTime*afterScale - d.Before.TotalTime*beforeScale
+		if !d.Gone {
+			d.MeanDelta, d.MeanPercent = change(d.Before.MeanTime(), d.After.MeanTime())
+			d.P95Delta, d.P95Percent = change(d.Before.P95Time(), d.After.P95Time())
+		}
+		results = append(results, *d)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].TimeDelta != results[j].TimeDelta {
+			return results[i].TimeDelta > results[j].TimeDelta
+		}
+		return results[i].Fingerprint < results[j].Fingerprint
+	})
+	return results
+}
+
+// perThousand returns the factor scaling counts in results to per 1000 events.
+func perThousand(results []QueryStats) float64 {
+	var count int64
+	for _, s := range results {
+		count += s.Count
+	}
+	if count == 0 {
+		return 0
+	}
+	return 1000 / float64(count)
+}
+
+// change returns the difference from before to after, and the percent
+// change, which is zero if before is zero.
+func change(before, after float64) (delta, pct float64) {
+	delta = after - before
+	if before != 0 {
+		pct = 100 * delta / before
+	}
+	return delta, pct
+}
+
+// WriteComparison writes deltas as a plain text table, in the order given.
+func WriteComparison(w io.Writer, deltas []QueryDelta) error {
+	ew := &errWriter{w: w}
+	ew.printf("# Time change    Calls before/after  Mean change          P95 change           Query\n")
+	for _, d := range deltas {
+		ew.printf("%+12.6fs %8d/%-8d", d.TimeDelta, d.Before.Count, d.After.Count)
+		switch {
+		case d.New:
+			ew.printf("   %-20s %-20s", "new", "new")
+		case d.Gone:
+			ew.printf("   %-20s %-20s", "gone", "gone")
+		default:
+			ew.printf("   %+10.6fs %+7.1f%% %+10.6fs %+7.1f%%", d.MeanDelta, d.MeanPercent, d.P95Delta, d.P95Percent)
+		}
+		ew.printf(" %s\n", Truncate(d.Fingerprint, 60))
+	}
+	return ew.err
+}
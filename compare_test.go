@@ -0,0 +1,81 @@
+package mysqllog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+)
+
+func aggregateFile(t *testing.T, path string) []QueryStats {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aggregate(parseAll(&Parser{}, string(b)))
+}
+
+func TestCompareAggregates(t *testing.T) {
+	before := aggregateFile(t, "./_test/before.txt")
+	after := aggregateFile(t, "./_test/after.txt")
+
+	deltas := CompareAggregates(before, after)
+	if len(deltas) != 4 {
+		t.Fatalf("expected 4 deltas, got %d", len(deltas))
+	}
+
+	type TestCase struct {
+		Fingerprint string
+		New, Gone   bool
+		TimeDelta   float64
+		MeanPercent float64
+	}
+	for i, tc := range []TestCase{
+		{"select * from orders where id = ?", false, false, 1.178, 5354.5},
+		{"select * from coupons where code = ?", true, false, 0.03, 0},
+		{"delete from sessions where expires < ?", false, true, -0.05, 0},
+		{"select * from customers where name like ?", false, false, -0.1, -50},
+	} {
+		d := deltas[i]
+		if d.Fingerprint != tc.Fingerprint || d.New != tc.New || d.Gone != tc.Gone ||
+			math.Abs(d.TimeDelta-tc.TimeDelta) > 1e-9 || math.Abs(d.MeanPercent-tc.MeanPercent) > 0.1 {
+			t.Errorf("%d: expected %+v, got %+v", i, tc, d)
+		}
+	}
+	if d := deltas[0]; d.P95Percent < 1000 {
+		t.Errorf("expected the p95 of the regressed query to grow, got %+.1f%%", d.P95Percent)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteComparison(&buf, deltas); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || !strings.HasSuffix(lines[1], "select * from orders where id = ?") ||
+		!strings.Contains(lines[2], "new") || !strings.Contains(lines[3], "gone") {
+		t.Errorf("unexpected comparison\n%s", buf.String())
+	}
+}
+
+func TestCompareAggregatesPerThousandEvents(t *testing.T) {
+	before := aggregate([]LogEvent{
+		{"Statement": "SELECT a FROM t", "Query_time": 1.0},
+		{"Statement": "SELECT b FROM t", "Query_time": 1.0},
+	})
+	var events []LogEvent
+	for i := 0; i < 10; i++ {
+		events = append(events, LogEvent{"Statement": "SELECT a FROM t", "Query_time": 1.0})
+		events = append(events, LogEvent{"Statement": "SELECT b FROM t", "Query_time": 1.0})
+	}
+	after := aggregate(events)
+
+	for _, d := range CompareAggregates(before, after, PerThousandEvents()) {
+		if d.CountDelta != 0 || d.TimeDelta != 0 {
+			t.Errorf("expected no change for %q with the same mix of traffic, got %+v", d.Fingerprint, d)
+		}
+	}
+	if d := CompareAggregates(before, after)[0]; d.CountDelta != 9 {
+		t.Errorf("expected a raw count delta of 9, got %f", d.CountDelta)
+	}
+}
@@ -0,0 +1,41 @@
+package mysqllog
+
+// ConnectionID returns the connection id of e: its "Id", from the
+// User@Host line, or else its "Thread_id".
+func ConnectionID(e LogEvent) (id int64, ok bool) {
+	if id, ok = e.Int64("Id"); ok {
+		return id, true
+	}
+	return e.Int64("Thread_id")
+}
+
+// WithConnectionFilter keeps only the events of the connections with
+// the given ids (see ConnectionID), such as to pull the history of a
+// session found in the processlist. Events without an id are dropped
+// and counted in Stats.NoConnectionID, and events of other connections
+// in Stats.ConnectionFiltered.
+func WithConnectionFilter(ids ...int64) Option {
+	return func(p *Parser) {
+		p.connections = make(map[int64]bool, len(ids))
+		for _, id := range ids {
+			p.connections[id] = true
+		}
+	}
+}
+
+// admitConnection applies WithConnectionFilter to a completed event.
+func (p *Parser) admitConnection(e LogEvent) bool {
+	if p.connections == nil {
+		return true
+	}
+	id, ok := ConnectionID(e)
+	if !ok {
+		p.stats.NoConnectionID++
+		return false
+	}
+	if !p.connections[id] {
+		p.stats.ConnectionFiltered++
+		return false
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+package mysqllog
+
+import "testing"
+
+func TestWithConnectionFilter(t *testing.T) {
+	input := "# User@Host: app[app] @ web1 []  Id:    12\n# Query_time: 1\nLOCK TABLES t WRITE;\n" +
+		"# User@Host: app[app] @ web2 []  Id:    13\n# Query_time: 1\nSELECT 1;\n" +
+		"# Query_time: 1\nSELECT 2;\n" +
+		"# Thread_id: 12  Schema: app\n# Query_time: 2\nALTER TABLE t ADD c INT;\n"
+	p := NewParser(WithConnectionFilter(12))
+	events := parseAll(p, input)
+	if len(events) != 2 || events[0]["Statement"] != "LOCK TABLES t WRITE;" || events[1]["Statement"] != "ALTER TABLE t ADD c INT;" {
+		t.Fatalf("expected the events of connection 12, got %v", events)
+	}
+	if stats := p.Stats(); stats.Events != 2 || stats.NoConnectionID != 1 || stats.ConnectionFiltered != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	a := NewAggregator(WithRollups("Connection"))
+	for _, e := range parseAll(&Parser{}, input) {
+		a.Add(e)
+	}
+	rollups := a.Rollup("Connection")
+	if len(rollups) != 3 || rollups[0].Value != "12" || rollups[0].Count != 2 || rollups[0].TotalTime != 3 {
+		t.Fatalf("unexpected rollups %+v", rollups)
+	}
+	if rollups[1].Value != NoneValue && rollups[2].Value != NoneValue {
+		t.Errorf("expected events without an id under %q, got %+v", NoneValue, rollups)
+	}
+}
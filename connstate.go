@@ -0,0 +1,148 @@
+package mysqllog
+
+import (
+	"container/list"
+	"time"
+)
+
+// DefaultTrackedConnections is the number of connections
+// WithConnectionTracking keeps the state of.
+const DefaultTrackedConnections = 10000
+
+// ConnectionState is what the events of a connection have shown about
+// it, as returned by Parser.Connections.
+type ConnectionState struct {
+	ID int64 `json:"id"`
+	// User and Host are those of the last event with a # User@Host line.
+	User string `json:"user,omitempty"`
+	Host string `json:"host,omitempty"`
+	// Database is the default database of the last use line, which the
+	// server writes when it changes.
+	Database string `json:"database,omitempty"`
+	// LastEvent is the time of the last event with a timestamp, and
+	// Events the number of events.
+	LastEvent time.Time `json:"last_event"`
+	Events    int64     `json:"events"`
+}
+
+// WithConnectionTracking keeps the ConnectionState of each connection,
+// by its Id, or Thread_id if it has none, for Parser.Connections, as
+// for a view of the current sessions while tailing. Every event counts,
+// including those filtered out later.
+//
+// At most maxConnections connections are kept, the least recently
+// active being forgotten first, or DefaultTrackedConnections if
+// maxConnections is 0 or less. Everything is forgotten at a server
+// startup banner, since connection ids start over. TailFile and
+// WatchDir keep the states in their checkpoints.
+func WithConnectionTracking(maxConnections int) Option {
+	return func(p *Parser) {
+		if maxConnections <= 0 {
+			maxConnections = DefaultTrackedConnections
+		}
+		p.tracker = &connectionTracker{
+			max:         maxConnections,
+			lru:         list.New(),
+			connections: map[int64]*list.Element{},
+		}
+	}
+}
+
+// Connections returns the state of the connections tracked by
+// WithConnectionTracking, the most recently active first, or nil
+// without it.
+func (p *Parser) Connections() []ConnectionState {
+	if p.tracker == nil {
+		return nil
+	}
+	return p.tracker.states()
+}
+
+// RestoreConnections replaces the state of the connections tracked by
+// WithConnectionTracking with states, as returned by Connections, such
+// as from a checkpoint.
+func (p *Parser) RestoreConnections(states []ConnectionState) {
+	if p.tracker != nil {
+		p.tracker.restore(states)
+	}
+}
+
+type connectionTracker struct {
+	max         int
+	lru         *list.List
+	connections map[int64]*list.Element
+	// banner is the line number of a startup banner not acted on yet.
+	banner int
+}
+
+// track updates the state of the connection of e, whose first line is
+// line.
+func (t *connectionTracker) track(e LogEvent, line int) {
+	if t.banner > 0 && line > t.banner {
+		t.restore(nil)
+		t.banner = 0
+	}
+	id, ok := ConnectionID(e)
+	if !ok {
+		return
+	}
+	var c *ConnectionState
+	if el, ok := t.connections[id]; ok {
+		t.lru.MoveToFront(el)
+		c = el.Value.(*ConnectionState)
+	} else {
+		c = &ConnectionState{ID: id}
+		t.connections[id] = t.lru.PushFront(c)
+		if t.lru.Len() > t.max {
+			oldest := t.lru.Back()
+			t.lru.Remove(oldest)
+			delete(t.connections, oldest.Value.(*ConnectionState).ID)
+		}
+	}
+	if user, ok := e["User"].(string); ok {
+		c.User = user
+		c.Host, _ = e["Host"].(string)
+	}
+	if database, ok := e["Database"].(string); ok {
+		c.Database = database
+	}
+	if ts, ok := EventTime(e); ok {
+		c.LastEvent = ts
+	}
+	c.Events++
+}
+
+func (t *connectionTracker) states() []ConnectionState {
+	states := make([]ConnectionState, 0, t.lru.Len())
+	for el := t.lru.Front(); el != nil; el = el.Next() {
+		states = append(states, *el.Value.(*ConnectionState))
+	}
+	return states
+}
+
+// restore replaces the states with states, the most recently active
+// first.
+func (t *connectionTracker) restore(states []ConnectionState) {
+	t.lru.Init()
+	t.connections = map[int64]*list.Element{}
+	for i := len(states) - 1; i >= 0; i-- {
+		c := states[i]
+		if el, ok := t.connections[c.ID]; ok {
+			t.lru.Remove(el)
+		}
+		t.connections[c.ID] = t.lru.PushFront(&c)
+	}
+	for t.lru.Len() > t.max {
+		oldest := t.lru.Back()
+		t.lru.Remove(oldest)
+		delete(t.connections, oldest.Value.(*ConnectionState).ID)
+	}
+}
+
+// checkBanner notes if line, the nth, is a server startup banner, so
+// events after it forget every connection.
+func (t *connectionTracker) checkBanner(line string, n int) {
+	if isBanner(line) {
+		t.banner = n
+	}
+}
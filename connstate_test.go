@@ -0,0 +1,127 @@
+package mysqllog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// connectionEvent returns an event of connection id at second ts, with
+// a use line if use isn't empty.
+func connectionEvent(id int64, user string, ts int, use string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# User@Host: %s[%s] @ web1 []  Id: %d\n# Query_time: 0.1\n", user, user, id)
+	if use != "" {
+		fmt.Fprintf(&b, "use %s;\n", use)
+	}
+	fmt.Fprintf(&b, "SET timestamp=%d;\nSELECT %d;\n", 1690884000+ts, ts)
+	return b.String()
+}
+
+func TestConnections(t *testing.T) {
+	content := connectionEvent(5, "app", 0, "orders") +
+		connectionEvent(6, "batch", 1, "") +
+		connectionEvent(5, "app", 2, "") +
+		connectionEvent(5, "app", 3, "billing") +
+		connectionEvent(6, "batch", 4, "reports") +
+		connectionEvent(5, "app", 5, "audit")
+	p := NewParser(WithConnectionTracking(0))
+	parseAll(p, content)
+	expected := []ConnectionState{
+		{ID: 5, User: "app", Host: "web1", Database: "audit", LastEvent: time.Unix(1690884005, 0), Events: 4},
+		{ID: 6, User: "batch", Host: "web1", Database: "reports", LastEvent: time.Unix(1690884004, 0), Events: 2},
+	}
+	checkConnections(t, p.Connections(), expected)
+
+	if NewParser().Connections() != nil {
+		t.Error("expected no connections without WithConnectionTracking")
+	}
+
+	// The least recently active connection is forgotten first.
+	p = NewParser(WithConnectionTracking(2))
+	parseAll(p, content+connectionEvent(7, "app", 6, ""))
+	if states := p.Connections(); len(states) != 2 || states[0].ID != 7 || states[1].ID != 5 {
+		t.Errorf("unexpected connections %+v", states)
+	}
+
+	// A server restart forgets every connection.
+	p = NewParser(WithConnectionTracking(0))
+	banner := "/usr/sbin/mysqld, Version: 8.0.33 (MySQL Community Server - GPL). started with:\n" +
+		"Tcp port: 3306  Unix socket: /var/run/mysqld/mysqld.sock\nTime                 Id Command    Argument\n"
+	parseAll(p, content+banner+connectionEvent(5, "other", 7, ""))
+	checkConnections(t, p.Connections(), []ConnectionState{{ID: 5, User: "other", Host: "web1", LastEvent: time.Unix(1690884007, 0), Events: 1}})
+}
+
+func TestConnectionsCheckpoint(t *testing.T) {
+	state := CheckpointState{Offset: 10, Connections: []ConnectionState{
+		{ID: 5, User: "app", Host: "web1", Database: "audit", LastEvent: time.Unix(1690884005, 0).UTC(), Events: 4},
+		{ID: 6, User: "batch", Events: 2},
+	}}
+	b, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded CheckpointState
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.equal(state) {
+		t.Errorf("expected %+v, got %+v from %s", state, decoded, b)
+	}
+	p := NewParser(WithConnectionTracking(0))
+	p.RestoreConnections(decoded.Connections)
+	parseAll(p, connectionEvent(6, "batch", 9, ""))
+	if states := p.Connections(); len(states) != 2 || states[0].ID != 6 || states[0].Events != 3 || states[1].Database != "audit" {
+		t.Errorf("unexpected connections %+v", states)
+	}
+
+	// TailFile resumes the connections from its checkpoint.
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+	appendFile(t, path, connectionEvent(5, "app", 0, "orders")+connectionEvent(6, "batch", 1, ""))
+	store := &memoryStore{states: map[string]CheckpointState{}}
+	opts := append(fastPoll, WithCheckpointStore(store), WithTailParserOptions(WithConnectionTracking(0)))
+	for i, use := range []string{"billing", "audit"} {
+		run := startFollow(func(ctx context.Context, fn func(LogEvent)) error {
+			return TailFile(ctx, path, fn, opts...)
+		})
+		if i == 0 {
+			run.expect(t, "SELECT 0;")
+		}
+		appendFile(t, path, connectionEvent(5, "app", 2+i, use))
+		run.expect(t, fmt.Sprintf("SELECT %d;", 1+i))
+		time.Sleep(30 * time.Millisecond)
+		run.stop(t)
+	}
+	state, _, _ = store.Load(path)
+	if len(state.Connections) != 2 || state.Connections[0].ID != 5 || state.Connections[0].Database != "billing" || state.Connections[0].Events != 2 {
+		t.Errorf("unexpected connections in the checkpoint %+v", state.Connections)
+	}
+}
+
+func checkConnections(t *testing.T, got, expected []ConnectionState) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+	for i := range expected {
+		if !got[i].LastEvent.Equal(expected[i].LastEvent) {
+			t.Errorf("connection %d: expected last event %v, got %v", i, expected[i].LastEvent, got[i].LastEvent)
+		}
+		got[i].LastEvent = expected[i].LastEvent
+		if !reflect.DeepEqual(got[i], expected[i]) {
+			t.Errorf("connection %d: expected %+v, got %+v", i, expected[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package mysqllog
+
+// ControlTypeKey is the attribute holding the type of the control events
+// of WithControlEvents, ServerRestart or LogRotation, and of the
+// BufferOverflow events of a Collector. Other events don't have it.
+const ControlTypeKey = "_type"
+
+// Types of control events.
+const (
+	// ServerRestart is a server startup banner in the log, with the
+	// "ServerVersion", "ServerFlavor", "ServerBinary", "ServerPort" and
+	// "ServerSocket" it tells, and "Offset" with WithOffsets. Banners
+	// have no time of their own.
+	ServerRestart = "ServerRestart"
+	// LogRotation is where reading moved to another file, as between the
+	// files of ParseFiles or when TailFile follows rotation, with the
+	// "SourceFile" read next and, when following, "Rotation", "rename"
+	// or "truncate".
+	LogRotation = "LogRotation"
+	// BufferOverflow is data of a Collector source dropped for
+	// WithCollectorMemoryLimit, with the "DroppedBytes". The Collector
+	// writes it without WithControlEvents.
+	BufferOverflow = "BufferOverflow"
+)
+
+// WithControlEvents emits control events among the events parsed, in log
+// order: a ServerRestart for each startup banner, and a LogRotation
+// where reading moved to another file. They have a ControlTypeKey
+// telling them apart, see IsControlEvent, and go straight to the caller,
+// without hooks, labels or filters. A banner ends the pending event, as
+// a header would.
+//
+// Aggregators ignore control events; other sinks write them as any
+// event, so wrap those that shouldn't see them with DropControlEvents.
+// MySQL also writes a banner when FLUSH LOGS reopens the log, so a
+// ServerRestart may be the log reopened by the same server.
+func WithControlEvents() Option {
+	return func(p *Parser) {
+		p.controlEvents = true
+	}
+}
+
+// IsControlEvent reports whether e is a control event of
+// WithControlEvents.
+func IsControlEvent(e LogEvent) bool {
+	_, ok := e[ControlTypeKey].(string)
+	return ok
+}
+
+// DropControlEvents returns a Sink writing the events written to it to
+// sink, except for control events.
+func DropControlEvents(sink Sink) Sink {
+	return controlFilter{sink}
+}
+
+type controlFilter struct {
+	Sink
+}
+
+func (f controlFilter) Write(e LogEvent) error {
+	if IsControlEvent(e) {
+		return nil
+	}
+	return f.Sink.Write(e)
+}
+
+// pendingRestart is a banner not emitted yet as a ServerRestart, until
+// the line after it tells the port.
+type pendingRestart struct {
+	server ServerInfo
+	offset int64
+}
+
+// consumeControl handles line for WithControlEvents, after checkServer.
+// handled is set for a banner, which isn't consumed as other lines, and
+// e is the event it ended or the ServerRestart of a banner right before
+// it, if any. Otherwise, e is the ServerRestart of
+// the banner before line, to return in place of the event of line,
+// which has none since a banner leaves no event pending.
+func (p *Parser) consumeControl(line string) (e LogEvent, handled bool) {
+	restart := p.takeRestart()
+	if !isBanner(line) {
+		return restart, false
+	}
+	if p.inHeader || p.inQuery {
+		// There's none with a restart pending.
+		var reason IncompleteReason
+		if p.inHeader || p.quote != 0 {
+			reason = IncompleteResync
+		}
+		p.inHeader, p.inQuery = false, false
+		restart = p.finish(reason)
+	}
+	p.restart = &pendingRestart{server: p.server, offset: p.lineStart}
+	return restart, true
+}
+
+// takeRestart returns the ServerRestart of the banner before the line
+// being consumed, if any.
+func (p *Parser) takeRestart() LogEvent {
+	r := p.restart
+	if r == nil {
+		return nil
+	}
+	p.restart = nil
+	server := r.server
+	if p.server.Line == server.Line {
+		// With the port, if the line after the banner had it.
+		server = p.server
+	}
+	e := LogEvent{ControlTypeKey: ServerRestart}
+	for key, value := range map[string]string{
+		"ServerVersion": server.Version,
+		"ServerFlavor":  server.Flavor.String(),
+		"ServerBinary":  server.Binary,
+		"ServerSocket":  server.Socket,
+	} {
+		if value != "" {
+			e[key] = value
+		}
+	}
+	if server.Port > 0 {
+		e["ServerPort"] = int64(server.Port)
+	}
+	if p.offsets {
+		e["Offset"] = r.offset
+	}
+	return e
+}
+
+// rotationEvent returns a LogRotation for reading path next.
+func rotationEvent(path, rotation string) LogEvent {
+	e := LogEvent{ControlTypeKey: LogRotation, "SourceFile": path}
+	if rotation != "" {
+		e["Rotation"] = rotation
+	}
+	return e
+}
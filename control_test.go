@@ -0,0 +1,153 @@
+package mysqllog
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlEvents(t *testing.T) {
+	content, err := ioutil.ReadFile("./_test/restarts.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := parseAll(&Parser{}, string(content))
+	events := parseAll(NewParser(WithControlEvents(), WithOffsets()), string(content))
+
+	var kinds []string
+	var queries []LogEvent
+	for _, e := range events {
+		if IsControlEvent(e) {
+			kinds = append(kinds, e[ControlTypeKey].(string))
+			continue
+		}
+		kinds = append(kinds, e["Statement"].(string))
+		delete(e, "Offset")
+		delete(e, "EventID")
+		queries = append(queries, e)
+	}
+	expected := []string{"SELECT 1;", "SELECT COUNT(*) FROM orders;", ServerRestart, "SELECT 1;", ServerRestart, "SELECT 2;"}
+	if strings.Join(kinds, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected %q, got %q", expected, kinds)
+	}
+	if !reflect.DeepEqual(queries, plain) {
+		t.Errorf("expected the same events as without control events, got\n%v\nwant\n%v", queries, plain)
+	}
+	restart := events[2]
+	wantRestart := LogEvent{
+		ControlTypeKey:  ServerRestart,
+		"ServerVersion": "8.0.34",
+		"ServerFlavor":  "MySQL",
+		"ServerBinary":  "/usr/sbin/mysqld",
+		"ServerPort":    int64(3306),
+		"ServerSocket":  "/var/run/mysqld/mysqld.sock",
+		"Offset":        int64(strings.Index(string(content), "/usr/sbin/mysqld")),
+	}
+	if !reflect.DeepEqual(restart, wantRestart) {
+		t.Errorf("expected %v, got %v", wantRestart, restart)
+	}
+	if mariadb := events[4]; mariadb["ServerFlavor"] != "MariaDB" || mariadb["ServerVersion"] != "10.6.12-MariaDB" || mariadb["ServerPort"] != nil {
+		t.Errorf("unexpected MariaDB restart %v", mariadb)
+	}
+
+	// Aggregators ignore them.
+	with, without := NewAggregator(), NewAggregator()
+	for _, e := range events {
+		with.Add(e)
+	}
+	for _, e := range plain {
+		without.Add(e)
+	}
+	if with.Summary().Events != without.Summary().Events || len(with.Results()) != len(without.Results()) {
+		t.Errorf("expected the aggregator to ignore control events")
+	}
+	recorded := &recordingSink{}
+	sink := DropControlEvents(recorded)
+	for _, e := range events {
+		sink.Write(e)
+	}
+	if len(recorded.events) != len(plain) {
+		t.Errorf("expected DropControlEvents to write %d events, got %d", len(plain), len(recorded.events))
+	}
+
+	// A banner ends the event before it, and one at the end of the
+	// input is flushed.
+	events = parseAll(NewParser(WithControlEvents()), "# Query_time: 1\nSELECT 'a\n"+
+		"/usr/sbin/mysqld, Version: 8.0.33 (MySQL Community Server - GPL). started with:\n"+
+		"/usr/sbin/mysqld, Version: 8.0.34 (MySQL Community Server - GPL). started with:\n")
+	if len(events) != 3 || events[0]["Statement"] != "SELECT 'a" || events[0]["IncompleteReason"] != string(IncompleteResync) ||
+		events[1]["ServerVersion"] != "8.0.33" || events[2]["ServerVersion"] != "8.0.34" || events[1]["ServerPort"] != nil {
+		t.Errorf("unexpected events %v", events)
+	}
+}
+
+func TestParseFilesLogRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var paths []string
+	for i, name := range []string{"slow.log.1", "slow.log"} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(slowEvent(i)+slowEvent(i+10)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	var kinds []string
+	err = ParseFiles(paths, func(e LogEvent) {
+		if IsControlEvent(e) {
+			kinds = append(kinds, e[ControlTypeKey].(string)+" "+filepath.Base(e["SourceFile"].(string)))
+			return
+		}
+		kinds = append(kinds, e["Statement"].(string))
+	}, WithParser(NewParser(WithControlEvents())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "SELECT 0;,SELECT 10;,LogRotation slow.log,SELECT 1;,SELECT 11;"
+	if strings.Join(kinds, ",") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(kinds, ","))
+	}
+}
+
+func TestTailFileLogRotation(t *testing.T) {
+	for _, rotation := range []string{"rename", "truncate"} {
+		dir, err := ioutil.TempDir("", "mysqllog")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "slow.log")
+		appendFile(t, path, slowEvent(1)+slowEvent(2))
+
+		run := startFollow(func(ctx context.Context, fn func(LogEvent)) error {
+			return TailFile(ctx, path, func(e LogEvent) {
+				if IsControlEvent(e) {
+					e = LogEvent{"Statement": e[ControlTypeKey].(string) + " " + e["Rotation"].(string)}
+				}
+				fn(e)
+			}, append([]TailOption{WithTailParserOptions(WithControlEvents())}, fastPoll...)...)
+		})
+		run.expect(t, "SELECT 1;")
+		if rotation == "rename" {
+			if err := os.Rename(path, path+".1"); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err := os.Truncate(path, 0); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(60 * time.Millisecond)
+		}
+		appendFile(t, path, slowEvent(3)+slowEvent(4))
+		run.expect(t, "SELECT 2;", LogRotation+" "+rotation, "SELECT 3;")
+		run.stop(t)
+	}
+}
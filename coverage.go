@@ -0,0 +1,109 @@
+package mysqllog
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WithPresenceStats counts how many events have each key in
+// Stats.Presence, so an attribute that stops being parsed, as after a
+// server upgrade changes the log format, shows in a CoverageReport.
+func WithPresenceStats() Option {
+	return func(p *Parser) {
+		p.presence = true
+	}
+}
+
+// DefaultCoverage is the presence rate expected of the attributes every
+// event should have, allowing for the odd event missing one.
+var DefaultCoverage = map[string]float64{
+	"Timestamp":  0.99,
+	"User":       0.99,
+	"Host":       0.99,
+	"Query_time": 0.99,
+	"Statement":  0.99,
+}
+
+// AttributeCoverage is how many events had an attribute.
+type AttributeCoverage struct {
+	Key    string
+	Events int64
+	// Rate is Events over all the events, and Expected the rate
+	// expected, or 0 if none is.
+	Rate     float64
+	Expected float64
+	// Low is set if Rate is below Expected.
+	Low bool
+}
+
+// CoverageReport returns the coverage of each key in s.Presence and
+// expected, sorted by key, flagging keys whose presence rate is below
+// that in expected, or DefaultCoverage if expected is nil. It needs
+// WithPresenceStats.
+func (s Stats) CoverageReport(expected map[string]float64) []AttributeCoverage {
+	return coverageReport(s.Events, s.Presence, expected)
+}
+
+func coverageReport(events int64, presence map[string]int64, expected map[string]float64) []AttributeCoverage {
+	if expected == nil {
+		expected = DefaultCoverage
+	}
+	keys := make([]string, 0, len(presence)+len(expected))
+	for key := range presence {
+		keys = append(keys, key)
+	}
+	for key := range expected {
+		if _, ok := presence[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	report := make([]AttributeCoverage, len(keys))
+	for i, key := range keys {
+		c := AttributeCoverage{Key: key, Events: presence[key], Expected: expected[key]}
+		if events > 0 {
+			c.Rate = float64(c.Events) / float64(events)
+		}
+		c.Low = c.Rate < c.Expected
+		report[i] = c
+	}
+	return report
+}
+
+// WriteCoverage writes report as a table, with "low" after the keys
+// below their expected rate.
+func WriteCoverage(w io.Writer, report []AttributeCoverage) error {
+	width := len("attribute")
+	for _, c := range report {
+		if len(c.Key) > width {
+			width = len(c.Key)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-*s %8s %7s %8s\n", width, "attribute", "events", "rate", "expected")
+	for _, c := range report {
+		expected := "-"
+		if c.Expected > 0 {
+			expected = fmt.Sprintf("%.1f%%", c.Expected*100)
+		}
+		fmt.Fprintf(&b, "%-*s %8d %6.1f%% %8s", width, c.Key, c.Events, c.Rate*100, expected)
+		if c.Low {
+			b.WriteString(" low")
+		}
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// countPresence counts the keys of e for WithPresenceStats.
+func (p *Parser) countPresence(e LogEvent) {
+	if p.stats.Presence == nil {
+		p.stats.Presence = map[string]int64{}
+	}
+	for key := range e {
+		p.stats.Presence[key]++
+	}
+}
@@ -0,0 +1,63 @@
+package mysqllog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestCoverageReport(t *testing.T) {
+	b, err := ioutil.ReadFile("_test/rds.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]float64{"Rows_examined": 1, "Query_time": 1, "Bytes_sent": 0.5}
+	coverage := func(content string) (AttributeCoverage, []AttributeCoverage) {
+		p := NewParser(WithPresenceStats())
+		parseAll(p, content)
+		report := p.Stats().CoverageReport(expected)
+		for _, c := range report {
+			if c.Key == "Rows_examined" {
+				return c, report
+			}
+		}
+		t.Fatalf("no Rows_examined in %v", report)
+		return AttributeCoverage{}, nil
+	}
+
+	c, report := coverage(string(b))
+	if c.Rate != 1 || c.Low {
+		t.Errorf("unexpected coverage %+v", c)
+	}
+	for _, c := range report {
+		switch {
+		case c.Key == "Bytes_sent" && (c.Events != 0 || !c.Low):
+			t.Errorf("expected Bytes_sent to be missing, got %+v", c)
+		case c.Key == "Query_time" && c.Low:
+			t.Errorf("unexpected coverage %+v", c)
+		}
+	}
+
+	// A format change breaks Rows_examined in some events.
+	corrupted := strings.Replace(string(b), "Rows_examined: ", "Rows_examined ", 23)
+	c, report = coverage(corrupted)
+	if c.Events != 208 || !c.Low || c.Rate >= 0.95 {
+		t.Errorf("expected the presence of Rows_examined to drop, got %+v", c)
+	}
+	var buf bytes.Buffer
+	if err := WriteCoverage(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\nRows_examined      208   90.0%   100.0% low\n") {
+		t.Errorf("expected Rows_examined to be low in\n%s", buf.String())
+	}
+
+	verify, err := Verify(strings.NewReader(corrupted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verify.CheckCoverage(expected); !verify.HasWarnings() {
+		t.Errorf("expected a warning for Rows_examined, got %+v", verify.Coverage)
+	}
+}
@@ -0,0 +1,59 @@
+package mysqllog
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// CSVWriter is a Sink writing events as CSV rows, after a header row of
+// field names. Fields missing from an event are left empty.
+type CSVWriter struct {
+	w      *csv.Writer
+	fields []Field
+	header bool
+}
+
+// NewCSVWriter returns a CSVWriter writing fields to w in order, or
+// DefaultFields if none are given. Use KeyStyle.Fields to name the
+// columns in a key style.
+func NewCSVWriter(w io.Writer, fields ...Field) *CSVWriter {
+	if len(fields) == 0 {
+		fields = DefaultFields
+	}
+	return &CSVWriter{w: csv.NewWriter(w), fields: fields}
+}
+
+// Write writes e as a row, writing the header row first if it's the
+// first event.
+func (c *CSVWriter) Write(e LogEvent) error {
+	if !c.header {
+		c.writeHeader()
+	}
+	flat := e.Flatten(FlattenOptions{})
+	row := make([]string, len(c.fields))
+	for i, f := range c.fields {
+		row[i], _ = FieldValue(flat, f)
+	}
+	c.w.Write(row)
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// Close implements Sink, writing the header row if no event was
+// written. It doesn't close the underlying writer.
+func (c *CSVWriter) Close() error {
+	if !c.header {
+		c.writeHeader()
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *CSVWriter) writeHeader() {
+	names := make([]string, len(c.fields))
+	for i, f := range c.fields {
+		names[i] = f.Name
+	}
+	c.w.Write(names)
+	c.header = true
+}
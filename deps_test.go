@@ -0,0 +1,35 @@
+package mysqllog
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestStandardLibraryOnly checks that the package only depends on the
+// standard library on every platform, so it can be embedded anywhere;
+// integrations with other dependencies go in the packages of sinks.
+func TestStandardLibraryOnly(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip(err)
+	}
+	list := func(goos, format string, packages ...string) []string {
+		cmd := exec.Command("go", append([]string{"list", "-f", format}, packages...)...)
+		cmd.Env = append(os.Environ(), "GOOS="+goos)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Skipf("go list: %v", err)
+		}
+		return strings.Fields(string(out))
+	}
+	for _, goos := range []string{"linux", "darwin", "windows"} {
+		deps := list(goos, "{{join .Deps \" \"}}", ".")
+		if len(deps) == 0 {
+			continue
+		}
+		for _, dep := range list(goos, "{{if not .Standard}}{{.ImportPath}}{{end}}", deps...) {
+			t.Errorf("%s: unexpected dependency %s", goos, dep)
+		}
+	}
+}
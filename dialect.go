@@ -0,0 +1,300 @@
+package mysqllog
+
+import (
+	"strings"
+	"time"
+)
+
+// Dialect is a slow query log format variant.
+type Dialect int
+
+const (
+	// MySQL is the format written by MySQL and compatible servers.
+	MySQL Dialect = iota
+	// TiDB is the format written by TiDB.
+	TiDB
+	// MariaDB is the format written by MariaDB. It's parsed the same
+	// as MySQL.
+	MariaDB
+	// Percona is the format written by Percona Server. It's parsed the
+	// same as MySQL.
+	Percona
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case MySQL:
+		return "MySQL"
+	case TiDB:
+		return "TiDB"
+	case MariaDB:
+		return "MariaDB"
+	case Percona:
+		return "Percona"
+	}
+	return "unknown"
+}
+
+// WithDialect sets the log format to parse instead of detecting it.
+//
+// In TiDB mode, "DB" is stored as "Database", "Timestamp" comes from the
+// "# Time:" line since TiDB doesn't write SET timestamp, and a line
+// containing only ";" ends the statement. Values such as "Plan" are
+// kept as raw strings.
+func WithDialect(d Dialect) Option {
+	return func(p *Parser) {
+		p.dialect = d
+		p.dialectSet = true
+	}
+}
+
+// Dialect returns the log format p parses: the one set by WithDialect,
+// or else the one detected so far, starting as MySQL.
+//
+// Detection looks at the server startup banner, the format of the
+// "# Time:" line and attributes only some servers write, such as TiDB's
+// Txn_start_ts or MariaDB's QC_hit. It goes on through the log, and
+// stronger evidence revises what was detected from weaker evidence: a
+// banner over attributes, and attributes over the "# Time:" line. The
+// dialect detected for an event applies to it.
+func (p *Parser) Dialect() Dialect {
+	return p.dialect
+}
+
+// How strong the evidence for a detected dialect is.
+const (
+	evidenceNone = iota
+	evidenceTime
+	evidenceAttribute
+	evidenceBanner
+)
+
+// dialectAttributes are attributes written by a single dialect, other
+// than TiDB's, which are the ones in tidbAttributeTypes only.
+var dialectAttributes = map[string]Dialect{
+	"Errno":                   MySQL,
+	"Bytes_received":          MySQL,
+	"Read_first":              MySQL,
+	"Read_key":                MySQL,
+	"Read_rnd_next":           MySQL,
+	"Sort_scan_count":         MySQL,
+	"Created_tmp_tables":      MySQL,
+	"Created_tmp_disk_tables": MySQL,
+	"QC_hit":                  MariaDB,
+	"Priority_queue":          MariaDB,
+	"QC_Hit":                  Percona,
+	"Last_errno":              Percona,
+	"InnoDB_trx_id":           Percona,
+	"InnoDB_IO_r_ops":         Percona,
+	"InnoDB_rec_lock_wait":    Percona,
+	"InnoDB_queue_wait":       Percona,
+	"InnoDB_pages_distinct":   Percona,
+	"Log_slow_rate_type":      Percona,
+	"Log_slow_rate_limit":     Percona,
+}
+
+// detectDialect changes the dialect to d if evidence is stronger than
+// the evidence for the current one and the dialect wasn't set with
+// WithDialect.
+func (p *Parser) detectDialect(d Dialect, evidence int) {
+	if p.dialectSet || evidence <= p.evidence {
+		return
+	}
+	if p.logger != nil && d != p.dialect {
+		p.logger.Printf("mysqllog: line %d: detected dialect %s", p.line, d)
+	}
+	p.dialect = d
+	p.evidence = evidence
+}
+
+// detectBanner detects the dialect from the server version in line if
+// it's a startup banner, such as "/usr/sbin/mysqld, Version: 10.6.12-
+// MariaDB-log (MariaDB Server). started with:".
+func (p *Parser) detectBanner(line string) {
+	if p.dialectSet || !isBanner(line) {
+		return
+	}
+	p.detectDialect(bannerDialect(line), evidenceBanner)
+}
+
+// detectHeader detects the dialect from the header lines of an event.
+// Once attributes have been seen, only a banner is stronger, so headers
+// aren't looked at anymore.
+func (p *Parser) detectHeader(lines []string) {
+	if p.dialectSet || p.evidence >= evidenceAttribute {
+		return
+	}
+	if d, evidence := headerDialect(lines); evidence > evidenceNone {
+		p.detectDialect(d, evidence)
+	}
+}
+
+// headerDialect returns the dialect the header lines at the start of
+// lines tell, and how strong the evidence is.
+func headerDialect(lines []string) (d Dialect, evidence int) {
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		if line[0] != '#' || isAdminCommand(line) {
+			return d, evidence
+		}
+		if strings.HasPrefix(line, "# Time: ") {
+			value := strings.TrimSpace(line[len("# Time: "):])
+			if _, err := parseTimeLine(value); err == nil {
+				// TiDB writes nanoseconds, MySQL microseconds.
+				if fractionDigits(value) == 9 && evidence < evidenceTime {
+					d, evidence = TiDB, evidenceTime
+				}
+			} else if _, err := time.Parse(legacyTimeLayout, strings.Join(strings.Fields(value), " ")); err == nil && evidence < evidenceTime {
+				// MySQL 5.6 and older write it too, but MariaDB is the
+				// one still doing so.
+				d, evidence = MariaDB, evidenceTime
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "# User@Host") {
+			continue
+		}
+		for _, field := range strings.Fields(line[1:]) {
+			if len(field) < 2 || field[len(field)-1] != ':' {
+				continue
+			}
+			key := field[:len(field)-1]
+			if d, ok := dialectAttributes[key]; ok {
+				return d, evidenceAttribute
+			}
+			if _, ok := tidbAttributeTypes[key]; ok {
+				if _, ok := attributeTypes[key]; !ok {
+					return TiDB, evidenceAttribute
+				}
+			}
+		}
+	}
+	return d, evidence
+}
+
+var tidbAttributeTypes = map[string]AttributeKind{
+	"Txn_start_ts":              AttributeInt,
+	"Conn_ID":                   AttributeInt,
+	"Session_alias":             AttributeString,
+	"Exec_retry_count":          AttributeInt,
+	"Exec_retry_time":           AttributeFloat,
+	"Query_time":                AttributeFloat,
+	"Parse_time":                AttributeFloat,
+	"Compile_time":              AttributeFloat,
+	"Rewrite_time":              AttributeFloat,
+	"Preproc_subqueries":        AttributeInt,
+	"Preproc_subqueries_time":   AttributeFloat,
+	"Optimize_time":             AttributeFloat,
+	"Wait_TS":                   AttributeFloat,
+	"Cop_time":                  AttributeFloat,
+	"Process_time":              AttributeFloat,
+	"Wait_time":                 AttributeFloat,
+	"Backoff_time":              AttributeFloat,
+	"Backoff_types":             AttributeString,
+	"LockKeys_time":             AttributeFloat,
+	"Request_count":             AttributeInt,
+	"Total_keys":                AttributeInt,
+	"Process_keys":              AttributeInt,
+	"Prewrite_time":             AttributeFloat,
+	"Wait_prewrite_binlog_time": AttributeFloat,
+	"Commit_time":               AttributeFloat,
+	"Get_commit_ts_time":        AttributeFloat,
+	"Commit_backoff_time":       AttributeFloat,
+	"Resolve_lock_time":         AttributeFloat,
+	"Local_latch_wait_time":     AttributeFloat,
+	"Write_keys":                AttributeInt,
+	"Write_size":                AttributeInt,
+	"Prewrite_region":           AttributeInt,
+	"Txn_retry":                 AttributeInt,
+	"DB":                        AttributeString,
+	"Index_names":               AttributeString,
+	"Is_internal":               AttributeBool,
+	"Digest":                    AttributeString,
+	"Stats":                     AttributeString,
+	"Num_cop_tasks":             AttributeInt,
+	"Cop_proc_avg":              AttributeFloat,
+	"Cop_proc_p90":              AttributeFloat,
+	"Cop_proc_max":              AttributeFloat,
+	"Cop_proc_addr":             AttributeString,
+	"Cop_wait_avg":              AttributeFloat,
+	"Cop_wait_p90":              AttributeFloat,
+	"Cop_wait_max":              AttributeFloat,
+	"Cop_wait_addr":             AttributeString,
+	"Mem_max":                   AttributeInt,
+	"Disk_max":                  AttributeInt,
+	"Prepared":                  AttributeBool,
+	"Plan_from_cache":           AttributeBool,
+	"Plan_from_binding":         AttributeBool,
+	"Has_more_results":          AttributeBool,
+	"Succ":                      AttributeBool,
+	"IsExplicitTxn":             AttributeBool,
+	"IsSyncStatsFailed":         AttributeBool,
+	"Result_rows":               AttributeInt,
+	"Warnings":                  AttributeString,
+	"Resource_group":            AttributeString,
+	"Request_unit_read":         AttributeFloat,
+	"Request_unit_write":        AttributeFloat,
+	"Time_queued_by_rc":         AttributeFloat,
+	"KV_total":                  AttributeFloat,
+	"PD_total":                  AttributeFloat,
+	"Backoff_total":             AttributeFloat,
+	"Write_sql_response_total":  AttributeFloat,
+	"Plan":                      AttributeString,
+	"Plan_digest":               AttributeString,
+	"Binary_plan":               AttributeString,
+	"Prev_stmt":                 AttributeString,
+}
+
+// tidbRestOfLine lists TiDB attributes whose value runs to the end of
+// the line and may contain spaces.
+var tidbRestOfLine = map[string]bool{
+	"Plan":        true,
+	"Binary_plan": true,
+	"Index_names": true,
+	"Stats":       true,
+	"Prev_stmt":   true,
+	"Warnings":    true,
+}
+
+// parseTiDBAttributes splits a TiDB header line into key/value pairs.
+// Pairs are separated by spaces, optionally with "#" between them.
+func parseTiDBAttributes(line string) [][2]string {
+	var pairs [][2]string
+	fields := strings.Fields(strings.TrimPrefix(line, "#"))
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if len(field) < 2 || !strings.HasSuffix(field, ":") {
+			continue
+		}
+		key := field[:len(field)-1]
+		if tidbRestOfLine[key] {
+			idx := strings.Index(line, " "+field) + 1
+			if idx == 0 {
+				idx = strings.Index(line, field)
+			}
+			pairs = append(pairs, [2]string{key, strings.TrimSpace(line[idx+len(field):])})
+			break
+		}
+		if i+1 < len(fields) {
+			i++
+			pairs = append(pairs, [2]string{key, fields[i]})
+		}
+	}
+	return pairs
+}
+
+// fractionDigits returns the number of digits after the decimal point
+// of the seconds of an RFC 3339 timestamp.
+func fractionDigits(value string) int {
+	dot := strings.IndexByte(value, '.')
+	if dot < 0 {
+		return 0
+	}
+	n := 0
+	for dot+1+n < len(value) && isDigit(value[dot+1+n]) {
+		n++
+	}
+	return n
+}
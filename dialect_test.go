@@ -0,0 +1,218 @@
+package mysqllog
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTiDBFile(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/tidb.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := parseAll(NewParser(WithDialect(TiDB), WithUnixTimestamps()), string(b))
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+
+	first := events[0]
+	expected := LogEvent{
+		"User":                    "root",
+		"Host":                    "10.0.1.12",
+		"IP":                      "10.0.1.12",
+		"Database":                "test",
+		"Timestamp":               float64(time.Date(2023, 8, 1, 2, 36, 57, 0, time.UTC).Unix()) + 0.123,
+		"Txn_start_ts":            int64(443409868529532929),
+		"Conn_ID":                 int64(3086),
+		"Query_time":              1.527627037,
+		"Parse_time":              0.000054933,
+		"Compile_time":            0.000129729,
+		"Rewrite_time":            0.000000003,
+		"Preproc_subqueries":      int64(2),
+		"Preproc_subqueries_time": 0.000000002,
+		"Optimize_time":           0.000081223,
+		"Wait_TS":                 0.000004195,
+		"Process_time":            0.07,
+		"Request_count":           int64(1),
+		"Total_keys":              int64(131073),
+		"Process_keys":            int64(131072),
+		"Prewrite_time":           0.335415029,
+		"Commit_time":             0.032175429,
+		"Get_commit_ts_time":      0.000177098,
+		"Local_latch_wait_time":   0.106869448,
+		"Write_keys":              int64(131072),
+		"Write_size":              int64(3538944),
+		"Prewrite_region":         int64(1),
+		"Is_internal":             false,
+		"Digest":                  "50a2e32d2abbd6c1764b1b7f2058d428ef2712b029282b776beb9506a365c0f1",
+		"Stats":                   "t:pseudo",
+		"Num_cop_tasks":           int64(1),
+		"Cop_proc_avg":            0.07,
+		"Cop_proc_p90":            0.07,
+		"Cop_proc_max":            0.07,
+		"Cop_proc_addr":           "172.16.5.87:20171",
+		"Cop_wait_avg":            0.0,
+		"Cop_wait_p90":            0.0,
+		"Cop_wait_max":            0.0,
+		"Cop_wait_addr":           "172.16.5.87:20171",
+		"Mem_max":                 int64(525211),
+		"Disk_max":                int64(65536),
+		"Prepared":                false,
+		"Plan_from_cache":         false,
+		"Plan_from_binding":       false,
+		"Has_more_results":        false,
+		"Succ":                    true,
+		"Plan":                    "tidb_decode_plan('ZJAwCTMyXzcJMAkyMAlkYXRhOlRhYmxlU2Nhbl82CjEJMTBfNgkxAR0AdAEY1Dp0LCByYW5nZTpbLWluZiwraW5mXSwga2VlcCBvcmRlcjpmYWxzZSwgc3RhdHM6cHNldWRvCg==')",
+		"Plan_digest":             "e5f5c1ff0a3e7f10b3cbbf5a5d8d6b46dc1d4a5e2c2b8f8bc4cbe9a6b43a5c9e",
+		"Statement":               "insert into t select * from t;",
+	}
+	if !first.Equal(expected) {
+		t.Errorf("unexpected first event: %q", Diff(expected, first))
+	}
+
+	second := events[1]
+	if second["Query_time"] != 0.412830861 || second["Parse_time"] != 0.000031087 || second["Compile_time"] != 0.000402134 {
+		t.Errorf("expected #-separated attributes to be parsed, got %v", second)
+	}
+	if second["Index_names"] != "[orders:idx_customer_created]" {
+		t.Errorf("unexpected Index_names %v", second["Index_names"])
+	}
+	if second["Statement"] != "SELECT id, total FROM orders WHERE customer_id = 42 AND created_at > '2023-07-01'\nORDER BY created_at DESC LIMIT 20;" {
+		t.Errorf("unexpected statement %q", second["Statement"])
+	}
+	if _, ok := second["Database"]; ok {
+		t.Errorf("expected no database, got %v", second["Database"])
+	}
+
+	third := events[2]
+	if third["Database"] != "mysql" || third["Succ"] != false {
+		t.Errorf("unexpected third event %v", third)
+	}
+	if third["Statement"] != "analyze table mysql.stats_histograms" {
+		t.Errorf("expected lone semicolon line to be dropped, got %q", third["Statement"])
+	}
+}
+
+func TestParseTiDBAttributes(t *testing.T) {
+	pairs := parseTiDBAttributes("# Query_time: 1.5 # Parse_time: 0.1 Stats: a:1 b:2")
+	expected := [][2]string{{"Query_time", "1.5"}, {"Parse_time", "0.1"}, {"Stats", "a:1 b:2"}}
+	if len(pairs) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, pairs)
+	}
+	for i := range pairs {
+		if pairs[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], pairs[i])
+		}
+	}
+}
+
+func TestDetectDialect(t *testing.T) {
+	type TestCase struct {
+		file     string
+		expected Dialect
+	}
+	for _, c := range []TestCase{
+		{"canonical_mysql8.txt", MySQL},
+		{"canonical_mariadb.txt", MariaDB},
+		{"canonical_percona.txt", Percona},
+		{"canonical_tidb.txt", TiDB},
+		{"tidb.txt", TiDB},
+		{"rds.txt", MySQL},
+	} {
+		b, err := ioutil.ReadFile("./_test/" + c.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := &Parser{}
+		events := parseAll(p, string(b))
+		if p.Dialect() != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.file, c.expected, p.Dialect())
+		}
+		expected := parseAll(NewParser(WithDialect(c.expected)), string(b))
+		if !reflect.DeepEqual(events, expected) {
+			t.Errorf("%s: expected the events parsed as %v, got %v", c.file, c.expected, events)
+		}
+	}
+}
+
+func TestDetectDialectRevised(t *testing.T) {
+	type TestCase struct {
+		lines    []string
+		expected []Dialect
+	}
+	for _, c := range []TestCase{
+		{
+			// The legacy # Time: line is weak evidence for MariaDB,
+			// revised by Percona's attributes and then by a banner.
+			[]string{
+				"# Time: 230801 10:36:57\n",
+				"# Query_time: 1  Lock_time: 0\n",
+				"SELECT 1;\n",
+				"# Thread_id: 1  Schema: shop  Last_errno: 0  Killed: 0\n",
+				"# Query_time: 1  Lock_time: 0\n",
+				"SELECT 2;\n",
+				"\n",
+				"/usr/sbin/mysqld, Version: 8.0.32 (MySQL Community Server - GPL). started with:\n",
+				"# QC_hit: No\n",
+				"# Query_time: 1  Lock_time: 0\n",
+				"SELECT 3;\n",
+			},
+			[]Dialect{MySQL, MariaDB, Percona, MySQL},
+		},
+		{
+			// Attributes aren't revised by weaker evidence, nor by
+			// evidence as strong.
+			[]string{
+				"# Query_time: 1  Lock_time: 0  QC_hit: No\n",
+				"SELECT 1;\n",
+				"# Time: 2023-08-01T10:36:57.123456789+08:00\n",
+				"# Query_time: 1  Lock_time: 0  InnoDB_trx_id: 1\n",
+				"SELECT 2;\n",
+			},
+			[]Dialect{MySQL, MariaDB, MariaDB},
+		},
+	} {
+		p := &Parser{}
+		detected := []Dialect{p.Dialect()}
+		for _, line := range c.lines {
+			if e := p.ConsumeLine(line); e != nil {
+				detected = append(detected, p.Dialect())
+			}
+		}
+		p.Flush()
+		detected = append(detected, p.Dialect())
+		if !reflect.DeepEqual(detected, c.expected) {
+			t.Errorf("expected %v, got %v", c.expected, detected)
+		}
+	}
+}
+
+func TestDetectDialectTime(t *testing.T) {
+	p := &Parser{}
+	parseAll(p, "# Time: 2023-08-01T10:36:57.123456789+08:00\n# Query_time: 1.5\nSELECT 1;\n")
+	if p.Dialect() != TiDB {
+		t.Errorf("expected TiDB from nanoseconds, got %v", p.Dialect())
+	}
+	p = &Parser{}
+	parseAll(p, "# Time: 2023-08-01T10:36:57.123456+08:00\n# Query_time: 1.5\nSELECT 1;\n")
+	if p.Dialect() != MySQL {
+		t.Errorf("expected MySQL from microseconds, got %v", p.Dialect())
+	}
+}
+
+func TestWithDialectOverridesDetection(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/tidb.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewParser(WithDialect(MySQL))
+	events := parseAll(p, string(b))
+	if p.Dialect() != MySQL {
+		t.Errorf("expected MySQL, got %v", p.Dialect())
+	}
+	if _, ok := events[0]["Txn_start_ts"].(float64); !ok {
+		t.Errorf("expected Txn_start_ts to be parsed as an unknown MySQL attribute, got %v", events[0])
+	}
+}
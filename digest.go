@@ -0,0 +1,292 @@
+package mysqllog
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DigestVersion is the version of the digests written by
+// Aggregator.MarshalDigest. MergeDigest reads digests up to it.
+const DigestVersion = 1
+
+// digestJSON is the document of a digest. Keys are short, since digests
+// are shipped over constrained links.
+type digestJSON struct {
+	Version   int                       `json:"v"`
+	Count     int64                     `json:"n"`
+	TotalTime float64                   `json:"t"`
+	Evictions int64                     `json:"evicted,omitempty"`
+	Summary   digestSummary             `json:"summary"`
+	Queries   []digestStats             `json:"queries"`
+	Other     *digestStats              `json:"other,omitempty"`
+	Rollups   map[string][]digestRollup `json:"rollups,omitempty"`
+}
+
+type digestSummary struct {
+	Events       int64     `json:"n"`
+	FirstEvent   time.Time `json:"first"`
+	LastEvent    time.Time `json:"last"`
+	TotalTime    float64   `json:"t"`
+	MaxTime      float64   `json:"max"`
+	RowsExamined int64     `json:"examined"`
+	RowsSent     int64     `json:"sent"`
+	Bytes        int64     `json:"bytes,omitempty"`
+	Users        []string  `json:"users,omitempty"`
+	Databases    []string  `json:"databases,omitempty"`
+	Fingerprints []string  `json:"fingerprints,omitempty"`
+}
+
+type digestStats struct {
+	Fingerprint     string                        `json:"f"`
+	Count           int64                         `json:"n"`
+	Logged          int64                         `json:"logged"`
+	TotalTime       float64                       `json:"t"`
+	MinTime         float64                       `json:"min"`
+	MaxTime         float64                       `json:"max"`
+	LockTime        float64                       `json:"lock"`
+	RowsSent        int64                         `json:"sent"`
+	RowsExamined    int64                         `json:"examined"`
+	FullScans       int64                         `json:"full_scans,omitempty"`
+	UnboundedWrites int64                         `json:"unbounded,omitempty"`
+	StatementBytes  int64                         `json:"bytes"`
+	MaxValuesTuples int64                         `json:"tuples,omitempty"`
+	Histogram       [HistogramBuckets]int64       `json:"h"`
+	FirstSeen       time.Time                     `json:"first"`
+	LastSeen        time.Time                     `json:"last"`
+	Attributes      map[string]digestDistribution `json:"attrs,omitempty"`
+	Sample          LogEvent                      `json:"sample,omitempty"`
+}
+
+type digestDistribution struct {
+	Count  int64         `json:"n"`
+	Sum    float64       `json:"sum"`
+	Min    float64       `json:"min"`
+	Max    float64       `json:"max"`
+	Mean   float64       `json:"mean"`
+	M2     float64       `json:"m2"`
+	Sketch *digestSketch `json:"sketch,omitempty"`
+}
+
+type digestSketch struct {
+	Accuracy float64       `json:"accuracy"`
+	Zero     int64         `json:"zero,omitempty"`
+	Count    int64         `json:"n"`
+	Min      float64       `json:"min"`
+	Max      float64       `json:"max"`
+	Bins     map[int]int64 `json:"bins"`
+}
+
+type digestRollup struct {
+	Value        string  `json:"value"`
+	Count        int64   `json:"n"`
+	TotalTime    float64 `json:"t"`
+	RowsExamined int64   `json:"examined"`
+}
+
+// MarshalDigest returns a compact JSON document holding the stats of
+// every fingerprint, the rollups and the Summary of a, which
+// MergeDigest adds to another Aggregator as Merge would, such as to
+// combine the digests of many agents into a global view. Lock minutes
+// and table stats aren't included. The document is versioned with
+// DigestVersion.
+func (a *Aggregator) MarshalDigest() ([]byte, error) {
+	d := digestJSON{
+		Version:   DigestVersion,
+		Count:     a.count,
+		TotalTime: a.totalTime,
+		Evictions: a.evictions,
+		Summary: digestSummary{
+			Events:       a.summary.Events,
+			FirstEvent:   a.summary.FirstEvent,
+			LastEvent:    a.summary.LastEvent,
+			TotalTime:    a.summary.TotalTime,
+			MaxTime:      a.summary.MaxTime,
+			RowsExamined: a.summary.RowsExamined,
+			RowsSent:     a.summary.RowsSent,
+			Bytes:        a.summary.Bytes,
+			Users:        sortedSet(a.summary.users),
+			Databases:    sortedSet(a.summary.databases),
+			Fingerprints: sortedSet(a.summary.fingerprints),
+		},
+		Queries: make([]digestStats, 0, len(a.stats)),
+	}
+	for _, s := range a.stats {
+		d.Queries = append(d.Queries, newDigestStats(s))
+	}
+	sort.Slice(d.Queries, func(i, j int) bool { return d.Queries[i].Fingerprint < d.Queries[j].Fingerprint })
+	if a.other != nil {
+		other := newDigestStats(a.other)
+		d.Other = &other
+	}
+	for key, values := range a.rollups {
+		if d.Rollups == nil {
+			d.Rollups = map[string][]digestRollup{}
+		}
+		rollups := make([]digestRollup, 0, len(values))
+		for _, r := range values {
+			rollups = append(rollups, digestRollup{r.Value, r.Count, r.TotalTime, r.RowsExamined})
+		}
+		sort.Slice(rollups, func(i, j int) bool { return rollups[i].Value < rollups[j].Value })
+		d.Rollups[key] = rollups
+	}
+	return json.Marshal(d)
+}
+
+// MergeDigest adds the digest data, from MarshalDigest, to a. Rollups
+// of attributes that aren't dimensions of a are ignored, and quantiles
+// need the same accuracy, as with Merge. It returns an error for
+// digests of a later DigestVersion.
+func (a *Aggregator) MergeDigest(data []byte) error {
+	var d digestJSON
+	if err := json.Unmarshal(data, &d); err != nil {
+		return fmt.Errorf("mysqllog: bad digest: %v", err)
+	}
+	if d.Version < 1 || d.Version > DigestVersion {
+		return fmt.Errorf("mysqllog: unsupported digest version %d", d.Version)
+	}
+	other := &Aggregator{
+		stats:     make(map[string]*QueryStats, len(d.Queries)),
+		rollups:   make(map[string]map[string]*Rollup, len(d.Rollups)),
+		count:     d.Count,
+		totalTime: d.TotalTime,
+		evictions: d.Evictions,
+		summary: Summary{
+			Events:       d.Summary.Events,
+			FirstEvent:   d.Summary.FirstEvent,
+			LastEvent:    d.Summary.LastEvent,
+			TotalTime:    d.Summary.TotalTime,
+			MaxTime:      d.Summary.MaxTime,
+			RowsExamined: d.Summary.RowsExamined,
+			RowsSent:     d.Summary.RowsSent,
+			Bytes:        d.Summary.Bytes,
+		},
+	}
+	for _, set := range []struct {
+		set    *map[string]struct{}
+		values []string
+	}{
+		{&other.summary.users, d.Summary.Users},
+		{&other.summary.databases, d.Summary.Databases},
+		{&other.summary.fingerprints, d.Summary.Fingerprints},
+	} {
+		for _, value := range set.values {
+			addDistinct(set.set, value)
+		}
+	}
+	for _, q := range d.Queries {
+		other.stats[q.Fingerprint] = q.stats()
+	}
+	if d.Other != nil {
+		other.other = d.Other.stats()
+	}
+	for key, rollups := range d.Rollups {
+		values := make(map[string]*Rollup, len(rollups))
+		for _, r := range rollups {
+			values[r.Value] = &Rollup{Value: r.Value, Count: r.Count, TotalTime: r.TotalTime, RowsExamined: r.RowsExamined}
+		}
+		other.rollups[key] = values
+	}
+	return a.Merge(other)
+}
+
+// EventDigest returns the digest of e, an event written by a Forwarder,
+// for MergeDigest. ok is false if e isn't one.
+func EventDigest(e LogEvent) (data []byte, ok bool) {
+	switch d := e[DigestKey].(type) {
+	case json.RawMessage:
+		return d, len(d) > 0
+	case []byte:
+		return d, len(d) > 0
+	case string:
+		return []byte(d), d != ""
+	case map[string]interface{}:
+		// Decoded from the JSON of the event.
+		data, err := json.Marshal(d)
+		return data, err == nil
+	}
+	return nil, false
+}
+
+func newDigestStats(s *QueryStats) digestStats {
+	d := digestStats{
+		Fingerprint:     s.Fingerprint,
+		Count:           s.Count,
+		Logged:          s.Logged,
+		TotalTime:       s.TotalTime,
+		MinTime:         s.MinTime,
+		MaxTime:         s.MaxTime,
+		LockTime:        s.LockTime,
+		RowsSent:        s.RowsSent,
+		RowsExamined:    s.RowsExamined,
+		FullScans:       s.FullScans,
+		UnboundedWrites: s.UnboundedWrites,
+		StatementBytes:  s.StatementBytes,
+		MaxValuesTuples: s.MaxValuesTuples,
+		Histogram:       s.Histogram,
+		FirstSeen:       s.FirstSeen,
+		LastSeen:        s.LastSeen,
+		Sample:          s.Sample,
+	}
+	for key, dist := range s.attributes {
+		if d.Attributes == nil {
+			d.Attributes = make(map[string]digestDistribution, len(s.attributes))
+		}
+		dd := digestDistribution{dist.Count, dist.Sum, dist.Min, dist.Max, dist.mean, dist.m2, nil}
+		if sk := dist.sketch; sk != nil {
+			dd.Sketch = &digestSketch{sk.accuracy, sk.zero, sk.count, sk.min, sk.max, sk.bins}
+		}
+		d.Attributes[key] = dd
+	}
+	return d
+}
+
+// stats returns the QueryStats of d.
+func (d digestStats) stats() *QueryStats {
+	s := &QueryStats{
+		Fingerprint:     d.Fingerprint,
+		Count:           d.Count,
+		Logged:          d.Logged,
+		TotalTime:       d.TotalTime,
+		MinTime:         d.MinTime,
+		MaxTime:         d.MaxTime,
+		LockTime:        d.LockTime,
+		RowsSent:        d.RowsSent,
+		RowsExamined:    d.RowsExamined,
+		FullScans:       d.FullScans,
+		UnboundedWrites: d.UnboundedWrites,
+		StatementBytes:  d.StatementBytes,
+		MaxValuesTuples: d.MaxValuesTuples,
+		Histogram:       d.Histogram,
+		FirstSeen:       d.FirstSeen,
+		LastSeen:        d.LastSeen,
+		Sample:          d.Sample,
+	}
+	for key, dd := range d.Attributes {
+		if s.attributes == nil {
+			s.attributes = make(map[string]*Distribution, len(d.Attributes))
+		}
+		dist := &Distribution{Count: dd.Count, Sum: dd.Sum, Min: dd.Min, Max: dd.Max, mean: dd.Mean, m2: dd.M2}
+		if sk := dd.Sketch; sk != nil {
+			dist.sketch = NewSketch(sk.Accuracy)
+			dist.sketch.zero, dist.sketch.count = sk.Zero, sk.Count
+			dist.sketch.min, dist.sketch.max = sk.Min, sk.Max
+			for i, n := range sk.Bins {
+				dist.sketch.bins[i] = n
+			}
+		}
+		s.attributes[key] = dist
+	}
+	return s
+}
+
+// sortedSet returns the values of set in order.
+func sortedSet(set map[string]struct{}) []string {
+	values := make([]string, 0, len(set))
+	for value := range set {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,124 @@
+package mysqllog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestForwarderDigests(t *testing.T) {
+	newAggregator := func() *Aggregator {
+		return NewAggregator(WithRollups("Database", "User", "Verb"))
+	}
+	report := func(a *Aggregator) string {
+		var buf bytes.Buffer
+		if err := WriteReport(&buf, a.Results(), WithTopN(0), WithSummary(a.Summary())); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range a.Dimensions() {
+			for _, r := range a.Rollup(key) {
+				fmt.Fprintf(&buf, "%s %s %d %.6f %d\n", key, r.Value, r.Count, r.TotalTime, r.RowsExamined)
+			}
+		}
+		return buf.String()
+	}
+
+	single := newAggregator()
+	var shipped bytes.Buffer
+	for i, file := range []string{"./_test/rds.txt", "./_test/sessions.txt"} {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		agent := NewForwarder(NewJSONWriter(&shipped, Original), WithForwarderInterval(time.Minute),
+			WithForwarderEvents(7), WithForwarderClock(clock), WithForwarderSource(fmt.Sprint("agent", i)),
+			WithForwarderAggregator(WithRollups("Database", "User", "Verb")))
+		for _, e := range parseAll(NewParser(), string(b)) {
+			single.Add(e)
+			if err := agent.Write(e); err != nil {
+				t.Fatal(err)
+			}
+			clock.Add(15 * time.Second)
+		}
+		if err := agent.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	global := newAggregator()
+	digests := 0
+	scanner := bufio.NewScanner(&shipped)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		var e LogEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		data, ok := EventDigest(e)
+		if !ok || e["Source"] == nil {
+			t.Fatalf("expected a digest event, got %v", e)
+		}
+		if err := global.MergeDigest(data); err != nil {
+			t.Fatal(err)
+		}
+		digests++
+	}
+	if digests < 4 {
+		t.Errorf("expected several digests per agent, got %d", digests)
+	}
+	if got, want := report(global), report(single); got != want {
+		t.Errorf("merged digests differ:\n%s\nwant:\n%s", got, want)
+	}
+	got, want := global.Results(), single.Results()
+	for i := range want {
+		if i >= len(got) {
+			t.Fatalf("expected %d results, got %d", len(want), len(got))
+		}
+		for _, q := range []float64{0.5, 0.95, 0.99} {
+			if got[i].Quantile(q) != want[i].Quantile(q) {
+				t.Errorf("result %d: expected quantile %v of %v, got %v", i, q, want[i].Quantile(q), got[i].Quantile(q))
+			}
+		}
+	}
+
+	if err := global.MergeDigest([]byte(`{"v":2}`)); err == nil {
+		t.Error("expected an error for a later digest version")
+	}
+}
+
+func TestForwarderFlush(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sink := &recordingSink{}
+	f := NewForwarder(sink, WithForwarderInterval(time.Minute), WithForwarderClock(clock))
+	f.Write(LogEvent{"Statement": "SELECT 1", "Query_time": 1.0})
+	if err := f.FlushDue(); err != nil || len(sink.events) != 0 {
+		t.Fatalf("expected no digest before the interval, got %v, %v", sink.events, err)
+	}
+	clock.Add(time.Minute)
+	if err := f.FlushDue(); err != nil || len(sink.events) != 1 {
+		t.Fatalf("expected a digest after the interval, got %v, %v", sink.events, err)
+	}
+	clock.Add(time.Minute)
+	if err := f.FlushDue(); err != nil || len(sink.events) != 1 {
+		t.Errorf("expected no digest without events, got %d", len(sink.events))
+	}
+	f.Write(LogEvent{"Statement": "SELECT 2", "Query_time": 2.0})
+	if err := f.Close(); err != nil || len(sink.events) != 2 || !sink.closed {
+		t.Fatalf("expected the partial interval on close, got %d, %v", len(sink.events), err)
+	}
+
+	// Each digest only has its own interval.
+	a := NewAggregator()
+	data, _ := EventDigest(sink.events[1])
+	if err := a.MergeDigest(data); err != nil {
+		t.Fatal(err)
+	}
+	if results := a.Results(); len(results) != 1 || results[0].TotalTime != 2 || !sink.events[1]["Timestamp"].(time.Time).Equal(clock.Now()) {
+		t.Errorf("unexpected digest %s", data)
+	}
+}
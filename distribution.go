@@ -0,0 +1,248 @@
+package mysqllog
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DistributionAttributes are the attributes QueryStats keeps a
+// Distribution of, when events have them. BytesPerRow is derived from
+// the others.
+var DistributionAttributes = []string{"Query_time", "Lock_time", "Rows_sent", "Rows_examined", "Bytes_sent", "Bytes_received", BytesPerRow}
+
+// BytesPerRow is the Distribution of Bytes_sent per row sent, counting
+// zero rows sent as one, of the events with Bytes_sent.
+const BytesPerRow = "BytesPerRow"
+
+// distributionValue returns the value of key, one of
+// DistributionAttributes, for e, and false if e doesn't have it.
+func distributionValue(e LogEvent, key string) (float64, bool) {
+	if key != BytesPerRow {
+		return e.Float64(key)
+	}
+	sent, ok := e.Float64("Bytes_sent")
+	if !ok {
+		return 0, false
+	}
+	rows, _ := e.Float64("Rows_sent")
+	return sent / math.Max(rows, 1), true
+}
+
+// Distribution summarizes the values of an attribute in constant
+// memory: the mean and variance are computed with Welford's method,
+// and quantiles come from a Sketch.
+type Distribution struct {
+	Count int64
+	Sum   float64
+	Min   float64
+	Max   float64
+
+	mean   float64
+	m2     float64
+	sketch *Sketch
+}
+
+// newDistribution returns an empty Distribution whose quantiles have
+// the given relative accuracy.
+func newDistribution(accuracy float64) *Distribution {
+	return &Distribution{sketch: NewSketch(accuracy)}
+}
+
+// Add adds a value.
+func (d *Distribution) Add(v float64) {
+	if d.Count == 0 || v < d.Min {
+		d.Min = v
+	}
+	if d.Count == 0 || v > d.Max {
+		d.Max = v
+	}
+	d.Count++
+	d.Sum += v
+	delta := v - d.mean
+	d.mean += delta / float64(d.Count)
+	d.m2 += delta * (v - d.mean)
+	if d.sketch == nil {
+		d.sketch = NewSketch(DefaultSketchAccuracy)
+	}
+	d.sketch.Add(v)
+}
+
+// Mean returns the average value.
+func (d *Distribution) Mean() float64 {
+	return d.mean
+}
+
+// Stddev returns the standard deviation of the values.
+func (d *Distribution) Stddev() float64 {
+	if d.Count == 0 || d.m2 <= 0 {
+		return 0
+	}
+	return math.Sqrt(d.m2 / float64(d.Count))
+}
+
+// Quantile returns the approximate q-quantile of the values.
+func (d *Distribution) Quantile(q float64) float64 {
+	if d.sketch == nil {
+		return 0
+	}
+	return d.sketch.Quantile(q)
+}
+
+// Median returns the approximate median.
+func (d *Distribution) Median() float64 {
+	return d.Quantile(0.5)
+}
+
+// P95 returns the approximate 95th percentile.
+func (d *Distribution) P95() float64 {
+	return d.Quantile(0.95)
+}
+
+// Merge adds the values of other to d. Both must have the same
+// quantile accuracy.
+func (d *Distribution) Merge(other *Distribution) error {
+	if other.Count == 0 {
+		return nil
+	}
+	if other.sketch != nil {
+		if d.sketch == nil {
+			d.sketch = NewSketch(other.sketch.RelativeAccuracy())
+		}
+		if err := d.sketch.Merge(other.sketch); err != nil {
+			return err
+		}
+	}
+	if d.Count == 0 || other.Min < d.Min {
+		d.Min = other.Min
+	}
+	if d.Count == 0 || other.Max > d.Max {
+		d.Max = other.Max
+	}
+	// Combines the means and variances as in Chan et al.
+	count := d.Count + other.Count
+	delta := other.mean - d.mean
+	d.m2 += other.m2 + delta*delta*float64(d.Count)*float64(other.Count)/float64(count)
+	d.mean += delta * float64(other.Count) / float64(count)
+	d.Count = count
+	d.Sum += other.Sum
+	return nil
+}
+
+// clone returns a copy of d that isn't affected by later values.
+func (d *Distribution) clone() *Distribution {
+	c := *d
+	if d.sketch != nil {
+		c.sketch = d.sketch.Clone()
+	}
+	return &c
+}
+
+// attributeLabels are the names of DistributionAttributes in reports,
+// as pt-query-digest has them.
+var attributeLabels = map[string]string{
+	"Query_time":    "Exec time",
+	"Lock_time":     "Lock time",
+	"Rows_sent":     "Rows sent",
+	"Rows_examined": "Rows examine",
+	"Bytes_sent":    "Bytes sent",
+	// pt-query-digest has no such rows.
+	"Bytes_received": "Bytes recv",
+	BytesPerRow:      "Bytes/row",
+}
+
+// reportAttribute is a row of the attribute table of a report, with
+// the numbers formatted in human units.
+type reportAttribute struct {
+	Name                                      string
+	Total, Min, Max, Avg, P95, Stddev, Median string
+}
+
+// attributeRows returns the attribute table rows of s.
+func attributeRows(s QueryStats) []reportAttribute {
+	var rows []reportAttribute
+	for _, key := range DistributionAttributes {
+		d := s.Attribute(key)
+		if d == nil {
+			continue
+		}
+		format := formatCount
+		switch key {
+		case "Query_time", "Lock_time":
+			format = formatSeconds
+		case "Bytes_sent", "Bytes_received", BytesPerRow:
+			format = formatBytes
+		}
+		total := format(d.Sum)
+		if key == BytesPerRow {
+			// A sum of ratios means nothing.
+			total = ""
+		}
+		rows = append(rows, reportAttribute{
+			Name:   attributeLabels[key],
+			Total:  total,
+			Min:    format(d.Min),
+			Max:    format(d.Max),
+			Avg:    format(d.Mean()),
+			P95:    format(d.P95()),
+			Stddev: format(d.Stddev()),
+			Median: format(d.Median()),
+		})
+	}
+	return rows
+}
+
+// formatSeconds formats a time with three significant digits in s, ms
+// or us, as pt-query-digest does.
+func formatSeconds(v float64) string {
+	switch {
+	case v == 0:
+		return "0"
+	case v >= 1:
+		return significant(v) + "s"
+	case v >= 1e-3:
+		return significant(v*1e3) + "ms"
+	}
+	return significant(v*1e6) + "us"
+}
+
+// formatCount formats a count with three significant digits and a k,
+// M or G suffix for thousands, millions and billions.
+func formatCount(v float64) string {
+	return scaled(v, 1000)
+}
+
+// formatBytes formats a size with three significant digits and a k, M
+// or G suffix for multiples of 1024.
+func formatBytes(v float64) string {
+	return scaled(v, 1024)
+}
+
+func scaled(v, unit float64) string {
+	suffix := ""
+	for _, s := range []string{"k", "M", "G"} {
+		if v < unit {
+			break
+		}
+		v /= unit
+		suffix = s
+	}
+	return significant(v) + suffix
+}
+
+// significant formats v with up to three significant digits, without
+// trailing zeros.
+func significant(v float64) string {
+	decimals := 2
+	switch {
+	case v >= 100:
+		decimals = 0
+	case v >= 10:
+		decimals = 1
+	}
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
@@ -0,0 +1,138 @@
+package mysqllog
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func TestDistribution(t *testing.T) {
+	values := []float64{0.5, 1.5, 2, 8, 0.25, 3, 3, 12}
+	whole := newDistribution(DefaultSketchAccuracy)
+	left := newDistribution(DefaultSketchAccuracy)
+	right := newDistribution(DefaultSketchAccuracy)
+	var sum float64
+	for i, v := range values {
+		whole.Add(v)
+		if i < 3 {
+			left.Add(v)
+		} else {
+			right.Add(v)
+		}
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(squares / float64(len(values)))
+
+	if err := left.Merge(right); err != nil {
+		t.Fatal(err)
+	}
+	for name, d := range map[string]*Distribution{"streamed": whole, "merged": left} {
+		if d.Count != 8 || d.Sum != sum || d.Min != 0.25 || d.Max != 12 {
+			t.Errorf("%s: expected count 8, sum %v, min 0.25 and max 12, got %d, %v, %v and %v", name, sum, d.Count, d.Sum, d.Min, d.Max)
+		}
+		if math.Abs(d.Mean()-mean) > 1e-12 || math.Abs(d.Stddev()-stddev) > 1e-12 {
+			t.Errorf("%s: expected mean %v and stddev %v, got %v and %v", name, mean, stddev, d.Mean(), d.Stddev())
+		}
+		if median := d.Median(); math.Abs(median-2) > 2*DefaultSketchAccuracy {
+			t.Errorf("%s: expected a median of about 2, got %v", name, median)
+		}
+	}
+}
+
+func TestQueryStatsAttributes(t *testing.T) {
+	results := aggregate([]LogEvent{
+		{"Statement": "SELECT 1", "Query_time": 1.0, "Rows_examined": int64(10)},
+		{"Statement": "SELECT 1", "Query_time": 3.0, "Rows_examined": int64(30), "Bytes_sent": int64(100)},
+	})
+	s := results[0]
+	if d := s.Attribute("Rows_examined"); d == nil || d.Count != 2 || d.Mean() != 20 || d.Stddev() != 10 {
+		t.Errorf("expected Rows_examined with a mean of 20 and stddev of 10, got %+v", d)
+	}
+	if d := s.Attribute("Bytes_sent"); d == nil || d.Count != 1 {
+		t.Errorf("expected Bytes_sent for the one event that has it, got %+v", d)
+	}
+	if d := s.Attribute("Rows_sent"); d != nil {
+		t.Errorf("expected no Rows_sent, got %+v", d)
+	}
+	if s.StddevTime() != 1 {
+		t.Errorf("expected a Query_time stddev of 1, got %v", s.StddevTime())
+	}
+}
+
+func TestQueryStatsNetworkBytes(t *testing.T) {
+	results := aggregate([]LogEvent{
+		{"Statement": "SELECT * FROM t", "Query_time": 1.0, "Rows_sent": int64(10), "Bytes_sent": int64(5000), "Bytes_received": int64(30)},
+		{"Statement": "SELECT * FROM t", "Query_time": 1.0, "Rows_sent": int64(0), "Bytes_sent": int64(1000)},
+		{"Statement": "SELECT * FROM t", "Query_time": 1.0, "Rows_sent": int64(4)},
+	})
+	s := results[0]
+	if count := s.AttributeCount(BytesPerRow); count != 2 {
+		t.Errorf("expected BytesPerRow for the 2 events with Bytes_sent, got %d", count)
+	}
+	// 5000/10 and 1000/1, without a zero for the event without bytes.
+	if bytes := s.BytesPerRow(); bytes != 750 {
+		t.Errorf("expected 750 bytes per row, got %v", bytes)
+	}
+	if count := s.AttributeCount("Bytes_received"); count != 1 {
+		t.Errorf("expected Bytes_received for 1 event, got %d", count)
+	}
+	if d := s.Attribute("Bytes_received"); d == nil || d.Mean() != 30 {
+		t.Errorf("expected a Bytes_received mean of 30, got %+v", d)
+	}
+	if count := s.AttributeCount("Lock_time"); count != 0 {
+		t.Errorf("expected no Lock_time, got %d", count)
+	}
+}
+
+func TestNetworkBytesTypes(t *testing.T) {
+	for _, name := range []string{"canonical_mysql8.txt", "canonical_percona.txt", "canonical_mariadb.txt"} {
+		b, err := ioutil.ReadFile("_test/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events := parseAll(NewParser(), string(b))
+		if len(events) == 0 {
+			t.Fatalf("%s: no events", name)
+		}
+		for _, key := range []string{"Bytes_sent", "Bytes_received"} {
+			if v, ok := events[0][key]; ok {
+				if _, ok := v.(int64); !ok {
+					t.Errorf("%s: expected %s as an int64, got %T", name, key, v)
+				}
+			}
+		}
+		if _, ok := events[0]["Bytes_sent"].(int64); !ok {
+			t.Errorf("%s: expected Bytes_sent, got %v", name, events[0]["Bytes_sent"])
+		}
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	type TestCase struct {
+		format func(float64) string
+		value  float64
+		want   string
+	}
+	for _, c := range []TestCase{
+		{formatSeconds, 0, "0"},
+		{formatSeconds, 0.0000154, "15.4us"},
+		{formatSeconds, 0.25, "250ms"},
+		{formatSeconds, 1.5, "1.5s"},
+		{formatSeconds, 123.456, "123s"},
+		{formatCount, 999, "999"},
+		{formatCount, 1234, "1.23k"},
+		{formatCount, 2500000, "2.5M"},
+		{formatCount, 10.5, "10.5"},
+		{formatBytes, 2048, "2k"},
+		{formatBytes, 3 << 20, "3M"},
+	} {
+		if got := c.format(c.value); got != c.want {
+			t.Errorf("expected %v to format as %q, got %q", c.value, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package mysqllog
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithDurations stores Query_time, Lock_time and the other times in
+// seconds, such as InnoDB_rec_lock_wait and TiDB's Process_time, as
+// time.Duration instead of float64. They're converted from the
+// decimal text, so microseconds aren't lost to float rounding.
+// LogEvent.Float64 still returns them in seconds. See EncodeDurations
+// for JSON output.
+func WithDurations() Option {
+	return func(p *Parser) {
+		p.durations = true
+	}
+}
+
+// isDurationAttribute reports whether the float attribute key holds
+// seconds.
+func isDurationAttribute(key string) bool {
+	return key == "Query_time" || key == "Lock_time" ||
+		strings.HasSuffix(key, "_time") || strings.HasSuffix(key, "_wait")
+}
+
+var errBadSeconds = errors.New("mysqllog: invalid seconds")
+
+// parseSeconds converts a decimal number of seconds, such as
+// "1.234567", to a time.Duration exactly, up to nanoseconds. Other
+// notations, such as exponents, go through float64.
+func parseSeconds(s string) (time.Duration, error) {
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	negative := strings.HasPrefix(whole, "-")
+	digits := strings.TrimPrefix(whole, "-")
+	if len(frac) > 9 {
+		frac = frac[:9]
+	}
+	if isDecimal(digits) && (frac == "" || isDecimal(frac)) && len(digits) <= 9 && digits+frac != "" {
+		sec, _ := strconv.ParseInt("0"+digits, 10, 64)
+		nsec, _ := strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+		d := time.Duration(sec)*time.Second + time.Duration(nsec)
+		if negative {
+			d = -d
+		}
+		return d, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f > time.Duration(1<<63-1).Seconds() || f < -time.Duration(1<<63-1).Seconds() {
+		return 0, errBadSeconds
+	}
+	return time.Duration(f * float64(time.Second)), nil
+}
+
+func isDecimal(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Duration returns the attribute key in seconds as a time.Duration.
+// time.Duration, float64 and int64 values are accepted. ok is false if
+// the attribute is missing or not numeric.
+func (e LogEvent) Duration(key string) (d time.Duration, ok bool) {
+	switch v := e[key].(type) {
+	case time.Duration:
+		return v, true
+	case float64:
+		return time.Duration(v * float64(time.Second)), true
+	case int64:
+		return time.Duration(v) * time.Second, true
+	}
+	return 0, false
+}
+
+// DurationFormat is how time.Duration values are written as JSON.
+type DurationFormat int
+
+const (
+	// DurationSeconds writes float64 seconds, as without WithDurations.
+	DurationSeconds DurationFormat = iota
+	// DurationString writes strings such as "1.5s", from
+	// time.Duration.String.
+	DurationString
+	// DurationNanoseconds writes int64 nanoseconds, which is what
+	// encoding/json does with a time.Duration.
+	DurationNanoseconds
+)
+
+// EncodeDurations returns e with its time.Duration values converted to
+// format for JSON. e is returned as it is if there's nothing to
+// convert; otherwise it's copied, leaving e unchanged.
+func EncodeDurations(e LogEvent, format DurationFormat) LogEvent {
+	if format == DurationNanoseconds {
+		return e
+	}
+	var encoded LogEvent
+	for k, v := range e {
+		d, ok := v.(time.Duration)
+		if !ok {
+			continue
+		}
+		if encoded == nil {
+			encoded = make(LogEvent, len(e))
+			for k, v := range e {
+				encoded[k] = v
+			}
+		}
+		if format == DurationString {
+			encoded[k] = d.String()
+		} else {
+			encoded[k] = d.Seconds()
+		}
+	}
+	if encoded == nil {
+		return e
+	}
+	return encoded
+}
@@ -0,0 +1,121 @@
+package mysqllog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSeconds(t *testing.T) {
+	type TestCase struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}
+	for _, c := range []TestCase{
+		{"0.000123", 123 * time.Microsecond, true},
+		{"1.234567", 1234567 * time.Microsecond, true},
+		{"10", 10 * time.Second, true},
+		{".5", 500 * time.Millisecond, true},
+		{"2.", 2 * time.Second, true},
+		{"0.1234567891", 123456789, true},
+		{"-1.5", -1500 * time.Millisecond, true},
+		{"1e-3", time.Millisecond, true},
+		{"1e300", 0, false},
+		{"", 0, false},
+		{".", 0, false},
+		{"1.2.3", 0, false},
+	} {
+		d, err := parseSeconds(c.value)
+		if (err == nil) != c.ok || d != c.expected {
+			t.Errorf("%q: expected %v, %v, got %v, %v", c.value, c.expected, c.ok, d, err)
+		}
+	}
+}
+
+func TestWithDurations(t *testing.T) {
+	input := "# Query_time: 0.000123  Lock_time: 2.5 Rows_sent: 1\n" +
+		"# InnoDB_rec_lock_wait: 0.000001\nSELECT 1;\n"
+	events := parseAll(NewParser(WithDurations()), input)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	e := events[0]
+	if e["Query_time"] != 123*time.Microsecond || e["Lock_time"] != 2500*time.Millisecond ||
+		e["InnoDB_rec_lock_wait"] != time.Microsecond || e["Rows_sent"] != int64(1) {
+		t.Errorf("unexpected attributes %v", e)
+	}
+	if f, ok := e.Float64("Query_time"); !ok || f != 0.000123 {
+		t.Errorf("expected 0.000123 seconds, got %v", f)
+	}
+	if d, ok := parseAll(&Parser{}, input)[0].Duration("Lock_time"); !ok || d != 2500*time.Millisecond {
+		t.Errorf("expected 2.5s from a float, got %v", d)
+	}
+}
+
+func TestWithDurationsFixtures(t *testing.T) {
+	paths, err := filepath.Glob("_test/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		floats := parseAll(&Parser{}, string(content))
+		durations := parseAll(NewParser(WithDurations()), string(content))
+		if len(floats) != len(durations) {
+			t.Fatalf("%s: expected %d events, got %d", path, len(floats), len(durations))
+		}
+		for i, e := range durations {
+			for k, v := range e {
+				d, ok := v.(time.Duration)
+				if !ok {
+					continue
+				}
+				f, _ := floats[i][k].(float64)
+				if math.Abs(float64(d)-f*1e9) >= 1 {
+					t.Errorf("%s: event %d: %s: expected %v seconds, got %v", path, i, k, f, d)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeDurations(t *testing.T) {
+	type TestCase struct {
+		format   DurationFormat
+		expected string
+	}
+	e := LogEvent{"Query_time": 1500 * time.Millisecond, "Rows_sent": int64(1)}
+	for _, c := range []TestCase{
+		{DurationSeconds, `{"Query_time":1.5,"Rows_sent":1}`},
+		{DurationString, `{"Query_time":"1.5s","Rows_sent":1}`},
+		{DurationNanoseconds, `{"Query_time":1500000000,"Rows_sent":1}`},
+	} {
+		b, err := json.Marshal(EncodeDurations(e, c.format))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != c.expected {
+			t.Errorf("%d: expected %s, got %s", c.format, c.expected, b)
+		}
+
+		var buf bytes.Buffer
+		w := NewJSONWriter(&buf, Original, WithDurationFormat(c.format))
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.expected+"\n" {
+			t.Errorf("%d: expected %s from the JSONWriter, got %s", c.format, c.expected, buf.String())
+		}
+	}
+	if _, ok := e["Query_time"].(time.Duration); !ok {
+		t.Errorf("expected the event to be unchanged, got %v", e)
+	}
+}
@@ -0,0 +1,49 @@
+package mysqllog
+
+import (
+	"regexp"
+	"strings"
+)
+
+// errorLogLine matches the lines of an error log: those of MySQL 5.7 and
+// newer, such as "2023-08-01T10:36:57.123456Z 0 [Warning] [MY-010068]
+// [Server] ...", those of MariaDB and MySQL 5.6, such as "2023-08-01
+// 10:36:57 0 [Note] ..." or "2023-08-01 10:36:57 7f2a InnoDB: ...", and
+// those of MySQL 5.5 and older, such as "230801 10:36:57 [ERROR] ...".
+var errorLogLine = regexp.MustCompile(`^(?:\d{4}-\d\d-\d\d[T ]\d\d:\d\d:\d\d(?:\.\d+)?(?:Z|[+-]\d\d:?\d\d)?|\d{6} {1,2}\d{1,2}:\d\d:\d\d)(?: +[0-9a-fA-F]+)? +(?:\[(?:ERROR|Error|Warning|Warn|Note|System|Info|InnoDB)\]|InnoDB:)`)
+
+// WithErrorLogLines calls fn, if not nil, with the error log lines
+// skipped by p, without their line ending. When log_error and
+// slow_query_log_file are the same file, or a container merges both
+// logs into one stream, lines such as "2023-08-01T10:36:57.123456Z 0
+// [Warning] ..." come between the events. The Parser always skips them,
+// with this option or without, and counts them in Stats.ForeignLines;
+// a line inside an open string literal of a statement is its content,
+// though. The lines after the first of a multi-line error log entry,
+// such as a stack trace, have no timestamp and aren't told apart.
+func WithErrorLogLines(fn func(line string)) Option {
+	return func(p *Parser) {
+		p.errorLogLines = fn
+	}
+}
+
+// skipErrorLogLine reports whether line is an error log line, and skips
+// it if so.
+func (p *Parser) skipErrorLogLine(line string) bool {
+	if p.inQuery && p.quote != 0 || !isErrorLogLine(line) {
+		return false
+	}
+	p.stats.ForeignLines++
+	if p.logger != nil {
+		p.logger.Printf("mysqllog: line %d: skipped error log line", p.line)
+	}
+	if p.errorLogLines != nil {
+		p.errorLogLines(strings.TrimRight(line, "\r\n"))
+	}
+	return true
+}
+
+// isErrorLogLine reports whether line is an error log line.
+func isErrorLogLine(line string) bool {
+	return line != "" && line[0] >= '0' && line[0] <= '9' && errorLogLine.MatchString(line)
+}
@@ -0,0 +1,70 @@
+package mysqllog
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestErrorLogLines(t *testing.T) {
+	log, err := ioutil.ReadFile("./_test/interleaved.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var skipped []string
+	p := NewParser(WithErrorLogLines(func(line string) {
+		skipped = append(skipped, line)
+	}))
+	events := parseAll(p, string(log))
+
+	statements := []string{
+		"SELECT * FROM orders\nWHERE id = 1;",
+		"INSERT INTO notes (body) VALUES ('before\n2023-08-01T10:36:58.000000Z 0 [Warning] pasted into a note\nafter');",
+		"SELECT id,\n2023, 'x' FROM orders;",
+	}
+	if len(events) != len(statements) {
+		t.Fatalf("expected %d events, got %d: %v", len(statements), len(events), events)
+	}
+	for i, e := range events {
+		if e["Statement"] != statements[i] {
+			t.Errorf("event %d: expected statement %q, got %q", i, statements[i], e["Statement"])
+		}
+		if _, incomplete := e.Incomplete(); incomplete {
+			t.Errorf("event %d: expected to be complete, got %v", i, e)
+		}
+	}
+	if e := events[1]; e["User"] != "app" || e["Query_time"] != 2.0 {
+		t.Errorf("expected the header of the second event to be whole, got %v", e)
+	}
+	if n := p.Stats().ForeignLines; n != 8 || len(skipped) != 8 {
+		t.Errorf("expected 8 error log lines skipped, got %d and %q", n, skipped)
+	} else if skipped[2] != "2023-08-01T10:36:57.200000Z 12 [Note] [MY-010914] [Server] Aborted connection 12 to db: 'shop' user: 'app' host: 'web1' (Got an error reading communication packets)." {
+		t.Errorf("unexpected line %q", skipped[2])
+	}
+}
+
+func TestErrorLogLine(t *testing.T) {
+	cases := []struct {
+		Line     string
+		Expected bool
+	}{
+		{"2023-08-01T10:36:57.123456Z 0 [Warning] [MY-010068] [Server] CA certificate ca.pem is self signed.", true},
+		{"2023-08-01T10:36:57.123456+02:00 0 [ERROR] [MY-012574] [InnoDB] Unable to lock ./ibdata1", true},
+		{"2023-08-01T10:36:57.123456Z 0 [Note] Event Scheduler: Loaded 0 events", true},
+		{"2023-08-01 10:36:57 0 [Note] InnoDB: Initializing buffer pool", true},
+		{"2023-08-01 10:36:57 140234 [Warning] Aborted connection", true},
+		{"2023-08-01 10:36:57 7f2a3c InnoDB: Error: unable to create temporary file", true},
+		{"230801 10:36:57 [ERROR] Aborted connection", true},
+		{"230801  9:36:57 InnoDB: Started", true},
+		{"2023-08-01T10:36:57.123456Z", false},
+		{"2023-08-01 10:36:57, 'x', 1)", false},
+		{"2023-08-01 10:36:57 0 [Unknown] x", false},
+		{"1, 2, 3);", false},
+		{"# Time: 2023-08-01T10:36:57.123456Z", false},
+		{"SELECT 1;", false},
+	}
+	for _, c := range cases {
+		if result := errorLogLine.MatchString(c.Line); result != c.Expected {
+			t.Errorf("expected %q to match %v", c.Line, c.Expected)
+		}
+	}
+}
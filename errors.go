@@ -0,0 +1,119 @@
+package mysqllog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Reasons for a ParseError, to compare with its Reason.
+var (
+	ErrBadTimestamp     = errors.New("mysqllog: bad timestamp")
+	ErrBadUserHost      = errors.New("mysqllog: bad User@Host line")
+	ErrUnknownAttribute = errors.New("mysqllog: unknown attribute")
+	ErrBadAttribute     = errors.New("mysqllog: bad attribute value")
+)
+
+// Sections of an event a ParseError can be found in.
+const (
+	SectionHeader   = "header"
+	SectionUserHost = "user-host"
+	SectionSet      = "set"
+)
+
+// DefaultErrorLineLength is the length ParseError.Text is truncated to.
+const DefaultErrorLineLength = 200
+
+// ParseError is malformed input found by a Parser in strict mode.
+type ParseError struct {
+	// Line is the 1-based number of the line, and Offset the byte offset
+	// of its start, counting every line given to the Parser.
+	Line   int
+	Offset int64
+	// Text is the line, truncated to DefaultErrorLineLength.
+	Text    string
+	Section string
+	// Reason is one of the Err variables, such as ErrBadTimestamp.
+	Reason error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("mysqllog: line %d (%s): %s: %q", e.Line, e.Section,
+		strings.TrimPrefix(e.Reason.Error(), "mysqllog: "), e.Text)
+}
+
+// Unwrap returns the Reason, so errors.Is(err, ErrBadTimestamp) works.
+func (e *ParseError) Unwrap() error {
+	return e.Reason
+}
+
+// ReadError is an error reading the input, as opposed to a ParseError.
+type ReadError struct {
+	// Offset is the number of bytes read before the error.
+	Offset int64
+	Err    error
+}
+
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("mysqllog: reading at byte %d: %v", e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// WithStrict makes the Parser report malformed input, such as a bad
+// "# Time:" line or an attribute it has no type for, as ParseErrors.
+// Events are still parsed as well as possible. ParseReader and ParseFile
+// stop at the first ParseError and return it.
+func WithStrict() Option {
+	return func(p *Parser) {
+		p.strict = true
+	}
+}
+
+// Errors returns the ParseErrors found since the last call, in input
+// order, in strict mode.
+func (p *Parser) Errors() []*ParseError {
+	errs := p.errs
+	p.errs = nil
+	return errs
+}
+
+// legacyTimeLayout is the "# Time:" format of MySQL 5.5 and MariaDB,
+// such as "171224  2:42:00", with spaces collapsed.
+const legacyTimeLayout = "060102 15:04:05"
+
+// checkKnown reports whether the attribute key is known or registered,
+// recording ErrUnknownAttribute in strict mode if it isn't.
+func (p *Parser) checkKnown(i int, line, key string, known bool) bool {
+	if known {
+		return true
+	}
+	if _, ok := p.attributes.parser(key); ok {
+		return true
+	}
+	p.parseError(i, line, SectionHeader, ErrUnknownAttribute)
+	return false
+}
+
+// linePosition is where a pending line starts in the input.
+type linePosition struct {
+	line   int
+	offset int64
+}
+
+// parseError records a ParseError for pending line i in strict mode.
+func (p *Parser) parseError(i int, line, section string, reason error) {
+	if !p.strict || i >= len(p.positions) {
+		return
+	}
+	p.errs = append(p.errs, &ParseError{
+		Line:    p.positions[i].line,
+		Offset:  p.positions[i].offset,
+		Text:    Truncate(strings.TrimRight(line, "\r\n"), DefaultErrorLineLength),
+		Section: section,
+		Reason:  reason,
+	})
+}
@@ -0,0 +1,29 @@
+//go:build go1.13
+// +build go1.13
+
+package mysqllog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorsAs(t *testing.T) {
+	log := "# Time: yesterday\n# User@Host: root[root] @ localhost []\n# Query_time: 1.0\nSELECT 1;\n"
+	err := ParseReader(strings.NewReader(log), func(LogEvent) {}, WithParser(NewParser(WithStrict())))
+	wrapped := fmt.Errorf("loading: %w", err)
+
+	var parseErr *ParseError
+	if !errors.As(wrapped, &parseErr) || parseErr.Line != 1 {
+		t.Fatalf("expected a ParseError for line 1, got %v", err)
+	}
+	if !errors.Is(wrapped, ErrBadTimestamp) || errors.Is(wrapped, ErrBadUserHost) {
+		t.Errorf("expected only ErrBadTimestamp to match, got %v", err)
+	}
+	var readErr *ReadError
+	if errors.As(wrapped, &readErr) {
+		t.Errorf("expected a ParseError, not a ReadError")
+	}
+}
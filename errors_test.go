@@ -0,0 +1,91 @@
+package mysqllog
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/corrupted.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(b)
+
+	p := NewParser(WithStrict())
+	events := parseAll(p, content)
+	if len(events) != 3 {
+		t.Fatalf("expected every event to be parsed, got %d", len(events))
+	}
+	type TestCase struct {
+		Line    int
+		Section string
+		Reason  error
+	}
+	expected := []TestCase{
+		{6, SectionHeader, ErrBadTimestamp},
+		{7, SectionUserHost, ErrBadUserHost},
+		{8, SectionHeader, ErrBadAttribute},
+		{8, SectionHeader, ErrUnknownAttribute},
+		{9, SectionSet, ErrBadTimestamp},
+	}
+	errs := p.Errors()
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), errs)
+	}
+	for i, c := range expected {
+		e := errs[i]
+		if e.Line != c.Line || e.Section != c.Section || e.Reason != c.Reason {
+			t.Errorf("expected %+v, got %+v", c, *e)
+		}
+		if !strings.HasPrefix(content[e.Offset:], e.Text+"\n") {
+			t.Errorf("expected line %d at byte %d, got %q", e.Line, e.Offset, e.Text)
+		}
+	}
+	if msg := errs[1].Error(); msg != `mysqllog: line 7 (user-host): bad User@Host line: "# User@Host: garbage"` {
+		t.Errorf("unexpected message %s", msg)
+	}
+	if len(p.Errors()) != 0 {
+		t.Errorf("expected the errors to be cleared")
+	}
+
+	// Lenient parsers don't report anything.
+	p = &Parser{}
+	parseAll(p, content)
+	if errs := p.Errors(); len(errs) != 0 {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
+
+func TestParseReaderErrors(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/corrupted.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := 0
+	err = ParseReader(strings.NewReader(string(b)), func(LogEvent) { events++ }, WithParser(NewParser(WithStrict())))
+	if e, ok := err.(*ParseError); !ok || e.Line != 6 || e.Reason != ErrBadTimestamp {
+		t.Errorf("expected a ParseError for line 6, got %v", err)
+	}
+	if events != 2 {
+		t.Errorf("expected 2 events before stopping, got %d", events)
+	}
+
+	failure := errors.New("disk on fire")
+	r := io.MultiReader(strings.NewReader("# Time: 2017-12-24T02:41:51.316510Z\n"), &failingReader{failure})
+	err = ParseReader(r, func(LogEvent) {})
+	if e, ok := err.(*ReadError); !ok || e.Err != failure || e.Offset != 36 {
+		t.Errorf("expected a ReadError at byte 36, got %#v", err)
+	}
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
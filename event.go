@@ -0,0 +1,404 @@
+package mysqllog
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Float64 returns the numeric attribute key as a float64.
+// float64 and int64 values are accepted, and time.Duration values, from
+// WithDurations, are returned in seconds. ok is false if the attribute
+// is missing or not numeric.
+func (e LogEvent) Float64(key string) (v float64, ok bool) {
+	switch n := e[key].(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case time.Duration:
+		return n.Seconds(), true
+	}
+	return 0, false
+}
+
+// Int64 returns the numeric attribute key as an int64.
+// Float values are truncated, and time.Duration values are whole
+// seconds. ok is false if the attribute is missing or not numeric.
+func (e LogEvent) Int64(key string) (v int64, ok bool) {
+	switch n := e[key].(type) {
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), true
+	case time.Duration:
+		return int64(n / time.Second), true
+	}
+	return 0, false
+}
+
+// Clone returns a deep copy of e. Nested maps and slices, such as
+// "Labels", are copied too, so the clone can be mutated freely.
+func (e LogEvent) Clone() LogEvent {
+	if e == nil {
+		return nil
+	}
+	clone := make(LogEvent, len(e))
+	for k, v := range e {
+		clone[k] = cloneValue(v)
+	}
+	return clone
+}
+
+// coreKeys are the keys LogEvent.Keys returns first, in order, and
+// eventFieldKeys the keys of eventFields, which aren't header attributes.
+var (
+	coreKeys = []string{"Timestamp", "User", "Host", "IP", "Port", "Id", "Database",
+		"Query_time", "Lock_time", "Rows_sent", "Rows_examined", "Statement"}
+	eventFieldKeys = func() map[string]bool {
+		keys := make(map[string]bool, len(eventFields))
+		for _, f := range eventFields {
+			keys[f.Name] = true
+		}
+		return keys
+	}()
+)
+
+// Keys returns the keys of e in a canonical order, for output that
+// mustn't depend on the random order of ranging over the map: the core
+// fields first, in the order Timestamp, User, Host, IP, Port, Id,
+// Database, Query_time, Lock_time, Rows_sent, Rows_examined and
+// Statement, then the other header attributes, and keys this package
+// doesn't know, sorted, then the keys the Parser and its options add,
+// such as "Labels" or "Incomplete", sorted.
+func (e LogEvent) Keys() []string {
+	keys := make([]string, 0, len(e))
+	for _, key := range coreKeys {
+		if _, ok := e[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	core := len(keys)
+	for key := range e {
+		if !isCoreKey(key) {
+			keys = append(keys, key)
+		}
+	}
+	rest := keys[core:]
+	sort.Slice(rest, func(i, j int) bool {
+		if fi, fj := eventFieldKeys[rest[i]], eventFieldKeys[rest[j]]; fi != fj {
+			return fj
+		}
+		return rest[i] < rest[j]
+	})
+	return keys
+}
+
+func isCoreKey(key string) bool {
+	for _, core := range coreKeys {
+		if key == core {
+			return true
+		}
+	}
+	return false
+}
+
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case LogEvent:
+		return v.Clone()
+	case map[string]interface{}:
+		return map[string]interface{}(LogEvent(v).Clone())
+	case map[string]string:
+		m := make(map[string]string, len(v))
+		for k, s := range v {
+			m[k] = s
+		}
+		return m
+	case []string:
+		return append([]string(nil), v...)
+	case []Statement:
+		return append([]Statement(nil), v...)
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, x := range v {
+			s[i] = cloneValue(x)
+		}
+		return s
+	}
+	return v
+}
+
+// Equal reports whether e and other have the same keys with equal values.
+// Numbers are compared by value regardless of type, so int64(1) equals
+// float64(1). time.Time values are compared with time.Time.Equal, ignoring
+// the location. Nested maps and slices are compared element by element
+// with the same rules.
+func (e LogEvent) Equal(other LogEvent) bool {
+	if len(e) != len(other) {
+		return false
+	}
+	for k, v := range e {
+		w, ok := other[k]
+		if !ok || !valuesEqual(v, w) {
+			return false
+		}
+	}
+	return true
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	switch a := a.(type) {
+	case time.Time:
+		b, ok := b.(time.Time)
+		return ok && a.Equal(b)
+	case LogEvent:
+		b, ok := toEvent(b)
+		return ok && a.Equal(b)
+	case map[string]interface{}:
+		b, ok := toEvent(b)
+		return ok && LogEvent(a).Equal(b)
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !valuesEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case time.Duration:
+		return n.Seconds(), true
+	}
+	return 0, false
+}
+
+func toEvent(v interface{}) (LogEvent, bool) {
+	switch m := v.(type) {
+	case LogEvent:
+		return m, true
+	case map[string]interface{}:
+		return LogEvent(m), true
+	}
+	return nil, false
+}
+
+// Diff returns one line per key whose value differs between a and b,
+// using the rules of LogEvent.Equal, sorted by key. Each line looks like
+// "Query_time: 0.1 != 0.2", with "<missing>" for absent keys.
+// Diff returns nil if the events are equal.
+func Diff(a, b LogEvent) []string {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var diffs []string
+	for _, k := range sorted {
+		v, inA := a[k]
+		w, inB := b[k]
+		if inA && inB && valuesEqual(v, w) {
+			continue
+		}
+		diffs = append(diffs, fmt.Sprintf("%s: %s != %s", k, diffValue(v, inA), diffValue(w, inB)))
+	}
+	return diffs
+}
+
+func diffValue(v interface{}, ok bool) string {
+	if !ok {
+		return "<missing>"
+	}
+	return fmt.Sprintf("%#v", v)
+}
+
+// Canonical returns e as text for tests and diffs, a "key: value" line
+// per attribute sorted by key, so expectations can be written as
+// strings and failures compared line by line. Events that are Equal
+// have the same text:
+//
+//	Id: 3
+//	Labels.env: "prod"
+//	Query_time: 0.5
+//	Statement: "SELECT 1"
+//	Tables: ["t"]
+//	Timestamp: 2018-03-01T10:00:00Z
+//
+// Strings are quoted as in Go, numbers are written the same whether
+// they're int64 or float64, without exponents for most, and times in
+// UTC as RFC 3339. Durations are written as by time.Duration.String,
+// and other Stringers as their quoted String. Every attribute is
+// included: maps, such as "Labels" and "Meta", have a line for each of
+// their keys after a dot, in order, and lists of scalars are written on
+// their line, while lists of maps or structs, such as "Statements",
+// have lines for each element after its index, as in
+// "Statements[0].Text".
+func (e LogEvent) Canonical() string {
+	if len(e) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	writeCanonical(&b, "", reflect.ValueOf(map[string]interface{}(e)))
+	return b.String()
+}
+
+// writeCanonical writes the lines of v with key, or of its elements
+// with key as the prefix for maps, structs and lists of them.
+func writeCanonical(b *strings.Builder, key string, v reflect.Value) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			canonicalLine(b, key, "null")
+			return
+		}
+		v = v.Elem()
+	}
+	if s, ok := canonicalScalar(v); ok {
+		canonicalLine(b, key, s)
+		return
+	}
+	sep := "."
+	if key == "" {
+		sep = ""
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Len() == 0 {
+			canonicalLine(b, key, "{}")
+			return
+		}
+		keys := make([]string, 0, v.Len())
+		values := map[string]reflect.Value{}
+		for _, k := range v.MapKeys() {
+			name := fmt.Sprint(k.Interface())
+			keys = append(keys, name)
+			values[name] = v.MapIndex(k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeCanonical(b, key+sep+k, values[k])
+		}
+	case reflect.Struct:
+		t := v.Type()
+		var fields []string
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath == "" {
+				fields = append(fields, t.Field(i).Name)
+			}
+		}
+		sort.Strings(fields)
+		for _, f := range fields {
+			writeCanonical(b, key+sep+f, v.FieldByName(f))
+		}
+	case reflect.Slice, reflect.Array:
+		items := make([]string, v.Len())
+		for i := range items {
+			s, ok := canonicalScalar(indirect(v.Index(i)))
+			if !ok {
+				for i := 0; i < v.Len(); i++ {
+					writeCanonical(b, fmt.Sprintf("%s[%d]", key, i), v.Index(i))
+				}
+				return
+			}
+			items[i] = s
+		}
+		canonicalLine(b, key, "["+strings.Join(items, ", ")+"]")
+	default:
+		canonicalLine(b, key, fmt.Sprintf("%v", v.Interface()))
+	}
+}
+
+// indirect returns the value v holds, if it's an interface.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+func canonicalLine(b *strings.Builder, key, value string) {
+	b.WriteString(key)
+	b.WriteString(": ")
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// canonicalScalar returns the text of v for Canonical, and false if it
+// isn't a single value.
+func canonicalScalar(v reflect.Value) (string, bool) {
+	if !v.IsValid() {
+		return "null", true
+	}
+	if (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr || v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.IsNil() {
+		return "null", true
+	}
+	if v.CanInterface() {
+		switch x := v.Interface().(type) {
+		case time.Time:
+			return x.UTC().Format(time.RFC3339Nano), true
+		case time.Duration:
+			return x.String(), true
+		case []byte:
+			return strconv.Quote(string(x)), true
+		case fmt.Stringer:
+			return strconv.Quote(x.String()), true
+		}
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String()), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return canonicalFloat(v.Float()), true
+	}
+	return "", false
+}
+
+// canonicalFloat writes f in the shortest form that reads back the
+// same, without an exponent unless it's very large or small.
+func canonicalFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	if abs := math.Abs(f); f != 0 && (abs < 1e-6 || abs >= 1e21) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
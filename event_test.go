@@ -0,0 +1,194 @@
+package mysqllog
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogEventClone(t *testing.T) {
+	e := LogEvent{
+		"User":       "app",
+		"Query_time": 1.5,
+		"Labels":     map[string]string{"host": "db-1"},
+		"Tables":     []string{"t1"},
+	}
+	clone := e.Clone()
+	if !reflect.DeepEqual(e, clone) {
+		t.Fatalf("expected clone %v to equal %v", clone, e)
+	}
+
+	clone["User"] = "other"
+	clone["Labels"].(map[string]string)["host"] = "db-2"
+	clone["Tables"].([]string)[0] = "t2"
+	if e["User"] != "app" || e["Labels"].(map[string]string)["host"] != "db-1" || e["Tables"].([]string)[0] != "t1" {
+		t.Errorf("mutating the clone changed the original: %v", e)
+	}
+
+	if LogEvent(nil).Clone() != nil {
+		t.Error("expected nil clone of nil event")
+	}
+}
+
+func TestLogEventEqual(t *testing.T) {
+	ts := time.Unix(1514083320, 0)
+
+	type TestCase struct {
+		A, B     LogEvent
+		Expected bool
+	}
+
+	cases := []TestCase{
+		{A: LogEvent{"Rows_sent": int64(1)}, B: LogEvent{"Rows_sent": float64(1)}, Expected: true},
+		{A: LogEvent{"Rows_sent": int64(1)}, B: LogEvent{"Rows_sent": int64(2)}, Expected: false},
+		{A: LogEvent{"Rows_sent": int64(1)}, B: LogEvent{"Rows_sent": "1"}, Expected: false},
+		{A: LogEvent{"Timestamp": ts}, B: LogEvent{"Timestamp": ts.UTC()}, Expected: true},
+		{A: LogEvent{"User": "app"}, B: LogEvent{"User": "app", "Host": "h"}, Expected: false},
+		{A: LogEvent{"User": "app", "Host": "h"}, B: LogEvent{"User": "app", "Other": "h"}, Expected: false},
+		{
+			A:        LogEvent{"Labels": map[string]string{"a": "b"}},
+			B:        LogEvent{"Labels": map[string]string{"a": "b"}},
+			Expected: true,
+		},
+		{
+			A:        LogEvent{"Meta": map[string]interface{}{"n": int64(3)}},
+			B:        LogEvent{"Meta": map[string]interface{}{"n": 3.0}},
+			Expected: true,
+		},
+	}
+
+	for _, c := range cases {
+		if result := c.A.Equal(c.B); result != c.Expected {
+			t.Errorf("expected %v.Equal(%v) to be %v", c.A, c.B, c.Expected)
+		}
+		if result := c.B.Equal(c.A); result != c.Expected {
+			t.Errorf("expected %v.Equal(%v) to be %v", c.B, c.A, c.Expected)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := LogEvent{"User": "app", "Query_time": 0.1, "Rows_sent": int64(1)}
+	b := LogEvent{"User": "app", "Query_time": 0.2, "Rows_sent": 1.0, "Host": "h"}
+
+	expected := []string{
+		`Host: <missing> != "h"`,
+		"Query_time: 0.1 != 0.2",
+	}
+	if result := Diff(a, b); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+	if result := Diff(a, a.Clone()); result != nil {
+		t.Errorf("expected no differences, got %q", result)
+	}
+}
+
+// lineDiff returns the lines of want and got that differ, prefixed with
+// "-" and "+", for failures comparing Canonical texts.
+func lineDiff(want, got string) string {
+	a, b := strings.SplitAfter(want, "\n"), strings.SplitAfter(got, "\n")
+	// lcs[i][j] is the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var diff strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i, j = i+1, j+1
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			diff.WriteString("-" + strings.TrimSuffix(a[i], "\n") + "\n")
+			i++
+		default:
+			diff.WriteString("+" + strings.TrimSuffix(b[j], "\n") + "\n")
+			j++
+		}
+	}
+	return diff.String()
+}
+
+func TestCanonical(t *testing.T) {
+	type TestCase struct {
+		Event    LogEvent
+		Expected string
+	}
+	ts := time.Date(2018, 3, 1, 10, 0, 0, 123456000, time.FixedZone("CET", 3600))
+	cases := []TestCase{
+		{nil, ""},
+		{LogEvent{}, ""},
+		{LogEvent{"Statement": "SELECT 'a'\n  FROM t", "User": ""}, "Statement: \"SELECT 'a'\\n  FROM t\"\nUser: \"\"\n"},
+		// Numbers read the same whatever their type.
+		{LogEvent{"Rows_sent": int64(3), "Rows_examined": 3.0, "Query_time": 0.5}, "Query_time: 0.5\nRows_examined: 3\nRows_sent: 3\n"},
+		{LogEvent{"A": 1e-9, "B": 1e21, "C": -2.5, "D": 0.0, "E": 123456789.125}, "A: 1e-09\nB: 1e+21\nC: -2.5\nD: 0\nE: 123456789.125\n"},
+		{LogEvent{"A": math.NaN(), "B": math.Inf(1), "C": math.Inf(-1)}, "A: NaN\nB: +Inf\nC: -Inf\n"},
+		{LogEvent{"A": 7, "B": int32(-7), "C": uint64(7), "D": float32(0.5)}, "A: 7\nB: -7\nC: 7\nD: 0.5\n"},
+		// Times are in UTC.
+		{LogEvent{"Timestamp": ts}, "Timestamp: 2018-03-01T09:00:00.123456Z\n"},
+		{LogEvent{"Query_time": 1500 * time.Millisecond, "Full_scan": true, "Missing": nil}, "Full_scan: true\nMissing: null\nQuery_time: 1.5s\n"},
+		{LogEvent{"Severity": SeverityCritical, "Dialect": TiDB}, "Dialect: \"" + TiDB.String() + "\"\nSeverity: \"" + string(SeverityCritical) + "\"\n"},
+		// Nested maps have a line per key.
+		{LogEvent{"Labels": map[string]string{"env": "prod", "az": "b"}, "Meta": map[string]interface{}{"app": "web", "nested": LogEvent{"n": int64(1)}}},
+			"Labels.az: \"b\"\nLabels.env: \"prod\"\nMeta.app: \"web\"\nMeta.nested.n: 1\n"},
+		{LogEvent{"Labels": map[string]string{}}, "Labels: {}\n"},
+		// Lists of scalars are on one line, others one per element.
+		{LogEvent{"Tables": []string{"a", "b"}, "Empty": []string{}, "Mixed": []interface{}{"a", int64(1), 2.5}}, "Empty: []\nMixed: [\"a\", 1, 2.5]\nTables: [\"a\", \"b\"]\n"},
+		{LogEvent{"Statements": []Statement{{Text: "SELECT 1", Database: "a"}, {Text: "SELECT 2"}}},
+			"Statements[0].Database: \"a\"\nStatements[0].Text: \"SELECT 1\"\nStatements[1].Database: \"\"\nStatements[1].Text: \"SELECT 2\"\n"},
+		{LogEvent{"List": []interface{}{map[string]interface{}{"a": 1.0}, "b"}}, "List[0].a: 1\nList[1]: \"b\"\n"},
+		{LogEvent{"Raw": []byte(`{"v":1}`)}, "Raw: \"{\\\"v\\\":1}\"\n"},
+	}
+	for _, c := range cases {
+		if got := c.Event.Canonical(); got != c.Expected {
+			t.Errorf("unexpected text of %#v:\n%s", c.Event, lineDiff(c.Expected, got))
+		}
+	}
+
+	// Equal events have the same text.
+	a := LogEvent{"Rows_sent": int64(1), "Timestamp": ts, "Labels": LogEvent{"env": "prod"}}
+	b := LogEvent{"Rows_sent": 1.0, "Timestamp": ts.UTC(), "Labels": map[string]interface{}{"env": "prod"}}
+	if !a.Equal(b) || a.Canonical() != b.Canonical() {
+		t.Errorf("expected equal events to have the same text:\n%s", lineDiff(a.Canonical(), b.Canonical()))
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	got := lineDiff("A: 1\nB: 2\nC: 3\n", "A: 1\nB: 4\nC: 3\nD: 5\n")
+	if expected := "-B: 2\n+B: 4\n+D: 5\n"; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	e := LogEvent{
+		"Statement":   "SELECT 1",
+		"Labels":      map[string]string{"env": "prod"},
+		"Full_scan":   true,
+		"Query_time":  1.5,
+		"Zebra":       "z",
+		"Timestamp":   "2023-08-01 10:00:00",
+		"Thread_id":   int64(5),
+		"Fingerprint": "select ?",
+	}
+	expected := []string{"Timestamp", "Query_time", "Statement", "Full_scan", "Thread_id", "Zebra", "Fingerprint", "Labels"}
+	if keys := e.Keys(); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %q, got %q", expected, keys)
+	}
+	if keys := (LogEvent{}).Keys(); len(keys) != 0 {
+		t.Errorf("expected no keys, got %q", keys)
+	}
+}
@@ -0,0 +1,70 @@
+package mysqllog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"hash/fnv"
+	"strconv"
+)
+
+// WithSourceID identifies the input in the "EventID" of each event, in
+// place of the identity of its file: see EventID.
+func WithSourceID(id string) Option {
+	return func(p *Parser) {
+		p.sourceID = id
+	}
+}
+
+// EventID returns the ID of e, a stable identifier for idempotent writes
+// to external systems, such as a primary or document key, so processing
+// a file again after a crash doesn't duplicate rows. It's the "EventID"
+// set by a Parser with WithOffsets: a hash of the identity of the input,
+// the byte offset of the event and its raw header lines. It's the same
+// every time the same file is read, while two events of a file never
+// share it, even with the same timestamp and statement.
+//
+// The identity of the input is that of WithSourceID, or the FileIdentity
+// of the file for ParseFile and TailFile, falling back to its path, or
+// the source of a Collector. For events without an "EventID" but with
+// an "Offset", such as events decoded from JSON, it's computed from
+// their "SourceFile" or "Source", the offset and the text WriteSlowLog
+// writes for them. It's "" for events without an offset.
+func EventID(e LogEvent) string {
+	if id, ok := e["EventID"].(string); ok && id != "" {
+		return id
+	}
+	offset, ok := e.Int64("Offset")
+	if !ok {
+		return ""
+	}
+	source, _ := e["SourceFile"].(string)
+	if source == "" {
+		source, _ = e["Source"].(string)
+	}
+	var text bytes.Buffer
+	WriteSlowLog(&text, e)
+	return eventID(source, offset, []string{text.String()})
+}
+
+// eventID hashes the identity of an input, an offset in it and the
+// header lines of the event there.
+func eventID(source string, offset int64, header []string) string {
+	h := fnv.New128a()
+	h.Write([]byte(source))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(offset, 10)))
+	for _, line := range header {
+		h.Write([]byte{0})
+		h.Write([]byte(line))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// setID sets "EventID" on e for WithOffsets, from the pending lines.
+func (p *Parser) setID(e LogEvent) {
+	source := p.sourceID
+	if source == "" {
+		source = p.inputID
+	}
+	e["EventID"] = eventID(source, p.eventOffset, p.lines[:headerLines(p.lines)])
+}
@@ -0,0 +1,68 @@
+package mysqllog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEventID(t *testing.T) {
+	parse := func(path string, opts ...Option) []string {
+		var ids []string
+		err := ParseFile(path, func(e LogEvent) {
+			ids = append(ids, EventID(e))
+		}, WithParser(NewParser(append(opts, WithOffsets())...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+
+	// Stable across runs over the same file, and unique within it.
+	first, second := parse("./_test/rds.txt"), parse("./_test/rds.txt")
+	if len(first) == 0 || strings.Join(first, ",") != strings.Join(second, ",") {
+		t.Fatalf("expected the same IDs for the same file, got %q and %q", first, second)
+	}
+	seen := map[string]bool{}
+	for _, id := range first {
+		if id == "" || seen[id] {
+			t.Errorf("expected unique IDs, got %q", first)
+		}
+		seen[id] = true
+	}
+
+	// A byte-identical event at another offset, or in another file, has
+	// another ID.
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	entry := "# Time: 2018-03-01T10:00:00.000000Z\n# User@Host: app[app] @ localhost []  Id: 1\n# Query_time: 1.0  Lock_time: 0.0 Rows_sent: 1  Rows_examined: 1\nSET timestamp=1519898400;\nSELECT 1;\n"
+	a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+	if err := ioutil.WriteFile(a, []byte(entry+entry), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte(entry), 0644); err != nil {
+		t.Fatal(err)
+	}
+	twice, other := parse(a), parse(b)
+	if len(twice) != 2 || len(other) != 1 || twice[0] == twice[1] || twice[0] == other[0] {
+		t.Errorf("expected different IDs for the same event at other offsets, got %q and %q", twice, other)
+	}
+	if named := parse(b, WithSourceID("db1")); named[0] == other[0] || named[0] != parse(a, WithSourceID("db1"))[0] {
+		t.Errorf("expected WithSourceID to identify the input in place of the file")
+	}
+
+	// Events without one get it from their source and offset.
+	e := LogEvent{"SourceFile": "slow.log", "Offset": int64(10), "Statement": "SELECT 1"}
+	moved := LogEvent{"SourceFile": "slow.log", "Offset": float64(20), "Statement": "SELECT 1"}
+	if id := EventID(e); id == "" || id != EventID(e.Clone()) || id == EventID(moved) {
+		t.Errorf("unexpected computed IDs %q and %q", id, EventID(moved))
+	}
+	if id := EventID(LogEvent{"Statement": "SELECT 1"}); id != "" {
+		t.Errorf("expected no ID without an offset, got %q", id)
+	}
+}
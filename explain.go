@@ -0,0 +1,413 @@
+package mysqllog
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for the EXPLAIN cache of WithExplain.
+const (
+	DefaultExplainCacheTTL  = 10 * time.Minute
+	DefaultExplainCacheSize = 1000
+)
+
+// ErrNotExplainable is returned for statements that are not explained
+// because they aren't a SELECT, UPDATE or DELETE, or could have side
+// effects outside the database.
+var ErrNotExplainable = errors.New("mysqllog: statement can't be safely explained")
+
+// ErrExplainThrottled is returned for statements not explained because
+// of WithExplainRate or WithExplainConcurrency.
+var ErrExplainThrottled = errors.New("mysqllog: EXPLAIN throttled")
+
+// ErrExplainPaused is returned for statements not explained because
+// WithExplainBreaker paused explaining.
+var ErrExplainPaused = errors.New("mysqllog: EXPLAIN paused")
+
+// WithExplain runs EXPLAIN FORMAT=JSON for the sample statement of each
+// reported fingerprint against db and includes the plan in the report.
+// Each EXPLAIN runs inside a read-only transaction with the sample's
+// Database as the default database, and is cancelled after timeout.
+// Only SELECT, UPDATE and DELETE statements are explained; statements
+// writing files (INTO OUTFILE/DUMPFILE) or containing several statements
+// are refused. Failures, such as a table that no longer exists, are
+// noted in the report instead of the plan.
+//
+// To spare the server, plans are cached by fingerprint and database
+// for DefaultExplainCacheTTL unless WithExplainCache says otherwise, and
+// reports note the plans from the cache, which may be stale. The cache
+// is kept by the returned option, so reusing it for several reports
+// reuses the plans too. Limits and a circuit breaker can be set with
+// opts.
+//
+// Selecting the database changes the default database of pooled
+// connections, so db should be dedicated to explaining.
+func WithExplain(db *sql.DB, timeout time.Duration, opts ...ExplainOption) ReportOption {
+	x := &explainer{
+		db:        db,
+		timeout:   timeout,
+		cacheTTL:  DefaultExplainCacheTTL,
+		cacheSize: DefaultExplainCacheSize,
+	}
+	for _, opt := range opts {
+		opt(x)
+	}
+	x.clock = clockOf(x.clock)
+	if x.concurrency > 0 {
+		x.slots = make(chan struct{}, x.concurrency)
+	}
+	if x.rate > 0 {
+		x.started = timeRing{times: make([]time.Time, x.rate)}
+	}
+	x.cache = map[string]*list.Element{}
+	x.cacheOrder = list.New()
+	return func(o *reportOptions) {
+		o.explain = x
+	}
+}
+
+// ExplainOption configures the EXPLAINs of WithExplain.
+type ExplainOption func(*explainer)
+
+// WithExplainCache keeps plans, and failures to explain, for ttl, up to
+// size fingerprints, forgetting the oldest first. A ttl of zero or less
+// explains every time.
+func WithExplainCache(ttl time.Duration, size int) ExplainOption {
+	return func(x *explainer) {
+		x.cacheTTL, x.cacheSize = ttl, size
+	}
+}
+
+// WithExplainConcurrency runs at most n EXPLAINs at a time across the
+// reports sharing the option. Others wait for their turn within the
+// timeout, and fail with ErrExplainThrottled after it. Zero or less, the
+// default, doesn't limit them.
+func WithExplainConcurrency(n int) ExplainOption {
+	return func(x *explainer) {
+		x.concurrency = n
+	}
+}
+
+// WithExplainRate runs at most n EXPLAINs in any period of length per;
+// statements beyond it fail with ErrExplainThrottled. Zero or less, the
+// default, doesn't limit them.
+func WithExplainRate(n int, per time.Duration) ExplainOption {
+	return func(x *explainer) {
+		x.rate, x.ratePeriod = n, per
+	}
+}
+
+// WithExplainBreaker stops explaining for cooldown after failures
+// consecutive EXPLAINs failed, or after one took longer than latency,
+// failing with ErrExplainPaused in the meantime. The first EXPLAIN after
+// the pause trips it again if it fails. A zero failures or latency
+// doesn't check them; the default is no breaker.
+func WithExplainBreaker(failures int, latency, cooldown time.Duration) ExplainOption {
+	return func(x *explainer) {
+		x.maxErrors, x.maxLatency, x.cooldown = failures, latency, cooldown
+	}
+}
+
+// WithExplainClock tells the time for the cache, the rate limit and the
+// breaker with c in place of RealClock.
+func WithExplainClock(c Clock) ExplainOption {
+	return func(x *explainer) {
+		x.clock = c
+	}
+}
+
+type explainer struct {
+	db      *sql.DB
+	timeout time.Duration
+
+	cacheTTL    time.Duration
+	cacheSize   int
+	concurrency int
+	rate        int
+	ratePeriod  time.Duration
+	maxErrors   int
+	maxLatency  time.Duration
+	cooldown    time.Duration
+	clock       Clock
+
+	slots chan struct{}
+
+	mu         sync.Mutex
+	cache      map[string]*list.Element
+	cacheOrder *list.List
+	started    timeRing
+	errors     int
+	pausedTill time.Time
+}
+
+// explainEntry is a cached EXPLAIN.
+type explainEntry struct {
+	key  string
+	plan string
+	err  error
+	at   time.Time
+}
+
+// explainable reports whether statement may be explained. The checks
+// are on the statement itself rather than its fingerprint, which drops
+// the executable comments the server runs.
+func explainable(statement string) bool {
+	text, executable := statementText(statement)
+	if executable {
+		return false
+	}
+	verb := text
+	if i := strings.IndexByte(verb, ' '); i >= 0 {
+		verb = verb[:i]
+	}
+	switch verb {
+	case "select", "update", "delete":
+	default:
+		return false
+	}
+	return !strings.Contains(text, ";") && !hasKeyword(text, "into outfile", "into dumpfile")
+}
+
+// statementText returns the tokens of statement in lower case, separated
+// by spaces, with string literals and quoted identifiers as "?" and
+// comments dropped, so checks on it aren't fooled by them or by spacing.
+// executable is set if statement has an executable comment, such as
+// "/*!50000 ... */" or MariaDB's "/*M! ... */", which the server runs,
+// as found by the same scan as the tokens.
+func statementText(statement string) (text string, executable bool) {
+	tokens, executable := lex(statement)
+	words := make([]string, len(tokens))
+	for i, t := range tokens {
+		if t.kind == tokenString || t.kind == tokenIdent {
+			words[i] = "?"
+		} else {
+			words[i] = strings.ToLower(t.text)
+		}
+	}
+	return strings.Join(words, " "), executable
+}
+
+// hasKeyword reports whether text, a fingerprint or the statementText
+// of a statement, has any of keywords as words.
+func hasKeyword(text string, keywords ...string) bool {
+	text = " " + text + " "
+	for _, keyword := range keywords {
+		if strings.Contains(text, " "+keyword+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// explained is the outcome of explaining a statement.
+type explained struct {
+	plan string
+	err  error
+	// cached is set for plans from the cache, which are age old.
+	cached bool
+	age    time.Duration
+}
+
+// heading returns the line introducing the plan in reports, or telling
+// why there's none.
+func (r explained) heading() string {
+	heading := "EXPLAIN"
+	if r.cached {
+		heading += " (cached " + r.age.String() + " ago, may be stale)"
+	}
+	switch r.err {
+	case nil:
+		return heading
+	case ErrNotExplainable:
+		return "EXPLAIN skipped"
+	case ErrExplainThrottled:
+		return "EXPLAIN skipped: throttled"
+	case ErrExplainPaused:
+		return "EXPLAIN skipped: paused after failures"
+	}
+	return heading + " failed: " + r.err.Error()
+}
+
+// explain explains the statement of e, or returns the plan cached for
+// its fingerprint.
+func (x *explainer) explain(ctx context.Context, e LogEvent) explained {
+	statement, _ := e["Statement"].(string)
+	statement = strings.TrimRight(strings.TrimSpace(statement), "; \t\n")
+	if !explainable(statement) {
+		return explained{err: ErrNotExplainable}
+	}
+	db, _ := e["Database"].(string)
+	key := Checksum(Fingerprint(statement)) + " " + db
+	if entry, ok := x.cached(key); ok {
+		return explained{plan: entry.plan, err: entry.err, cached: true, age: x.clock.Now().Sub(entry.at)}
+	}
+	if err := x.admit(); err != nil {
+		return explained{err: err}
+	}
+	if x.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, x.timeout)
+		defer cancel()
+	}
+	if x.slots != nil {
+		select {
+		case x.slots <- struct{}{}:
+			defer func() { <-x.slots }()
+		case <-ctx.Done():
+			return explained{err: ErrExplainThrottled}
+		}
+	}
+	start := x.clock.Now()
+	plan, err := x.query(ctx, statement, db)
+	x.done(key, plan, err, start)
+	return explained{plan: plan, err: err}
+}
+
+// cached returns the cache entry for key if it's fresh.
+func (x *explainer) cached(key string) (*explainEntry, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	el, ok := x.cache[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*explainEntry)
+	if x.clock.Now().Sub(entry.at) >= x.cacheTTL {
+		x.cacheOrder.Remove(el)
+		delete(x.cache, key)
+		return nil, false
+	}
+	return entry, true
+}
+
+// admit checks the breaker and the rate limit before an EXPLAIN.
+func (x *explainer) admit() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	now := x.clock.Now()
+	if now.Before(x.pausedTill) {
+		return ErrExplainPaused
+	}
+	if x.rate > 0 {
+		if x.started.full() && now.Sub(x.started.oldest()) < x.ratePeriod {
+			return ErrExplainThrottled
+		}
+		x.started.push(now)
+	}
+	return nil
+}
+
+// done records the outcome of an EXPLAIN started at start.
+func (x *explainer) done(key, plan string, err error, start time.Time) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	now := x.clock.Now()
+	switch {
+	case x.maxLatency > 0 && now.Sub(start) > x.maxLatency,
+		err != nil && x.maxErrors > 0 && x.errors+1 >= x.maxErrors:
+		x.pausedTill = now.Add(x.cooldown)
+		// One more failure after the pause trips it again.
+		x.errors = 0
+		if x.maxErrors > 0 {
+			x.errors = x.maxErrors - 1
+		}
+	case err != nil:
+		x.errors++
+	default:
+		x.errors = 0
+	}
+	if x.cacheTTL <= 0 || x.cacheSize <= 0 {
+		return
+	}
+	if el, ok := x.cache[key]; ok {
+		x.cacheOrder.Remove(el)
+	}
+	for x.cacheOrder.Len() >= x.cacheSize {
+		oldest := x.cacheOrder.Back()
+		x.cacheOrder.Remove(oldest)
+		delete(x.cache, oldest.Value.(*explainEntry).key)
+	}
+	x.cache[key] = x.cacheOrder.PushFront(&explainEntry{key: key, plan: plan, err: err, at: now})
+}
+
+// query runs the EXPLAIN of statement with db as the default database.
+func (x *explainer) query(ctx context.Context, statement, db string) (plan string, err error) {
+	err = queryReadOnly(ctx, x.db, db, "EXPLAIN FORMAT=JSON "+statement, func(rows *sql.Rows) error {
+		plan, err = formatRows(rows)
+		return err
+	})
+	return plan, err
+}
+
+// queryReadOnly runs query on db inside a read-only transaction, with
+// database as the default database if it's set, and calls fn with its
+// rows.
+func queryReadOnly(ctx context.Context, db *sql.DB, database, query string, fn func(*sql.Rows) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if database != "" {
+		if _, err := tx.ExecContext(ctx, "USE "+quoteIdentifier(database)); err != nil {
+			return err
+		}
+	}
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	return fn(rows)
+}
+
+// formatRows renders a result set as tab-separated lines. A single
+// value, such as a JSON plan, is returned as is.
+func formatRows(rows *sql.Rows) (string, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		fields := make([]string, len(values))
+		for i, v := range values {
+			if v.Valid {
+				fields[i] = v.String
+			} else {
+				fields[i] = "NULL"
+			}
+		}
+		lines = append(lines, strings.Join(fields, "\t"))
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if len(columns) == 1 && len(lines) == 1 {
+		return lines[0], nil
+	}
+	return strings.Join(append([]string{strings.Join(columns, "\t")}, lines...), "\n"), nil
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
@@ -0,0 +1,372 @@
+package mysqllog
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDriver is a database/sql driver that records queries and answers
+// EXPLAIN with a canned plan, or an error for tables named "missing".
+// Queries are answered by rows instead if it's set, and those wait is
+// true for block until they're cancelled.
+type fakeDriver struct {
+	mu       sync.Mutex
+	queries  []string
+	readOnly []bool
+	rows     func(query string) (*fakeRows, error)
+	wait     func(query string) bool
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d: d}, nil
+}
+
+func (d *fakeDriver) record(query string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries = append(d.queries, query)
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.d.mu.Lock()
+	c.d.readOnly = append(c.d.readOnly, opts.ReadOnly)
+	c.d.mu.Unlock()
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.record(query)
+	if c.d.wait != nil && c.d.wait(query) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if c.d.rows != nil {
+		return c.d.rows(query)
+	}
+	if strings.Contains(query, "missing") {
+		return nil, errors.New("Error 1146: Table 'app.missing' doesn't exist")
+	}
+	return &fakeRows{columns: []string{"EXPLAIN"}, values: [][]driver.Value{{`{"query_block": {}}`}}}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	types   []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) ColumnTypeDatabaseTypeName(i int) string {
+	if i < len(r.types) {
+		return r.types[i]
+	}
+	return ""
+}
+
+func (r *fakeRows) Close() error { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var registerFakeDriver sync.Once
+
+func openFakeDB(t *testing.T) (*sql.DB, *fakeDriver) {
+	return openNamedFakeDB(t, t.Name())
+}
+
+// openNamedFakeDB opens a fake database of its own for each name.
+func openNamedFakeDB(t *testing.T, name string) (*sql.DB, *fakeDriver) {
+	d := &fakeDriver{}
+	registerFakeDriver.Do(func() {
+		sql.Register("mysqllog-fake", fakeDriverProxy{})
+	})
+	fakeDrivers.Store(name, d)
+	db, err := sql.Open("mysqllog-fake", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, d
+}
+
+// fakeDrivers maps data source names to the fakeDriver handling them,
+// since sql.Register can only be called once per driver name.
+var fakeDrivers sync.Map
+
+type fakeDriverProxy struct{}
+
+func (fakeDriverProxy) Open(name string) (driver.Conn, error) {
+	d, ok := fakeDrivers.Load(name)
+	if !ok {
+		return nil, errors.New("unknown fake database " + name)
+	}
+	return d.(*fakeDriver).Open(name)
+}
+
+func TestExplainable(t *testing.T) {
+	type TestCase struct {
+		Statement string
+		Expected  bool
+	}
+
+	cases := []TestCase{
+		{Statement: "SELECT * FROM t", Expected: true},
+		{Statement: "/* app */ select * from t where x = 'into outfile'", Expected: true},
+		{Statement: "UPDATE t SET x = 1", Expected: true},
+		{Statement: "DELETE FROM t", Expected: true},
+		{Statement: "INSERT INTO t VALUES (1)", Expected: false},
+		{Statement: "DROP TABLE t", Expected: false},
+		{Statement: "SELECT * FROM t INTO OUTFILE '/tmp/x'", Expected: false},
+		{Statement: "SELECT * FROM t INTO DUMPFILE '/tmp/x'", Expected: false},
+		{Statement: "SELECT 1; DROP TABLE t", Expected: false},
+		{Statement: "SELECT * FROM t INTO /* x */\n OUTFILE '/tmp/x'", Expected: false},
+		{Statement: "SELECT 1 /*!; DROP TABLE t */", Expected: false},
+		{Statement: "SELECT * FROM t /*!50000 INTO OUTFILE '/tmp/x' */", Expected: false},
+		{Statement: "SELECT * FROM t /*M!100000 INTO DUMPFILE '/tmp/x' */", Expected: false},
+		{Statement: "/*!40001 SQL_NO_CACHE */ SELECT * FROM t", Expected: false},
+		{Statement: "SELECT * FROM t /* don't */ WHERE x = ';'", Expected: true},
+		{Statement: "SELECT * FROM t WHERE x = '/*!50000 */' AND `a;b` = 1", Expected: true},
+		{Statement: "SELECT /*+ NO_INDEX(t) */ * FROM t", Expected: true},
+		{Statement: "select 1 from `t\\` /*!50000 union select * from mysql.user into outfile '/tmp/x' */ ` ", Expected: false},
+	}
+
+	for _, c := range cases {
+		if result := explainable(c.Statement); result != c.Expected {
+			t.Errorf("expected explainable(%q) to be %v", c.Statement, c.Expected)
+		}
+	}
+}
+
+func TestWriteReportWithExplain(t *testing.T) {
+	db, d := openFakeDB(t)
+	defer db.Close()
+
+	results := aggregate([]LogEvent{
+		{"Statement": "SELECT * FROM orders WHERE id = 1;", "Query_time": 3.0, "Database": "app"},
+		{"Statement": "SELECT * FROM missing", "Query_time": 2.0, "Database": "app"},
+		{"Statement": "INSERT INTO t VALUES (1)", "Query_time": 1.0},
+	})
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, results, WithExplain(db, time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	report := buf.String()
+	for _, expected := range []string{
+		"# EXPLAIN\n{\"query_block\": {}}\n",
+		"# EXPLAIN failed: Error 1146: Table 'app.missing' doesn't exist\n",
+		"# EXPLAIN skipped\n",
+	} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("expected report to contain %q, got\n%s", expected, report)
+		}
+	}
+
+	expectedQueries := []string{
+		"USE `app`",
+		"EXPLAIN FORMAT=JSON SELECT * FROM orders WHERE id = 1",
+		"USE `app`",
+		"EXPLAIN FORMAT=JSON SELECT * FROM missing",
+	}
+	if strings.Join(d.queries, "\n") != strings.Join(expectedQueries, "\n") {
+		t.Errorf("expected queries %q, got %q", expectedQueries, d.queries)
+	}
+	for _, readOnly := range d.readOnly {
+		if !readOnly {
+			t.Error("expected read-only transactions")
+		}
+	}
+}
+
+// explains returns the number of EXPLAINs d ran.
+func (d *fakeDriver) explains() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, query := range d.queries {
+		if strings.HasPrefix(query, "EXPLAIN") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestExplainCache(t *testing.T) {
+	db, d := openFakeDB(t)
+	defer db.Close()
+	clock := NewManualClock(time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC))
+	explain := WithExplain(db, time.Second, WithExplainCache(time.Hour, 10), WithExplainClock(clock))
+
+	results := aggregate([]LogEvent{
+		{"Statement": "SELECT * FROM orders WHERE id = 1", "Query_time": 3.0, "Database": "app"},
+		{"Statement": "SELECT * FROM missing", "Query_time": 2.0, "Database": "app"},
+		{"Statement": "SELECT * FROM customers", "Query_time": 1.0, "Database": "archive"},
+	})
+	report := func() string {
+		var buf bytes.Buffer
+		if err := WriteReport(&buf, results, explain); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	first := report()
+	if d.explains() != 3 || strings.Contains(first, "cached") {
+		t.Fatalf("expected 3 fresh EXPLAINs, got %d:\n%s", d.explains(), first)
+	}
+	clock.Add(90 * time.Second)
+	second := report()
+	if d.explains() != 3 {
+		t.Errorf("expected the cached plans to be reused, got %d EXPLAINs", d.explains())
+	}
+	for _, expected := range []string{
+		"# EXPLAIN (cached 1m30s ago, may be stale)\n{\"query_block\": {}}\n",
+		"# EXPLAIN (cached 1m30s ago, may be stale) failed: Error 1146",
+	} {
+		if !strings.Contains(second, expected) {
+			t.Errorf("expected report to contain %q, got\n%s", expected, second)
+		}
+	}
+	var html bytes.Buffer
+	if err := WriteHTMLReport(&html, results, explain); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "<p>EXPLAIN (cached 1m30s ago, may be stale)</p>") {
+		t.Errorf("expected the HTML report to note the cached plan, got\n%s", html.String())
+	}
+
+	clock.Add(time.Hour)
+	report()
+	if d.explains() != 6 {
+		t.Errorf("expected expired plans to be explained again, got %d EXPLAINs", d.explains())
+	}
+}
+
+func TestExplainLimits(t *testing.T) {
+	db, d := openFakeDB(t)
+	defer db.Close()
+	clock := NewManualClock(time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC))
+	o := newReportOptions([]ReportOption{WithExplain(db, time.Second,
+		WithExplainCache(0, 0), WithExplainRate(2, time.Minute), WithExplainClock(clock))})
+	event := func(id int) LogEvent {
+		return LogEvent{"Statement": "SELECT * FROM orders WHERE id = " + string(rune('0'+id))}
+	}
+	for i, want := range []error{nil, nil, ErrExplainThrottled, ErrExplainThrottled} {
+		if r := o.explain.explain(context.Background(), event(i)); r.err != want {
+			t.Errorf("EXPLAIN %d: expected %v, got %v", i, want, r.err)
+		}
+	}
+	clock.Add(time.Minute)
+	if r := o.explain.explain(context.Background(), event(5)); r.err != nil {
+		t.Errorf("expected an EXPLAIN after the period, got %v", r.err)
+	}
+	if d.explains() != 3 {
+		t.Errorf("expected 3 EXPLAINs, got %d", d.explains())
+	}
+
+	// With one EXPLAIN at a time, another waits for it up to the timeout.
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	d.rows = func(query string) (*fakeRows, error) {
+		if strings.Contains(query, "slow") {
+			started <- struct{}{}
+			<-release
+		}
+		return &fakeRows{columns: []string{"EXPLAIN"}, values: [][]driver.Value{{"{}"}}}, nil
+	}
+	o = newReportOptions([]ReportOption{WithExplain(db, 50*time.Millisecond, WithExplainConcurrency(1))})
+	done := make(chan explained)
+	go func() {
+		done <- o.explain.explain(context.Background(), LogEvent{"Statement": "SELECT * FROM slow"})
+	}()
+	<-started
+	if r := o.explain.explain(context.Background(), event(1)); r.err != ErrExplainThrottled {
+		t.Errorf("expected the second EXPLAIN to be throttled, got %v", r.err)
+	}
+	close(release)
+	if r := <-done; r.err != nil && r.err != context.DeadlineExceeded {
+		t.Errorf("unexpected error %v", r.err)
+	}
+	d.rows = nil
+	if r := o.explain.explain(context.Background(), event(2)); r.err != nil {
+		t.Errorf("expected an EXPLAIN once the first is done, got %v", r.err)
+	}
+}
+
+func TestExplainBreaker(t *testing.T) {
+	db, d := openFakeDB(t)
+	defer db.Close()
+	clock := NewManualClock(time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC))
+	o := newReportOptions([]ReportOption{WithExplain(db, time.Second,
+		WithExplainCache(0, 0), WithExplainBreaker(2, time.Second, time.Minute), WithExplainClock(clock))})
+	explain := func(statement string) error {
+		return o.explain.explain(context.Background(), LogEvent{"Statement": statement}).err
+	}
+
+	// Consecutive failures pause explaining, and one more after the
+	// cooldown pauses it again.
+	explain("SELECT * FROM missing")
+	if err := explain("SELECT * FROM orders"); err != nil {
+		t.Errorf("expected a success to reset the failures, got %v", err)
+	}
+	explain("SELECT * FROM missing")
+	explain("SELECT * FROM missing")
+	if err := explain("SELECT * FROM orders"); err != ErrExplainPaused {
+		t.Errorf("expected explaining to be paused, got %v", err)
+	}
+	if d.explains() != 4 {
+		t.Errorf("expected no EXPLAIN while paused, got %d", d.explains())
+	}
+	clock.Add(time.Minute)
+	explain("SELECT * FROM missing")
+	if err := explain("SELECT * FROM orders"); err != ErrExplainPaused {
+		t.Errorf("expected a failure after the cooldown to pause explaining again, got %v", err)
+	}
+	clock.Add(time.Minute)
+	if err := explain("SELECT * FROM orders"); err != nil {
+		t.Errorf("expected explaining to resume, got %v", err)
+	}
+
+	// So does a slow EXPLAIN.
+	d.rows = func(query string) (*fakeRows, error) {
+		clock.Add(2 * time.Second)
+		return &fakeRows{columns: []string{"EXPLAIN"}, values: [][]driver.Value{{"{}"}}}, nil
+	}
+	if err := explain("SELECT * FROM orders"); err != nil {
+		t.Errorf("expected the slow plan, got %v", err)
+	}
+	if err := explain("SELECT * FROM orders"); err != ErrExplainPaused {
+		t.Errorf("expected a slow EXPLAIN to pause explaining, got %v", err)
+	}
+}
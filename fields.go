@@ -0,0 +1,52 @@
+package mysqllog
+
+import "strings"
+
+// Field selects an event attribute for output by the writers, such as
+// LogfmtWriter, under the given name.
+type Field struct {
+	Name string
+	Key  string
+}
+
+// DefaultFields are the fields written when none are given.
+var DefaultFields = []Field{
+	{"ts", "Timestamp"},
+	{"user", "User"},
+	{"host", "Host"},
+	{"db", "Database"},
+	{"query_time", "Query_time"},
+	{"lock_time", "Lock_time"},
+	{"rows_sent", "Rows_sent"},
+	{"rows_examined", "Rows_examined"},
+	{"statement", "Statement"},
+}
+
+// Fields returns fields for keys, named after the key in lowercase, in
+// the order given.
+func Fields(keys ...string) []Field {
+	fields := make([]Field, len(keys))
+	for i, key := range keys {
+		fields[i] = Field{Name: strings.ToLower(key), Key: key}
+	}
+	return fields
+}
+
+// FieldValue returns the value of f in flat, an event flattened by
+// LogEvent.Flatten, and false if it doesn't have it. Events renamed by
+// WithKeyStyle are looked up by the styled key too.
+func FieldValue(flat map[string]string, f Field) (string, bool) {
+	v, ok := flat[f.Key]
+	for _, style := range []KeyStyle{SnakeLower, CamelLower} {
+		if ok {
+			break
+		}
+		v, ok = flat[style.Key(f.Key)]
+	}
+	return v, ok
+}
+
+// formatValue formats a value as LogEvent.Flatten does.
+func formatValue(v interface{}) string {
+	return flattenValue(v, 0)
+}
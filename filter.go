@@ -0,0 +1,26 @@
+package mysqllog
+
+// WithMinRowsExamined keeps only the events that examined at least n
+// rows, however fast they were, to find the queries that won't scale
+// with their tables. Events without Rows_examined are dropped too. The
+// dropped events are counted in Stats.RowsFiltered. Like the other
+// filters, it applies on top of them, so an event must pass all of
+// them.
+func WithMinRowsExamined(n int64) Option {
+	return func(p *Parser) {
+		p.minRowsExamined = n
+		p.rowsFilter = true
+	}
+}
+
+// admitRows applies WithMinRowsExamined to a completed event.
+func (p *Parser) admitRows(e LogEvent) bool {
+	if !p.rowsFilter {
+		return true
+	}
+	if rows, ok := e.Int64("Rows_examined"); ok && rows >= p.minRowsExamined {
+		return true
+	}
+	p.stats.RowsFiltered++
+	return false
+}
@@ -0,0 +1,28 @@
+package mysqllog
+
+import "testing"
+
+func TestWithMinRowsExamined(t *testing.T) {
+	input := "# User@Host: app[app] @ web1 []  Id: 1\n# Query_time: 0.001 Lock_time: 0 Rows_sent: 1 Rows_examined: 500000\nSELECT 1;\n" +
+		"# User@Host: app[app] @ web1 []  Id: 1\n# Query_time: 5 Lock_time: 0 Rows_sent: 1 Rows_examined: 10\nSELECT 2;\n" +
+		"# User@Host: app[app] @ web1 []  Id: 2\n# Query_time: 0.1 Lock_time: 0 Rows_sent: 1 Rows_examined: 100000\nSELECT 3;\n" +
+		"# User@Host: app[app] @ web1 []  Id: 1\n# Query_time: 1\nSELECT 4;\n"
+	p := NewParser(WithMinRowsExamined(100000))
+	events := parseAll(p, input)
+	if len(events) != 2 || events[0]["Statement"] != "SELECT 1;" || events[1]["Statement"] != "SELECT 3;" {
+		t.Fatalf("unexpected events %v", events)
+	}
+	if stats := p.Stats(); stats.Events != 2 || stats.RowsFiltered != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// Filters compose: both must pass.
+	p = NewParser(WithMinRowsExamined(100000), WithConnectionFilter(1))
+	events = parseAll(p, input)
+	if len(events) != 1 || events[0]["Statement"] != "SELECT 1;" {
+		t.Fatalf("expected only SELECT 1, got %v", events)
+	}
+	if stats := p.Stats(); stats.RowsFiltered != 2 || stats.ConnectionFiltered != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
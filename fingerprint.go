@@ -0,0 +1,226 @@
+package mysqllog
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Fingerprint returns a normalized form of statement in the style of
+// pt-query-digest: comments are removed, string and numeric literals
+// are replaced with "?", whitespace is collapsed, everything outside
+// identifiers quoted with backticks is lowercased, and a trailing
+// semicolon is dropped. Statements that differ only in their literals
+// share a fingerprint.
+//
+// Lists of literals are collapsed so batch sizes don't matter:
+// "IN (1,2,3)" becomes "in (?+)", and any number of literal-only tuples
+// after VALUES becomes "values (?+)", as do several tuples after VALUES
+// that are the same once normalized, such as "(1, NULL), (2, NULL)".
+// Negative numbers are literals. Other lists containing anything other
+// than literals, such as subqueries or nested tuples, are left alone.
+//
+// Administrator commands are fingerprinted as the command, as in
+// "administrator command: Quit". Statements with nothing but comments,
+// such as "/* ping */" or "-- keepalive", are CommentOnlyFingerprint, so
+// the fingerprint is only empty for an empty statement.
+func Fingerprint(statement string) string {
+	if isAdminCommand(statement) {
+		return adminCommandPrefix[2:] + adminCommand(statement)
+	}
+	var b strings.Builder
+	b.Grow(len(statement))
+	space := false
+	writeSpace := func() {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+	}
+
+	for i := 0; i < len(statement); i++ {
+		c := statement[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+		case c == '/' && i+1 < len(statement) && statement[i+1] == '*':
+			end := strings.Index(statement[i+2:], "*/")
+			if end < 0 {
+				i = len(statement)
+			} else {
+				i += end + 3
+			}
+			space = true
+		case c == '#' || (c == '-' && strings.HasPrefix(statement[i:], "-- ")):
+			end := strings.IndexByte(statement[i:], '\n')
+			if end < 0 {
+				i = len(statement)
+			} else {
+				i += end
+			}
+			space = true
+		case c == '\'' || c == '"':
+			i = skipQuoted(statement, i)
+			writeSpace()
+			b.WriteByte('?')
+		case c == '`':
+			j := len(statement)
+			if end := strings.IndexByte(statement[i+1:], '`'); end >= 0 {
+				j = i + end + 2
+			}
+			writeSpace()
+			b.WriteString(statement[i:j])
+			i = j - 1
+		case isDigit(c) && !isIdentByte(prevByte(statement, i)),
+			c == '-' && i+1 < len(statement) && isDigit(statement[i+1]) && unaryMinus(b.String()):
+			if c == '-' {
+				// A negative number is a literal too.
+				i++
+			}
+			for i+1 < len(statement) && (isIdentByte(statement[i+1]) || statement[i+1] == '.') {
+				i++
+			}
+			writeSpace()
+			b.WriteByte('?')
+		default:
+			writeSpace()
+			if c >= 'A' && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			b.WriteByte(c)
+		}
+	}
+
+	fingerprint := strings.TrimSpace(strings.TrimRight(b.String(), "; "))
+	if fingerprint == "" && statement != "" {
+		return CommentOnlyFingerprint
+	}
+	return collapseValues(literalListRe.ReplaceAllString(fingerprint, "$1 (?+)"))
+}
+
+// unaryMinus reports whether a "-" after the fingerprint so far is a
+// sign rather than a subtraction: at the start, or after an opening
+// parenthesis, a comma or an operator.
+func unaryMinus(fingerprint string) bool {
+	if fingerprint == "" {
+		return true
+	}
+	return strings.IndexByte("(,=<>!+-*/%", fingerprint[len(fingerprint)-1]) >= 0
+}
+
+// valuesRe matches the start of the tuples of VALUES in a fingerprint.
+var valuesRe = regexp.MustCompile(`\bvalues? \(`)
+
+// collapseValues replaces the tuples after VALUES in fingerprint with
+// "(?+)" when there are several and they're all the same, such as rows
+// with the same NULL or function columns.
+func collapseValues(fingerprint string) string {
+	locs := valuesRe.FindAllStringIndex(fingerprint, -1)
+	for k := len(locs) - 1; k >= 0; k-- {
+		start := locs[k][1] - 1
+		var groups []string
+		end := start
+		for end < len(fingerprint) && fingerprint[end] == '(' {
+			close := matchingParen(fingerprint, end)
+			if close < 0 {
+				break
+			}
+			groups = append(groups, fingerprint[end:close+1])
+			next := close + 1
+			for next < len(fingerprint) && (fingerprint[next] == ' ' || fingerprint[next] == ',') {
+				next++
+			}
+			if next >= len(fingerprint) || fingerprint[next] != '(' || !strings.Contains(fingerprint[close+1:next], ",") {
+				end = close + 1
+				break
+			}
+			end = next
+		}
+		same := len(groups) > 1
+		for _, g := range groups {
+			same = same && g == groups[0]
+		}
+		if same {
+			fingerprint = fingerprint[:start] + "(?+)" + fingerprint[end:]
+		}
+	}
+	return fingerprint
+}
+
+// matchingParen returns the index of the parenthesis closing the one at
+// s[i], skipping identifiers quoted with backticks, or -1.
+func matchingParen(s string, i int) int {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '`':
+			i = skipQuoted(s, i)
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// CommentOnlyFingerprint is the fingerprint of statements with only
+// comments, optimizer hints, whitespace or semicolons.
+const CommentOnlyFingerprint = "/* comment-only */"
+
+// isCommentOnly reports whether statement isn't empty but has only
+// comments, whitespace or semicolons.
+func isCommentOnly(statement string) bool {
+	if statement == "" {
+		return false
+	}
+	for _, t := range tokenize(statement) {
+		if !t.isPunct(';') {
+			return false
+		}
+	}
+	return true
+}
+
+// literalListRe matches IN and VALUES followed by parenthesized groups
+// containing only placeholders.
+var literalListRe = regexp.MustCompile(`\b(in|values?)(?:[\s,]*\([\s,]*\?[\s?,]*\))+`)
+
+// skipQuoted returns the index of the closing quote of the string
+// literal or backquoted identifier starting at s[i], or the last index
+// of s if it's unterminated. A doubled quote is an escaped one, and so
+// is a backslash and the character after it, except in identifiers.
+func skipQuoted(s string, i int) int {
+	quote := s[i]
+	for i++; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(s) - 1
+}
+
+func prevByte(s string, i int) byte {
+	if i == 0 {
+		return ' '
+	}
+	return s[i-1]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
@@ -0,0 +1,154 @@
+package mysqllog
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	type TestCase struct {
+		Statement string
+		Expected  string
+	}
+
+	cases := []TestCase{
+		{
+			Statement: "SELECT 1;",
+			Expected:  "select ?",
+		},
+		{
+			Statement: "SELECT * FROM users WHERE id = 42 AND name = 'bob'",
+			Expected:  "select * from users where id = ? and name = ?",
+		},
+		{
+			Statement: "select *\n  from   t1\twhere a=\"x\" and b = 1.5;",
+			Expected:  "select * from t1 where a=? and b = ?",
+		},
+		{
+			Statement: "SELECT /* comment */ `Col1` FROM `My_Table` -- trailing\nWHERE x = 'it''s' # other",
+			Expected:  "select `Col1` from `My_Table` where x = ?",
+		},
+		{
+			Statement: `SELECT 'a\'b', 0x1F FROM t`,
+			Expected:  "select ?, ? from t",
+		},
+		{
+			Statement: "SELECT 'unterminated",
+			Expected:  "select ?",
+		},
+		{
+			Statement: "/* ping */",
+			Expected:  CommentOnlyFingerprint,
+		},
+		{
+			Statement: "-- keepalive\n# from the pool",
+			Expected:  CommentOnlyFingerprint,
+		},
+		{
+			Statement: "/*+ MAX_EXECUTION_TIME(1000) */ ;",
+			Expected:  CommentOnlyFingerprint,
+		},
+		{
+			Statement: ";",
+			Expected:  CommentOnlyFingerprint,
+		},
+		{
+			Statement: "",
+			Expected:  "",
+		},
+	}
+
+	for _, c := range cases {
+		result := Fingerprint(c.Statement)
+		if result != c.Expected {
+			t.Errorf("expected %q, got %q", c.Expected, result)
+		}
+	}
+}
+
+func TestFingerprintCollapsesLists(t *testing.T) {
+	type TestCase struct {
+		Statement string
+		Expected  string
+	}
+
+	huge := "SELECT * FROM t WHERE id IN (1"
+	for i := 2; i <= 500; i++ {
+		huge += fmt.Sprintf(", %d", i)
+	}
+	huge += ")"
+
+	cases := []TestCase{
+		{
+			Statement: "SELECT * FROM t WHERE id IN (1)",
+			Expected:  "select * from t where id in (?+)",
+		},
+		{
+			Statement: "SELECT * FROM t WHERE id IN (1,2,3)",
+			Expected:  "select * from t where id in (?+)",
+		},
+		{
+			Statement: huge,
+			Expected:  "select * from t where id in (?+)",
+		},
+		{
+			Statement: "SELECT * FROM t WHERE name in('a', 'b') AND x = 1",
+			Expected:  "select * from t where name in (?+) and x = ?",
+		},
+		{
+			Statement: "SELECT * FROM t WHERE id IN (SELECT id FROM u WHERE x IN (1, 2))",
+			Expected:  "select * from t where id in (select id from u where x in (?+))",
+		},
+		{
+			Statement: "SELECT * FROM t WHERE (a, b) IN ((1, 2), (3, 4))",
+			Expected:  "select * from t where (a, b) in ((?, ?), (?, ?))",
+		},
+		{
+			Statement: "INSERT INTO t (a, b) VALUES (1, 'x')",
+			Expected:  "insert into t (a, b) values (?+)",
+		},
+		{
+			Statement: "INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y'),(3,'z');",
+			Expected:  "insert into t (a, b) values (?+)",
+		},
+		{
+			Statement: "INSERT INTO t VALUES (1, NOW())",
+			Expected:  "insert into t values (?, now())",
+		},
+		{
+			Statement: "INSERT INTO t (a, b) VALUES (1,NULL),(2,NULL)",
+			Expected:  "insert into t (a, b) values (?+)",
+		},
+		{
+			Statement: "INSERT INTO t (a, b) VALUES (1, NULL), (2, NULL), (3, NULL)",
+			Expected:  "insert into t (a, b) values (?+)",
+		},
+		{
+			Statement: "INSERT INTO t (a, b) VALUES (1,-2),(3,-4)",
+			Expected:  "insert into t (a, b) values (?+)",
+		},
+		{
+			Statement: "INSERT INTO t VALUES (1, NOW(), TRUE), (2, NOW(), TRUE) ON DUPLICATE KEY UPDATE a = VALUES(a)",
+			Expected:  "insert into t values (?+) on duplicate key update a = values(a)",
+		},
+		{
+			Statement: "INSERT INTO t VALUES (1, NOW()), (2, NULL)",
+			Expected:  "insert into t values (?, now()), (?, null)",
+		},
+		{
+			Statement: "SELECT a - 1, b-2, -3 FROM t WHERE x IN (-1, 2) AND y = -1.5",
+			Expected:  "select a - ?, b-?, ? from t where x in (?+) and y = ?",
+		},
+		{
+			Statement: "SELECT * FROM t JOIN u ON t.id = u.id WHERE join(1) = min(2)",
+			Expected:  "select * from t join u on t.id = u.id where join(?) = min(?)",
+		},
+	}
+
+	for _, c := range cases {
+		result := Fingerprint(c.Statement)
+		if result != c.Expected {
+			t.Errorf("expected %q, got %q", c.Expected, result)
+		}
+	}
+}
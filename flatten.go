@@ -0,0 +1,164 @@
+package mysqllog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FlattenOptions configures LogEvent.Flatten.
+type FlattenOptions struct {
+	// Precision is the number of decimals of float64 values, and of
+	// durations in seconds. Zero writes the fewest decimals that read
+	// back as the same value.
+	Precision int
+	// Separator joins the name of a nested map and one of its keys,
+	// "." if empty.
+	Separator string
+	// Prefixed names every key of a nested map after the map, such as
+	// "Labels.env", instead of only the conflicting ones.
+	Prefixed bool
+}
+
+// Flatten returns e as text, for formats without nesting such as CSV,
+// logfmt and statsd tags. Numbers never use exponents, bools are "true"
+// or "false", times are RFC 3339 with fractional seconds, durations are
+// in seconds, and lists are joined with commas. Nil values are left out.
+//
+// The entries of nested maps, such as "Labels", "Meta" and "Unknown",
+// are flattened under their own keys. A key that's also a key of e, or
+// of a nested map before it in key order, is prefixed with the name of
+// its map and the separator, as "Meta.User", so top-level keys always
+// win. Maps nested deeper are prefixed with the key they're under.
+func (e LogEvent) Flatten(opts FlattenOptions) map[string]string {
+	if opts.Separator == "" {
+		opts.Separator = "."
+	}
+	flat := make(map[string]string, len(e))
+	var nested []string
+	for k, v := range e {
+		if _, ok := nestedMap(v); ok {
+			nested = append(nested, k)
+			continue
+		}
+		if v != nil {
+			flat[k] = flattenValue(v, opts.Precision)
+		}
+	}
+	sort.Strings(nested)
+	for _, name := range nested {
+		m, _ := nestedMap(e[name])
+		for _, key := range sortedKeys(m) {
+			flatKey := key
+			if _, taken := e[key]; taken || opts.Prefixed {
+				flatKey = name + opts.Separator + key
+			} else if _, taken := flat[key]; taken {
+				flatKey = name + opts.Separator + key
+			}
+			flattenInto(flat, flatKey, m[key], opts)
+		}
+	}
+	return flat
+}
+
+// flattenInto adds v to flat under key, and the entries of v under key
+// and the separator if it's a map. Keys already in flat are kept.
+func flattenInto(flat map[string]string, key string, v interface{}, opts FlattenOptions) {
+	if m, ok := nestedMap(v); ok {
+		for _, k := range sortedKeys(m) {
+			flattenInto(flat, key+opts.Separator+k, m[k], opts)
+		}
+		return
+	}
+	if _, taken := flat[key]; taken || v == nil {
+		return
+	}
+	flat[key] = flattenValue(v, opts.Precision)
+}
+
+// Nested returns e with its nested maps, such as "Labels", "Meta" and
+// "Unknown", as map[string]interface{} at any depth, for formats with
+// nesting such as JSON and Elasticsearch documents. Other values are
+// kept as they are. The maps are copies, so the result can be changed
+// without changing e.
+func (e LogEvent) Nested() map[string]interface{} {
+	nested := make(map[string]interface{}, len(e))
+	for k, v := range e {
+		nested[k] = nestedValue(v)
+	}
+	return nested
+}
+
+func nestedValue(v interface{}) interface{} {
+	m, ok := nestedMap(v)
+	if !ok {
+		return v
+	}
+	copied := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		copied[k] = nestedValue(v)
+	}
+	return copied
+}
+
+// nestedMap returns v as a map[string]interface{} if it's a map of
+// attributes. map[string]string values are converted.
+func nestedMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case LogEvent:
+		return m, true
+	case map[string]interface{}:
+		return m, true
+	case map[string]string:
+		converted := make(map[string]interface{}, len(m))
+		for k, s := range m {
+			converted[k] = s
+		}
+		return converted, true
+	}
+	return nil, false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// flattenValue formats a value as Flatten does, with floats rounded to
+// precision decimals, if positive.
+func flattenValue(v interface{}, precision int) string {
+	if precision <= 0 {
+		precision = -1
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', precision, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return strconv.FormatFloat(v.Seconds(), 'f', precision, 64)
+	case []string:
+		return strings.Join(v, ",")
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, x := range v {
+			values[i] = flattenValue(x, precision)
+		}
+		return strings.Join(values, ",")
+	}
+	return fmt.Sprint(v)
+}
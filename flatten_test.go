@@ -0,0 +1,97 @@
+package mysqllog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFlatten(t *testing.T) {
+	e := LogEvent{
+		"User":       "app",
+		"Query_time": 0.000123,
+		"Rows_sent":  int64(3),
+		"Full_scan":  true,
+		"Timestamp":  time.Date(2023, 8, 1, 10, 0, 0, 500000000, time.UTC),
+		"Wait":       1500 * time.Millisecond,
+		"Tables":     []string{"a", "b"},
+		"Empty":      nil,
+		"Labels":     map[string]string{"env": "prod", "User": "label"},
+		"Meta":       map[string]string{"env": "meta", "app": "web"},
+		"Unknown":    map[string]interface{}{"Deep": map[string]string{"x": "1"}},
+	}
+	type TestCase struct {
+		name     string
+		opts     FlattenOptions
+		expected map[string]string
+	}
+	testCases := []TestCase{
+		{
+			name: "default",
+			expected: map[string]string{
+				"User":       "app",
+				"Query_time": "0.000123",
+				"Rows_sent":  "3",
+				"Full_scan":  "true",
+				"Timestamp":  "2023-08-01T10:00:00.5Z",
+				"Wait":       "1.5",
+				"Tables":     "a,b",
+				// Top-level keys win, then the maps in key order.
+				"Labels.User": "label",
+				"env":         "prod",
+				"Meta.env":    "meta",
+				"app":         "web",
+				"Deep.x":      "1",
+			},
+		},
+		{
+			name: "prefixed with precision",
+			opts: FlattenOptions{Precision: 2, Separator: "_", Prefixed: true},
+			expected: map[string]string{
+				"User":           "app",
+				"Query_time":     "0.00",
+				"Rows_sent":      "3",
+				"Full_scan":      "true",
+				"Timestamp":      "2023-08-01T10:00:00.5Z",
+				"Wait":           "1.50",
+				"Tables":         "a,b",
+				"Labels_User":    "label",
+				"Labels_env":     "prod",
+				"Meta_env":       "meta",
+				"Meta_app":       "web",
+				"Unknown_Deep_x": "1",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		if got := e.Flatten(tc.opts); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestNested(t *testing.T) {
+	labels := map[string]string{"env": "prod"}
+	e := LogEvent{"User": "app", "Labels": labels, "Meta": LogEvent{"a": map[string]string{"b": "c"}}}
+	nested := e.Nested()
+	expected := map[string]interface{}{
+		"User":   "app",
+		"Labels": map[string]interface{}{"env": "prod"},
+		"Meta":   map[string]interface{}{"a": map[string]interface{}{"b": "c"}},
+	}
+	if !reflect.DeepEqual(nested, expected) {
+		t.Errorf("expected %v, got %v", expected, nested)
+	}
+	nested["Labels"].(map[string]interface{})["env"] = "dev"
+	if labels["env"] != "prod" {
+		t.Error("expected Nested to copy the maps")
+	}
+}
+
+func TestLogfmtNestedFields(t *testing.T) {
+	e := LogEvent{"User": "app", "Labels": map[string]string{"env": "prod", "User": "label"}}
+	got := FormatLogfmt(e, []Field{{"user", "User"}, {"env", "env"}, {"label_user", "Labels.User"}})
+	if expected := "user=app env=prod label_user=label"; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,173 @@
+package mysqllog
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// DefaultForwarderInterval is how often a Forwarder writes a digest by
+// default.
+const DefaultForwarderInterval = 5 * time.Minute
+
+// DigestKey is the attribute holding the digest in the events written
+// by a Forwarder, a json.RawMessage. See EventDigest.
+const DigestKey = "Digest"
+
+// ForwarderOption configures a Forwarder.
+type ForwarderOption func(*Forwarder)
+
+// WithForwarderInterval writes a digest every d, DefaultForwarderInterval
+// by default. Zero or less only writes them for WithForwarderEvents,
+// Flush and Close.
+func WithForwarderInterval(d time.Duration) ForwarderOption {
+	return func(f *Forwarder) {
+		f.interval = d
+	}
+}
+
+// WithForwarderEvents writes a digest once n events were aggregated
+// since the last one, besides every WithForwarderInterval. Zero or less,
+// the default, doesn't count events.
+func WithForwarderEvents(n int64) ForwarderOption {
+	return func(f *Forwarder) {
+		f.maxEvents = n
+	}
+}
+
+// WithForwarderAggregator aggregates events with an Aggregator made with
+// opts, such as WithRollups and WithMaxFingerprints. Receivers merging
+// the digests need the same quantile accuracy.
+func WithForwarderAggregator(opts ...AggregatorOption) ForwarderOption {
+	return func(f *Forwarder) {
+		f.aggregatorOpts = opts
+	}
+}
+
+// WithForwarderSource sets "Source" on the digest events to id, such as
+// the name of the agent.
+func WithForwarderSource(id string) ForwarderOption {
+	return func(f *Forwarder) {
+		f.source = id
+	}
+}
+
+// WithForwarderClock tells the time for WithForwarderInterval with c in
+// place of RealClock.
+func WithForwarderClock(c Clock) ForwarderOption {
+	return func(f *Forwarder) {
+		f.clock = c
+	}
+}
+
+// Forwarder is a Sink aggregating the events written to it, and writing
+// a digest of them to another Sink at intervals instead of the events
+// themselves, for agents on links where shipping every event costs too
+// much. Each digest holds what was aggregated since the previous one,
+// from Aggregator.MarshalDigest, and is written as an event with the
+// time it was cut as "Timestamp", the digest as DigestKey and, with
+// WithForwarderSource, "Source". The receiving side merges the digests
+// of many agents with EventDigest and Aggregator.MergeDigest.
+//
+// Intervals are checked when events are written, and with FlushDue,
+// which is worth calling periodically so quiet agents still send their
+// last events. Close writes the digest of the partial interval. A
+// Forwarder is safe for concurrent use.
+type Forwarder struct {
+	sink           Sink
+	interval       time.Duration
+	maxEvents      int64
+	aggregatorOpts []AggregatorOption
+	source         string
+	clock          Clock
+
+	mu         sync.Mutex
+	aggregator *Aggregator
+	events     int64
+	start      time.Time
+}
+
+// NewForwarder returns a Forwarder writing digests to sink.
+func NewForwarder(sink Sink, opts ...ForwarderOption) *Forwarder {
+	f := &Forwarder{sink: sink, interval: DefaultForwarderInterval}
+	for _, opt := range opts {
+		opt(f)
+	}
+	f.clock = clockOf(f.clock)
+	f.reset()
+	return f
+}
+
+// Write aggregates e, first writing the digest of the previous interval
+// if it's over, and then that of e's if it reached the events of
+// WithForwarderEvents.
+func (f *Forwarder) Write(e LogEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.flushDue(); err != nil {
+		return err
+	}
+	f.aggregator.Add(e)
+	f.events++
+	if f.maxEvents > 0 && f.events >= f.maxEvents {
+		return f.flush()
+	}
+	return nil
+}
+
+// FlushDue writes the digest of the interval if it's over.
+func (f *Forwarder) FlushDue() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.flushDue()
+}
+
+// Flush writes the digest of the events aggregated since the last one,
+// and starts a new interval. Nothing is written without events.
+func (f *Forwarder) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.flush()
+}
+
+// Close writes the digest of the partial interval and closes the sink.
+func (f *Forwarder) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.flush()
+	if closeErr := f.sink.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (f *Forwarder) flushDue() error {
+	if f.interval > 0 && f.clock.Now().Sub(f.start) >= f.interval {
+		return f.flush()
+	}
+	return nil
+}
+
+func (f *Forwarder) flush() error {
+	if f.events == 0 {
+		f.reset()
+		return nil
+	}
+	data, err := f.aggregator.MarshalDigest()
+	if err != nil {
+		return err
+	}
+	e := LogEvent{"Timestamp": f.clock.Now(), DigestKey: json.RawMessage(data)}
+	if f.source != "" {
+		e["Source"] = f.source
+	}
+	f.reset()
+	return f.sink.Write(e)
+}
+
+// reset starts a new interval.
+func (f *Forwarder) reset() {
+	f.aggregator = NewAggregator(f.aggregatorOpts...)
+	f.events = 0
+	f.start = f.clock.Now()
+}
@@ -0,0 +1,46 @@
+package mysqllog
+
+// Defaults for WithFullScanDetection.
+const (
+	DefaultFullScanRatio   = 1000
+	DefaultFullScanMinRows = 10000
+)
+
+// WithFullScanDetection sets "ProbableFullScan" to true on events that
+// examined at least minRows rows and more than ratio rows per row sent,
+// counting Rows_sent of zero as one. When the event has MariaDB's
+// Full_scan attribute, it is used instead of the heuristic. Events
+// missing Rows_examined are never flagged.
+func WithFullScanDetection(ratio float64, minRows int64) Option {
+	return func(p *Parser) {
+		p.fullScanRatio = ratio
+		p.fullScanMinRows = minRows
+		p.fullScan = true
+	}
+}
+
+// probableFullScan reports whether e looks like a full scan.
+func probableFullScan(e LogEvent, ratio float64, minRows int64) bool {
+	if fullScan, ok := e["Full_scan"].(bool); ok {
+		return fullScan
+	}
+	examined, ok := e.Int64("Rows_examined")
+	if !ok || examined < minRows {
+		return false
+	}
+	sent, _ := e.Int64("Rows_sent")
+	if sent < 1 {
+		sent = 1
+	}
+	return float64(examined)/float64(sent) > ratio
+}
+
+// isFullScan reports whether e is flagged as a full scan, either by
+// WithFullScanDetection or by MariaDB's Full_scan attribute.
+func isFullScan(e LogEvent) bool {
+	if flagged, ok := e["ProbableFullScan"].(bool); ok {
+		return flagged
+	}
+	fullScan, _ := e["Full_scan"].(bool)
+	return fullScan
+}
@@ -0,0 +1,77 @@
+package mysqllog
+
+import "testing"
+
+func TestProbableFullScan(t *testing.T) {
+	type TestCase struct {
+		Event    LogEvent
+		Expected bool
+	}
+
+	cases := []TestCase{
+		{Event: LogEvent{"Rows_examined": int64(2000000), "Rows_sent": int64(1)}, Expected: true},
+		// Zero rows sent counts as one.
+		{Event: LogEvent{"Rows_examined": int64(2000000), "Rows_sent": int64(0)}, Expected: true},
+		{Event: LogEvent{"Rows_examined": int64(2000000)}, Expected: true},
+		{Event: LogEvent{"Rows_examined": int64(2000000), "Rows_sent": int64(10000)}, Expected: false},
+		// Below the minimum rows examined.
+		{Event: LogEvent{"Rows_examined": int64(5000), "Rows_sent": int64(0)}, Expected: false},
+		{Event: LogEvent{"Rows_sent": int64(0)}, Expected: false},
+		{Event: LogEvent{}, Expected: false},
+		// MariaDB's Full_scan wins over the heuristic.
+		{Event: LogEvent{"Full_scan": true, "Rows_examined": int64(10)}, Expected: true},
+		{Event: LogEvent{"Full_scan": false, "Rows_examined": int64(2000000)}, Expected: false},
+	}
+
+	for _, c := range cases {
+		if result := probableFullScan(c.Event, DefaultFullScanRatio, DefaultFullScanMinRows); result != c.Expected {
+			t.Errorf("expected %v for %v, got %v", c.Expected, c.Event, result)
+		}
+	}
+}
+
+func TestWithFullScanDetection(t *testing.T) {
+	input := "# Query_time: 1.5  Lock_time: 0.0 Rows_sent: 1  Rows_examined: 500000\nSELECT * FROM t WHERE x = 1;\n" +
+		"# Query_time: 0.1  Lock_time: 0.0 Rows_sent: 1  Rows_examined: 1\nSELECT * FROM t WHERE id = 1;\n" +
+		"# Query_time: 1.2  Lock_time: 0.0 Rows_sent: 0  Rows_examined: 400000\nSELECT * FROM t WHERE x = 2;\n"
+	events := parseAll(NewParser(WithFullScanDetection(DefaultFullScanRatio, DefaultFullScanMinRows)), input)
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	if events[0]["ProbableFullScan"] != true || events[2]["ProbableFullScan"] != true {
+		t.Errorf("expected scans to be flagged: %v, %v", events[0], events[2])
+	}
+	if _, ok := events[1]["ProbableFullScan"]; ok {
+		t.Errorf("expected index lookup not to be flagged: %v", events[1])
+	}
+
+	a := NewAggregator()
+	for _, e := range events {
+		a.Add(e)
+	}
+	a.Add(LogEvent{"Statement": "SELECT * FROM t WHERE x = 3", "Query_time": 0.1, "Rows_sent": int64(1), "Rows_examined": int64(1)})
+	results := a.Results()
+	if len(results) != 2 {
+		t.Fatalf("expected 2 fingerprints, got %d", len(results))
+	}
+	scan := results[0]
+	if scan.FullScans != 2 || scan.FullScanPercent() != 200.0/3 {
+		t.Errorf("expected 2 of 3 calls to be full scans, got %d (%f%%)", scan.FullScans, scan.FullScanPercent())
+	}
+	if scan.ExaminedPerSent() != 900001.0/2 {
+		t.Errorf("unexpected rows examined per row sent %f", scan.ExaminedPerSent())
+	}
+}
+
+func TestParseMariaDBFullScan(t *testing.T) {
+	input := "# Query_time: 1.5  Lock_time: 0.0 Rows_sent: 1  Rows_examined: 10\n" +
+		"# Full_scan: Yes  Full_join: No  Tmp_table: No  Tmp_table_on_disk: No\n" +
+		"SELECT * FROM t;\n"
+	events := parseAll(NewParser(WithFullScanDetection(DefaultFullScanRatio, DefaultFullScanMinRows)), input)
+	if events[0]["Full_scan"] != true || events[0]["Full_join"] != false {
+		t.Errorf("expected Yes and No to be parsed as bools, got %v", events[0])
+	}
+	if events[0]["ProbableFullScan"] != true {
+		t.Errorf("expected Full_scan to flag the event, got %v", events[0])
+	}
+}
@@ -0,0 +1,72 @@
+//go:build go1.18
+// +build go1.18
+
+package mysqllog
+
+import (
+	"bufio"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fuzzSeeds adds the fixtures and a few known tricky inputs to f.
+func fuzzSeeds(f *testing.F) {
+	files, _ := filepath.Glob("./_test/*.txt")
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(b))
+	}
+	f.Add(content)
+	for _, s := range []string{
+		"# User@Host: x\nSELECT 1;\n",
+		"# User@Host: a@@[b] @ h [i]\nSELECT 1;\n",
+		"# Query_time:\t1.5 Foo:\tbar\nSELECT 1;\n",
+		"#\tPlan: x\n;\n",
+		"# User@Host: a[b] @ \nSELECT 1;\n",
+		"# Time: 1\nSET timestamp=\nSELECT 1;\n",
+		"# Time: 1\nSET timestamp=;\nuse \nSELECT 1;\n",
+		"# Query_time: 1 Lock_time: \n# DB: \n;\n",
+		"/*app=shop,x='*/\n# Id:\n\n\n",
+	} {
+		f.Add(s)
+	}
+}
+
+func FuzzConsumeLine(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, opts := range [][]Option{
+			nil,
+			{WithDialect(TiDB), WithStrict(), WithCommentMetadata(), WithUnknownAttributes(KeepUnknown)},
+			{WithCanonicalKeys(false), WithUserHostInheritance(2), WithKeyStyle(CamelLower), WithVerbExtraction(), WithTableExtraction()},
+		} {
+			p := NewParser(opts...)
+			r := bufio.NewReader(strings.NewReader(input))
+			for {
+				line, err := r.ReadString('\n')
+				if line != "" {
+					p.ConsumeLine(line)
+				}
+				if err != nil {
+					break
+				}
+			}
+			p.Flush()
+		}
+	})
+}
+
+func FuzzParseEvent(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, input string) {
+		if e := ParseEvent([]byte(input)); e != nil {
+			e.Validate()
+			Fingerprint(e["Statement"].(string))
+		}
+	})
+}
@@ -0,0 +1,294 @@
+// Package gen writes synthetic slow query logs, to test and benchmark
+// parsing without real logs.
+package gen
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flavor is the server whose log format is written.
+type Flavor int
+
+const (
+	// MySQL57 is MySQL 5.7, with RFC 3339 "# Time:" lines.
+	MySQL57 Flavor = iota
+	// MySQL56 is MySQL 5.6, with "# Time: 171224  2:42:00" lines.
+	MySQL56
+	// MySQL80 is MySQL 8.0 with log_slow_extra.
+	MySQL80
+	// Percona is Percona Server with log_slow_verbosity=full.
+	Percona
+	// MariaDB is MariaDB with log_slow_verbosity=query_plan.
+	MariaDB
+)
+
+func (f Flavor) String() string {
+	switch f {
+	case MySQL57:
+		return "MySQL 5.7"
+	case MySQL56:
+		return "MySQL 5.6"
+	case MySQL80:
+		return "MySQL 8.0"
+	case Percona:
+		return "Percona"
+	case MariaDB:
+		return "MariaDB"
+	}
+	return "unknown"
+}
+
+// Distribution draws Query_time values, in seconds.
+type Distribution interface {
+	Sample(r *rand.Rand) float64
+}
+
+// LogNormal is a log-normal distribution, the usual shape of query
+// latencies, with the given median and the standard deviation of its
+// logarithm.
+type LogNormal struct {
+	Median float64
+	Sigma  float64
+}
+
+// Sample implements Distribution.
+func (d LogNormal) Sample(r *rand.Rand) float64 {
+	return d.Median * math.Exp(d.Sigma*r.NormFloat64())
+}
+
+// Uniform is a uniform distribution from Min to Max.
+type Uniform struct {
+	Min float64
+	Max float64
+}
+
+// Sample implements Distribution.
+func (d Uniform) Sample(r *rand.Rand) float64 {
+	return d.Min + (d.Max-d.Min)*r.Float64()
+}
+
+// Template is a statement written with probability proportional to
+// Weight. "{n}" in Statement is replaced with a random number and "{s}"
+// with a random quoted string.
+type Template struct {
+	Statement string
+	Weight    int
+}
+
+// DefaultTemplates are the statements written when a GenSpec has none.
+var DefaultTemplates = []Template{
+	{"SELECT * FROM orders WHERE id = {n};", 40},
+	{"SELECT o.id, c.name FROM orders o JOIN customers c ON c.id = o.customer_id\nWHERE c.email = {s} ORDER BY o.created_at DESC LIMIT 10;", 20},
+	{"UPDATE carts SET updated_at = NOW() WHERE session = {s};", 15},
+	{"INSERT INTO events (user_id, kind) VALUES ({n}, {s}), ({n}, {s});", 15},
+	{"DELETE FROM sessions WHERE expires_at < {n};", 5},
+	{"SELECT COUNT(*) FROM products WHERE category_id IN ({n}, {n}, {n});", 5},
+}
+
+// DefaultStart is the time of the first event when a GenSpec has none.
+var DefaultStart = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// GenSpec describes a log to generate.
+type GenSpec struct {
+	// Events is the number of events.
+	Events int
+	Flavor Flavor
+	// QueryTime is LogNormal{Median: 0.1, Sigma: 1} if nil.
+	QueryTime Distribution
+	// Templates are DefaultTemplates if empty.
+	Templates []Template
+	// BannerEvery writes a server startup banner, as after a log
+	// rotation or restart, every BannerEvery events, if positive.
+	BannerEvery int
+	// Seed makes the output deterministic: the same spec always writes
+	// the same log.
+	Seed int64
+	// Start is the time of the first event, DefaultStart if zero.
+	// Events are up to 2 seconds apart.
+	Start time.Time
+}
+
+var (
+	users     = []string{"app", "app", "app", "reporting", "admin"}
+	hosts     = []string{"web1", "web2", "web3", "batch1"}
+	databases = []string{"shop", "shop", "analytics"}
+)
+
+// Generate writes the log described by spec to w.
+func Generate(w io.Writer, spec GenSpec) error {
+	if spec.Events < 0 {
+		return errors.New("gen: negative number of events")
+	}
+	if spec.Flavor < MySQL57 || spec.Flavor > MariaDB {
+		return fmt.Errorf("gen: unknown flavor %d", spec.Flavor)
+	}
+	templates := spec.Templates
+	if len(templates) == 0 {
+		templates = DefaultTemplates
+	}
+	totalWeight := 0
+	for _, t := range templates {
+		if t.Weight < 0 {
+			return fmt.Errorf("gen: negative weight for %q", t.Statement)
+		}
+		totalWeight += t.Weight
+	}
+	if totalWeight == 0 {
+		return errors.New("gen: templates have no weight")
+	}
+	queryTime := spec.QueryTime
+	if queryTime == nil {
+		queryTime = LogNormal{Median: 0.1, Sigma: 1}
+	}
+	ts := spec.Start
+	if ts.IsZero() {
+		ts = DefaultStart
+	}
+
+	g := &generator{
+		w:      bufio.NewWriter(w),
+		r:      rand.New(rand.NewSource(spec.Seed)),
+		flavor: spec.Flavor,
+	}
+	g.banner()
+	for i := 0; i < spec.Events; i++ {
+		if spec.BannerEvery > 0 && i > 0 && i%spec.BannerEvery == 0 {
+			g.banner()
+		}
+		ts = ts.Add(time.Duration(g.r.Int63n(int64(2 * time.Second))))
+		pick := g.r.Intn(totalWeight)
+		var template Template
+		for _, template = range templates {
+			if pick -= template.Weight; pick < 0 {
+				break
+			}
+		}
+		g.event(ts, math.Max(queryTime.Sample(g.r), 0), template.Statement)
+	}
+	return g.w.Flush()
+}
+
+type generator struct {
+	w      *bufio.Writer
+	r      *rand.Rand
+	flavor Flavor
+}
+
+func (g *generator) printf(format string, v ...interface{}) {
+	fmt.Fprintf(g.w, format, v...)
+}
+
+func (g *generator) banner() {
+	version := map[Flavor]string{
+		MySQL57: "5.7.30-log (MySQL Community Server (GPL))",
+		MySQL56: "5.6.48-log (MySQL Community Server (GPL))",
+		MySQL80: "8.0.21 (MySQL Community Server - GPL)",
+		Percona: "5.7.30-33-log (Percona Server (GPL), Release 33, Revision 6517692)",
+		MariaDB: "10.5.5-MariaDB-log (MariaDB Server)",
+	}[g.flavor]
+	g.printf("/usr/sbin/mysqld, Version: %s. started with:\n", version)
+	g.printf("Tcp port: 3306  Unix socket: /var/run/mysqld/mysqld.sock\n")
+	g.printf("Time                 Id Command    Argument\n")
+}
+
+func (g *generator) event(ts time.Time, queryTime float64, template string) {
+	r := g.r
+	user := users[r.Intn(len(users))]
+	host := hosts[r.Intn(len(hosts))]
+	ip := fmt.Sprintf("10.0.0.%d", 10+r.Intn(4))
+	id := 1 + r.Intn(200)
+	db := databases[r.Intn(len(databases))]
+	lockTime := queryTime * r.Float64() / 10
+	examined := r.Intn(100000)
+	sent := 0
+	if examined > 0 {
+		sent = r.Intn(examined)
+	}
+
+	switch g.flavor {
+	case MySQL56, MariaDB:
+		g.printf("# Time: %s\n", ts.Format("060102 15:04:05"))
+	default:
+		g.printf("# Time: %s\n", ts.Format("2006-01-02T15:04:05.000000Z"))
+	}
+	switch g.flavor {
+	case MariaDB:
+		g.printf("# User@Host: %s[%s] @ %s [%s]\n", user, user, host, ip)
+		g.printf("# Thread_id: %d  Schema: %s  QC_hit: No\n", id, db)
+		g.printf("# Query_time: %.6f  Lock_time: %.6f  Rows_sent: %d  Rows_examined: %d\n", queryTime, lockTime, sent, examined)
+		g.printf("# Rows_affected: 0  Bytes_sent: %d\n", 100+sent*20)
+	case Percona:
+		g.printf("# User@Host: %s[%s] @ %s [%s]  Id: %5d\n", user, user, host, ip, id)
+		g.printf("# Schema: %s  Last_errno: 0  Killed: 0\n", db)
+		g.printf("# Query_time: %.6f  Lock_time: %.6f  Rows_sent: %d  Rows_examined: %d  Rows_affected: 0\n", queryTime, lockTime, sent, examined)
+		g.printf("# Bytes_sent: %d  Tmp_tables: 0  Tmp_disk_tables: 0  Tmp_table_sizes: 0\n", 100+sent*20)
+		g.printf("# Full_scan: %s  Full_join: No  Tmp_table: No  Tmp_table_on_disk: No\n", yesNo(examined > 10000))
+		g.printf("# Filesort: No  Filesort_on_disk: No  Merge_passes: 0\n")
+	case MySQL80:
+		g.printf("# User@Host: %s[%s] @ %s [%s]  Id: %5d\n", user, user, host, ip, id)
+		start := ts.Add(-time.Duration(queryTime * float64(time.Second)))
+		g.printf("# Query_time: %.6f  Lock_time: %.6f Rows_sent: %d  Rows_examined: %d Thread_id: %d Errno: 0 Killed: 0 "+
+			"Bytes_received: 0 Bytes_sent: %d Read_first: 0 Read_last: 0 Read_key: 1 Read_next: 0 Read_prev: 0 Read_rnd: 0 "+
+			"Read_rnd_next: %d Sort_merge_passes: 0 Sort_range_count: 0 Sort_rows: 0 Sort_scan_count: 0 "+
+			"Created_tmp_disk_tables: 0 Created_tmp_tables: 0 Start: %s End: %s\n",
+			queryTime, lockTime, sent, examined, id, 100+sent*20, examined,
+			start.Format("2006-01-02T15:04:05.000000Z"), ts.Format("2006-01-02T15:04:05.000000Z"))
+	default:
+		g.printf("# User@Host: %s[%s] @ %s [%s]  Id: %5d\n", user, user, host, ip, id)
+		g.printf("# Query_time: %.6f  Lock_time: %.6f Rows_sent: %d  Rows_examined: %d\n", queryTime, lockTime, sent, examined)
+	}
+	if r.Intn(4) == 0 {
+		g.printf("use %s;\n", db)
+	}
+	g.printf("SET timestamp=%d;\n", ts.Unix())
+	g.w.WriteString(g.fill(template))
+	g.w.WriteByte('\n')
+}
+
+// fill replaces the placeholders of template.
+func (g *generator) fill(template string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(template, '{')
+		if i < 0 {
+			b.WriteString(template)
+			return b.String()
+		}
+		b.WriteString(template[:i])
+		switch {
+		case strings.HasPrefix(template[i:], "{n}"):
+			b.WriteString(strconv.Itoa(g.r.Intn(1000000)))
+		case strings.HasPrefix(template[i:], "{s}"):
+			b.WriteString("'" + randomString(g.r, 4+g.r.Intn(12)) + "'")
+		default:
+			b.WriteByte('{')
+			template = template[i+1:]
+			continue
+		}
+		template = template[i+3:]
+	}
+}
+
+func randomString(r *rand.Rand, n int) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letters[r.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
@@ -0,0 +1,131 @@
+package gen
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Preetam/mysqllog"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, flavor := range []Flavor{MySQL57, MySQL56, MySQL80, Percona, MariaDB} {
+		for seed := int64(0); seed < 5; seed++ {
+			spec := GenSpec{Events: 200 + int(seed), Flavor: flavor, BannerEvery: 50, Seed: seed}
+			var buf bytes.Buffer
+			if err := Generate(&buf, spec); err != nil {
+				t.Fatal(err)
+			}
+			events := 0
+			err := mysqllog.ParseReader(bytes.NewReader(buf.Bytes()), func(e mysqllog.LogEvent) {
+				events++
+				statement, _ := e["Statement"].(string)
+				if statement == "" || strings.Contains(statement, "started with") || strings.Contains(statement, "{") {
+					t.Errorf("%s: unexpected statement %q", flavor, statement)
+				}
+				if _, ok := e.Float64("Query_time"); !ok {
+					t.Errorf("%s: expected a Query_time, got %v", flavor, e)
+				}
+				if _, ok := e["User"]; !ok {
+					t.Errorf("%s: expected a User, got %v", flavor, e)
+				}
+			}, mysqllog.WithParser(mysqllog.NewParser(mysqllog.WithStrict())))
+			if err != nil {
+				t.Errorf("%s: %v", flavor, err)
+			}
+			if events != spec.Events {
+				t.Errorf("%s, seed %d: expected %d events, got %d", flavor, seed, spec.Events, events)
+			}
+
+			var again bytes.Buffer
+			Generate(&again, spec)
+			if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+				t.Errorf("%s: expected the same seed to write the same log", flavor)
+			}
+		}
+	}
+}
+
+func TestGenerateSpec(t *testing.T) {
+	var buf bytes.Buffer
+	err := Generate(&buf, GenSpec{
+		Events:    100,
+		QueryTime: Uniform{Min: 2, Max: 3},
+		Templates: []Template{{"SELECT {n}, '{x}' FROM t WHERE s = {s};", 1}, {"never", 0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "never") || !strings.Contains(buf.String(), "'{x}'") {
+		t.Errorf("unexpected output\n%s", buf.String())
+	}
+	mysqllog.ParseReader(&buf, func(e mysqllog.LogEvent) {
+		if q, _ := e.Float64("Query_time"); q < 2 || q > 3 {
+			t.Errorf("expected a Query_time from 2 to 3, got %v", q)
+		}
+	})
+
+	for _, spec := range []GenSpec{
+		{Events: -1},
+		{Flavor: Flavor(42)},
+		{Templates: []Template{{"SELECT 1", 0}}},
+		{Templates: []Template{{"SELECT 1", -1}}},
+	} {
+		if err := Generate(&buf, spec); err == nil {
+			t.Errorf("expected an error for %+v", spec)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	var buf bytes.Buffer
+	if err := Generate(&buf, GenSpec{Events: 10000, Flavor: Percona, BannerEvery: 1000}); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(buf.Len()))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mysqllog.ParseReader(bytes.NewReader(buf.Bytes()), func(mysqllog.LogEvent) {})
+	}
+}
+
+var (
+	largeLogOnce sync.Once
+	largeLog     []byte
+)
+
+// generatedLarge returns a generated log of 1M events, the same for
+// every benchmark.
+func generatedLarge(b *testing.B) []byte {
+	largeLogOnce.Do(func() {
+		var buf bytes.Buffer
+		if err := Generate(&buf, GenSpec{Events: 1000000, Flavor: Percona, BannerEvery: 1000}); err != nil {
+			b.Fatal(err)
+		}
+		largeLog = buf.Bytes()
+	})
+	return largeLog
+}
+
+func BenchmarkParseLarge(b *testing.B) {
+	log := generatedLarge(b)
+	b.SetBytes(int64(len(log)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mysqllog.ParseReader(bytes.NewReader(log), func(mysqllog.LogEvent) {})
+	}
+}
+
+func BenchmarkScanStatsLarge(b *testing.B) {
+	log := generatedLarge(b)
+	b.SetBytes(int64(len(log)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := mysqllog.ScanStats(bytes.NewReader(log)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// Package mysqlloggrpc serves the mysqllog Parser and Aggregator over
+// gRPC, as defined in mysqllog.proto.
+//
+// The server is built with the "grpc" tag, after generating the
+// protobuf and gRPC code, so the main package doesn't depend on gRPC:
+//
+//	go generate ./grpc
+//	go build -tags grpc ./grpc
+package mysqlloggrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative mysqllog.proto
@@ -0,0 +1,262 @@
+//go:build grpc
+// +build grpc
+
+package mysqlloggrpc
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Preetam/mysqllog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements SlowLogServer.
+type Server struct {
+	UnimplementedSlowLogServer
+
+	// ParserOptions configure the Parser of each stream, before the
+	// labels of the request.
+	ParserOptions []mysqllog.Option
+	// AggregatorOptions configure the Aggregator of each Aggregate call.
+	AggregatorOptions []mysqllog.AggregatorOption
+	// Collector, if set, parses the streams with a SourceLabel, so their
+	// events also go to its sink and Aggregator, and the state of each
+	// source is kept by it: the Parser options are those of the
+	// Collector, and the other labels of the request are ignored. Chunks
+	// refused by its WithCollectorMemoryLimit end the stream with
+	// RESOURCE_EXHAUSTED, to be sent again later.
+	Collector *mysqllog.Collector
+}
+
+// SourceLabel is the label naming the source of a stream for
+// Server.Collector.
+const SourceLabel = "source"
+
+// Parse implements SlowLogServer.
+func (s *Server) Parse(stream SlowLog_ParseServer) error {
+	return s.consume(stream.Recv, func(e mysqllog.LogEvent, first *ParseRequest) error {
+		return stream.Send(NewLogEvent(e, int(first.GetMaxStatementLength())))
+	})
+}
+
+// Aggregate implements SlowLogServer.
+func (s *Server) Aggregate(stream SlowLog_AggregateServer) error {
+	a := mysqllog.NewAggregator(s.AggregatorOptions...)
+	var first *ParseRequest
+	err := s.consume(stream.Recv, func(e mysqllog.LogEvent, req *ParseRequest) error {
+		first = req
+		a.Add(e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	response := &AggregateResponse{}
+	results := a.Results()
+	for _, r := range results {
+		response.Count += r.Count
+		response.TotalTime += r.TotalTime
+	}
+	// first is nil if no events were sent; the getters handle it.
+	if n := int(first.GetTopN()); n > 0 && len(results) > n {
+		results = results[:n]
+	}
+	maxStatement := int(first.GetMaxStatementLength())
+	for i := range results {
+		response.Queries = append(response.Queries, NewQueryStats(&results[i], maxStatement))
+	}
+	return stream.SendAndClose(response)
+}
+
+// consume parses the requests returned by recv until io.EOF, calling
+// emit with each event and the first request, which holds the options.
+func (s *Server) consume(recv func() (*ParseRequest, error), emit func(mysqllog.LogEvent, *ParseRequest) error) error {
+	var (
+		first   *ParseRequest
+		parser  *mysqllog.Parser
+		partial string
+		source  string
+	)
+	consume := func(line string) error {
+		if e := parser.ConsumeLine(line); e != nil {
+			return emit(e, first)
+		}
+		return nil
+	}
+	collected := func(e mysqllog.LogEvent) error {
+		return emit(e, first)
+	}
+	for {
+		req, err := recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first == nil && s.Collector != nil && req.GetLabels()[SourceLabel] != "" {
+			first = req
+			source = req.GetLabels()[SourceLabel]
+		}
+		if source != "" {
+			_, err := s.Collector.Consume(source, strings.NewReader(requestText(req)), collected)
+			if err == mysqllog.ErrCollectorFull {
+				return status.Error(codes.ResourceExhausted, err.Error())
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if first == nil {
+			first = req
+			opts := append([]mysqllog.Option{}, s.ParserOptions...)
+			if len(req.GetLabels()) > 0 {
+				opts = append(opts, mysqllog.WithLabels(req.GetLabels()))
+			}
+			parser = mysqllog.NewParser(opts...)
+		}
+
+		text := partial + requestText(req)
+		partial = ""
+		for text != "" {
+			i := strings.IndexByte(text, '\n')
+			if i < 0 {
+				// Keeps the end of the chunk for the next one.
+				partial = text
+				break
+			}
+			if err := consume(text[:i+1]); err != nil {
+				return err
+			}
+			text = text[i+1:]
+		}
+	}
+	if source != "" {
+		_, err := s.Collector.Flush(source, collected)
+		return err
+	}
+	if parser == nil {
+		return nil
+	}
+	if partial != "" {
+		if err := consume(partial); err != nil {
+			return err
+		}
+	}
+	if e := parser.Flush(); e != nil {
+		return emit(e, first)
+	}
+	return nil
+}
+
+// requestText returns the log text of req, a chunk or a line.
+func requestText(req *ParseRequest) string {
+	if line, ok := req.GetData().(*ParseRequest_Line); ok {
+		if !strings.HasSuffix(line.Line, "\n") {
+			return line.Line + "\n"
+		}
+		return line.Line
+	}
+	return string(req.GetChunk())
+}
+
+// commonKeys are the attributes with LogEvent fields.
+var commonKeys = map[string]bool{
+	"Timestamp":     true,
+	"User":          true,
+	"Host":          true,
+	"Database":      true,
+	"Statement":     true,
+	"Query_time":    true,
+	"Lock_time":     true,
+	"Rows_sent":     true,
+	"Rows_examined": true,
+	"Labels":        true,
+}
+
+// NewLogEvent converts e to a message, truncating the statement to
+// maxStatement characters unless it's 0 or less. Attributes without a
+// field of their own are in Extra.
+func NewLogEvent(e mysqllog.LogEvent, maxStatement int) *LogEvent {
+	m := &LogEvent{}
+	if ts, ok := mysqllog.EventTime(e); ok {
+		m.Timestamp = timestamppb.New(ts)
+	}
+	m.User, _ = e["User"].(string)
+	m.Host, _ = e["Host"].(string)
+	m.Database, _ = e["Database"].(string)
+	statement, _ := e["Statement"].(string)
+	m.Fingerprint = mysqllog.Fingerprint(statement)
+	m.Statement = mysqllog.Truncate(statement, maxStatement)
+	m.QueryTime, _ = e.Float64("Query_time")
+	m.LockTime, _ = e.Float64("Lock_time")
+	m.RowsSent, _ = e.Int64("Rows_sent")
+	m.RowsExamined, _ = e.Int64("Rows_examined")
+	m.Labels, _ = e["Labels"].(map[string]string)
+	for key, v := range e {
+		if commonKeys[key] {
+			continue
+		}
+		value, err := structpb.NewValue(extraValue(v))
+		if err != nil {
+			continue
+		}
+		if m.Extra == nil {
+			m.Extra = map[string]*structpb.Value{}
+		}
+		m.Extra[key] = value
+	}
+	return m
+}
+
+// NewQueryStats converts s to a message. See NewLogEvent for maxStatement.
+func NewQueryStats(s *mysqllog.QueryStats, maxStatement int) *QueryStats {
+	m := &QueryStats{
+		Fingerprint:  s.Fingerprint,
+		Count:        s.Count,
+		TotalTime:    s.TotalTime,
+		MinTime:      s.MinTime,
+		MaxTime:      s.MaxTime,
+		MeanTime:     s.MeanTime(),
+		P95Time:      s.P95Time(),
+		LockTime:     s.LockTime,
+		RowsSent:     s.RowsSent,
+		RowsExamined: s.RowsExamined,
+	}
+	if !s.FirstSeen.IsZero() {
+		m.FirstSeen = timestamppb.New(s.FirstSeen)
+		m.LastSeen = timestamppb.New(s.LastSeen)
+	}
+	if s.Sample != nil {
+		m.Sample = NewLogEvent(s.Sample, maxStatement)
+	}
+	return m
+}
+
+// extraValue converts attribute values structpb doesn't support.
+func extraValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, s := range v {
+			list[i] = s
+		}
+		return list
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for k, s := range v {
+			m[k] = s
+		}
+		return m
+	}
+	return v
+}
@@ -0,0 +1,123 @@
+//go:build grpc
+// +build grpc
+
+package mysqlloggrpc
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func dialServer(t *testing.T) (SlowLogClient, func()) {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	RegisterSlowLogServer(server, &Server{})
+	go server.Serve(listener)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSlowLogClient(conn), func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+// sendFixture streams the fixture in chunks that split lines and events.
+func sendFixture(t *testing.T, send func(*ParseRequest) error, first *ParseRequest) {
+	data, err := ioutil.ReadFile("../_test/before.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(data) > 0 {
+		n := 50
+		if n > len(data) {
+			n = len(data)
+		}
+		req := &ParseRequest{}
+		if first != nil {
+			req, first = first, nil
+		}
+		req.Data = &ParseRequest_Chunk{Chunk: data[:n]}
+		if err := send(req); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+}
+
+func TestParse(t *testing.T) {
+	client, stop := dialServer(t)
+	defer stop()
+
+	stream, err := client.Parse(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendFixture(t, stream.Send, &ParseRequest{MaxStatementLength: 20, Labels: map[string]string{"host": "db1"}})
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []*LogEvent
+	for {
+		e, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	e := events[0]
+	if e.User != "app" || e.Database != "shop" || e.RowsExamined != 1 || e.QueryTime != 0.01 {
+		t.Errorf("unexpected event %v", e)
+	}
+	if e.Statement != "SELECT * FROM ord..." || e.Fingerprint != "select * from orders where id = ?" {
+		t.Errorf("expected a truncated statement and a full fingerprint, got %q and %q", e.Statement, e.Fingerprint)
+	}
+	if e.Labels["host"] != "db1" || e.GetTimestamp().GetSeconds() != 1519898400 {
+		t.Errorf("unexpected labels %v or timestamp %v", e.Labels, e.Timestamp)
+	}
+	if e.Extra["Id"].GetNumberValue() != 7 {
+		t.Errorf("expected Id in extra, got %v", e.Extra)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	client, stop := dialServer(t)
+	defer stop()
+
+	stream, err := client.Aggregate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendFixture(t, stream.Send, &ParseRequest{TopN: 1})
+	response, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Count != 4 || len(response.Queries) != 1 {
+		t.Fatalf("unexpected response %v", response)
+	}
+	top := response.Queries[0]
+	if top.Fingerprint != "select * from customers where name like ?" || top.Sample.GetRowsExamined() != 500 {
+		t.Errorf("unexpected top query %v", top)
+	}
+}
@@ -0,0 +1,112 @@
+package mysqllog
+
+// DefaultHeaderLineLimit is how much of a header line attributes are read
+// from, unless set with WithHeaderLineLimit.
+const DefaultHeaderLineLimit = 64 << 10
+
+// WithHeaderLineLimit reads attributes from the first n bytes of each
+// header line only, cut before the last attribute that doesn't fit, and
+// ignores the rest, counting the lines in Stats.HeaderLinesCut and the
+// bytes in Stats.HeaderBytesCut. Parsing a header line takes time in
+// proportion to its length, so a corrupted or crafted one, as sent to
+// an IngestHandler, can't hold up the parser for long. Less than zero
+// reads header lines whole.
+func WithHeaderLineLimit(n int) Option {
+	return func(p *Parser) {
+		p.headerLimit = n
+	}
+}
+
+// limitHeaderLines cuts the header lines of lines at the limit of
+// WithHeaderLineLimit, in place.
+func (p *Parser) limitHeaderLines(lines []string) {
+	limit := p.headerLimit
+	switch {
+	case limit < 0:
+		return
+	case limit == 0:
+		limit = DefaultHeaderLineLimit
+	}
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		if line[0] != '#' || isAdminCommand(line) {
+			return
+		}
+		if len(line) <= limit {
+			continue
+		}
+		cut := limit
+		// An attribute cut in its value would get the wrong value, and a
+		// key without one the value on the next line.
+		if !isSpace(line[cut]) {
+			cut = lastField(line, cut)
+		}
+		for cut > 0 && isSpace(line[cut-1]) {
+			cut--
+		}
+		if cut > 0 && line[cut-1] == ':' {
+			cut = lastField(line, cut)
+		}
+		if cut == 0 {
+			// It's still a header line.
+			cut = 1
+		}
+		lines[i] = line[:cut] + "\n"
+		p.stats.HeaderLinesCut++
+		p.stats.HeaderBytesCut += int64(len(line) - cut)
+	}
+}
+
+// lastField returns where the spaces before the last field of line[:end]
+// start, or 0.
+func lastField(line string, end int) int {
+	for end > 0 && isSpace(line[end-1]) {
+		end--
+	}
+	for end > 0 && !isSpace(line[end-1]) {
+		end--
+	}
+	for end > 0 && isSpace(line[end-1]) {
+		end--
+	}
+	return end
+}
+
+// attributeMatches returns the indexes of the matches of attributesRe in
+// stream. The regexp runs on pieces of stream cut at spaces that aren't
+// after a colon, which no match spans, as it's much faster on short
+// inputs than on a long corrupted line.
+func attributeMatches(stream string) [][]int {
+	const piece = 512
+	if len(stream) <= piece {
+		return attributesRe.FindAllStringIndex(stream, -1)
+	}
+	var matches [][]int
+	for start := 0; start < len(stream); {
+		end := start + piece
+		if end >= len(stream) {
+			end = len(stream)
+		}
+		for end < len(stream) && !(isSpace(stream[end]) && !isSpace(stream[end-1]) && stream[end-1] != ':') {
+			end++
+		}
+		for _, m := range attributesRe.FindAllStringIndex(stream[start:end], -1) {
+			m[0] += start
+			m[1] += start
+			matches = append(matches, m)
+		}
+		start = end
+	}
+	return matches
+}
+
+// isSpace reports whether c is whitespace for \s of a regexp.
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\f', '\r':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package mysqllog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeaderLineLimit(t *testing.T) {
+	header := "# Query_time: 1.5  Lock_time: 0.1 Rows_sent: 12345 Rows_examined: 7\n"
+	content := "# User@Host: app[app] @ web1 []\n" + header + "SELECT 1;\n"
+
+	p := NewParser(WithHeaderLineLimit(len("# Query_time: 1.5  Lock_time: 0.1 Rows_sent: 123")))
+	events := parseAll(p, content)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	e := events[0]
+	if e["Query_time"] != 1.5 || e["Lock_time"] != 0.1 || e["User"] != "app" {
+		t.Errorf("unexpected event %v", e)
+	}
+	// The attribute cut in its value is left out rather than wrong.
+	for _, key := range []string{"Rows_sent", "Rows_examined"} {
+		if v, ok := e[key]; ok {
+			t.Errorf("expected no %s, got %v", key, v)
+		}
+	}
+	if s := p.Stats(); s.HeaderLinesCut != 1 || s.HeaderBytesCut != int64(len(" Rows_sent: 12345 Rows_examined: 7\n")) {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	for _, limit := range []int{0, -1} {
+		p := NewParser(WithHeaderLineLimit(limit))
+		if e := parseAll(p, content)[0]; e["Rows_examined"] != int64(7) || p.Stats().HeaderLinesCut != 0 {
+			t.Errorf("limit %d: unexpected event %v", limit, e)
+		}
+	}
+}
+
+func TestHostileHeaderLine(t *testing.T) {
+	line := "# Query_time: 2.0 " + strings.Repeat("a: b:c ", 1<<20/7) + "\n"
+	content := "# User@Host: app[app] @ web1 []\n" + line + "SELECT 1;\n"
+	for _, opts := range [][]Option{nil, {WithUnknownAttributes(KeepUnknown)}} {
+		// The fastest of a few runs, as the machine may be busy.
+		best := time.Hour
+		var events []LogEvent
+		var p *Parser
+		for i := 0; i < 3; i++ {
+			start := time.Now()
+			p = NewParser(opts...)
+			events = parseAll(p, content)
+			if d := time.Since(start); d < best {
+				best = d
+			}
+		}
+		if len(events) != 1 || events[0]["Query_time"] != 2.0 {
+			t.Fatalf("unexpected events %v", events)
+		}
+		if s := p.Stats(); s.HeaderLinesCut != 1 || s.HeaderBytesCut < int64(len(line)-DefaultHeaderLineLimit) {
+			t.Errorf("unexpected stats %+v", s)
+		}
+		if best > 50*time.Millisecond && !raceEnabled {
+			t.Errorf("parsing a 1 MB header line took %v", best)
+		}
+	}
+}
+
+func TestAttributeMatches(t *testing.T) {
+	// Long enough to be matched in pieces, with attributes across them.
+	var b strings.Builder
+	for i := 0; b.Len() < 4096; i++ {
+		b.WriteString(" Query_time:  1.5 Rows_sent:\t3, Bytes: x:y: z ::: _a_: 9\n\f")
+		b.WriteString(strings.Repeat("x", i%7))
+	}
+	stream := b.String()
+	got := attributeMatches(stream)
+	expected := attributesRe.FindAllStringIndex(stream, -1)
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d matches, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		if got[i][0] != expected[i][0] || got[i][1] != expected[i][1] {
+			t.Fatalf("match %d: expected %v, got %v", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestHeaderLineLimitWithoutSpaces(t *testing.T) {
+	content := "# User@Host: app[app] @ web1 []\n#" + strings.Repeat("x", 100) + "\n# Query_time: 1.5\nSELECT 1;\n"
+	events := parseAll(NewParser(WithHeaderLineLimit(50)), content)
+	if len(events) != 1 || events[0]["Query_time"] != 1.5 || events[0]["Statement"] != "SELECT 1;" {
+		t.Errorf("unexpected events %v", events)
+	}
+}
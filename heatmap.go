@@ -0,0 +1,259 @@
+package mysqllog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults for Heatmap.
+const (
+	DefaultHeatmapResolution = 5 * time.Minute
+	DefaultHeatmapRows       = 7 * 24 * 12
+)
+
+// DefaultHeatmapBounds are the Query_time column bounds of a Heatmap,
+// from 1ms to 100s by powers of 10.
+var DefaultHeatmapBounds = LogBounds(0.001, 100, 1)
+
+// LogBounds returns bounds from min to max seconds in steps of equal
+// ratio, perDecade of them per power of 10, for Heatmap.Bounds.
+func LogBounds(min, max float64, perDecade int) []float64 {
+	if min <= 0 || max < min || perDecade <= 0 {
+		return nil
+	}
+	var bounds []float64
+	for i := 0; ; i++ {
+		// Rounded to 12 significant digits, so 10^-3 is exactly 0.001.
+		b, _ := strconv.ParseFloat(strconv.FormatFloat(min*math.Pow(10, float64(i)/float64(perDecade)), 'g', 12, 64), 64)
+		if b > max*(1+1e-9) {
+			return bounds
+		}
+		bounds = append(bounds, b)
+	}
+}
+
+// HeatmapRow is the counts of a Heatmap row, one per column.
+type HeatmapRow struct {
+	Start  time.Time `json:"start"`
+	Counts []int64   `json:"counts"`
+}
+
+// Heatmap is a Sink counting events by time (rows) and Query_time
+// (columns), to see when slowness happens. Events without a timestamp
+// are ignored.
+//
+// Rows start at multiples of Resolution. At most MaxRows rows are kept,
+// up to the newest event: older rows are dropped as newer events come
+// in, and events for them are counted in Dropped.
+type Heatmap struct {
+	// Resolution is the length of a row, DefaultHeatmapResolution if
+	// zero.
+	Resolution time.Duration
+	// Bounds are the ascending Query_times in seconds between columns,
+	// DefaultHeatmapBounds if nil. The first column holds the events
+	// faster than Bounds[0], and column i those from Bounds[i-1] up to
+	// but excluding Bounds[i], so there's one more column than bounds.
+	Bounds []float64
+	// MaxRows is DefaultHeatmapRows if zero.
+	MaxRows int
+
+	// Dropped counts the events for rows that were already dropped.
+	Dropped int64
+
+	rows   map[int64][]int64
+	newest int64
+}
+
+func (h *Heatmap) resolution() time.Duration {
+	if h.Resolution <= 0 {
+		return DefaultHeatmapResolution
+	}
+	return h.Resolution
+}
+
+func (h *Heatmap) bounds() []float64 {
+	if h.Bounds == nil {
+		return DefaultHeatmapBounds
+	}
+	return h.Bounds
+}
+
+func (h *Heatmap) maxRows() int64 {
+	if h.MaxRows <= 0 {
+		return DefaultHeatmapRows
+	}
+	return int64(h.MaxRows)
+}
+
+// Write counts e in the cell of its timestamp and Query_time.
+func (h *Heatmap) Write(e LogEvent) error {
+	ts, ok := EventTime(e)
+	if !ok {
+		return nil
+	}
+	resolution := h.resolution()
+	key := ts.Truncate(resolution).UnixNano()
+	if h.rows == nil {
+		h.rows = map[int64][]int64{}
+		h.newest = key
+	}
+	if key > h.newest {
+		h.newest = key
+		for k := range h.rows {
+			if !h.inWindow(k) {
+				delete(h.rows, k)
+			}
+		}
+	}
+	if !h.inWindow(key) {
+		h.Dropped++
+		return nil
+	}
+	bounds := h.bounds()
+	counts := h.rows[key]
+	if counts == nil {
+		counts = make([]int64, len(bounds)+1)
+		h.rows[key] = counts
+	}
+	queryTime, _ := e.Float64("Query_time")
+	counts[sort.Search(len(bounds), func(i int) bool { return bounds[i] > queryTime })]++
+	return nil
+}
+
+// inWindow reports whether the row key is within MaxRows of the newest.
+func (h *Heatmap) inWindow(key int64) bool {
+	return (h.newest-key)/int64(h.resolution()) < h.maxRows()
+}
+
+// Close implements Sink.
+func (h *Heatmap) Close() error {
+	return nil
+}
+
+// Rows returns every row from the oldest kept to the newest, including
+// the empty ones in between.
+func (h *Heatmap) Rows() []HeatmapRow {
+	if len(h.rows) == 0 {
+		return nil
+	}
+	oldest := h.newest
+	for key := range h.rows {
+		if key < oldest {
+			oldest = key
+		}
+	}
+	step := int64(h.resolution())
+	rows := make([]HeatmapRow, 0, (h.newest-oldest)/step+1)
+	for key := oldest; key <= h.newest; key += step {
+		counts := h.rows[key]
+		if counts == nil {
+			counts = make([]int64, len(h.bounds())+1)
+		}
+		rows = append(rows, HeatmapRow{Start: time.Unix(0, key).UTC(), Counts: append([]int64(nil), counts...)})
+	}
+	return rows
+}
+
+// Columns returns the labels of the columns: "<" and the first bound,
+// then the lower bound of each other column, as in "<1ms", "1ms",
+// "10ms".
+func (h *Heatmap) Columns() []string {
+	bounds := h.bounds()
+	columns := make([]string, 0, len(bounds)+1)
+	for i, b := range bounds {
+		label := time.Duration(math.Round(b * 1e9)).String()
+		if i == 0 {
+			columns = append(columns, "<"+label)
+		}
+		columns = append(columns, label)
+	}
+	if len(bounds) == 0 {
+		columns = append(columns, "all")
+	}
+	return columns
+}
+
+// WriteCSV writes the rows as CSV with a header row of the column
+// labels. Times are RFC 3339 in UTC.
+func (h *Heatmap) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"start"}, h.Columns()...))
+	for _, row := range h.Rows() {
+		record := []string{row.Start.Format(time.RFC3339)}
+		for _, n := range row.Counts {
+			record = append(record, strconv.FormatInt(n, 10))
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the bounds, column labels and rows as a JSON object.
+func (h *Heatmap) WriteJSON(w io.Writer) error {
+	rows := h.Rows()
+	if rows == nil {
+		rows = []HeatmapRow{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Resolution float64      `json:"resolution_seconds"`
+		Bounds     []float64    `json:"bounds"`
+		Columns    []string     `json:"columns"`
+		Rows       []HeatmapRow `json:"rows"`
+	}{h.resolution().Seconds(), h.bounds(), h.Columns(), rows})
+}
+
+// heatmapShades are the blocks of WriteText, from empty to the largest
+// count.
+var heatmapShades = []string{" ", "░", "▒", "▓", "█"}
+
+// WriteText draws the rows for a terminal, a line each after a header
+// of the column labels, with each cell shaded by its count relative to
+// the largest.
+func (h *Heatmap) WriteText(w io.Writer) error {
+	columns := h.Columns()
+	width := 0
+	for _, label := range columns {
+		if len(label) > width {
+			width = len(label)
+		}
+	}
+	rows := h.Rows()
+	var max int64
+	for _, row := range rows {
+		for _, n := range row.Counts {
+			if n > max {
+				max = n
+			}
+		}
+	}
+	const layout = "2006-01-02 15:04"
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", len(layout)))
+	for _, label := range columns {
+		fmt.Fprintf(&b, " %-*s", width, label)
+	}
+	b.WriteString("\n")
+	for _, row := range rows {
+		b.WriteString(row.Start.Format(layout))
+		for _, n := range row.Counts {
+			shade := 0
+			if n > 0 {
+				shade = int((n*int64(len(heatmapShades)-1) + max - 1) / max)
+			}
+			b.WriteString(" " + strings.Repeat(heatmapShades[shade], width))
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,101 @@
+package mysqllog
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLogBounds(t *testing.T) {
+	if b := LogBounds(0.001, 100, 1); !reflect.DeepEqual(b, []float64{0.001, 0.01, 0.1, 1, 10, 100}) {
+		t.Errorf("unexpected bounds %v", b)
+	}
+	if b := LogBounds(1, 10, 2); !reflect.DeepEqual(b, []float64{1, 3.16227766017, 10}) {
+		t.Errorf("unexpected bounds %v", b)
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	base := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	h := &Heatmap{Resolution: 5 * time.Minute, Bounds: []float64{0.1, 1}}
+	type TestCase struct {
+		offset    time.Duration
+		queryTime float64
+	}
+	for _, c := range []TestCase{
+		// Row edges: 10:00 up to but excluding 10:05.
+		{0, 0.05},
+		{5*time.Minute - time.Nanosecond, 0.0999},
+		{5 * time.Minute, 0.1},
+		// Column edges: a bound is in the column it starts.
+		{5 * time.Minute, 0.5},
+		{5 * time.Minute, 1},
+		{20 * time.Minute, 30},
+	} {
+		h.Write(LogEvent{"Timestamp": base.Add(c.offset), "Query_time": c.queryTime})
+	}
+	h.Write(LogEvent{"Query_time": 1.0})
+
+	expected := []HeatmapRow{
+		{base, []int64{2, 0, 0}},
+		{base.Add(5 * time.Minute), []int64{0, 2, 1}},
+		{base.Add(10 * time.Minute), []int64{0, 0, 0}},
+		{base.Add(15 * time.Minute), []int64{0, 0, 0}},
+		{base.Add(20 * time.Minute), []int64{0, 0, 1}},
+	}
+	if rows := h.Rows(); !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected %v, got %v", expected, rows)
+	}
+	if columns := h.Columns(); !reflect.DeepEqual(columns, []string{"<100ms", "100ms", "1s"}) {
+		t.Errorf("unexpected columns %v", columns)
+	}
+
+	var buf bytes.Buffer
+	h.WriteCSV(&buf)
+	csv := "start,<100ms,100ms,1s\n2018-03-01T10:00:00Z,2,0,0\n2018-03-01T10:05:00Z,0,2,1\n" +
+		"2018-03-01T10:10:00Z,0,0,0\n2018-03-01T10:15:00Z,0,0,0\n2018-03-01T10:20:00Z,0,0,1\n"
+	if buf.String() != csv {
+		t.Errorf("expected\n%s\ngot\n%s", csv, buf.String())
+	}
+
+	buf.Reset()
+	h.WriteText(&buf)
+	text := "                 <100ms 100ms  1s    \n" +
+		"2018-03-01 10:00 ██████              \n" +
+		"2018-03-01 10:05        ██████ ▒▒▒▒▒▒\n" +
+		"2018-03-01 10:10                     \n" +
+		"2018-03-01 10:15                     \n" +
+		"2018-03-01 10:20               ▒▒▒▒▒▒\n"
+	if buf.String() != text {
+		t.Errorf("expected\n%s\ngot\n%s", text, buf.String())
+	}
+
+	buf.Reset()
+	h.WriteJSON(&buf)
+	var decoded struct {
+		Resolution float64      `json:"resolution_seconds"`
+		Bounds     []float64    `json:"bounds"`
+		Rows       []HeatmapRow `json:"rows"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.Resolution != 300 || len(decoded.Rows) != 5 {
+		t.Errorf("unexpected JSON %s", buf.String())
+	}
+}
+
+func TestHeatmapMaxRows(t *testing.T) {
+	base := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	h := &Heatmap{Resolution: time.Minute, MaxRows: 3}
+	for _, minute := range []int{0, 1, 2, 3, 0, 1, 4} {
+		h.Write(LogEvent{"Timestamp": base.Add(time.Duration(minute) * time.Minute), "Query_time": 0.5})
+	}
+	// Minute 0 was dropped at minute 3, and minute 1 at minute 4.
+	rows := h.Rows()
+	if len(rows) != 3 || !rows[0].Start.Equal(base.Add(2*time.Minute)) || h.Dropped != 1 {
+		t.Errorf("unexpected rows %v, %d dropped", rows, h.Dropped)
+	}
+	if rows[2].Counts[3] != 1 {
+		t.Errorf("expected the newest event in the 100ms column, got %v", rows[2])
+	}
+}
@@ -0,0 +1,30 @@
+package mysqllog
+
+// EventHook transforms a completed event. It returns the event to emit,
+// which may be e itself, modified or not, or another event, and false
+// to drop it.
+type EventHook func(e LogEvent) (LogEvent, bool)
+
+// WithEventHook runs hook on each completed event, after every other
+// option has been applied, to enrich it, such as with a GeoIP lookup of
+// "IP", or drop it. Hooks run in the order they're added, each on the
+// event returned by the previous one, and the first to drop the event
+// stops the chain. Unless the Parser has WithEventPool, events aren't
+// reused, so a hook may keep the event it's given.
+func WithEventHook(hook EventHook) Option {
+	return func(p *Parser) {
+		p.hooks = append(p.hooks, hook)
+	}
+}
+
+// runHooks passes e through the hooks, returning nil if one drops it.
+func (p *Parser) runHooks(e LogEvent) LogEvent {
+	for _, hook := range p.hooks {
+		var ok bool
+		if e, ok = hook(e); !ok || e == nil {
+			p.stats.HookDropped++
+			return nil
+		}
+	}
+	return e
+}
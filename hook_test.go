@@ -0,0 +1,45 @@
+package mysqllog
+
+import "testing"
+
+func TestWithEventHook(t *testing.T) {
+	input := "# User@Host: app[app] @  [10.0.0.1]\n# Query_time: 1\nSELECT 1;\n" +
+		"# User@Host: batch[batch] @  [10.0.0.2]\n# Query_time: 2\nSELECT 2;\n" +
+		"# User@Host: app[app] @  [10.0.0.3]\n# Query_time: 3\nSELECT 3;\n"
+	var order []string
+	p := NewParser(
+		WithEventHook(func(e LogEvent) (LogEvent, bool) {
+			order = append(order, "drop")
+			return e, e["User"] != "batch"
+		}),
+		WithEventHook(func(e LogEvent) (LogEvent, bool) {
+			order = append(order, "enrich")
+			e["Country"] = "NL"
+			return e, true
+		}),
+		WithEventHook(func(e LogEvent) (LogEvent, bool) {
+			order = append(order, "replace")
+			return LogEvent{"Statement": e["Statement"], "Country": e["Country"]}, true
+		}),
+	)
+	events := parseAll(p, input)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %v", events)
+	}
+	expected := LogEvent{"Statement": "SELECT 3;", "Country": "NL"}
+	if !events[1].Equal(expected) {
+		t.Errorf("unexpected event: %q", Diff(expected, events[1]))
+	}
+	expectedOrder := []string{"drop", "enrich", "replace", "drop", "drop", "enrich", "replace"}
+	if len(order) != len(expectedOrder) {
+		t.Fatalf("expected hooks to run as %v, got %v", expectedOrder, order)
+	}
+	for i := range order {
+		if order[i] != expectedOrder[i] {
+			t.Fatalf("expected hooks to run as %v, got %v", expectedOrder, order)
+		}
+	}
+	if stats := p.Stats(); stats.Events != 2 || stats.HookDropped != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
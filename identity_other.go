@@ -0,0 +1,16 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package mysqllog
+
+import "os"
+
+// FileIdentity returns an identifier of the file behind info that
+// survives renames, such as the device and inode on Unix, or "" if it
+// isn't known.
+func FileIdentity(info os.FileInfo) string {
+	return ""
+}
+
+// defaultRotation is what RotationAuto is here.
+const defaultRotation = RotationCreationTime
@@ -0,0 +1,24 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package mysqllog
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// FileIdentity returns an identifier of the file behind info that
+// survives renames, such as the device and inode on Unix, or "" if it
+// isn't known.
+func FileIdentity(info os.FileInfo) string {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatUint(uint64(st.Dev), 10) + ":" + strconv.FormatUint(uint64(st.Ino), 10)
+}
+
+// defaultRotation is what RotationAuto is here.
+const defaultRotation = RotationIdentity
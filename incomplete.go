@@ -0,0 +1,95 @@
+package mysqllog
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// IncompleteReason is why an event is known or likely to be missing
+// part of its entry. Incomplete events have "Incomplete" set to true and
+// the reason, as a string, in "IncompleteReason".
+type IncompleteReason string
+
+const (
+	// IncompleteEOF events were flushed at the end of the input inside
+	// their statement: in a string literal, or in a last line without a
+	// newline or a final semicolon, as when the log is still being
+	// written.
+	IncompleteEOF IncompleteReason = "eof"
+	// IncompleteTruncated events have a statement cut at the limit of
+	// WithStatementLimit.
+	IncompleteTruncated IncompleteReason = "truncated"
+	// IncompleteResync events were ended by a header line inside a
+	// string literal of their statement, where the parser resynchronizes
+	// on the next event, as in a corrupted section of the log.
+	IncompleteResync IncompleteReason = "resync"
+	// IncompleteIdleFlush events were flushed by WithIdleFlush before
+	// the next event showed where they end.
+	IncompleteIdleFlush IncompleteReason = "idle_flush"
+)
+
+// Incomplete returns why e is incomplete, and false if it isn't.
+func (e LogEvent) Incomplete() (IncompleteReason, bool) {
+	if incomplete, _ := e["Incomplete"].(bool); !incomplete {
+		return "", false
+	}
+	reason, _ := e["IncompleteReason"].(string)
+	return IncompleteReason(reason), true
+}
+
+// WithDropIncomplete drops incomplete events, counting them in
+// Stats.IncompleteDropped.
+func WithDropIncomplete() Option {
+	return func(p *Parser) {
+		p.dropIncomplete = true
+	}
+}
+
+// WithStatementLimit keeps at most n bytes of the statement lines of
+// each event, including its use and SET lines, and drops the rest, so
+// a runaway statement, such as a huge multi-row INSERT, can't take up
+// unbounded memory. Events with longer statements are incomplete with
+// IncompleteTruncated. Zero or less keeps statements whole.
+func WithStatementLimit(n int) Option {
+	return func(p *Parser) {
+		p.statementLimit = n
+	}
+}
+
+// limitLine returns the part of a statement line to keep under
+// WithStatementLimit, possibly empty.
+func (p *Parser) limitLine(line string) string {
+	if p.truncated {
+		return ""
+	}
+	if room := p.statementLimit - p.statementBytes; len(line) > room {
+		p.truncated = true
+		for room > 0 && !utf8.RuneStart(line[room]) {
+			room--
+		}
+		line = line[:room]
+	}
+	p.statementBytes += len(line)
+	return line
+}
+
+// incompleteAtEOF reports whether the pending statement is cut by the
+// end of the input.
+func (p *Parser) incompleteAtEOF() bool {
+	if p.quote != 0 {
+		return true
+	}
+	if len(p.lines) == 0 {
+		return false
+	}
+	last := p.lines[len(p.lines)-1]
+	return !strings.HasSuffix(last, "\n") && !strings.HasSuffix(strings.TrimSpace(last), ";")
+}
+
+// markIncomplete flags e as incomplete for reason, if it isn't empty.
+func markIncomplete(e LogEvent, reason IncompleteReason) {
+	if reason != "" {
+		e["Incomplete"] = true
+		e["IncompleteReason"] = string(reason)
+	}
+}
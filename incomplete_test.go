@@ -0,0 +1,207 @@
+package mysqllog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseLines parses content like parseAll, including a last line
+// without a newline.
+func parseLines(p *Parser, content string) []LogEvent {
+	events := []LogEvent{}
+	reader := bufio.NewReader(strings.NewReader(content))
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if event := p.ConsumeLine(line); event != nil {
+				events = append(events, event)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if event := p.Flush(); event != nil {
+		events = append(events, event)
+	}
+	return events
+}
+
+const incompleteHeader = "# Time: 2023-08-01T10:00:00.000000Z\n# User@Host: app[app] @ web1 []\n# Query_time: 1.0 Lock_time: 0.0 Rows_sent: 1 Rows_examined: 1\n"
+
+func TestIncomplete(t *testing.T) {
+	type TestCase struct {
+		name       string
+		opts       []Option
+		content    string
+		reasons    []IncompleteReason
+		statements []string
+	}
+	testCases := []TestCase{
+		{
+			name:       "complete",
+			content:    slowEvent(1) + slowEvent(2),
+			reasons:    []IncompleteReason{"", ""},
+			statements: []string{"SELECT 1;", "SELECT 2;"},
+		},
+		{
+			name:       "complete without a newline",
+			content:    incompleteHeader + "SELECT 1;",
+			reasons:    []IncompleteReason{""},
+			statements: []string{"SELECT 1;"},
+		},
+		{
+			name:       "eof in a string",
+			content:    incompleteHeader + "SELECT 'a\nb\n",
+			reasons:    []IncompleteReason{IncompleteEOF},
+			statements: []string{"SELECT 'a\nb"},
+		},
+		{
+			name:       "eof in a line",
+			content:    incompleteHeader + "SELECT * FROM t\nWHERE",
+			reasons:    []IncompleteReason{IncompleteEOF},
+			statements: []string{"SELECT * FROM t\nWHERE"},
+		},
+		{
+			name:       "truncated",
+			opts:       []Option{WithStatementLimit(20)},
+			content:    incompleteHeader + "INSERT INTO t VALUES\n(1), (2), (3);\n" + slowEvent(2),
+			reasons:    []IncompleteReason{IncompleteTruncated, ""},
+			statements: []string{"INSERT INTO t VALUES", "SELECT 2;"},
+		},
+		{
+			name:       "truncated in a rune",
+			opts:       []Option{WithStatementLimit(17)},
+			content:    incompleteHeader + "SELECT 'héllo wörld';\n",
+			reasons:    []IncompleteReason{IncompleteTruncated},
+			statements: []string{"SELECT 'héllo w"},
+		},
+		{
+			name:       "truncated at eof",
+			opts:       []Option{WithStatementLimit(10)},
+			content:    incompleteHeader + "SELECT 'abc\n",
+			reasons:    []IncompleteReason{IncompleteTruncated},
+			statements: []string{"SELECT 'ab"},
+		},
+		{
+			name:       "resync",
+			content:    incompleteHeader + "SELECT 'abc\n" + slowEvent(2),
+			reasons:    []IncompleteReason{IncompleteResync, ""},
+			statements: []string{"SELECT 'abc", "SELECT 2;"},
+		},
+		{
+			name:       "dropped",
+			opts:       []Option{WithDropIncomplete()},
+			content:    incompleteHeader + "SELECT 'abc\n" + slowEvent(2) + incompleteHeader + "SELECT",
+			reasons:    []IncompleteReason{""},
+			statements: []string{"SELECT 2;"},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			events := parseLines(NewParser(c.opts...), c.content)
+			if len(events) != len(c.reasons) {
+				t.Fatalf("expected %d events, got %d", len(c.reasons), len(events))
+			}
+			for i, e := range events {
+				reason, incomplete := e.Incomplete()
+				if reason != c.reasons[i] || incomplete != (c.reasons[i] != "") {
+					t.Errorf("event %d: expected reason %q, got %q, %v", i, c.reasons[i], reason, incomplete)
+				}
+				if got := e["Statement"]; got != c.statements[i] {
+					t.Errorf("event %d: expected statement %q, got %q", i, c.statements[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestIncompleteStats(t *testing.T) {
+	content := incompleteHeader + "SELECT 'abc\n" + slowEvent(2) + incompleteHeader + "SELECT"
+	p := NewParser()
+	parseLines(p, content)
+	if s := p.Stats(); s.Events != 3 || s.Incomplete != 2 || s.IncompleteDropped != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	p = NewParser(WithDropIncomplete())
+	parseLines(p, content)
+	if s := p.Stats(); s.Events != 1 || s.Incomplete != 2 || s.IncompleteDropped != 2 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestIncompleteSinks(t *testing.T) {
+	events := parseLines(NewParser(), incompleteHeader+"SELECT 'abc\n"+slowEvent(2))
+	var buf bytes.Buffer
+	json := NewJSONWriter(&buf, Original)
+	logfmt := NewLogfmtWriter(&buf, Fields("Incomplete", "IncompleteReason")...)
+	for _, sink := range []Sink{json, logfmt} {
+		if err := sink.Write(events[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := buf.String()
+	for _, expected := range []string{`"Incomplete":true`, `"IncompleteReason":"resync"`, "incomplete=true incompletereason=resync"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %s in %s", expected, out)
+		}
+	}
+}
+
+func TestIdleFlush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+	appendFile(t, path, slowEvent(1))
+
+	// The clock only moves once the tail waits for the next poll, so
+	// the file is always read before the pending event is found idle.
+	clock := NewManualClock(time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC))
+	wait := func() {
+		clock.WaitTimers(1)
+		clock.Add(time.Second)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan LogEvent, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- TailFile(ctx, path, func(e LogEvent) { events <- e }, append(fastPoll, WithIdleFlush(30*time.Millisecond), WithTailClock(clock))...)
+	}()
+	expect := func(statement string, reason IncompleteReason) {
+		select {
+		case e := <-events:
+			if got, _ := e.Incomplete(); e["Statement"] != statement || got != reason {
+				t.Errorf("expected %q with reason %q, got %q with %q", statement, reason, e["Statement"], got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %q", statement)
+		}
+	}
+	wait()
+	expect("SELECT 1;", IncompleteIdleFlush)
+	appendFile(t, path, slowEvent(2)+slowEvent(3))
+	wait()
+	expect("SELECT 2;", "")
+	select {
+	case e := <-events:
+		t.Fatalf("expected SELECT 3; to be pending, got %v", e["Statement"])
+	default:
+	}
+	wait()
+	expect("SELECT 3;", IncompleteIdleFlush)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
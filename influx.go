@@ -0,0 +1,166 @@
+package mysqllog
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultInfluxMeasurement is the measurement of the points written in
+// InfluxDB line protocol.
+const DefaultInfluxMeasurement = "mysql_slowlog"
+
+// AppendLineProtocol appends e to dst as a point in InfluxDB line
+// protocol, and reports whether it did. The point has the user, db,
+// fingerprint (see FingerprintTag) and host tags of e when it has them,
+// along with tags; the query_time, lock_time, rows_sent and
+// rows_examined fields; and the EventTime of e in nanoseconds. Events
+// without a timestamp or without any of the fields aren't appended,
+// since the server would take the time of the write instead.
+func AppendLineProtocol(dst []byte, measurement string, tags map[string]string, e LogEvent) ([]byte, bool) {
+	ts, ok := EventTime(e)
+	if !ok {
+		return dst, false
+	}
+	all := make(map[string]string, len(tags)+4)
+	for key, value := range tags {
+		all[key] = value
+	}
+	for _, tag := range []struct{ key, value string }{
+		{"user", stringAttribute(e, "User")},
+		{"db", stringAttribute(e, "Database")},
+		{"fingerprint", FingerprintTag(e)},
+		{"host", stringAttribute(e, "Host")},
+	} {
+		if tag.value != "" {
+			all[tag.key] = tag.value
+		}
+	}
+
+	var fields []byte
+	for _, field := range []struct{ name, key string }{
+		{"query_time", "Query_time"},
+		{"lock_time", "Lock_time"},
+	} {
+		if v, ok := e.Float64(field.key); ok {
+			fields = appendField(fields, field.name)
+			fields = strconv.AppendFloat(fields, v, 'f', -1, 64)
+		}
+	}
+	for _, field := range []struct{ name, key string }{
+		{"rows_sent", "Rows_sent"},
+		{"rows_examined", "Rows_examined"},
+	} {
+		if v, ok := e.Int64(field.key); ok {
+			fields = appendField(fields, field.name)
+			fields = strconv.AppendInt(fields, v, 10)
+			fields = append(fields, 'i')
+		}
+	}
+	if len(fields) == 0 {
+		return dst, false
+	}
+	return appendPoint(dst, measurement, all, fields, ts), true
+}
+
+// appendPoint appends a line of measurement with tags in key order,
+// fields and ts.
+func appendPoint(dst []byte, measurement string, tags map[string]string, fields []byte, ts time.Time) []byte {
+	if measurement == "" {
+		measurement = DefaultInfluxMeasurement
+	}
+	dst = append(dst, measurementEscaper.Replace(measurement)...)
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if tags[key] == "" {
+			continue
+		}
+		dst = append(dst, ',')
+		dst = append(dst, tagEscaper.Replace(key)...)
+		dst = append(dst, '=')
+		dst = append(dst, tagEscaper.Replace(tags[key])...)
+	}
+	dst = append(dst, ' ')
+	dst = append(dst, fields...)
+	dst = append(dst, ' ')
+	dst = strconv.AppendInt(dst, ts.UnixNano(), 10)
+	return append(dst, '\n')
+}
+
+func appendField(fields []byte, name string) []byte {
+	if len(fields) > 0 {
+		fields = append(fields, ',')
+	}
+	fields = append(fields, name...)
+	return append(fields, '=')
+}
+
+// Line protocol can't hold line breaks, so they become spaces.
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\ `, "\r", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\ `, "\r", `\ `)
+)
+
+func stringAttribute(e LogEvent, key string) string {
+	s, _ := e[key].(string)
+	return s
+}
+
+// WriteTimelineLineProtocol writes entries as points in InfluxDB line
+// protocol at the time of their bucket, with the fingerprint tag and
+// the query_time field.
+func WriteTimelineLineProtocol(w io.Writer, measurement string, tags map[string]string, entries []TimelineEntry) error {
+	var buf []byte
+	for _, entry := range entries {
+		all := make(map[string]string, len(tags)+1)
+		for key, value := range tags {
+			all[key] = value
+		}
+		if entry.Fingerprint != "" {
+			all["fingerprint"] = shortChecksum(entry.Fingerprint)
+		}
+		fields := strconv.AppendFloat([]byte("query_time="), entry.QueryTime, 'f', -1, 64)
+		buf = appendPoint(buf, measurement, all, fields, entry.Bucket)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// LineProtocolWriter is a Sink writing events in InfluxDB line
+// protocol, as with AppendLineProtocol, for tools such as the influx
+// CLI or Telegraf. Events that can't be written are skipped.
+type LineProtocolWriter struct {
+	w           *bufio.Writer
+	measurement string
+	tags        map[string]string
+	buf         []byte
+}
+
+// NewLineProtocolWriter returns a LineProtocolWriter writing points of
+// measurement, DefaultInfluxMeasurement if empty, with tags to w.
+func NewLineProtocolWriter(w io.Writer, measurement string, tags map[string]string) *LineProtocolWriter {
+	return &LineProtocolWriter{w: bufio.NewWriter(w), measurement: measurement, tags: tags}
+}
+
+// Write writes e as a line.
+func (l *LineProtocolWriter) Write(e LogEvent) error {
+	var ok bool
+	l.buf, ok = AppendLineProtocol(l.buf[:0], l.measurement, l.tags, e)
+	if !ok {
+		return nil
+	}
+	l.w.Write(l.buf)
+	return l.w.Flush()
+}
+
+// Close implements Sink. It doesn't close the underlying writer.
+func (l *LineProtocolWriter) Close() error {
+	return l.w.Flush()
+}
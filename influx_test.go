@@ -0,0 +1,54 @@
+package mysqllog
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestAppendLineProtocol(t *testing.T) {
+	base := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	fingerprint := shortChecksum("select ?")
+	type TestCase struct {
+		event    LogEvent
+		expected string
+	}
+	for _, c := range []TestCase{
+		{
+			LogEvent{"User": "app", "Database": "shop", "Host": "db1", "Query_time": 1.5, "Lock_time": 0.001,
+				"Rows_sent": int64(1), "Rows_examined": int64(10), "Statement": "SELECT 1", "Timestamp": base},
+			"mysql_slowlog,cluster=main,db=shop,fingerprint=" + fingerprint + ",host=db1,user=app " +
+				"query_time=1.5,lock_time=0.001,rows_sent=1i,rows_examined=10i 1519898400000000000\n",
+		},
+		{
+			LogEvent{"User": "a b,c=d", "Host": "x\ny", "Query_time": 2.0, "Timestamp": base.Unix()},
+			`mysql_slowlog,cluster=main,host=x\ y,user=a\ b\,c\=d query_time=2 1519898400000000000` + "\n",
+		},
+		// No timestamp, or no fields.
+		{LogEvent{"Query_time": 1.0}, ""},
+		{LogEvent{"User": "app", "Timestamp": base}, ""},
+	} {
+		line, ok := AppendLineProtocol(nil, "", map[string]string{"cluster": "main"}, c.event)
+		if string(line) != c.expected || ok != (c.expected != "") {
+			t.Errorf("%v: expected %q, got %q, %v", c.event, c.expected, line, ok)
+		}
+	}
+}
+
+func TestLineProtocolWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewLineProtocolWriter(&buf, "slow log", nil)
+	w.Write(LogEvent{"Query_time": 1.0, "Timestamp": int64(1)})
+	w.Write(LogEvent{"Query_time": 1.0})
+	w.Close()
+	if expected := `slow\ log query_time=1 1000000000` + "\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	base := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	WriteTimelineLineProtocol(&buf, "", nil, []TimelineEntry{{Bucket: base, QueryTime: 0.25, Fingerprint: "select ?"}})
+	if expected := "mysql_slowlog,fingerprint=" + shortChecksum("select ?") + " query_time=0.25 1519898400000000000\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
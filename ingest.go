@@ -0,0 +1,184 @@
+package mysqllog
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Defaults for IngestHandler.
+const (
+	DefaultIngestBodySize = 16 << 20
+	DefaultIngestIdle     = DefaultCollectorIdle
+	DefaultIngestSources  = DefaultCollectorSources
+)
+
+// SourceHeader identifies the source of an upload to IngestHandler.
+const SourceHeader = "X-Source-Id"
+
+// IngestOption configures IngestHandler.
+type IngestOption func(*ingestHandler)
+
+// WithMaxBodySize stops reading uploads at n bytes, after decompression,
+// and responds with 413 Request Entity Too Large. The events before the
+// limit are still written.
+func WithMaxBodySize(n int64) IngestOption {
+	return func(h *ingestHandler) {
+		h.maxBody = n
+	}
+}
+
+// WithSourceIdle drops the state of a source after d without uploads,
+// flushing its pending event.
+func WithSourceIdle(d time.Duration) IngestOption {
+	return func(h *ingestHandler) {
+		h.idle = d
+	}
+}
+
+// WithMaxSources keeps the state of at most n sources, flushing and
+// dropping the least recently seen one to make room.
+func WithMaxSources(n int) IngestOption {
+	return func(h *ingestHandler) {
+		h.maxSources = n
+	}
+}
+
+// WithParserOptions configures the Parser of each source.
+func WithParserOptions(opts ...Option) IngestOption {
+	return func(h *ingestHandler) {
+		h.parserOpts = opts
+	}
+}
+
+// WithCollector parses uploads with c, such as one shared with the gRPC
+// server, in place of a Collector of the handler's own. The sink and the
+// source options of IngestHandler are then ignored: those of c apply.
+func WithCollector(c *Collector) IngestOption {
+	return func(h *ingestHandler) {
+		h.collector = c
+	}
+}
+
+// IngestHandler returns a handler that parses raw slow query log text
+// POSTed by remote agents and writes the events to sink, with "Source"
+// set to the SourceHeader of the upload. The body may be gzipped with
+// Content-Encoding: gzip.
+//
+// Uploads are parsed by a Collector, so each source has its own Parser
+// and an event or line split across uploads is put back together. The
+// pending event is written when the next one starts, when the source is
+// dropped for being idle or to make room for others, or when the request
+// has the query parameter "flush=1", such as the final upload of a file.
+// The response is a JSON object with the number of "events" written.
+// Uploads refused by the WithCollectorMemoryLimit of a shared Collector
+// get 429 Too Many Requests, to be sent again later.
+//
+// Uploads from different sources are parsed concurrently; uploads from
+// the same source are serialized. Writes to sink are serialized.
+func IngestHandler(sink Sink, opts ...IngestOption) http.Handler {
+	h := &ingestHandler{
+		maxBody:    DefaultIngestBodySize,
+		idle:       DefaultIngestIdle,
+		maxSources: DefaultIngestSources,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.collector == nil {
+		h.collector = NewCollector(sink,
+			WithCollectorIdle(h.idle),
+			WithCollectorMaxSources(h.maxSources),
+			WithCollectorParserOptions(h.parserOpts...))
+	}
+	return h
+}
+
+type ingestHandler struct {
+	maxBody    int64
+	idle       time.Duration
+	maxSources int
+	parserOpts []Option
+
+	collector *Collector
+}
+
+func (h *ingestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.Header.Get(SourceHeader)
+	if id == "" {
+		http.Error(w, "missing "+SourceHeader+" header", http.StatusBadRequest)
+		return
+	}
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, "invalid gzip body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer zr.Close()
+		body = zr
+	}
+	body = &maxReader{r: body, n: h.maxBody}
+
+	events, err := h.collector.Consume(id, body, nil)
+	if err == nil && r.URL.Query().Get("flush") == "1" {
+		var flushed int
+		flushed, err = h.collector.Flush(id, nil)
+		events += flushed
+	}
+
+	status := http.StatusOK
+	switch {
+	case err == errBodyTooLarge:
+		status = http.StatusRequestEntityTooLarge
+	case err == ErrCollectorFull:
+		status = http.StatusTooManyRequests
+		w.Header().Set("Retry-After", "1")
+	case err != nil:
+		status = http.StatusInternalServerError
+	}
+	response := struct {
+		Events int    `json:"events"`
+		Error  string `json:"error,omitempty"`
+	}{Events: events}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+var errBodyTooLarge = errors.New("mysqllog: upload too large")
+
+// maxReader reads at most n bytes from r, failing with errBodyTooLarge
+// if there are more.
+type maxReader struct {
+	r io.Reader
+	n int64
+}
+
+func (m *maxReader) Read(p []byte) (int, error) {
+	if m.n <= 0 {
+		var b [1]byte
+		if n, err := m.r.Read(b[:]); n == 0 {
+			return 0, err
+		}
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > m.n {
+		p = p[:m.n]
+	}
+	n, err := m.r.Read(p)
+	m.n -= int64(n)
+	return n, err
+}
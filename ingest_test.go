@@ -0,0 +1,157 @@
+package mysqllog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// statementsOf parses path and returns the statements of its events.
+func statementsOf(t *testing.T, path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p := &Parser{}
+	var statements []string
+	reader := bufio.NewReader(f)
+	for line, err := reader.ReadString('\n'); err == nil; line, err = reader.ReadString('\n') {
+		if e := p.ConsumeLine(line); e != nil {
+			statements = append(statements, e["Statement"].(string))
+		}
+	}
+	if e := p.Flush(); e != nil {
+		statements = append(statements, e["Statement"].(string))
+	}
+	return statements
+}
+
+func upload(t *testing.T, handler http.Handler, source, target string, body []byte, gzipped bool) (int, int) {
+	if gzipped {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		zw.Close()
+		body = buf.Bytes()
+	}
+	req := httptest.NewRequest("POST", target, bytes.NewReader(body))
+	req.Header.Set(SourceHeader, source)
+	if gzipped {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var response struct {
+		Events int `json:"events"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	return w.Code, response.Events
+}
+
+func TestIngestHandler(t *testing.T) {
+	sink := &recordingSink{}
+	handler := IngestHandler(sink)
+
+	files := map[string]string{"db1": "./_test/before.txt", "db2": "./_test/after.txt"}
+	chunks := map[string][][]byte{}
+	for source, path := range files {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Chunks split lines and events across uploads.
+		for len(data) > 0 {
+			n := 37
+			if n > len(data) {
+				n = len(data)
+			}
+			chunks[source] = append(chunks[source], data[:n])
+			data = data[n:]
+		}
+	}
+
+	total := 0
+	for i := 0; len(chunks["db1"]) > 0 || len(chunks["db2"]) > 0; i++ {
+		for _, source := range []string{"db1", "db2"} {
+			if len(chunks[source]) == 0 {
+				continue
+			}
+			chunk := chunks[source][0]
+			chunks[source] = chunks[source][1:]
+			target := "/ingest"
+			if len(chunks[source]) == 0 {
+				target += "?flush=1"
+			}
+			code, events := upload(t, handler, source, target, chunk, source == "db2")
+			if code != http.StatusOK {
+				t.Fatalf("upload %d of %s: unexpected status %d", i, source, code)
+			}
+			total += events
+		}
+	}
+
+	if total != len(sink.events) {
+		t.Errorf("expected the responses to count %d events, got %d", len(sink.events), total)
+	}
+	for source, path := range files {
+		expected := statementsOf(t, path)
+		var got []string
+		for _, e := range sink.events {
+			if e["Source"] == source {
+				got = append(got, e["Statement"].(string))
+			}
+		}
+		if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("%s: expected %q, got %q", source, expected, got)
+		}
+	}
+}
+
+func TestIngestHandlerLimits(t *testing.T) {
+	sink := &recordingSink{}
+	handler := IngestHandler(sink, WithMaxBodySize(64), WithSourceIdle(time.Minute), WithMaxSources(2)).(*ingestHandler)
+	clock := NewManualClock(time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC))
+	handler.collector.pool.Clock = clock
+
+	event := "# User@Host: app[app] @ web1 []\n# Query_time: 1.0\nSELECT 1;\n"
+	if code, _ := upload(t, handler, "db1", "/", []byte(strings.Repeat(event, 3)), true); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a large body, got %d", code)
+	}
+
+	for _, source := range []string{"db2", "db3"} {
+		if code, _ := upload(t, handler, source, "/", []byte(event), false); code != http.StatusOK {
+			t.Fatalf("unexpected status %d", code)
+		}
+	}
+	// db1 was dropped to make room for db3, flushing the event before
+	// the limit.
+	if len(sink.events) != 1 || sink.events[0]["Source"] != "db1" {
+		t.Fatalf("expected db1 to be flushed, got %v", sink.events)
+	}
+
+	clock.Add(2 * time.Minute)
+	if code, _ := upload(t, handler, "db4", "/", nil, false); code != http.StatusOK {
+		t.Fatalf("unexpected status %d", code)
+	}
+	if len(sink.events) != 3 || len(handler.collector.pool.Sources()) != 1 {
+		t.Errorf("expected idle sources to be flushed and dropped, got %d events and %d sources", len(sink.events), len(handler.collector.pool.Sources()))
+	}
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(event))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without %s, got %d", SourceHeader, w.Code)
+	}
+}
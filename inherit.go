@@ -0,0 +1,110 @@
+package mysqllog
+
+import "container/list"
+
+// DefaultInheritedConnections is the number of connections whose User,
+// Host and IP are remembered by WithUserHostInheritance.
+const DefaultInheritedConnections = 10000
+
+// WithUserHostInheritance fills in User, Host and IP for events without
+// a "# User@Host" line, such as some admin statements, from the last
+// event of the same connection, and sets "UserInferred" to true. The
+// connection is the event's Id, or Thread_id if it has none. Events
+// without either inherit from the event just before them.
+//
+// At most maxConnections connections are remembered, the least recently
+// seen being forgotten first, or DefaultInheritedConnections if
+// maxConnections is 0 or less. Everything is forgotten at a server
+// startup banner, since connection ids start over.
+func WithUserHostInheritance(maxConnections int) Option {
+	return func(p *Parser) {
+		if maxConnections <= 0 {
+			maxConnections = DefaultInheritedConnections
+		}
+		p.inherit = &userHostInheritance{
+			max:         maxConnections,
+			lru:         list.New(),
+			connections: map[int64]*list.Element{},
+		}
+	}
+}
+
+// connectionContext is what an event inherits from its connection.
+type connectionContext struct {
+	id   int64
+	user interface{}
+	host interface{}
+	ip   interface{}
+}
+
+type userHostInheritance struct {
+	max         int
+	lru         *list.List
+	connections map[int64]*list.Element
+	last        *connectionContext
+	// banner is the line number of a startup banner not acted on yet.
+	banner int
+}
+
+// apply fills in e, whose first line is line, from its connection, or
+// remembers the connection of e if it has a User.
+func (h *userHostInheritance) apply(e LogEvent, line int) {
+	if h.banner > 0 && line > h.banner {
+		h.lru.Init()
+		h.connections = map[int64]*list.Element{}
+		h.last = nil
+		h.banner = 0
+	}
+	id, hasID := ConnectionID(e)
+	if _, ok := e["User"]; ok {
+		c := &connectionContext{id: id, user: e["User"], host: e["Host"], ip: e["IP"]}
+		h.last = c
+		if hasID {
+			h.remember(c)
+		}
+		return
+	}
+
+	c := h.last
+	if hasID {
+		c = nil
+		if el, ok := h.connections[id]; ok {
+			h.lru.MoveToFront(el)
+			c = el.Value.(*connectionContext)
+		}
+	}
+	if c == nil {
+		return
+	}
+	e["User"] = c.user
+	if c.host != nil {
+		e["Host"] = c.host
+	}
+	if c.ip != nil {
+		e["IP"] = c.ip
+	}
+	e["UserInferred"] = true
+	h.last = c
+}
+
+func (h *userHostInheritance) remember(c *connectionContext) {
+	if el, ok := h.connections[c.id]; ok {
+		el.Value = c
+		h.lru.MoveToFront(el)
+		return
+	}
+	h.connections[c.id] = h.lru.PushFront(c)
+	if h.lru.Len() > h.max {
+		oldest := h.lru.Back()
+		h.lru.Remove(oldest)
+		delete(h.connections, oldest.Value.(*connectionContext).id)
+	}
+}
+
+// checkBanner notes if line, the nth, is a server startup banner, so
+// events after it forget every connection.
+func (h *userHostInheritance) checkBanner(line string, n int) {
+	if isBanner(line) {
+		h.banner = n
+	}
+}
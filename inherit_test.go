@@ -0,0 +1,63 @@
+package mysqllog
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestUserHostInheritance(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/inherit.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := parseAll(NewParser(WithUserHostInheritance(0)), string(b))
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	if _, ok := events[0]["UserInferred"]; ok {
+		t.Errorf("expected the first event's User to be its own")
+	}
+	for _, e := range events {
+		if e["User"] != "app" || e["Host"] != "web1" || e["IP"] != "10.0.0.5" {
+			t.Errorf("expected app@web1, got %v", e)
+		}
+	}
+	for _, e := range events[1:] {
+		if e["UserInferred"] != true {
+			t.Errorf("expected UserInferred, got %v", e)
+		}
+	}
+
+	if _, ok := parseAll(&Parser{}, string(b))[1]["User"]; ok {
+		t.Errorf("expected no inheritance by default")
+	}
+}
+
+func TestUserHostInheritanceReset(t *testing.T) {
+	event := func(header string) string {
+		return "# Time: 2017-12-24T02:41:51.316510Z\n" + header + "# Query_time: 1.0  Lock_time: 0.0 Rows_sent: 0  Rows_examined: 0\nSELECT 1;\n"
+	}
+	log := event("# User@Host: a[a] @ h1 []  Id: 1\n") +
+		event("# User@Host: b[b] @ h2 []  Id: 2\n") +
+		event("# User@Host: c[c] @ h3 []  Id: 3\n") +
+		// Connection 1 was forgotten to keep 2.
+		event("# Thread_id: 1\n") +
+		event("# Thread_id: 3\n") +
+		"/usr/sbin/mysqld, Version: 5.7.16-log (MySQL Community Server (GPL)). started with:\n" +
+		event("# Thread_id: 3\n") +
+		event("")
+	events := parseAll(NewParser(WithUserHostInheritance(2)), log)
+	if len(events) != 7 {
+		t.Fatalf("expected 7 events, got %d", len(events))
+	}
+	type TestCase struct {
+		User     interface{}
+		Inferred bool
+	}
+	for i, c := range []TestCase{{"a", false}, {"b", false}, {"c", false}, {nil, false}, {"c", true}, {nil, false}, {nil, false}} {
+		e := events[i]
+		if e["User"] != c.User || (e["UserInferred"] == true) != c.Inferred {
+			t.Errorf("event %d: expected %v (inferred %v), got %v", i, c.User, c.Inferred, e)
+		}
+	}
+}
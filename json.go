@@ -0,0 +1,74 @@
+package mysqllog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// JSONWriter is a Sink writing each event as a line of JSON, with keys
+// in the order of LogEvent.Keys, and those of nested maps sorted.
+type JSONWriter struct {
+	w         *bufio.Writer
+	style     KeyStyle
+	durations DurationFormat
+	buf       []byte
+}
+
+// JSONOption configures a JSONWriter.
+type JSONOption func(*JSONWriter)
+
+// WithDurationFormat writes time.Duration values, from WithDurations,
+// in format instead of DurationSeconds.
+func WithDurationFormat(format DurationFormat) JSONOption {
+	return func(j *JSONWriter) {
+		j.durations = format
+	}
+}
+
+// NewJSONWriter returns a JSONWriter writing to w with keys renamed to
+// style. Events already renamed by WithKeyStyle are written as they
+// are with Original.
+func NewJSONWriter(w io.Writer, style KeyStyle, opts ...JSONOption) *JSONWriter {
+	j := &JSONWriter{w: bufio.NewWriter(w), style: style}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// Write writes e as a line.
+func (j *JSONWriter) Write(e LogEvent) error {
+	nested := LogEvent(EncodeDurations(e, j.durations).Nested())
+	var names map[string]string
+	if j.style != Original {
+		names = styledKeys(nested, j.style)
+	}
+	b := append(j.buf[:0], '{')
+	for i, key := range nested.Keys() {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		name := key
+		if names != nil {
+			name = names[key]
+		}
+		k, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(nested[key])
+		if err != nil {
+			return err
+		}
+		b = append(append(append(b, k...), ':'), v...)
+	}
+	j.buf = append(b, '}', '\n')
+	j.w.Write(j.buf)
+	return j.w.Flush()
+}
+
+// Close implements Sink. It doesn't close the underlying writer.
+func (j *JSONWriter) Close() error {
+	return j.w.Flush()
+}
@@ -0,0 +1,42 @@
+package mysqllog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestJSONWriter(t *testing.T) {
+	log, err := ioutil.ReadFile("./_test/rds.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w := NewJSONWriter(&buf, Original)
+	camel := NewJSONWriter(&buf, CamelLower)
+	p := NewParser(WithLabels(map[string]string{"env": "prod", "az": "b"}), WithFullScanDetection(1, 1))
+	events := parseAll(p, string(log))
+	for _, e := range events {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	camel.Write(events[0])
+	if err := camel.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		if err := ioutil.WriteFile("./_test/json.txt", buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := ioutil.ReadFile("./_test/json.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), golden) {
+		t.Errorf("output differs from _test/json.txt (rerun with -update), got\n%s", buf.String())
+	}
+}
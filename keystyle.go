@@ -0,0 +1,139 @@
+package mysqllog
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// KeyStyle is a naming convention for event keys in output.
+type KeyStyle int
+
+const (
+	// Original keeps keys as the parser sets them, such as Query_time.
+	Original KeyStyle = iota
+	// SnakeLower writes keys in lowercase with words separated by
+	// underscores, such as query_time and probable_full_scan.
+	SnakeLower
+	// CamelLower writes keys in camel case starting in lowercase, such
+	// as queryTime and probableFullScan.
+	CamelLower
+)
+
+func (s KeyStyle) String() string {
+	switch s {
+	case Original:
+		return "original"
+	case SnakeLower:
+		return "snake_lower"
+	case CamelLower:
+		return "camel_lower"
+	}
+	return "unknown"
+}
+
+// Key returns key in style s. Words are split at underscores and where
+// a lowercase letter or digit is followed by an uppercase one, so
+// Rows_examined is rows_examined or rowsExamined, and InnoDB_IO_r_ops
+// is inno_db_io_r_ops or innoDbIoROps.
+func (s KeyStyle) Key(key string) string {
+	if s == Original {
+		return key
+	}
+	words := keyWords(key)
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if s == CamelLower && i > 0 {
+			w = strings.ToUpper(w[:1]) + w[1:]
+		}
+		words[i] = w
+	}
+	if s == CamelLower {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words, "_")
+}
+
+func keyWords(key string) []string {
+	var words []string
+	start := 0
+	runes := []rune(key)
+	for i, c := range runes {
+		switch {
+		case c == '_':
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+		case i > start && unicode.IsUpper(c) && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])):
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// Fields returns fields for keys, named in style s, in the order given.
+func (s KeyStyle) Fields(keys ...string) []Field {
+	fields := make([]Field, len(keys))
+	for i, key := range keys {
+		fields[i] = Field{Name: s.Key(key), Key: key}
+	}
+	return fields
+}
+
+// WithKeyStyle renames the top-level keys of emitted events, both
+// parsed attributes and ones such as User, Timestamp and Statement, to
+// style. Keys of nested maps, such as Labels, are kept. A key is kept as
+// it is if its new name is another key of the event or was taken by a
+// key earlier in sort order, so keys never collide.
+//
+// Aggregator and the other sinks of this package read the original
+// keys, so styled events are meant for output only. The writers look
+// fields up by their styled key too.
+func WithKeyStyle(style KeyStyle) Option {
+	return func(p *Parser) {
+		p.keyStyle = style
+	}
+}
+
+// styleKeys renames the keys of e in place to style.
+func styleKeys(e LogEvent, style KeyStyle) {
+	if style == Original {
+		return
+	}
+	renamed := make(map[string]interface{}, len(e))
+	for key, name := range styledKeys(e, style) {
+		renamed[name] = e[key]
+	}
+	for key := range e {
+		delete(e, key)
+	}
+	for key, v := range renamed {
+		e[key] = v
+	}
+}
+
+// styledKeys returns the name in style of each key of e. A key whose
+// name is taken keeps its own.
+func styledKeys(e LogEvent, style KeyStyle) map[string]string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	names := make(map[string]string, len(e))
+	taken := make(map[string]bool, len(e))
+	for _, key := range keys {
+		name := style.Key(key)
+		if _, exists := e[name]; taken[name] || (exists && name != key) {
+			name = key
+		}
+		names[key] = name
+		taken[name] = true
+	}
+	return names
+}
@@ -0,0 +1,110 @@
+package mysqllog
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestKeyStyle(t *testing.T) {
+	type TestCase struct {
+		Key   string
+		Snake string
+		Camel string
+	}
+	for _, c := range []TestCase{
+		{"Query_time", "query_time", "queryTime"},
+		{"Rows_examined", "rows_examined", "rowsExamined"},
+		{"Timestamp", "timestamp", "timestamp"},
+		{"QC_Hit", "qc_hit", "qcHit"},
+		{"InnoDB_IO_r_ops", "inno_db_io_r_ops", "innoDbIoROps"},
+		{"ProbableFullScan", "probable_full_scan", "probableFullScan"},
+		{"Cop_proc_p90", "cop_proc_p90", "copProcP90"},
+		{"query_time", "query_time", "queryTime"},
+	} {
+		if key := SnakeLower.Key(c.Key); key != c.Snake {
+			t.Errorf("expected %s as %q, got %q", c.Key, c.Snake, key)
+		}
+		if key := CamelLower.Key(c.Key); key != c.Camel {
+			t.Errorf("expected %s as %q, got %q", c.Key, c.Camel, key)
+		}
+		if key := Original.Key(c.Key); key != c.Key {
+			t.Errorf("expected %s to be kept, got %q", c.Key, key)
+		}
+	}
+}
+
+func TestKeyStyleNoCollisions(t *testing.T) {
+	keys := []string{"User", "Host", "Id", "Database", "Timestamp", "Statement", "Labels", "Unknown",
+		"Verb", "Tables", "Severity", "ProbableFullScan", "UnboundedWrite"}
+	for key := range attributeTypes {
+		keys = append(keys, key)
+	}
+	for key := range tidbAttributeTypes {
+		keys = append(keys, key)
+	}
+	// Names of the same attribute in different forks, such as QC_Hit and
+	// QC_hit, never appear together.
+	canonical := map[string]string{}
+	for _, c := range canonicalKeys {
+		for _, source := range c.sources {
+			canonical[source] = c.key
+		}
+	}
+	for _, style := range []KeyStyle{SnakeLower, CamelLower} {
+		seen := map[string]string{}
+		for _, key := range keys {
+			name := style.Key(key)
+			if other, ok := seen[name]; ok && other != key && (canonical[key] == "" || canonical[key] != canonical[other]) {
+				t.Errorf("%s: %s and %s are both %s", style, key, other, name)
+			}
+			seen[name] = key
+		}
+	}
+
+	// Keys that would collide are kept.
+	e := LogEvent{"Query_time": 1.0, "query_time": 2.0, "Rows_sent": int64(1)}
+	styleKeys(e, SnakeLower)
+	if len(e) != 3 || e["Query_time"] != 1.0 || e["query_time"] != 2.0 || e["rows_sent"] != int64(1) {
+		t.Errorf("unexpected keys %v", e)
+	}
+}
+
+func TestWithKeyStyle(t *testing.T) {
+	original := parseAll(&Parser{}, content)
+	styled := parseAll(NewParser(WithKeyStyle(SnakeLower), WithLabels(map[string]string{"Env": "prod"})), content)
+	if len(styled) != len(original) {
+		t.Fatalf("expected %d events, got %d", len(original), len(styled))
+	}
+	for i, e := range styled {
+		if len(e) != len(original[i])+1 {
+			t.Errorf("expected every key to be kept, got %v", e)
+		}
+		for key, v := range original[i] {
+			if _, ok := e[SnakeLower.Key(key)]; !ok {
+				t.Errorf("expected %s as %s, got %v", key, SnakeLower.Key(key), v)
+			}
+		}
+		if labels, _ := e["labels"].(map[string]string); labels["Env"] != "prod" {
+			t.Errorf("expected label keys to be kept, got %v", e["labels"])
+		}
+	}
+
+	var logfmt, csv, js bytes.Buffer
+	NewLogfmtWriter(&logfmt, SnakeLower.Fields("Query_time", "User")...).Write(styled[0])
+	NewCSVWriter(&csv, CamelLower.Fields("Query_time", "Rows_examined")...).Write(styled[0])
+	NewJSONWriter(&js, CamelLower).Write(original[0])
+	if expected := "query_time=0.020363 user=rdsadmin\n"; logfmt.String() != expected {
+		t.Errorf("expected logfmt %q, got %q", expected, logfmt.String())
+	}
+	if expected := "queryTime,rowsExamined\n0.020363,1\n"; csv.String() != expected {
+		t.Errorf("expected CSV %q, got %q", expected, csv.String())
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["queryTime"] != 0.020363 || len(decoded) != len(original[0]) {
+		t.Errorf("unexpected JSON %s", js.String())
+	}
+}
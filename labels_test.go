@@ -0,0 +1,43 @@
+package mysqllog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithLabels(t *testing.T) {
+	labels := map[string]string{
+		"hostname": "db-1",
+		"cluster":  "main",
+		"User":     "label-user",
+	}
+	p := NewParser(WithLabels(labels))
+	// Mutating the caller's map after construction must not affect events.
+	labels["cluster"] = "changed"
+
+	events := parseAll(p, content+content)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	expected := map[string]string{
+		"hostname": "db-1",
+		"cluster":  "main",
+		"User":     "label-user",
+	}
+	for _, e := range events {
+		if !reflect.DeepEqual(e["Labels"], expected) {
+			t.Errorf("expected labels %v, got %v", expected, e["Labels"])
+		}
+		// Parsed attributes win over labels with the same name.
+		if e["User"] != "rdsadmin" {
+			t.Errorf("expected parsed User to be kept, got %v", e["User"])
+		}
+	}
+
+	// Each event gets its own copy.
+	events[0]["Labels"].(map[string]string)["hostname"] = "other"
+	if events[1]["Labels"].(map[string]string)["hostname"] != "db-1" {
+		t.Error("expected labels not to be shared between events")
+	}
+}
@@ -0,0 +1,161 @@
+package mysqllog
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultLockMinutes is the number of lock-heavy minutes shown in
+// reports.
+const DefaultLockMinutes = 10
+
+// LockMinute summarizes the lock time of the events in a minute.
+type LockMinute struct {
+	Minute    time.Time
+	Count     int64
+	QueryTime float64
+	LockTime  float64
+}
+
+// LockPercent returns the share of Query_time spent waiting for locks.
+func (m *LockMinute) LockPercent() float64 {
+	return percent(m.LockTime, m.QueryTime)
+}
+
+// LockPercent returns the share of Query_time spent waiting for locks,
+// from 0 to 100.
+func (s *QueryStats) LockPercent() float64 {
+	return percent(s.LockTime, s.TotalTime)
+}
+
+// LockSummary is the lock contention analysis of an Aggregator.
+type LockSummary struct {
+	LockTime  float64
+	QueryTime float64
+	// Top are the fingerprints with the most lock time, in descending
+	// order.
+	Top []QueryStats
+	// Minutes are the minutes with lock time, in chronological order.
+	Minutes []LockMinute
+}
+
+// LockPercent returns the share of Query_time spent waiting for locks.
+func (l *LockSummary) LockPercent() float64 {
+	return percent(l.LockTime, l.QueryTime)
+}
+
+// HeaviestMinutes returns the n minutes with the most lock time, in
+// chronological order, to line up with DDL or backups.
+func (l *LockSummary) HeaviestMinutes(n int) []LockMinute {
+	minutes := append([]LockMinute(nil), l.Minutes...)
+	sort.SliceStable(minutes, func(i, j int) bool { return minutes[i].LockTime > minutes[j].LockTime })
+	if n > 0 && len(minutes) > n {
+		minutes = minutes[:n]
+	}
+	sort.Slice(minutes, func(i, j int) bool { return minutes[i].Minute.Before(minutes[j].Minute) })
+	return minutes
+}
+
+// LockContention returns the lock time per fingerprint and per minute,
+// with the topN fingerprints with the most lock time, or all of them if
+// topN is 0 or less. Lock_time is counted up to Query_time, since
+// rounding can make it larger. Minutes come from event timestamps;
+// events without one are only counted per fingerprint.
+func (a *Aggregator) LockContention(topN int) LockSummary {
+	summary := LockSummary{QueryTime: a.totalTime}
+	for _, s := range a.Results() {
+		summary.LockTime += s.LockTime
+		if s.LockTime > 0 {
+			summary.Top = append(summary.Top, s)
+		}
+	}
+	sort.SliceStable(summary.Top, func(i, j int) bool { return summary.Top[i].LockTime > summary.Top[j].LockTime })
+	if topN > 0 && len(summary.Top) > topN {
+		summary.Top = summary.Top[:topN]
+	}
+	for _, m := range a.lockMinutes {
+		if m.LockTime > 0 {
+			summary.Minutes = append(summary.Minutes, *m)
+		}
+	}
+	sort.Slice(summary.Minutes, func(i, j int) bool { return summary.Minutes[i].Minute.Before(summary.Minutes[j].Minute) })
+	return summary
+}
+
+// clampedLockTime returns the Lock_time of e, at most queryTime.
+func clampedLockTime(e LogEvent, queryTime float64) float64 {
+	lockTime, _ := e.Float64("Lock_time")
+	if lockTime > queryTime {
+		return queryTime
+	}
+	return lockTime
+}
+
+func (a *Aggregator) addLockMinute(e LogEvent, queryTime float64) {
+	ts, ok := EventTime(e)
+	if !ok {
+		return
+	}
+	minute := ts.Truncate(time.Minute)
+	m := a.lockMinutes[minute.Unix()]
+	if m == nil {
+		m = &LockMinute{Minute: minute}
+		a.lockMinutes[minute.Unix()] = m
+	}
+	m.Count++
+	m.QueryTime += queryTime
+	m.LockTime += clampedLockTime(e, queryTime)
+}
+
+func (a *Aggregator) mergeLockMinutes(other *Aggregator) {
+	for key, o := range other.lockMinutes {
+		m := a.lockMinutes[key]
+		if m == nil {
+			m = &LockMinute{Minute: o.Minute}
+			a.lockMinutes[key] = m
+		}
+		m.Count += o.Count
+		m.QueryTime += o.QueryTime
+		m.LockTime += o.LockTime
+	}
+}
+
+// WithLockContention adds a lock contention section for summary, from
+// Aggregator.LockContention, to the text and Markdown reports.
+func WithLockContention(summary LockSummary) ReportOption {
+	return func(o *reportOptions) {
+		o.locks = &summary
+	}
+}
+
+func writeLockSection(ew *errWriter, l *LockSummary) {
+	ew.printf("\n# Lock contention\n")
+	ew.printf("# %.6fs of lock time, %.1f%% of query time\n", l.LockTime, l.LockPercent())
+	ew.printf("# Rank Lock time          Lock%%  Calls   Query\n")
+	for i, s := range l.Top {
+		ew.printf("# %4d %11.6fs %5.1f%% %7d %s\n", i+1, s.LockTime, s.LockPercent(), s.Count, Truncate(s.Fingerprint, 60))
+	}
+	if minutes := l.HeaviestMinutes(DefaultLockMinutes); len(minutes) > 0 {
+		ew.printf("# Lock-heavy minutes\n")
+		for _, m := range minutes {
+			ew.printf("# %s %11.6fs %5.1f%% %7d\n", m.Minute.Format(DefaultTimestampLayout), m.LockTime, m.LockPercent(), m.Count)
+		}
+	}
+}
+
+func writeMarkdownLockSection(ew *errWriter, l *LockSummary) {
+	ew.printf("\n### Lock contention\n\n")
+	ew.printf("%.6fs of lock time, %.1f%% of query time\n\n", l.LockTime, l.LockPercent())
+	ew.printf("| Rank | Lock time | Lock %% | Calls | Query |\n")
+	ew.printf("| ---: | --------: | -----: | ----: | :---- |\n")
+	for i, s := range l.Top {
+		ew.printf("| %d | %.6fs | %.1f | %d | %s |\n", i+1, s.LockTime, s.LockPercent(), s.Count, markdownEscape(Truncate(s.Fingerprint, 60)))
+	}
+	if minutes := l.HeaviestMinutes(DefaultLockMinutes); len(minutes) > 0 {
+		ew.printf("\n| Minute | Lock time | Lock %% | Events |\n")
+		ew.printf("| :----- | --------: | -----: | -----: |\n")
+		for _, m := range minutes {
+			ew.printf("| %s | %.6fs | %.1f | %d |\n", m.Minute.Format(DefaultTimestampLayout), m.LockTime, m.LockPercent(), m.Count)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package mysqllog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func lockAggregator() *Aggregator {
+	base := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	a := NewAggregator()
+	for _, e := range []LogEvent{
+		{"Statement": "UPDATE t SET a = 1 WHERE id = 1", "Query_time": 2.0, "Lock_time": 1.5, "Timestamp": base},
+		// Rounding can make Lock_time larger than Query_time.
+		{"Statement": "UPDATE t SET a = 2 WHERE id = 2", "Query_time": 0.5, "Lock_time": 0.6, "Timestamp": base.Add(90 * time.Second)},
+		{"Statement": "SELECT * FROM u WHERE id = 1", "Query_time": 1.0, "Lock_time": 0.1, "Timestamp": base.Add(100 * time.Second)},
+		{"Statement": "SELECT 1", "Query_time": 0.5, "Lock_time": 0.0},
+	} {
+		a.Add(e)
+	}
+	return a
+}
+
+func TestLockContention(t *testing.T) {
+	summary := lockAggregator().LockContention(1)
+
+	if summary.LockTime != 2.1 || summary.QueryTime != 4.0 {
+		t.Errorf("expected 2.1s of 4s, got %v of %v", summary.LockTime, summary.QueryTime)
+	}
+	if len(summary.Top) != 1 || summary.Top[0].Fingerprint != "update t set a = ? where id = ?" {
+		t.Fatalf("unexpected top fingerprints %v", summary.Top)
+	}
+	if top := summary.Top[0]; top.LockTime != 2.0 || top.LockPercent() != 80 {
+		t.Errorf("expected the lock time to be clamped, got %v (%v%%)", top.LockTime, top.LockPercent())
+	}
+
+	if len(summary.Minutes) != 2 {
+		t.Fatalf("expected 2 minutes, got %v", summary.Minutes)
+	}
+	second := summary.Minutes[1]
+	if !second.Minute.Equal(time.Date(2018, 3, 1, 10, 1, 0, 0, time.UTC)) || second.Count != 2 || second.LockTime != 0.6 {
+		t.Errorf("unexpected minute %+v", second)
+	}
+	if heaviest := summary.HeaviestMinutes(1); len(heaviest) != 1 || heaviest[0].LockTime != 1.5 {
+		t.Errorf("unexpected heaviest minutes %v", heaviest)
+	}
+
+	merged := NewAggregator()
+	merged.Merge(lockAggregator())
+	merged.Merge(lockAggregator())
+	if m := merged.LockContention(0).Minutes[0]; m.Count != 2 || m.LockTime != 3.0 {
+		t.Errorf("expected minutes to be merged, got %+v", m)
+	}
+}
+
+func TestLockContentionReport(t *testing.T) {
+	a := lockAggregator()
+	var text, markdown bytes.Buffer
+	if err := WriteReport(&text, a.Results(), WithLockContention(a.LockContention(0))); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMarkdownReport(&markdown, a.Results(), WithLockContention(a.LockContention(0))); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"# Lock contention\n# 2.100000s of lock time, 52.5% of query time\n",
+		"#    1    2.000000s  80.0%       2 update t set a = ? where id = ?\n",
+		"# 2018-03-01 10:01:00    0.600000s  40.0%       2\n",
+	} {
+		if !strings.Contains(text.String(), expected) {
+			t.Errorf("expected the report to contain %q, got\n%s", expected, text.String())
+		}
+	}
+	for _, expected := range []string{
+		"### Lock contention\n\n2.100000s of lock time, 52.5% of query time\n",
+		"| 2 | 0.100000s | 10.0 | 1 | select \\* from u where id = ? |\n",
+		"| 2018-03-01 10:00:00 | 1.500000s | 75.0 | 1 |\n",
+	} {
+		if !strings.Contains(markdown.String(), expected) {
+			t.Errorf("expected the Markdown report to contain %q, got\n%s", expected, markdown.String())
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package mysqllog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LogfmtWriter is a Sink writing each event as a logfmt line, such as
+//
+//	ts="2017-12-24 02:42:00" user=app query_time=1.234 statement="SELECT 1"
+//
+// Fields missing from an event are left out of its line.
+type LogfmtWriter struct {
+	w      *bufio.Writer
+	fields []Field
+}
+
+// NewLogfmtWriter returns a LogfmtWriter writing fields to w in order,
+// or DefaultFields if none are given.
+func NewLogfmtWriter(w io.Writer, fields ...Field) *LogfmtWriter {
+	if len(fields) == 0 {
+		fields = DefaultFields
+	}
+	return &LogfmtWriter{w: bufio.NewWriter(w), fields: fields}
+}
+
+// Write writes e as a line.
+func (l *LogfmtWriter) Write(e LogEvent) error {
+	l.w.WriteString(FormatLogfmt(e, l.fields))
+	l.w.WriteByte('\n')
+	return l.w.Flush()
+}
+
+// Close implements Sink. It doesn't close the underlying writer.
+func (l *LogfmtWriter) Close() error {
+	return l.w.Flush()
+}
+
+// FormatLogfmt returns fields of e as a logfmt line, without a newline.
+func FormatLogfmt(e LogEvent, fields []Field) string {
+	var b strings.Builder
+	flat := e.Flatten(FlattenOptions{})
+	for _, f := range fields {
+		value, ok := FieldValue(flat, f)
+		if !ok {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(f.Name)
+		b.WriteByte('=')
+		writeLogfmtValue(&b, value)
+	}
+	return b.String()
+}
+
+// writeLogfmtValue writes value, quoting it if it's empty or contains
+// spaces, quotes, equals signs or control characters.
+func writeLogfmtValue(b *strings.Builder, value string) {
+	if value != "" && !strings.ContainsAny(value, " =\"\\") && !hasControl(value) {
+		b.WriteString(value)
+		return
+	}
+	b.WriteByte('"')
+	for _, c := range value {
+		switch c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < ' ' || c == 0x7f {
+				fmt.Fprintf(b, `\u%04x`, c)
+				continue
+			}
+			b.WriteRune(c)
+		}
+	}
+	b.WriteByte('"')
+}
+
+func hasControl(s string) bool {
+	for _, c := range s {
+		if c < ' ' || c == 0x7f {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package mysqllog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestLogfmtWriter(t *testing.T) {
+	events := []LogEvent{
+		{
+			"Timestamp":     "2017-12-24 02:42:00",
+			"User":          "app",
+			"Database":      "shop",
+			"Query_time":    1.234,
+			"Rows_examined": int64(123456789012),
+			"Statement":     "SELECT * FROM t WHERE name = \"a\" AND x='b=c'",
+		},
+		{"User": "", "Query_time": 1e-7, "Statement": "SELECT\n\t1;\r\n-- \\ done"},
+		{"Host": "db=1", "Rows_sent": int64(0)},
+	}
+
+	var buf bytes.Buffer
+	w := NewLogfmtWriter(&buf)
+	for _, e := range events {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	custom := NewLogfmtWriter(&buf, Fields("Rows_examined", "User")...)
+	custom.Write(events[0])
+	if err := custom.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		if err := ioutil.WriteFile("./_test/logfmt.txt", buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := ioutil.ReadFile("./_test/logfmt.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), golden) {
+		t.Errorf("output differs from _test/logfmt.txt (rerun with -update), got\n%s", buf.String())
+	}
+}
@@ -0,0 +1,30 @@
+package mysqllog
+
+// Logger receives debug messages about parsing. *log.Logger implements
+// it, and SlogLogger adapts a *slog.Logger on Go 1.21 and later.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger logs the Parser's state transitions to l, such as entering
+// the header or the statement of an event, each event emitted with its
+// number of header and statement lines, lines skipped outside events,
+// such as server startup banners, and attribute values that can't be
+// converted. Line numbers count every line given to the Parser; an
+// event is logged at the line that ends it. Without a logger, nothing is
+// formatted.
+func WithLogger(l Logger) Option {
+	return func(p *Parser) {
+		p.logger = l
+	}
+}
+
+// headerLines returns the number of header lines at the start of lines.
+func headerLines(lines []string) int {
+	for i, line := range lines {
+		if line != "" && (line[0] != '#' || isAdminCommand(line)) {
+			return i
+		}
+	}
+	return len(lines)
+}
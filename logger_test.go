@@ -0,0 +1,48 @@
+package mysqllog
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// recordingLogger is a Logger keeping the messages it's given.
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+const loggerContent = `/usr/sbin/mysqld, Version: 5.7.16-log (MySQL Community Server (GPL)). started with:
+# Time: 2017-12-24T02:41:51.316510Z
+# Query_time: 0.019019  Lock_time: 0.000000 Rows_sent: x  Rows_examined: 0
+SELECT 1;
+# Time: 2017-12-24T02:41:53.213732Z
+# User@Host: rdsadmin[rdsadmin] @ localhost [127.0.0.1]  Id:     3
+SELECT
+  2;
+`
+
+var loggerMessages = []string{
+	`mysqllog: line 1: skipped line outside an event: "/usr/sbin/mysqld, Version: 5.7.16-log (MySQL Community Server (GPL)). started with:"`,
+	"mysqllog: line 2: entering header",
+	"mysqllog: line 4: entering query",
+	`mysqllog: can't convert Rows_sent value "x"`,
+	"mysqllog: line 5: event emitted with 2 header lines and 1 statement lines",
+	"mysqllog: line 5: entering header",
+	"mysqllog: line 7: entering query",
+	"mysqllog: line 8: event emitted with 2 header lines and 2 statement lines",
+}
+
+func TestWithLogger(t *testing.T) {
+	l := &recordingLogger{}
+	events := parseAll(NewParser(WithLogger(l)), loggerContent)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if !reflect.DeepEqual(l.messages, loggerMessages) {
+		t.Errorf("expected messages\n%q\ngot\n%q", loggerMessages, l.messages)
+	}
+}
@@ -0,0 +1,125 @@
+package mysqllog
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// DefaultLateness is the lateness window used by MergeStreams.
+const DefaultLateness = 5 * time.Second
+
+// MergeStreams merges events from streams into a single stream ordered
+// by event timestamp, allowing DefaultLateness of skew between sources.
+// See MergeStreamsWithin.
+func MergeStreams(streams ...<-chan LogEvent) <-chan LogEvent {
+	return MergeStreamsWithin(DefaultLateness, streams...)
+}
+
+// MergeStreamsWithin merges events from streams into a single stream
+// ordered by event timestamp (see EventTime). Events are buffered until
+// the newest timestamp seen is more than lateness ahead of them, so
+// sources that are skewed by less than lateness are reordered correctly.
+// An event older than one already emitted is sent immediately with
+// "OutOfOrder" set to true. Events without a timestamp are passed
+// through as they arrive. The returned channel is closed once all
+// streams are closed and the buffer is drained.
+//
+// Events with the same timestamp are ordered by the "Seq" set by
+// WithSequence, then by the order of their streams in streams, so the
+// merge is the same from run to run, then by arrival.
+//
+// Use WithLabels on each source's Parser to tell the origins apart.
+func MergeStreamsWithin(lateness time.Duration, streams ...<-chan LogEvent) <-chan LogEvent {
+	in := make(chan timedEvent)
+	var wg sync.WaitGroup
+	wg.Add(len(streams))
+	for i, stream := range streams {
+		go func(i int, stream <-chan LogEvent) {
+			defer wg.Done()
+			for e := range stream {
+				in <- timedEvent{event: e, stream: i}
+			}
+		}(i, stream)
+	}
+	go func() {
+		wg.Wait()
+		close(in)
+	}()
+
+	out := make(chan LogEvent)
+	go func() {
+		defer close(out)
+		var (
+			pending  eventHeap
+			seq      int
+			newest   time.Time
+			lastSent time.Time
+		)
+		for te := range in {
+			e := te.event
+			ts, ok := EventTime(e)
+			if !ok {
+				out <- e
+				continue
+			}
+			if !lastSent.IsZero() && ts.Before(lastSent) {
+				e["OutOfOrder"] = true
+				out <- e
+				continue
+			}
+			te.ts, te.seq = ts, seq
+			te.sub, _ = e.Int64("Seq")
+			heap.Push(&pending, te)
+			seq++
+			if ts.After(newest) {
+				newest = ts
+			}
+			for pending.Len() > 0 && newest.Sub(pending[0].ts) > lateness {
+				next := heap.Pop(&pending).(timedEvent)
+				lastSent = next.ts
+				out <- next.event
+			}
+		}
+		for pending.Len() > 0 {
+			out <- heap.Pop(&pending).(timedEvent).event
+		}
+	}()
+	return out
+}
+
+// timedEvent is an event of the stream at index stream, with sub its
+// "Seq", and seq its arrival order.
+type timedEvent struct {
+	event  LogEvent
+	ts     time.Time
+	sub    int64
+	stream int
+	seq    int
+}
+
+// eventHeap is a min-heap of events by timestamp, then "Seq", stream
+// and arrival order.
+type eventHeap []timedEvent
+
+func (h eventHeap) Len() int { return len(h) }
+func (h eventHeap) Less(i, j int) bool {
+	a, b := h[i], h[j]
+	switch {
+	case !a.ts.Equal(b.ts):
+		return a.ts.Before(b.ts)
+	case a.sub != b.sub:
+		return a.sub < b.sub
+	case a.stream != b.stream:
+		return a.stream < b.stream
+	}
+	return a.seq < b.seq
+}
+func (h eventHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *eventHeap) Push(x interface{}) { *h = append(*h, x.(timedEvent)) }
+func (h *eventHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
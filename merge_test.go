@@ -0,0 +1,76 @@
+package mysqllog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeStreamsWithin(t *testing.T) {
+	base := int64(1514083320)
+	a := make(chan LogEvent)
+	b := make(chan LogEvent)
+	merged := MergeStreamsWithin(5*time.Second, a, b)
+
+	go func() {
+		// b's clock runs three seconds behind a's.
+		for i := int64(0); i < 50; i++ {
+			a <- LogEvent{"Timestamp": base + 2*i, "Labels": map[string]string{"host": "a"}}
+			b <- LogEvent{"Timestamp": base + 2*i + 1 - 3, "Labels": map[string]string{"host": "b"}}
+		}
+		close(a)
+		close(b)
+	}()
+
+	var events []LogEvent
+	for e := range merged {
+		events = append(events, e)
+	}
+	if len(events) != 100 {
+		t.Fatalf("expected 100 events, got %d", len(events))
+	}
+	for i := 1; i < len(events); i++ {
+		prev, _ := EventTime(events[i-1])
+		cur, _ := EventTime(events[i])
+		if cur.Before(prev) {
+			t.Errorf("event %d at %v is before event %d at %v", i, cur, i-1, prev)
+		}
+		if _, ok := events[i]["OutOfOrder"]; ok {
+			t.Errorf("event %d unexpectedly flagged out of order", i)
+		}
+	}
+}
+
+func TestMergeStreamsLateEvent(t *testing.T) {
+	base := int64(1514083320)
+	a := make(chan LogEvent)
+	merged := MergeStreamsWithin(time.Second, a)
+
+	go func() {
+		a <- LogEvent{"Timestamp": base}
+		a <- LogEvent{"Timestamp": base + 10}
+		// Later than the window: base has already been emitted.
+		a <- LogEvent{"Timestamp": base - 100}
+		a <- LogEvent{"Statement": "no timestamp"}
+		close(a)
+	}()
+
+	var events []LogEvent
+	for e := range merged {
+		events = append(events, e)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	if events[0]["Timestamp"] != base {
+		t.Errorf("expected first event at %d, got %v", base, events[0]["Timestamp"])
+	}
+	if events[1]["Timestamp"] != base-100 || events[1]["OutOfOrder"] != true {
+		t.Errorf("expected late event flagged out of order, got %v", events[1])
+	}
+	if events[2]["Statement"] != "no timestamp" {
+		t.Errorf("expected event without timestamp to pass through, got %v", events[2])
+	}
+	if events[3]["Timestamp"] != base+10 {
+		t.Errorf("expected buffered event last, got %v", events[3])
+	}
+}
@@ -0,0 +1,165 @@
+package mysqllog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewSince returns the stats of the fingerprints first seen at or after
+// t, such as since a deploy, in the order they were first seen, leaving
+// out events without a statement. Fingerprints evicted with
+// WithMaxFingerprints are seen anew when they come back.
+func (a *Aggregator) NewSince(t time.Time) []QueryStats {
+	var results []QueryStats
+	for _, s := range a.stats {
+		if s.Fingerprint != "" && !s.FirstSeen.IsZero() && !s.FirstSeen.Before(t) {
+			results = append(results, s.snapshot())
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].FirstSeen.Equal(results[j].FirstSeen) {
+			return results[i].FirstSeen.Before(results[j].FirstSeen)
+		}
+		return results[i].Fingerprint < results[j].Fingerprint
+	})
+	return results
+}
+
+// NewFingerprint is a fingerprint seen for the first time by a
+// FingerprintDetector.
+type NewFingerprint struct {
+	Fingerprint string
+	// Checksum is the Checksum of Fingerprint, as in baselines.
+	Checksum string
+	// FirstSeen is the time of Sample, zero if it has none.
+	FirstSeen time.Time
+	// Sample is the event that introduced the fingerprint.
+	Sample LogEvent
+}
+
+// FingerprintDetector is a Sink calling a function the first time it's
+// written an event whose fingerprint isn't a known one, such as a query
+// shipped with a deploy. The known fingerprints are those of the
+// baseline, loaded from a previous aggregation with AddBaseline or
+// LoadBaseline, and those detected since, so each new fingerprint is
+// reported once. SaveBaseline stores them for the next run. Events
+// without a statement are ignored.
+//
+// Baselines are newline-delimited lists of the Checksum of each
+// fingerprint, so they're small and don't hold any statement text.
+// Memory grows with the number of distinct fingerprints. A
+// FingerprintDetector is safe for concurrent use.
+type FingerprintDetector struct {
+	notify func(NewFingerprint)
+
+	mu    sync.Mutex
+	known map[string]struct{}
+}
+
+// NewFingerprintDetector returns a FingerprintDetector with an empty
+// baseline, calling notify for each new fingerprint.
+func NewFingerprintDetector(notify func(NewFingerprint)) *FingerprintDetector {
+	return &FingerprintDetector{notify: notify, known: map[string]struct{}{}}
+}
+
+// Write checks whether the fingerprint of e is new.
+func (d *FingerprintDetector) Write(e LogEvent) error {
+	fingerprint := eventFingerprint(e)
+	if fingerprint == "" || fingerprint == OtherFingerprint {
+		return nil
+	}
+	checksum := Checksum(fingerprint)
+	d.mu.Lock()
+	_, known := d.known[checksum]
+	d.known[checksum] = struct{}{}
+	d.mu.Unlock()
+	if known || d.notify == nil {
+		return nil
+	}
+	ts, _ := EventTime(e)
+	d.notify(NewFingerprint{Fingerprint: fingerprint, Checksum: checksum, FirstSeen: ts, Sample: e})
+	return nil
+}
+
+// Close implements Sink.
+func (d *FingerprintDetector) Close() error {
+	return nil
+}
+
+// AddBaseline adds the fingerprints of results, such as those of
+// Aggregator.Results, to the known ones.
+func (d *FingerprintDetector) AddBaseline(results []QueryStats) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range results {
+		if s.Fingerprint != "" && s.Fingerprint != OtherFingerprint {
+			d.known[Checksum(s.Fingerprint)] = struct{}{}
+		}
+	}
+}
+
+// LoadBaseline adds the checksums of the baseline read from r, as
+// written by SaveBaseline, to the known fingerprints. Blank lines are
+// skipped.
+func (d *FingerprintDetector) LoadBaseline(r io.Reader) error {
+	var checksums []string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !isChecksum(line) {
+			return fmt.Errorf("mysqllog: baseline line %d: bad checksum %q", n, line)
+		}
+		checksums = append(checksums, strings.ToUpper(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, checksum := range checksums {
+		d.known[checksum] = struct{}{}
+	}
+	return nil
+}
+
+// SaveBaseline writes the checksums of the known fingerprints to w, one
+// per line in order, for LoadBaseline.
+func (d *FingerprintDetector) SaveBaseline(w io.Writer) error {
+	d.mu.Lock()
+	checksums := sortedSet(d.known)
+	d.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, checksum := range checksums {
+		bw.WriteString(checksum)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// Known returns the number of known fingerprints.
+func (d *FingerprintDetector) Known() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.known)
+}
+
+// isChecksum reports whether s looks like a Checksum.
+func isChecksum(s string) bool {
+	if len(s) != 16 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package mysqllog
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewFingerprints(t *testing.T) {
+	day1 := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	event := func(ts time.Time, statement string) LogEvent {
+		return LogEvent{"Timestamp": ts, "Query_time": 0.1, "Statement": statement}
+	}
+	var first []LogEvent
+	for i := 0; i < 50; i++ {
+		ts := day1.Add(time.Duration(i) * time.Minute)
+		first = append(first,
+			event(ts, fmt.Sprintf("SELECT * FROM orders WHERE id = %d", i)),
+			event(ts, fmt.Sprintf("UPDATE users SET seen = NOW() WHERE id = %d", i)))
+	}
+	first = append(first, event(day1, "DELETE FROM sessions WHERE expires < 10"))
+	second := []LogEvent{
+		event(day2, "SELECT * FROM orders WHERE id = 7"),
+		event(day2.Add(time.Minute), "SELECT * FROM orders WHERE id IN (1, 2)"),
+		event(day2.Add(2*time.Minute), "select  *  from orders where id = 99"),
+		event(day2.Add(3*time.Minute), "INSERT INTO audit VALUES (1, 'deploy')"),
+		event(day2.Add(4*time.Minute), "SELECT * FROM orders WHERE id IN (3, 4, 5)"),
+		event(day2.Add(5*time.Minute), "INSERT INTO audit VALUES (2, 'again')"),
+		event(day2.Add(6*time.Minute), "DELETE FROM sessions WHERE expires < 20"),
+		{"Timestamp": day2, "Query_time": 0.1},
+	}
+	wantNew := []string{Fingerprint("SELECT * FROM orders WHERE id IN (1, 2)"), Fingerprint("INSERT INTO audit VALUES (1, 'deploy')")}
+
+	baseline := NewAggregator()
+	for _, e := range first {
+		baseline.Add(e)
+	}
+	var fired []NewFingerprint
+	d := NewFingerprintDetector(func(f NewFingerprint) { fired = append(fired, f) })
+	d.AddBaseline(baseline.Results())
+	for _, e := range second {
+		d.Write(e)
+	}
+	if len(fired) != len(wantNew) {
+		t.Fatalf("expected %d new fingerprints, got %v", len(wantNew), fired)
+	}
+	for i, f := range fired {
+		if f.Fingerprint != wantNew[i] || f.Checksum != Checksum(wantNew[i]) {
+			t.Errorf("expected %q to be new, got %+v", wantNew[i], f)
+		}
+	}
+	if !fired[0].FirstSeen.Equal(day2.Add(time.Minute)) || fired[0].Sample["Statement"] != "SELECT * FROM orders WHERE id IN (1, 2)" {
+		t.Errorf("unexpected first new fingerprint %+v", fired[0])
+	}
+
+	// The saved baseline has the new fingerprints, and loads back.
+	var saved bytes.Buffer
+	if err := d.SaveBaseline(&saved); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(saved.String()), "\n")
+	if len(lines) != 5 || !sort.StringsAreSorted(lines) {
+		t.Errorf("unexpected baseline %q", saved.String())
+	}
+	fired = nil
+	reloaded := NewFingerprintDetector(func(f NewFingerprint) { fired = append(fired, f) })
+	if err := reloaded.LoadBaseline(strings.NewReader(strings.ToLower(saved.String()) + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range second {
+		reloaded.Write(e)
+	}
+	if len(fired) != 0 || reloaded.Known() != 5 {
+		t.Errorf("expected no new fingerprints from the saved baseline, got %v", fired)
+	}
+	if err := reloaded.LoadBaseline(strings.NewReader("SELECT 1\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected an error for a bad baseline, got %v", err)
+	}
+
+	// Aggregating both days, NewSince has the fingerprints of day 2.
+	a := NewAggregator()
+	for _, e := range append(first, second...) {
+		a.Add(e)
+	}
+	var since []string
+	for _, s := range a.NewSince(day2) {
+		since = append(since, s.Fingerprint)
+	}
+	if strings.Join(since, "\n") != strings.Join(wantNew, "\n") {
+		t.Errorf("expected %q new since day 2, got %q", wantNew, since)
+	}
+	if n := len(a.NewSince(day1)); n != 5 {
+		t.Errorf("expected every fingerprint new since day 1, got %d", n)
+	}
+}
@@ -0,0 +1,167 @@
+package mysqllog
+
+import (
+	"sort"
+	"time"
+)
+
+// WithOffsets sets "Offset" on each event to the byte offset of its
+// first line in the input, as an int64, and "EventID" to its EventID.
+// SortByTime uses the offset to break ties.
+func WithOffsets() Option {
+	return func(p *Parser) {
+		p.offsets = true
+	}
+}
+
+// WithOutOfOrderDetection sets "OutOfOrder" to true on events whose
+// timestamp (see EventTime) is more than tolerance before the one of the
+// previous event, as after a clock step or when logs were concatenated
+// in the wrong order, and counts them in Stats.OutOfOrder. warn, if not
+// nil, is called with both timestamps. Events keep their own timestamp,
+// so time buckets such as those of a Timeline still use it.
+func WithOutOfOrderDetection(tolerance time.Duration, warn func(previous, current time.Time)) Option {
+	return func(p *Parser) {
+		p.outOfOrder = true
+		p.outOfOrderTolerance = tolerance
+		p.outOfOrderWarn = warn
+	}
+}
+
+// WithSequence sets "Seq", an int64, on each event to how many events
+// before it in the input have the same timestamp, so events within a
+// second, which have the same SET timestamp, keep their order in the
+// file through SortByTime and MergeStreams. The sequence restarts at 0
+// with every new timestamp, and with every file read by a Parser of its
+// own, as with TailFile and WatchDir. It's synthetic: the server writes
+// events once they complete, so it's the order of completion, not of
+// the times within the second. Events with the End attribute of
+// log_slow_extra, which has the time to the microsecond, are left out.
+func WithSequence() Option {
+	return func(p *Parser) {
+		p.sequence = true
+	}
+}
+
+// setSequence sets "Seq" on e for WithSequence.
+func (p *Parser) setSequence(e LogEvent) {
+	if _, ok := e["End"]; ok {
+		return
+	}
+	ts, ok := e["Timestamp"].(time.Time)
+	if !ok {
+		return
+	}
+	if ts.Equal(p.seqTime) {
+		p.seq++
+	} else {
+		p.seqTime, p.seq = ts, 0
+	}
+	e["Seq"] = p.seq
+}
+
+// checkOrder flags e if it's out of order with the previous event.
+func (p *Parser) checkOrder(e LogEvent) {
+	ts, ok := EventTime(e)
+	if !ok {
+		return
+	}
+	previous := p.previousTime
+	p.previousTime = ts
+	if previous.IsZero() || previous.Sub(ts) <= p.outOfOrderTolerance {
+		return
+	}
+	e["OutOfOrder"] = true
+	p.stats.OutOfOrder++
+	if p.outOfOrderWarn != nil {
+		p.outOfOrderWarn(previous, ts)
+	}
+}
+
+// orderKey is what events are ordered by. Missing values are zero, so
+// events without a timestamp come first.
+type orderKey struct {
+	ts     time.Time
+	seq    int64
+	id     int64
+	offset int64
+}
+
+func eventOrderKey(e LogEvent) orderKey {
+	var k orderKey
+	k.ts, _ = EventTime(e)
+	k.seq, _ = e.Int64("Seq")
+	k.id, _ = ConnectionID(e)
+	k.offset, _ = e.Int64("Offset")
+	return k
+}
+
+func (k orderKey) less(other orderKey) bool {
+	if !k.ts.Equal(other.ts) {
+		return k.ts.Before(other.ts)
+	}
+	if k.seq != other.seq {
+		return k.seq < other.seq
+	}
+	if k.id != other.id {
+		return k.id < other.id
+	}
+	return k.offset < other.offset
+}
+
+// eventOrder sorts events by their orderKey, computed once.
+type eventOrder struct {
+	events []LogEvent
+	keys   []orderKey
+}
+
+func newEventOrder(events []LogEvent) *eventOrder {
+	o := &eventOrder{events: events, keys: make([]orderKey, len(events))}
+	for i, e := range events {
+		o.keys[i] = eventOrderKey(e)
+	}
+	return o
+}
+
+func (o *eventOrder) Len() int           { return len(o.events) }
+func (o *eventOrder) Less(i, j int) bool { return o.keys[i].less(o.keys[j]) }
+func (o *eventOrder) Swap(i, j int) {
+	o.events[i], o.events[j] = o.events[j], o.events[i]
+	o.keys[i], o.keys[j] = o.keys[j], o.keys[i]
+}
+
+// SortByTime sorts events by timestamp (see EventTime), then by the
+// "Seq" set by WithSequence, then by connection Id (or Thread_id), then
+// by the "Offset" set by WithOffsets.
+// The sort is stable, so events that tie on all three keep their order.
+// Events without a timestamp come first.
+func SortByTime(events []LogEvent) {
+	sort.Stable(newEventOrder(events))
+}
+
+// IsSortedByTime reports whether events are in the order of SortByTime.
+func IsSortedByTime(events []LogEvent) bool {
+	return sort.IsSorted(newEventOrder(events))
+}
+
+// MaxOutOfOrder returns how far the most out of order event of events
+// is behind the newest timestamp before it, or 0 if their timestamps
+// never go back. Merging with at least that lateness, such as with
+// MergeStreamsWithin, puts them back in order. Events without a
+// timestamp are ignored.
+func MaxOutOfOrder(events []LogEvent) time.Duration {
+	var newest time.Time
+	var max time.Duration
+	for _, e := range events {
+		ts, ok := EventTime(e)
+		if !ok {
+			continue
+		}
+		if ts.After(newest) {
+			newest = ts
+		} else if d := newest.Sub(ts); d > max {
+			max = d
+		}
+	}
+	return max
+}
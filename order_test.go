@@ -0,0 +1,152 @@
+package mysqllog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSortByTime(t *testing.T) {
+	base := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	events := []LogEvent{
+		{"n": 0, "Timestamp": base.Add(2 * time.Second)},
+		{"n": 1, "Timestamp": base.Unix(), "Id": int64(7)},
+		{"n": 2, "Timestamp": float64(base.Unix()) + 0.5},
+		{"n": 3, "Timestamp": base.Format(time.RFC3339), "Id": int64(3)},
+		{"n": 4},
+		{"n": 5, "Timestamp": base.Unix(), "Thread_id": int64(3), "Offset": int64(20)},
+		{"n": 6, "Timestamp": base.Unix(), "Id": int64(3), "Offset": int64(10)},
+	}
+	if IsSortedByTime(events) {
+		t.Error("expected the events not to be sorted")
+	}
+	if d := MaxOutOfOrder(events); d != 2*time.Second {
+		t.Errorf("expected 2s out of order, got %v", d)
+	}
+
+	SortByTime(events)
+	order := []int{}
+	for _, e := range events {
+		order = append(order, e["n"].(int))
+	}
+	for i, n := range []int{4, 3, 6, 5, 1, 2, 0} {
+		if order[i] != n {
+			t.Fatalf("expected order [4 3 6 5 1 2 0], got %v", order)
+		}
+	}
+	if !IsSortedByTime(events) || MaxOutOfOrder(events) != 0 {
+		t.Error("expected the events to be sorted")
+	}
+}
+
+func TestWithOffsets(t *testing.T) {
+	input := "# Query_time: 1\nSELECT 1;\n# Query_time: 2\nSELECT 2;\n"
+	events := parseAll(NewParser(WithOffsets()), input)
+	if len(events) != 2 || events[0]["Offset"] != int64(0) || events[1]["Offset"] != int64(26) {
+		t.Errorf("unexpected offsets %v", events)
+	}
+}
+
+func TestWithOutOfOrderDetection(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/clock_jump.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type warning struct{ previous, current time.Time }
+	var warnings []warning
+	p := NewParser(WithOutOfOrderDetection(5*time.Second, func(previous, current time.Time) {
+		warnings = append(warnings, warning{previous, current})
+	}))
+	events := parseAll(p, string(b))
+	if len(events) != 5 {
+		t.Fatalf("expected 5 events, got %d", len(events))
+	}
+	// A second back is within the tolerance; the 2 hour jump isn't.
+	for i, e := range events {
+		if _, flagged := e["OutOfOrder"]; flagged != (i == 3) {
+			t.Errorf("event %d: unexpected OutOfOrder %v", i, e["OutOfOrder"])
+		}
+	}
+	if p.Stats().OutOfOrder != 1 {
+		t.Errorf("expected 1 event out of order, got %d", p.Stats().OutOfOrder)
+	}
+	if len(warnings) != 1 || warnings[0].previous.Unix() != 1690891229 || warnings[0].current.Unix() != 1690884060 {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+
+	events = parseAll(NewParser(WithOutOfOrderDetection(3*time.Hour, nil)), string(b))
+	for i, e := range events {
+		if _, flagged := e["OutOfOrder"]; flagged {
+			t.Errorf("event %d: expected no OutOfOrder within 3h", i)
+		}
+	}
+
+	// Time buckets use the event's own timestamp.
+	var entries []TimelineEntry
+	timeline := &Timeline{Resolution: time.Hour, Emit: func(e []TimelineEntry) { entries = append(entries, e...) }}
+	for _, e := range parseAll(NewParser(WithOutOfOrderDetection(0, nil)), string(b)) {
+		timeline.Write(e)
+	}
+	timeline.Close()
+	if len(entries) != 2 || entries[0].Bucket.Unix() != 1690884000 || entries[0].QueryTime != 0.5 || entries[1].QueryTime != 0.3 {
+		t.Errorf("unexpected timeline %v", entries)
+	}
+}
+
+func TestWithSequence(t *testing.T) {
+	// sameSecond returns n events with the same SET timestamp, the
+	// connection ids going down so they don't sort in file order.
+	sameSecond := func(source string, n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&b, "# User@Host: app[app] @ %s []  Id: %d\n# Query_time: 0.1\nSET timestamp=1690884000;\nSELECT %d;\n", source, 100-i, i)
+		}
+		b.WriteString("# User@Host: app[app] @ " + source + " []  Id: 1\n# Query_time: 0.1\nSET timestamp=1690884001;\nSELECT 'next';\n")
+		return b.String()
+	}
+	slow := parseAll(NewParser(WithSequence()), sameSecond("db", 10))
+	other := parseAll(NewParser(WithSequence()), sameSecond("other", 3))
+	for i, e := range slow {
+		if seq := e["Seq"]; seq != int64(i%10) {
+			t.Errorf("event %d: expected Seq %d, got %v", i, i%10, seq)
+		}
+	}
+
+	a, b := make(chan LogEvent), make(chan LogEvent)
+	merged := MergeStreamsWithin(time.Second, a, b)
+	go func() {
+		for _, e := range other {
+			a <- e
+		}
+		close(a)
+	}()
+	go func() {
+		for _, e := range slow {
+			b <- e
+		}
+		close(b)
+	}()
+	var order []string
+	var events []LogEvent
+	for e := range merged {
+		events = append(events, e)
+		order = append(order, e["Host"].(string)+" "+e["Statement"].(string))
+	}
+	expected := []string{"other SELECT 0;", "db SELECT 0;", "other SELECT 1;", "db SELECT 1;", "other SELECT 2;", "db SELECT 2;",
+		"db SELECT 3;", "db SELECT 4;", "db SELECT 5;", "db SELECT 6;", "db SELECT 7;", "db SELECT 8;", "db SELECT 9;",
+		"other SELECT 'next';", "db SELECT 'next';"}
+	if strings.Join(order, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected\n%v\ngot\n%v", expected, order)
+	}
+	if !IsSortedByTime(events) {
+		t.Error("expected the merged events to be sorted")
+	}
+	SortByTime(slow)
+	for i, e := range slow[:10] {
+		if e["Statement"] != fmt.Sprintf("SELECT %d;", i) {
+			t.Errorf("expected file order after sorting, got %v at %d", e["Statement"], i)
+		}
+	}
+}
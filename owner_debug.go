@@ -0,0 +1,22 @@
+//go:build mysqllog_debug
+// +build mysqllog_debug
+
+package mysqllog
+
+import "sync/atomic"
+
+// ownerCheck panics when a Parser is entered by a goroutine while
+// another one is still in it.
+type ownerCheck struct {
+	busy int32
+}
+
+func (c *ownerCheck) enter() {
+	if !atomic.CompareAndSwapInt32(&c.busy, 0, 1) {
+		panic("mysqllog: Parser used by several goroutines at once; use one Parser per source, such as with ParserPool")
+	}
+}
+
+func (c *ownerCheck) leave() {
+	atomic.StoreInt32(&c.busy, 0)
+}
@@ -0,0 +1,20 @@
+//go:build mysqllog_debug
+// +build mysqllog_debug
+
+package mysqllog
+
+import "testing"
+
+func TestOwnerCheck(t *testing.T) {
+	p := NewParser()
+	p.ConsumeLine("# Time: 2021-01-01T00:00:00Z\n")
+
+	// Simulates a second goroutine entering while the first is in it.
+	p.owner.enter()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic on concurrent use")
+		}
+	}()
+	p.ConsumeLine("# User@Host: root[root] @ localhost []\n")
+}
@@ -8,86 +8,599 @@ import (
 )
 
 // LogEvent represents a slow query log event.
-// "User", "Host", "Timestamp" (from SET timestamp as a time.Time), and "Statement"
-// all should usually be present. Other attributes are set if found.
+// "User", "Host", "Timestamp" (from SET timestamp, formatted as a string by
+// default), and "Statement" all should usually be present. Other attributes are set if found.
 // Numbers are float64 or int64. Values of "Yes" or "No" are converted to bools.
 type LogEvent map[string]interface{}
 
 // Parser is a MySQL slow query log format parser.
+// The zero value is ready to use; NewParser applies options.
+//
+// A Parser holds the pending event of a single log, and isn't safe for
+// concurrent use: lines of different logs fed to the same Parser are
+// mixed into corrupted events. Use a Parser per source, such as with
+// ParserPool. Builds with the mysqllog_debug tag panic on concurrent use.
 type Parser struct {
+	owner ownerCheck
+
 	inHeader bool
 	inQuery  bool
 	lines    []string
+	// quote is the quote character of a string literal left open by the
+	// statement so far, or 0.
+	quote byte
+
+	// sampled is set once the sampling decision for the pending
+	// event has been made, and skip if the event is sampled out.
+	sampled bool
+	skip    bool
+	stats   Stats
+
+	classifier *Classifier
+	labels     map[string]string
+	sampling   sampling
+	now        func() time.Time
+
+	timestampLayout string
+	unixTimestamps  bool
+
+	dialect    Dialect
+	dialectSet bool
+	evidence   int
+
+	fullScan        bool
+	fullScanRatio   float64
+	fullScanMinRows int64
+
+	rowsFilter      bool
+	minRowsExamined int64
+
+	verbs           bool
+	skipTrivial     bool
+	tables          bool
+	unboundedWrites bool
+	commentMetadata bool
+	statementSize   bool
+	routines        bool
+	splitStatements bool
+	metricsOnly     bool
+	fullText        bool
+	redact          bool
+	vault           *RedactionVault
+
+	attributes attributeTable
+	durations  bool
+	unknown    UnknownAttributes
+	keyStyle   KeyStyle
+
+	canonical     bool
+	keepOriginals bool
+
+	// In strict mode, positions has the position of each pending line.
+	strict    bool
+	line      int
+	offset    int64
+	lineStart int64
+	positions []linePosition
+	errs      []*ParseError
+
+	hooks       []EventHook
+	connections map[int64]bool
+	logger      Logger
+	inherit     *userHostInheritance
+	// eventLine is the line number of the first pending line, and
+	// eventOffset its byte offset.
+	eventLine   int
+	eventOffset int64
+	offsets     bool
+
+	pooled bool
+
+	// server is the ServerInfo so far, and eventServer the one when the
+	// pending event started.
+	server       ServerInfo
+	eventServer  ServerInfo
+	serverLabels bool
+
+	outOfOrder          bool
+	outOfOrderTolerance time.Duration
+	outOfOrderWarn      func(previous, current time.Time)
+	// previousTime is the timestamp of the last event that had one.
+	previousTime time.Time
+
+	// truncated is set once the pending statement was cut at
+	// statementLimit, and statementBytes is its length so far.
+	truncated      bool
+	statementBytes int
+	statementLimit int
+	dropIncomplete bool
+
+	presence bool
+
+	// seq is the "Seq" of the last event, at seqTime, with
+	// WithSequence.
+	sequence bool
+	seqTime  time.Time
+	seq      int64
+
+	headerLimit int
+
+	tracker *connectionTracker
+
+	rawNumbers bool
+
+	eventClock *ManualClock
+
+	// sourceID is the identity of the input of WithSourceID, and inputID
+	// the one of the file or source read, for EventID.
+	sourceID string
+	inputID  string
+
+	// trailer are the comment lines after the pending statement, until
+	// it's known whether they end it or start the next header.
+	trailer     []trailerLine
+	keepTrailer bool
+
+	keepHeaderLines bool
+
+	// restart is the banner of WithControlEvents not emitted yet.
+	controlEvents bool
+	restart       *pendingRestart
+
+	// pendingBytes is the length of lines and trailer.
+	pendingBytes int
+
+	errorLogLines func(line string)
+}
+
+// Option configures a Parser.
+type Option func(*Parser)
+
+// NewParser returns a Parser configured with opts.
+func NewParser(opts ...Option) *Parser {
+	p := &Parser{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WithLabels stamps labels onto every event under the "Labels" key as a
+// map[string]string. Labels are kept apart from parsed attributes, so a
+// label named like an attribute (e.g. "User") never overrides parsed data.
+func WithLabels(labels map[string]string) Option {
+	return func(p *Parser) {
+		p.labels = make(map[string]string, len(labels))
+		for k, v := range labels {
+			p.labels[k] = v
+		}
+	}
+}
+
+// Stats returns counters describing the events seen by p.
+func (p *Parser) Stats() Stats {
+	stats := p.stats
+	if p.stats.Presence != nil {
+		stats.Presence = make(map[string]int64, len(p.stats.Presence))
+		for key, n := range p.stats.Presence {
+			stats.Presence[key] = n
+		}
+	}
+	return stats
+}
+
+// emit applies the configured options to a completed event.
+// It returns nil if the event is dropped.
+func (p *Parser) emit(event LogEvent) LogEvent {
+	if p.sequence {
+		p.setSequence(event)
+	}
+	p.formatTimestamp(event)
+	if p.canonical {
+		canonicalize(event, p.keepOriginals)
+	}
+	if p.inherit != nil {
+		p.inherit.apply(event, p.eventLine)
+	}
+	if p.tracker != nil {
+		p.tracker.track(event, p.eventLine)
+	}
+	if p.eventClock != nil {
+		p.eventClock.Advance(event)
+	}
+	if p.offsets {
+		event["Offset"] = p.eventOffset
+		p.setID(event)
+	}
+	if p.keepHeaderLines {
+		p.setHeaderLines(event)
+	}
+	if _, incomplete := event.Incomplete(); incomplete {
+		p.stats.Incomplete++
+		if p.dropIncomplete {
+			p.stats.IncompleteDropped++
+			return nil
+		}
+	}
+	if p.skipTrivial {
+		if statement, _ := event["Statement"].(string); isCommentOnly(statement) {
+			p.stats.TrivialSkipped++
+			return nil
+		}
+	}
+	if !p.admitConnection(event) || !p.admitRows(event) || !p.admit(event) {
+		return nil
+	}
+	if p.outOfOrder {
+		p.checkOrder(event)
+	}
+	if p.fullScan && probableFullScan(event, p.fullScanRatio, p.fullScanMinRows) {
+		event["ProbableFullScan"] = true
+	}
+	if p.verbs || p.tables || p.unboundedWrites || p.commentMetadata || p.statementSize || p.routines || p.splitStatements {
+		statement, _ := event["Statement"].(string)
+		if p.verbs {
+			event["Verb"] = Verb(statement)
+		}
+		if p.tables {
+			event["Tables"] = Tables(statement)
+		}
+		if p.unboundedWrites && UnboundedWrite(statement) {
+			event["UnboundedWrite"] = true
+		}
+		if p.commentMetadata {
+			if meta := CommentMetadata(statement); meta != nil {
+				event["Meta"] = meta
+			}
+		}
+		if p.statementSize {
+			setStatementSize(event, statement)
+		}
+		if p.routines {
+			setRoutine(event, statement)
+		}
+		if p.splitStatements {
+			database, _ := event["Database"].(string)
+			event["Statements"] = splitStatements(statement, database)
+		}
+	}
+	if p.classifier != nil {
+		if severity := p.classifier.Classify(event); severity != "" {
+			event["Severity"] = severity
+		}
+	}
+	if p.redact {
+		p.redactEvent(event)
+	}
+	serverLabels := p.serverLabels && p.eventServer.Banners > 0
+	if len(p.labels) > 0 || serverLabels {
+		labels := make(map[string]string, len(p.labels)+2)
+		if serverLabels {
+			labels[ServerVersionLabel] = p.eventServer.Version
+			labels[ServerFlavorLabel] = p.eventServer.Flavor.String()
+		}
+		for k, v := range p.labels {
+			labels[k] = v
+		}
+		event["Labels"] = labels
+	}
+	if p.metricsOnly {
+		if statement, ok := event["Statement"].(string); ok {
+			event["Fingerprint"] = Fingerprint(statement)
+			delete(event, "Statement")
+		}
+		delete(event, "ExecutableText")
+		delete(event, "RawNumbers")
+		delete(event, "Statements")
+		delete(event, "Trailer")
+	}
+	styleKeys(event, p.keyStyle)
+	if event = p.runHooks(event); event == nil {
+		return nil
+	}
+	if p.presence {
+		p.countPresence(event)
+	}
+	p.stats.Events++
+	return event
 }
 
 // ConsumeLine consumes a line and returns a LogEvent if
 // the parser recognizes a completed event.
 func (p *Parser) ConsumeLine(line string) LogEvent {
-	if line == "" {
+	p.owner.enter()
+	defer p.owner.leave()
+	p.line++
+	p.lineStart = p.offset
+	p.offset += int64(len(line))
+	p.stats.Bytes += int64(len(line))
+	if p.inherit != nil {
+		p.inherit.checkBanner(line, p.line)
+	}
+	if p.tracker != nil {
+		p.tracker.checkBanner(line, p.line)
+	}
+	p.checkServer(line)
+	p.detectBanner(line)
+	if p.controlEvents {
+		restart, handled := p.consumeControl(line)
+		if handled {
+			return restart
+		}
+		if restart != nil {
+			p.consumeLine(line)
+			return restart
+		}
+	}
+	return p.consumeLine(line)
+}
+
+// consumeLine consumes a line that may be part of an event.
+func (p *Parser) consumeLine(line string) LogEvent {
+	if strings.TrimSpace(line) == "" {
+		if p.inQuery && p.quote != 0 {
+			// A blank line inside a string literal.
+			p.appendLine(line)
+			return nil
+		}
 		if p.inQuery {
-			// We're in a new section
-			event := parseEntry(p.lines)
-			p.lines = append(p.lines[:0], line)
+			// Blank lines, even with spaces or tabs, end the event.
 			p.inQuery = false
-			p.inHeader = true
-			return event
+			return p.finish("")
 		}
 		return nil
 	}
-	if strings.HasPrefix(line, "#") {
+	if p.skipErrorLogLine(line) {
+		return nil
+	}
+	if strings.HasPrefix(line, "#") && !((p.inHeader || p.inQuery) && isAdminCommand(line)) {
 		// Comment line
+		if p.inQuery && p.quote == 0 && !startsHeader(line) {
+			// After the statement, but maybe not the next header.
+			p.trailer = append(p.trailer, trailerLine{line, linePosition{p.line, p.lineStart}})
+			p.pendingBytes += len(line)
+			return nil
+		}
 		if p.inQuery {
 			// We're in a new section
-			event := parseEntry(p.lines)
-			p.lines = append(p.lines[:0], line)
+			var reason IncompleteReason
+			if p.quote != 0 {
+				reason = IncompleteResync
+			}
+			event := p.finish(reason)
+			if p.logger != nil {
+				p.logger.Printf("mysqllog: line %d: entering header", p.line)
+			}
 			p.inQuery = false
 			p.inHeader = true
+			p.appendLine(line)
 			return event
 		}
+		if !p.inHeader && p.logger != nil {
+			p.logger.Printf("mysqllog: line %d: entering header", p.line)
+		}
 		p.inHeader = true
-		p.lines = append(p.lines, line)
+		p.appendLine(line)
 		return nil
 	}
 
 	// Not a comment line
 	if p.inHeader {
+		if p.logger != nil {
+			p.logger.Printf("mysqllog: line %d: entering query", p.line)
+		}
 		p.inHeader = false
 		p.inQuery = true
-		p.lines = append(p.lines, line)
+		p.quote = quoteState(0, line)
+		p.appendLine(line)
 		return nil
 	}
+	if p.inQuery && len(p.trailer) > 0 {
+		// The comment lines were the header of the next event.
+		header := p.trailer
+		p.trailer = nil
+		event := p.finish("")
+		if p.logger != nil {
+			p.logger.Printf("mysqllog: line %d: entering query", p.line)
+		}
+		p.inHeader, p.inQuery = true, false
+		for _, h := range header {
+			p.appendLineAt(h.text, h.pos)
+		}
+		p.inHeader, p.inQuery = false, true
+		p.quote = quoteState(0, line)
+		p.appendLine(line)
+		return event
+	}
 	if p.inQuery {
 		// Keep consuming query lines
-		p.lines = append(p.lines, line)
+		p.quote = quoteState(p.quote, line)
+		p.appendLine(line)
+	} else if p.logger != nil && p.redact {
+		p.logger.Printf("mysqllog: line %d: skipped line outside an event", p.line)
+	} else if p.logger != nil {
+		p.logger.Printf("mysqllog: line %d: skipped line outside an event: %q", p.line, strings.TrimRight(line, "\r\n"))
 	}
 
 	return nil
 }
 
 // Flush processes any pending lines and returns a LogEvent if one is complete.
+// The event is incomplete with IncompleteEOF if the lines end inside its
+// statement.
 func (p *Parser) Flush() LogEvent {
+	p.owner.enter()
+	defer p.owner.leave()
+	if restart := p.takeRestart(); restart != nil {
+		return restart
+	}
 	if !p.inQuery {
 		return nil
 	}
-	event := parseEntry(p.lines)
+	var reason IncompleteReason
+	if p.incompleteAtEOF() {
+		reason = IncompleteEOF
+	}
+	p.inQuery = false
+	p.inHeader = false
+	return p.finish(reason)
+}
+
+// flushIdle flushes the pending event for WithIdleFlush, as incomplete
+// with IncompleteIdleFlush.
+func (p *Parser) flushIdle() LogEvent {
+	p.owner.enter()
+	defer p.owner.leave()
+	if !p.inQuery {
+		return nil
+	}
+	p.inQuery = false
+	p.inHeader = false
+	return p.finish(IncompleteIdleFlush)
+}
+
+// quoteState returns the quote character of the string literal open
+// at the end of line, given the one open at its start, or 0. Comments
+// to the end of the line are skipped.
+func quoteState(quote byte, line string) byte {
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '#' || (c == '-' && strings.HasPrefix(line[i:], "-- ")):
+			return 0
+		case c == '/' && strings.HasPrefix(line[i:], "/*"):
+			end := strings.Index(line[i+2:], "*/")
+			if end < 0 {
+				return 0
+			}
+			i += end + 3
+		}
+	}
+	return quote
+}
+
+// appendLine adds a line to the pending event. The sampling decision
+// is made on the first line so skipped events aren't buffered at all.
+func (p *Parser) appendLine(line string) {
+	p.appendLineAt(line, linePosition{p.line, p.lineStart})
+}
+
+// appendLineAt adds a line read at pos to the pending event.
+func (p *Parser) appendLineAt(line string, pos linePosition) {
+	if !p.sampled {
+		p.sampled = true
+		p.skip = !p.sampleIn()
+	}
+	if p.inQuery && p.statementLimit > 0 {
+		if line = p.limitLine(line); line == "" {
+			return
+		}
+	}
+	if !p.skip {
+		if len(p.lines) == 0 {
+			p.eventLine = pos.line
+			p.eventOffset = pos.offset
+			p.eventServer = p.server
+		}
+		p.lines = append(p.lines, line)
+		p.pendingBytes += len(line)
+		if p.strict {
+			p.positions = append(p.positions, pos)
+		}
+	}
+}
+
+// finish parses the pending lines and resets them for the next event,
+// flagged as incomplete for reason, if it isn't empty, or if the
+// statement was truncated.
+func (p *Parser) finish(reason IncompleteReason) LogEvent {
+	var event LogEvent
+	if p.skip {
+		p.stats.SampledOut++
+		if p.logger != nil {
+			p.logger.Printf("mysqllog: line %d: event sampled out", p.line)
+		}
+	} else {
+		parsed := p.parseEntry(p.lines)
+		if p.truncated {
+			reason = IncompleteTruncated
+		}
+		markIncomplete(parsed, reason)
+		p.setTrailer(parsed)
+		if event = p.emit(parsed); event == nil && p.pooled {
+			parsed.Release()
+		}
+		if p.logger != nil {
+			header := headerLines(p.lines)
+			if event == nil {
+				p.logger.Printf("mysqllog: line %d: event dropped with %d header lines and %d statement lines", p.line, header, len(p.lines)-header)
+			} else {
+				p.logger.Printf("mysqllog: line %d: event emitted with %d header lines and %d statement lines", p.line, header, len(p.lines)-header)
+			}
+		}
+	}
 	p.lines = p.lines[:0]
+	p.positions = p.positions[:0]
+	p.trailer = p.trailer[:0]
+	p.quote = 0
+	p.truncated = false
+	p.statementBytes = 0
+	p.pendingBytes = 0
+	p.sampled = false
+	p.skip = false
 	return event
 }
 
-var userHostAttributesRe = regexp.MustCompile(`\b(User@Host: \S+\[\w+\]+ @ (?:)(\w+)? \[\S*\])|(Id:.+)`)
+// dropPending drops the pending event without emitting it, so the lines
+// up to the next header are skipped.
+func (p *Parser) dropPending() {
+	p.inHeader, p.inQuery = false, false
+	p.lines = p.lines[:0]
+	p.positions = p.positions[:0]
+	p.trailer = p.trailer[:0]
+	p.quote = 0
+	p.sampled, p.skip = false, false
+	p.truncated, p.statementBytes = false, 0
+	p.pendingBytes = 0
+}
+
+var userHostAttributesRe = regexp.MustCompile(`\b(User@Host: \S+\[\w+\]+ @ (?:)([^\s\[]+)? \[\S*\])|(Id:.+)`)
 var attributesRe = regexp.MustCompile(`\b([\w_]+:\s+[^\s]+)\b`)
 
 func parseUserHostLine(line string) map[string]string {
 	event := map[string]string{}
 	matches := userHostAttributesRe.FindAllString(line, -1)
 	for _, match := range matches {
-		parts := strings.Split(match, ": ")
+		parts := strings.SplitN(match, ": ", 2)
 		switch parts[0] {
 		case "User@Host":
-			userHostParts := strings.Split(parts[1], "@")
+			// The user can't contain spaces, so the first " @ " is the
+			// separator even if the user contains "@".
+			userHostParts := strings.SplitN(parts[1], " @ ", 2)
 			event["User"] = strings.TrimSpace(strings.Split(userHostParts[0], "[")[0])
-			event["Host"] = strings.TrimSpace(strings.Split(userHostParts[1], "[")[0])
-			event["IP"] = strings.TrimRight(strings.TrimSpace(strings.Split(userHostParts[1], "[")[1]), "]")
+			host := strings.TrimSpace(strings.Split(userHostParts[1], "[")[0])
+			ip := strings.TrimSpace(userHostParts[1][strings.IndexByte(userHostParts[1], '[')+1:])
+			ip = strings.TrimSuffix(ip, "]")
+			// Middleware such as ProxySQL adds the client port.
+			var port, hostPort string
+			ip, port = splitPort(ip)
+			host, hostPort = splitPort(host)
+			if port == "" {
+				port = hostPort
+			}
+			if port != "" {
+				event["Port"] = port
+			}
+			event["Host"] = host
+			event["IP"] = ip
 			if len(event["IP"]) == 0 {
 				delete(event, "IP")
 			}
@@ -103,71 +616,191 @@ func parseUserHostLine(line string) map[string]string {
 	return event
 }
 
+// splitPort splits a ":port" suffix off addr, a host name, IPv4 address,
+// or IPv6 address in brackets if it has a port. An IPv6 address with
+// its colons and no brackets is left alone.
+func splitPort(addr string) (host, port string) {
+	i := strings.LastIndexByte(addr, ':')
+	if i < 0 {
+		return addr, ""
+	}
+	host, port = addr[:i], addr[i+1:]
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return addr, ""
+	}
+	switch {
+	case strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]"):
+		return host[1 : len(host)-1], port
+	case strings.IndexByte(host, ':') >= 0:
+		return addr, ""
+	}
+	return host, port
+}
+
+// convertAttribute converts an attribute value to the Go type for kind.
+// It returns nil if the value can't be converted.
+func convertAttribute(kind AttributeKind, value string) interface{} {
+	switch kind {
+	case AttributeString:
+		return value
+	case AttributeBool:
+		switch value {
+		case "Yes":
+			return true
+		case "No":
+			return false
+		}
+		v, err := strconv.ParseBool(value)
+		if err == nil {
+			return v
+		}
+	case AttributeFloat:
+		v, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			return v
+		}
+	case AttributeInt:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			return v
+		}
+	case AttributeDuration:
+		v, err := parseSeconds(value)
+		if err == nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// eventSize returns about the number of keys of the event of lines, so
+// its map doesn't grow while it's parsed: one for each ": " of the
+// header lines, and a few more for the "# User@Host:" line, the database
+// and the statement.
+func eventSize(lines []string) int {
+	n := 5
+	for _, line := range lines[:headerLines(lines)] {
+		n += strings.Count(line, ": ")
+	}
+	return n
+}
+
 // parseEntry actually parses lines that belong to a log event.
-func parseEntry(lines []string) LogEvent {
-	event := LogEvent{}
+func (p *Parser) parseEntry(lines []string) LogEvent {
+	p.limitHeaderLines(lines)
+	p.detectHeader(lines)
+	event := p.newEvent(eventSize(lines))
 	var i int
 	var line string
+	var timeLine time.Time
+	// run is the indexes of the attribute lines since the last line of
+	// another kind, parsed together by parseAttributes.
+	var run []int
 	for i, line = range lines {
 		if line == "" {
 			continue
 		}
-		if line[0] != '#' {
+		if line[0] != '#' || isAdminCommand(line) {
 			break
 		}
+		if strings.HasPrefix(line, "# Time: ") {
+			p.parseAttributes(event, lines, run)
+			run = run[:0]
+			value := strings.TrimSpace(line[len("# Time: "):])
+			t, err := parseTimeLine(value)
+			if err == nil {
+				timeLine = t
+			} else if _, err := time.Parse(legacyTimeLayout, strings.Join(strings.Fields(value), " ")); err != nil {
+				p.parseError(i, line, SectionHeader, ErrBadTimestamp)
+			}
+			continue
+		}
 		if strings.HasPrefix(line, "# User@Host") {
+			p.parseAttributes(event, lines, run)
+			run = run[:0]
 			fields := parseUserHostLine(line)
+			if _, ok := fields["User"]; !ok {
+				p.parseError(i, line, SectionUserHost, ErrBadUserHost)
+			}
 			for k, v := range fields {
 				event[k] = v
 			}
+			if port, ok := fields["Port"]; ok {
+				event["Port"], _ = strconv.ParseInt(port, 10, 64)
+			}
+			if idx := strings.LastIndex(line, "Id:"); idx >= 0 {
+				if id, err := strconv.ParseInt(strings.TrimSpace(line[idx+len("Id:"):]), 10, 64); err == nil {
+					event["Id"] = id
+				}
+			}
 			continue
 		}
-		matches := attributesRe.FindAllString(line, -1)
-		for _, match := range matches {
-			parts := strings.Split(match, ": ")
-			var attributeValue interface{}
-			switch attributeTypes[parts[0]] {
-			case attributeTypeString:
-				attributeValue = parts[1]
-			case attributeTypeBool:
-				v, err := strconv.ParseBool(parts[1])
-				if err == nil {
-					attributeValue = v
+		if strings.HasPrefix(line, storedRoutinePrefix) {
+			p.parseAttributes(event, lines, run)
+			run = run[:0]
+			if routine := parseStoredRoutine(line); routine != "" {
+				event["Routine"] = routine
+			}
+			continue
+		}
+		if p.dialect == TiDB {
+			for _, kv := range parseTiDBAttributes(line) {
+				kind, known := p.attributes.tidbKind(kv[0])
+				if p.keepUnknown(event, kv[0], kv[1], known) {
+					continue
 				}
-			case attributeTypeFloat:
-				v, err := strconv.ParseFloat(parts[1], 64)
-				if err == nil {
-					attributeValue = v
+				known = p.checkKnown(i, line, kv[0], known)
+				attributeValue := p.convertAttribute(kv[0], kv[1], kind)
+				if attributeValue == nil {
+					if p.logger != nil {
+						p.logger.Printf("mysqllog: can't convert %s value %q", kv[0], kv[1])
+					}
+					if known {
+						p.parseError(i, line, SectionHeader, ErrBadAttribute)
+					}
+					continue
 				}
-			case attributeTypeInt:
-				v, err := strconv.ParseInt(parts[1], 10, 64)
-				if err == nil {
-					attributeValue = int64(v)
+				if kv[0] == "DB" {
+					event["Database"] = attributeValue
+					continue
 				}
+				event[kv[0]] = attributeValue
+				p.keepRawNumber(event, kv[0], kv[1], attributeValue)
 			}
-
-			if attributeValue == nil {
-				continue
-			}
-
-			event[parts[0]] = attributeValue
+			continue
 		}
+		run = append(run, i)
 	}
+	p.parseAttributes(event, lines, run)
 
 	// See if we have lines to skip
+	preamble := i
+	// sentWith is a statement on the same line as a use statement.
+	sentWith := ""
 	for ; i < len(lines); i++ {
 		if strings.HasPrefix(lines[i], "use ") {
-			db := strings.TrimRight(strings.Split(lines[i], " ")[1], ";\n")
-			event["Database"] = db
+			db, rest, ok := parseUse(lines[i])
+			if ok {
+				event["Database"] = db
+			}
+			if rest != "" {
+				sentWith = rest
+				i++
+				break
+			}
 			continue
 		}
 		if strings.HasPrefix(lines[i], "SET ") {
 			if strings.HasPrefix(lines[i], "SET timestamp=") {
+				at := i
 				unixTimestampString := strings.TrimRight(strings.Split(lines[i], "=")[1], ";\n")
 				event["Timestamp"] = unixTimestampString
 				i, err := strconv.ParseInt(unixTimestampString, 10, 64)
+				if err != nil {
+					p.parseError(at, lines[at], SectionSet, ErrBadTimestamp)
+				}
 				if err == nil {
-					event["Timestamp"] = time.Unix(i, 0).Format("2006-01-02 15:04:05")
+					event["Timestamp"] = p.reconcileTimeLine(event, time.Unix(i, 0), timeLine)
 				}
 			}
 			continue
@@ -175,15 +808,95 @@ func parseEntry(lines []string) LogEvent {
 		break
 	}
 
+	if _, ok := event["Timestamp"]; !ok && p.dialect == TiDB && !timeLine.IsZero() {
+		// TiDB has no SET timestamp line.
+		event["Timestamp"] = timeLine
+	}
+
 	queryLines := []string{}
+	if sentWith != "" {
+		queryLines = append(queryLines, sentWith)
+	}
 	for ; i < len(lines); i++ {
 		if strings.HasSuffix(lines[i], "started with:\n") {
 			// Rolled over to a new log file
 			break
 		}
-		queryLines = append(queryLines, lines[i])
+		if p.dialect == TiDB && strings.TrimSpace(lines[i]) == ";" {
+			// TiDB may terminate the statement with a lone semicolon.
+			break
+		}
+		queryLines = append(queryLines, strings.TrimRight(lines[i], "\r\n"))
+	}
+	if p.fullText {
+		event["ExecutableText"] = strings.TrimSuffix(strings.Join(lines[preamble:i], ""), "\n")
 	}
 
 	event["Statement"] = strings.TrimSpace(strings.Join(queryLines, "\n"))
+	if statement := event["Statement"].(string); isAdminCommand(statement) {
+		event["AdminCommand"] = adminCommand(statement)
+	}
 	return event
 }
+
+// parseAttributes parses the "Key: value" pairs of the consecutive
+// attribute lines of lines at indexes run into event. The lines are
+// read as one stream, without their "#", so a value wrapped onto the
+// next line still goes with its key.
+func (p *Parser) parseAttributes(event LogEvent, lines []string, run []int) {
+	if len(run) == 0 {
+		return
+	}
+	var b strings.Builder
+	starts := make([]int, len(run))
+	for k, i := range run {
+		if k > 0 {
+			b.WriteByte(' ')
+		}
+		starts[k] = b.Len()
+		b.WriteString(strings.TrimRight(lines[i], "\r\n")[1:])
+	}
+	stream := b.String()
+	k := 0
+	for _, m := range attributeMatches(stream) {
+		for k+1 < len(starts) && starts[k+1] <= m[0] {
+			k++
+		}
+		i, line := run[k], lines[run[k]]
+		match := stream[m[0]:m[1]]
+		// The regexp allows any whitespace after the colon.
+		colon := strings.IndexByte(match, ':')
+		parts := [2]string{match[:colon], strings.TrimSpace(match[colon+1:])}
+		kind, known := p.attributes.kind(parts[0])
+		if p.keepUnknown(event, parts[0], parts[1], known) {
+			continue
+		}
+		known = p.checkKnown(i, line, parts[0], known)
+		attributeValue := p.convertAttribute(parts[0], parts[1], kind)
+		if attributeValue == nil {
+			if p.logger != nil {
+				p.logger.Printf("mysqllog: can't convert %s value %q", parts[0], parts[1])
+			}
+			if known {
+				p.parseError(i, line, SectionHeader, ErrBadAttribute)
+			}
+			continue
+		}
+
+		event[parts[0]] = attributeValue
+		p.keepRawNumber(event, parts[0], parts[1], attributeValue)
+	}
+}
+
+// adminCommandPrefix starts the statement of events for administrator
+// commands such as Quit, which the server logs as a comment.
+const adminCommandPrefix = "# administrator command: "
+
+func isAdminCommand(line string) bool {
+	return strings.HasPrefix(line, adminCommandPrefix)
+}
+
+// adminCommand returns the command of an administrator command statement.
+func adminCommand(statement string) string {
+	return strings.TrimSpace(strings.TrimRight(statement[len(adminCommandPrefix):], "; \r\n"))
+}
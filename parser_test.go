@@ -16,6 +16,22 @@ func jsonPrint(v interface{}) string {
 	return string(b)
 }
 
+// parseAll feeds content to p line by line and returns every event,
+// including the one flushed at the end.
+func parseAll(p *Parser, content string) []LogEvent {
+	events := []LogEvent{}
+	reader := bufio.NewReader(strings.NewReader(content))
+	for line, err := reader.ReadString('\n'); err == nil; line, err = reader.ReadString('\n') {
+		if event := p.ConsumeLine(line); event != nil {
+			events = append(events, event)
+		}
+	}
+	if event := p.Flush(); event != nil {
+		events = append(events, event)
+	}
+	return events
+}
+
 var content = `# Time: 2017-12-24T02:42:00.126000Z
 # User@Host: rdsadmin[rdsadmin] @ localhost [127.0.0.1]  Id:     3
 # Query_time: 0.020363  Lock_time: 0.018450 Rows_sent: 0  Rows_examined: 1
@@ -36,25 +52,63 @@ func TestParseSingleEvent(t *testing.T) {
 			parsedEvent = event
 		}
 	}
+	if event := p.Flush(); event != nil {
+		parsedEvent = event
+	}
 	if parsedEvent == nil {
 		t.Fatal("expected to parse an event")
 	}
 
-	expectedEvent := LogEvent{
-		"User":          "rdsadmin",
-		"Host":          "localhost",
-		"IP":            "127.0.0.1",
-		"Database":      "foo",
-		"Query_time":    float64(0.020363),
-		"Lock_time":     float64(0.018450),
-		"Rows_sent":     int64(0),
-		"Rows_examined": int64(1),
-		"Timestamp":     time.Unix(1514083320, 0).UTC(),
-		"Statement":     "SELECT count(*) from mysql.rds_replication_status WHERE master_host IS NOT NULL and master_port IS NOT NULL GROUP BY action_timestamp,called_by_user,action,mysql_version,master_host,master_port ORDER BY action_timestamp LIMIT 1;",
+	expected := `Database: "foo"
+Host: "localhost"
+IP: "127.0.0.1"
+Id: 3
+Lock_time: 0.01845
+Query_time: 0.020363
+Rows_examined: 1
+Rows_sent: 0
+Statement: "SELECT count(*) from mysql.rds_replication_status WHERE master_host IS NOT NULL and master_port IS NOT NULL GROUP BY action_timestamp,called_by_user,action,mysql_version,master_host,master_port ORDER BY action_timestamp LIMIT 1;"
+Timestamp: "` + time.Unix(1514083320, 0).Format(DefaultTimestampLayout) + `"
+User: "rdsadmin"
+`
+	if got := parsedEvent.Canonical(); got != expected {
+		t.Errorf("unexpected event:\n%s", lineDiff(expected, got))
+	}
+	if _, ok := parsedEvent["Id"].(int64); !ok {
+		t.Errorf("expected Id to be an int64, got %T", parsedEvent["Id"])
 	}
+}
 
-	if !reflect.DeepEqual(parsedEvent, expectedEvent) {
-		t.Errorf("expected event\n%v\n, got\n%v", jsonPrint(expectedEvent), jsonPrint(parsedEvent))
+// TestParseFixtures compares the events of the fixtures of each dialect
+// with their Canonical text in the .events golden file next to them.
+// Timestamps are unix seconds, which don't depend on the time zone.
+func TestParseFixtures(t *testing.T) {
+	for _, name := range []string{"rds", "tidb", "canonical_mysql8", "canonical_percona", "canonical_mariadb", "canonical_tidb",
+		"headers_wrapped", "userhost_ports", "percona_routines", "mariadb_trailer"} {
+		content, err := ioutil.ReadFile("./_test/" + name + ".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		for i, e := range parseAll(NewParser(WithUnixTimestamps()), string(content)) {
+			if i > 0 {
+				buf.WriteByte('\n')
+			}
+			buf.WriteString(e.Canonical())
+		}
+		path := "./_test/" + name + ".events"
+		if *update {
+			if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		golden, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != string(golden) {
+			t.Errorf("events differ from %s (rerun with -update):\n%s", path, lineDiff(string(golden), got))
+		}
 	}
 }
 
@@ -80,6 +134,14 @@ func TestParseUserHostLine(t *testing.T) {
 				"Host": "localhost",
 			},
 		},
+		{
+			Line: "# User@Host: root[root] @ pool-70-106-0-0.clppva.fios.verizon.net [70.106.0.0]  Id:    12",
+			Expected: map[string]string{
+				"User": "root",
+				"Host": "pool-70-106-0-0.clppva.fios.verizon.net",
+				"IP":   "70.106.0.0",
+			},
+		},
 		{
 			Line: "# User@Host: rdsadmin[rdsadmin] @  [127.0.0.1]  Id:     3",
 			Expected: map[string]string{
@@ -88,6 +150,43 @@ func TestParseUserHostLine(t *testing.T) {
 				"IP":   "127.0.0.1",
 			},
 		},
+		{
+			Line: "# User@Host: a@@[b] @ h [10.0.0.1]",
+			Expected: map[string]string{
+				"User": "a@@",
+				"Host": "h",
+				"IP":   "10.0.0.1",
+			},
+		},
+		{
+			Line:     "# User@Host: x",
+			Expected: map[string]string{},
+		},
+		{
+			Line: "# User@Host: app[app] @ web1 [10.0.0.5:43210]",
+			Expected: map[string]string{
+				"User": "app",
+				"Host": "web1",
+				"IP":   "10.0.0.5",
+				"Port": "43210",
+			},
+		},
+		{
+			Line: "# User@Host: app[app] @ proxysql.internal:6033 []",
+			Expected: map[string]string{
+				"User": "app",
+				"Host": "proxysql.internal",
+				"Port": "6033",
+			},
+		},
+		{
+			Line: "# User@Host: app[app] @  [::ffff:10.0.0.5]",
+			Expected: map[string]string{
+				"User": "app",
+				"Host": "::ffff:10.0.0.5",
+				"IP":   "::ffff:10.0.0.5",
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -147,3 +246,151 @@ func BenchmarkParse(b *testing.B) {
 		}
 	}
 }
+
+func TestParseMalformedInput(t *testing.T) {
+	for _, input := range []string{
+		"# User@Host: a@@[b] @ h [i]\nSELECT 1;\n",
+		"# User@Host: x\nSELECT 1;\n",
+		"# Query_time:\t1.5  Foo:\tbar\nSELECT 1;\n",
+		"# Time: 1\nSET timestamp=\nuse \nSELECT 1;\n",
+		"#\tPlan: x\n;\n",
+	} {
+		for _, p := range []*Parser{{}, NewParser(WithDialect(TiDB), WithStrict())} {
+			events := parseAll(p, input)
+			if len(events) != 1 {
+				t.Errorf("%q: expected 1 event, got %d", input, len(events))
+			}
+		}
+	}
+	e := parseAll(&Parser{}, "# Query_time:\t1.5\nSELECT 1;\n")[0]
+	if e["Query_time"] != 1.5 {
+		t.Errorf("expected a Query_time after a tab, got %v", e)
+	}
+}
+
+func TestParseBlankSeparators(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/blank_separators.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := parseAll(&Parser{}, string(b))
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	for i, expected := range []string{
+		"SELECT 1;",
+		"INSERT INTO notes (body) VALUES ('first\n\t\nsecond');",
+		"UPDATE t SET a = 1 -- it's fine\nWHERE id = 2;",
+	} {
+		if events[i]["Statement"] != expected {
+			t.Errorf("event %d: expected statement %q, got %q", i, expected, events[i]["Statement"])
+		}
+	}
+}
+
+func TestParseWrappedHeaders(t *testing.T) {
+	unwrapped, err := ioutil.ReadFile("./_test/headers_unwrapped.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := ioutil.ReadFile("./_test/headers_wrapped.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := parseAll(&Parser{}, string(unwrapped))
+	if len(expected) != 2 || expected[0]["InnoDB_IO_r_wait"] != 0.0002 || expected[0]["Tmp_table_sizes"] != int64(0) {
+		t.Fatalf("unexpected events %v", expected)
+	}
+	p := NewParser(WithStrict())
+	events := parseAll(p, string(wrapped))
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+	if errs := p.Errors(); len(errs) != 0 {
+		t.Errorf("expected no errors, got %v", errs)
+	}
+}
+
+func TestQuoteState(t *testing.T) {
+	type TestCase struct {
+		quote    byte
+		line     string
+		expected byte
+	}
+	for _, c := range []TestCase{
+		{0, "SELECT 'a", '\''},
+		{0, "SELECT 'it''s'", 0},
+		{0, `SELECT "a\"b`, '"'},
+		{0, "SELECT `a\\", '`'},
+		{'\'', "b' FROM t", 0},
+		{0, "SELECT 1 # it's", 0},
+		{0, "SELECT 1 -- it's", 0},
+		{0, "SELECT /* it's */ 'a", '\''},
+		{0, "SELECT 1 /* it's", 0},
+	} {
+		if got := quoteState(c.quote, c.line); got != c.expected {
+			t.Errorf("quoteState(%q, %q): expected %q, got %q", c.quote, c.line, c.expected, got)
+		}
+	}
+}
+
+func TestUserHostPorts(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/userhost_ports.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := parseAll(NewParser(), string(b))
+	type TestCase struct {
+		Host string
+		IP   interface{}
+		Port interface{}
+	}
+	expected := []TestCase{
+		{"10.0.0.5", "10.0.0.5", int64(43210)},
+		{"host.example.com", nil, int64(3306)},
+		{"2001:db8::5", "2001:db8::5", nil},
+		{"2001:db8::5", "2001:db8::5", int64(43210)},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for i, c := range expected {
+		e := events[i]
+		if e["Host"] != c.Host || e["IP"] != c.IP || e["Port"] != c.Port {
+			t.Errorf("event %d: expected host %v, IP %v and port %v, got %v, %v and %v", i, c.Host, c.IP, c.Port, e["Host"], e["IP"], e["Port"])
+		}
+	}
+
+	// The port survives writing the events back.
+	var buf bytes.Buffer
+	for _, e := range events {
+		if err := WriteSlowLog(&buf, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, e := range parseAll(NewParser(), buf.String()) {
+		if !e.Equal(events[i]) {
+			t.Errorf("event %d differs after round trip:\n%s", i, strings.Join(Diff(events[i], e), "\n"))
+		}
+	}
+}
+
+func BenchmarkParseEntry(b *testing.B) {
+	data, err := ioutil.ReadFile("_test/canonical_percona.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var lines []string
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if line == "" || lines != nil && strings.HasPrefix(line, "# Time:") {
+			break
+		}
+		lines = append(lines, line)
+	}
+	p := NewParser()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.parseEntry(lines)
+	}
+}
@@ -0,0 +1,189 @@
+package mysqllog
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Source is the parsing state of one source in a ParserPool.
+type Source struct {
+	// ID is the name of the source, such as a file or an agent.
+	ID string
+	// Parser parses the lines of the source, and only them.
+	Parser *Parser
+	// Data is kept along with the Parser for the caller, such as the
+	// unterminated end of the last chunk read.
+	Data interface{}
+
+	mu sync.Mutex
+	// lastSeen is protected by ParserPool.mu.
+	lastSeen time.Time
+	// removed is set once the source is evicted, so a call that raced
+	// with the eviction starts over with a new one.
+	removed bool
+}
+
+// ParserPool keeps a Parser for each of many sources, such as tailed
+// files or remote agents, which is how logs read concurrently must be
+// parsed: a Parser isn't safe for concurrent use, and lines of
+// different logs fed to the same one are mixed up.
+//
+// Sources are created on first use with the options of the pool. Calls
+// for different sources run concurrently; calls for the same source are
+// serialized. Set the fields before the first call.
+type ParserPool struct {
+	// Idle evicts sources not used for that long, if positive.
+	Idle time.Duration
+	// MaxSources evicts the least recently used source to make room
+	// for a new one, if positive.
+	MaxSources int
+	// Evicted, if set, is called with each source that's evicted or
+	// removed, locked, so its pending event can be flushed.
+	Evicted func(s *Source)
+	// Clock tells the time for Idle, RealClock if nil.
+	Clock Clock
+
+	opts []Option
+
+	mu      sync.Mutex
+	lru     *list.List
+	sources map[string]*list.Element
+}
+
+// NewParserPool returns a ParserPool creating Parsers with opts.
+func NewParserPool(opts ...Option) *ParserPool {
+	return &ParserPool{
+		opts:    opts,
+		lru:     list.New(),
+		sources: map[string]*list.Element{},
+	}
+}
+
+// Do calls fn with the source id, creating it if needed, while no other
+// call for id runs.
+func (pp *ParserPool) Do(id string, fn func(s *Source)) {
+	var s *Source
+	for {
+		s = pp.source(id)
+		s.mu.Lock()
+		if !s.removed {
+			break
+		}
+		s.mu.Unlock()
+	}
+	defer s.mu.Unlock()
+	fn(s)
+}
+
+// Remove evicts the source id, if there's one.
+func (pp *ParserPool) Remove(id string) {
+	pp.mu.Lock()
+	el, ok := pp.sources[id]
+	var s *Source
+	if ok {
+		s = pp.remove(el)
+	}
+	pp.mu.Unlock()
+	if s != nil {
+		pp.evict(s)
+	}
+}
+
+// Close evicts every source.
+func (pp *ParserPool) Close() {
+	pp.mu.Lock()
+	var removed []*Source
+	for el := pp.lru.Back(); el != nil; el = pp.lru.Back() {
+		removed = append(removed, pp.remove(el))
+	}
+	pp.mu.Unlock()
+	for _, s := range removed {
+		pp.evict(s)
+	}
+}
+
+// Expire evicts the sources not used for Idle, which calls otherwise do
+// only when they get a source, so idle sources are flushed without
+// waiting for another call.
+func (pp *ParserPool) Expire() {
+	pp.mu.Lock()
+	expired := pp.expired(clockOf(pp.Clock).Now())
+	pp.mu.Unlock()
+	for _, s := range expired {
+		pp.evict(s)
+	}
+}
+
+// Sources returns the sorted IDs of the sources in the pool.
+func (pp *ParserPool) Sources() []string {
+	pp.mu.Lock()
+	ids := make([]string, 0, len(pp.sources))
+	for id := range pp.sources {
+		ids = append(ids, id)
+	}
+	pp.mu.Unlock()
+	sort.Strings(ids)
+	return ids
+}
+
+// source returns the source id, creating it if needed, after evicting
+// idle sources and making room for a new one.
+func (pp *ParserPool) source(id string) *Source {
+	pp.mu.Lock()
+	now := clockOf(pp.Clock).Now()
+	expired := pp.expired(now)
+	el, ok := pp.sources[id]
+	if ok {
+		el.Value.(*Source).lastSeen = now
+		pp.lru.MoveToFront(el)
+	} else {
+		if pp.MaxSources > 0 && pp.lru.Len() >= pp.MaxSources {
+			expired = append(expired, pp.remove(pp.lru.Back()))
+		}
+		s := &Source{ID: id, Parser: NewParser(pp.opts...), lastSeen: now}
+		s.Parser.inputID = id
+		el = pp.lru.PushFront(s)
+		pp.sources[id] = el
+	}
+	s := el.Value.(*Source)
+	pp.mu.Unlock()
+
+	for _, old := range expired {
+		pp.evict(old)
+	}
+	return s
+}
+
+// expired removes the sources not used for Idle at now, which pp.mu must
+// protect, and returns them.
+func (pp *ParserPool) expired(now time.Time) []*Source {
+	var expired []*Source
+	for el := pp.lru.Back(); el != nil; el = pp.lru.Back() {
+		s := el.Value.(*Source)
+		if pp.Idle <= 0 || now.Sub(s.lastSeen) <= pp.Idle {
+			break
+		}
+		expired = append(expired, pp.remove(el))
+	}
+	return expired
+}
+
+// remove unlinks el, which pp.mu must protect, and returns its source.
+func (pp *ParserPool) remove(el *list.Element) *Source {
+	s := el.Value.(*Source)
+	pp.lru.Remove(el)
+	delete(pp.sources, s.ID)
+	return s
+}
+
+// evict marks a removed source and passes it to Evicted.
+func (pp *ParserPool) evict(s *Source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removed = true
+	if pp.Evicted != nil {
+		pp.Evicted(s)
+	}
+}
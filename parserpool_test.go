@@ -0,0 +1,120 @@
+package mysqllog
+
+import (
+	"bufio"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParserPoolConcurrent(t *testing.T) {
+	pool := NewParserPool()
+	var (
+		mu     sync.Mutex
+		events = map[string][]string{}
+	)
+	collect := func(id string, e LogEvent) {
+		if e == nil {
+			return
+		}
+		mu.Lock()
+		events[id] = append(events[id], e["Statement"].(string))
+		mu.Unlock()
+	}
+	pool.Evicted = func(s *Source) {
+		collect(s.ID, s.Parser.Flush())
+	}
+
+	// Interleaves the lines of several logs, each read by a goroutine.
+	const sources, n = 4, 50
+	var wg sync.WaitGroup
+	for i := 0; i < sources; i++ {
+		id := fmt.Sprintf("log%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var log strings.Builder
+			for j := 0; j < n; j++ {
+				log.WriteString(slowEvent(j))
+			}
+			reader := bufio.NewReader(strings.NewReader(log.String()))
+			for line, err := reader.ReadString('\n'); err == nil; line, err = reader.ReadString('\n') {
+				pool.Do(id, func(s *Source) {
+					collect(id, s.Parser.ConsumeLine(line))
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	pool.Close()
+
+	if ids := pool.Sources(); len(ids) != 0 {
+		t.Errorf("expected no sources after Close, got %v", ids)
+	}
+	for i := 0; i < sources; i++ {
+		id := fmt.Sprintf("log%d", i)
+		got := events[id]
+		if len(got) != n {
+			t.Errorf("%s: expected %d events, got %d", id, n, len(got))
+			continue
+		}
+		for j, statement := range got {
+			if want := fmt.Sprintf("SELECT %d;", j); statement != want {
+				t.Errorf("%s: expected event %d to be %q, got %q", id, j, want, statement)
+			}
+		}
+	}
+}
+
+func TestParserPoolEviction(t *testing.T) {
+	pool := NewParserPool()
+	pool.Idle = time.Minute
+	pool.MaxSources = 2
+	clock := NewManualClock(time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC))
+	pool.Clock = clock
+	var evicted []string
+	pool.Evicted = func(s *Source) {
+		evicted = append(evicted, s.ID)
+	}
+	use := func(id string) {
+		pool.Do(id, func(s *Source) {
+			s.Data = id
+		})
+	}
+
+	use("a")
+	use("b")
+	use("a")
+	use("c")
+	if want := []string{"b"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("expected the least recently used source to be evicted, got %v", evicted)
+	}
+	if want := []string{"a", "c"}; !reflect.DeepEqual(pool.Sources(), want) {
+		t.Errorf("expected sources %v, got %v", want, pool.Sources())
+	}
+
+	clock.Add(2 * time.Minute)
+	use("d")
+	if want := []string{"b", "a", "c"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("expected idle sources to be evicted, got %v", evicted)
+	}
+
+	pool.Do("d", func(s *Source) {
+		if s.Data != "d" {
+			t.Errorf("expected the data of the source to be kept, got %v", s.Data)
+		}
+	})
+	pool.Remove("d")
+	pool.Remove("missing")
+	if want := []string{"b", "a", "c", "d"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("expected the removed source to be evicted, got %v", evicted)
+	}
+	pool.Do("d", func(s *Source) {
+		if s.Data != nil {
+			t.Errorf("expected a new source after Remove, got data %v", s.Data)
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package mysqllog
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// DefaultPipelineBuffer is the number of events buffered between the
+// stages of a Pipeline per worker.
+const DefaultPipelineBuffer = 16
+
+// Pipeline applies an expensive Transform, such as fingerprinting,
+// redaction or classification, to events with a pool of worker
+// goroutines before writing them to a sink. The zero value passes
+// events through unchanged, in order of completion.
+type Pipeline struct {
+	// Workers is the number of goroutines running Transform,
+	// runtime.GOMAXPROCS(0) if zero or less.
+	Workers int
+	// Transform returns the event to write, or nil to drop it. It's
+	// called concurrently.
+	Transform func(LogEvent) LogEvent
+	// Ordered writes events in the order they were received, holding
+	// back those that finish early. Otherwise they're written as soon
+	// as they're transformed.
+	Ordered bool
+	// Buffer is the number of events in flight per worker,
+	// DefaultPipelineBuffer if zero or less. Once it's reached, the
+	// pipeline stops receiving until the sink catches up.
+	Buffer int
+}
+
+type pipelineItem struct {
+	seq   int64
+	event LogEvent
+}
+
+// Run transforms the events received from in and writes them to sink,
+// until in is closed or ctx is done. It returns the first error from
+// sink, after which the remaining events aren't written, or ctx.Err().
+// All goroutines have exited when Run returns. The sink is not closed.
+func (p *Pipeline) Run(ctx context.Context, in <-chan LogEvent, sink Sink) error {
+	workers := p.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	buffer := p.Buffer
+	if buffer <= 0 {
+		buffer = DefaultPipelineBuffer
+	}
+	transform := p.Transform
+	if transform == nil {
+		transform = func(e LogEvent) LogEvent { return e }
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// window bounds the events between receiving and writing, so a
+	// slow sink holds back the producer.
+	window := make(chan struct{}, workers*buffer)
+	jobs := make(chan pipelineItem, workers)
+	results := make(chan pipelineItem, workers)
+
+	go func() {
+		defer close(jobs)
+		var seq int64
+		for {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			var e LogEvent
+			var ok bool
+			select {
+			case e, ok = <-in:
+			case <-ctx.Done():
+				return
+			}
+			if !ok {
+				return
+			}
+			select {
+			case jobs <- pipelineItem{seq: seq, event: e}:
+				seq++
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				item.event = transform(item.event)
+				select {
+				case results <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var err error
+	write := func(e LogEvent) {
+		<-window
+		if e == nil || err != nil {
+			return
+		}
+		if err = sink.Write(e); err != nil {
+			cancel()
+		}
+	}
+	pending := map[int64]LogEvent{}
+	var next int64
+	for item := range results {
+		if !p.Ordered {
+			write(item.event)
+			continue
+		}
+		pending[item.seq] = item.event
+		for {
+			e, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			write(e)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return parent.Err()
+}
+
+// RunReader parses the slow query log read from r, as with ParseReader,
+// and runs the events through the pipeline to sink. It returns the
+// first error from reading, sink or ctx.
+func (p *Pipeline) RunReader(ctx context.Context, r io.Reader, sink Sink, opts ...ReadOption) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	in := make(chan LogEvent)
+	parsed := make(chan error, 1)
+	go func() {
+		defer close(in)
+		parsed <- ParseReader(contextReader{ctx: ctx, r: r}, func(e LogEvent) {
+			select {
+			case in <- e:
+			case <-ctx.Done():
+			}
+		}, opts...)
+	}()
+
+	err := p.Run(ctx, in, sink)
+	cancel()
+	parseErr := <-parsed
+	if re, ok := parseErr.(*ReadError); ok && re.Err == context.Canceled {
+		parseErr = nil
+	}
+	if err == nil {
+		err = parseErr
+	}
+	return err
+}
+
+// contextReader stops reading once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
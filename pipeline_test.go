@@ -0,0 +1,171 @@
+package mysqllog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func numberedEvents(n int) <-chan LogEvent {
+	in := make(chan LogEvent)
+	go func() {
+		defer close(in)
+		for i := 0; i < n; i++ {
+			in <- LogEvent{"Statement": fmt.Sprintf("SELECT %d", i), "N": i}
+		}
+	}()
+	return in
+}
+
+func TestPipelineOrdered(t *testing.T) {
+	sink := &recordingSink{}
+	p := &Pipeline{
+		Workers: 4,
+		Ordered: true,
+		Transform: func(e LogEvent) LogEvent {
+			n := e["N"].(int)
+			// Later events finish first.
+			time.Sleep(time.Duration(10-n%10) * 100 * time.Microsecond)
+			if n%7 == 0 {
+				return nil
+			}
+			e["Fingerprint"] = Fingerprint(e["Statement"].(string))
+			return e
+		},
+	}
+	if err := p.Run(context.Background(), numberedEvents(200), sink); err != nil {
+		t.Fatal(err)
+	}
+	last := -1
+	for _, e := range sink.events {
+		n := e["N"].(int)
+		if n <= last || n%7 == 0 || e["Fingerprint"] != "select ?" {
+			t.Fatalf("unexpected event %v after %d", e, last)
+		}
+		last = n
+	}
+	if len(sink.events) != 200-29 {
+		t.Errorf("expected %d events, got %d", 200-29, len(sink.events))
+	}
+}
+
+func TestPipelineUnordered(t *testing.T) {
+	sink := &recordingSink{}
+	p := &Pipeline{Workers: 3}
+	if err := p.Run(context.Background(), numberedEvents(100), sink); err != nil {
+		t.Fatal(err)
+	}
+	seen := map[int]bool{}
+	for _, e := range sink.events {
+		seen[e["N"].(int)] = true
+	}
+	if len(seen) != 100 {
+		t.Errorf("expected every event once, got %d", len(seen))
+	}
+}
+
+type blockingSink struct {
+	release chan struct{}
+	written int
+}
+
+func (s *blockingSink) Write(e LogEvent) error {
+	<-s.release
+	s.written++
+	return nil
+}
+
+func (s *blockingSink) Close() error { return nil }
+
+func TestPipelineBackpressureAndCancel(t *testing.T) {
+	var produced int64
+	in := make(chan LogEvent)
+	go func() {
+		defer close(in)
+		for i := 0; i < 1000; i++ {
+			in <- LogEvent{"N": i}
+			atomic.AddInt64(&produced, 1)
+		}
+	}()
+
+	sink := &blockingSink{release: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	p := &Pipeline{Workers: 2, Buffer: 4}
+	go func() { done <- p.Run(ctx, in, sink) }()
+
+	time.Sleep(20 * time.Millisecond)
+	// The sink is stuck on the first event, so the producer is held back
+	// once the window of 2*4 events is full.
+	if n := atomic.LoadInt64(&produced); n > 10 {
+		t.Errorf("expected the producer to be held back, got %d events produced", n)
+	}
+	cancel()
+	close(sink.release)
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the pipeline to drain on cancellation")
+	}
+	// Unblocks the producer.
+	for range in {
+	}
+}
+
+func TestPipelineRunReader(t *testing.T) {
+	failing := errors.New("full")
+	sink := &recordingSink{}
+	p := &Pipeline{Workers: 2, Ordered: true}
+	log := strings.Repeat(slowEvent(1), 50)
+	if err := p.RunReader(context.Background(), strings.NewReader(log), sink); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.events) != 50 {
+		t.Errorf("expected 50 events, got %d", len(sink.events))
+	}
+
+	sink = &recordingSink{err: failing}
+	if err := p.RunReader(context.Background(), strings.NewReader(log), sink); err != failing {
+		t.Errorf("expected the sink error, got %v", err)
+	}
+	if len(sink.events) != 1 {
+		t.Errorf("expected writes to stop after an error, got %d", len(sink.events))
+	}
+}
+
+// benchmarkStatement is long enough for fingerprinting and table
+// extraction to dominate.
+var benchmarkStatement = "SELECT o.id, o.total, c.name FROM orders o JOIN customers c ON c.id = o.customer_id " +
+	"WHERE o.created_at > '2018-03-01 10:00:00' AND c.region IN (1, 2, 3, 4, 5) AND o.status = 'shipped' " +
+	"ORDER BY o.created_at DESC LIMIT 100"
+
+func BenchmarkPipeline(b *testing.B) {
+	transform := func(e LogEvent) LogEvent {
+		statement := e["Statement"].(string)
+		e["Fingerprint"] = Fingerprint(statement)
+		e["Tables"] = Tables(statement)
+		return e
+	}
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			in := make(chan LogEvent, 64)
+			go func() {
+				defer close(in)
+				for i := 0; i < b.N; i++ {
+					in <- LogEvent{"Statement": benchmarkStatement}
+				}
+			}()
+			p := &Pipeline{Workers: workers, Transform: transform}
+			if err := p.Run(context.Background(), in, MultiSink()); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
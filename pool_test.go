@@ -0,0 +1,130 @@
+package mysqllog
+
+import (
+	"bufio"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// poolToken is put in pooled events by trackPoolLeaks. Its finalizer
+// counts a leak if the event is collected without being released.
+type poolToken struct {
+	released int32
+	leaks    *int64
+}
+
+const poolTokenKey = "_poolToken"
+
+var poolTracking sync.Mutex
+
+// trackPoolLeaks counts the pooled events that are garbage collected
+// without being released, until the returned function is called.
+func trackPoolLeaks(t *testing.T) (leaks func() int64, stop func()) {
+	poolTracking.Lock()
+	var n int64
+	poolGet = func(e LogEvent) {
+		token := &poolToken{leaks: &n}
+		runtime.SetFinalizer(token, func(token *poolToken) {
+			if atomic.LoadInt32(&token.released) == 0 {
+				atomic.AddInt64(token.leaks, 1)
+			}
+		})
+		e[poolTokenKey] = token
+	}
+	poolRelease = func(e LogEvent) {
+		if token, ok := e[poolTokenKey].(*poolToken); ok {
+			atomic.StoreInt32(&token.released, 1)
+		}
+	}
+	leaks = func() int64 {
+		for i := 0; i < 5; i++ {
+			runtime.GC()
+			time.Sleep(20 * time.Millisecond)
+		}
+		return atomic.LoadInt64(&n)
+	}
+	return leaks, func() {
+		poolGet, poolRelease = nil, nil
+		poolTracking.Unlock()
+	}
+}
+
+func TestWithEventPool(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/rds.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := parseAll(&Parser{}, string(b))
+	leaks, stop := trackPoolLeaks(t)
+	defer stop()
+
+	p := NewParser(WithEventPool())
+	var n int
+	r := bufio.NewReader(strings.NewReader(string(b)))
+	consume := func(e LogEvent) {
+		if e == nil {
+			return
+		}
+		clone := e.Clone()
+		delete(clone, poolTokenKey)
+		if !clone.Equal(expected[n]) {
+			t.Errorf("event %d: %q", n, Diff(expected[n], clone))
+		}
+		n++
+		// One event is kept without being released.
+		if n != 3 {
+			e.Release()
+		}
+	}
+	for line, err := r.ReadString('\n'); err == nil; line, err = r.ReadString('\n') {
+		consume(p.ConsumeLine(line))
+	}
+	consume(p.Flush())
+	if n != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), n)
+	}
+	if n := leaks(); n != 1 {
+		t.Errorf("expected 1 leaked event, got %d", n)
+	}
+}
+
+func TestWithEventPoolDropped(t *testing.T) {
+	leaks, stop := trackPoolLeaks(t)
+	defer stop()
+	p := NewParser(WithEventPool(), WithMinRowsExamined(10))
+	events := parseAll(p, "# Query_time: 1  Rows_examined: 1\nSELECT 1;\n# Query_time: 1  Rows_examined: 20\nSELECT 2;\n")
+	if len(events) != 1 || events[0]["Statement"] != "SELECT 2;" {
+		t.Fatalf("unexpected events %v", events)
+	}
+	events[0].Release()
+	events = nil
+	if n := leaks(); n != 0 {
+		t.Errorf("expected the dropped event to be released, got %d leaks", n)
+	}
+}
+
+func BenchmarkParsePool(b *testing.B) {
+	for _, pooled := range []bool{false, true} {
+		name := "default"
+		if pooled {
+			name = "pooled"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p := &Parser{pooled: pooled}
+				reader := bufio.NewReader(strings.NewReader(content))
+				for line, err := reader.ReadString('\n'); err == nil; line, err = reader.ReadString('\n') {
+					if e := p.ConsumeLine(line); e != nil && pooled {
+						e.Release()
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package mysqllog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultPrettyWidth is the width of a PrettyWriter when it isn't given
+// and COLUMNS isn't set.
+const DefaultPrettyWidth = 80
+
+// ANSI escape sequences used by PrettyWriter.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// PrettyWriter is a Sink printing each event as a short block for
+// people following a log in a terminal: the timestamp, Query_time and
+// rows on the first line, user@host and database on the second, and
+// the statement on one line with keywords in bold, truncated to the
+// width of the terminal. Query_time is colored by the event's
+// "Severity", or else red from 10s and yellow from 1s.
+//
+// Colors are on when writing to a terminal, unless the NO_COLOR
+// environment variable is set or TERM is "dumb". Control characters in
+// statements are replaced, so a log line can't inject escape sequences.
+type PrettyWriter struct {
+	w      *bufio.Writer
+	color  bool
+	width  int
+	expand bool
+}
+
+// PrettyOption configures a PrettyWriter.
+type PrettyOption func(*PrettyWriter)
+
+// WithColor turns colors on or off regardless of the output.
+func WithColor(on bool) PrettyOption {
+	return func(p *PrettyWriter) {
+		p.color = on
+	}
+}
+
+// WithWidth truncates lines to n columns instead of the width from the
+// COLUMNS environment variable or DefaultPrettyWidth.
+func WithWidth(n int) PrettyOption {
+	return func(p *PrettyWriter) {
+		p.width = n
+	}
+}
+
+// WithFullStatements prints statements whole, with their line breaks,
+// instead of truncating them to a line.
+func WithFullStatements() PrettyOption {
+	return func(p *PrettyWriter) {
+		p.expand = true
+	}
+}
+
+// NewPrettyWriter returns a PrettyWriter writing to w.
+func NewPrettyWriter(w io.Writer, opts ...PrettyOption) *PrettyWriter {
+	p := &PrettyWriter{
+		w:     bufio.NewWriter(w),
+		color: isTerminal(w) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb",
+		width: DefaultPrettyWidth,
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		p.width = columns
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// isTerminal reports whether w is a character device, such as a
+// terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Write prints e as a block followed by a blank line.
+func (p *PrettyWriter) Write(e LogEvent) error {
+	ts := "-"
+	if t, ok := EventTime(e); ok {
+		ts = t.Format(DefaultTimestampLayout)
+	} else if s, ok := e["Timestamp"].(string); ok {
+		ts = s
+	}
+	queryTime, _ := e.Float64("Query_time")
+	p.w.WriteString(sanitize(ts, false) + "  " + p.paint(severityColor(e, queryTime), fmt.Sprintf("%.6fs", queryTime)))
+	if sent, ok := e.Int64("Rows_sent"); ok {
+		examined, _ := e.Int64("Rows_examined")
+		p.w.WriteString(p.paint(ansiDim, fmt.Sprintf("  rows %d/%d", sent, examined)))
+	}
+	p.w.WriteByte('\n')
+
+	var who []string
+	user, _ := e["User"].(string)
+	host, _ := e["Host"].(string)
+	if user != "" || host != "" {
+		who = append(who, user+"@"+host)
+	}
+	if db, ok := e["Database"].(string); ok && db != "" {
+		who = append(who, db)
+	}
+	if len(who) > 0 {
+		line := truncateWidth(sanitize(strings.Join(who, "  "), false), p.width)
+		p.w.WriteString(p.paint(ansiCyan, line))
+		p.w.WriteByte('\n')
+	}
+
+	statement, _ := e["Statement"].(string)
+	if p.expand {
+		statement = sanitize(statement, true)
+	} else {
+		statement = truncateWidth(sanitize(strings.Join(strings.Fields(statement), " "), false), p.width)
+	}
+	p.w.WriteString(p.highlight(statement))
+	p.w.WriteString("\n\n")
+	return p.w.Flush()
+}
+
+// Close implements Sink. It doesn't close the underlying writer.
+func (p *PrettyWriter) Close() error {
+	return p.w.Flush()
+}
+
+// paint wraps s in the escape sequence code if colors are on.
+func (p *PrettyWriter) paint(code, s string) string {
+	if !p.color || code == "" {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// severityColor returns the color of the Query_time of e.
+func severityColor(e LogEvent, queryTime float64) string {
+	severity, _ := e["Severity"].(string)
+	if severity == "" {
+		switch {
+		case queryTime >= 10:
+			severity = SeverityCritical
+		case queryTime >= 1:
+			severity = SeverityWarn
+		default:
+			severity = SeverityInfo
+		}
+	}
+	switch severity {
+	case SeverityCritical:
+		return ansiRed
+	case SeverityWarn:
+		return ansiYellow
+	case SeverityInfo:
+		return ansiGreen
+	}
+	return ""
+}
+
+// prettyKeywords are the words PrettyWriter prints in bold.
+var prettyKeywords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`SELECT INSERT UPDATE DELETE REPLACE INTO VALUES VALUE SET FROM WHERE
+		AND OR NOT IN IS NULL LIKE BETWEEN EXISTS JOIN INNER LEFT RIGHT OUTER CROSS ON USING AS GROUP BY
+		ORDER HAVING LIMIT OFFSET UNION ALL DISTINCT ASC DESC CASE WHEN THEN ELSE END CREATE ALTER DROP
+		TABLE INDEX TRUNCATE RENAME CALL SHOW EXPLAIN BEGIN COMMIT ROLLBACK USE FOR LOCK SHARE MODE
+		DUPLICATE KEY WITH`) {
+		prettyKeywords[word] = true
+	}
+}
+
+// highlight puts the keywords of statement in bold if colors are on.
+// Quoted strings and identifiers are left alone.
+func (p *PrettyWriter) highlight(statement string) string {
+	if !p.color {
+		return statement
+	}
+	var b strings.Builder
+	for i := 0; i < len(statement); i++ {
+		c := statement[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			j := skipQuoted(statement, i)
+			b.WriteString(statement[i : j+1])
+			i = j
+		case isIdentByte(c):
+			j := i + 1
+			for j < len(statement) && isIdentByte(statement[j]) {
+				j++
+			}
+			if word := statement[i:j]; prettyKeywords[strings.ToUpper(word)] {
+				b.WriteString(ansiBold + word + ansiReset)
+			} else {
+				b.WriteString(word)
+			}
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// sanitize replaces control characters and invalid UTF-8 in s with
+// U+FFFD. With lines, line breaks are kept and tabs become spaces.
+func sanitize(s string, lines bool) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case lines && r == '\n':
+			return r
+		case lines && r == '\t':
+			return ' '
+		case r == utf8.RuneError || unicode.IsControl(r):
+			return utf8.RuneError
+		}
+		return r
+	}, s)
+}
+
+// truncateWidth truncates s to width terminal columns, ending with an
+// ellipsis when it's cut. Wide runes, such as CJK, take two columns and
+// combining marks none.
+func truncateWidth(s string, width int) string {
+	if width <= 0 || displayWidth(s) <= width {
+		return s
+	}
+	ellipsis := "..."
+	if width <= len(ellipsis) {
+		ellipsis = ""
+	}
+	limit := width - len(ellipsis)
+	columns := 0
+	for i, r := range s {
+		w := runeWidth(r)
+		if columns+w > limit {
+			return s[:i] + ellipsis
+		}
+		columns += w
+	}
+	return s
+}
+
+// displayWidth returns the number of terminal columns of s.
+func displayWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// wideRanges are the ranges of runes taking two terminal columns: East
+// Asian wide and fullwidth characters and emoji.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f},
+	{0x2e80, 0x303e},
+	{0x3041, 0x33ff},
+	{0x3400, 0x4dbf},
+	{0x4e00, 0x9fff},
+	{0xa000, 0xa4cf},
+	{0xac00, 0xd7a3},
+	{0xf900, 0xfaff},
+	{0xfe30, 0xfe4f},
+	{0xff00, 0xff60},
+	{0xffe0, 0xffe6},
+	{0x1f300, 0x1f64f},
+	{0x1f900, 0x1f9ff},
+	{0x20000, 0x3fffd},
+}
+
+// runeWidth returns the number of terminal columns of r.
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, wide := range wideRanges {
+		if r >= wide[0] && r <= wide[1] {
+			return 2
+		}
+	}
+	return 1
+}
@@ -0,0 +1,95 @@
+package mysqllog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPrettyWriter(t *testing.T) {
+	events := []LogEvent{
+		{
+			"Timestamp": time.Date(2018, 3, 1, 10, 0, 0, 0, time.Local), "Query_time": 12.5,
+			"Rows_sent": int64(1), "Rows_examined": int64(250000),
+			"User": "app", "Host": "web1", "Database": "shop",
+			"Statement": "SELECT name, 'from here' FROM `select` WHERE id IN (1, 2)\n  ORDER BY name LIMIT 10;",
+		},
+		{
+			"Timestamp": "2018-03-01 10:00:05", "Query_time": 0.25, "Severity": SeverityCritical,
+			"Statement": "UPDATE 表 SET 名前 = '東京タワー' WHERE id = 1",
+		},
+		{"Query_time": 1.5, "Statement": "INSERT INTO t VALUES ('\x1b[31mred')"},
+	}
+	type TestCase struct {
+		golden string
+		opts   []PrettyOption
+	}
+	for _, c := range []TestCase{
+		{"pretty.txt", []PrettyOption{WithColor(false), WithWidth(40)}},
+		{"pretty_color.txt", []PrettyOption{WithColor(true), WithWidth(40)}},
+		{"pretty_full.txt", []PrettyOption{WithColor(false), WithFullStatements()}},
+	} {
+		var buf bytes.Buffer
+		w := NewPrettyWriter(&buf, c.opts...)
+		for _, e := range events {
+			if err := w.Write(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		path := "./_test/" + c.golden
+		if *update {
+			if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		golden, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), golden) {
+			t.Errorf("output differs from %s (rerun with -update), got\n%s", path, buf.String())
+		}
+		if bytes.Contains(buf.Bytes(), []byte("\x1b[31mred")) {
+			t.Errorf("%s: expected escape sequences in statements to be replaced", c.golden)
+		}
+	}
+}
+
+func TestPrettyWriterColorDetection(t *testing.T) {
+	f, err := ioutil.TempFile("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if NewPrettyWriter(f).color || NewPrettyWriter(&bytes.Buffer{}).color {
+		t.Error("expected no colors when not writing to a terminal")
+	}
+}
+
+func TestTruncateWidth(t *testing.T) {
+	type TestCase struct {
+		s        string
+		width    int
+		expected string
+	}
+	for _, c := range []TestCase{
+		{"SELECT 1", 8, "SELECT 1"},
+		{"SELECT 12", 8, "SELEC..."},
+		{"東京タワー", 10, "東京タワー"},
+		{"東京タワー", 8, "東京..."},
+		{"東京タワー", 9, "東京タ..."},
+		{"café au lait", 5, "ca..."},
+		{"café au lait", 7, "café..."},
+		{"SELECT", 2, "SE"},
+	} {
+		if got := truncateWidth(c.s, c.width); got != c.expected {
+			t.Errorf("truncateWidth(%q, %d): expected %q, got %q", c.s, c.width, c.expected, got)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package mysqllog
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Checksum returns the checksum pt-query-digest gives fingerprint: the
+// last 16 hex digits of its MD5, in upper case.
+func Checksum(fingerprint string) string {
+	sum := md5.Sum([]byte(fingerprint))
+	return strings.ToUpper(hex.EncodeToString(sum[8:]))
+}
+
+// FingerprintTag returns the first 8 digits of the Checksum of the
+// fingerprint of e in lower case, a short tag for metrics, or "" if e has
+// no statement.
+func FingerprintTag(e LogEvent) string {
+	fingerprint := eventFingerprint(e)
+	if fingerprint == "" {
+		return ""
+	}
+	return shortChecksum(fingerprint)
+}
+
+// shortChecksum returns the first 8 digits of the Checksum of
+// fingerprint in lower case.
+func shortChecksum(fingerprint string) string {
+	return strings.ToLower(Checksum(fingerprint)[:8])
+}
+
+// Distill returns a short description of statement in the style of
+// pt-query-digest, its Verb followed by its Tables, as in
+// "SELECT orders customers".
+func Distill(statement string) string {
+	return strings.Join(append([]string{Verb(statement)}, Tables(statement)...), " ")
+}
+
+// WritePTDigestJSON writes results in the shape of the JSON output of
+// pt-query-digest (--output json), with a class for each fingerprint
+// under "classes" and the totals under "global". results are expected
+// in the order returned by Aggregator.Results; WithTopN limits the
+// classes.
+//
+// Each class has its attribute, checksum (see Checksum), distillate
+// (see Distill), fingerprint, example (the slowest event), Query_time
+// histogram, query_count, and ts_min and ts_max in UTC. Query_time metrics have
+// every statistic, approximating median and pct_95 as Quantile does.
+// Lock_time, Rows_sent, Rows_examined and Query_length only have sum,
+// avg and pct, since the Aggregator doesn't keep their distributions;
+// db, user and host are those of the example rather than the most
+// frequent ones. The "tables" of classes, "as_select" of examples and
+// the "files" of global aren't written.
+func WritePTDigestJSON(w io.Writer, results []QueryStats, opts ...ReportOption) error {
+	o := newReportOptions(opts)
+	var global QueryStats
+	for _, s := range results {
+		if err := global.Merge(s); err != nil {
+			return err
+		}
+	}
+	top := results
+	if o.topN > 0 && len(top) > o.topN {
+		top = top[:o.topN]
+	}
+
+	classes := make([]map[string]interface{}, 0, len(top))
+	for _, s := range top {
+		statement, _ := s.Sample["Statement"].(string)
+		example := map[string]interface{}{
+			"query":      statement,
+			"Query_time": fmt.Sprintf("%.6f", s.MaxTime),
+		}
+		if ts, ok := EventTime(s.Sample); ok {
+			example["ts"] = ts.UTC().Format(DefaultTimestampLayout)
+		}
+		metrics := ptMetrics(s, &global)
+		for attribute, key := range map[string]string{"db": "Database", "user": "User", "host": "Host"} {
+			if v, ok := s.Sample[key].(string); ok {
+				metrics[attribute] = map[string]string{"value": v}
+			}
+		}
+		class := map[string]interface{}{
+			"attribute":   "fingerprint",
+			"checksum":    Checksum(s.Fingerprint),
+			"distillate":  Distill(statement),
+			"fingerprint": s.Fingerprint,
+			"example":     example,
+			"histograms":  map[string][HistogramBuckets]int64{"Query_time": s.Histogram},
+			"metrics":     metrics,
+			"query_count": s.Count,
+		}
+		if !s.FirstSeen.IsZero() {
+			class["ts_min"] = s.FirstSeen.UTC().Format(DefaultTimestampLayout)
+			class["ts_max"] = s.LastSeen.UTC().Format(DefaultTimestampLayout)
+		}
+		classes = append(classes, class)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{
+		"classes": classes,
+		"global": map[string]interface{}{
+			"metrics":            ptMetrics(global, nil),
+			"query_count":        global.Count,
+			"unique_query_count": len(results),
+		},
+	})
+}
+
+// ptMetrics returns the pt-query-digest metrics of s, with the share
+// of each in global, if any.
+func ptMetrics(s QueryStats, global *QueryStats) map[string]interface{} {
+	count := float64(s.Count)
+	if count == 0 {
+		count = 1
+	}
+	queryTime := map[string]string{
+		"sum":    fmt.Sprintf("%.6f", s.TotalTime),
+		"avg":    fmt.Sprintf("%.6f", s.MeanTime()),
+		"min":    fmt.Sprintf("%.6f", s.MinTime),
+		"max":    fmt.Sprintf("%.6f", s.MaxTime),
+		"median": fmt.Sprintf("%.6f", s.Quantile(0.5)),
+		"pct_95": fmt.Sprintf("%.6f", s.P95Time()),
+		"stddev": fmt.Sprintf("%.6f", s.StddevTime()),
+	}
+	lockTime := map[string]string{
+		"sum": fmt.Sprintf("%.6f", s.LockTime),
+		"avg": fmt.Sprintf("%.6f", s.LockTime/count),
+	}
+	totals := ptTotals(s)
+	metrics := map[string]interface{}{"Query_time": queryTime, "Lock_time": lockTime}
+	for key, total := range totals {
+		metrics[key] = map[string]string{
+			"sum": fmt.Sprintf("%d", total),
+			"avg": fmt.Sprintf("%.0f", float64(total)/count),
+		}
+	}
+	if global != nil {
+		share := func(part, total float64) string {
+			return fmt.Sprintf("%.2f", percent(part, total)/100)
+		}
+		queryTime["pct"] = share(s.TotalTime, global.TotalTime)
+		lockTime["pct"] = share(s.LockTime, global.LockTime)
+		globalTotals := ptTotals(*global)
+		for key, total := range totals {
+			metrics[key].(map[string]string)["pct"] = share(float64(total), float64(globalTotals[key]))
+		}
+	}
+	return metrics
+}
+
+// ptTotals returns the metrics of s that only have totals.
+func ptTotals(s QueryStats) map[string]int64 {
+	return map[string]int64{
+		"Rows_sent":     s.RowsSent,
+		"Rows_examined": s.RowsExamined,
+		"Query_length":  s.StatementBytes,
+	}
+}
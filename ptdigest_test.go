@@ -0,0 +1,90 @@
+package mysqllog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	// echo -n "select ?" | md5sum: 1fe1379fe2a31b8d16219655761820a2
+	if sum := Checksum("select ?"); sum != "16219655761820A2" {
+		t.Errorf("expected 16219655761820A2, got %s", sum)
+	}
+}
+
+func TestDistill(t *testing.T) {
+	if d := Distill("SELECT * FROM orders o JOIN customers c ON c.id = o.customer_id"); d != "SELECT orders customers" {
+		t.Errorf("unexpected distillate %q", d)
+	}
+}
+
+func TestWritePTDigestJSON(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/rds.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAggregator()
+	for _, e := range parseAll(&Parser{}, string(b)) {
+		a.Add(e)
+	}
+	results := a.Results()
+	var buf bytes.Buffer
+	if err := WritePTDigestJSON(&buf, results, WithTopN(5)); err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := ioutil.WriteFile("./_test/ptdigest.json", buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := ioutil.ReadFile("./_test/ptdigest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), golden) {
+		t.Errorf("output differs from _test/ptdigest.json (rerun with -update), got\n%s", buf.String())
+	}
+
+	var doc struct {
+		Classes []struct {
+			Checksum    string `json:"checksum"`
+			Fingerprint string `json:"fingerprint"`
+			Example     struct {
+				Query string `json:"query"`
+			} `json:"example"`
+			QueryCount int64 `json:"query_count"`
+		} `json:"classes"`
+		Global struct {
+			QueryCount       int64 `json:"query_count"`
+			UniqueQueryCount int   `json:"unique_query_count"`
+		} `json:"global"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	for _, s := range results {
+		count += s.Count
+	}
+	if len(doc.Classes) != 5 || doc.Global.QueryCount != count || doc.Global.UniqueQueryCount != len(results) {
+		t.Errorf("expected 5 classes of %d queries in %d, got %d classes and %+v", count, len(results), len(doc.Classes), doc.Global)
+	}
+	for _, c := range doc.Classes {
+		if c.Fingerprint == "" || c.Example.Query == "" || c.Checksum != Checksum(c.Fingerprint) {
+			t.Errorf("expected a fingerprint, example and checksum, got %+v", c)
+		}
+	}
+}
+
+func TestStddevTime(t *testing.T) {
+	s := aggregate([]LogEvent{
+		{"Statement": "SELECT 1", "Query_time": 1.0},
+		{"Statement": "SELECT 2", "Query_time": 3.0},
+	})[0]
+	if math.Abs(s.StddevTime()-1) > 1e-9 {
+		t.Errorf("expected a standard deviation of 1, got %v", s.StddevTime())
+	}
+}
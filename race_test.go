@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package mysqllog
+
+// raceEnabled is set when testing with the race detector, which slows
+// down timed tests.
+const raceEnabled = true
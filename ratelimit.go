@@ -0,0 +1,31 @@
+package mysqllog
+
+// RateLimitAttribute is the Percona attribute with the
+// log_slow_rate_limit an event was logged under: only one in that many
+// sessions or queries is logged.
+const RateLimitAttribute = "Log_slow_rate_limit"
+
+// WithRateLimitScaling counts each event as the number of events its
+// Log_slow_rate_limit stands for, so the counts and total times of
+// QueryStats, rollups and QPS estimate the true volume of a sampled log
+// instead of under-reporting it. Events without the attribute, or with
+// a limit of 1, count once, so logs whose limit changed with SET GLOBAL
+// are scaled event by event. Scaled stats are marked as estimates (see
+// QueryStats.Estimated); their distributions and the Summary, lock and
+// table stats are of the logged events.
+func WithRateLimitScaling() AggregatorOption {
+	return func(a *Aggregator) {
+		a.scaleRateLimit = true
+	}
+}
+
+// weight returns the number of events e counts as.
+func (a *Aggregator) weight(e LogEvent) int64 {
+	if !a.scaleRateLimit {
+		return 1
+	}
+	if n, ok := e.Int64(RateLimitAttribute); ok && n > 1 {
+		return n
+	}
+	return 1
+}
@@ -0,0 +1,78 @@
+package mysqllog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestRateLimitScaling(t *testing.T) {
+	b, err := ioutil.ReadFile("_test/rate_limit.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := parseAll(NewParser(), string(b))
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	if limit, ok := events[0][RateLimitAttribute].(int64); !ok || limit != 10 {
+		t.Errorf("expected a rate limit of 10, got %v", events[0][RateLimitAttribute])
+	}
+
+	type TestCase struct {
+		name             string
+		opts             []AggregatorOption
+		selects, updates int64
+		selectTime       float64
+		selectRows       int64
+		estimated        bool
+	}
+	testCases := []TestCase{
+		{name: "logged", selects: 3, updates: 1, selectTime: 1.5, selectRows: 30},
+		// 10 by the first limit, 1 without a limit and 100 by the second.
+		{name: "scaled", opts: []AggregatorOption{WithRateLimitScaling()}, selects: 111, updates: 1, selectTime: 55.5, selectRows: 1110, estimated: true},
+	}
+	for _, tc := range testCases {
+		a := NewAggregator(tc.opts...)
+		for _, e := range events {
+			a.Add(e)
+		}
+		results := a.Results()
+		if len(results) != 2 {
+			t.Fatalf("%s: expected 2 fingerprints, got %d", tc.name, len(results))
+		}
+		selects, updates := results[0], results[1]
+		if strings.HasPrefix(selects.Fingerprint, "update") {
+			selects, updates = updates, selects
+		}
+		if selects.Count != tc.selects || selects.Logged != 3 || selects.TotalTime != tc.selectTime || selects.RowsExamined != tc.selectRows {
+			t.Errorf("%s: expected %d selects taking %vs and examining %d rows, got %d (%d logged) taking %vs and examining %d",
+				tc.name, tc.selects, tc.selectTime, tc.selectRows, selects.Count, selects.Logged, selects.TotalTime, selects.RowsExamined)
+		}
+		if selects.Estimated() != tc.estimated {
+			t.Errorf("%s: expected Estimated %v", tc.name, tc.estimated)
+		}
+		if updates.Count != tc.updates || updates.Estimated() {
+			t.Errorf("%s: expected %d unscaled updates, got %d", tc.name, tc.updates, updates.Count)
+		}
+		if d := selects.Attribute("Query_time"); d == nil || d.Count != 3 {
+			t.Errorf("%s: expected the distribution of the logged events, got %+v", tc.name, d)
+		}
+		if r := a.Rollup("User"); len(r) != 1 || r[0].Count != tc.selects+tc.updates {
+			t.Errorf("%s: expected the rollup to count %d events, got %+v", tc.name, tc.selects+tc.updates, r)
+		}
+
+		var buf bytes.Buffer
+		if err := WriteReport(&buf, results); err != nil {
+			t.Fatal(err)
+		}
+		report := buf.String()
+		if got := strings.Contains(report, estimatedLine); got != tc.estimated {
+			t.Errorf("%s: expected the estimate line %v, got:\n%s", tc.name, tc.estimated, report)
+		}
+		if tc.estimated && !strings.Contains(report, "# Query 1: 111 calls (estimated from 3 logged),") {
+			t.Errorf("%s: expected the query marked as estimated, got:\n%s", tc.name, report)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package mysqllog
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultProgressInterval is the number of bytes between calls to the
+// WithProgress callback.
+const DefaultProgressInterval = 4 << 20
+
+// ReadOption configures ParseReader, ParseFile and ParseFiles.
+type ReadOption func(*readConfig)
+
+type readConfig struct {
+	parser           *Parser
+	invalid          func(LogEvent, []Problem)
+	progress         func(bytesRead, totalBytes int64, events int)
+	progressInterval int64
+	maxEvents        int
+	// inputID identifies the file read, for EventID.
+	inputID string
+}
+
+// WithParser parses with p instead of a Parser with default settings.
+func WithParser(p *Parser) ReadOption {
+	return func(c *readConfig) {
+		c.parser = p
+	}
+}
+
+// WithProgress calls f about every DefaultProgressInterval bytes, and
+// once more at the end, with the bytes read so far, the total
+// size of the input and the number of events. totalBytes is -1 if the
+// size isn't known, such as for a pipe. f is called from the reading
+// goroutine, never concurrently.
+func WithProgress(f func(bytesRead, totalBytes int64, events int)) ReadOption {
+	return func(c *readConfig) {
+		c.progress = f
+	}
+}
+
+// WithInvalid calls f instead of the event callback with events that
+// aren't complete, as reported by LogEvent.IsComplete, and their
+// problems.
+func WithInvalid(f func(e LogEvent, problems []Problem)) ReadOption {
+	return func(c *readConfig) {
+		c.invalid = f
+	}
+}
+
+// WithProgressInterval calls the WithProgress callback about every n
+// bytes instead.
+func WithProgressInterval(n int64) ReadOption {
+	return func(c *readConfig) {
+		c.progressInterval = n
+	}
+}
+
+// WithMaxEvents stops reading once n events have been parsed, so the
+// start of a large file can be sampled without reading the rest. The
+// event being parsed when the limit is reached is never flushed.
+// ParseFiles counts events over all files.
+func WithMaxEvents(n int) ReadOption {
+	return func(c *readConfig) {
+		c.maxEvents = n
+	}
+}
+
+// ParseReader parses the slow query log read from r and calls fn with
+// each event, flushing the last one at the end of r. Errors reading r
+// are returned as a *ReadError. With a strict Parser, it stops at the
+// first *ParseError and returns it, after calling fn with its event.
+func ParseReader(r io.Reader, fn func(LogEvent), opts ...ReadOption) error {
+	c := &readConfig{progressInterval: DefaultProgressInterval}
+	for _, opt := range opts {
+		opt(c)
+	}
+	p := c.parser
+	if p == nil {
+		p = &Parser{}
+	}
+	if c.inputID != "" {
+		p.inputID = c.inputID
+	}
+	if c.invalid != nil {
+		valid := fn
+		fn = func(e LogEvent) {
+			if problems := e.Validate(); !complete(problems) {
+				c.invalid(e, problems)
+				return
+			}
+			valid(e)
+		}
+	}
+
+	var total, read, next int64
+	events := 0
+	if c.progress != nil {
+		total = inputSize(r)
+		next = c.progressInterval
+	}
+	// done reports whether reading can stop before the end of r.
+	done := func() bool {
+		return c.maxEvents > 0 && events >= c.maxEvents
+	}
+	reader := bufio.NewReader(r)
+	for !done() {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if e := p.ConsumeLine(line); e != nil {
+				events++
+				fn(e)
+			}
+			if errs := p.Errors(); len(errs) > 0 {
+				return errs[0]
+			}
+			read += int64(len(line))
+			if c.progress != nil && read >= next {
+				c.progress(read, total, events)
+				next = read + c.progressInterval
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return &ReadError{Offset: read, Err: err}
+		}
+	}
+	if !done() {
+		if e := p.Flush(); e != nil {
+			events++
+			fn(e)
+		}
+		if errs := p.Errors(); len(errs) > 0 {
+			return errs[0]
+		}
+	}
+	if c.progress != nil {
+		c.progress(read, total, events)
+	}
+	return nil
+}
+
+// ParseFile parses the slow query log at path like ParseReader.
+func ParseFile(path string, fn func(LogEvent), opts ...ReadOption) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	id := path
+	if info, err := f.Stat(); err == nil && FileIdentity(info) != "" {
+		id = FileIdentity(info)
+	}
+	opts = append(opts[:len(opts):len(opts)], func(c *readConfig) { c.inputID = id })
+	return ParseReader(f, fn, opts...)
+}
+
+// ParseFiles parses the slow query logs at paths in order like
+// ParseFile, such as the matches of filepath.Glob, and sets
+// "SourceFile" on each event to the path of its file. Each file's last
+// event is flushed before the next file is read, so events never span
+// files, and WithProgress reports on each file in turn. Errors are
+// returned as an *os.PathError with the path of the file.
+func ParseFiles(paths []string, fn func(LogEvent), opts ...ReadOption) error {
+	c := &readConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	events := 0
+	for i, path := range paths {
+		if c.maxEvents > 0 && events >= c.maxEvents {
+			break
+		}
+		if c.parser != nil {
+			// Line numbers and offsets are per file.
+			c.parser.line, c.parser.offset = 0, 0
+			if c.parser.controlEvents && i > 0 {
+				fn(rotationEvent(path, ""))
+			}
+		}
+		fileOpts := opts
+		if c.maxEvents > 0 {
+			fileOpts = append(opts[:len(opts):len(opts)], WithMaxEvents(c.maxEvents-events))
+		}
+		path := path
+		err := ParseFile(path, func(e LogEvent) {
+			events++
+			e["SourceFile"] = path
+			fn(e)
+		}, fileOpts...)
+		if _, ok := err.(*os.PathError); err != nil && !ok {
+			err = &os.PathError{Op: "parse", Path: path, Err: err}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inputSize returns the number of bytes left in r, or -1 if it isn't
+// known. It knows regular files and readers with a Size method, such as
+// io.SectionReader, bytes.Reader and strings.Reader.
+func inputSize(r io.Reader) int64 {
+	switch r := r.(type) {
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	case interface {
+		Size() int64
+		Len() int
+	}:
+		// bytes.Reader and strings.Reader: Len is what's left unread.
+		return int64(r.Len())
+	case interface{ Size() int64 }:
+		return r.Size()
+	}
+	return -1
+}
+
+// ETA estimates the time left to read totalBytes, at the rate it took
+// elapsed to read bytesRead. ok is false if the total isn't known or
+// nothing has been read yet.
+func ETA(elapsed time.Duration, bytesRead, totalBytes int64) (eta time.Duration, ok bool) {
+	if totalBytes < 0 || bytesRead <= 0 {
+		return 0, false
+	}
+	if bytesRead >= totalBytes {
+		return 0, true
+	}
+	rate := float64(elapsed) / float64(bytesRead)
+	return time.Duration(rate * float64(totalBytes-bytesRead)), true
+}
@@ -0,0 +1,203 @@
+package mysqllog
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReaderProgress(t *testing.T) {
+	data, err := ioutil.ReadFile("./_test/before.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type call struct {
+		read, total int64
+		events      int
+	}
+
+	var calls []call
+	var statements []string
+	err = ParseReader(strings.NewReader(string(data)), func(e LogEvent) {
+		statements = append(statements, e["Statement"].(string))
+	}, WithProgress(func(read, total int64, events int) {
+		calls = append(calls, call{read, total, events})
+	}), WithProgressInterval(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 4 {
+		t.Errorf("expected 4 events, got %q", statements)
+	}
+	if len(calls) < 3 {
+		t.Fatalf("expected several progress calls, got %v", calls)
+	}
+	for i, c := range calls {
+		if c.total != int64(len(data)) {
+			t.Errorf("expected total %d, got %d", len(data), c.total)
+		}
+		if i > 0 && (c.read < calls[i-1].read || c.events < calls[i-1].events) {
+			t.Errorf("expected progress to increase, got %v", calls)
+		}
+	}
+	if last := calls[len(calls)-1]; last.read != int64(len(data)) || last.events != 4 {
+		t.Errorf("expected the last call to cover everything, got %v", last)
+	}
+
+	// The size of a plain stream isn't known.
+	calls = nil
+	ParseReader(io.MultiReader(strings.NewReader(string(data))), func(LogEvent) {}, WithProgress(func(read, total int64, events int) {
+		calls = append(calls, call{read, total, events})
+	}))
+	if len(calls) != 1 || calls[0].total != -1 {
+		t.Errorf("expected a single call with an unknown total, got %v", calls)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	f, err := os.Open("./_test/before.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, _ := f.Stat()
+	if size := inputSize(f); size != info.Size() {
+		t.Errorf("expected the file size %d, got %d", info.Size(), size)
+	}
+
+	events := 0
+	if err := ParseFile("./_test/before.txt", func(LogEvent) { events++ }, WithParser(NewParser(WithVerbExtraction()))); err != nil {
+		t.Fatal(err)
+	}
+	if events != 4 {
+		t.Errorf("expected 4 events, got %d", events)
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func TestWithMaxEvents(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 10000; i++ {
+		input.WriteString(slowEvent(i % 60))
+	}
+	r := &countingReader{r: strings.NewReader(input.String())}
+	var statements []string
+	err := ParseReader(r, func(e LogEvent) { statements = append(statements, e["Statement"].(string)) }, WithMaxEvents(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 3 || statements[2] != "SELECT 2;" {
+		t.Errorf("expected the first 3 events, got %v", statements)
+	}
+	// Only the buffer holding the start of the fourth event is read.
+	if r.n > 8192 {
+		t.Errorf("expected to stop reading early, read %d of %d bytes", r.n, input.Len())
+	}
+
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "slow-1.log")
+	second := filepath.Join(dir, "slow-2.log")
+	appendFile(t, first, slowEvent(1)+slowEvent(2))
+	appendFile(t, second, slowEvent(3)+slowEvent(4))
+	statements = nil
+	err = ParseFiles([]string{first, second, filepath.Join(dir, "missing.log")},
+		func(e LogEvent) { statements = append(statements, e["Statement"].(string)) }, WithMaxEvents(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 3 || statements[2] != "SELECT 3;" {
+		t.Errorf("expected the first 3 events across files, got %v", statements)
+	}
+}
+
+func TestParseFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "slow-1.log")
+	second := filepath.Join(dir, "slow-2.log")
+	appendFile(t, first, slowEvent(1)+slowEvent(2))
+	// A statement without a header at the start of a file doesn't belong
+	// to the last event of the previous one.
+	appendFile(t, second, "SELECT orphan;\n"+slowEvent(3))
+
+	var events []LogEvent
+	err = ParseFiles([]string{first, second}, func(e LogEvent) { events = append(events, e) },
+		WithParser(NewParser(WithOffsets())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type TestCase struct {
+		statement string
+		file      string
+		offset    int64
+	}
+	expected := []TestCase{
+		{"SELECT 1;", first, 0},
+		{"SELECT 2;", first, int64(len(slowEvent(1)))},
+		{"SELECT 3;", second, int64(len("SELECT orphan;\n"))},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %v", len(expected), events)
+	}
+	for i, c := range expected {
+		e := events[i]
+		if e["Statement"] != c.statement || e["SourceFile"] != c.file || e["Offset"] != c.offset {
+			t.Errorf("event %d: expected %+v, got %v", i, c, e)
+		}
+	}
+
+	err = ParseFiles([]string{first, filepath.Join(dir, "missing.log")}, func(LogEvent) {})
+	if pe, ok := err.(*os.PathError); !ok || pe.Path != filepath.Join(dir, "missing.log") {
+		t.Errorf("expected a *os.PathError for the missing file, got %v", err)
+	}
+	appendFile(t, second, "# Time: bad\nSELECT 4;\n")
+	err = ParseFiles([]string{first, second}, func(LogEvent) {}, WithParser(NewParser(WithStrict())))
+	if pe, ok := err.(*os.PathError); !ok || pe.Path != second {
+		t.Errorf("expected the parse error to name %s, got %v", second, err)
+	} else if _, ok := pe.Err.(*ParseError); !ok {
+		t.Errorf("expected a *ParseError, got %v", pe.Err)
+	}
+}
+
+func TestETA(t *testing.T) {
+	type TestCase struct {
+		elapsed     time.Duration
+		read, total int64
+		eta         time.Duration
+		ok          bool
+	}
+	testCases := []TestCase{
+		{time.Minute, 25, 100, 3 * time.Minute, true},
+		{time.Minute, 100, 100, 0, true},
+		{time.Minute, 0, 100, 0, false},
+		{time.Minute, 25, -1, 0, false},
+	}
+	for _, c := range testCases {
+		eta, ok := ETA(c.elapsed, c.read, c.total)
+		if eta != c.eta || ok != c.ok {
+			t.Errorf("ETA(%v, %d, %d): expected %v %v, got %v %v", c.elapsed, c.read, c.total, c.eta, c.ok, eta, ok)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package mysqllog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// Redact returns statement with its string and numeric literals
+// replaced with "?", as Fingerprint finds them, and the literals
+// replaced, in order. Everything else, including comments and spacing,
+// is kept as written.
+func Redact(statement string) (redacted string, literals []string) {
+	return redact(statement, "")
+}
+
+// redact redacts statement as Redact does, writing mark after the first
+// placeholder.
+func redact(statement, mark string) (string, []string) {
+	var b strings.Builder
+	var literals []string
+	placeholder := func(literal string) {
+		b.WriteByte('?')
+		if len(literals) == 0 {
+			b.WriteString(mark)
+		}
+		literals = append(literals, literal)
+	}
+	start := 0
+	for i := 0; i < len(statement); i++ {
+		c := statement[i]
+		end := -1
+		switch {
+		case c == '/' && strings.HasPrefix(statement[i:], "/*"):
+			if j := strings.Index(statement[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(statement)
+			}
+		case c == '#' || strings.HasPrefix(statement[i:], "-- "):
+			if j := strings.IndexByte(statement[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(statement)
+			}
+		case c == '`':
+			if j := strings.IndexByte(statement[i+1:], '`'); j >= 0 {
+				i += j + 1
+			} else {
+				i = len(statement)
+			}
+		case c == '\'' || c == '"':
+			end = skipQuoted(statement, i)
+		case isDigit(c) && !isIdentByte(prevByte(statement, i)):
+			end = i
+			for end+1 < len(statement) && (isIdentByte(statement[end+1]) || statement[end+1] == '.') {
+				end++
+			}
+		}
+		if end >= 0 {
+			b.WriteString(statement[start:i])
+			placeholder(statement[i : end+1])
+			i = end
+			start = end + 1
+		}
+	}
+	if len(literals) == 0 {
+		return statement, nil
+	}
+	b.WriteString(statement[start:])
+	return b.String(), literals
+}
+
+// Encrypter encrypts the literals a RedactionVault keeps, such as with
+// AES-GCM and a key only investigators can get.
+type Encrypter interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+}
+
+// EncrypterFunc is an Encrypter that calls itself.
+type EncrypterFunc func(plaintext []byte) ([]byte, error)
+
+// Encrypt calls f.
+func (f EncrypterFunc) Encrypt(plaintext []byte) ([]byte, error) {
+	return f(plaintext)
+}
+
+// RedactionVault keeps the literals WithRedaction removes, encrypted, so
+// they can be recovered for an event with access to the key.
+//
+// Each redacted event gets a random "RedactionToken", and an event with
+// the same "RedactionToken" and the encrypted literals as a []byte in
+// "Ciphertext" is written to Sink. The plaintext is a JSON array of the
+// literals of "Statement", in order; they go back in place of its "?"
+// placeholders, which are all literals unless the statement had a "?"
+// of its own.
+//
+// Literals are never written or logged in the clear. If encrypting or
+// writing them fails, the event is still redacted, without a token, and
+// Err returns the error.
+type RedactionVault struct {
+	Encrypter Encrypter
+	Sink      Sink
+	// Inline also writes the token as a comment after the first
+	// placeholder of "Statement", as "?/*v:3f9a0c1b2d4e*/", for outputs
+	// that don't keep other attributes.
+	Inline bool
+
+	mu  sync.Mutex
+	err error
+}
+
+// Err returns the first error encrypting or writing literals.
+func (v *RedactionVault) Err() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.err
+}
+
+// keep writes the literals of the event with token to the sink. It
+// reports whether they were written.
+func (v *RedactionVault) keep(token string, literals []string) bool {
+	plaintext, err := json.Marshal(literals)
+	if err == nil {
+		var ciphertext []byte
+		if ciphertext, err = v.Encrypter.Encrypt(plaintext); err == nil {
+			v.mu.Lock()
+			err = v.Sink.Write(LogEvent{"RedactionToken": token, "Ciphertext": ciphertext})
+			v.mu.Unlock()
+		}
+	}
+	if err != nil {
+		v.mu.Lock()
+		if v.err == nil {
+			v.err = err
+		}
+		v.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// WithRedaction replaces the literals of "Statement", "ExecutableText"
+// and "Statements" with "?", as Redact does, after the other attributes
+// derived from the statement, such as "Verb" and "Tables", are set. If
+// vault isn't nil, it keeps the literals. Lines skipped outside of an
+// event aren't logged either.
+func WithRedaction(vault *RedactionVault) Option {
+	return func(p *Parser) {
+		p.redact = true
+		p.vault = vault
+	}
+}
+
+// redactEvent redacts e for WithRedaction.
+func (p *Parser) redactEvent(e LogEvent) {
+	v := p.vault
+	var token, mark string
+	if v != nil {
+		token = redactionToken()
+		if v.Inline {
+			mark = "/*v:" + token + "*/"
+		}
+	}
+	var literals []string
+	if statement, ok := e["Statement"].(string); ok {
+		redacted, found := redact(statement, mark)
+		e["Statement"] = redacted
+		literals = append(literals, found...)
+	}
+	if text, ok := e["ExecutableText"].(string); ok {
+		e["ExecutableText"] = redactExecutable(text)
+	}
+	if statements, ok := e["Statements"].([]Statement); ok {
+		// Their literals are those of the statement.
+		for i := range statements {
+			statements[i].Text, _ = Redact(statements[i].Text)
+		}
+	}
+	if v == nil || len(literals) == 0 {
+		return
+	}
+	if v.keep(token, literals) {
+		e["RedactionToken"] = token
+	} else if statement, ok := e["Statement"].(string); ok && mark != "" {
+		e["Statement"] = strings.Replace(statement, mark, "", 1)
+	}
+}
+
+// redactExecutable redacts an "ExecutableText", keeping its use and SET
+// timestamp lines, whose literals come back in other attributes, so it
+// still parses.
+func redactExecutable(text string) string {
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "use ") || strings.HasPrefix(line, "SET timestamp=") {
+			continue
+		}
+		redacted, _ := Redact(strings.Join(lines[i:], ""))
+		return strings.Join(lines[:i], "") + redacted
+	}
+	return text
+}
+
+// redactionToken returns a new random token for a RedactionVault.
+func redactionToken() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
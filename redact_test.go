@@ -0,0 +1,156 @@
+package mysqllog
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	type TestCase struct {
+		statement string
+		redacted  string
+		literals  []string
+	}
+	for _, c := range []TestCase{
+		{"SELECT * FROM t1 WHERE a = 'x' AND b = 2", "SELECT * FROM t1 WHERE a = ? AND b = ?", []string{"'x'", "2"}},
+		{`SELECT "it\"s", 'o''k', 0x1F, 1.5e3`, "SELECT ?, ?, ?, ?", []string{`"it\"s"`, "'o''k'", "0x1F", "1.5e3"}},
+		{"SELECT `col 1` FROM t /* id 5 */ -- 'x'\nWHERE c = 3", "SELECT `col 1` FROM t /* id 5 */ -- 'x'\nWHERE c = ?", []string{"3"}},
+		{"SELECT NOW()", "SELECT NOW()", nil},
+	} {
+		redacted, literals := Redact(c.statement)
+		if redacted != c.redacted || !reflect.DeepEqual(literals, c.literals) {
+			t.Errorf("%q: expected %q %q, got %q %q", c.statement, c.redacted, c.literals, redacted, literals)
+		}
+		if Fingerprint(redacted) != Fingerprint(c.statement) {
+			t.Errorf("%q: expected the fingerprint of the redacted statement to be %q, got %q", c.statement, Fingerprint(c.statement), Fingerprint(redacted))
+		}
+	}
+}
+
+// testCipher encrypts with AES-GCM, the nonce before the ciphertext.
+type testCipher struct {
+	aead cipher.AEAD
+}
+
+func newTestCipher(t *testing.T) *testCipher {
+	block, err := aes.NewCipher(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCipher{aead}
+}
+
+func (c *testCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (c *testCipher) decrypt(ciphertext []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	return c.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+}
+
+func TestRedactionVault(t *testing.T) {
+	content, err := ioutil.ReadFile("_test/redact.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := []string{"alice@example.com", "4111111111111111", "5550 1234", "90210", "8675309", "brien", "3.14159"}
+
+	c := newTestCipher(t)
+	vaultSink := &recordingSink{}
+	vault := &RedactionVault{Encrypter: c, Sink: vaultSink, Inline: true}
+	logger := &recordingLogger{}
+	p := NewParser(WithRedaction(vault), WithFullStatementText(), WithStatementSplitting(), WithVerbExtraction(), WithLogger(logger))
+
+	var out bytes.Buffer
+	w := NewJSONWriter(&out, Original)
+	events := parseAll(p, string(content))
+	for _, e := range events {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var vaultOut bytes.Buffer
+	for _, e := range vaultSink.events {
+		b, _ := json.Marshal(e)
+		vaultOut.Write(b)
+	}
+	logged := strings.Join(logger.messages, "\n")
+	for _, secret := range secrets {
+		for name, output := range map[string]string{"main": out.String(), "vault": vaultOut.String(), "log": logged} {
+			if strings.Contains(output, secret) {
+				t.Errorf("%s output contains %q", name, secret)
+			}
+		}
+	}
+	if vault.Err() != nil {
+		t.Fatal(vault.Err())
+	}
+	if len(events) != 3 || len(vaultSink.events) != 2 {
+		t.Fatalf("expected 3 events and 2 vault entries, got %d and %d", len(events), len(vaultSink.events))
+	}
+	if _, ok := events[2]["RedactionToken"]; ok {
+		t.Error("expected no token for an event without literals")
+	}
+	if events[0]["Verb"] != "SELECT" || !strings.HasPrefix(events[0]["ExecutableText"].(string), "use crm;\nSET timestamp=1709287200;\nSELECT") {
+		t.Errorf("unexpected event %v", events[0])
+	}
+
+	// Each vault entry gives back the literals of its event.
+	expected := [][]string{
+		{"'alice@example.com'", `"4111111111111111"`},
+		{"'+31 6 5550 1234'", "90210", "8675309", "'o''brien'", "3.14159"},
+	}
+	inline := regexp.MustCompile(`/\*v:([0-9a-f]{12})\*/`)
+	for i, entry := range vaultSink.events {
+		token := events[i]["RedactionToken"]
+		if entry["RedactionToken"] != token {
+			t.Errorf("expected vault entry %d for token %v, got %v", i, token, entry["RedactionToken"])
+		}
+		if m := inline.FindStringSubmatch(events[i]["Statement"].(string)); m == nil || m[1] != token {
+			t.Errorf("expected token %v inline, got %q", token, events[i]["Statement"])
+		}
+		plaintext, err := c.decrypt(entry["Ciphertext"].([]byte))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var literals []string
+		if err := json.Unmarshal(plaintext, &literals); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(literals, expected[i]) {
+			t.Errorf("expected literals %q, got %q", expected[i], literals)
+		}
+	}
+
+	// If the literals can't be kept, events are redacted all the same.
+	failing := &RedactionVault{
+		Encrypter: EncrypterFunc(func([]byte) ([]byte, error) { return nil, errors.New("no key") }),
+		Sink:      vaultSink,
+		Inline:    true,
+	}
+	events = parseAll(NewParser(WithRedaction(failing)), string(content))
+	if failing.Err() == nil || events[0]["Statement"] != "SELECT id FROM customers WHERE email = ? AND card = ?;" {
+		t.Errorf("expected a redacted statement without a token, got %q and %v", events[0]["Statement"], failing.Err())
+	}
+}
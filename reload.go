@@ -0,0 +1,103 @@
+package mysqllog
+
+import "sync"
+
+// TransferTo hands the state of p over to next, a Parser with another
+// configuration, so next continues exactly where p stopped: the pending
+// lines of an unfinished event, where p is in the log, and what p found
+// about the server, its dialect and, if both track them, its
+// connections. The next event emitted, with the options of next, is the
+// one p was in the middle of. p is left without a pending event, so
+// flushing it doesn't emit that event as well.
+//
+// The sampling decision of the pending event is kept. Stats, errors and
+// the state of sampling, rate limits and hooks stay with p.
+func (p *Parser) TransferTo(next *Parser) {
+	p.owner.enter()
+	defer p.owner.leave()
+	next.owner.enter()
+	defer next.owner.leave()
+
+	next.inHeader, next.inQuery = p.inHeader, p.inQuery
+	next.lines = append(next.lines[:0], p.lines...)
+	next.positions = append(next.positions[:0], p.positions...)
+	next.trailer = append(next.trailer[:0], p.trailer...)
+	next.quote = p.quote
+	next.sampled, next.skip = p.sampled, p.skip
+	next.truncated, next.statementBytes = p.truncated, p.statementBytes
+	next.pendingBytes = p.pendingBytes
+
+	next.line, next.offset, next.lineStart = p.line, p.offset, p.lineStart
+	next.eventLine, next.eventOffset = p.eventLine, p.eventOffset
+	next.server, next.eventServer = p.server, p.eventServer
+	if next.controlEvents {
+		next.restart = p.restart
+	}
+	p.restart = nil
+	next.previousTime = p.previousTime
+	next.seqTime, next.seq = p.seqTime, p.seq
+	if p.tracker != nil && next.tracker != nil {
+		next.tracker.restore(p.tracker.states())
+		next.tracker.banner = p.tracker.banner
+	}
+	if !next.dialectSet {
+		next.dialect, next.evidence = p.dialect, p.evidence
+	}
+
+	p.dropPending()
+}
+
+// Reconfigurer changes the parser options of a running TailFile or
+// WatchDir, given with WithReconfigurer.
+type Reconfigurer struct {
+	mu      sync.Mutex
+	opts    []Option
+	pending bool
+}
+
+// Reconfigure parses the file being followed with opts, in place of the
+// options of WithTailParserOptions, from the next line read. The file
+// isn't opened again: its new Parser takes over with TransferTo, so the
+// event being read when the options change is emitted once, with the
+// new options. Files opened later are parsed with opts too.
+func (r *Reconfigurer) Reconfigure(opts ...Option) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.opts = opts
+	r.pending = true
+}
+
+// take returns the options given to Reconfigure since the last call, if
+// any.
+func (r *Reconfigurer) take() ([]Option, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	opts, pending := r.opts, r.pending
+	r.opts, r.pending = nil, false
+	return opts, pending
+}
+
+// WithReconfigurer lets r change the parser options while following.
+func WithReconfigurer(r *Reconfigurer) TailOption {
+	return func(t *tailConfig) {
+		t.reconfigurer = r
+	}
+}
+
+// reconfigure applies the options given to the Reconfigurer of c since
+// it was last checked to r, if it isn't nil, and the files opened later.
+func (c *tailConfig) reconfigure(r *fileReader) {
+	opts, ok := c.reconfigurer.take()
+	if !ok {
+		return
+	}
+	c.parserOpts = opts
+	if r != nil {
+		next := NewParser(opts...)
+		r.parser.TransferTo(next)
+		r.parser = next
+	}
+}
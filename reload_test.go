@@ -0,0 +1,82 @@
+package mysqllog
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransferTo(t *testing.T) {
+	old := NewParser()
+	for _, line := range strings.SplitAfter(slowEvent(0)+incompleteHeader+"SELECT\n", "\n") {
+		if line != "" {
+			old.ConsumeLine(line)
+		}
+	}
+
+	next := NewParser(WithOffsets(), WithVerbExtraction(), WithLabels(map[string]string{"config": "new"}))
+	old.TransferTo(next)
+	if e := old.Flush(); e != nil {
+		t.Errorf("expected no event from the old parser, got %v", e)
+	}
+	events := parseLines(next, "1;\n"+slowEvent(2))
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	e := events[0]
+	if e["Statement"] != "SELECT\n1;" || e["Verb"] != "SELECT" || e["Offset"] != int64(len(slowEvent(0))) {
+		t.Errorf("unexpected event %v", e)
+	}
+	if labels := e["Labels"]; !reflect.DeepEqual(labels, map[string]string{"config": "new"}) {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if _, incomplete := e.Incomplete(); incomplete {
+		t.Errorf("unexpected incomplete event %v", e)
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+	appendFile(t, path, slowEvent(1)+incompleteHeader+"SELECT\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan LogEvent, 10)
+	done := make(chan error, 1)
+	var r Reconfigurer
+	go func() {
+		done <- TailFile(ctx, path, func(e LogEvent) { events <- e }, append(fastPoll, WithReconfigurer(&r))...)
+	}()
+	expect := func(statement string, verb interface{}) {
+		select {
+		case e := <-events:
+			if e["Statement"] != statement || e["Verb"] != verb {
+				t.Errorf("expected %q with verb %v, got %v", statement, verb, e)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %q", statement)
+		}
+	}
+	expect("SELECT 1;", nil)
+	time.Sleep(30 * time.Millisecond)
+	r.Reconfigure(WithVerbExtraction())
+	time.Sleep(30 * time.Millisecond)
+	appendFile(t, path, "2;\n"+slowEvent(3))
+	expect("SELECT\n2;", "SELECT")
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if len(events) > 0 {
+		t.Errorf("unexpected event %v", <-events)
+	}
+}
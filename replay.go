@@ -0,0 +1,510 @@
+package mysqllog
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of a Replayer.
+const (
+	// DefaultReplaySlowdown is how many times slower than the reference
+	// a candidate must be to report a fingerprint as slower.
+	DefaultReplaySlowdown = 1.5
+	// DefaultReplayMaxRows is the number of rows of a result hashed by
+	// WithReplayResults.
+	DefaultReplayMaxRows = 10000
+)
+
+// Replayer is a Sink replaying the SELECTs of the events written to it
+// on a reference and a candidate server, such as the current primary and
+// an upgraded server or a read replica, to find the fingerprints that
+// are slower, fail or, with WithReplayResults, return different results
+// on the candidate. Report tells them.
+//
+// Each statement runs on the reference and then on the candidate, as
+// WithExplain runs EXPLAINs: inside a read-only transaction with the
+// event's Database as the default database, cancelled after the timeout.
+// Statements other than SELECTs, and locking reads, are skipped, as are
+// those that fail on the reference. Errors are in the report, so Write
+// doesn't return them. A Replayer is safe for concurrent use.
+type Replayer struct {
+	reference, candidate *sql.DB
+	timeout              time.Duration
+
+	compare     bool
+	maxRows     int
+	tolerance   float64
+	slowdown    float64
+	minSlowdown time.Duration
+	clock       Clock
+
+	mu     sync.Mutex
+	stats  map[string]*ReplayStats
+	totals ReplayReport
+}
+
+// ReplayOption configures a Replayer.
+type ReplayOption func(*Replayer)
+
+// WithReplayResults compares the result of each SELECT on the candidate
+// with that of the reference, from a checksum of the row count and the
+// values of its first maxRows rows, or DefaultReplayMaxRows if maxRows
+// is zero or less. Floating point and decimal values are rounded to a
+// multiple of tolerance before they're hashed, unless it's zero or less.
+//
+// Rows are compared as a multiset, since servers may return them in any
+// order without an ORDER BY. Results with more than maxRows rows are run
+// again on both servers with an ORDER BY on every column appended, so
+// the same rows are hashed, unless the statement has an ORDER BY, LIMIT
+// or UNION of its own, and are left uncompared then. Results of a LIMIT
+// without an ORDER BY are any rows, so they're not compared either.
+func WithReplayResults(maxRows int, tolerance float64) ReplayOption {
+	return func(r *Replayer) {
+		if maxRows <= 0 {
+			maxRows = DefaultReplayMaxRows
+		}
+		r.compare, r.maxRows, r.tolerance = true, maxRows, tolerance
+	}
+}
+
+// WithReplaySlowdown reports a fingerprint as slower when the candidate
+// took ratio times as long as the reference to run its statements, and
+// at least min longer per statement, in place of DefaultReplaySlowdown
+// with no minimum.
+func WithReplaySlowdown(ratio float64, min time.Duration) ReplayOption {
+	return func(r *Replayer) {
+		r.slowdown, r.minSlowdown = ratio, min
+	}
+}
+
+// WithReplayClock times the statements with c in place of RealClock.
+func WithReplayClock(c Clock) ReplayOption {
+	return func(r *Replayer) {
+		r.clock = c
+	}
+}
+
+// NewReplayer returns a Replayer replaying statements on reference and
+// candidate, cancelling each after timeout unless it's zero. Selecting
+// the database changes the default database of pooled connections, so
+// both should be dedicated to replaying.
+func NewReplayer(reference, candidate *sql.DB, timeout time.Duration, opts ...ReplayOption) *Replayer {
+	r := &Replayer{
+		reference: reference,
+		candidate: candidate,
+		timeout:   timeout,
+		slowdown:  DefaultReplaySlowdown,
+		stats:     map[string]*ReplayStats{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.clock = clockOf(r.clock)
+	return r
+}
+
+// ReplayStats are the replays of the statements of a fingerprint.
+type ReplayStats struct {
+	Fingerprint string
+	// Sample is the first statement replayed, and Database its default
+	// database.
+	Sample   string
+	Database string
+	// Replays is the number of statements run on both servers without
+	// error, which took ReferenceTime and CandidateTime in all.
+	Replays       int64
+	ReferenceTime time.Duration
+	CandidateTime time.Duration
+	// Errors is the number of statements that failed on the candidate
+	// only, Error the first error.
+	Errors int64
+	Error  string
+	// Compared is the number of results compared, Differences those that
+	// differed and Difference how the first one did.
+	Compared    int64
+	Differences int64
+	Difference  string
+}
+
+// Slowdown returns how many times as long the candidate took as the
+// reference, or 0 without replays.
+func (s ReplayStats) Slowdown() float64 {
+	if s.ReferenceTime <= 0 {
+		return 0
+	}
+	return float64(s.CandidateTime) / float64(s.ReferenceTime)
+}
+
+// ReplayReport is the outcome of a replay.
+type ReplayReport struct {
+	// Statements is the number of statements written to the Replayer,
+	// of which Skipped weren't replayed since they aren't SELECTs,
+	// ReferenceErrors failed on the reference and Uncompared results
+	// couldn't be compared.
+	Statements      int64
+	Skipped         int64
+	ReferenceErrors int64
+	Uncompared      int64
+	// Slower are the fingerprints slower on the candidate, slowest
+	// first; Errored those failing on the candidate, and Different those
+	// returning different results, with the most first.
+	Slower    []ReplayStats
+	Errored   []ReplayStats
+	Different []ReplayStats
+}
+
+// Write replays the statement of e.
+func (r *Replayer) Write(e LogEvent) error {
+	statement, _ := e["Statement"].(string)
+	statement = strings.TrimRight(strings.TrimSpace(statement), "; \t\n")
+	if statement == "" {
+		return nil
+	}
+	if !replayable(statement) {
+		r.mu.Lock()
+		r.totals.Statements++
+		r.totals.Skipped++
+		r.mu.Unlock()
+		return nil
+	}
+	database, _ := e["Database"].(string)
+	ref := r.run(r.reference, statement, database)
+	var cand replayResult
+	if ref.err == nil {
+		cand = r.run(r.candidate, statement, database)
+	}
+	var difference string
+	compared := false
+	if r.compare && ref.err == nil && cand.err == nil {
+		difference, compared = r.difference(ref, cand, statement, database)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.totals.Statements++
+	if ref.err != nil {
+		r.totals.ReferenceErrors++
+		return nil
+	}
+	fingerprint := Fingerprint(statement)
+	s := r.stats[fingerprint]
+	if s == nil {
+		s = &ReplayStats{Fingerprint: fingerprint, Sample: statement, Database: database}
+		r.stats[fingerprint] = s
+	}
+	if cand.err != nil {
+		s.Errors++
+		if s.Error == "" {
+			s.Error = cand.err.Error()
+		}
+		return nil
+	}
+	s.Replays++
+	s.ReferenceTime += ref.took
+	s.CandidateTime += cand.took
+	switch {
+	case r.compare && !compared:
+		r.totals.Uncompared++
+	case compared:
+		s.Compared++
+		if difference != "" {
+			s.Differences++
+			if s.Difference == "" {
+				s.Difference = difference
+			}
+		}
+	}
+	return nil
+}
+
+// Close implements Sink.
+func (r *Replayer) Close() error {
+	return nil
+}
+
+// Report returns the outcome of the statements replayed so far.
+func (r *Replayer) Report() ReplayReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := r.totals
+	for _, s := range r.stats {
+		if s.Replays > 0 && s.Slowdown() >= r.slowdown &&
+			(s.CandidateTime-s.ReferenceTime)/time.Duration(s.Replays) >= r.minSlowdown {
+			report.Slower = append(report.Slower, *s)
+		}
+		if s.Errors > 0 {
+			report.Errored = append(report.Errored, *s)
+		}
+		if s.Differences > 0 {
+			report.Different = append(report.Different, *s)
+		}
+	}
+	sortReplayStats(report.Slower, func(s ReplayStats) float64 { return s.Slowdown() })
+	sortReplayStats(report.Errored, func(s ReplayStats) float64 { return float64(s.Errors) })
+	sortReplayStats(report.Different, func(s ReplayStats) float64 { return float64(s.Differences) })
+	return report
+}
+
+// sortReplayStats sorts stats by decreasing key, then by fingerprint.
+func sortReplayStats(stats []ReplayStats, key func(ReplayStats) float64) {
+	sort.Slice(stats, func(i, j int) bool {
+		if ki, kj := key(stats[i]), key(stats[j]); ki != kj {
+			return ki > kj
+		}
+		return stats[i].Fingerprint < stats[j].Fingerprint
+	})
+}
+
+// WriteReplayReport writes report as plain text, with a section for
+// each of the slower, errored and different fingerprints.
+func WriteReplayReport(w io.Writer, report ReplayReport) error {
+	ew := &errWriter{w: w}
+	ew.printf("# Replay: %d statements, %d skipped, %d failed on the reference, %d results not compared\n",
+		report.Statements, report.Skipped, report.ReferenceErrors, report.Uncompared)
+	ew.printf("# %d slower, %d errored, %d with different results\n",
+		len(report.Slower), len(report.Errored), len(report.Different))
+
+	if len(report.Slower) > 0 {
+		ew.printf("\n# Slower\n")
+		for _, s := range report.Slower {
+			ew.printf("# %.2fx, %.6fs on the reference and %.6fs on the candidate in %d replays: %s\n", s.Slowdown(),
+				s.ReferenceTime.Seconds(), s.CandidateTime.Seconds(), s.Replays, Truncate(s.Fingerprint, 60))
+		}
+	}
+	if len(report.Errored) > 0 {
+		ew.printf("\n# Errored\n")
+		for _, s := range report.Errored {
+			ew.printf("# %d of %d statements failed: %s\n", s.Errors, s.Errors+s.Replays, Truncate(s.Fingerprint, 60))
+			ew.printf("#   %s\n", s.Error)
+		}
+	}
+	if len(report.Different) > 0 {
+		ew.printf("\n# Different results\n")
+		for _, s := range report.Different {
+			ew.printf("# %d of %d results differed: %s\n", s.Differences, s.Compared, Truncate(s.Fingerprint, 60))
+			ew.printf("#   %s\n", s.Difference)
+		}
+	}
+	seen := map[string]bool{}
+	for _, section := range [][]ReplayStats{report.Slower, report.Errored, report.Different} {
+		for _, s := range section {
+			if seen[s.Fingerprint] {
+				continue
+			}
+			if len(seen) == 0 {
+				ew.printf("\n# Samples\n")
+			}
+			seen[s.Fingerprint] = true
+			if s.Database != "" {
+				ew.printf("# Database: %s\n", s.Database)
+			}
+			ew.printf("%s;\n", s.Sample)
+		}
+	}
+	return ew.err
+}
+
+// replayable reports whether statement may be replayed: a SELECT that
+// may be explained, without executable comments, and isn't a locking
+// read.
+func replayable(statement string) bool {
+	if !explainable(statement) {
+		return false
+	}
+	text, _ := statementText(statement)
+	return strings.HasPrefix(text, "select") &&
+		!hasKeyword(text, "for update", "for share", "lock in share mode")
+}
+
+// orderedStatement returns statement with an ORDER BY on its columns
+// appended, if it's safe to.
+func orderedStatement(statement string, columns int) (string, bool) {
+	fingerprint := Fingerprint(statement)
+	if columns == 0 || !strings.HasPrefix(fingerprint, "select ") ||
+		hasKeyword(fingerprint, "order by", "limit", "union", "into", "procedure", "with rollup") {
+		return "", false
+	}
+	positions := make([]string, columns)
+	for i := range positions {
+		positions[i] = strconv.Itoa(i + 1)
+	}
+	// On a line of its own, after any comment ending the statement.
+	return statement + "\nORDER BY " + strings.Join(positions, ", "), true
+}
+
+// replayResult is the outcome of running a statement on a server.
+type replayResult struct {
+	took time.Duration
+	err  error
+	// columns is the number of columns of the result, and rows the
+	// number of rows hashed, in any order in unordered and in order in
+	// ordered. truncated is set if there were more.
+	columns   int
+	rows      int64
+	truncated bool
+	unordered uint64
+	ordered   uint64
+}
+
+// run runs statement on db.
+func (r *Replayer) run(db *sql.DB, statement, database string) replayResult {
+	ctx := context.Background()
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	var result replayResult
+	start := r.clock.Now()
+	result.err = queryReadOnly(ctx, db, database, statement, func(rows *sql.Rows) error {
+		return r.hash(rows, &result)
+	})
+	result.took = r.clock.Now().Sub(start)
+	return result
+}
+
+// hash reads rows, hashing them into result for WithReplayResults.
+func (r *Replayer) hash(rows *sql.Rows, result *replayResult) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	result.columns = len(columns)
+	numeric := make([]bool, len(columns))
+	if types, err := rows.ColumnTypes(); err == nil {
+		for i, t := range types {
+			switch t.DatabaseTypeName() {
+			case "FLOAT", "DOUBLE", "REAL", "DECIMAL", "NEWDECIMAL":
+				numeric[i] = true
+			}
+		}
+	}
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	ordered := fnv.New64a()
+	var sum [8]byte
+	for rows.Next() {
+		if !r.compare {
+			continue
+		}
+		if result.rows == int64(r.maxRows) {
+			result.truncated = true
+			break
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		h := r.hashRow(values, numeric)
+		result.unordered += h
+		binary.LittleEndian.PutUint64(sum[:], h)
+		ordered.Write(sum[:])
+		result.rows++
+	}
+	result.ordered = ordered.Sum64()
+	return rows.Err()
+}
+
+// hashRow hashes the values of a row, rounding those of numeric columns
+// to the tolerance.
+func (r *Replayer) hashRow(values []interface{}, numeric []bool) uint64 {
+	h := fnv.New64a()
+	var buf []byte
+	for i, v := range values {
+		var s string
+		switch v := v.(type) {
+		case nil:
+			h.Write([]byte{0})
+			continue
+		case float64:
+			s = r.formatFloat(v)
+		case float32:
+			s = r.formatFloat(float64(v))
+		case []byte:
+			s = string(v)
+		case string:
+			s = v
+		case time.Time:
+			s = v.UTC().Format(time.RFC3339Nano)
+		default:
+			s = fmt.Sprint(v)
+		}
+		if numeric[i] {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				s = r.formatFloat(f)
+			}
+		}
+		// The length tells the values apart.
+		buf = strconv.AppendInt(append(buf[:0], 1), int64(len(s)), 10)
+		h.Write(append(buf, ':'))
+		h.Write([]byte(s))
+	}
+	return h.Sum64()
+}
+
+// formatFloat formats f rounded to the tolerance.
+func (r *Replayer) formatFloat(f float64) string {
+	if r.tolerance > 0 {
+		f = math.Round(f / r.tolerance)
+	}
+	if f == 0 {
+		// Not -0.
+		f = 0
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// difference tells how the results of statement differ, if they do, and
+// whether they could be compared.
+func (r *Replayer) difference(ref, cand replayResult, statement, database string) (string, bool) {
+	if ref.columns != cand.columns {
+		return fmt.Sprintf("%d columns, %d on the candidate", ref.columns, cand.columns), true
+	}
+	if ref.truncated != cand.truncated || ref.rows != cand.rows {
+		return fmt.Sprintf("%s, %s on the candidate", r.rowCount(ref), r.rowCount(cand)), true
+	}
+	if !ref.truncated {
+		fingerprint := Fingerprint(statement)
+		if hasKeyword(fingerprint, "limit") && !hasKeyword(fingerprint, "order by") {
+			return "", false
+		}
+		if ref.unordered != cand.unordered {
+			return fmt.Sprintf("different values in %d rows", ref.rows), true
+		}
+		return "", true
+	}
+	ordered, ok := orderedStatement(statement, ref.columns)
+	if !ok {
+		return "", false
+	}
+	if ref = r.run(r.reference, ordered, database); ref.err != nil {
+		return "", false
+	}
+	if cand = r.run(r.candidate, ordered, database); cand.err != nil {
+		return "", false
+	}
+	if ref.ordered != cand.ordered {
+		return fmt.Sprintf("different values in the first %d rows", r.maxRows), true
+	}
+	return "", true
+}
+
+// rowCount describes the number of rows of a result.
+func (r *Replayer) rowCount(result replayResult) string {
+	if result.truncated {
+		return fmt.Sprintf("more than %d rows", r.maxRows)
+	}
+	return fmt.Sprintf("%d rows", result.rows)
+}
@@ -0,0 +1,221 @@
+package mysqllog
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayable(t *testing.T) {
+	cases := []struct {
+		Statement string
+		Expected  bool
+	}{
+		{"SELECT * FROM t", true},
+		{"select count(*) from t where x = 'for update'", true},
+		{"SELECT * FROM t FOR UPDATE", false},
+		{"SELECT * FROM t FOR SHARE", false},
+		{"SELECT * FROM t LOCK IN SHARE MODE", false},
+		{"UPDATE t SET x = 1", false},
+		{"DELETE FROM t", false},
+		{"SELECT * FROM t INTO OUTFILE '/tmp/x'", false},
+		{"SELECT * FROM t WHERE id = 1 /*!50000 FOR UPDATE */", false},
+		{"SELECT * FROM t /*!50000 INTO OUTFILE '/tmp/x' */", false},
+		{"SELECT * FROM t /*M!100000 LOCK IN SHARE MODE */", false},
+		{"SELECT * FROM t FOR /* x */\n UPDATE", false},
+		{"SELECT * FROM (SELECT * FROM t FOR UPDATE) u", false},
+		{"select 1 from `t\\` /*!50000 union select * from mysql.user into outfile '/tmp/x' */ ` ", false},
+	}
+	for _, c := range cases {
+		if result := replayable(c.Statement); result != c.Expected {
+			t.Errorf("expected replayable(%q) to be %v", c.Statement, c.Expected)
+		}
+	}
+}
+
+func TestOrderedStatement(t *testing.T) {
+	cases := []struct {
+		Statement string
+		Expected  string
+	}{
+		{"SELECT a, b FROM t WHERE c = 'limit'", "SELECT a, b FROM t WHERE c = 'limit'\nORDER BY 1, 2"},
+		{"SELECT a, b FROM t -- all of it", "SELECT a, b FROM t -- all of it\nORDER BY 1, 2"},
+		{"SELECT a, b FROM t ORDER BY a", ""},
+		{"SELECT a, b FROM t LIMIT 10", ""},
+		{"SELECT a, b FROM t UNION SELECT c, d FROM u", ""},
+		{"SELECT a, count(*) FROM t GROUP BY a WITH ROLLUP", ""},
+		{"(SELECT a, b FROM t)", ""},
+	}
+	for _, c := range cases {
+		if result, _ := orderedStatement(c.Statement, 2); result != c.Expected {
+			t.Errorf("orderedStatement(%q): expected %q, got %q", c.Statement, c.Expected, result)
+		}
+	}
+}
+
+// replayServer answers the queries replayed on a fake database, taking
+// took for each on clock.
+type replayServer struct {
+	clock   *ManualClock
+	took    func(query string) time.Duration
+	results map[string][][]driver.Value
+}
+
+func (s replayServer) rows(query string) (*fakeRows, error) {
+	s.clock.Add(s.took(query))
+	for table, values := range s.results {
+		if strings.Contains(query, "FROM "+table) {
+			if strings.HasSuffix(query, "ORDER BY 1, 2") {
+				values = sortedValues(values)
+			}
+			return &fakeRows{columns: []string{"a", "b"}, types: []string{"BIGINT", "DECIMAL"}, values: values}, nil
+		}
+	}
+	return nil, errors.New("Error 1146: Table '" + query + "' doesn't exist")
+}
+
+// sortedValues returns rows of an int and any value sorted by the int.
+func sortedValues(rows [][]driver.Value) [][]driver.Value {
+	sorted := append([][]driver.Value(nil), rows...)
+	for i := range sorted {
+		for j := i + 1; j < len(sorted); j++ {
+			if sorted[j][0].(int64) < sorted[i][0].(int64) {
+				sorted[i], sorted[j] = sorted[j], sorted[i]
+			}
+		}
+	}
+	return sorted
+}
+
+func replayRows(ids ...int64) [][]driver.Value {
+	rows := make([][]driver.Value, len(ids))
+	for i, id := range ids {
+		rows[i] = []driver.Value{id, []byte("x")}
+	}
+	return rows
+}
+
+func TestReplayer(t *testing.T) {
+	clock := NewManualClock(time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC))
+	millisecond := func(string) time.Duration { return time.Millisecond }
+	reference := replayServer{clock: clock, took: millisecond, results: map[string][][]driver.Value{
+		"orders":   replayRows(1),
+		"renamed":  replayRows(1),
+		"invoices": {{int64(1), 10.0}, {int64(2), 20.0}},
+		"items":    {{int64(1), 1.001}, {int64(2), 2.0}},
+		"prices":   {{int64(1), []byte("1.50")}},
+		"big":      replayRows(5, 4, 3, 2, 1),
+		"sorted":   replayRows(1, 2, 3, 4, 5),
+		"drifted":  replayRows(5, 4, 3, 2, 1),
+		"sample":   replayRows(1, 2),
+		"slow":     replayRows(1),
+	}}
+	candidate := replayServer{clock: clock, took: func(query string) time.Duration {
+		if strings.Contains(query, "orders") {
+			return 5 * time.Millisecond
+		}
+		return time.Millisecond
+	}, results: map[string][][]driver.Value{
+		"orders":   replayRows(1),
+		"invoices": {{int64(2), 20.0}, {int64(1), 10.5}},
+		"items":    {{int64(2), 2.0}, {int64(1), 1.0}},
+		"prices":   {{int64(1), []byte("1.5")}},
+		"big":      replayRows(1, 3, 5, 2, 4),
+		"sorted":   replayRows(1, 3, 5, 2, 4),
+		"drifted":  replayRows(1, 3, 5, 6, 7),
+		"sample":   replayRows(3, 4),
+		"slow":     replayRows(1),
+	}}
+	referenceDB, referenceDriver := openNamedFakeDB(t, t.Name()+"/reference")
+	defer referenceDB.Close()
+	referenceDriver.rows = reference.rows
+	candidateDB, candidateDriver := openNamedFakeDB(t, t.Name()+"/candidate")
+	defer candidateDB.Close()
+	candidateDriver.rows = candidate.rows
+	candidateDriver.wait = func(query string) bool { return strings.Contains(query, "slow") }
+
+	r := NewReplayer(referenceDB, candidateDB, 50*time.Millisecond, WithReplayResults(3, 0.01), WithReplayClock(clock))
+	for _, statement := range []string{
+		"SELECT * FROM orders WHERE id = 1",
+		"SELECT * FROM orders WHERE id = 2;",
+		"SELECT * FROM renamed",
+		"SELECT * FROM invoices",
+		"SELECT * FROM items",
+		"SELECT * FROM prices",
+		"SELECT * FROM big",
+		"SELECT * FROM sorted ORDER BY a",
+		"SELECT * FROM drifted WHERE a > 0",
+		"SELECT * FROM sample LIMIT 2",
+		"SELECT * FROM slow",
+		"SELECT * FROM gone",
+		"UPDATE orders SET a = 1",
+	} {
+		r.Write(LogEvent{"Statement": statement, "Database": "shop"})
+	}
+	report := r.Report()
+
+	if report.Statements != 13 || report.Skipped != 1 || report.ReferenceErrors != 1 || report.Uncompared != 2 {
+		t.Errorf("expected 13 statements, 1 skipped, 1 failed on the reference and 2 uncompared, got %+v", report)
+	}
+	fingerprints := func(stats []ReplayStats) []string {
+		var fingerprints []string
+		for _, s := range stats {
+			fingerprints = append(fingerprints, s.Fingerprint)
+		}
+		return fingerprints
+	}
+	if got := fingerprints(report.Slower); len(got) != 1 || got[0] != "select * from orders where id = ?" {
+		t.Errorf("expected orders to be slower, got %q", got)
+	} else if s := report.Slower[0]; s.Replays != 2 || s.Slowdown() != 5 {
+		t.Errorf("expected 2 replays 5 times slower, got %+v", s)
+	}
+	if got := fingerprints(report.Errored); len(got) != 2 || got[0] != "select * from renamed" || got[1] != "select * from slow" {
+		t.Errorf("expected renamed and slow to fail, got %q", got)
+	} else if !strings.Contains(report.Errored[0].Error, "doesn't exist") || report.Errored[1].Error != "context deadline exceeded" {
+		t.Errorf("expected a missing table and a timeout, got %q and %q", report.Errored[0].Error, report.Errored[1].Error)
+	}
+	if got := fingerprints(report.Different); len(got) != 2 || got[0] != "select * from drifted where a > ?" || got[1] != "select * from invoices" {
+		t.Errorf("expected drifted and invoices to differ, got %q", got)
+	} else if report.Different[0].Difference != "different values in the first 3 rows" ||
+		report.Different[1].Difference != "different values in 2 rows" {
+		t.Errorf("unexpected differences %q and %q", report.Different[0].Difference, report.Different[1].Difference)
+	}
+
+	var ordered int
+	for _, query := range append(referenceDriver.queries, candidateDriver.queries...) {
+		if strings.HasSuffix(query, "\nORDER BY 1, 2") {
+			ordered++
+		}
+		if strings.Contains(query, "UPDATE") {
+			t.Errorf("expected writes to be skipped, got %q", query)
+		}
+	}
+	if ordered != 4 {
+		t.Errorf("expected big and drifted to be run again ordered on both servers, got %d", ordered)
+	}
+	for _, readOnly := range candidateDriver.readOnly {
+		if !readOnly {
+			t.Error("expected read-only transactions")
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteReplayReport(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Replay: 13 statements, 1 skipped, 1 failed on the reference, 2 results not compared\n",
+		"# 1 slower, 2 errored, 2 with different results\n",
+		"\n# Slower\n# 5.00x, 0.002000s on the reference and 0.010000s on the candidate in 2 replays: select * from orders where id = ?\n",
+		"\n# Errored\n# 1 of 1 statements failed: select * from renamed\n",
+		"\n# Different results\n# 1 of 1 results differed: select * from drifted where a > ?\n#   different values in the first 3 rows\n",
+		"\n# Samples\n# Database: shop\nSELECT * FROM orders WHERE id = 1;\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected the report to have %q, got:\n%s", want, buf.String())
+		}
+	}
+}
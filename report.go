@@ -0,0 +1,323 @@
+package mysqllog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultTopN is the number of fingerprints detailed in a report
+// unless WithTopN is given.
+const DefaultTopN = 10
+
+// ReportOption configures report output.
+type ReportOption func(*reportOptions)
+
+type reportOptions struct {
+	topN    int
+	explain *explainer
+	locks   *LockSummary
+	summary *Summary
+	tables  []TableStats
+}
+
+func newReportOptions(opts []ReportOption) *reportOptions {
+	o := &reportOptions{topN: DefaultTopN}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithTopN limits the report to the n fingerprints with the most total
+// Query_time. A value of 0 or less includes every fingerprint.
+func WithTopN(n int) ReportOption {
+	return func(o *reportOptions) {
+		o.topN = n
+	}
+}
+
+// WriteReport writes a plain text report of results in the style of
+// pt-query-digest: a profile of the top fingerprints followed by a
+// section with details and a sample statement for each. results are
+// expected in the order returned by Aggregator.Results.
+func WriteReport(w io.Writer, results []QueryStats, opts ...ReportOption) error {
+	o := newReportOptions(opts)
+
+	var totalTime, qps float64
+	var totalCount int64
+	for _, s := range results {
+		totalTime += s.TotalTime
+		totalCount += s.Count
+		qps += s.QPS
+	}
+	top := results
+	if o.topN > 0 && len(top) > o.topN {
+		top = top[:o.topN]
+	}
+
+	ew := &errWriter{w: w}
+	if o.summary != nil {
+		ew.printf("# Summary\n")
+		for _, line := range o.summary.lines() {
+			ew.printf("# %s\n", line)
+		}
+		ew.printf("\n")
+	}
+	ew.printf("# Overall: %d total, %d unique, %.6fs total query time%s\n", totalCount, len(results), totalTime, qpsNote(qps))
+	if anyEstimated(results) {
+		ew.printf("# %s\n", estimatedLine)
+	}
+	ew.printf("\n# Profile\n")
+	ew.printf("# Rank Response time      Calls   R/Call    Query\n")
+	ew.printf("# ==== ================== ======= ========= %s\n", strings.Repeat("=", 40))
+	for i, s := range top {
+		ew.printf("# %4d %11.6fs %5.1f%% %7d %8.6fs %s\n", i+1, s.TotalTime, percent(s.TotalTime, totalTime),
+			s.Count, s.MeanTime(), Truncate(s.Fingerprint, 60))
+	}
+
+	for i, s := range top {
+		ew.printf("\n# Query %d: %d calls%s, %.6fs total, %.1f%% of total time\n", i+1, s.Count, estimatedNote(s), s.TotalTime, percent(s.TotalTime, totalTime))
+		ew.printf("# Fingerprint: %s\n", s.Fingerprint)
+		if !s.FirstSeen.IsZero() {
+			ew.printf("# Time range: %s to %s\n", s.FirstSeen.Format(DefaultTimestampLayout), s.LastSeen.Format(DefaultTimestampLayout))
+		}
+		if s.QPS > 0 {
+			ew.printf("# Rate: %.4f QPS overall, %.4f QPS while seen\n", s.QPS, s.ActiveQPS)
+		}
+		writeAttributeTable(ew, s)
+		ew.printf("# Rows examined per row sent: %.1f\n", s.ExaminedPerSent())
+		if s.FullScans > 0 {
+			ew.printf("# Full scans: %.1f%% of calls\n", s.FullScanPercent())
+		}
+		ew.printf("# Statement size: avg %.0f bytes%s\n", s.MeanStatementBytes(), valuesTuplesNote(s))
+		if db, ok := s.Sample["Database"].(string); ok {
+			ew.printf("# Database: %s\n", db)
+		}
+		statement, _ := s.Sample["Statement"].(string)
+		ew.printf("%s\n", statement)
+		if o.explain != nil {
+			r := o.explain.explain(context.Background(), s.Sample)
+			ew.printf("# %s\n", r.heading())
+			if r.err == nil {
+				ew.printf("%s\n", r.plan)
+			}
+		}
+	}
+
+	if unbounded := unboundedWrites(results); len(unbounded) > 0 {
+		ew.printf("\n# Unbounded writes\n")
+		for _, s := range unbounded {
+			ew.printf("# %d calls, %.6fs total: %s\n", s.UnboundedWrites, s.TotalTime, Truncate(s.Fingerprint, 60))
+		}
+	}
+	if o.locks != nil {
+		writeLockSection(ew, o.locks)
+	}
+	if len(o.tables) > 0 {
+		writeTableSection(ew, o.tables)
+	}
+	return ew.err
+}
+
+// writeAttributeTable writes the distribution of each attribute of s,
+// as pt-query-digest does.
+func writeAttributeTable(ew *errWriter, s QueryStats) {
+	ew.printf("# %-12s %7s %7s %7s %7s %7s %7s %7s\n", "Attribute", "total", "min", "max", "avg", "95%", "stddev", "median")
+	ew.printf("# ============ ======= ======= ======= ======= ======= ======= =======\n")
+	ew.printf("# %-12s %7d\n", "Count", s.Count)
+	for _, row := range attributeRows(s) {
+		ew.printf("# %-12s %7s %7s %7s %7s %7s %7s %7s\n", row.Name, row.Total, row.Min, row.Max, row.Avg, row.P95, row.Stddev, row.Median)
+	}
+}
+
+// qpsNote describes the overall rate of events, if it's known.
+func qpsNote(qps float64) string {
+	if qps <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %.4f QPS", qps)
+}
+
+// estimatedNote marks the counts of s as estimates, if they are.
+func estimatedNote(s QueryStats) string {
+	if !s.Estimated() {
+		return ""
+	}
+	return fmt.Sprintf(" (estimated from %d logged)", s.Logged)
+}
+
+// anyEstimated reports whether any of results has estimated counts.
+func anyEstimated(results []QueryStats) bool {
+	for i := range results {
+		if results[i].Estimated() {
+			return true
+		}
+	}
+	return false
+}
+
+// estimatedLine explains estimated counts in the overall numbers.
+const estimatedLine = "Counts and times are estimates, scaled up by Log_slow_rate_limit from the sampled events."
+
+// valuesTuplesNote describes the largest batch insert of s, if any.
+func valuesTuplesNote(s QueryStats) string {
+	if s.MaxValuesTuples == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", up to %d rows per insert", s.MaxValuesTuples)
+}
+
+// unboundedWrites returns the results with unbounded writes, in order.
+func unboundedWrites(results []QueryStats) []QueryStats {
+	var unbounded []QueryStats
+	for _, s := range results {
+		if s.UnboundedWrites > 0 {
+			unbounded = append(unbounded, s)
+		}
+	}
+	return unbounded
+}
+
+// reportData is the contents of a report, shared by the HTML and
+// Markdown writers.
+type reportData struct {
+	Count     int64
+	Unique    int
+	TotalTime float64
+	// QPS is zero if the events span no time.
+	QPS     float64
+	Queries []reportQuery
+	// Estimated is set if any counts are estimates.
+	Estimated bool
+	// Summary is the lines of the summary given with WithSummary.
+	Summary []string
+	// UnboundedWrites covers every result, not only the top ones.
+	UnboundedWrites []QueryStats
+	// Tables are the tables given with WithTables.
+	Tables []TableStats
+}
+
+type reportQuery struct {
+	Rank      int
+	Stats     QueryStats
+	Percent   float64
+	Database  string
+	Statement string
+	FirstSeen string
+	LastSeen  string
+	Histogram []reportBar
+	// EstimatedNote is the estimatedNote of Stats.
+	EstimatedNote string
+	// Attributes is the attribute table, formatted.
+	Attributes []reportAttribute
+	Explain    string
+	// ExplainNote tells that Explain is a plan from the cache.
+	ExplainNote string
+}
+
+type reportBar struct {
+	Label string
+	Count int64
+	// Width is the count relative to the largest bucket, from 0 to 100.
+	Width int
+}
+
+func buildReport(results []QueryStats, o *reportOptions) reportData {
+	data := reportData{Unique: len(results), UnboundedWrites: unboundedWrites(results), Tables: o.tables, Estimated: anyEstimated(results)}
+	if o.summary != nil {
+		data.Summary = o.summary.lines()
+	}
+	for _, s := range results {
+		data.TotalTime += s.TotalTime
+		data.Count += s.Count
+		data.QPS += s.QPS
+	}
+	top := results
+	if o.topN > 0 && len(top) > o.topN {
+		top = top[:o.topN]
+	}
+	for i, s := range top {
+		q := reportQuery{
+			Rank:          i + 1,
+			Stats:         s,
+			EstimatedNote: estimatedNote(s),
+			Percent:       percent(s.TotalTime, data.TotalTime),
+		}
+		q.Attributes = attributeRows(s)
+		q.Database, _ = s.Sample["Database"].(string)
+		q.Statement, _ = s.Sample["Statement"].(string)
+		if !s.FirstSeen.IsZero() {
+			q.FirstSeen = s.FirstSeen.Format(DefaultTimestampLayout)
+			q.LastSeen = s.LastSeen.Format(DefaultTimestampLayout)
+		}
+		var max int64
+		for _, n := range s.Histogram {
+			if n > max {
+				max = n
+			}
+		}
+		for bucket, n := range s.Histogram {
+			bar := reportBar{Label: HistogramLabels[bucket], Count: n}
+			if max > 0 {
+				bar.Width = int(100 * n / max)
+			}
+			q.Histogram = append(q.Histogram, bar)
+		}
+		if o.explain != nil {
+			r := o.explain.explain(context.Background(), s.Sample)
+			switch {
+			case r.err != nil:
+				q.Explain = r.heading()
+			case r.cached:
+				q.Explain, q.ExplainNote = r.plan, r.heading()
+			default:
+				q.Explain = r.plan
+			}
+		}
+		data.Queries = append(data.Queries, q)
+	}
+	return data
+}
+
+func percent(part, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * part / total
+}
+
+// Truncate shortens s to at most n runes, marking truncation with "...".
+// An n of zero or less keeps s whole.
+func Truncate(s string, n int) string {
+	if n <= 0 {
+		return s
+	}
+	if len(s) > utf8.UTFMax*n {
+		// The first n runes are in there, and the rest isn't converted.
+		s = s[:utf8.UTFMax*n+1]
+	}
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n <= 3 {
+		return string(runes[:n])
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+// errWriter remembers the first write error so callers can check once.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
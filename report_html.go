@@ -0,0 +1,142 @@
+package mysqllog
+
+import (
+	"html/template"
+	"io"
+)
+
+// WriteHTMLReport writes the report of WriteReport as a single HTML page
+// with inline styles, so it can be shared as one file. The profile table
+// can be sorted by clicking its headers, and each query has a bar chart
+// of its Query_time histogram.
+func WriteHTMLReport(w io.Writer, results []QueryStats, opts ...ReportOption) error {
+	return htmlReportTemplate.Execute(w, buildReport(results, newReportOptions(opts)))
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"inc":           func(i int) int { return i + 1 },
+	"estimatedLine": func() string { return estimatedLine },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Slow query report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { padding: 0.2em 0.6em; text-align: right; border-bottom: 1px solid #ddd; }
+th { cursor: pointer; background: #f4f4f4; }
+td.query, th.query { text-align: left; font-family: monospace; }
+pre { background: #f8f8f8; padding: 0.8em; overflow-x: auto; white-space: pre-wrap; }
+.histogram td { border: none; padding: 0.1em 0.4em; }
+.bar { background: #4a7ebb; height: 0.8em; }
+</style>
+</head>
+<body>
+<h1>Slow query report</h1>
+{{- if .Summary}}
+<h2>Summary</h2>
+<ul>
+{{- range .Summary}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+<p>{{.Count}} total, {{.Unique}} unique, {{printf "%.6f" .TotalTime}}s total query time
+{{- if .QPS}}, {{printf "%.4f" .QPS}} QPS{{end}}</p>
+{{- if .Estimated}}
+<p>{{estimatedLine}}</p>
+{{- end}}
+<h2>Profile</h2>
+<table id="profile">
+<thead><tr><th>Rank</th><th>Response time</th><th>%</th><th>Calls</th><th>R/Call</th><th class="query">Query</th></tr></thead>
+<tbody>
+{{- range .Queries}}
+<tr><td>{{.Rank}}</td><td>{{printf "%.6f" .Stats.TotalTime}}</td><td>{{printf "%.1f" .Percent}}</td><td>{{.Stats.Count}}</td><td>{{printf "%.6f" .Stats.MeanTime}}</td><td class="query"><a href="#query-{{.Rank}}">{{.Stats.Fingerprint}}</a></td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- range .Queries}}
+<h2 id="query-{{.Rank}}">Query {{.Rank}}</h2>
+<p>{{.Stats.Count}} calls{{.EstimatedNote}}, {{printf "%.6f" .Stats.TotalTime}}s total, {{printf "%.1f" .Percent}}% of total time</p>
+<ul>
+<li>Fingerprint: <code>{{.Stats.Fingerprint}}</code></li>
+{{- if .FirstSeen}}
+<li>Time range: {{.FirstSeen}} to {{.LastSeen}}</li>
+{{- end}}
+{{- if .Stats.QPS}}
+<li>Rate: {{printf "%.4f" .Stats.QPS}} QPS overall, {{printf "%.4f" .Stats.ActiveQPS}} QPS while seen</li>
+{{- end}}
+<li>Rows examined per row sent: {{printf "%.1f" .Stats.ExaminedPerSent}}</li>
+{{- if .Stats.FullScans}}
+<li>Full scans: {{printf "%.1f" .Stats.FullScanPercent}}% of calls</li>
+{{- end}}
+<li>Statement size: avg {{printf "%.0f" .Stats.MeanStatementBytes}} bytes
+{{- if .Stats.MaxValuesTuples}}, up to {{.Stats.MaxValuesTuples}} rows per insert{{end}}</li>
+{{- if .Database}}
+<li>Database: {{.Database}}</li>
+{{- end}}
+</ul>
+<table class="attributes">
+<thead><tr><th>Attribute</th><th>Total</th><th>Min</th><th>Max</th><th>Avg</th><th>95%</th><th>Stddev</th><th>Median</th></tr></thead>
+<tbody>
+{{- range .Attributes}}
+<tr><td>{{.Name}}</td><td>{{.Total}}</td><td>{{.Min}}</td><td>{{.Max}}</td><td>{{.Avg}}</td><td>{{.P95}}</td><td>{{.Stddev}}</td><td>{{.Median}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+<table class="histogram">
+{{- range .Histogram}}
+<tr><td>{{.Label}}</td><td><div class="bar" style="width: {{.Width}}px"></div></td><td>{{.Count}}</td></tr>
+{{- end}}
+</table>
+<pre>{{.Statement}}</pre>
+{{- if .ExplainNote}}
+<p>{{.ExplainNote}}</p>
+{{- end}}
+{{- if .Explain}}
+<pre>{{.Explain}}</pre>
+{{- end}}
+{{- end}}
+{{- if .UnboundedWrites}}
+<h2>Unbounded writes</h2>
+<table>
+<thead><tr><th>Calls</th><th>Response time</th><th class="query">Query</th></tr></thead>
+<tbody>
+{{- range .UnboundedWrites}}
+<tr><td>{{.UnboundedWrites}}</td><td>{{printf "%.6f" .TotalTime}}</td><td class="query">{{.Fingerprint}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+{{- if .Tables}}
+<h2>Tables</h2>
+<table>
+<thead><tr><th>Rank</th><th class="query">Table</th><th>Response time</th><th>%</th><th>Calls</th><th>Reads</th><th>Writes</th><th>Rows examined</th><th class="query">Top queries</th></tr></thead>
+<tbody>
+{{- range $i, $t := .Tables}}
+<tr><td>{{inc $i}}</td><td class="query">{{$t.Table}}</td><td>{{printf "%.6f" $t.TotalTime}}</td><td>{{printf "%.1f" $t.Percent}}</td><td>{{$t.Count}}</td><td>{{$t.Reads}}</td><td>{{$t.Writes}}</td><td>{{$t.RowsExamined}}</td><td class="query">
+{{- range $j, $f := $t.Top}}{{if $j}}<br>{{end}}{{$f.Fingerprint}}{{end}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+<script>
+document.querySelectorAll("#profile th").forEach(function (th, column) {
+  th.addEventListener("click", function () {
+    var body = th.closest("table").tBodies[0];
+    var rows = Array.prototype.slice.call(body.rows);
+    var asc = th.dataset.order !== "asc";
+    th.dataset.order = asc ? "asc" : "desc";
+    rows.sort(function (a, b) {
+      var x = a.cells[column].textContent, y = b.cells[column].textContent;
+      var c = isNaN(x) || isNaN(y) ? x.localeCompare(y) : x - y;
+      return asc ? c : -c;
+    });
+    rows.forEach(function (row) { body.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,58 @@
+package mysqllog
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestWriteHTMLReport(t *testing.T) {
+	results := aggregate([]LogEvent{
+		{"Statement": "SELECT * FROM t WHERE name = '<script>'", "Query_time": 0.002, "Database": "app"},
+		{"Statement": "SELECT * FROM t WHERE name = 'a & b'", "Query_time": 1.5, "Database": "app"},
+		{"Statement": "UPDATE u SET x = 1", "Query_time": 0.5, "Full_scan": true},
+	})
+
+	var buf bytes.Buffer
+	if err := WriteHTMLReport(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := ioutil.WriteFile("./_test/report.html", buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := ioutil.ReadFile("./_test/report.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), golden) {
+		t.Errorf("report differs from _test/report.html (rerun with -update), got\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "'<script>'") {
+		t.Error("expected statements to be escaped")
+	}
+}
+
+func TestWriteHTMLReportSize(t *testing.T) {
+	var events []LogEvent
+	for i := 0; i < 10000; i++ {
+		events = append(events, LogEvent{
+			"Statement":  fmt.Sprintf("SELECT * FROM t%d WHERE id = %d", i%500, i),
+			"Query_time": float64(i%100) / 10,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHTMLReport(&buf, aggregate(events), WithTopN(100)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 300<<10 {
+		t.Errorf("expected a report under 300KB, got %d bytes", buf.Len())
+	}
+}
@@ -0,0 +1,120 @@
+package mysqllog
+
+import (
+	"io"
+	"strings"
+)
+
+// WriteMarkdownReport writes the report of WriteReport as GitHub
+// flavored Markdown, for pasting into tickets and pull requests.
+func WriteMarkdownReport(w io.Writer, results []QueryStats, opts ...ReportOption) error {
+	o := newReportOptions(opts)
+	data := buildReport(results, o)
+
+	ew := &errWriter{w: w}
+	ew.printf("## Slow query report\n\n")
+	if len(data.Summary) > 0 {
+		ew.printf("### Summary\n\n")
+		for _, line := range data.Summary {
+			ew.printf("- %s\n", line)
+		}
+		ew.printf("\n")
+	}
+	ew.printf("| Events | Unique | Total time |\n")
+	ew.printf("| -----: | -----: | ---------: |\n")
+	ew.printf("| %d | %d | %.6fs |\n", data.Count, data.Unique, data.TotalTime)
+	if data.QPS > 0 {
+		ew.printf("\n%.4f QPS\n", data.QPS)
+	}
+	if data.Estimated {
+		ew.printf("\n%s\n", estimatedLine)
+	}
+	ew.printf("\n### Profile\n\n")
+	ew.printf("| Rank | Response time | %% | Calls | R/Call | Query |\n")
+	ew.printf("| ---: | ------------: | --: | ----: | -----: | :---- |\n")
+	for _, q := range data.Queries {
+		ew.printf("| %d | %.6fs | %.1f | %d | %.6fs | [%s](#query-%d) |\n", q.Rank, q.Stats.TotalTime, q.Percent,
+			q.Stats.Count, q.Stats.MeanTime(), markdownEscape(Truncate(q.Stats.Fingerprint, 60)), q.Rank)
+	}
+
+	for _, q := range data.Queries {
+		s := q.Stats
+		ew.printf("\n### Query %d\n\n", q.Rank)
+		ew.printf("%d calls%s, %.6fs total, %.1f%% of total time\n\n", s.Count, q.EstimatedNote, s.TotalTime, q.Percent)
+		ew.printf("- Fingerprint: %s\n", markdownEscape(s.Fingerprint))
+		if q.FirstSeen != "" {
+			ew.printf("- Time range: %s to %s\n", q.FirstSeen, q.LastSeen)
+		}
+		if s.QPS > 0 {
+			ew.printf("- Rate: %.4f QPS overall, %.4f QPS while seen\n", s.QPS, s.ActiveQPS)
+		}
+		ew.printf("- Rows examined per row sent: %.1f\n", s.ExaminedPerSent())
+		if s.FullScans > 0 {
+			ew.printf("- Full scans: %.1f%% of calls\n", s.FullScanPercent())
+		}
+		ew.printf("- Statement size: avg %.0f bytes%s\n", s.MeanStatementBytes(), valuesTuplesNote(s))
+		if q.Database != "" {
+			ew.printf("- Database: %s\n", markdownEscape(q.Database))
+		}
+		ew.printf("\n| Attribute | Total | Min | Max | Avg | 95%% | Stddev | Median |\n")
+		ew.printf("| :-------- | ----: | --: | --: | --: | --: | -----: | -----: |\n")
+		for _, row := range q.Attributes {
+			ew.printf("| %s | %s | %s | %s | %s | %s | %s | %s |\n", row.Name, row.Total, row.Min, row.Max, row.Avg, row.P95, row.Stddev, row.Median)
+		}
+		fence := codeFence(q.Statement)
+		ew.printf("\n%ssql\n%s\n%s\n", fence, q.Statement, fence)
+		if q.ExplainNote != "" {
+			ew.printf("\n%s\n", markdownEscape(q.ExplainNote))
+		}
+		if q.Explain != "" {
+			fence = codeFence(q.Explain)
+			ew.printf("\n%s\n%s\n%s\n", fence, q.Explain, fence)
+		}
+	}
+
+	if len(data.UnboundedWrites) > 0 {
+		ew.printf("\n### Unbounded writes\n\n")
+		ew.printf("| Calls | Total time | Query |\n")
+		ew.printf("| ----: | ---------: | :---- |\n")
+		for _, s := range data.UnboundedWrites {
+			ew.printf("| %d | %.6fs | %s |\n", s.UnboundedWrites, s.TotalTime, markdownEscape(Truncate(s.Fingerprint, 60)))
+		}
+	}
+	if o.locks != nil {
+		writeMarkdownLockSection(ew, o.locks)
+	}
+	if len(data.Tables) > 0 {
+		writeMarkdownTableSection(ew, data.Tables)
+	}
+	return ew.err
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "|", `\|`, "*", `\*`, "_", `\_`,
+	"[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`,
+)
+
+// markdownEscape escapes s for use as inline text, including in tables.
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// codeFence returns a backtick fence longer than any run of backticks
+// in s, so s can't close the code block early.
+func codeFence(s string) string {
+	longest, run := 0, 0
+	for _, c := range s {
+		if c == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
@@ -0,0 +1,32 @@
+package mysqllog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMarkdownReport(t *testing.T) {
+	results := aggregate([]LogEvent{
+		{"Statement": "SELECT a | b FROM `t` WHERE id = 1", "Query_time": 2.0, "Database": "app"},
+		{"Statement": "SELECT '```' FROM u", "Query_time": 0.5},
+	})
+
+	var buf bytes.Buffer
+	if err := WriteMarkdownReport(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	report := buf.String()
+	for _, expected := range []string{
+		"| 2 | 2 | 2.500000s |\n",
+		"| 1 | 2.000000s | 80.0 | 1 | 2.000000s | [select a \\| b from \\`t\\` where id = ?](#query-1) |\n",
+		"### Query 1\n",
+		"- Database: app\n",
+		"```sql\nSELECT a | b FROM `t` WHERE id = 1\n```\n",
+		"````sql\nSELECT '```' FROM u\n````\n",
+	} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("expected report to contain %q, got\n%s", expected, report)
+		}
+	}
+}
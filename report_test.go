@@ -0,0 +1,76 @@
+package mysqllog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func aggregate(events []LogEvent) []QueryStats {
+	a := NewAggregator()
+	for _, e := range events {
+		a.Add(e)
+	}
+	return a.Results()
+}
+
+func TestWriteReport(t *testing.T) {
+	results := aggregate([]LogEvent{
+		{"Statement": "SELECT * FROM t WHERE id = 1", "Query_time": 1.0, "Database": "app"},
+		{"Statement": "SELECT * FROM t WHERE id = 2", "Query_time": 3.0, "Database": "app"},
+		{"Statement": "UPDATE u SET x = 1 WHERE id = 2", "Query_time": 0.5},
+	})
+
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, results, WithTopN(1)); err != nil {
+		t.Fatal(err)
+	}
+	report := buf.String()
+	for _, expected := range []string{
+		"# Overall: 3 total, 2 unique, 4.500000s total query time",
+		"#    1    4.000000s  88.9%       2 2.000000s select * from t where id = ?",
+		"# Query 1: 2 calls, 4.000000s total, 88.9% of total time",
+		"# Database: app",
+		"SELECT * FROM t WHERE id = 2\n",
+	} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("expected report to contain %q, got\n%s", expected, report)
+		}
+	}
+	if strings.Contains(report, "update u") {
+		t.Errorf("expected only the top fingerprint, got\n%s", report)
+	}
+}
+
+func TestWriteReportAttributes(t *testing.T) {
+	var events []LogEvent
+	for i := 1; i <= 20; i++ {
+		events = append(events, LogEvent{
+			"Statement":     "SELECT * FROM orders WHERE id = 1",
+			"Query_time":    float64(i) * 0.05,
+			"Lock_time":     float64(i) * 0.00001,
+			"Rows_sent":     int64(i),
+			"Rows_examined": int64(i * 5000),
+			"Bytes_sent":    int64(i * 1024),
+		})
+	}
+	events = append(events, LogEvent{"Statement": "SELECT 1", "Query_time": 0.0002})
+
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, aggregate(events)); err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := ioutil.WriteFile("./_test/report.txt", buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := ioutil.ReadFile("./_test/report.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), golden) {
+		t.Errorf("report differs from _test/report.txt (rerun with -update), got\n%s", buf.String())
+	}
+}
@@ -0,0 +1,134 @@
+package mysqllog
+
+import (
+	"bytes"
+	"io"
+)
+
+// ReverseReader reads the events of a slow query log newest first, such
+// as to page through the recent events of a large file. It reads the
+// file backward in blocks like TailEvents, keeping a block and the lines
+// of the events in it not returned yet, and parses the events one at a
+// time as they're asked for.
+//
+// Events are parsed as by TailEvents, each after the banner of the
+// server it was logged by, if one precedes it since the event before.
+// With WithOffsets, offsets are those in the file.
+type ReverseReader struct {
+	ra   io.ReaderAt
+	opts []Option
+
+	// data is the log from pos up to the events already parsed, and
+	// starts the offsets of the event starts in it.
+	pos    int64
+	data   []byte
+	starts []int
+	// events are those parsed and not returned yet, as in the log.
+	events []LogEvent
+	err    error
+}
+
+// NewReverseReader returns a ReverseReader reading the slow query log of
+// size bytes in ra, with a Parser configured with opts.
+func NewReverseReader(ra io.ReaderAt, size int64, opts ...Option) *ReverseReader {
+	if size < 0 {
+		size = 0
+	}
+	return &ReverseReader{ra: ra, opts: opts, pos: size}
+}
+
+// Next returns the event logged before the one it last returned,
+// starting with the last event of the log. It returns io.EOF after the
+// first one, and a *ReadError if reading fails.
+func (r *ReverseReader) Next() (LogEvent, error) {
+	for {
+		if n := len(r.events); n > 0 {
+			e := r.events[n-1]
+			r.events[n-1] = nil
+			r.events = r.events[:n-1]
+			return e, nil
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		n := len(r.starts)
+		switch {
+		case n > 1:
+			// The first start isn't parsed before the block before it is
+			// read, for a banner there.
+			r.parse(r.starts[n-1], r.starts[n-2])
+		case n == 1 && r.pos == 0:
+			r.parse(r.starts[0], 0)
+		case r.pos == 0 && len(r.data) > 0:
+			// The lines before the first event start.
+			r.parse(0, 0)
+		case r.pos == 0:
+			r.err = io.EOF
+		default:
+			r.read()
+		}
+	}
+}
+
+// read reads the block before data.
+func (r *ReverseReader) read() {
+	start := r.pos - tailEventsBlock
+	if start < 0 {
+		start = 0
+	}
+	block := make([]byte, r.pos-start, int64(len(r.data))+r.pos-start)
+	if _, err := r.ra.ReadAt(block, start); err != nil && err != io.EOF {
+		r.err = &ReadError{Offset: start, Err: err}
+		return
+	}
+	r.data = append(block, r.data...)
+	r.pos = start
+	r.starts = eventStarts(r.data, r.pos == 0)
+}
+
+// parse parses the events of data from offset from, after the lines
+// from prev, and drops them from data.
+func (r *ReverseReader) parse(from, prev int) {
+	p := NewParser(r.opts...)
+	if i := lastBanner(r.data[prev:from]); i >= 0 {
+		// The banner and the lines after it aren't in an event.
+		for _, line := range bytes.SplitAfter(r.data[prev+i:from], []byte("\n")) {
+			if len(line) > 0 {
+				p.ConsumeLine(string(line))
+			}
+		}
+	}
+	p.offset = r.pos + int64(from)
+	var events []LogEvent
+	err := ParseReader(bytes.NewReader(r.data[from:]), func(e LogEvent) {
+		events = append(events, e)
+	}, WithParser(p))
+	if err != nil {
+		r.err = err
+	}
+	r.events = events
+	r.data = r.data[:from]
+	if n := len(r.starts); n > 0 && r.starts[n-1] == from {
+		r.starts = r.starts[:n-1]
+	}
+}
+
+// lastBanner returns the offset in data of its last banner line, or -1.
+func lastBanner(data []byte) int {
+	for end := len(data); end > 0; {
+		i := bytes.LastIndex(data[:end], []byte("started with:"))
+		if i < 0 {
+			return -1
+		}
+		start := bytes.LastIndexByte(data[:i], '\n') + 1
+		lineEnd := len(data)
+		if j := bytes.IndexByte(data[i:], '\n'); j >= 0 {
+			lineEnd = i + j + 1
+		}
+		if isBanner(string(data[start:lineEnd])) {
+			return start
+		}
+		end = i
+	}
+	return -1
+}
@@ -0,0 +1,25 @@
+//go:build go1.23
+// +build go1.23
+
+package mysqllog
+
+import (
+	"io"
+	"iter"
+)
+
+// ReverseEvents returns the events of the slow query log of size bytes
+// in ra newest first, read lazily by a ReverseReader with a Parser
+// configured with opts. The iteration ends early if reading fails; use
+// a ReverseReader for the error.
+func ReverseEvents(ra io.ReaderAt, size int64, opts ...Option) iter.Seq[LogEvent] {
+	return func(yield func(LogEvent) bool) {
+		r := NewReverseReader(ra, size, opts...)
+		for {
+			e, err := r.Next()
+			if err != nil || !yield(e) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build go1.23
+// +build go1.23
+
+package mysqllog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReverseEvents(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 5; i++ {
+		input.WriteString(slowEvent(i))
+	}
+	var statements []string
+	for e := range ReverseEvents(strings.NewReader(input.String()), int64(input.Len())) {
+		statements = append(statements, e["Statement"].(string))
+		if len(statements) == 3 {
+			break
+		}
+	}
+	if strings.Join(statements, " ") != "SELECT 4; SELECT 3; SELECT 2;" {
+		t.Errorf("unexpected events %q", statements)
+	}
+}
@@ -0,0 +1,115 @@
+package mysqllog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readReverse reads all events of input with a ReverseReader.
+func readReverse(t *testing.T, input string, opts ...Option) []LogEvent {
+	var events []LogEvent
+	r := NewReverseReader(strings.NewReader(input), int64(len(input)), opts...)
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+}
+
+func TestReverseReaderFixtures(t *testing.T) {
+	paths, err := filepath.Glob("./_test/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inputs []string
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, string(data))
+	}
+	var generated strings.Builder
+	for i := 0; i < 100; i++ {
+		generated.WriteString(slowEvent(i % 60))
+		if i%30 == 29 {
+			generated.WriteString("/usr/sbin/mariadbd, Version: 10.6.12-MariaDB-log (MariaDB Server). started with:\n" +
+				"Tcp port: 0  Unix socket: /run/mysqld/mysqld.sock\nTime                 Id Command    Argument\n")
+		}
+		if i%7 == 0 {
+			generated.WriteString("# Tmp_tables: 1  Tmp_disk_tables: 0\n")
+		}
+	}
+	paths = append(paths, "generated")
+	inputs = append(inputs, generated.String())
+
+	defer func(block int64) { tailEventsBlock = block }(tailEventsBlock)
+	for i, input := range inputs {
+		expected, err := parseEvents([]byte(input), []Option{WithOffsets(), WithTrailer()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, block := range []int64{7, 100, 4096, 64 << 10} {
+			tailEventsBlock = block
+			events := readReverse(t, input, WithOffsets(), WithTrailer())
+			if len(events) != len(expected) {
+				t.Errorf("%s, block %d: expected %d events, got %d", paths[i], block, len(expected), len(events))
+				continue
+			}
+			for j := range events {
+				want, got := expected[j].Canonical(), events[len(events)-1-j].Canonical()
+				if want != got {
+					t.Errorf("%s, block %d: event %d differs:\n%s", paths[i], block, j, lineDiff(want, got))
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestReverseReaderReadsTheEnd(t *testing.T) {
+	var input bytes.Buffer
+	for i := 0; i < 10000; i++ {
+		input.WriteString(slowEvent(i % 60))
+	}
+	r := &countingReaderAt{r: bytes.NewReader(input.Bytes())}
+	reader := NewReverseReader(r, int64(input.Len()))
+	for _, want := range []string{"SELECT 39;", "SELECT 38;", "SELECT 37;"} {
+		e, err := reader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e["Statement"] != want {
+			t.Errorf("expected %q, got %v", want, e)
+		}
+	}
+	if r.n > tailEventsBlock {
+		t.Errorf("expected to read one block, read %d bytes", r.n)
+	}
+
+	if _, err := NewReverseReader(r, 0).Next(); err != io.EOF {
+		t.Errorf("expected io.EOF from an empty file, got %v", err)
+	}
+	failing := NewReverseReader(errReaderAt{}, 100)
+	if _, err := failing.Next(); err == nil {
+		t.Error("expected an error")
+	} else if _, ok := err.(*ReadError); !ok {
+		t.Errorf("expected a ReadError, got %v", err)
+	}
+}
+
+type errReaderAt struct{}
+
+func (errReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return 0, errors.New("broken")
+}
@@ -0,0 +1,142 @@
+package mysqllog
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// NoneValue is the rollup value for events missing the attribute.
+const NoneValue = "(none)"
+
+// Rollup summarizes the events sharing a value of an attribute.
+type Rollup struct {
+	Value        string  `json:"value"`
+	Count        int64   `json:"count"`
+	TotalTime    float64 `json:"total_time"`
+	RowsExamined int64   `json:"rows_examined"`
+	// Percent is the share of the overall Query_time, from 0 to 100.
+	Percent float64 `json:"percent"`
+}
+
+// WithRollups sets the attributes events are rolled up by, replacing
+// the default of Database, User and Host. Attributes holding a []string
+// contribute the event to each of their values. "Verb" and "Tables" are
+// computed from the statement for events parsed without
+// WithVerbExtraction or WithTableExtraction, "Routine" from CALL
+// statements as with WithRoutineExtraction, and "Connection" is the
+// ConnectionID, for per-connection totals.
+func WithRollups(keys ...string) AggregatorOption {
+	return func(a *Aggregator) {
+		a.dimensions = append([]string(nil), keys...)
+	}
+}
+
+func (a *Aggregator) addRollups(e LogEvent, weight int64) {
+	queryTime, _ := e.Float64("Query_time")
+	rowsExamined, _ := e.Int64("Rows_examined")
+	for _, key := range a.dimensions {
+		for _, value := range rollupValues(rollupAttribute(e, key)) {
+			r := a.rollups[key][value]
+			if r == nil {
+				r = &Rollup{Value: value}
+				a.rollups[key][value] = r
+			}
+			r.Count += weight
+			r.TotalTime += queryTime * float64(weight)
+			r.RowsExamined += rowsExamined * weight
+		}
+	}
+}
+
+// rollupAttribute returns the value of key in e, computing the derived
+// attributes if they're missing.
+func rollupAttribute(e LogEvent, key string) interface{} {
+	if v, ok := e[key]; ok {
+		return v
+	}
+	statement, _ := e["Statement"].(string)
+	switch key {
+	case "Verb":
+		return Verb(statement)
+	case "Tables":
+		return Tables(statement)
+	case "Routine":
+		return Routine(statement)
+	case "Connection":
+		if id, ok := ConnectionID(e); ok {
+			return id
+		}
+	}
+	return nil
+}
+
+// rollupValues returns the rollup values for an attribute value.
+func rollupValues(v interface{}) []string {
+	switch v := v.(type) {
+	case nil:
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []string:
+		if len(v) > 0 {
+			return v
+		}
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+	return []string{NoneValue}
+}
+
+// Dimensions returns the attributes events are rolled up by.
+func (a *Aggregator) Dimensions() []string {
+	return append([]string(nil), a.dimensions...)
+}
+
+// Rollup returns the rollups for the attribute key sorted by total
+// Query_time in descending order, or nil if key isn't a dimension.
+func (a *Aggregator) Rollup(key string) []Rollup {
+	values, ok := a.rollups[key]
+	if !ok {
+		return nil
+	}
+	rollups := make([]Rollup, 0, len(values))
+	for _, r := range values {
+		rollup := *r
+		rollup.Percent = percent(r.TotalTime, a.totalTime)
+		rollups = append(rollups, rollup)
+	}
+	sort.Slice(rollups, func(i, j int) bool {
+		if rollups[i].TotalTime != rollups[j].TotalTime {
+			return rollups[i].TotalTime > rollups[j].TotalTime
+		}
+		return rollups[i].Value < rollups[j].Value
+	})
+	return rollups
+}
+
+// WriteRollups writes rollups for the attribute key as a compact table.
+func WriteRollups(w io.Writer, key string, rollups []Rollup) error {
+	ew := &errWriter{w: w}
+	ew.printf("# By %s\n", key)
+	ew.printf("# %-30s %14s %6s %8s %14s\n", key, "Response time", "%", "Calls", "Rows examined")
+	for _, r := range rollups {
+		ew.printf("# %-30s %13.6fs %5.1f%% %8d %14d\n", Truncate(r.Value, 30), r.TotalTime, r.Percent, r.Count, r.RowsExamined)
+	}
+	return ew.err
+}
+
+// WriteCombinedReport writes the rollups of every dimension of a followed
+// by the per-fingerprint report.
+func WriteCombinedReport(w io.Writer, a *Aggregator, opts ...ReportOption) error {
+	for _, key := range a.dimensions {
+		if err := WriteRollups(w, key, a.Rollup(key)); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return WriteReport(w, a.Results(), opts...)
+}
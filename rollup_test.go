@@ -0,0 +1,84 @@
+package mysqllog
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func rollupFixture(opts ...AggregatorOption) *Aggregator {
+	a := NewAggregator(opts...)
+	for _, e := range []LogEvent{
+		{"Statement": "SELECT 1", "Query_time": 3.0, "Database": "shop", "User": "app", "Rows_examined": int64(10)},
+		{"Statement": "SELECT 2", "Query_time": 1.0, "Database": "shop", "User": "report", "Rows_examined": int64(5)},
+		{"Statement": "SELECT 3", "Query_time": 4.0, "User": "app"},
+		{"Statement": "SELECT 4", "Query_time": 2.0, "Database": "", "User": "app", "Tables": []string{"a", "b"}},
+	} {
+		a.Add(e)
+	}
+	return a
+}
+
+func TestAggregatorRollup(t *testing.T) {
+	a := rollupFixture()
+
+	expected := []Rollup{
+		{Value: NoneValue, Count: 2, TotalTime: 6, Percent: 60},
+		{Value: "shop", Count: 2, TotalTime: 4, RowsExamined: 15, Percent: 40},
+	}
+	if result := a.Rollup("Database"); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+
+	expected = []Rollup{
+		{Value: "app", Count: 3, TotalTime: 9, RowsExamined: 10, Percent: 90},
+		{Value: "report", Count: 1, TotalTime: 1, RowsExamined: 5, Percent: 10},
+	}
+	if result := a.Rollup("User"); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+	if result := a.Rollup("Host"); len(result) != 1 || result[0].Value != NoneValue || result[0].Count != 4 {
+		t.Errorf("expected every event in the (none) host bucket, got %+v", result)
+	}
+	if result := a.Rollup("Verb"); result != nil {
+		t.Errorf("expected nil for an unknown dimension, got %+v", result)
+	}
+
+	b, err := json.Marshal(a.Rollup("User")[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `[{"value":"app","count":3,"total_time":9,"rows_examined":10,"percent":90}]` {
+		t.Errorf("unexpected JSON %s", b)
+	}
+}
+
+func TestAggregatorRollupMultiValued(t *testing.T) {
+	a := rollupFixture(WithRollups("Tables"))
+	result := a.Rollup("Tables")
+	if len(result) != 3 || result[0].Value != NoneValue || result[1].Value != "a" || result[2].Value != "b" {
+		t.Errorf("unexpected rollups %+v", result)
+	}
+	if a.Rollup("Database") != nil {
+		t.Error("expected WithRollups to replace the default dimensions")
+	}
+}
+
+func TestWriteCombinedReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCombinedReport(&buf, rollupFixture()); err != nil {
+		t.Fatal(err)
+	}
+	report := buf.String()
+	database := strings.Index(report, "# By Database\n")
+	user := strings.Index(report, "# By User\n")
+	profile := strings.Index(report, "# Profile\n")
+	if database < 0 || user < database || profile < user {
+		t.Errorf("expected rollups above the profile, got\n%s", report)
+	}
+	if !strings.Contains(report, "# app                                 9.000000s  90.0%        3             10\n") {
+		t.Errorf("unexpected rollup row in\n%s", report)
+	}
+}
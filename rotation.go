@@ -0,0 +1,105 @@
+package mysqllog
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// RotationDetection is how TailFile and WatchDir tell that the file at
+// the path they follow was replaced by a new one, as by rename rotation.
+// Truncation, as by copytruncate rotation, is told by the size dropping
+// below what was read with all of them.
+type RotationDetection int
+
+const (
+	// RotationAuto is RotationIdentity where files have identities, as
+	// on Unix, and RotationCreationTime elsewhere, as on Windows.
+	RotationAuto RotationDetection = iota
+	// RotationIdentity tells a new file by its identity, the device and
+	// inode on Unix (see FileIdentity). Checkpoints are only resumed for
+	// the same identity.
+	RotationIdentity
+	// RotationCreationTime tells a new file by a later creation time, or
+	// where creation times aren't known, as RotationContent does. On
+	// Windows, where mysqld holds the log open so it can't be renamed and
+	// rotation is copytruncate only, this catches a log deleted and
+	// created again.
+	RotationCreationTime
+	// RotationContent tells a new file by its first bytes differing from
+	// those of the file being read, for mounts where identities aren't
+	// stable, as on some container bind mounts. A new file is only told
+	// once it's written past where the two differ, and checkpoints are
+	// resumed by fingerprint alone.
+	RotationContent
+)
+
+// WithRotationDetection tells rotation by d instead of RotationAuto.
+func WithRotationDetection(d RotationDetection) TailOption {
+	return func(t *tailConfig) {
+		t.rotation = d
+	}
+}
+
+func (d RotationDetection) resolve() RotationDetection {
+	if d == RotationAuto {
+		return defaultRotation
+	}
+	return d
+}
+
+// identity returns the identity of the file behind info that checkpoints
+// record, or "" if d doesn't use identities.
+func (d RotationDetection) identity(info os.FileInfo) string {
+	if d.resolve() != RotationIdentity {
+		return ""
+	}
+	return FileIdentity(info)
+}
+
+// replaced reports whether current, the file now at path, is a different
+// file than f, opened from path, whose info is opened.
+func (d RotationDetection) replaced(path string, f *os.File, opened, current os.FileInfo) (bool, error) {
+	switch d.resolve() {
+	case RotationIdentity:
+		return !os.SameFile(opened, current), nil
+	case RotationCreationTime:
+		if created, ok := creationTime(opened); ok {
+			if now, ok := creationTime(current); ok {
+				return !now.Equal(created), nil
+			}
+		}
+	}
+	return contentDiffers(path, f, opened, current)
+}
+
+// contentDiffers reports whether the first bytes of f differ from those
+// of the file at path, up to fingerprintSize and the size of either.
+func contentDiffers(path string, f *os.File, opened, current os.FileInfo) (bool, error) {
+	n := int64(fingerprintSize)
+	if opened.Size() < n {
+		n = opened.Size()
+	}
+	if current.Size() < n {
+		n = current.Size()
+	}
+	if n == 0 {
+		return false, nil
+	}
+	other, err := openShared(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer other.Close()
+	a, b := make([]byte, n), make([]byte, n)
+	if _, err := f.ReadAt(a, 0); err != nil && err != io.EOF {
+		return false, err
+	}
+	if _, err := io.ReadFull(other, b); err != nil && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return !bytes.Equal(a, b), nil
+}
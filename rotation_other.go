@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package mysqllog
+
+import (
+	"os"
+	"time"
+)
+
+// creationTime returns the time the file behind info was created, which
+// isn't known here.
+func creationTime(info os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// openShared opens path for reading. Others can rename and delete it
+// while it's open.
+func openShared(path string) (*os.File, error) {
+	return os.Open(path)
+}
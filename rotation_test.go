@@ -0,0 +1,62 @@
+package mysqllog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// remountedInfo is the info of a file seen through another mount, where
+// its identity differs.
+type remountedInfo struct {
+	os.FileInfo
+}
+
+func TestRotationReplaced(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+	appendFile(t, path, slowEvent(1))
+	f, err := openShared(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	opened, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestCase struct {
+		detection RotationDetection
+		current   os.FileInfo
+		expected  bool
+	}
+	same := mustStat(t, path)
+	for _, c := range []TestCase{
+		{RotationIdentity, same, false},
+		{RotationContent, same, false},
+		{RotationCreationTime, same, false},
+		{RotationIdentity, remountedInfo{same}, true},
+		{RotationContent, remountedInfo{same}, false},
+	} {
+		if replaced, err := c.detection.replaced(path, f, opened, c.current); err != nil || replaced != c.expected {
+			t.Errorf("%d on %T: expected %v, got %v, %v", c.detection, c.current, c.expected, replaced, err)
+		}
+	}
+
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, slowEvent(2))
+	current := mustStat(t, path)
+	for _, d := range []RotationDetection{RotationAuto, RotationIdentity, RotationContent, RotationCreationTime} {
+		if replaced, err := d.replaced(path, f, opened, current); err != nil || !replaced {
+			t.Errorf("%d: expected a new file, got %v, %v", d, replaced, err)
+		}
+	}
+}
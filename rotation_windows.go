@@ -0,0 +1,36 @@
+//go:build windows
+// +build windows
+
+package mysqllog
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// creationTime returns the time the file behind info was created.
+func creationTime(info os.FileInfo) (time.Time, bool) {
+	d, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, d.CreationTime.Nanoseconds()), true
+}
+
+// openShared opens path for reading as os.Open does, but lets others
+// rename and delete it while it's open, so following the log doesn't
+// get in the way of rotating it.
+func openShared(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
@@ -0,0 +1,57 @@
+//go:build windows
+// +build windows
+
+package mysqllog
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotationWindows(t *testing.T) {
+	if defaultRotation != RotationCreationTime {
+		t.Errorf("expected RotationCreationTime by default, got %d", defaultRotation)
+	}
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+	appendFile(t, path, slowEvent(1)+slowEvent(2))
+
+	run := startFollow(func(ctx context.Context, fn func(LogEvent)) error {
+		return TailFile(ctx, path, fn, fastPoll...)
+	})
+	run.expect(t, "SELECT 1;")
+
+	// copytruncate, the only rotation while mysqld holds the log open.
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	appendFile(t, path, slowEvent(3)+slowEvent(4))
+	run.expect(t, "SELECT 2;", "SELECT 3;")
+
+	// Following the log doesn't keep it from being renamed, and a log
+	// created again later is told apart by its creation time.
+	created, ok := creationTime(mustStat(t, path))
+	if !ok {
+		t.Fatal("expected a creation time")
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	appendFile(t, path, slowEvent(5)+slowEvent(6))
+	if now, _ := creationTime(mustStat(t, path)); !now.After(created) {
+		t.Errorf("expected a later creation time than %v, got %v", created, now)
+	}
+	run.expect(t, "SELECT 4;", "SELECT 5;")
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+}
@@ -0,0 +1,55 @@
+package mysqllog
+
+import "strings"
+
+// storedRoutinePrefix starts the header line Percona Server logs for
+// statements run inside a stored routine.
+const storedRoutinePrefix = "# Stored routine: "
+
+// WithRoutineExtraction sets "Routine" on CALL statements to the name
+// of the procedure, as returned by Routine, so they can be rolled up by
+// routine. Events with a "# Stored routine:" line keep that routine.
+func WithRoutineExtraction() Option {
+	return func(p *Parser) {
+		p.routines = true
+	}
+}
+
+// Routine returns the name of the procedure called by a CALL statement,
+// with its schema if given and without backticks, such as
+// "app.nightly_rollup", or "" for other statements.
+func Routine(statement string) string {
+	tokens := tokenize(statement)
+	if len(tokens) < 2 || !tokens[0].is("call") {
+		return ""
+	}
+	name := func(t token) (string, bool) {
+		return t.text, t.kind == tokenWord || t.kind == tokenIdent
+	}
+	routine, ok := name(tokens[1])
+	if !ok {
+		return ""
+	}
+	if len(tokens) >= 4 && tokens[2].isPunct('.') {
+		if proc, ok := name(tokens[3]); ok {
+			routine += "." + proc
+		}
+	}
+	return routine
+}
+
+// setRoutine sets the "Routine" of e from its CALL statement, unless it
+// has one already.
+func setRoutine(e LogEvent, statement string) {
+	if _, ok := e["Routine"]; ok {
+		return
+	}
+	if routine := Routine(statement); routine != "" {
+		e["Routine"] = routine
+	}
+}
+
+// parseStoredRoutine returns the routine of a "# Stored routine:" line.
+func parseStoredRoutine(line string) string {
+	return strings.TrimSpace(line[len(storedRoutinePrefix):])
+}
@@ -0,0 +1,63 @@
+package mysqllog
+
+import (
+	"testing"
+)
+
+func TestRoutine(t *testing.T) {
+	type TestCase struct {
+		statement string
+		expected  string
+	}
+	for _, c := range []TestCase{
+		{"CALL nightly_rollup(1)", "nightly_rollup"},
+		{"call `app`.`nightly_rollup`(DATE(NOW()));", "app.nightly_rollup"},
+		{"/* cron */ CALL reports.refresh_cache", "reports.refresh_cache"},
+		{"CALL", ""},
+		{"SELECT nightly_rollup(1)", ""},
+		{"EXECUTE stmt1", ""},
+	} {
+		if got := Routine(c.statement); got != c.expected {
+			t.Errorf("Routine(%q): expected %q, got %q", c.statement, c.expected, got)
+		}
+	}
+}
+
+func TestParseStoredRoutines(t *testing.T) {
+	p := NewParser(WithStrict(), WithVerbExtraction(), WithRoutineExtraction())
+	var events []LogEvent
+	if err := ParseFile("./_test/percona_routines.txt", func(e LogEvent) { events = append(events, e) }, WithParser(p)); err != nil {
+		t.Fatal(err)
+	}
+	type TestCase struct {
+		verb    string
+		routine interface{}
+	}
+	expected := []TestCase{
+		{VerbInsert, "app.nightly_rollup"},
+		{VerbDelete, "app.nightly_rollup"},
+		{VerbCall, "app.nightly_rollup"},
+		{VerbReplace, "reports.refresh_cache"},
+		{VerbCall, "reports.refresh_cache"},
+		{VerbExecute, nil},
+		{VerbPrepare, nil},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for i, c := range expected {
+		if events[i]["Verb"] != c.verb || events[i]["Routine"] != c.routine {
+			t.Errorf("event %d: expected %s in %v, got %v in %v", i, c.verb, c.routine, events[i]["Verb"], events[i]["Routine"])
+		}
+	}
+
+	a := NewAggregator(WithRollups("Routine"))
+	err := ParseFile("./_test/percona_routines.txt", a.Add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rollups := a.Rollup("Routine")
+	if len(rollups) != 3 || rollups[0].Value != "app.nightly_rollup" || rollups[0].Count != 3 || rollups[1].Value != NoneValue {
+		t.Errorf("unexpected rollups %+v", rollups)
+	}
+}
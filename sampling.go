@@ -0,0 +1,109 @@
+package mysqllog
+
+import (
+	"math/rand"
+	"time"
+)
+
+// sampling holds the emission control state of a Parser.
+type sampling struct {
+	hasRate bool
+	rate    float64
+	rnd     *rand.Rand
+
+	perFingerprint int
+	minute         time.Time
+	seen           map[string]int
+
+	perSecond float64
+	tokens    float64
+	last      time.Time
+}
+
+// WithSampleRate keeps each event with probability rate.
+// The decision is made when an event starts, so skipped events are
+// never parsed. A rate of 1 or more keeps everything; 0 or less drops
+// everything. Skipped events are counted in Stats.SampledOut.
+func WithSampleRate(rate float64) Option {
+	return func(p *Parser) {
+		p.sampling.hasRate = true
+		p.sampling.rate = rate
+		p.sampling.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+}
+
+// WithFingerprintLimit keeps only the first n events per fingerprint in
+// each wall-clock minute. Dropped events are counted in
+// Stats.FingerprintLimited.
+func WithFingerprintLimit(n int) Option {
+	return func(p *Parser) {
+		p.sampling.perFingerprint = n
+	}
+}
+
+// WithRateLimit caps the number of events emitted per second using a
+// token bucket with a burst of perSecond events (at least one).
+// Dropped events are counted in Stats.RateLimited.
+func WithRateLimit(perSecond float64) Option {
+	return func(p *Parser) {
+		p.sampling.perSecond = perSecond
+	}
+}
+
+// clock returns the current time.
+func (p *Parser) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// sampleIn reports whether the event that is starting should be kept.
+func (p *Parser) sampleIn() bool {
+	s := &p.sampling
+	if !s.hasRate || s.rate >= 1 {
+		return true
+	}
+	return s.rnd.Float64() < s.rate
+}
+
+// admit applies the fingerprint and rate limits to a completed event.
+func (p *Parser) admit(event LogEvent) bool {
+	s := &p.sampling
+	if s.perFingerprint > 0 {
+		minute := p.clock().Truncate(time.Minute)
+		if s.seen == nil || !minute.Equal(s.minute) {
+			s.minute = minute
+			s.seen = map[string]int{}
+		}
+		statement, _ := event["Statement"].(string)
+		fingerprint := Fingerprint(statement)
+		if s.seen[fingerprint] >= s.perFingerprint {
+			p.stats.FingerprintLimited++
+			return false
+		}
+		s.seen[fingerprint]++
+	}
+	if s.perSecond > 0 {
+		burst := s.perSecond
+		if burst < 1 {
+			burst = 1
+		}
+		now := p.clock()
+		if s.last.IsZero() {
+			s.tokens = burst
+		} else {
+			s.tokens += now.Sub(s.last).Seconds() * s.perSecond
+			if s.tokens > burst {
+				s.tokens = burst
+			}
+		}
+		s.last = now
+		if s.tokens < 1 {
+			p.stats.RateLimited++
+			return false
+		}
+		s.tokens--
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package mysqllog
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// generateEvents returns n events whose statements cycle through
+// queries different fingerprints.
+func generateEvents(n, queries int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "# User@Host: app[app] @ localhost [127.0.0.1]  Id:     %d\n", i)
+		fmt.Fprintf(&b, "# Query_time: 0.%06d  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: %d\n", i%1000000, i)
+		fmt.Fprintf(&b, "SET timestamp=%d;\n", 1514083320+i)
+		fmt.Fprintf(&b, "SELECT * FROM t%d WHERE id = %d;\n", i%queries, i)
+	}
+	return b.String()
+}
+
+func TestWithSampleRate(t *testing.T) {
+	const n = 10000
+	p := NewParser(WithSampleRate(0.1))
+	events := parseAll(p, generateEvents(n, 1))
+	if len(events) < 800 || len(events) > 1200 {
+		t.Errorf("expected about %d events, got %d", n/10, len(events))
+	}
+	stats := p.Stats()
+	if stats.Events+stats.SampledOut != n {
+		t.Errorf("expected %d events in total, got %d emitted and %d sampled out", n, stats.Events, stats.SampledOut)
+	}
+	for _, e := range events {
+		if !strings.HasPrefix(e["Statement"].(string), "SELECT * FROM t0") {
+			t.Fatalf("unexpected statement %q", e["Statement"])
+		}
+	}
+
+	if events := parseAll(NewParser(WithSampleRate(1)), generateEvents(100, 1)); len(events) != 100 {
+		t.Errorf("expected all 100 events, got %d", len(events))
+	}
+	if events := parseAll(NewParser(WithSampleRate(0)), generateEvents(100, 1)); len(events) != 0 {
+		t.Errorf("expected no events, got %d", len(events))
+	}
+}
+
+func TestWithFingerprintLimit(t *testing.T) {
+	clock := NewManualClock(time.Date(2017, 12, 24, 2, 42, 0, 0, time.UTC))
+	p := NewParser(WithFingerprintLimit(3), WithClock(clock))
+
+	events := parseAll(p, generateEvents(100, 4))
+	if len(events) != 12 {
+		t.Errorf("expected 12 events, got %d", len(events))
+	}
+	if p.Stats().FingerprintLimited != 88 {
+		t.Errorf("expected 88 limited events, got %d", p.Stats().FingerprintLimited)
+	}
+
+	// The limit resets every minute.
+	clock.Add(time.Minute)
+	events = parseAll(p, generateEvents(10, 1))
+	if len(events) != 3 {
+		t.Errorf("expected 3 events, got %d", len(events))
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	clock := NewManualClock(time.Date(2017, 12, 24, 2, 42, 0, 0, time.UTC))
+	p := NewParser(WithRateLimit(5), WithClock(clock))
+
+	events := parseAll(p, generateEvents(20, 20))
+	if len(events) != 5 {
+		t.Errorf("expected 5 events, got %d", len(events))
+	}
+
+	clock.Add(time.Second)
+	events = parseAll(p, generateEvents(20, 20))
+	if len(events) != 5 {
+		t.Errorf("expected 5 events after a second, got %d", len(events))
+	}
+	if p.Stats().RateLimited != 30 {
+		t.Errorf("expected 30 rate limited events, got %d", p.Stats().RateLimited)
+	}
+}
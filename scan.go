@@ -0,0 +1,116 @@
+package mysqllog
+
+import (
+	"bytes"
+	"strings"
+)
+
+// ScanEvents is a bufio.SplitFunc that splits a slow query log into raw
+// events. Each token is the text of one event from its first header line
+// through its statement, without trailing blank lines. Event boundaries
+// are the same as the Parser's: lines before the first header are
+// skipped, server startup banners and blank lines end the statement,
+// comment lines after the statement are left out of the token (see
+// WithTrailer), a trailing header without a statement is dropped, and
+// error log lines are skipped, though those within an event stay in its
+// token for ParseEvent to skip.
+func ScanEvents(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	start := -1
+	end := -1
+	inQuery := false
+	banner := false
+	// trailer is the start of the comment lines after the statement.
+	trailer := -1
+	var quote byte
+	i := 0
+	for i < len(data) {
+		next := len(data)
+		if j := bytes.IndexByte(data[i:], '\n'); j >= 0 {
+			next = i + j + 1
+		} else if !atEOF {
+			break
+		}
+
+		line := data[i:next]
+		if !(inQuery && quote != 0) && isErrorLogLine(string(line)) {
+			// Skipped by the Parser, and by ParseEvent if it's within
+			// the token.
+			i = next
+			continue
+		}
+		if line[0] == '#' && !(start >= 0 && bytes.HasPrefix(line, []byte(adminCommandPrefix))) {
+			if inQuery && quote == 0 && !startsHeader(string(line)) {
+				if trailer < 0 {
+					trailer = i
+				}
+			} else if inQuery {
+				return i, bytes.TrimRight(data[start:end], " \t\r\n"), nil
+			}
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 && len(bytes.TrimSpace(line)) == 0 && quote == 0 {
+			if inQuery {
+				return next, bytes.TrimRight(data[start:end], " \t\r\n"), nil
+			}
+		} else if start >= 0 && trailer >= 0 {
+			// The comment lines were the header of the next event.
+			return trailer, bytes.TrimRight(data[start:end], " \t\r\n"), nil
+		} else if start >= 0 {
+			if !inQuery {
+				inQuery = true
+				end = i
+			}
+			quote = quoteState(quote, string(line))
+			if bytes.HasSuffix(line, []byte("started with:\n")) {
+				banner = true
+			}
+			if !banner {
+				end = next
+			}
+		}
+		i = next
+	}
+
+	if atEOF && inQuery {
+		return len(data), bytes.TrimRight(data[start:end], " \t\r\n"), nil
+	}
+	if start < 0 {
+		// Nothing but lines outside of an event so far.
+		if atEOF {
+			return len(data), nil, nil
+		}
+		return i, nil, nil
+	}
+	if atEOF {
+		return len(data), nil, nil
+	}
+	// Request more data for the partial event.
+	return start, nil, nil
+}
+
+// ParseEvent parses the raw text of a single event, such as a token
+// produced by ScanEvents, using the default Parser settings. Error log
+// lines in it are skipped.
+func ParseEvent(raw []byte) LogEvent {
+	lines := strings.SplitAfter(string(raw), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	// Error log lines within the event are skipped, as by the Parser.
+	kept := lines[:0]
+	inQuery := false
+	var quote byte
+	for _, line := range lines {
+		if !(inQuery && quote != 0) && isErrorLogLine(line) {
+			continue
+		}
+		if inQuery || line[0] != '#' {
+			inQuery = true
+			quote = quoteState(quote, line)
+		}
+		kept = append(kept, line)
+	}
+	p := &Parser{}
+	return p.emit(p.parseEntry(kept))
+}
@@ -0,0 +1,80 @@
+package mysqllog
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestScanEventsMatchesParser(t *testing.T) {
+	for _, file := range []string{"rds.txt", "blank_separators.txt", "interleaved.txt"} {
+		b, err := ioutil.ReadFile("./_test/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testScanEventsMatchesParser(t, b)
+	}
+}
+
+func testScanEventsMatchesParser(t *testing.T, b []byte) {
+	expected := parseAll(&Parser{}, string(b))
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Split(ScanEvents)
+	parsed := []LogEvent{}
+	for scanner.Scan() {
+		parsed = append(parsed, ParseEvent(scanner.Bytes()))
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(parsed))
+	}
+	for i := range expected {
+		if !parsed[i].Equal(expected[i]) {
+			t.Errorf("event %d differs: %q", i, Diff(expected[i], parsed[i]))
+		}
+	}
+}
+
+func TestScanEventsTokens(t *testing.T) {
+	input := "junk before\n" +
+		"# Query_time: 1\nSELECT 1;\n\n" +
+		"# Query_time: 2\nSELECT\n2;\n" +
+		"/usr/sbin/mysqld, Version: 5.7.16-log (MySQL Community Server (GPL)). started with:\n" +
+		"Tcp port: 3306  Unix socket: /tmp/mysql.sock\n" +
+		"Time                 Id Command    Argument\n" +
+		"# Query_time: 3\nSELECT 3;" +
+		"\n# trailing header only\n"
+
+	// Feed the scanner one byte at a time to exercise partial reads.
+	scanner := bufio.NewScanner(&oneByteReader{r: strings.NewReader(input)})
+	scanner.Split(ScanEvents)
+	tokens := []string{}
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Text())
+	}
+	expected := []string{
+		"# Query_time: 1\nSELECT 1;",
+		"# Query_time: 2\nSELECT\n2;",
+		"# Query_time: 3\nSELECT 3;",
+	}
+	if strings.Join(tokens, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected tokens %q, got %q", expected, tokens)
+	}
+}
+
+type oneByteReader struct {
+	r *strings.Reader
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return r.r.Read(p[:1])
+}
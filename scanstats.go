@@ -0,0 +1,223 @@
+package mysqllog
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ScanResult is what ScanStats found in a log.
+type ScanResult struct {
+	Events int64
+	Lines  int64
+	Bytes  int64
+
+	// FirstEvent and LastEvent are the earliest and latest SET
+	// timestamps, zero if no event had one.
+	FirstEvent time.Time
+	LastEvent  time.Time
+
+	TotalTime    float64
+	MaxTime      float64
+	LockTime     float64
+	RowsExamined int64
+
+	// Histogram counts events by Query_time; see HistogramLabels.
+	Histogram [HistogramBuckets]int64
+	// sketch holds the Query_time distribution for Quantile.
+	sketch *Sketch
+}
+
+// Quantile returns the approximate q-quantile of Query_time.
+func (r *ScanResult) Quantile(q float64) float64 {
+	if r.sketch == nil {
+		return 0
+	}
+	return r.sketch.Quantile(q)
+}
+
+// Span returns the time from the first to the last event.
+func (r *ScanResult) Span() time.Duration {
+	return r.LastEvent.Sub(r.FirstEvent)
+}
+
+// ScanStats reads a slow query log and counts its events, their
+// Query_time distribution and a few other numbers, as a quick first look
+// at a large log. It only looks at event boundaries, the Query_time,
+// Lock_time and Rows_examined attributes and SET timestamp, without
+// creating LogEvents or keeping statements, so it's much faster
+// than parsing. Events are the ones a Parser with default options
+// emits, except in rare cases, such as a statement with a line starting
+// with "#" inside a string literal.
+func ScanStats(r io.Reader) (ScanResult, error) {
+	var s scanState
+	s.result.sketch = NewSketch(DefaultSketchAccuracy)
+	reader := bufio.NewReaderSize(r, 64<<10)
+	// continued is set while reading the rest of a line too long for
+	// the buffer, which is only looked at for its length.
+	continued := false
+	for {
+		line, err := reader.ReadSlice('\n')
+		s.result.Bytes += int64(len(line))
+		if len(line) > 0 && !continued {
+			s.result.Lines++
+			s.line(line)
+		}
+		continued = err == bufio.ErrBufferFull
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			s.end()
+			return s.result, nil
+		}
+		if err != nil {
+			s.end()
+			return s.result, &ReadError{Offset: s.result.Bytes, Err: err}
+		}
+	}
+}
+
+// scanState is the state of ScanStats between lines.
+type scanState struct {
+	result ScanResult
+
+	inHeader, inQuery bool
+
+	queryTime, lockTime float64
+	rowsExamined        int64
+	timestamp           int64
+	hasTimestamp        bool
+	// skipping is set after the SET and use lines of the statement.
+	skipping bool
+}
+
+var (
+	scanQueryTime    = []byte("Query_time:")
+	scanLockTime     = []byte("Lock_time:")
+	scanRowsExamined = []byte("Rows_examined:")
+	scanTimestamp    = []byte("SET timestamp=")
+	scanUse          = []byte("use ")
+	scanSet          = []byte("SET ")
+	scanAdminCommand = []byte(adminCommandPrefix)
+)
+
+// line handles a line as Parser.ConsumeLine does.
+func (s *scanState) line(line []byte) {
+	if len(bytes.TrimSpace(line)) == 0 {
+		if s.inQuery {
+			s.end()
+		}
+		return
+	}
+	if line[0] == '#' && !((s.inHeader || s.inQuery) && bytes.HasPrefix(line, scanAdminCommand)) {
+		if s.inQuery {
+			s.end()
+		}
+		s.inHeader = true
+		s.header(line)
+		return
+	}
+	if !s.inHeader && !s.inQuery {
+		return
+	}
+	if s.inHeader {
+		s.inHeader = false
+		s.inQuery = true
+		s.skipping = true
+	}
+	if !s.skipping {
+		return
+	}
+	switch {
+	case bytes.HasPrefix(line, scanTimestamp):
+		if ts, ok := scanInt(line[len(scanTimestamp):]); ok {
+			s.timestamp, s.hasTimestamp = ts, true
+		}
+	case bytes.HasPrefix(line, scanUse), bytes.HasPrefix(line, scanSet):
+	default:
+		s.skipping = false
+	}
+}
+
+// header picks the attributes of ScanStats out of a header line.
+func (s *scanState) header(line []byte) {
+	if i := bytes.Index(line, scanQueryTime); i >= 0 {
+		s.queryTime, _ = scanFloat(line[i+len(scanQueryTime):])
+	}
+	if i := bytes.Index(line, scanLockTime); i >= 0 {
+		s.lockTime, _ = scanFloat(line[i+len(scanLockTime):])
+	}
+	if i := bytes.Index(line, scanRowsExamined); i >= 0 {
+		s.rowsExamined, _ = scanInt(line[i+len(scanRowsExamined):])
+	}
+}
+
+// end counts the pending event, if it has a statement, and starts over.
+func (s *scanState) end() {
+	if s.inQuery {
+		r := &s.result
+		r.Events++
+		r.TotalTime += s.queryTime
+		if s.queryTime > r.MaxTime {
+			r.MaxTime = s.queryTime
+		}
+		r.LockTime += s.lockTime
+		r.RowsExamined += s.rowsExamined
+		r.Histogram[histogramBucket(s.queryTime)]++
+		r.sketch.Add(s.queryTime)
+		if s.hasTimestamp {
+			ts := time.Unix(s.timestamp, 0)
+			if r.FirstEvent.IsZero() || ts.Before(r.FirstEvent) {
+				r.FirstEvent = ts
+			}
+			if ts.After(r.LastEvent) {
+				r.LastEvent = ts
+			}
+		}
+	}
+	*s = scanState{result: s.result}
+}
+
+// scanInt parses the unsigned integer at the start of b, after spaces.
+func scanInt(b []byte) (int64, bool) {
+	b = bytes.TrimLeft(b, " \t")
+	var n int64
+	i := 0
+	for ; i < len(b) && isDigit(b[i]); i++ {
+		n = n*10 + int64(b[i]-'0')
+	}
+	return n, i > 0
+}
+
+// scanFloat parses the unsigned decimal number at the start of b, after
+// spaces, such as "0.000123", rounding as strconv.ParseFloat does for
+// the up to 15 digits of the slow log.
+func scanFloat(b []byte) (float64, bool) {
+	b = bytes.TrimLeft(b, " \t")
+	var mantissa int64
+	digits, decimals := 0, 0
+	i := 0
+	for ; i < len(b) && isDigit(b[i]); i++ {
+		mantissa = mantissa*10 + int64(b[i]-'0')
+		digits++
+	}
+	if i < len(b) && b[i] == '.' {
+		for i++; i < len(b) && isDigit(b[i]); i++ {
+			mantissa = mantissa*10 + int64(b[i]-'0')
+			digits++
+			decimals++
+		}
+	}
+	if digits > 15 || decimals >= len(pow10) {
+		// Too long to be exact this way.
+		v, err := strconv.ParseFloat(string(b[:i]), 64)
+		return v, err == nil
+	}
+	return float64(mantissa) / pow10[decimals], digits > 0
+}
+
+// pow10 are the powers of ten a float64 holds exactly.
+var pow10 = [...]float64{1, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10, 1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18, 1e19, 1e20, 1e21, 1e22}
@@ -0,0 +1,113 @@
+package mysqllog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parsedStats computes what ScanStats should return for content by
+// parsing it.
+func parsedStats(content string) ScanResult {
+	var want ScanResult
+	for _, event := range parseAll(&Parser{}, content) {
+		want.Events++
+		queryTime, _ := event["Query_time"].(float64)
+		want.TotalTime += queryTime
+		want.MaxTime = math.Max(want.MaxTime, queryTime)
+		lockTime, _ := event["Lock_time"].(float64)
+		want.LockTime += lockTime
+		rows, _ := event["Rows_examined"].(int64)
+		want.RowsExamined += rows
+		want.Histogram[histogramBucket(queryTime)]++
+		if ts, ok := EventTime(event); ok {
+			ts = time.Unix(ts.Unix(), 0)
+			if want.FirstEvent.IsZero() || ts.Before(want.FirstEvent) {
+				want.FirstEvent = ts
+			}
+			if ts.After(want.LastEvent) {
+				want.LastEvent = ts
+			}
+		}
+	}
+	return want
+}
+
+func TestScanStats(t *testing.T) {
+	for _, name := range []string{"rds.txt", "percona_routines.txt", "banners.txt", "corrupted.txt", "blank_separators.txt", "sessions.txt"} {
+		b, err := ioutil.ReadFile("_test/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ScanStats(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		want := parsedStats(string(b))
+		if got.Events != want.Events {
+			t.Errorf("%s: Events = %d, expected %d", name, got.Events, want.Events)
+		}
+		if got.Bytes != int64(len(b)) {
+			t.Errorf("%s: Bytes = %d, expected %d", name, got.Bytes, len(b))
+		}
+		if lines := int64(strings.Count(string(b), "\n")); got.Lines != lines {
+			t.Errorf("%s: Lines = %d, expected %d", name, got.Lines, lines)
+		}
+		if got.TotalTime != want.TotalTime || got.MaxTime != want.MaxTime || got.LockTime != want.LockTime {
+			t.Errorf("%s: times = %v %v %v, expected %v %v %v", name,
+				got.TotalTime, got.MaxTime, got.LockTime, want.TotalTime, want.MaxTime, want.LockTime)
+		}
+		if got.RowsExamined != want.RowsExamined {
+			t.Errorf("%s: RowsExamined = %d, expected %d", name, got.RowsExamined, want.RowsExamined)
+		}
+		if got.Histogram != want.Histogram {
+			t.Errorf("%s: Histogram = %v, expected %v", name, got.Histogram, want.Histogram)
+		}
+		if !got.FirstEvent.Equal(want.FirstEvent) || !got.LastEvent.Equal(want.LastEvent) {
+			t.Errorf("%s: events from %v to %v, expected %v to %v", name,
+				got.FirstEvent, got.LastEvent, want.FirstEvent, want.LastEvent)
+		}
+	}
+}
+
+func TestScanStatsLongLine(t *testing.T) {
+	content := "# Query_time: 2.5  Lock_time: 0.5 Rows_sent: 1  Rows_examined: 10\n" +
+		"SET timestamp=1514083320;\n" +
+		"SELECT '" + strings.Repeat("x", 200<<10) + "';\n" +
+		"# Query_time: 0.25  Lock_time: 0 Rows_sent: 1  Rows_examined: 5\n" +
+		"SELECT 1"
+	got, err := ScanStats(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Events != 2 || got.Lines != 5 || got.Bytes != int64(len(content)) {
+		t.Errorf("got %d events, %d lines, %d bytes", got.Events, got.Lines, got.Bytes)
+	}
+	if got.TotalTime != 2.75 || got.MaxTime != 2.5 || got.RowsExamined != 15 {
+		t.Errorf("got %v total, %v max, %d rows", got.TotalTime, got.MaxTime, got.RowsExamined)
+	}
+	if q := got.Quantile(1); math.Abs(q-2.5) > 2.5*DefaultSketchAccuracy {
+		t.Errorf("Quantile(1) = %v, expected about 2.5", q)
+	}
+	if got.Span() != 0 {
+		t.Errorf("Span() = %v, expected 0", got.Span())
+	}
+}
+
+func TestScanFloat(t *testing.T) {
+	for _, s := range []string{"0", "0.000123", " 12.5 ", "3.1415926535", "0.1", "99999.999999", "1234567890.1234567"} {
+		got, ok := scanFloat([]byte(s))
+		want, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || !ok || got != want {
+			t.Errorf("scanFloat(%q) = %v, %v, expected %v", s, got, ok, want)
+		}
+	}
+	if _, ok := scanFloat([]byte("abc")); ok {
+		t.Errorf("scanFloat(%q) succeeded", "abc")
+	}
+}
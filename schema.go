@@ -0,0 +1,369 @@
+package mysqllog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// FieldSpec describes a key events can have.
+type FieldSpec struct {
+	Name string
+	// GoType is the type of the value in a LogEvent, such as "int64",
+	// "time.Duration" or "[]string".
+	GoType string
+	// JSONType is the JSON Schema type of the value in JSON output:
+	// "integer", "number", "string", "boolean", "array" or "object".
+	JSONType string
+	// Dialects are the log formats that have the key.
+	Dialects []Dialect
+	// Unit is the unit of times and sizes, Seconds or Bytes, or 0.
+	Unit Unit
+	// Option is the option that adds the key, if it isn't always there.
+	Option      string
+	Description string
+}
+
+// MarshalJSON writes the dialects of f by name.
+func (f FieldSpec) MarshalJSON() ([]byte, error) {
+	dialects := make([]string, len(f.Dialects))
+	for i, d := range f.Dialects {
+		dialects[i] = d.String()
+	}
+	return json.Marshal(struct {
+		Name        string   `json:"name"`
+		GoType      string   `json:"go_type"`
+		JSONType    string   `json:"json_type"`
+		Dialects    []string `json:"dialects"`
+		Unit        string   `json:"unit,omitempty"`
+		Option      string   `json:"option,omitempty"`
+		Description string   `json:"description"`
+	}{f.Name, f.GoType, f.JSONType, dialects, f.unitName(), f.Option, f.Description})
+}
+
+// unitName returns the name of the unit of f, or "" if it has none.
+func (f FieldSpec) unitName() string {
+	if f.Unit == 0 {
+		return ""
+	}
+	return f.Unit.String()
+}
+
+// Schema returns every key a Parser can set on events with the default
+// attribute types, sorted by name. Header attributes come from the
+// tables the Parser converts them with. Attributes without a type are
+// left out; see WithUnknownAttributes.
+func Schema() []FieldSpec {
+	return schema(&attributeTable{}, false, false, Original)
+}
+
+// Schema returns the keys p can set on events, as Schema does, with the
+// types and names of its options: attributes registered with
+// WithAttribute, WithAttributeParser and WithAttributeUnit, WithDurations,
+// WithUnixTimestamps and WithKeyStyle. Values of WithAttributeParser
+// functions are of any type.
+func (p *Parser) Schema() []FieldSpec {
+	return schema(&p.attributes, p.durations, p.unixTimestamps, p.keyStyle)
+}
+
+func schema(t *attributeTable, durations, unixTimestamps bool, style KeyStyle) []FieldSpec {
+	fields := map[string]FieldSpec{}
+	for _, f := range eventFields {
+		f.Dialects = allDialects
+		fields[f.Name] = f
+	}
+	if unixTimestamps {
+		f := fields["Timestamp"]
+		f.GoType, f.JSONType = "int64", "number"
+		f.Description = "The time of the SET timestamp line, or the # Time: line in TiDB mode, in unix seconds: a float64 with a fraction of a second from the # Time: line. A SET timestamp that isn't a number is kept as text."
+		fields["Timestamp"] = f
+	}
+	kinds := t.kinds
+	if kinds == nil {
+		kinds = attributeTypes
+	}
+	tidbKinds := t.tidbKinds
+	if tidbKinds == nil {
+		tidbKinds = tidbAttributeTypes
+	}
+	addAttribute := func(name string, kind AttributeKind, dialects []Dialect) {
+		if durations && kind == AttributeFloat && isDurationAttribute(name) {
+			kind = AttributeDuration
+		}
+		if _, ok := t.parser(name); ok {
+			fields[name] = FieldSpec{Name: name, GoType: "interface{}", Dialects: dialects, Option: "WithAttributeParser", Description: attributeDescription(name)}
+			return
+		}
+		unit := attributeUnit(name, kind)
+		if u, ok := t.units[name]; ok {
+			unit = u.canonical()
+		}
+		goType, jsonType := kindTypes(kind)
+		fields[name] = FieldSpec{Name: name, GoType: goType, JSONType: jsonType, Dialects: dialects, Unit: unit, Description: attributeDescription(name)}
+	}
+	for name, kind := range kinds {
+		dialects := mysqlDialects
+		_, builtin := attributeTypes[name]
+		if d, ok := dialectAttributes[name]; ok {
+			dialects = []Dialect{d}
+		} else if _, ok := tidbKinds[name]; ok || !builtin {
+			// Registered attributes are converted for TiDB too.
+			dialects = allDialects
+		}
+		addAttribute(name, kind, dialects)
+	}
+	for name, kind := range tidbKinds {
+		if _, ok := kinds[name]; ok || name == "DB" {
+			// DB is stored as "Database".
+			continue
+		}
+		addAttribute(name, kind, []Dialect{TiDB})
+	}
+	for name := range t.parsers {
+		if _, ok := fields[name]; !ok {
+			addAttribute(name, AttributeString, allDialects)
+		}
+	}
+	for name := range t.units {
+		if _, ok := fields[name]; !ok {
+			// Numbers without a type are float64.
+			addAttribute(name, AttributeFloat, allDialects)
+		}
+	}
+
+	specs := make([]FieldSpec, 0, len(fields))
+	for _, f := range fields {
+		f.Name = style.Key(f.Name)
+		specs = append(specs, f)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
+var (
+	allDialects   = []Dialect{MySQL, MariaDB, Percona, TiDB}
+	mysqlDialects = []Dialect{MySQL, MariaDB, Percona}
+)
+
+// kindTypes returns the Go and JSON types of values of kind.
+func kindTypes(kind AttributeKind) (goType, jsonType string) {
+	switch kind {
+	case AttributeFloat:
+		return "float64", "number"
+	case AttributeInt:
+		return "int64", "integer"
+	case AttributeBool:
+		return "bool", "boolean"
+	case AttributeDuration:
+		// JSONWriter writes seconds unless told otherwise.
+		return "time.Duration", "number"
+	}
+	return "string", "string"
+}
+
+// attributeDescription returns the description of the header attribute
+// name.
+func attributeDescription(name string) string {
+	if d, ok := attributeDescriptions[name]; ok {
+		return d
+	}
+	return "The " + name + " header attribute."
+}
+
+// WriteSchemaMarkdown writes fields as a Markdown table.
+func WriteSchemaMarkdown(w io.Writer, fields []FieldSpec) error {
+	var b strings.Builder
+	b.WriteString("| Name | Go type | JSON type | Dialects | Unit | Option | Description |\n")
+	b.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, f := range fields {
+		dialects := make([]string, len(f.Dialects))
+		for i, d := range f.Dialects {
+			dialects[i] = d.String()
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s |\n", f.Name, markdownCell(f.GoType), f.JSONType,
+			strings.Join(dialects, ", "), f.unitName(), f.Option, markdownCell(f.Description))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func markdownCell(s string) string {
+	return strings.Replace(s, "|", `\|`, -1)
+}
+
+// eventFields are the keys events get other than header attributes,
+// for every dialect.
+var eventFields = []FieldSpec{
+	{Name: "User", GoType: "string", JSONType: "string", Description: "The user of the # User@Host: line."},
+	{Name: "Host", GoType: "string", JSONType: "string", Description: "The host of the # User@Host: line."},
+	{Name: "IP", GoType: "string", JSONType: "string", Description: "The IP address of the # User@Host: line."},
+	{Name: "Port", GoType: "int64", JSONType: "integer", Description: "The client port of the # User@Host: line, where the host has one."},
+	{Name: "Id", GoType: "int64", JSONType: "integer", Description: "The connection id of the # User@Host: line."},
+	{Name: "Database", GoType: "string", JSONType: "string", Description: "The database of the use line, or DB in TiDB mode."},
+	{Name: "Timestamp", GoType: "string", JSONType: "string", Description: "The time of the SET timestamp line, or the # Time: line in TiDB mode, in DefaultTimestampLayout or the layout of TimestampFormat; see EventTime. A SET timestamp that isn't a number is kept as text."},
+	{Name: "Statement", GoType: "string", JSONType: "string", Description: "The statement, without the use and SET lines before it."},
+	{Name: "ExecutableText", GoType: "string", JSONType: "string", Description: "The statement with its use and SET lines, to run it again."},
+	{Name: "RawNumbers", GoType: "map[string]string", JSONType: "object", Option: "WithRawNumbers", Description: "The text of the numeric attributes WriteSlowLog wouldn't write back the same, by key."},
+	{Name: "Trailer", GoType: "[]string", JSONType: "array", Option: "WithTrailer", Description: "The comment lines logged after the statement, such as MariaDB optimizer trace snippets."},
+	{Name: "HeaderLines", GoType: "[]string", JSONType: "array", Option: "WithHeaderLines", Description: "The header lines as logged."},
+	{Name: "_type", GoType: "string", JSONType: "string", Option: "WithControlEvents", Description: "The type of a control event, ServerRestart, LogRotation or BufferOverflow, in place of the attributes of a query."},
+	{Name: "ServerVersion", GoType: "string", JSONType: "string", Option: "WithControlEvents", Description: "The server version of the banner of a ServerRestart."},
+	{Name: "ServerFlavor", GoType: "string", JSONType: "string", Option: "WithControlEvents", Description: "The dialect of the banner of a ServerRestart."},
+	{Name: "ServerBinary", GoType: "string", JSONType: "string", Option: "WithControlEvents", Description: "The path of the server of the banner of a ServerRestart."},
+	{Name: "ServerPort", GoType: "int64", JSONType: "integer", Option: "WithControlEvents", Description: "The TCP port of the server of a ServerRestart."},
+	{Name: "ServerSocket", GoType: "string", JSONType: "string", Option: "WithControlEvents", Description: "The Unix socket of the server of a ServerRestart."},
+	{Name: "Rotation", GoType: "string", JSONType: "string", Option: "WithControlEvents", Description: "How the file of a LogRotation followed by TailFile was rotated: rename or truncate."},
+	{Name: "AdminCommand", GoType: "string", JSONType: "string", Description: "The command of an administrator command event, such as Quit, in place of a statement."},
+	{Name: "Incomplete", GoType: "bool", JSONType: "boolean", Description: "Set when the event is known or likely to be missing part of its entry; see IncompleteReason."},
+	{Name: "IncompleteReason", GoType: "string", JSONType: "string", Description: "Why the event is incomplete: eof, truncated, resync or idle_flush."},
+	{Name: "TimestampSkew", GoType: "float64", JSONType: "number", Unit: Seconds, Description: "The seconds the # Time: line is off from SET timestamp, where they disagree."},
+	{Name: "Unknown", GoType: "map[string]string", JSONType: "object", Option: "WithUnknownAttributes", Description: "The header attributes without a type, as raw strings."},
+	{Name: "Labels", GoType: "map[string]string", JSONType: "object", Option: "WithLabels", Description: "The labels given with WithLabels, and the server labels of WithServerLabels."},
+	{Name: "Offset", GoType: "int64", JSONType: "integer", Option: "WithOffsets", Description: "The byte offset of the first line of the event."},
+	{Name: "EventID", GoType: "string", JSONType: "string", Option: "WithOffsets", Description: "The EventID, stable across runs over the same file, for idempotent writes."},
+	{Name: "Verb", GoType: "string", JSONType: "string", Option: "WithVerbExtraction", Description: "The verb of the statement, such as SELECT."},
+	{Name: "Tables", GoType: "[]string", JSONType: "array", Option: "WithTableExtraction", Description: "The tables the statement uses."},
+	{Name: "UnboundedWrite", GoType: "bool", JSONType: "boolean", Option: "WithUnboundedWriteDetection", Description: "Set for an UPDATE or DELETE without a WHERE or LIMIT."},
+	{Name: "Meta", GoType: "map[string]string", JSONType: "object", Option: "WithCommentMetadata", Description: "The key-value pairs of the comments of the statement."},
+	{Name: "StatementBytes", GoType: "int64", JSONType: "integer", Unit: Bytes, Option: "WithStatementSize", Description: "The length of the statement in bytes."},
+	{Name: "StatementLines", GoType: "int64", JSONType: "integer", Option: "WithStatementSize", Description: "The number of lines of the statement."},
+	{Name: "ValuesTuples", GoType: "int64", JSONType: "integer", Option: "WithStatementSize", Description: "The number of VALUES tuples of an INSERT or REPLACE."},
+	{Name: "Routine", GoType: "string", JSONType: "string", Option: "WithRoutineExtraction", Description: "The stored routine the statement ran in."},
+	{Name: "Statements", GoType: "[]mysqllog.Statement", JSONType: "array", Option: "WithStatementSplitting", Description: "The statements of the event, each with its database."},
+	{Name: "RedactionToken", GoType: "string", JSONType: "string", Option: "WithRedaction", Description: "The token the RedactionVault keeps the literals of the statement under."},
+	{Name: "Severity", GoType: "string", JSONType: "string", Option: "WithClassifier", Description: "The severity the Classifier gave the event."},
+	{Name: "Fingerprint", GoType: "string", JSONType: "string", Option: "WithMetricsOnly", Description: "The fingerprint of the statement, in place of it."},
+	{Name: "ProbableFullScan", GoType: "bool", JSONType: "boolean", Option: "WithFullScanDetection", Description: "Set when the rows examined suggest a full table scan."},
+	{Name: "Seq", GoType: "int64", JSONType: "integer", Option: "WithSequence", Description: "The number of events before it with the same timestamp, for ordering within a second; synthetic."},
+	{Name: "OutOfOrder", GoType: "bool", JSONType: "boolean", Option: "WithOutOfOrderDetection", Description: "Set when the event is older than the one before it."},
+	{Name: "UserInferred", GoType: "bool", JSONType: "boolean", Option: "WithUserHostInheritance", Description: "Set when User and Host are from an earlier event of the connection."},
+	{Name: "CanonicalConflicts", GoType: "[]string", JSONType: "array", Option: "WithCanonicalKeys", Description: "The canonical keys whose names had different values."},
+	{Name: "SourceFile", GoType: "string", JSONType: "string", Option: "TailFile", Description: "The path of the file the event was read from."},
+	{Name: "Source", GoType: "string", JSONType: "string", Option: "Collector", Description: "The id of the source the event came from."},
+	{Name: "DroppedBytes", GoType: "int64", JSONType: "integer", Option: "WithCollectorMemoryLimit", Description: "The bytes of a Collector source dropped by a BufferOverflow."},
+}
+
+// attributeDescriptions describe the header attributes of attributeTypes
+// and tidbAttributeTypes.
+var attributeDescriptions = map[string]string{
+	"Thread_id":               "The connection id.",
+	"Schema":                  "The default database.",
+	"Last_errno":              "The error number of the statement, 0 if none.",
+	"Killed":                  "The reason the statement was killed, 0 if it wasn't.",
+	"Query_time":              "The seconds the statement took.",
+	"Lock_time":               "The seconds spent waiting for locks.",
+	"Rows_sent":               "The rows sent to the client.",
+	"Rows_examined":           "The rows read by the server.",
+	"Rows_affected":           "The rows changed.",
+	"Rows_read":               "The rows read.",
+	"Bytes_sent":              "The bytes sent to the client.",
+	"Bytes_received":          "The bytes received from the client.",
+	"Tmp_tables":              "The temporary tables created.",
+	"Tmp_disk_tables":         "The temporary tables created on disk.",
+	"Tmp_table_sizes":         "The bytes of the temporary tables.",
+	"InnoDB_trx_id":           "The InnoDB transaction id.",
+	"QC_Hit":                  "Whether the query cache answered the statement.",
+	"QC_hit":                  "Whether the query cache answered the statement.",
+	"Full_scan":               "Whether the statement scanned a table fully.",
+	"Full_join":               "Whether the statement joined without an index.",
+	"Tmp_table":               "Whether the statement used a temporary table.",
+	"Tmp_table_on_disk":       "Whether the statement used a temporary table on disk.",
+	"Filesort":                "Whether the statement sorted with filesort.",
+	"Filesort_on_disk":        "Whether the statement sorted on disk.",
+	"Merge_passes":            "The merge passes of sorting.",
+	"InnoDB_IO_r_ops":         "The InnoDB page reads.",
+	"InnoDB_IO_r_bytes":       "The bytes of InnoDB page reads.",
+	"InnoDB_IO_r_wait":        "The seconds spent waiting for InnoDB page reads.",
+	"InnoDB_rec_lock_wait":    "The seconds spent waiting for InnoDB row locks.",
+	"InnoDB_queue_wait":       "The seconds spent waiting to enter InnoDB.",
+	"InnoDB_pages_distinct":   "The distinct InnoDB pages accessed.",
+	"Log_slow_rate_type":      "What log_slow_rate_limit samples by, session or query.",
+	"Log_slow_rate_limit":     "One in how many events was logged; see WithRateLimitScaling.",
+	"Errno":                   "The error number of the statement, 0 if none.",
+	"Read_first":              "The reads of the first entry of an index.",
+	"Read_last":               "The reads of the last entry of an index.",
+	"Read_key":                "The reads of a row by key.",
+	"Read_next":               "The reads of the next row in key order.",
+	"Read_prev":               "The reads of the previous row in key order.",
+	"Read_rnd":                "The reads of a row by position.",
+	"Read_rnd_next":           "The reads of the next row of a table scan.",
+	"Sort_merge_passes":       "The merge passes of sorting.",
+	"Sort_range_count":        "The sorts done with ranges.",
+	"Sort_rows":               "The rows sorted.",
+	"Sort_scan_count":         "The sorts done by scanning a table.",
+	"Created_tmp_disk_tables": "The temporary tables created on disk.",
+	"Created_tmp_tables":      "The temporary tables created.",
+	"Start":                   "The time the statement started.",
+	"End":                     "The time the statement ended.",
+
+	"Txn_start_ts":              "The start timestamp of the transaction.",
+	"Conn_ID":                   "The connection id.",
+	"Session_alias":             "The alias of the session.",
+	"Exec_retry_count":          "The times the statement was retried.",
+	"Exec_retry_time":           "The seconds spent on retries.",
+	"Parse_time":                "The seconds spent parsing.",
+	"Compile_time":              "The seconds spent compiling the plan.",
+	"Rewrite_time":              "The seconds spent rewriting the statement.",
+	"Preproc_subqueries":        "The subqueries run in advance.",
+	"Preproc_subqueries_time":   "The seconds spent running subqueries in advance.",
+	"Optimize_time":             "The seconds spent optimizing the plan.",
+	"Wait_TS":                   "The seconds spent waiting for a timestamp.",
+	"Cop_time":                  "The seconds of coprocessor tasks.",
+	"Process_time":              "The seconds TiKV spent processing.",
+	"Wait_time":                 "The seconds TiKV spent waiting.",
+	"Backoff_time":              "The seconds spent backing off before retries.",
+	"Backoff_types":             "The kinds of backoff.",
+	"LockKeys_time":             "The seconds spent locking keys.",
+	"Request_count":             "The coprocessor requests sent.",
+	"Total_keys":                "The keys scanned by the coprocessor.",
+	"Process_keys":              "The keys processed by the coprocessor.",
+	"Prewrite_time":             "The seconds of the prewrite phase of the commit.",
+	"Wait_prewrite_binlog_time": "The seconds spent waiting for the binlog prewrite.",
+	"Commit_time":               "The seconds of the commit phase.",
+	"Get_commit_ts_time":        "The seconds spent getting the commit timestamp.",
+	"Commit_backoff_time":       "The seconds spent backing off during the commit.",
+	"Resolve_lock_time":         "The seconds spent resolving locks.",
+	"Local_latch_wait_time":     "The seconds spent waiting for local latches.",
+	"Write_keys":                "The keys written.",
+	"Write_size":                "The bytes written.",
+	"Prewrite_region":           "The regions of the prewrite.",
+	"Txn_retry":                 "The times the transaction was retried.",
+	"Index_names":               "The indexes used.",
+	"Is_internal":               "Whether TiDB ran the statement itself.",
+	"Digest":                    "The digest of the statement.",
+	"Stats":                     "The statistics versions of the tables used.",
+	"Num_cop_tasks":             "The coprocessor tasks.",
+	"Cop_proc_avg":              "The average seconds of processing of the coprocessor tasks.",
+	"Cop_proc_p90":              "The 90th percentile seconds of processing of the coprocessor tasks.",
+	"Cop_proc_max":              "The most seconds of processing of a coprocessor task.",
+	"Cop_proc_addr":             "The address of the slowest coprocessor task.",
+	"Cop_wait_avg":              "The average seconds of waiting of the coprocessor tasks.",
+	"Cop_wait_p90":              "The 90th percentile seconds of waiting of the coprocessor tasks.",
+	"Cop_wait_max":              "The most seconds of waiting of a coprocessor task.",
+	"Cop_wait_addr":             "The address of the coprocessor task that waited longest.",
+	"Mem_max":                   "The most bytes of memory used.",
+	"Disk_max":                  "The most bytes of disk used.",
+	"Prepared":                  "Whether the statement was prepared.",
+	"Plan_from_cache":           "Whether the plan came from the plan cache.",
+	"Plan_from_binding":         "Whether the plan came from a binding.",
+	"Has_more_results":          "Whether the statement has more results to fetch.",
+	"Succ":                      "Whether the statement succeeded.",
+	"IsExplicitTxn":             "Whether the statement ran in an explicit transaction.",
+	"IsSyncStatsFailed":         "Whether loading statistics synchronously failed.",
+	"Result_rows":               "The rows returned.",
+	"Warnings":                  "The warnings of the statement, as JSON.",
+	"Resource_group":            "The resource group of the statement.",
+	"Request_unit_read":         "The read request units used.",
+	"Request_unit_write":        "The write request units used.",
+	"Time_queued_by_rc":         "The seconds spent queued by resource control.",
+	"KV_total":                  "The seconds spent on TiKV requests.",
+	"PD_total":                  "The seconds spent on PD requests.",
+	"Backoff_total":             "The seconds spent backing off in total.",
+	"Write_sql_response_total":  "The seconds spent writing the response.",
+	"Plan":                      "The execution plan, encoded.",
+	"Plan_digest":               "The digest of the plan.",
+	"Binary_plan":               "The execution plan in binary, encoded.",
+	"Prev_stmt":                 "The statement before this one in the transaction.",
+}
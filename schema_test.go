@@ -0,0 +1,120 @@
+package mysqllog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func schemaTypes(fields []FieldSpec) map[string]string {
+	types := map[string]string{}
+	for _, f := range fields {
+		types[f.Name] = f.GoType
+	}
+	return types
+}
+
+func TestSchemaCoversFixtures(t *testing.T) {
+	files, err := filepath.Glob("_test/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	optionSets := [][]Option{
+		{WithUnknownAttributes(KeepUnknown)},
+		{WithUnknownAttributes(KeepUnknown), WithVerbExtraction(), WithTableExtraction(), WithUnboundedWriteDetection(),
+			WithCommentMetadata(), WithStatementSize(), WithRoutineExtraction(), WithStatementSplitting(), WithOffsets(),
+			WithFullScanDetection(0.5, 1), WithUserHostInheritance(10), WithOutOfOrderDetection(time.Second, nil),
+			WithServerLabels(), WithLabels(map[string]string{"env": "test"}), WithCanonicalKeys(true), WithHeaderLines(),
+			WithControlEvents()},
+		{WithUnknownAttributes(KeepUnknown), WithDurations(), WithMetricsOnly()},
+		{WithUnknownAttributes(KeepUnknown), WithUnixTimestamps(), WithKeyStyle(SnakeLower)},
+	}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, opts := range optionSets {
+			p := NewParser(opts...)
+			types := schemaTypes(p.Schema())
+			for _, e := range parseAll(p, string(content)) {
+				for key, v := range e {
+					goType, ok := types[key]
+					if !ok {
+						t.Errorf("%s, options %d: %s isn't in the schema", file, i, key)
+						continue
+					}
+					if strings.EqualFold(key, "Timestamp") {
+						// Unix timestamps are float64 with a
+						// fraction, and invalid ones strings.
+						continue
+					}
+					if got := strings.Replace(fmt.Sprintf("%T", v), "mysqllog.", "", 1); got != strings.Replace(goType, "mysqllog.", "", 1) {
+						t.Errorf("%s, options %d: expected %s to be %s, got %s", file, i, key, goType, got)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSchemaDescriptions(t *testing.T) {
+	for _, kinds := range []map[string]AttributeKind{attributeTypes, tidbAttributeTypes} {
+		for name := range kinds {
+			if _, ok := attributeDescriptions[name]; !ok && name != "DB" {
+				t.Errorf("%s has no description", name)
+			}
+		}
+	}
+	for _, f := range Schema() {
+		if f.Description == "" || f.GoType == "" || f.JSONType == "" || len(f.Dialects) == 0 {
+			t.Errorf("%s is incomplete: %+v", f.Name, f)
+		}
+	}
+}
+
+func TestParserSchema(t *testing.T) {
+	p := NewParser(
+		WithAttribute("Thread_id", AttributeString),
+		WithAttribute("Frobnicate_count", AttributeInt),
+		WithAttributeParser("Ticket", func(s string) (interface{}, error) { return strconv.Atoi(s) }),
+		WithDurations(),
+	)
+	fields := map[string]FieldSpec{}
+	for _, f := range p.Schema() {
+		fields[f.Name] = f
+	}
+	type TestCase struct {
+		name, goType, jsonType string
+		dialects               int
+	}
+	for _, c := range []TestCase{
+		{"Thread_id", "string", "string", 3},
+		{"Frobnicate_count", "int64", "integer", 4},
+		{"Ticket", "interface{}", "", 4},
+		{"Query_time", "time.Duration", "number", 4},
+		{"Rows_sent", "int64", "integer", 3},
+		{"Txn_start_ts", "int64", "integer", 1},
+	} {
+		f := fields[c.name]
+		if f.GoType != c.goType || f.JSONType != c.jsonType || len(f.Dialects) != c.dialects {
+			t.Errorf("%s: expected %s, %s in %d dialects, got %+v", c.name, c.goType, c.jsonType, c.dialects, f)
+		}
+	}
+	if _, ok := schemaTypes(Schema())["Frobnicate_count"]; ok {
+		t.Error("expected registered attributes only in the schema of their parser")
+	}
+
+	var b bytes.Buffer
+	if err := WriteSchemaMarkdown(&b, p.Schema()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "| Ticket | interface{} |  | MySQL, MariaDB, Percona, TiDB |  | WithAttributeParser | The Ticket header attribute. |\n") {
+		t.Errorf("unexpected Markdown:\n%s", b.String())
+	}
+}
@@ -0,0 +1,102 @@
+package mysqllog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// Errors returned by TailServer when the server's slow query log can't
+// be followed.
+var (
+	ErrSlowLogDisabled = errors.New("mysqllog: slow_query_log is off")
+	// ErrSlowLogTable is returned when log_output doesn't include FILE.
+	// Reading the mysql.slow_log table isn't supported.
+	ErrSlowLogTable = errors.New("mysqllog: log_output doesn't include FILE")
+)
+
+// ServerLog is the slow query log configuration of a server.
+type ServerLog struct {
+	// Enabled is @@slow_query_log.
+	Enabled bool
+	// Output is @@log_output, such as "FILE" or "TABLE,FILE".
+	Output string
+	// File is @@slow_query_log_file, relative to @@datadir if it
+	// isn't absolute.
+	File string
+}
+
+// ToFile reports whether the server writes the log to File.
+func (l ServerLog) ToFile() bool {
+	for _, output := range strings.Split(l.Output, ",") {
+		if strings.EqualFold(strings.TrimSpace(output), "FILE") {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryServerLog returns the slow query log configuration of the server
+// of db from its system variables.
+func QueryServerLog(ctx context.Context, db *sql.DB) (ServerLog, error) {
+	var enabled, output, file, datadir sql.NullString
+	err := db.QueryRowContext(ctx, "SELECT @@slow_query_log, @@log_output, @@slow_query_log_file, @@datadir").
+		Scan(&enabled, &output, &file, &datadir)
+	if err != nil {
+		return ServerLog{}, err
+	}
+	l := ServerLog{
+		Enabled: enabled.String == "1" || strings.EqualFold(enabled.String, "ON"),
+		Output:  output.String,
+		File:    file.String,
+	}
+	if l.File != "" && !filepath.IsAbs(l.File) {
+		l.File = filepath.Join(datadir.String, l.File)
+	}
+	return l, nil
+}
+
+// TailServer follows the slow query log of the server of db, as
+// TailFile does, finding the file from the server's variables (see
+// QueryServerLog). It returns ErrSlowLogDisabled or ErrSlowLogTable if
+// the server isn't writing the log to a file.
+//
+// The variables are queried again every DefaultRecheckInterval, or the
+// interval given with WithRecheckInterval, while there's no new data.
+// If slow_query_log_file changed, the current file is read to the end
+// and its last event flushed before the new one is followed, once it
+// exists. FLUSH SLOW LOGS after the file was rotated is followed as
+// TailFile follows rotation. A failed query is retried at the next
+// interval, following the current file meanwhile.
+//
+// The file is read directly, so TailServer must run on the server's
+// host, or see its log through a shared mount at the same path.
+func TailServer(ctx context.Context, db *sql.DB, fn func(LogEvent), opts ...TailOption) error {
+	c := newTailConfig(opts)
+	l, err := QueryServerLog(ctx, db)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !l.ToFile():
+		return ErrSlowLogTable
+	case !l.Enabled:
+		return ErrSlowLogDisabled
+	}
+
+	checked := c.clock.Now()
+	moved := func(ctx context.Context) string {
+		if c.clock.Now().Sub(checked) < c.recheck {
+			return ""
+		}
+		checked = c.clock.Now()
+		l, err := QueryServerLog(ctx, db)
+		if err != nil || !l.ToFile() {
+			return ""
+		}
+		return l.File
+	}
+	return tailFile(ctx, c, l.File, fn, moved)
+}
@@ -0,0 +1,138 @@
+package mysqllog
+
+import (
+	"context"
+	"database/sql/driver"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer answers the log variable queries of QueryServerLog.
+type fakeServer struct {
+	mu                             sync.Mutex
+	enabled, output, file, datadir string
+}
+
+func (s *fakeServer) set(file string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.file = file
+}
+
+func (s *fakeServer) rows(query string) (*fakeRows, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &fakeRows{
+		columns: []string{"@@slow_query_log", "@@log_output", "@@slow_query_log_file", "@@datadir"},
+		values:  [][]driver.Value{{[]byte(s.enabled), []byte(s.output), []byte(s.file), []byte(s.datadir)}},
+	}, nil
+}
+
+func TestQueryServerLog(t *testing.T) {
+	type TestCase struct {
+		server   *fakeServer
+		expected ServerLog
+		toFile   bool
+	}
+	for _, c := range []TestCase{
+		{
+			&fakeServer{enabled: "1", output: "FILE", file: "db1-slow.log", datadir: "/var/lib/mysql/"},
+			ServerLog{Enabled: true, Output: "FILE", File: "/var/lib/mysql/db1-slow.log"},
+			true,
+		},
+		{
+			&fakeServer{enabled: "0", output: "TABLE,FILE", file: "/var/log/mysql/slow.log", datadir: "/var/lib/mysql/"},
+			ServerLog{Output: "TABLE,FILE", File: "/var/log/mysql/slow.log"},
+			true,
+		},
+		{
+			&fakeServer{enabled: "1", output: "TABLE", file: "slow.log", datadir: "/data/"},
+			ServerLog{Enabled: true, Output: "TABLE", File: "/data/slow.log"},
+			false,
+		},
+	} {
+		db, d := openFakeDB(t)
+		d.rows = c.server.rows
+		l, err := QueryServerLog(context.Background(), db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l != c.expected || l.ToFile() != c.toFile {
+			t.Errorf("expected %+v, got %+v", c.expected, l)
+		}
+		db.Close()
+	}
+}
+
+func TestTailServerNotToFile(t *testing.T) {
+	type TestCase struct {
+		server   *fakeServer
+		expected error
+	}
+	for _, c := range []TestCase{
+		{&fakeServer{enabled: "1", output: "TABLE", file: "slow.log"}, ErrSlowLogTable},
+		{&fakeServer{enabled: "1", output: "NONE", file: "slow.log"}, ErrSlowLogTable},
+		{&fakeServer{enabled: "0", output: "FILE", file: "slow.log"}, ErrSlowLogDisabled},
+	} {
+		db, d := openFakeDB(t)
+		d.rows = c.server.rows
+		err := TailServer(context.Background(), db, func(LogEvent) {})
+		if err != c.expected {
+			t.Errorf("%s %s: expected %v, got %v", c.server.enabled, c.server.output, c.expected, err)
+		}
+		db.Close()
+	}
+}
+
+func TestTailServerFileChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "slow.log")
+	second := filepath.Join(dir, "slow-2.log")
+	appendFile(t, first, slowEvent(1)+slowEvent(2))
+
+	server := &fakeServer{enabled: "ON", output: "FILE", file: "slow.log", datadir: dir}
+	db, d := openFakeDB(t)
+	defer db.Close()
+	d.rows = server.rows
+	checkpoint, err := OpenCheckpoint(filepath.Join(dir, "checkpoint.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := append([]TailOption{WithRecheckInterval(10 * time.Millisecond), WithCheckpoint(checkpoint)}, fastPoll...)
+	run := startFollow(func(ctx context.Context, fn func(LogEvent)) error {
+		return TailServer(ctx, db, fn, opts...)
+	})
+	run.expect(t, "SELECT 1;")
+
+	// The new file is followed once it exists, after the last events
+	// of the old one.
+	server.set(second)
+	time.Sleep(30 * time.Millisecond)
+	appendFile(t, first, slowEvent(3))
+	appendFile(t, second, slowEvent(4)+slowEvent(5))
+	run.expect(t, "SELECT 2;", "SELECT 3;", "SELECT 4;")
+
+	// FLUSH SLOW LOGS after a rename reopens the same path.
+	if err := os.Rename(second, second+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, second, slowEvent(6)+slowEvent(7))
+	run.expect(t, "SELECT 5;", "SELECT 6;")
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+
+	if _, ok := checkpoint.Offset(first); ok {
+		t.Errorf("expected the old file to be forgotten by the checkpoint")
+	}
+	if _, ok := checkpoint.Offset(second); !ok {
+		t.Errorf("expected an offset for the new file")
+	}
+}
@@ -0,0 +1,147 @@
+package mysqllog
+
+import (
+	"container/list"
+	"time"
+)
+
+// Defaults for Sessions.
+const (
+	DefaultSessionIdle = 5 * time.Minute
+	DefaultSessions    = 10000
+)
+
+// Session summarizes the events of one connection.
+type Session struct {
+	Id   int64
+	User string
+	Host string
+	// Start and End are the times of the first and last events.
+	Start time.Time
+	End   time.Time
+	// Queries and TotalTime cover the events other than administrator
+	// commands, and Fingerprints lists their fingerprints in order.
+	Queries      int
+	TotalTime    float64
+	Fingerprints []string
+}
+
+// Sessions is a Sink grouping events by connection "Id" into sessions.
+// A session ends at an administrator Quit command, when an event for
+// the Id follows more than Idle after the previous one, when a Connect
+// command starts a new session for the Id, or at Flush. Emit is called
+// with each finished session. At most MaxSessions sessions are tracked;
+// beyond that the least recently active one is finished early. Events
+// without an Id are ignored.
+type Sessions struct {
+	// Idle is DefaultSessionIdle if zero.
+	Idle time.Duration
+	// MaxSessions is DefaultSessions if zero.
+	MaxSessions int
+	Emit        func(Session)
+
+	lru     *list.List
+	entries map[int64]*list.Element
+}
+
+// Write adds e to the session of its connection.
+func (s *Sessions) Write(e LogEvent) error {
+	id, ok := e.Int64("Id")
+	if !ok {
+		return nil
+	}
+	ts, _ := EventTime(e)
+	command, _ := e["AdminCommand"].(string)
+
+	session := s.session(id)
+	if session != nil && (command == "Connect" ||
+		(!ts.IsZero() && !session.End.IsZero() && ts.Sub(session.End) > s.idle())) {
+		s.finish(id)
+		session = nil
+	}
+	if session == nil {
+		session = s.start(id)
+	}
+	if user, ok := e["User"].(string); ok && session.User == "" {
+		session.User = user
+	}
+	if host, ok := e["Host"].(string); ok && session.Host == "" {
+		session.Host = host
+	}
+	if !ts.IsZero() {
+		if session.Start.IsZero() {
+			session.Start = ts
+		}
+		session.End = ts
+	}
+	if command == "" {
+		queryTime, _ := e.Float64("Query_time")
+		statement, _ := e["Statement"].(string)
+		session.Queries++
+		session.TotalTime += queryTime
+		session.Fingerprints = append(session.Fingerprints, Fingerprint(statement))
+	}
+	if command == "Quit" {
+		s.finish(id)
+	}
+	return nil
+}
+
+// Flush finishes every open session, least recently active first.
+func (s *Sessions) Flush() {
+	for s.lru != nil && s.lru.Len() > 0 {
+		s.finish(s.lru.Back().Value.(*Session).Id)
+	}
+}
+
+// Close implements Sink by calling Flush.
+func (s *Sessions) Close() error {
+	s.Flush()
+	return nil
+}
+
+func (s *Sessions) idle() time.Duration {
+	if s.Idle > 0 {
+		return s.Idle
+	}
+	return DefaultSessionIdle
+}
+
+// session returns the open session for id, or nil.
+func (s *Sessions) session(id int64) *Session {
+	el, ok := s.entries[id]
+	if !ok {
+		return nil
+	}
+	s.lru.MoveToFront(el)
+	return el.Value.(*Session)
+}
+
+// start opens a session for id, finishing the least recently active
+// session if too many are open.
+func (s *Sessions) start(id int64) *Session {
+	if s.entries == nil {
+		s.entries = map[int64]*list.Element{}
+		s.lru = list.New()
+	}
+	max := s.MaxSessions
+	if max <= 0 {
+		max = DefaultSessions
+	}
+	for s.lru.Len() >= max {
+		s.finish(s.lru.Back().Value.(*Session).Id)
+	}
+	session := &Session{Id: id}
+	s.entries[id] = s.lru.PushFront(session)
+	return session
+}
+
+// finish removes the session for id and emits it.
+func (s *Sessions) finish(id int64) {
+	el := s.entries[id]
+	s.lru.Remove(el)
+	delete(s.entries, id)
+	if s.Emit != nil {
+		s.Emit(*el.Value.(*Session))
+	}
+}
@@ -0,0 +1,83 @@
+package mysqllog
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/sessions.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sessions []Session
+	s := &Sessions{Emit: func(session Session) { sessions = append(sessions, session) }}
+	for _, e := range parseAll(&Parser{}, string(b)) {
+		s.Write(e)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions before Flush, got %+v", sessions)
+	}
+	s.Flush()
+	if len(sessions) != 3 {
+		t.Fatalf("expected 3 sessions after Flush, got %+v", sessions)
+	}
+
+	batch := sessions[0]
+	expected := Session{
+		Id:        21,
+		User:      "batch",
+		Host:      "10.0.0.5",
+		Start:     time.Unix(1520503200, 0),
+		End:       time.Unix(1520503206, 0),
+		Queries:   3,
+		TotalTime: 10,
+		Fingerprints: []string{
+			"select * from orders where created < ?",
+			"delete from orders where id = ?",
+			"delete from orders where id = ?",
+		},
+	}
+	if !batch.Start.Equal(expected.Start) || !batch.End.Equal(expected.End) {
+		t.Errorf("expected %v to %v, got %v to %v", expected.Start, expected.End, batch.Start, batch.End)
+	}
+	batch.Start, batch.End = expected.Start, expected.End
+	if !reflect.DeepEqual(batch, expected) {
+		t.Errorf("expected session\n%+v, got\n%+v", expected, batch)
+	}
+
+	// The idle gap splits connection 22 into two sessions.
+	if web := sessions[1]; web.Id != 22 || web.Queries != 2 || web.End.Unix() != 1520503204 {
+		t.Errorf("unexpected session %+v", web)
+	}
+	if web := sessions[2]; web.Id != 22 || web.Queries != 1 || web.Fingerprints[0] != "select * from carts where user_id = ?" {
+		t.Errorf("unexpected session %+v", web)
+	}
+}
+
+func TestSessionsBounded(t *testing.T) {
+	var emitted []int64
+	s := &Sessions{MaxSessions: 2, Emit: func(session Session) { emitted = append(emitted, session.Id) }}
+	for id := int64(1); id <= 4; id++ {
+		s.Write(LogEvent{"Id": id, "Statement": "SELECT 1"})
+	}
+	if !reflect.DeepEqual(emitted, []int64{1, 2}) || len(s.entries) != 2 {
+		t.Errorf("expected the oldest sessions to be finished, got %v", emitted)
+	}
+}
+
+func TestParseAdminCommand(t *testing.T) {
+	events := parseAll(&Parser{}, "# User@Host: a[a] @ localhost []  Id:     3\n# Query_time: 0.1  Lock_time: 0 Rows_sent: 0  Rows_examined: 0\n"+
+		"# administrator command: Quit;\n# User@Host: a[a] @ localhost []  Id:     4\n# Query_time: 0.1  Lock_time: 0 Rows_sent: 0  Rows_examined: 0\nSELECT 1;\n")
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %v", events)
+	}
+	if events[0]["AdminCommand"] != "Quit" || Fingerprint(events[0]["Statement"].(string)) != "administrator command: Quit" {
+		t.Errorf("unexpected admin command event %v", events[0])
+	}
+	if events[1]["Id"] != int64(4) || events[1]["Statement"] != "SELECT 1;" {
+		t.Errorf("unexpected event %v", events[1])
+	}
+}
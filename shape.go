@@ -0,0 +1,261 @@
+package mysqllog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultShapes is the number of shapes a ShapeCollector keeps by
+// default.
+const DefaultShapes = 100
+
+// WithHeaderLines sets "HeaderLines" on each event to its header lines
+// as logged, without their newlines, such as for a ShapeCollector.
+func WithHeaderLines() Option {
+	return func(p *Parser) {
+		p.keepHeaderLines = true
+	}
+}
+
+// setHeaderLines sets "HeaderLines" on e for WithHeaderLines, from the
+// pending lines.
+func (p *Parser) setHeaderLines(e LogEvent) {
+	var lines []string
+	for _, line := range p.lines[:headerLines(p.lines)] {
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	e["HeaderLines"] = lines
+}
+
+// Shape is the shape of header lines counted by a ShapeCollector: the
+// line with each value replaced by the type it looks like, such as
+// "# Query_time: float  Lock_time: float  Rows_sent: int".
+type Shape struct {
+	Shape string `json:"shape"`
+	// Keys are the attribute names of the line in order.
+	Keys  []string `json:"keys,omitempty"`
+	Count int64    `json:"count"`
+	// Example is the first line seen with the shape.
+	Example string `json:"example"`
+}
+
+// ShapeCollector is a diagnostic Sink counting the distinct shapes of
+// the header lines of the events written to it, with an example line
+// each, to find out what a dialect the Parser doesn't fully understand
+// logs, such as for an issue or a WithAttribute. Parse with
+// WithHeaderLines to count the lines as logged; the header of other
+// events is rebuilt with WriteSlowLog, which only has the attributes
+// the Parser kept.
+//
+// It keeps the k shapes with the most lines: a new shape replaces the
+// one with the fewest, whose lines are then counted as dropped. Its
+// JSON has the number of "lines", the "dropped" ones and the "shapes"
+// in the order of Shapes. A ShapeCollector is safe for concurrent use.
+type ShapeCollector struct {
+	k int
+
+	mu      sync.Mutex
+	shapes  map[string]*Shape
+	lines   int64
+	dropped int64
+}
+
+// NewShapeCollector returns a ShapeCollector keeping k shapes, or
+// DefaultShapes if k is zero or less.
+func NewShapeCollector(k int) *ShapeCollector {
+	if k <= 0 {
+		k = DefaultShapes
+	}
+	return &ShapeCollector{k: k, shapes: map[string]*Shape{}}
+}
+
+// Write counts the header lines of e.
+func (c *ShapeCollector) Write(e LogEvent) error {
+	lines, ok := e["HeaderLines"].([]string)
+	if !ok {
+		var buf bytes.Buffer
+		WriteSlowLog(&buf, e)
+		all := strings.Split(buf.String(), "\n")
+		lines = all[:headerLines(all)]
+	}
+	for _, line := range lines {
+		if line != "" {
+			c.AddLine(line)
+		}
+	}
+	return nil
+}
+
+// Close implements Sink.
+func (c *ShapeCollector) Close() error {
+	return nil
+}
+
+// AddLine counts a header line.
+func (c *ShapeCollector) AddLine(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	shape, keys := lineShape(line)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines++
+	if s := c.shapes[shape]; s != nil {
+		s.Count++
+		return
+	}
+	if len(c.shapes) >= c.k {
+		var fewest *Shape
+		for _, s := range c.shapes {
+			if fewest == nil || s.Count < fewest.Count || s.Count == fewest.Count && s.Shape > fewest.Shape {
+				fewest = s
+			}
+		}
+		delete(c.shapes, fewest.Shape)
+		c.dropped += fewest.Count
+	}
+	c.shapes[shape] = &Shape{Shape: shape, Keys: keys, Count: 1, Example: line}
+}
+
+// Shapes returns the shapes kept, with the most lines first.
+func (c *ShapeCollector) Shapes() []Shape {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	shapes := make([]Shape, 0, len(c.shapes))
+	for _, s := range c.shapes {
+		shapes = append(shapes, *s)
+	}
+	sort.Slice(shapes, func(i, j int) bool {
+		if shapes[i].Count != shapes[j].Count {
+			return shapes[i].Count > shapes[j].Count
+		}
+		return shapes[i].Shape < shapes[j].Shape
+	})
+	return shapes
+}
+
+// MarshalJSON writes the lines counted and the Shapes.
+func (c *ShapeCollector) MarshalJSON() ([]byte, error) {
+	shapes := c.Shapes()
+	c.mu.Lock()
+	lines, dropped := c.lines, c.dropped
+	c.mu.Unlock()
+	return json.Marshal(struct {
+		Lines   int64   `json:"lines"`
+		Dropped int64   `json:"dropped"`
+		Shapes  []Shape `json:"shapes"`
+	}{lines, dropped, shapes})
+}
+
+// lineShape returns the shape of a header line and its attribute names.
+func lineShape(line string) (shape string, keys []string) {
+	if strings.HasPrefix(line, "# Time:") {
+		return "# Time: " + valueType(strings.TrimSpace(line[len("# Time:"):])), []string{"Time"}
+	}
+	text := strings.TrimPrefix(line, "#")
+	if strings.HasPrefix(line, "# User@Host:") {
+		return "# " + tokenShape(text), append([]string{"User@Host"}, tokenKeys(text[len(" User@Host:"):])...)
+	}
+	matches := attributeMatches(text)
+	if len(matches) == 0 {
+		return "# " + tokenShape(text), nil
+	}
+	parts := make([]string, len(matches))
+	for i, m := range matches {
+		match := text[m[0]:m[1]]
+		colon := strings.IndexByte(match, ':')
+		keys = append(keys, match[:colon])
+		parts[i] = match[:colon] + ": " + valueType(strings.TrimSpace(match[colon+1:]))
+	}
+	return "# " + strings.Join(parts, "  "), keys
+}
+
+// tokenShape returns text with its words replaced by their valueType,
+// keeping names followed by a colon and the punctuation around values.
+func tokenShape(text string) string {
+	fields := strings.Fields(text)
+	for i, field := range fields {
+		if isKeyToken(field) {
+			continue
+		}
+		var b strings.Builder
+		start := 0
+		for j := 0; j <= len(field); j++ {
+			if j < len(field) && !strings.ContainsRune("[]@(),", rune(field[j])) {
+				continue
+			}
+			if j > start {
+				b.WriteString(valueType(field[start:j]))
+			}
+			if j < len(field) {
+				b.WriteByte(field[j])
+			}
+			start = j + 1
+		}
+		fields[i] = b.String()
+	}
+	return strings.Join(fields, " ")
+}
+
+// tokenKeys returns the names followed by a colon in text.
+func tokenKeys(text string) []string {
+	var keys []string
+	for _, field := range strings.Fields(text) {
+		if isKeyToken(field) {
+			keys = append(keys, strings.TrimSuffix(field, ":"))
+		}
+	}
+	return keys
+}
+
+// isKeyToken reports whether field is a name followed by a colon, like
+// "Id:".
+func isKeyToken(field string) bool {
+	if len(field) < 2 || field[len(field)-1] != ':' {
+		return false
+	}
+	for _, r := range field[:len(field)-1] {
+		if !(r == '_' || r == '@' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// valueType guesses the type of an attribute value.
+func valueType(v string) string {
+	switch {
+	case v == "":
+		return "empty"
+	case strings.EqualFold(v, "yes") || strings.EqualFold(v, "no") || strings.EqualFold(v, "on") ||
+		strings.EqualFold(v, "off") || strings.EqualFold(v, "true") || strings.EqualFold(v, "false"):
+		return "bool"
+	case strings.HasPrefix(v, "0x") && len(v) > 2:
+		if _, err := strconv.ParseUint(v[2:], 16, 64); err == nil {
+			return "hex"
+		}
+	}
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return "int"
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return "float"
+	}
+	if net.ParseIP(v) != nil {
+		return "ip"
+	}
+	if _, err := parseTimeLine(v); err == nil {
+		return "time"
+	}
+	if _, err := time.Parse(legacyTimeLayout, strings.Join(strings.Fields(v), " ")); err == nil {
+		return "time"
+	}
+	return "string"
+}
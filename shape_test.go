@@ -0,0 +1,97 @@
+package mysqllog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestShapeCollector(t *testing.T) {
+	tests := []struct {
+		file   string
+		shapes []string
+	}{
+		{"./_test/canonical_percona.txt", []string{
+			"# Time: time",
+			"# User@Host: string[string] @ string [ip]",
+			"# Thread_id: int  Schema: string  Last_errno: int  Killed: int",
+			"# Query_time: float  Lock_time: float  Rows_sent: int  Rows_examined: int  Rows_affected: int",
+			"# Bytes_sent: int  Tmp_tables: int  Tmp_disk_tables: int  Tmp_table_sizes: int",
+			"# Full_scan: bool  Full_join: bool  Tmp_table: bool  Tmp_table_on_disk: bool",
+			"# Filesort: bool  Filesort_on_disk: bool  Merge_passes: int",
+		}},
+		{"./_test/canonical_mariadb.txt", []string{
+			"# Time: time",
+			"# User@Host: string[string] @ string [ip]",
+			"# Thread_id: int  Schema: string  QC_hit: bool",
+			"# Query_time: float  Lock_time: float  Rows_sent: int  Rows_examined: int",
+			"# Rows_affected: int  Bytes_sent: int",
+			"# Tmp_tables: int  Tmp_disk_tables: int  Tmp_table_sizes: int",
+			"# Full_scan: bool  Full_join: bool  Tmp_table: bool  Tmp_table_on_disk: bool",
+			"# Filesort: bool  Filesort_on_disk: bool  Merge_passes: int  Priority_queue: bool",
+		}},
+	}
+	for _, test := range tests {
+		content, err := ioutil.ReadFile(test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := NewShapeCollector(0)
+		for _, e := range parseAll(NewParser(WithHeaderLines()), string(content)) {
+			c.Write(e)
+		}
+		var got []string
+		for _, s := range c.Shapes() {
+			got = append(got, s.Shape)
+			if s.Count == 0 || s.Example == "" {
+				t.Errorf("%s: expected a count and an example for %q", test.file, s.Shape)
+			}
+		}
+		sort.Strings(got)
+		sort.Strings(test.shapes)
+		if !reflect.DeepEqual(got, test.shapes) {
+			t.Errorf("%s: expected shapes\n%q\ngot\n%q", test.file, test.shapes, got)
+		}
+	}
+
+	c := NewShapeCollector(0)
+	c.AddLine("# Thread_id: 42  Schema: shop  Last_errno: 0  Killed: 0\n")
+	if s := c.Shapes()[0]; !reflect.DeepEqual(s.Keys, []string{"Thread_id", "Schema", "Last_errno", "Killed"}) ||
+		s.Example != "# Thread_id: 42  Schema: shop  Last_errno: 0  Killed: 0" {
+		t.Errorf("unexpected shape %+v", s)
+	}
+
+	// Without WithHeaderLines, the header is rebuilt.
+	c = NewShapeCollector(0)
+	c.Write(LogEvent{"User": "app", "Host": "localhost", "Id": int64(1), "Query_time": 1.5, "Statement": "SELECT 1"})
+	if shapes := c.Shapes(); len(shapes) != 2 || shapes[0].Shape != "# Query_time: float" || shapes[1].Shape != "# User@Host: string[string] @ string [] Id: int" {
+		t.Errorf("unexpected shapes of a rebuilt header %+v", shapes)
+	}
+}
+
+func TestShapeCollectorBound(t *testing.T) {
+	c := NewShapeCollector(2)
+	for i := 0; i < 3; i++ {
+		c.AddLine("# Query_time: 1.0")
+	}
+	c.AddLine("# A: 1")
+	c.AddLine("# A: 2")
+	c.AddLine("# B: x")
+	shapes := c.Shapes()
+	if len(shapes) != 2 || shapes[0].Shape != "# Query_time: float" || shapes[1].Shape != "# B: string" {
+		t.Errorf("expected the most frequent shape and the newest, got %+v", shapes)
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump struct {
+		Lines, Dropped int64
+		Shapes         []Shape
+	}
+	if err := json.Unmarshal(b, &dump); err != nil || dump.Lines != 6 || dump.Dropped != 2 || len(dump.Shapes) != 2 {
+		t.Errorf("unexpected JSON %s", b)
+	}
+}
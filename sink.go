@@ -0,0 +1,47 @@
+package mysqllog
+
+// Sink receives parsed events.
+type Sink interface {
+	// Write consumes an event.
+	Write(e LogEvent) error
+	// Close flushes anything buffered and releases resources.
+	Close() error
+}
+
+// AckSink is a Sink that can acknowledge that events are stored
+// durably, for WithAckSink.
+type AckSink interface {
+	Sink
+	// WriteBatch writes events, and returns only once they're stored
+	// durably, or with the error that kept them from it.
+	WriteBatch(events []LogEvent) error
+}
+
+// MultiSink returns a Sink that writes each event to all of sinks in
+// order. Write and Close return the first error, but every sink is
+// still written to or closed.
+func MultiSink(sinks ...Sink) Sink {
+	return multiSink(sinks)
+}
+
+type multiSink []Sink
+
+func (m multiSink) Write(e LogEvent) error {
+	var first error
+	for _, s := range m {
+		if err := s.Write(e); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (m multiSink) Close() error {
+	var first error
+	for _, s := range m {
+		if err := s.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
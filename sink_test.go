@@ -0,0 +1,39 @@
+package mysqllog
+
+import (
+	"errors"
+	"testing"
+)
+
+// recordingSink collects the events written to it.
+type recordingSink struct {
+	events []LogEvent
+	closed bool
+	err    error
+}
+
+func (s *recordingSink) Write(e LogEvent) error {
+	s.events = append(s.events, e)
+	return s.err
+}
+
+func (s *recordingSink) Close() error {
+	s.closed = true
+	return s.err
+}
+
+func TestMultiSink(t *testing.T) {
+	failing := &recordingSink{err: errors.New("boom")}
+	ok := &recordingSink{}
+	sink := MultiSink(failing, ok)
+
+	if err := sink.Write(LogEvent{"Statement": "SELECT 1"}); err != failing.err {
+		t.Errorf("expected first error, got %v", err)
+	}
+	if err := sink.Close(); err != failing.err {
+		t.Errorf("expected first error, got %v", err)
+	}
+	if len(ok.events) != 1 || !ok.closed {
+		t.Error("expected every sink to be written and closed despite errors")
+	}
+}
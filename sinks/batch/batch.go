@@ -0,0 +1,239 @@
+// Package batch buffers mysqllog events and sends them in batches with
+// retries, for the sinks pushing events over the network, such as those
+// of the other packages of mysqllog/sinks.
+package batch
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Preetam/mysqllog"
+)
+
+// Defaults for a Batcher.
+const (
+	DefaultSize          = 1000
+	DefaultFlushInterval = 5 * time.Second
+	DefaultRetries       = 3
+	DefaultRetryBackoff  = 100 * time.Millisecond
+)
+
+// Option configures a Batcher, and the sinks built on one.
+type Option func(*config)
+
+type config struct {
+	size     int
+	interval time.Duration
+	retries  int
+	backoff  time.Duration
+	onError  func(err error, batch []mysqllog.LogEvent)
+	clock    mysqllog.Clock
+}
+
+// WithSize sends a batch once it holds n events.
+func WithSize(n int) Option {
+	return func(c *config) {
+		c.size = n
+	}
+}
+
+// WithFlushInterval sends pending events at least every d. Zero or less
+// only sends full batches and on Close.
+func WithFlushInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.interval = d
+	}
+}
+
+// WithRetries retries a failed batch up to n times, waiting backoff
+// before the first retry and doubling the wait after each one.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *config) {
+		c.retries = n
+		c.backoff = backoff
+	}
+}
+
+// WithErrorHandler calls f with the last error and the events of each
+// batch dropped after its retries are exhausted.
+func WithErrorHandler(f func(err error, batch []mysqllog.LogEvent)) Option {
+	return func(c *config) {
+		c.onError = f
+	}
+}
+
+// WithClock waits for the flush interval and between retries with clock in
+// place of mysqllog.RealClock.
+func WithClock(clock mysqllog.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// Batcher buffers events and sends them in batches with retries. Batches
+// are sent when full, at the flush interval, and on Close. A Batcher is
+// safe for concurrent use, and sends one batch at a time.
+type Batcher struct {
+	config
+	send func([]mysqllog.LogEvent) error
+
+	mu      sync.Mutex
+	pending []mysqllog.LogEvent
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// New returns a Batcher sending batches with send. An error returned by
+// send is retried, unless it's made with Permanent; RetryAfter sets the
+// wait before the next try.
+func New(send func([]mysqllog.LogEvent) error, opts ...Option) *Batcher {
+	b := &Batcher{
+		config: config{
+			size:     DefaultSize,
+			interval: DefaultFlushInterval,
+			retries:  DefaultRetries,
+			backoff:  DefaultRetryBackoff,
+		},
+		send: send,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&b.config)
+	}
+	if b.clock == nil {
+		b.clock = mysqllog.RealClock
+	}
+	if b.size <= 0 {
+		b.size = 1
+	}
+	if b.interval > 0 {
+		go b.run()
+	} else {
+		close(b.done)
+	}
+	return b
+}
+
+func (b *Batcher) run() {
+	defer close(b.done)
+	for {
+		timer := b.clock.NewTimer(b.interval)
+		select {
+		case <-timer.C():
+			b.Flush()
+		case <-b.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Add buffers e, sending the batch if it's full. It returns the error
+// of a batch that was dropped.
+func (b *Batcher) Add(e mysqllog.LogEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, e)
+	if len(b.pending) < b.size {
+		return nil
+	}
+	return b.sendPending()
+}
+
+// Flush sends any pending events.
+func (b *Batcher) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sendPending()
+}
+
+// WriteBatch sends events together with any pending ones, retrying as
+// for a full batch, and returns once they were sent, as for
+// mysqllog.AckSink.
+func (b *Batcher) WriteBatch(events []mysqllog.LogEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, events...)
+	return b.sendPending()
+}
+
+// Close stops the flush interval and sends any pending events.
+func (b *Batcher) Close() error {
+	select {
+	case <-b.stop:
+	default:
+		close(b.stop)
+	}
+	<-b.done
+	return b.Flush()
+}
+
+func (b *Batcher) sendPending() error {
+	if len(b.pending) == 0 {
+		return nil
+	}
+	batch := b.pending
+	b.pending = nil
+	backoff := b.backoff
+	err := b.send(batch)
+	for retry := 0; err != nil && retry < b.retries; retry++ {
+		if _, ok := err.(permanentError); ok {
+			break
+		}
+		if r, ok := err.(retryAfterError); ok && r.after > 0 {
+			b.sleep(r.after)
+		} else {
+			b.sleep(backoff)
+		}
+		backoff *= 2
+		err = b.send(batch)
+	}
+	if err != nil && b.onError != nil {
+		b.onError(err, batch)
+	}
+	return err
+}
+
+func (b *Batcher) sleep(d time.Duration) {
+	<-b.clock.NewTimer(d).C()
+}
+
+// RetryAfter returns err asking for the batch to be retried after d, as
+// given by the server, instead of the usual backoff. A d of zero or less
+// keeps the backoff.
+func RetryAfter(err error, d time.Duration) error {
+	return retryAfterError{err, d}
+}
+
+// Permanent returns err as one that retrying won't fix, such as a
+// request the server rejects.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+type retryAfterError struct {
+	error
+	after time.Duration
+}
+
+type permanentError struct {
+	error
+}
+
+// ParseRetryAfter parses a Retry-After header, in seconds or as an HTTP
+// date. It returns zero if the header is missing or invalid.
+func ParseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,150 @@
+package batch
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Preetam/mysqllog"
+)
+
+// recorder records the batches sent, failing the first failures calls
+// with err.
+type recorder struct {
+	mu       sync.Mutex
+	batches  [][]mysqllog.LogEvent
+	failures int
+	err      error
+	calls    int
+}
+
+func (r *recorder) send(events []mysqllog.LogEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.calls <= r.failures {
+		return r.err
+	}
+	r.batches = append(r.batches, events)
+	return nil
+}
+
+// sleepClock records the waits of a Batcher without waiting.
+type sleepClock struct {
+	*mysqllog.ManualClock
+	waits []time.Duration
+}
+
+func (c *sleepClock) NewTimer(d time.Duration) mysqllog.Timer {
+	c.waits = append(c.waits, d)
+	return c.ManualClock.NewTimer(0)
+}
+
+func (r *recorder) sent() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.batches)
+}
+
+func TestBatcher(t *testing.T) {
+	r := &recorder{}
+	b := New(r.send, WithSize(2), WithFlushInterval(0))
+	for i := 0; i < 3; i++ {
+		if err := b.Add(mysqllog.LogEvent{"Rows_sent": int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r.sent() != 1 || len(r.batches[0]) != 2 {
+		t.Fatalf("expected a full batch to be sent, got %v", r.batches)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if r.sent() != 2 || len(r.batches[1]) != 1 {
+		t.Errorf("expected Close to send the rest, got %v", r.batches)
+	}
+	if err := b.Flush(); err != nil || r.sent() != 2 {
+		t.Errorf("expected nothing to flush, got %v", r.batches)
+	}
+}
+
+func TestBatcherWriteBatch(t *testing.T) {
+	r := &recorder{failures: 1, err: errors.New("unavailable")}
+	b := New(r.send, WithSize(10), WithFlushInterval(0), WithRetries(1, time.Nanosecond))
+	b.Add(mysqllog.LogEvent{"Rows_sent": int64(0)})
+	err := b.WriteBatch([]mysqllog.LogEvent{{"Rows_sent": int64(1)}, {"Rows_sent": int64(2)}})
+	if err != nil || r.sent() != 1 || len(r.batches[0]) != 3 {
+		t.Errorf("expected the pending and written events sent together after a retry, got %v, %v", r.batches, err)
+	}
+}
+
+func TestBatcherFlushInterval(t *testing.T) {
+	r := &recorder{}
+	clock := mysqllog.NewManualClock(time.Time{})
+	b := New(r.send, WithFlushInterval(time.Minute), WithClock(clock))
+	defer b.Close()
+	b.Add(mysqllog.LogEvent{"Statement": "SELECT 1"})
+	clock.WaitTimers(1)
+	clock.Add(time.Minute)
+	// The next timer is made once the batch is sent.
+	clock.WaitTimers(1)
+	if r.sent() != 1 {
+		t.Errorf("expected the interval to send the pending event, got %d batches", r.sent())
+	}
+}
+
+func TestBatcherRetries(t *testing.T) {
+	unavailable := errors.New("unavailable")
+	for _, c := range []struct {
+		name     string
+		err      error
+		calls    int
+		sleeps   []time.Duration
+		dropped  bool
+		failures int
+	}{
+		{name: "retried", err: unavailable, failures: 2, calls: 3, sleeps: []time.Duration{time.Millisecond, 2 * time.Millisecond}},
+		{name: "exhausted", err: unavailable, failures: 100, calls: 3, sleeps: []time.Duration{time.Millisecond, 2 * time.Millisecond}, dropped: true},
+		{name: "permanent", err: Permanent(unavailable), failures: 100, calls: 1, dropped: true},
+		{name: "retry after", err: RetryAfter(unavailable, time.Minute), failures: 1, calls: 2, sleeps: []time.Duration{time.Minute}},
+	} {
+		r := &recorder{failures: c.failures, err: c.err}
+		var dropped []mysqllog.LogEvent
+		clock := &sleepClock{ManualClock: mysqllog.NewManualClock(time.Time{})}
+		b := New(r.send, WithSize(1), WithFlushInterval(0), WithRetries(2, time.Millisecond), WithClock(clock),
+			WithErrorHandler(func(err error, batch []mysqllog.LogEvent) { dropped = batch }))
+
+		err := b.Add(mysqllog.LogEvent{"Statement": "SELECT 1"})
+		sleeps := clock.waits
+		if (err != nil) != c.dropped || (len(dropped) == 1) != c.dropped {
+			t.Errorf("%s: unexpected error %v, dropped %v", c.name, err, dropped)
+		}
+		if r.calls != c.calls || len(sleeps) != len(c.sleeps) {
+			t.Errorf("%s: expected %d calls and sleeps %v, got %d and %v", c.name, c.calls, c.sleeps, r.calls, sleeps)
+			continue
+		}
+		for i := range sleeps {
+			if sleeps[i] != c.sleeps[i] {
+				t.Errorf("%s: expected sleeps %v, got %v", c.name, c.sleeps, sleeps)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		header   string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"Thu, 01 Mar 2018 10:00:30 GMT", 30 * time.Second},
+		{"soon", 0},
+	} {
+		if got := ParseRetryAfter(c.header, now); got != c.expected {
+			t.Errorf("ParseRetryAfter(%q): expected %v, got %v", c.header, c.expected, got)
+		}
+	}
+}
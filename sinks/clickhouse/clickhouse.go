@@ -0,0 +1,178 @@
+// Package clickhouse is a mysqllog Sink inserting events into a
+// ClickHouse table.
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Preetam/mysqllog"
+	"github.com/Preetam/mysqllog/sinks/batch"
+)
+
+// Schema is the default table for Sink, with columns matching Row. It
+// keeps one row per id once parts are merged, so events inserted again,
+// such as after a crash, aren't counted twice; query it with FINAL for
+// exact results before then.
+const Schema = `CREATE TABLE slow_queries (
+    id            String,
+    ts            DateTime64(6),
+    user          LowCardinality(String),
+    host          LowCardinality(String),
+    db            LowCardinality(String),
+    fingerprint   LowCardinality(String),
+    query_time    Float64,
+    lock_time     Float64,
+    rows_sent     Int64,
+    rows_examined Int64,
+    statement     String
+) ENGINE = ReplacingMergeTree
+PARTITION BY toYYYYMM(ts)
+ORDER BY (fingerprint, ts, id)`
+
+// Row is an event as inserted by Sink.
+type Row struct {
+	ID           string  `json:"id"`
+	Timestamp    string  `json:"ts"`
+	User         string  `json:"user"`
+	Host         string  `json:"host"`
+	Database     string  `json:"db"`
+	Fingerprint  string  `json:"fingerprint"`
+	QueryTime    float64 `json:"query_time"`
+	LockTime     float64 `json:"lock_time"`
+	RowsSent     int64   `json:"rows_sent"`
+	RowsExamined int64   `json:"rows_examined"`
+	Statement    string  `json:"statement"`
+}
+
+// timeLayout is the text format of DateTime64(6), in UTC.
+const timeLayout = "2006-01-02 15:04:05.000000"
+
+// NewRow converts e to a row, with the mysqllog.EventID of e as ID.
+// Events without a timestamp get the zero DateTime64.
+func NewRow(e mysqllog.LogEvent) Row {
+	row := Row{ID: mysqllog.EventID(e), Timestamp: time.Unix(0, 0).UTC().Format(timeLayout)}
+	if ts, ok := mysqllog.EventTime(e); ok {
+		row.Timestamp = ts.UTC().Format(timeLayout)
+	}
+	row.User, _ = e["User"].(string)
+	row.Host, _ = e["Host"].(string)
+	row.Database, _ = e["Database"].(string)
+	row.Statement, _ = e["Statement"].(string)
+	row.Fingerprint = mysqllog.Fingerprint(row.Statement)
+	row.QueryTime, _ = e.Float64("Query_time")
+	row.LockTime, _ = e.Float64("Lock_time")
+	row.RowsSent, _ = e.Int64("Rows_sent")
+	row.RowsExamined, _ = e.Int64("Rows_examined")
+	return row
+}
+
+// Inserter inserts rows into a ClickHouse table. HTTP
+// implements it over the HTTP interface; other protocols can be plugged
+// into Sink by implementing it.
+type Inserter interface {
+	Insert(ctx context.Context, table string, rows []Row) error
+}
+
+// HTTP inserts rows with the ClickHouse HTTP interface in
+// JSONEachRow format.
+type HTTP struct {
+	// URL is the server address, such as "http://localhost:8123".
+	URL      string
+	User     string
+	Password string
+	// AsyncInsert lets the server buffer inserts, without waiting for
+	// them to be written.
+	AsyncInsert bool
+	// Client is http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Insert implements Inserter.
+func (c *HTTP) Insert(ctx context.Context, table string, rows []Row) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+
+	params := url.Values{}
+	params.Set("query", "INSERT INTO "+table+" FORMAT JSONEachRow")
+	if c.AsyncInsert {
+		params.Set("async_insert", "1")
+		params.Set("wait_for_async_insert", "0")
+	}
+	req, err := http.NewRequest("POST", c.URL+"/?"+params.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if c.User != "" {
+		req.Header.Set("X-ClickHouse-User", c.User)
+		req.Header.Set("X-ClickHouse-Key", c.Password)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("clickhouse: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Sink is a mysqllog.Sink inserting events into a ClickHouse table in
+// batches. Batches are sent when full, at the flush interval, and on
+// Close, and failed batches are retried; see batch.Option.
+type Sink struct {
+	client Inserter
+	table  string
+	batch  *batch.Batcher
+}
+
+// New returns a Sink inserting into table with client.
+func New(client Inserter, table string, opts ...batch.Option) *Sink {
+	s := &Sink{client: client, table: table}
+	s.batch = batch.New(s.insert, opts...)
+	return s
+}
+
+func (s *Sink) insert(events []mysqllog.LogEvent) error {
+	rows := make([]Row, len(events))
+	for i, e := range events {
+		rows[i] = NewRow(e)
+	}
+	return s.client.Insert(context.Background(), s.table, rows)
+}
+
+// Write buffers e, inserting the batch if it's full.
+func (s *Sink) Write(e mysqllog.LogEvent) error {
+	return s.batch.Add(e)
+}
+
+// WriteBatch inserts events with any buffered ones, returning once
+// ClickHouse accepted them, for mysqllog.WithAckSink. With
+// HTTP.AsyncInsert, that's before they're written.
+func (s *Sink) WriteBatch(events []mysqllog.LogEvent) error {
+	return s.batch.WriteBatch(events)
+}
+
+// Close inserts any buffered events.
+func (s *Sink) Close() error {
+	return s.batch.Close()
+}
@@ -0,0 +1,112 @@
+package clickhouse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Preetam/mysqllog"
+	"github.com/Preetam/mysqllog/sinks/batch"
+)
+
+func TestSink(t *testing.T) {
+	var mu sync.Mutex
+	var queries, bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("X-ClickHouse-User") != "writer" {
+			t.Errorf("expected the user header, got %v", r.Header)
+		}
+		queries = append(queries, r.URL.Query().Get("query")+" async="+r.URL.Query().Get("async_insert"))
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	client := &HTTP{URL: server.URL, User: "writer", AsyncInsert: true}
+	sink := New(client, "slow_queries", batch.WithSize(2), batch.WithFlushInterval(0))
+	for i, statement := range []string{"SELECT 1", "SELECT 'a\"b'", "UPDATE t SET a = 1"} {
+		sink.Write(mysqllog.LogEvent{
+			"Statement":     statement,
+			"Query_time":    1.5,
+			"Rows_examined": int64(i),
+			"User":          "app",
+			"Timestamp":     time.Date(2018, 3, 1, 10, 0, i, 123456000, time.UTC),
+			"EventID":       fmt.Sprintf("id%d", i),
+		})
+	}
+	mu.Lock()
+	if len(bodies) != 1 {
+		t.Errorf("expected a full batch to be sent, got %d requests", len(bodies))
+	}
+	mu.Unlock()
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queries) != 2 || queries[0] != "INSERT INTO slow_queries FORMAT JSONEachRow async=1" {
+		t.Fatalf("unexpected queries %q", queries)
+	}
+	lines := strings.Split(strings.TrimSpace(bodies[0]), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 rows in the first batch, got %q", bodies[0])
+	}
+	var row map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &row); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"id": "id1", "ts": "2018-03-01 10:00:01.123456", "user": "app", "host": "", "db": "",
+		"fingerprint": "select ?", "query_time": 1.5, "lock_time": 0.0,
+		"rows_sent": 0.0, "rows_examined": 1.0, "statement": "SELECT 'a\"b'",
+	}
+	for k, v := range expected {
+		if row[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, row[k])
+		}
+	}
+	if !strings.Contains(bodies[1], `"fingerprint":"update t set a = ?"`) {
+		t.Errorf("unexpected second batch %q", bodies[1])
+	}
+}
+
+type flakyInserter struct {
+	failures int
+	calls    int
+}
+
+func (f *flakyInserter) Insert(ctx context.Context, table string, rows []Row) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func TestSinkRetries(t *testing.T) {
+	flaky := &flakyInserter{failures: 2}
+	sink := New(flaky, "t", batch.WithSize(1), batch.WithFlushInterval(0), batch.WithRetries(2, time.Millisecond))
+	if err := sink.Write(mysqllog.LogEvent{"Statement": "SELECT 1"}); err != nil || flaky.calls != 3 {
+		t.Errorf("expected success after 2 retries, got %v after %d calls", err, flaky.calls)
+	}
+
+	var dropped []mysqllog.LogEvent
+	failing := &flakyInserter{failures: 100}
+	sink = New(failing, "t", batch.WithSize(1), batch.WithFlushInterval(0), batch.WithRetries(2, time.Millisecond),
+		batch.WithErrorHandler(func(err error, batch []mysqllog.LogEvent) { dropped = batch }))
+	if err := sink.Write(mysqllog.LogEvent{"Statement": "SELECT 1"}); err == nil {
+		t.Error("expected an error once retries are exhausted")
+	}
+	if len(dropped) != 1 || failing.calls != 3 {
+		t.Errorf("unexpected retries: %d calls, dropped %v", failing.calls, dropped)
+	}
+}
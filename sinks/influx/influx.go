@@ -0,0 +1,101 @@
+// Package influx is a mysqllog Sink pushing events to InfluxDB, and the
+// servers compatible with its write API.
+package influx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Preetam/mysqllog"
+	"github.com/Preetam/mysqllog/sinks/batch"
+)
+
+// Config configures a Sink.
+type Config struct {
+	// URL is the server address, such as "http://localhost:8086".
+	URL string
+	// Org and Bucket are where points are written.
+	Org    string
+	Bucket string
+	// Token is sent as "Authorization: Token <Token>" when set.
+	Token string
+	// Measurement is mysqllog.DefaultInfluxMeasurement if empty.
+	Measurement string
+	// Tags are added to every point, such as {"cluster": "main"}.
+	Tags map[string]string
+	// Client is http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Sink is a mysqllog.Sink pushing events in InfluxDB line protocol, as
+// with mysqllog.AppendLineProtocol, to the /api/v2/write endpoint of InfluxDB 2
+// and servers compatible with it, such as VictoriaMetrics. Batches are
+// sent as for loki.Sink; see batch.Option.
+type Sink struct {
+	config Config
+	batch  *batch.Batcher
+	now    func() time.Time
+}
+
+// New returns a Sink pushing to config.URL.
+func New(config Config, opts ...batch.Option) *Sink {
+	s := &Sink{config: config, now: time.Now}
+	s.batch = batch.New(s.push, opts...)
+	return s
+}
+
+// Write buffers e, pushing the batch if it's full.
+func (s *Sink) Write(e mysqllog.LogEvent) error {
+	return s.batch.Add(e)
+}
+
+// Close pushes any buffered events.
+func (s *Sink) Close() error {
+	return s.batch.Close()
+}
+
+func (s *Sink) push(events []mysqllog.LogEvent) error {
+	var body []byte
+	for _, e := range events {
+		body, _ = mysqllog.AppendLineProtocol(body, s.config.Measurement, s.config.Tags, e)
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	query := url.Values{"org": {s.config.Org}, "bucket": {s.config.Bucket}, "precision": {"ns"}}
+	req, err := http.NewRequest("POST", strings.TrimRight(s.config.URL, "/")+"/api/v2/write?"+query.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return batch.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.config.Token)
+	}
+	client := s.config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("influx: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		return batch.RetryAfter(err, batch.ParseRetryAfter(resp.Header.Get("Retry-After"), s.now()))
+	case resp.StatusCode/100 == 4:
+		return batch.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package influx
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Preetam/mysqllog"
+	"github.com/Preetam/mysqllog/sinks/batch"
+)
+
+func TestSink(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/api/v2/write" || q.Get("org") != "ops" || q.Get("bucket") != "mysql" || q.Get("precision") != "ns" ||
+			r.Header.Get("Authorization") != "Token secret" {
+			t.Errorf("unexpected request %s %v", r.URL, r.Header)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(b))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := New(Config{
+		URL:    server.URL + "/",
+		Org:    "ops",
+		Bucket: "mysql",
+		Token:  "secret",
+		Tags:   map[string]string{"host": "db 1"},
+	}, batch.WithSize(2), batch.WithFlushInterval(0))
+	sink.Write(mysqllog.LogEvent{"User": "app", "Query_time": 1.0, "Timestamp": int64(1)})
+	sink.Write(mysqllog.LogEvent{"Host": "db=2", "Query_time": 2.0, "Timestamp": int64(2)})
+	// Not timestamped, so there's nothing to push.
+	sink.Write(mysqllog.LogEvent{"Query_time": 3.0})
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expected := `mysql_slowlog,host=db\ 1,user=app query_time=1 1000000000` + "\n" +
+		`mysql_slowlog,host=db\=2 query_time=2 2000000000` + "\n"
+	if len(bodies) != 1 || bodies[0] != expected {
+		t.Errorf("expected %q, got %q", expected, bodies)
+	}
+
+	status = http.StatusBadRequest
+	var dropped []mysqllog.LogEvent
+	sink = New(Config{URL: server.URL, Org: "ops", Bucket: "mysql", Token: "secret"},
+		batch.WithFlushInterval(0), batch.WithRetries(3, time.Millisecond),
+		batch.WithErrorHandler(func(err error, batch []mysqllog.LogEvent) { dropped = batch }))
+	sink.Write(mysqllog.LogEvent{"Query_time": 1.0, "Timestamp": int64(1)})
+	if err := sink.Close(); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("expected a 400 error, got %v", err)
+	}
+	if len(bodies) != 2 || len(dropped) != 1 {
+		t.Errorf("expected 1 request without retries, got %d", len(bodies)-1)
+	}
+}
@@ -0,0 +1,236 @@
+// Package loki is a mysqllog Sink pushing events to Grafana Loki.
+package loki
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Preetam/mysqllog"
+	"github.com/Preetam/mysqllog/sinks/batch"
+)
+
+// Format is the format of the log lines pushed by Sink.
+type Format int
+
+const (
+	// JSON pushes each event as a JSON object.
+	JSON Format = iota
+	// Logfmt pushes the selected Fields of each event as logfmt.
+	Logfmt
+)
+
+// Config configures a Sink.
+type Config struct {
+	// URL is the server address, such as "http://localhost:3100".
+	URL string
+	// TenantID is sent as X-Scope-OrgID when set.
+	TenantID string
+	// Labels are added to every stream, such as {"job": "mysql-slow"}.
+	Labels map[string]string
+	// LabelKeys are event attributes used as stream labels, named in
+	// lower case: "Host" becomes the "host" label. Each distinct set of
+	// values is a separate stream in Loki, so stick to attributes with
+	// few values such as Host or a cluster label. User or Database are
+	// fine for a handful of accounts and schemas, but anything per query
+	// will overwhelm the index.
+	LabelKeys []string
+	// Format is JSON by default.
+	Format Format
+	// Fields are the fields of a Logfmt line, mysqllog.DefaultFields if
+	// nil.
+	Fields []mysqllog.Field
+	// ClampTimestamps moves an event older than the last one pushed to
+	// its stream forward to that time, for Loki servers that reject
+	// out-of-order entries.
+	ClampTimestamps bool
+	// Client is http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Sink is a mysqllog.Sink pushing events to Grafana Loki in batches.
+// Entries are timestamped with mysqllog.EventTime, falling back to the
+// time the batch is pushed. Batches are sent gzipped when full, at the flush interval, and
+// on Close; see batch.Option. A 429 response is retried after the
+// server's Retry-After, and other 4xx responses aren't retried.
+type Sink struct {
+	config Config
+	batch  *batch.Batcher
+	now    func() time.Time
+
+	mu sync.Mutex
+	// last is the newest timestamp pushed per stream, for ClampTimestamps.
+	last map[string]time.Time
+}
+
+// New returns a Sink pushing to config.URL.
+func New(config Config, opts ...batch.Option) *Sink {
+	if config.Fields == nil {
+		config.Fields = mysqllog.DefaultFields
+	}
+	s := &Sink{config: config, now: time.Now, last: map[string]time.Time{}}
+	s.batch = batch.New(s.push, opts...)
+	return s
+}
+
+// Write buffers e, pushing the batch if it's full.
+func (s *Sink) Write(e mysqllog.LogEvent) error {
+	return s.batch.Add(e)
+}
+
+// Close pushes any buffered events.
+func (s *Sink) Close() error {
+	return s.batch.Close()
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type lokiEntry struct {
+	ts   time.Time
+	line string
+}
+
+func (s *Sink) push(events []mysqllog.LogEvent) error {
+	body, err := s.encode(events)
+	if err != nil {
+		return batch.Permanent(err)
+	}
+	req, err := http.NewRequest("POST", strings.TrimRight(s.config.URL, "/")+"/loki/api/v1/push", bytes.NewReader(body))
+	if err != nil {
+		return batch.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	if s.config.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", s.config.TenantID)
+	}
+	client := s.config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("loki: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return batch.RetryAfter(err, batch.ParseRetryAfter(resp.Header.Get("Retry-After"), s.now()))
+	case resp.StatusCode/100 == 4:
+		return batch.Permanent(err)
+	}
+	return err
+}
+
+// encode returns the gzipped push request for events.
+func (s *Sink) encode(events []mysqllog.LogEvent) ([]byte, error) {
+	streams := map[string]*lokiStream{}
+	entries := map[string][]lokiEntry{}
+	now := s.now()
+	for _, e := range events {
+		labels := s.labels(e)
+		key := labelKey(labels)
+		if streams[key] == nil {
+			streams[key] = &lokiStream{Stream: labels}
+		}
+		line, err := s.line(e)
+		if err != nil {
+			return nil, err
+		}
+		ts, ok := mysqllog.EventTime(e)
+		if !ok {
+			ts = now
+		}
+		entries[key] = append(entries[key], lokiEntry{ts: ts, line: line})
+	}
+
+	keys := make([]string, 0, len(streams))
+	for key := range streams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	request := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	s.mu.Lock()
+	for _, key := range keys {
+		stream := streams[key]
+		list := entries[key]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].ts.Before(list[j].ts) })
+		for _, entry := range list {
+			ts := entry.ts
+			if s.config.ClampTimestamps {
+				if last := s.last[key]; ts.Before(last) {
+					ts = last
+				}
+				s.last[key] = ts
+			}
+			stream.Values = append(stream.Values, [2]string{strconv.FormatInt(ts.UnixNano(), 10), entry.line})
+		}
+		request.Streams = append(request.Streams, stream)
+	}
+	s.mu.Unlock()
+
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	if err := json.NewEncoder(zw).Encode(request); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+func (s *Sink) labels(e mysqllog.LogEvent) map[string]string {
+	labels := make(map[string]string, len(s.config.Labels)+len(s.config.LabelKeys))
+	for name, value := range s.config.Labels {
+		labels[name] = value
+	}
+	flat := e.Flatten(mysqllog.FlattenOptions{})
+	for _, key := range s.config.LabelKeys {
+		if value, ok := mysqllog.FieldValue(flat, mysqllog.Field{Key: key}); ok && value != "" {
+			labels[strings.ToLower(key)] = value
+		}
+	}
+	return labels
+}
+
+func (s *Sink) line(e mysqllog.LogEvent) (string, error) {
+	if s.config.Format == Logfmt {
+		return mysqllog.FormatLogfmt(e, s.config.Fields), nil
+	}
+	b, err := json.Marshal(e.Nested())
+	return string(b), err
+}
+
+// labelKey identifies a label set.
+func labelKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%q,", name, labels[name])
+	}
+	return b.String()
+}
@@ -0,0 +1,144 @@
+package loki
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Preetam/mysqllog"
+	"github.com/Preetam/mysqllog/sinks/batch"
+)
+
+type lokiPush struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	} `json:"streams"`
+}
+
+func TestSink(t *testing.T) {
+	var mu sync.Mutex
+	var pushes []lokiPush
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/push" || r.Header.Get("Content-Encoding") != "gzip" || r.Header.Get("X-Scope-OrgID") != "ops" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var push lokiPush
+		if err := json.NewDecoder(zr).Decode(&push); err != nil {
+			t.Error(err)
+			return
+		}
+		mu.Lock()
+		pushes = append(pushes, push)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := New(Config{
+		URL:             server.URL + "/",
+		TenantID:        "ops",
+		Labels:          map[string]string{"job": "mysql"},
+		LabelKeys:       []string{"Host"},
+		Format:          Logfmt,
+		Fields:          mysqllog.Fields("User", "Query_time"),
+		ClampTimestamps: true,
+	}, batch.WithSize(3), batch.WithFlushInterval(0))
+	base := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	events := []mysqllog.LogEvent{
+		{"Host": "db1", "User": "app", "Query_time": 1.5, "Timestamp": base.Add(2 * time.Second)},
+		{"Host": "db2", "User": "app", "Query_time": 0.5, "Timestamp": base},
+		{"Host": "db1", "User": "etl", "Query_time": 2.0, "Timestamp": base.Add(time.Second)},
+		// Older than the last entry pushed for db1.
+		{"Host": "db1", "User": "app", "Query_time": 3.0, "Timestamp": base},
+	}
+	for _, e := range events {
+		if err := sink.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pushes) != 2 {
+		t.Fatalf("expected 2 pushes, got %d", len(pushes))
+	}
+	first := pushes[0]
+	if len(first.Streams) != 2 {
+		t.Fatalf("expected 2 streams, got %+v", first.Streams)
+	}
+	db1 := first.Streams[0]
+	if db1.Stream["host"] != "db1" || db1.Stream["job"] != "mysql" || len(db1.Stream) != 2 {
+		t.Errorf("unexpected labels %v", db1.Stream)
+	}
+	if len(db1.Values) != 2 {
+		t.Fatalf("expected 2 entries for db1, got %v", db1.Values)
+	}
+	expected := [2]string{"1519898401000000000", "user=etl query_time=2"}
+	if db1.Values[0] != expected {
+		t.Errorf("expected entries sorted by time, got %v", db1.Values)
+	}
+	clamped := pushes[1].Streams[0].Values[0]
+	if clamped != [2]string{"1519898402000000000", "user=app query_time=3"} {
+		t.Errorf("expected the late entry to be clamped, got %v", clamped)
+	}
+}
+
+// sleepClock records the waits of a Sink without waiting.
+type sleepClock struct {
+	*mysqllog.ManualClock
+	waits []time.Duration
+}
+
+func (c *sleepClock) NewTimer(d time.Duration) mysqllog.Timer {
+	c.waits = append(c.waits, d)
+	return c.ManualClock.NewTimer(0)
+}
+
+func TestSinkRetryAfter(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		switch requests {
+		case 1:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	var dropped int
+	clock := &sleepClock{ManualClock: mysqllog.NewManualClock(time.Time{})}
+	sink := New(Config{URL: server.URL}, batch.WithSize(1), batch.WithFlushInterval(0), batch.WithClock(clock),
+		batch.WithErrorHandler(func(err error, batch []mysqllog.LogEvent) { dropped += len(batch) }))
+
+	if err := sink.Write(mysqllog.LogEvent{"Statement": "SELECT 1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.waits) != 1 || clock.waits[0] != 7*time.Second {
+		t.Errorf("expected to wait for Retry-After, got %v", clock.waits)
+	}
+	if err := sink.Write(mysqllog.LogEvent{"Statement": "SELECT 2"}); err == nil {
+		t.Error("expected the rejected batch to fail")
+	}
+	if requests != 3 || dropped != 1 {
+		t.Errorf("expected a 400 not to be retried, got %d requests and %d dropped", requests, dropped)
+	}
+}
@@ -0,0 +1,275 @@
+// Package sqlite is a mysqllog Sink writing events into a SQLite
+// database.
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Preetam/mysqllog"
+)
+
+// SchemaVersion is the schema version written by Sink, kept in PRAGMA
+// user_version.
+const SchemaVersion = 2
+
+// DefaultCommitEvery is the number of events per transaction of Sink
+// unless WithCommitEvery is given.
+const DefaultCommitEvery = 1000
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS events (
+	id            INTEGER PRIMARY KEY,
+	event_id      TEXT,
+	ts            TEXT,
+	user          TEXT,
+	host          TEXT,
+	ip            TEXT,
+	db            TEXT,
+	fingerprint   TEXT,
+	query_time    REAL,
+	lock_time     REAL,
+	rows_sent     INTEGER,
+	rows_examined INTEGER,
+	statement     TEXT,
+	extra         TEXT
+)`,
+	`CREATE INDEX IF NOT EXISTS events_fingerprint ON events (fingerprint)`,
+	`CREATE INDEX IF NOT EXISTS events_ts ON events (ts)`,
+	`CREATE TABLE IF NOT EXISTS fingerprints (
+	fingerprint   TEXT PRIMARY KEY,
+	count         INTEGER,
+	total_time    REAL,
+	min_time      REAL,
+	max_time      REAL,
+	rows_examined INTEGER,
+	sample        TEXT
+)`,
+}
+
+// migrations upgrade the schema of a database from the version before
+// theirs.
+var migrations = map[int][]string{
+	2: {`ALTER TABLE events ADD COLUMN event_id TEXT`},
+}
+
+// indexes are created once the tables are up to date.
+var indexes = []string{
+	`CREATE UNIQUE INDEX IF NOT EXISTS events_event_id ON events (event_id)`,
+}
+
+// columns are the event keys stored in their own columns of the
+// events table; the rest are stored as JSON in extra.
+var columns = map[string]bool{
+	"Timestamp": true, "User": true, "Host": true, "IP": true, "Database": true,
+	"Query_time": true, "Lock_time": true, "Rows_sent": true, "Rows_examined": true,
+	"Statement": true, "EventID": true,
+}
+
+// Option configures a Sink.
+type Option func(*Sink)
+
+// WithCommitEvery commits the transaction inserting events every n events.
+func WithCommitEvery(n int) Option {
+	return func(s *Sink) {
+		s.commitEvery = n
+	}
+}
+
+// WithFingerprintsTable also fills the fingerprints table with a summary
+// per fingerprint when the sink is closed, adding to the rows already
+// there.
+func WithFingerprintsTable() Option {
+	return func(s *Sink) {
+		s.aggregator = mysqllog.NewAggregator(mysqllog.WithRollups())
+	}
+}
+
+// Sink is a mysqllog.Sink writing events into a SQLite database for ad hoc
+// analysis with SQL. It works with any SQLite driver registered with
+// database/sql. Timestamps are stored in UTC as "YYYY-MM-DD HH:MM:SS.SSSSSS",
+// which SQLite's date functions understand, attributes without a column
+// are stored as a JSON object in "extra", and "fingerprint" holds the
+// mysqllog.Fingerprint of the statement. "event_id" holds the
+// mysqllog.EventID of the event and is unique: an event written again,
+// such as when a file is processed again after a crash, is skipped.
+type Sink struct {
+	db          *sql.DB
+	commitEvery int
+	aggregator  *mysqllog.Aggregator
+
+	tx      *sql.Tx
+	insert  *sql.Stmt
+	pending int
+}
+
+// New creates or upgrades the schema in db and returns a sink
+// writing to it. It fails if db has a newer schema than SchemaVersion.
+func New(db *sql.DB, opts ...Option) (*Sink, error) {
+	s := &Sink{db: db, commitEvery: DefaultCommitEvery}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.commitEvery <= 0 {
+		s.commitEvery = 1
+	}
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return nil, err
+	}
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("mysqllog: database schema version %d is newer than %d", version, SchemaVersion)
+	}
+	stmts := schema
+	if version > 0 {
+		// The tables are there, as of version.
+		for v := version + 1; v <= SchemaVersion; v++ {
+			stmts = append(stmts, migrations[v]...)
+		}
+	}
+	for _, stmt := range append(stmts[:len(stmts):len(stmts)], indexes...) {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+	if version < SchemaVersion {
+		if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion)); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Write inserts e, committing every WithCommitEvery events.
+func (s *Sink) Write(e mysqllog.LogEvent) error {
+	if s.tx == nil {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		insert, err := tx.Prepare(`INSERT INTO events (event_id, ts, user, host, ip, db, fingerprint, query_time, lock_time,
+	rows_sent, rows_examined, statement, extra) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (event_id) DO NOTHING`)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		s.tx, s.insert = tx, insert
+	}
+
+	extra := map[string]interface{}{}
+	for k, v := range e {
+		if !columns[k] {
+			extra[k] = v
+		}
+	}
+	var extraJSON interface{}
+	if len(extra) > 0 {
+		b, err := json.Marshal(extra)
+		if err != nil {
+			return err
+		}
+		extraJSON = string(b)
+	}
+	var ts interface{}
+	if t, ok := mysqllog.EventTime(e); ok {
+		ts = t.UTC().Format("2006-01-02 15:04:05.000000")
+	}
+	var id interface{}
+	if eventID := mysqllog.EventID(e); eventID != "" {
+		id = eventID
+	}
+	statement, _ := e["Statement"].(string)
+	result, err := s.insert.Exec(id, ts, sqlValue(e["User"]), sqlValue(e["Host"]), sqlValue(e["IP"]), sqlValue(e["Database"]),
+		mysqllog.Fingerprint(statement), sqlValue(e["Query_time"]), sqlValue(e["Lock_time"]),
+		sqlValue(e["Rows_sent"]), sqlValue(e["Rows_examined"]), statement, extraJSON)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		// Written before.
+		return nil
+	}
+	if s.aggregator != nil {
+		s.aggregator.Add(e)
+	}
+	s.pending++
+	if s.pending >= s.commitEvery {
+		return s.commit()
+	}
+	return nil
+}
+
+// WriteBatch inserts events and commits them, with any pending ones, for
+// mysqllog.WithAckSink. The fingerprints table is still only written
+// on Close.
+func (s *Sink) WriteBatch(events []mysqllog.LogEvent) error {
+	for _, e := range events {
+		if err := s.Write(e); err != nil {
+			return err
+		}
+	}
+	return s.commit()
+}
+
+// sqlValue returns v if it's a type the database accepts, or nil.
+func sqlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string, float64, int64, bool:
+		return v
+	case time.Time:
+		return v.UTC().Format("2006-01-02 15:04:05.000000")
+	}
+	return nil
+}
+
+func (s *Sink) commit() error {
+	if s.tx == nil {
+		return nil
+	}
+	s.insert.Close()
+	err := s.tx.Commit()
+	s.tx, s.insert, s.pending = nil, nil, 0
+	return err
+}
+
+// Close commits pending events and writes the fingerprints table. It
+// doesn't close db.
+func (s *Sink) Close() error {
+	if err := s.commit(); err != nil {
+		return err
+	}
+	if s.aggregator == nil {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	upsert, err := tx.Prepare(`INSERT INTO fingerprints (fingerprint, count, total_time, min_time, max_time, rows_examined, sample)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (fingerprint) DO UPDATE SET
+	count = count + excluded.count,
+	total_time = total_time + excluded.total_time,
+	min_time = min(min_time, excluded.min_time),
+	max_time = max(max_time, excluded.max_time),
+	rows_examined = rows_examined + excluded.rows_examined,
+	sample = CASE WHEN excluded.max_time > max_time THEN excluded.sample ELSE sample END`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer upsert.Close()
+	for _, r := range s.aggregator.Results() {
+		sample, _ := r.Sample["Statement"].(string)
+		if _, err := upsert.Exec(r.Fingerprint, r.Count, r.TotalTime, r.MinTime, r.MaxTime, r.RowsExamined, sample); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	s.aggregator = mysqllog.NewAggregator(mysqllog.WithRollups())
+	return tx.Commit()
+}
@@ -0,0 +1,129 @@
+//go:build sqlite
+// +build sqlite
+
+package sqlite
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/Preetam/mysqllog"
+	_ "modernc.org/sqlite"
+)
+
+// TestSink needs the modernc.org/sqlite driver; run it with
+// go test -tags sqlite.
+func TestSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "slow.db")
+	var events []mysqllog.LogEvent
+	p := mysqllog.NewParser(mysqllog.WithLabels(map[string]string{"env": "test"}))
+	err = mysqllog.ParseFile("../../_test/rds.txt", func(e mysqllog.LogEvent) {
+		events = append(events, e)
+	}, mysqllog.WithParser(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write the log twice, reopening the file, to check the schema is
+	// reused and the fingerprints table accumulates.
+	for i := 0; i < 2; i++ {
+		db, err := sql.Open("sqlite", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sink, err := New(db, WithCommitEvery(50), WithFingerprintsTable())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range events {
+			if err := sink.Write(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var count, version, selects int
+	var labels string
+	if err := db.QueryRow("SELECT count(*), max(json_extract(extra, '$.Labels.env')) FROM events").Scan(&count, &labels); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2*len(events) || labels != "test" {
+		t.Errorf("expected %d events labelled test, got %d %q", 2*len(events), count, labels)
+	}
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil || version != SchemaVersion {
+		t.Errorf("expected schema version %d, got %d (%v)", SchemaVersion, version, err)
+	}
+	if err := db.QueryRow("SELECT count FROM fingerprints WHERE fingerprint = 'select ?'").Scan(&selects); err != nil {
+		t.Fatal(err)
+	}
+	var expected int
+	for _, e := range events {
+		if mysqllog.Fingerprint(e["Statement"].(string)) == "select ?" {
+			expected += 2
+		}
+	}
+	if selects != expected {
+		t.Errorf("expected %d calls of select ?, got %d", expected, selects)
+	}
+}
+
+func TestSinkEventID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "slow.db")
+	var events []mysqllog.LogEvent
+	err = mysqllog.ParseFile("../../_test/rds.txt", func(e mysqllog.LogEvent) {
+		events = append(events, e)
+	}, mysqllog.WithParser(mysqllog.NewParser(mysqllog.WithOffsets())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Writing the log again doesn't duplicate its events.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i := 0; i < 2; i++ {
+		sink, err := New(db, WithFingerprintsTable())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range events {
+			if err := sink.Write(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var count, calls int
+	if err := db.QueryRow("SELECT count(DISTINCT event_id), count(*) FROM events").Scan(&count, &calls); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(events) || calls != len(events) {
+		t.Errorf("expected %d events, got %d with %d distinct IDs", len(events), calls, count)
+	}
+	if err := db.QueryRow("SELECT sum(count) FROM fingerprints").Scan(&calls); err != nil || calls != len(events) {
+		t.Errorf("expected the fingerprints of %d events, got %d (%v)", len(events), calls, err)
+	}
+}
@@ -0,0 +1,244 @@
+// Package statsd is a mysqllog Sink sending metrics to StatsD and
+// DogStatsD servers.
+package statsd
+
+import (
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Preetam/mysqllog"
+	"github.com/Preetam/mysqllog/sinks/batch"
+)
+
+// Defaults for a Sink.
+const (
+	DefaultPrefix = "slowlog"
+	// DefaultMaxTagValues is the number of distinct values a tag
+	// key may have before new ones are sent as "other".
+	DefaultMaxTagValues = 100
+	// DefaultPacketSize fits a UDP datagram in a 1500 byte MTU.
+	DefaultPacketSize = 1432
+	// DefaultUnixPacketSize is the size of datagrams over a Unix
+	// socket.
+	DefaultUnixPacketSize = 8192
+)
+
+// Config configures a Sink.
+type Config struct {
+	// Address is the "host:port" of a StatsD or DogStatsD server over
+	// UDP, such as "127.0.0.1:8125", or "unix://" and the path of a
+	// DogStatsD Unix socket, such as
+	// "unix:///var/run/datadog/dsd.socket".
+	Address string
+	// Prefix starts the metric names, DefaultPrefix if empty.
+	Prefix string
+	// Tags are added to every metric, such as "env:prod".
+	Tags []string
+	// MaxTagValues limits the distinct values of each of the user, db,
+	// fingerprint and severity tags; DefaultMaxTagValues if zero.
+	// Later values are sent as "other", so an unexpected spread of
+	// users or statements doesn't create unbounded metrics.
+	MaxTagValues int
+	// SampleRate sends the timing and histogram of that fraction of the
+	// events, with the rate so the server scales them back up. Zero or
+	// one sends all of them. Counters aren't sampled.
+	SampleRate float64
+	// MaxPacketSize is the largest datagram, DefaultPacketSize or
+	// DefaultUnixPacketSize if zero.
+	MaxPacketSize int
+}
+
+// Sink is a mysqllog.Sink sending metrics in the DogStatsD format, which
+// plain StatsD servers read without the tags. For each event, it sends
+// Query_time in milliseconds as the "<prefix>.query_time" timing and
+// Rows_examined as the "<prefix>.rows_examined" histogram, tagged with
+// the user, db, fingerprint (its mysqllog.FingerprintTag) and
+// severity of the event, when it has them. Each batch also sends the
+// "<prefix>.events" and "<prefix>.parse_errors" counters.
+//
+// Metrics are batched as with the other batching sinks (see
+// batch.Option), and sent in as few datagrams as fit in MaxPacketSize.
+// Batches aren't retried unless batch.WithRetries is given, since a failed
+// batch may have been sent in part.
+type Sink struct {
+	config Config
+	conn   net.Conn
+	batch  *batch.Batcher
+	random func() float64
+	tags   string
+	// values are the tag values seen for each tag key.
+	values      map[string]map[string]bool
+	parseErrors int64
+}
+
+// New returns a Sink sending to config.Address.
+func New(config Config, opts ...batch.Option) (*Sink, error) {
+	network, address := "udp", config.Address
+	if strings.HasPrefix(address, "unix://") {
+		network, address = "unixgram", address[len("unix://"):]
+		if config.MaxPacketSize <= 0 {
+			config.MaxPacketSize = DefaultUnixPacketSize
+		}
+	}
+	if config.MaxPacketSize <= 0 {
+		config.MaxPacketSize = DefaultPacketSize
+	}
+	if config.Prefix == "" {
+		config.Prefix = DefaultPrefix
+	}
+	if config.MaxTagValues <= 0 {
+		config.MaxTagValues = DefaultMaxTagValues
+	}
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sink{
+		config: config,
+		conn:   conn,
+		random: rand.Float64,
+		values: map[string]map[string]bool{},
+	}
+	for _, tag := range config.Tags {
+		s.tags += "," + escape(tag)
+	}
+	s.batch = batch.New(s.send, append([]batch.Option{batch.WithRetries(0, 0)}, opts...)...)
+	return s, nil
+}
+
+// Write buffers e, sending the batch if it's full.
+func (s *Sink) Write(e mysqllog.LogEvent) error {
+	return s.batch.Add(e)
+}
+
+// AddParseErrors adds n to the parse_errors counter sent with the next
+// batch, such as the number of Parser.Errors.
+func (s *Sink) AddParseErrors(n int) {
+	atomic.AddInt64(&s.parseErrors, int64(n))
+}
+
+// Close sends any buffered events and closes the connection.
+func (s *Sink) Close() error {
+	err := s.batch.Close()
+	if cerr := s.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// send writes the metrics of events in datagrams.
+func (s *Sink) send(events []mysqllog.LogEvent) error {
+	p := &packer{conn: s.conn, max: s.config.MaxPacketSize}
+	rate := s.config.SampleRate
+	sampled := rate > 0 && rate < 1
+	for _, e := range events {
+		if sampled && s.random() >= rate {
+			continue
+		}
+		suffix := ""
+		if sampled {
+			suffix = "|@" + strconv.FormatFloat(rate, 'f', -1, 64)
+		}
+		suffix += s.eventTags(e)
+		if queryTime, ok := e.Float64("Query_time"); ok {
+			p.add(s.config.Prefix + ".query_time:" + strconv.FormatFloat(queryTime*1000, 'f', -1, 64) + "|ms" + suffix)
+		}
+		if rows, ok := e.Int64("Rows_examined"); ok {
+			p.add(s.config.Prefix + ".rows_examined:" + strconv.FormatInt(rows, 10) + "|h" + suffix)
+		}
+	}
+	p.add(s.config.Prefix + ".events:" + strconv.Itoa(len(events)) + "|c" + s.constantTags())
+	if n := atomic.SwapInt64(&s.parseErrors, 0); n > 0 {
+		p.add(s.config.Prefix + ".parse_errors:" + strconv.FormatInt(n, 10) + "|c" + s.constantTags())
+	}
+	return p.flush()
+}
+
+// constantTags returns the tags of config.Tags in the wire format.
+func (s *Sink) constantTags() string {
+	if s.tags == "" {
+		return ""
+	}
+	return "|#" + s.tags[1:]
+}
+
+// eventTags returns the tags of e and config.Tags in the wire format.
+func (s *Sink) eventTags(e mysqllog.LogEvent) string {
+	var b strings.Builder
+	add := func(key, value string) {
+		if value == "" {
+			return
+		}
+		b.WriteString(",")
+		b.WriteString(key)
+		b.WriteString(":")
+		b.WriteString(s.limit(key, escape(value)))
+	}
+	flat := e.Flatten(mysqllog.FlattenOptions{})
+	add("user", flat["User"])
+	add("db", flat["Database"])
+	add("fingerprint", mysqllog.FingerprintTag(e))
+	add("severity", flat["Severity"])
+	b.WriteString(s.tags)
+	if b.Len() == 0 {
+		return ""
+	}
+	return "|#" + b.String()[1:]
+}
+
+// limit returns value, or "other" if key already has MaxTagValues
+// other values.
+func (s *Sink) limit(key, value string) string {
+	values := s.values[key]
+	if values == nil {
+		values = map[string]bool{}
+		s.values[key] = values
+	}
+	if !values[value] {
+		if len(values) >= s.config.MaxTagValues {
+			return "other"
+		}
+		values[value] = true
+	}
+	return value
+}
+
+var escaper = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_", "\r", "_")
+
+// escape replaces the characters with a meaning in the wire
+// format.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// packer writes metric lines in datagrams of at most max bytes.
+type packer struct {
+	conn net.Conn
+	max  int
+	buf  []byte
+	err  error
+}
+
+func (p *packer) add(line string) {
+	if len(p.buf) > 0 && len(p.buf)+1+len(line) > p.max {
+		p.flush()
+	}
+	if len(p.buf) > 0 {
+		p.buf = append(p.buf, '\n')
+	}
+	p.buf = append(p.buf, line...)
+}
+
+// flush writes the pending lines, returning the first error.
+func (p *packer) flush() error {
+	if len(p.buf) > 0 {
+		if _, err := p.conn.Write(p.buf); err != nil && p.err == nil {
+			p.err = err
+		}
+		p.buf = p.buf[:0]
+	}
+	return p.err
+}
@@ -0,0 +1,103 @@
+package statsd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Preetam/mysqllog"
+	"github.com/Preetam/mysqllog/sinks/batch"
+)
+
+// listen returns a UDP listener and a function reading the lines
+// of the next datagram.
+func listen(t *testing.T) (net.PacketConn, func() []string) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, func() []string {
+		buf := make([]byte, 65536)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(string(buf[:n]), "\n")
+	}
+}
+
+func TestSink(t *testing.T) {
+	conn, read := listen(t)
+	defer conn.Close()
+	sink, err := New(Config{
+		Address: conn.LocalAddr().String(),
+		Tags:    []string{"env:prod"},
+	}, batch.WithSize(2), batch.WithFlushInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.AddParseErrors(3)
+	sink.Write(mysqllog.LogEvent{"User": "app", "Database": "shop", "Severity": mysqllog.SeverityWarn,
+		"Query_time": 1.5, "Rows_examined": int64(120), "Statement": "SELECT * FROM t WHERE id = 1"})
+	sink.Write(mysqllog.LogEvent{"User": "etl|1", "Query_time": 0.25})
+
+	fingerprint := strings.ToLower(mysqllog.Checksum("select * from t where id = ?")[:8])
+	expected := []string{
+		"slowlog.query_time:1500|ms|#user:app,db:shop,fingerprint:" + fingerprint + ",severity:warn,env:prod",
+		"slowlog.rows_examined:120|h|#user:app,db:shop,fingerprint:" + fingerprint + ",severity:warn,env:prod",
+		"slowlog.query_time:250|ms|#user:etl_1,env:prod",
+		"slowlog.events:2|c|#env:prod",
+		"slowlog.parse_errors:3|c|#env:prod",
+	}
+	lines := read()
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+
+	// The parse errors were sent and reset.
+	sink.Write(mysqllog.LogEvent{})
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if lines := read(); len(lines) != 1 || lines[0] != "slowlog.events:1|c|#env:prod" {
+		t.Errorf("unexpected lines %q", lines)
+	}
+}
+
+func TestSinkLimits(t *testing.T) {
+	conn, read := listen(t)
+	defer conn.Close()
+	sink, err := New(Config{
+		Address:       conn.LocalAddr().String(),
+		Prefix:        "mysql",
+		MaxTagValues:  2,
+		SampleRate:    0.5,
+		MaxPacketSize: 80,
+	}, batch.WithSize(4), batch.WithFlushInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := []float64{0.1, 0.7, 0.2, 0.3}
+	sink.random = func() float64 {
+		r := samples[0]
+		samples = samples[1:]
+		return r
+	}
+	for _, user := range []string{"a", "b", "c", "d"} {
+		sink.Write(mysqllog.LogEvent{"User": user, "Query_time": 1.0})
+	}
+	sink.Close()
+
+	// "b" isn't sampled, and "d" is past the limit of 2 users.
+	for _, expected := range [][]string{
+		{"mysql.query_time:1000|ms|@0.5|#user:a", "mysql.query_time:1000|ms|@0.5|#user:c"},
+		{"mysql.query_time:1000|ms|@0.5|#user:other", "mysql.events:4|c"},
+	} {
+		lines := read()
+		if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("expected %q, got %q", expected, lines)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package mysqllog
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// Defaults for NewSketch.
+const (
+	DefaultSketchAccuracy = 0.01
+	DefaultSketchBins     = 2048
+)
+
+// sketchMinValue is the smallest value a Sketch distinguishes from zero.
+const sketchMinValue = 1e-9
+
+// ErrSketchMismatch is returned when merging sketches with different
+// accuracies.
+var ErrSketchMismatch = errors.New("mysqllog: sketches have different accuracies")
+
+// Sketch is a streaming quantile sketch of non-negative values such as
+// Query_time, in the style of DDSketch. Values are counted in buckets
+// growing geometrically, so every quantile is within the relative
+// accuracy of an actual value: with an accuracy of 0.01, a p95 of 2s is
+// reported between 1.98s and 2.02s. Values of 1ns or less count as zero.
+//
+// Its size depends on the spread of the values rather than their
+// number; query times from 1ms to 10s at the default accuracy take
+// about 460 buckets. At most DefaultSketchBins buckets are kept, after
+// which the lowest buckets are merged, losing accuracy only on the
+// lowest quantiles.
+type Sketch struct {
+	accuracy float64
+	logGamma float64
+	bins     map[int]int64
+	zero     int64
+	count    int64
+	min, max float64
+}
+
+// NewSketch returns an empty Sketch with the given relative accuracy,
+// between 0 and 1 exclusive. Other values use DefaultSketchAccuracy.
+func NewSketch(accuracy float64) *Sketch {
+	if accuracy <= 0 || accuracy >= 1 {
+		accuracy = DefaultSketchAccuracy
+	}
+	return &Sketch{
+		accuracy: accuracy,
+		logGamma: math.Log((1 + accuracy) / (1 - accuracy)),
+		bins:     map[int]int64{},
+	}
+}
+
+// RelativeAccuracy returns the accuracy the sketch was created with.
+func (s *Sketch) RelativeAccuracy() float64 {
+	return s.accuracy
+}
+
+// Count returns the number of values added.
+func (s *Sketch) Count() int64 {
+	return s.count
+}
+
+// Add adds a value to the sketch.
+func (s *Sketch) Add(v float64) {
+	if s.count == 0 || v < s.min {
+		s.min = v
+	}
+	if s.count == 0 || v > s.max {
+		s.max = v
+	}
+	s.count++
+	if v <= sketchMinValue {
+		s.zero++
+		return
+	}
+	s.bins[int(math.Ceil(math.Log(v)/s.logGamma))]++
+	s.collapse()
+}
+
+// Quantile returns the approximate q-quantile, for q from 0 to 1, or 0
+// if the sketch is empty.
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(s.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank <= s.zero {
+		return s.clamp(0)
+	}
+	seen := s.zero
+	indexes := s.indexes()
+	for _, i := range indexes {
+		seen += s.bins[i]
+		if seen >= rank {
+			return s.clamp(s.value(i))
+		}
+	}
+	return s.max
+}
+
+// Merge adds the values of other to s.
+func (s *Sketch) Merge(other *Sketch) error {
+	if other == nil || other.count == 0 {
+		return nil
+	}
+	if other.accuracy != s.accuracy {
+		return ErrSketchMismatch
+	}
+	if s.count == 0 || other.min < s.min {
+		s.min = other.min
+	}
+	if s.count == 0 || other.max > s.max {
+		s.max = other.max
+	}
+	s.count += other.count
+	s.zero += other.zero
+	for i, n := range other.bins {
+		s.bins[i] += n
+	}
+	s.collapse()
+	return nil
+}
+
+// Clone returns a copy of s.
+func (s *Sketch) Clone() *Sketch {
+	c := *s
+	c.bins = make(map[int]int64, len(s.bins))
+	for i, n := range s.bins {
+		c.bins[i] = n
+	}
+	return &c
+}
+
+// value returns the representative value of bucket i, within the
+// relative accuracy of every value in it.
+func (s *Sketch) value(i int) float64 {
+	gamma := math.Exp(s.logGamma)
+	return 2 * math.Pow(gamma, float64(i)) / (1 + gamma)
+}
+
+func (s *Sketch) clamp(v float64) float64 {
+	return math.Max(s.min, math.Min(s.max, v))
+}
+
+func (s *Sketch) indexes() []int {
+	indexes := make([]int, 0, len(s.bins))
+	for i := range s.bins {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// collapse merges the lowest buckets until at most DefaultSketchBins remain.
+func (s *Sketch) collapse() {
+	if len(s.bins) <= DefaultSketchBins {
+		return
+	}
+	indexes := s.indexes()
+	excess := len(indexes) - DefaultSketchBins
+	into := indexes[excess]
+	for _, i := range indexes[:excess] {
+		s.bins[into] += s.bins[i]
+		delete(s.bins, i)
+	}
+}
@@ -0,0 +1,119 @@
+package mysqllog
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSketchQuantiles(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	type TestCase struct {
+		Name   string
+		Sample func() float64
+	}
+	for _, tc := range []TestCase{
+		{"uniform", func() float64 { return r.Float64() * 10 }},
+		{"exponential", func() float64 { return r.ExpFloat64() / 10 }},
+		{"lognormal", func() float64 { return math.Exp(r.NormFloat64()*2 - 5) }},
+		{"bimodal", func() float64 {
+			if r.Intn(10) == 0 {
+				return 5 + r.Float64()
+			}
+			return 0.001 + r.Float64()/1000
+		}},
+	} {
+		s := NewSketch(0.01)
+		values := make([]float64, 100000)
+		for i := range values {
+			values[i] = tc.Sample()
+			s.Add(values[i])
+		}
+		sort.Float64s(values)
+		for _, q := range []float64{0, 0.5, 0.9, 0.95, 0.99, 0.999, 1} {
+			exact := quantile(values, q)
+			if got := s.Quantile(q); math.Abs(got-exact) > 0.01*exact+1e-12 {
+				t.Errorf("%s: q%v expected %v within 1%%, got %v", tc.Name, q, exact, got)
+			}
+		}
+		if n := len(s.bins); n > 2000 {
+			t.Errorf("%s: expected a bounded sketch, got %d buckets", tc.Name, n)
+		}
+	}
+}
+
+func TestSketchMerge(t *testing.T) {
+	a, b, all := NewSketch(0.02), NewSketch(0.02), NewSketch(0.02)
+	for i := 1; i <= 1000; i++ {
+		v := float64(i) / 100
+		all.Add(v)
+		if i%2 == 0 {
+			a.Add(v)
+		} else {
+			b.Add(v)
+		}
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if a.Count() != 1000 || a.Quantile(0.95) != all.Quantile(0.95) {
+		t.Errorf("expected merged p95 %v, got %v", all.Quantile(0.95), a.Quantile(0.95))
+	}
+	if err := a.Merge(NewSketch(0.01)); err != nil {
+		t.Errorf("expected merging an empty sketch to succeed, got %v", err)
+	}
+	c := NewSketch(0.01)
+	c.Add(1)
+	if err := a.Merge(c); err != ErrSketchMismatch {
+		t.Errorf("expected ErrSketchMismatch, got %v", err)
+	}
+}
+
+func TestAggregatorMerge(t *testing.T) {
+	events := parseAll(&Parser{}, generateEvents(1000, 3))
+	all, a, b := NewAggregator(), NewAggregator(), NewAggregator()
+	for i, e := range events {
+		all.Add(e)
+		if i < 400 {
+			a.Add(e)
+		} else {
+			b.Add(e)
+		}
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, merged := all.Results(), a.Results()
+	if len(merged) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(merged))
+	}
+	for i := range expected {
+		e, m := expected[i], merged[i]
+		if m.Fingerprint != e.Fingerprint || m.Count != e.Count || m.RowsExamined != e.RowsExamined ||
+			m.MaxTime != e.MaxTime || !m.FirstSeen.Equal(e.FirstSeen) || !m.LastSeen.Equal(e.LastSeen) ||
+			m.Quantile(0.99) != e.Quantile(0.99) || math.Abs(m.TotalTime-e.TotalTime) > 1e-9 {
+			t.Errorf("merged stats differ for %q: expected %+v, got %+v", e.Fingerprint, e, m)
+		}
+	}
+	if r := a.Rollup("User"); len(r) != 1 || r[0].Count != 1000 {
+		t.Errorf("unexpected rollups %+v", r)
+	}
+}
+
+// quantile returns the q-quantile of sorted values using the
+// nearest-rank method.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,113 @@
+//go:build go1.21
+// +build go1.21
+
+package mysqllog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// DefaultSlogStatementLength is the number of characters of the
+// statement kept by LogEvent.LogValue.
+const DefaultSlogStatementLength = 1024
+
+// LogValue implements slog.LogValuer, so an event can be passed to a
+// slog.Logger as a single attribute. See SlogValue.
+func (e LogEvent) LogValue() slog.Value {
+	return e.SlogValue(DefaultSlogStatementLength)
+}
+
+// SlogValue returns the event as a slog group with one attribute per
+// key, in the order of LogEvent.Keys. Numbers, bools and times keep their kinds, with
+// Timestamp a time in whatever format the Parser wrote it, nested
+// maps such as Labels become groups, and the statement is truncated to
+// maxStatement characters, or kept whole if maxStatement is 0 or less.
+func (e LogEvent) SlogValue(maxStatement int) slog.Value {
+	keys := e.Keys()
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		v := e[k]
+		if s, ok := v.(string); ok && k == "Statement" && maxStatement > 0 {
+			v = Truncate(s, maxStatement)
+		}
+		if k == "Timestamp" {
+			if ts, ok := EventTime(e); ok {
+				v = ts
+			}
+		}
+		attrs = append(attrs, slog.Attr{Key: k, Value: slogValue(v)})
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func slogValue(v interface{}) slog.Value {
+	switch v := v.(type) {
+	case string:
+		return slog.StringValue(v)
+	case float64:
+		return slog.Float64Value(v)
+	case int64:
+		return slog.Int64Value(v)
+	case bool:
+		return slog.BoolValue(v)
+	case time.Time:
+		return slog.TimeValue(v)
+	case LogEvent:
+		return v.SlogValue(0)
+	case map[string]interface{}:
+		return LogEvent(v).SlogValue(0)
+	case map[string]string:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		attrs := make([]slog.Attr, 0, len(keys))
+		for _, k := range keys {
+			attrs = append(attrs, slog.String(k, v[k]))
+		}
+		return slog.GroupValue(attrs...)
+	}
+	return slog.AnyValue(v)
+}
+
+type slogSink struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// SlogSink returns a Sink logging each event to logger at level, as the
+// attribute "event" of a "slow query" record.
+func SlogSink(logger *slog.Logger, level slog.Level) Sink {
+	return &slogSink{logger: logger, level: level}
+}
+
+func (s *slogSink) Write(e LogEvent) error {
+	s.logger.LogAttrs(context.Background(), s.level, "slow query", slog.Any("event", e))
+	return nil
+}
+
+func (s *slogSink) Close() error {
+	return nil
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// SlogLogger returns a Logger for WithLogger writing to logger at debug
+// level. Messages aren't formatted unless debug is enabled.
+func SlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger: logger}
+}
+
+func (l slogLogger) Printf(format string, v ...interface{}) {
+	if !l.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	l.logger.Debug(fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,110 @@
+//go:build go1.21
+// +build go1.21
+
+package mysqllog
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingHandler is a slog.Handler keeping the records it handles.
+type recordingHandler struct {
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.records = append(h.records, r)
+	return nil
+}
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler      { return h }
+
+func TestSlogSink(t *testing.T) {
+	h := &recordingHandler{}
+	sink := SlogSink(slog.New(h), slog.LevelWarn)
+	sink.Write(LogEvent{
+		"Statement":     strings.Repeat("x", 2000),
+		"Query_time":    1.5,
+		"Rows_examined": int64(100),
+		"Full_scan":     true,
+		"Timestamp":     time.Unix(1514083320, 0).Format(DefaultTimestampLayout),
+		"User":          "app",
+		"Labels":        map[string]string{"host": "db1"},
+	})
+	if len(h.records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(h.records))
+	}
+	r := h.records[0]
+	if r.Level != slog.LevelWarn || r.Message != "slow query" {
+		t.Errorf("unexpected record %v %q", r.Level, r.Message)
+	}
+
+	var event slog.Value
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "event" {
+			event = a.Value.Resolve()
+		}
+		return true
+	})
+	if event.Kind() != slog.KindGroup {
+		t.Fatalf("expected a group, got %v", event.Kind())
+	}
+	kinds := map[string]slog.Kind{}
+	var keys []string
+	for _, a := range event.Group() {
+		keys = append(keys, a.Key)
+		kinds[a.Key] = a.Value.Kind()
+		if a.Key == "Statement" && len(a.Value.String()) != DefaultSlogStatementLength {
+			t.Errorf("expected a truncated statement, got %d characters", len(a.Value.String()))
+		}
+	}
+	if strings.Join(keys, ",") != "Timestamp,User,Query_time,Rows_examined,Statement,Full_scan,Labels" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	for key, kind := range map[string]slog.Kind{
+		"Full_scan":     slog.KindBool,
+		"Labels":        slog.KindGroup,
+		"Query_time":    slog.KindFloat64,
+		"Rows_examined": slog.KindInt64,
+		"Statement":     slog.KindString,
+		"Timestamp":     slog.KindTime,
+	} {
+		if kinds[key] != kind {
+			t.Errorf("%s: expected kind %v, got %v", key, kind, kinds[key])
+		}
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	h := &recordingHandler{}
+	parseAll(NewParser(WithLogger(SlogLogger(slog.New(h)))), loggerContent)
+	if len(h.records) != len(loggerMessages) {
+		t.Fatalf("expected %d records, got %d", len(loggerMessages), len(h.records))
+	}
+	for i, r := range h.records {
+		if r.Level != slog.LevelDebug || r.Message != loggerMessages[i] {
+			t.Errorf("expected %q at debug level, got %q at %v", loggerMessages[i], r.Message, r.Level)
+		}
+	}
+
+	// Nothing is logged above debug level.
+	h = &recordingHandler{}
+	disabled := slog.New(levelHandler{h, slog.LevelInfo})
+	parseAll(NewParser(WithLogger(SlogLogger(disabled))), loggerContent)
+	if len(h.records) != 0 {
+		t.Errorf("expected no records, got %d", len(h.records))
+	}
+}
+
+// levelHandler is a recordingHandler enabled from a level.
+type levelHandler struct {
+	*recordingHandler
+	level slog.Level
+}
+
+func (h levelHandler) Enabled(_ context.Context, level slog.Level) bool { return level >= h.level }
@@ -0,0 +1,207 @@
+package mysqllog
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteSlowLog writes e in the MySQL slow query log format, so filtered
+// or redacted events can be read by this package or other tools such as
+// pt-query-digest. It writes the "# Time:", "# User@Host:" and
+// "# Query_time:" header lines, a line for the other attributes the
+// Parser knows, such as Rows_affected, then "use", "SET timestamp" and
+// the statement. Attributes added after parsing, such as Labels or
+// Verb, aren't written. Parsing the output gives back an equal event,
+// as long as no line of the statement starts with "#".
+//
+// Numbers parsed with WithRawNumbers are written as they were logged.
+// Events parsed with WithFullStatementText have their "ExecutableText"
+// written in place of "use", "SET timestamp" and the statement, which
+// reproduces the entry as it was logged, apart from the header lines.
+// The "Trailer" of WithTrailer is written after the statement.
+func WriteSlowLog(w io.Writer, e LogEvent) error {
+	ew := &errWriter{w: w}
+	ts, hasTime := EventTime(e)
+	if hasTime {
+		ew.printf("# Time: %s\n", ts.UTC().Format("2006-01-02T15:04:05.000000Z"))
+	}
+
+	user, _ := e["User"].(string)
+	host, _ := e["Host"].(string)
+	ip, _ := e["IP"].(string)
+	if port, ok := e.Int64("Port"); ok {
+		// Goes back where parsing found it: after the IP if there's one.
+		switch {
+		case ip != "" && strings.Contains(ip, ":"):
+			ip = "[" + ip + "]:" + strconv.FormatInt(port, 10)
+		case ip != "":
+			ip += ":" + strconv.FormatInt(port, 10)
+		case host != "":
+			host += ":" + strconv.FormatInt(port, 10)
+		}
+	}
+	if user != "" || host != "" || ip != "" {
+		id, _ := e.Int64("Id")
+		ew.printf("# User@Host: %s[%s] @ %s [%s]  Id: %d\n", user, user, host, ip, id)
+	}
+
+	var standard []string
+	for _, key := range []string{"Query_time", "Lock_time", "Rows_sent", "Rows_examined"} {
+		if v, ok := e[key]; ok {
+			standard = append(standard, key+": "+slowLogAttribute(e, key, v))
+		}
+	}
+	if len(standard) > 0 {
+		ew.printf("# %s\n", strings.Join(standard, "  "))
+	}
+
+	var extra []string
+	for key := range attributeTypes {
+		switch key {
+		case "Query_time", "Lock_time", "Rows_sent", "Rows_examined":
+			continue
+		}
+		if _, ok := e[key]; ok {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+	for i := range extra {
+		extra[i] += ": " + slowLogAttribute(e, extra[i], e[extra[i]])
+	}
+	if len(extra) > 0 {
+		ew.printf("# %s\n", strings.Join(extra, "  "))
+	}
+
+	if text, ok := e["ExecutableText"].(string); ok && text != "" {
+		ew.printf("%s\n", text)
+	} else {
+		if db, ok := e["Database"].(string); ok && db != "" {
+			ew.printf("use %s;\n", db)
+		}
+		if hasTime {
+			ew.printf("SET timestamp=%d;\n", ts.Unix())
+		}
+		statement, _ := e["Statement"].(string)
+		ew.printf("%s\n", statement)
+	}
+	trailer, _ := e["Trailer"].([]string)
+	for _, line := range trailer {
+		ew.printf("%s\n", line)
+	}
+	return ew.err
+}
+
+// WithFullStatementText sets "ExecutableText" on each event to the
+// lines after its header as logged, such as "use" and every SET line in
+// their original order, followed by the statement, for replaying the
+// entry exactly. "Statement" still has the query alone, so fingerprints
+// and reports are unaffected. WithMetricsOnly drops it.
+func WithFullStatementText() Option {
+	return func(p *Parser) {
+		p.fullText = true
+	}
+}
+
+// WithRawNumbers keeps the text of the numeric attributes that
+// WriteSlowLog wouldn't write back the same, such as "Query_time: 1.5"
+// or "Rows_sent: 007", by key in "RawNumbers", so writing the event
+// gives back the logged values exactly, as for diffing the output with
+// the input. Values changed since parsing are written as usual.
+// WithMetricsOnly drops it.
+func WithRawNumbers() Option {
+	return func(p *Parser) {
+		p.rawNumbers = true
+	}
+}
+
+// keepRawNumber records value, the text of the attribute key parsed as
+// v, in "RawNumbers", with WithRawNumbers, if it's a number that
+// formatSlowLogValue doesn't give back.
+func (p *Parser) keepRawNumber(event LogEvent, key, value string, v interface{}) {
+	if !p.rawNumbers {
+		return
+	}
+	switch v.(type) {
+	case float64, int64, time.Duration:
+	default:
+		return
+	}
+	if formatSlowLogValue(v) == value {
+		return
+	}
+	raw, _ := event["RawNumbers"].(map[string]string)
+	if raw == nil {
+		raw = map[string]string{}
+		event["RawNumbers"] = raw
+	}
+	raw[key] = value
+}
+
+// slowLogAttribute formats v, the value of key in e, preferring its text
+// in "RawNumbers" if that still has the same value.
+func slowLogAttribute(e LogEvent, key string, v interface{}) string {
+	raws, _ := e["RawNumbers"].(map[string]string)
+	if raw, ok := raws[key]; ok {
+		switch v := v.(type) {
+		case float64:
+			if parsed, err := strconv.ParseFloat(raw, 64); err == nil && parsed == v {
+				return raw
+			}
+		case int64:
+			if parsed, err := strconv.ParseInt(raw, 10, 64); err == nil && parsed == v {
+				return raw
+			}
+		case time.Duration:
+			if parsed, err := parseSeconds(raw); err == nil && parsed == v {
+				return raw
+			}
+		}
+	}
+	return formatSlowLogValue(v)
+}
+
+// formatSlowLogValue formats an attribute value as MySQL does: times
+// with microseconds and booleans as Yes or No.
+func formatSlowLogValue(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		s := strconv.FormatFloat(v, 'f', 6, 64)
+		if parsed, _ := strconv.ParseFloat(s, 64); parsed != v {
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return s
+	case bool:
+		if v {
+			return "Yes"
+		}
+		return "No"
+	case time.Time:
+		return strconv.FormatInt(v.Unix(), 10)
+	}
+	return formatValue(v)
+}
+
+// SlowLogWriter is a Sink writing events with WriteSlowLog.
+type SlowLogWriter struct {
+	w *bufio.Writer
+}
+
+// NewSlowLogWriter returns a SlowLogWriter writing to w.
+func NewSlowLogWriter(w io.Writer) *SlowLogWriter {
+	return &SlowLogWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes e.
+func (s *SlowLogWriter) Write(e LogEvent) error {
+	return WriteSlowLog(s.w, e)
+}
+
+// Close flushes buffered output. It doesn't close the underlying writer.
+func (s *SlowLogWriter) Close() error {
+	return s.w.Flush()
+}
@@ -0,0 +1,184 @@
+package mysqllog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteSlowLogRoundTrip(t *testing.T) {
+	for _, path := range []string{"./_test/rds.txt", "./_test/sessions.txt", "./_test/before.txt"} {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, opts := range [][]Option{nil, {WithUnixTimestamps()}} {
+			events := parseAll(NewParser(opts...), string(b))
+			var buf bytes.Buffer
+			w := NewSlowLogWriter(&buf)
+			for _, e := range events {
+				if err := w.Write(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			reparsed := parseAll(NewParser(opts...), buf.String())
+			if len(reparsed) != len(events) {
+				t.Fatalf("%s: expected %d events, got %d", path, len(events), len(reparsed))
+			}
+			for i := range events {
+				if !events[i].Equal(reparsed[i]) {
+					t.Errorf("%s: event %d differs after round trip:\n%s", path, i, strings.Join(Diff(events[i], reparsed[i]), "\n"))
+				}
+			}
+		}
+	}
+}
+
+func TestWriteSlowLog(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteSlowLog(&buf, LogEvent{
+		"User":          "app",
+		"Host":          "web1",
+		"IP":            "10.0.0.1",
+		"Id":            int64(7),
+		"Database":      "shop",
+		"Query_time":    1.5,
+		"Lock_time":     0.0,
+		"Rows_sent":     int64(1),
+		"Rows_examined": int64(100),
+		"Full_scan":     true,
+		"Rows_affected": int64(0),
+		"Timestamp":     int64(1519898400),
+		"Statement":     "SELECT * FROM orders;",
+		"Labels":        map[string]string{"env": "prod"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# Time: 2018-03-01T10:00:00.000000Z
+# User@Host: app[app] @ web1 [10.0.0.1]  Id: 7
+# Query_time: 1.500000  Lock_time: 0.000000  Rows_sent: 1  Rows_examined: 100
+# Full_scan: Yes  Rows_affected: 0
+use shop;
+SET timestamp=1519898400;
+SELECT * FROM orders;
+`
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteSlowLogFullStatementText(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/executable.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := parseAll(NewParser(WithFullStatementText()), string(b))
+	plain := parseAll(NewParser(), string(b))
+	if len(events) != 3 || len(plain) != 3 {
+		t.Fatalf("expected 3 events, got %d and %d", len(events), len(plain))
+	}
+	if want := "SET timestamp=1690884001;\nuse billing;\nSET sql_mode='';\nSET SESSION time_zone='+00:00';\nUPDATE invoices\nSET paid = 1\nWHERE id = 2;"; events[1]["ExecutableText"] != want {
+		t.Errorf("expected ExecutableText %q, got %q", want, events[1]["ExecutableText"])
+	}
+	for i := range events {
+		if events[i]["Statement"] != plain[i]["Statement"] {
+			t.Errorf("event %d: expected Statement %q, got %q", i, plain[i]["Statement"], events[i]["Statement"])
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, e := range events {
+		if err := WriteSlowLog(&buf, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Apart from the header lines, the output is the log as it was.
+	body := func(s string) string {
+		var lines []string
+		for _, line := range strings.SplitAfter(s, "\n") {
+			if !strings.HasPrefix(line, "#") {
+				lines = append(lines, line)
+			}
+		}
+		return strings.Join(lines, "")
+	}
+	if got, want := body(buf.String()), body(string(b)); got != want {
+		t.Errorf("expected the entries to round trip, got:\n%s\nwant:\n%s", got, want)
+	}
+
+	events = parseAll(NewParser(WithFullStatementText(), WithMetricsOnly()), string(b))
+	if text, ok := events[0]["ExecutableText"]; ok {
+		t.Errorf("expected WithMetricsOnly to drop ExecutableText, got %q", text)
+	}
+}
+
+func TestWriteSlowLogRawNumbers(t *testing.T) {
+	// numbers returns the numeric attributes of the header lines of log,
+	// as logged, sorted.
+	numbers := func(log string) []string {
+		var tokens []string
+		for _, line := range strings.SplitAfter(log, "\n") {
+			if !strings.HasPrefix(line, "# ") || strings.HasPrefix(line, "# Time:") || strings.HasPrefix(line, "# User@Host:") {
+				continue
+			}
+			for _, m := range attributesRe.FindAllString(line, -1) {
+				colon := strings.IndexByte(m, ':')
+				key, value := m[:colon], strings.TrimSpace(m[colon+1:])
+				kind, ok := attributeTypes[key]
+				if _, err := strconv.ParseFloat(value, 64); ok && err == nil && (kind == AttributeFloat || kind == AttributeInt) {
+					tokens = append(tokens, key+": "+value)
+				}
+			}
+		}
+		sort.Strings(tokens)
+		return tokens
+	}
+	paths, err := filepath.Glob("./_test/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events := parseAll(NewParser(WithRawNumbers(), WithTrailer()), string(b))
+		// Attributes wrapped over lines can't be told apart line by line.
+		if len(events) == 0 || strings.HasSuffix(path, "headers_wrapped.txt") {
+			continue
+		}
+		var buf bytes.Buffer
+		for _, e := range events {
+			if err := WriteSlowLog(&buf, e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if expected, got := numbers(string(b)), numbers(buf.String()); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("%s: expected the numbers\n%s\ngot\n%s", path, strings.Join(expected, "\n"), strings.Join(got, "\n"))
+		}
+	}
+
+	// Values changed since parsing aren't written as logged.
+	e := parseAll(NewParser(WithRawNumbers()), "# Query_time: 1.5  Rows_sent: 007\nSELECT 1;\n")[0]
+	if raw := e["RawNumbers"].(map[string]string); len(raw) != 2 || raw["Query_time"] != "1.5" || raw["Rows_sent"] != "007" {
+		t.Errorf("unexpected RawNumbers %v", raw)
+	}
+	e["Query_time"] = 2.0
+	var buf bytes.Buffer
+	WriteSlowLog(&buf, e)
+	if !strings.Contains(buf.String(), "# Query_time: 2.000000  Rows_sent: 007\n") {
+		t.Errorf("unexpected output\n%s", buf.String())
+	}
+	if e := parseAll(NewParser(), "# Query_time: 1.5\nSELECT 1;\n")[0]; e["RawNumbers"] != nil {
+		t.Errorf("expected no RawNumbers without WithRawNumbers, got %v", e["RawNumbers"])
+	}
+}
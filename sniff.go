@@ -0,0 +1,135 @@
+package mysqllog
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SniffSize is the number of bytes Sniff looks at.
+const SniffSize = 4096
+
+// Confidence is how sure Sniff is that data is a slow query log.
+type Confidence int
+
+const (
+	// ConfidenceCompressed is for gzip data: decompress it and sniff
+	// again.
+	ConfidenceCompressed Confidence = iota - 1
+	// ConfidenceNone is for data that doesn't look like a slow query
+	// log, such as an error log, a general log or any other text.
+	ConfidenceNone
+	// ConfidenceLow is for data with a trace of a slow query log, such
+	// as a startup banner or a "SET timestamp=" line alone, which other
+	// logs may have too.
+	ConfidenceLow
+	// ConfidenceMedium is for data with a header line only slow query
+	// logs have, such as a "# User@Host:" or "# Query_time:" line.
+	ConfidenceMedium
+	// ConfidenceHigh is for data with several kinds of header lines.
+	ConfidenceHigh
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceCompressed:
+		return "compressed"
+	case ConfidenceNone:
+		return "none"
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceMedium:
+		return "medium"
+	case ConfidenceHigh:
+		return "high"
+	}
+	return "unknown"
+}
+
+// generalLogLine matches the entries of a general query log, such as
+// "2023-08-01T10:00:00.000000Z	   12 Query	SELECT 1".
+var generalLogLine = regexp.MustCompile(`^(\S+\s+)?\d+ (Connect|Query|Quit|Init DB|Prepare|Execute|Close stmt|Reset stmt|Field List|Statistics|Binlog Dump|Change user|Ping)(\t|$)`)
+
+// Sniff tells whether prefix, the start of a blob such as an upload,
+// looks like a slow query log, and its likely dialect, from the startup
+// banners, "# Time:", "# User@Host:" and "# Query_time:" lines and
+// "SET timestamp=" lines in its first SniffSize bytes. A leading UTF-8
+// byte order mark and a partial first or last line are tolerated. The
+// dialect is MySQL unless something tells otherwise. Gzip data is
+// ConfidenceCompressed, to sniff again once decompressed.
+func Sniff(prefix []byte) (Confidence, Dialect) {
+	if len(prefix) > SniffSize {
+		prefix = prefix[:SniffSize]
+	}
+	prefix = bytes.TrimPrefix(prefix, []byte("\xef\xbb\xbf"))
+	if bytes.HasPrefix(prefix, []byte{0x1f, 0x8b}) {
+		return ConfidenceCompressed, MySQL
+	}
+
+	var banner, timeLine, userHost, queryTime, setTimestamp, general bool
+	dialect, evidence := MySQL, evidenceNone
+	var header []string
+	for _, line := range strings.Split(string(prefix), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || line[0] != '#' {
+			if len(header) > 0 {
+				if d, e := headerDialect(header); e > evidence {
+					dialect, evidence = d, e
+				}
+				header = header[:0]
+			}
+		}
+		switch {
+		case isBanner(line):
+			banner = true
+			if evidence < evidenceBanner {
+				dialect, evidence = bannerDialect(line), evidenceBanner
+			}
+		case strings.HasPrefix(line, "# Time:"):
+			value := strings.TrimSpace(line[len("# Time:"):])
+			if _, err := parseTimeLine(value); err == nil || isLegacyTime(value) {
+				timeLine = true
+				header = append(header, line)
+			}
+		case strings.HasPrefix(line, "# User@Host:") && strings.Contains(line, "@ "):
+			userHost = true
+			header = append(header, line)
+		case strings.HasPrefix(line, "# Query_time:"):
+			queryTime = true
+			header = append(header, line)
+		case strings.HasPrefix(line, "#") && len(header) > 0:
+			header = append(header, line)
+		case strings.HasPrefix(strings.ToUpper(line), "SET TIMESTAMP="):
+			setTimestamp = true
+		case generalLogLine.MatchString(strings.TrimLeft(line, " \t")):
+			general = true
+		}
+	}
+	if d, e := headerDialect(header); e > evidence {
+		dialect, evidence = d, e
+	}
+
+	kinds := 0
+	for _, seen := range []bool{timeLine, userHost, queryTime, setTimestamp} {
+		if seen {
+			kinds++
+		}
+	}
+	switch {
+	case (userHost || queryTime) && kinds >= 2:
+		return ConfidenceHigh, dialect
+	case userHost || queryTime:
+		return ConfidenceMedium, dialect
+	case kinds > 0 || banner && !general:
+		return ConfidenceLow, dialect
+	}
+	return ConfidenceNone, MySQL
+}
+
+// isLegacyTime reports whether value is a time of the "# Time:" lines of
+// MySQL 5.6 and older and MariaDB, such as "230801 11:00:00".
+func isLegacyTime(value string) bool {
+	_, err := time.Parse(legacyTimeLayout, strings.Join(strings.Fields(value), " "))
+	return err == nil
+}
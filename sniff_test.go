@@ -0,0 +1,69 @@
+package mysqllog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	fixture := func(name string) []byte {
+		data, err := ioutil.ReadFile("_test/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(fixture("rds.txt"))
+	w.Close()
+	random := make([]byte, 2000)
+	rand.New(rand.NewSource(1)).Read(random)
+	rds := fixture("rds.txt")
+	long := bytes.Repeat(fixture("canonical_mysql8.txt"), 100)
+
+	cases := []struct {
+		name       string
+		data       []byte
+		confidence Confidence
+		dialect    Dialect
+	}{
+		{"mysql", rds, ConfidenceHigh, MySQL},
+		{"mariadb", fixture("canonical_mariadb.txt"), ConfidenceHigh, MariaDB},
+		{"percona", fixture("canonical_percona.txt"), ConfidenceHigh, Percona},
+		{"tidb", fixture("canonical_tidb.txt"), ConfidenceHigh, TiDB},
+		{"banners", fixture("banners.txt"), ConfidenceHigh, MySQL},
+		{"longer than SniffSize", long, ConfidenceHigh, MySQL},
+		{"byte order mark", append([]byte("\xef\xbb\xbf"), rds...), ConfidenceHigh, MySQL},
+		{"partial first line", rds[10:], ConfidenceHigh, MySQL},
+		{"crlf", []byte(strings.Replace(string(rds), "\n", "\r\n", -1)), ConfidenceHigh, MySQL},
+		{"header only", []byte("# Query_time: 1.5  Lock_time: 0.0 Rows_sent: 1  Rows_examined: 1\n"), ConfidenceMedium, MySQL},
+		{"banner only", []byte("/usr/sbin/mariadbd, Version: 10.6.12-MariaDB-log (MariaDB Server). started with:\n" +
+			"Tcp port: 3306  Unix socket: /run/mysqld/mysqld.sock\n" +
+			"Time                 Id Command    Argument\n"), ConfidenceLow, MariaDB},
+		{"gzip", gz.Bytes(), ConfidenceCompressed, MySQL},
+		{"general log", []byte("/usr/sbin/mysqld, Version: 8.0.33 (MySQL Community Server - GPL). started with:\n" +
+			"Tcp port: 3306  Unix socket: /var/run/mysqld/mysqld.sock\n" +
+			"Time                 Id Command    Argument\n" +
+			"2023-08-01T10:00:00.000000Z\t   12 Connect\tapp@web1 on shop using TCP/IP\n" +
+			"2023-08-01T10:00:00.100000Z\t   12 Query\tSELECT * FROM orders WHERE id = 1\n" +
+			"2023-08-01T10:00:00.200000Z\t   12 Quit\t\n"), ConfidenceNone, MySQL},
+		{"error log", []byte("2023-08-01T10:00:00.000000Z 0 [System] [MY-010116] [Server] /usr/sbin/mysqld (mysqld 8.0.33) starting as process 1\n" +
+			"2023-08-01T10:00:01.000000Z 1 [System] [MY-013576] [InnoDB] InnoDB initialization has started.\n" +
+			"2023-08-01T10:00:02.000000Z 0 [Warning] [MY-010068] [Server] CA certificate ca.pem is self signed.\n" +
+			"2023-08-01T10:00:03.000000Z 0 [System] [MY-010931] [Server] /usr/sbin/mysqld: ready for connections. Version: '8.0.33'  socket: '/var/run/mysqld/mysqld.sock'  port: 3306  MySQL Community Server - GPL.\n"), ConfidenceNone, MySQL},
+		{"text", []byte("# Time: for a change\n#\n# Notes from the meeting\n\nSELECT 1 is a query.\n"), ConfidenceNone, MySQL},
+		{"random", random, ConfidenceNone, MySQL},
+		{"empty", nil, ConfidenceNone, MySQL},
+	}
+	for _, c := range cases {
+		confidence, dialect := Sniff(c.data)
+		if confidence != c.confidence || dialect != c.dialect {
+			t.Errorf("%s: got %v, %v, want %v, %v", c.name, confidence, dialect, c.confidence, c.dialect)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package mysqllog
+
+import "strings"
+
+type tokenKind int
+
+const (
+	// tokenWord is a keyword or unquoted identifier.
+	tokenWord tokenKind = iota
+	// tokenIdent is an identifier quoted with backticks, without the quotes.
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+// token is a lexical token of a statement. depth is the number of
+// parentheses the token is nested in.
+type token struct {
+	kind  tokenKind
+	text  string
+	depth int
+}
+
+// is reports whether t is the keyword word, ignoring case.
+func (t token) is(word string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, word)
+}
+
+// isPunct reports whether t is the punctuation c.
+func (t token) isPunct(c byte) bool {
+	return t.kind == tokenPunct && t.text[0] == c
+}
+
+// tokenize splits statement into tokens, dropping whitespace and
+// comments, including optimizer hints. It's a lightweight lexer for
+// heuristics, not a SQL parser.
+func tokenize(statement string) []token {
+	tokens, _ := lex(statement)
+	return tokens
+}
+
+// lex is tokenize, also reporting whether statement has an executable
+// comment, such as "/*!50000 ... */" or MariaDB's "/*M! ... */", which
+// the server runs rather than ignores.
+func lex(statement string) (tokens []token, executable bool) {
+	depth := 0
+	for i := 0; i < len(statement); i++ {
+		c := statement[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case c == '/' && i+1 < len(statement) && statement[i+1] == '*':
+			rest := statement[i+2:]
+			executable = executable || strings.HasPrefix(rest, "!") || strings.HasPrefix(rest, "M!")
+			end := strings.Index(rest, "*/")
+			if end < 0 {
+				i = len(statement)
+			} else {
+				i += end + 3
+			}
+		case c == '#' || (c == '-' && strings.HasPrefix(statement[i:], "-- ")):
+			end := strings.IndexByte(statement[i:], '\n')
+			if end < 0 {
+				i = len(statement)
+			} else {
+				i += end
+			}
+		case c == '\'' || c == '"':
+			j := skipQuoted(statement, i)
+			tokens = append(tokens, token{tokenString, statement[i : j+1], depth})
+			i = j
+		case c == '`':
+			j := skipQuoted(statement, i)
+			end := j
+			if j == i || statement[j] != '`' {
+				// Not closed.
+				end = len(statement)
+			}
+			tokens = append(tokens, token{tokenIdent, strings.Replace(statement[i+1:end], "``", "`", -1), depth})
+			i = j
+		case isIdentByte(c):
+			j := i + 1
+			for j < len(statement) && isIdentByte(statement[j]) {
+				j++
+			}
+			kind := tokenWord
+			if isDigit(c) {
+				kind = tokenNumber
+			}
+			tokens = append(tokens, token{kind, statement[i:j], depth})
+			i = j - 1
+		default:
+			if c == ')' && depth > 0 {
+				depth--
+			}
+			tokens = append(tokens, token{tokenPunct, statement[i : i+1], depth})
+			if c == '(' {
+				depth++
+			}
+		}
+	}
+	return tokens, executable
+}
@@ -0,0 +1,98 @@
+package mysqllog
+
+import "strings"
+
+// Statement is one of the statements of an event, along with the
+// database it ran in.
+type Statement struct {
+	Text     string
+	Database string
+}
+
+// WithStatementSplitting sets "Statements" on each event to its
+// statements, a []Statement, for clients that send several at once. A
+// use statement between them isn't included, and changes the Database
+// of the statements after it; the first ones run in the "Database" of
+// the event. "Statement" is kept as it is.
+func WithStatementSplitting() Option {
+	return func(p *Parser) {
+		p.splitStatements = true
+	}
+}
+
+// SplitStatements splits statement at the semicolons that end the
+// statements in it, outside of string literals, quoted identifiers and
+// comments. The statements keep their semicolons and are trimmed of
+// spaces; empty ones are left out.
+func SplitStatements(statement string) []string {
+	var statements []string
+	add := func(s string) {
+		if s = strings.TrimSpace(s); s != "" && s != ";" {
+			statements = append(statements, s)
+		}
+	}
+	start := 0
+	for i := 0; i < len(statement); i++ {
+		switch c := statement[i]; {
+		case c == '\'' || c == '"':
+			i = skipQuoted(statement, i)
+		case c == '`':
+			if end := strings.IndexByte(statement[i+1:], '`'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(statement)
+			}
+		case c == '/' && strings.HasPrefix(statement[i:], "/*"):
+			if end := strings.Index(statement[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(statement)
+			}
+		case c == '#' || strings.HasPrefix(statement[i:], "-- "):
+			if end := strings.IndexByte(statement[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(statement)
+			}
+		case c == ';':
+			add(statement[start : i+1])
+			start = i + 1
+		}
+	}
+	if start < len(statement) {
+		add(statement[start:])
+	}
+	return statements
+}
+
+// splitStatements returns the Statements of statement, the first ones
+// running in database.
+func splitStatements(statement, database string) []Statement {
+	var statements []Statement
+	for _, text := range SplitStatements(statement) {
+		if db, rest, ok := parseUse(text); ok && rest == "" {
+			database = db
+			continue
+		}
+		statements = append(statements, Statement{Text: text, Database: database})
+	}
+	return statements
+}
+
+// parseUse parses the use statement at the start of line, such as
+// "use shop;", and returns its database and what follows it on the
+// line, such as a statement sent along with it.
+func parseUse(line string) (database, rest string, ok bool) {
+	if len(line) < len("use ") || !strings.EqualFold(line[:len("use ")], "use ") {
+		return "", "", false
+	}
+	database = line[len("use "):]
+	if end := strings.IndexByte(database, ';'); end >= 0 {
+		database, rest = database[:end], database[end+1:]
+	}
+	fields := strings.Fields(database)
+	if len(fields) == 0 {
+		return "", "", false
+	}
+	return strings.Trim(fields[0], "`"), strings.TrimSpace(rest), true
+}
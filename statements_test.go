@@ -0,0 +1,71 @@
+package mysqllog
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	type TestCase struct {
+		statement string
+		expected  []string
+	}
+	for _, c := range []TestCase{
+		{"SELECT 1", []string{"SELECT 1"}},
+		{"SELECT 1; SELECT 2;", []string{"SELECT 1;", "SELECT 2;"}},
+		{"SELECT 'a;b'; SELECT \"c;\";", []string{"SELECT 'a;b';", "SELECT \"c;\";"}},
+		{"SELECT 'it\\'s;'; SELECT 2", []string{"SELECT 'it\\'s;';", "SELECT 2"}},
+		{"SELECT `a;b` FROM t;;", []string{"SELECT `a;b` FROM t;"}},
+		{"SELECT 1 /* ; */; -- x;\nSELECT 2 # y;\n;", []string{"SELECT 1 /* ; */;", "-- x;\nSELECT 2 # y;\n;"}},
+		{"", nil},
+	} {
+		if statements := SplitStatements(c.statement); !reflect.DeepEqual(statements, c.expected) {
+			t.Errorf("%q: expected %q, got %q", c.statement, c.expected, statements)
+		}
+	}
+}
+
+func TestUseStatements(t *testing.T) {
+	content, err := ioutil.ReadFile("_test/use_statements.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := parseAll(NewParser(WithStatementSplitting()), string(content))
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	// use on the same line as the statement.
+	first := events[0]
+	if first["Database"] != "shop" || first["Statement"] != "SELECT * FROM orders WHERE id = 1;" {
+		t.Errorf("expected the statement after use in shop, got %q in %v", first["Statement"], first["Database"])
+	}
+	expected := []Statement{{Text: "SELECT * FROM orders WHERE id = 1;", Database: "shop"}}
+	if !reflect.DeepEqual(first["Statements"], expected) {
+		t.Errorf("expected %+v, got %+v", expected, first["Statements"])
+	}
+
+	// use between statements.
+	second := events[1]
+	if second["Database"] != "shop" {
+		t.Errorf("expected Database shop, got %v", second["Database"])
+	}
+	expected = []Statement{
+		{Text: "SELECT 1;", Database: "shop"},
+		{Text: "SELECT * FROM invoices WHERE note = 'a; b';", Database: "billing"},
+		{Text: "SELECT 2;", Database: "audit"},
+	}
+	if !reflect.DeepEqual(second["Statements"], expected) {
+		t.Errorf("expected %+v, got %+v", expected, second["Statements"])
+	}
+
+	// Without splitting, the statement is found after use all the same.
+	events = parseAll(NewParser(), string(content))
+	if events[0]["Statement"] != "SELECT * FROM orders WHERE id = 1;" {
+		t.Errorf("expected the statement after use, got %q", events[0]["Statement"])
+	}
+	if _, ok := events[0]["Statements"]; ok {
+		t.Error("expected no Statements without WithStatementSplitting")
+	}
+}
@@ -0,0 +1,129 @@
+package mysqllog
+
+import "strings"
+
+// WithStatementSize sets "StatementBytes" and "StatementLines" on each
+// event to the length and number of lines of its statement, and
+// "ValuesTuples" on INSERT and REPLACE statements with VALUES to the
+// number of rows they insert. See ValuesTuples.
+func WithStatementSize() Option {
+	return func(p *Parser) {
+		p.statementSize = true
+	}
+}
+
+// WithMetricsOnly drops the statement of each event once the attributes
+// derived from it, such as those of WithStatementSize, are set, so the
+// text is neither kept nor shipped. Events get a "Fingerprint" instead,
+// which the Aggregator groups them by.
+func WithMetricsOnly() Option {
+	return func(p *Parser) {
+		p.metricsOnly = true
+	}
+}
+
+// setStatementSize sets the WithStatementSize attributes of e.
+func setStatementSize(e LogEvent, statement string) {
+	e["StatementBytes"] = int64(len(statement))
+	lines := int64(0)
+	if statement != "" {
+		lines = int64(strings.Count(statement, "\n")) + 1
+	}
+	e["StatementLines"] = lines
+	if n, ok := ValuesTuples(statement); ok {
+		e["ValuesTuples"] = n
+	}
+}
+
+// ValuesTuples returns the number of top-level parenthesized groups
+// after VALUES in an INSERT or REPLACE statement, which is the number of
+// rows it inserts. ok is false for other statements, including INSERT
+// ... SELECT and INSERT ... SET. It scans the statement once without
+// tokenizing it, so it's cheap even for large batch inserts.
+func ValuesTuples(statement string) (n int64, ok bool) {
+	var quote byte
+	depth := 0
+	first := true
+	for i := 0; i < len(statement); i++ {
+		c := statement[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '#' || (c == '-' && strings.HasPrefix(statement[i:], "-- ")):
+			end := strings.IndexByte(statement[i:], '\n')
+			if end < 0 {
+				return n, ok
+			}
+			i += end
+		case c == '/' && strings.HasPrefix(statement[i:], "/*"):
+			end := strings.Index(statement[i+2:], "*/")
+			if end < 0 {
+				return n, ok
+			}
+			i += end + 3
+		case c == '(':
+			if depth == 0 && ok {
+				n++
+			}
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0 && isIdentByte(c):
+			end := i
+			for end < len(statement) && isIdentByte(statement[end]) {
+				end++
+			}
+			word := statement[i:end]
+			i = end - 1
+			switch {
+			case first:
+				if !strings.EqualFold(word, "insert") && !strings.EqualFold(word, "replace") {
+					return 0, false
+				}
+				first = false
+			case ok:
+				// ON DUPLICATE KEY UPDATE or a row alias ends the rows,
+				// except for the ROW of VALUES ROW(...).
+				if n > 0 && !strings.EqualFold(word, "row") {
+					return n, ok
+				}
+			case strings.EqualFold(word, "values") || strings.EqualFold(word, "value"):
+				ok = true
+			case strings.EqualFold(word, "select") || strings.EqualFold(word, "set"):
+				return 0, false
+			}
+		}
+	}
+	return n, ok
+}
+
+// statementSize returns the StatementBytes and ValuesTuples of e, from
+// its statement if WithStatementSize wasn't used.
+func statementSize(e LogEvent) (bytes, tuples int64) {
+	bytes, ok := e.Int64("StatementBytes")
+	if ok {
+		tuples, _ = e.Int64("ValuesTuples")
+		return bytes, tuples
+	}
+	statement, _ := e["Statement"].(string)
+	tuples, _ = ValuesTuples(statement)
+	return int64(len(statement)), tuples
+}
+
+// eventFingerprint returns the Fingerprint of the statement of e, or
+// the one set by WithMetricsOnly.
+func eventFingerprint(e LogEvent) string {
+	if statement, ok := e["Statement"].(string); ok {
+		return Fingerprint(statement)
+	}
+	fingerprint, _ := e["Fingerprint"].(string)
+	return fingerprint
+}
@@ -0,0 +1,84 @@
+package mysqllog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestValuesTuples(t *testing.T) {
+	type TestCase struct {
+		statement string
+		tuples    int64
+		ok        bool
+	}
+	for _, c := range []TestCase{
+		{"INSERT INTO t VALUES (1)", 1, true},
+		{"insert into t (a, b) values (1, 2), (3, 4),\n(5, 6);", 3, true},
+		{"REPLACE INTO t VALUE (1, '(a)'), (2, 'it''s')", 2, true},
+		{"INSERT INTO t VALUES (1, 'a\\')'), (2, ')')", 2, true},
+		{"/* batch */ INSERT INTO t VALUES (1), (2) ON DUPLICATE KEY UPDATE a = VALUES(a)", 2, true},
+		{"INSERT INTO t VALUES ROW(1), ROW(2)", 2, true},
+		{"INSERT INTO t VALUES (1), (2) AS new ON DUPLICATE KEY UPDATE a = new.a", 2, true},
+		{"INSERT INTO `values` (a) VALUES -- rows\n(1)", 1, true},
+		{"INSERT INTO t (a) SELECT a FROM u", 0, false},
+		{"INSERT INTO t SET a = 1", 0, false},
+		{"SELECT * FROM t WHERE a IN (1, 2)", 0, false},
+		{"", 0, false},
+	} {
+		tuples, ok := ValuesTuples(c.statement)
+		if tuples != c.tuples || ok != c.ok {
+			t.Errorf("%q: expected %d, %v, got %d, %v", c.statement, c.tuples, c.ok, tuples, ok)
+		}
+	}
+}
+
+func TestWithStatementSize(t *testing.T) {
+	input := "# Query_time: 1\nINSERT INTO t VALUES\n(1), (2);\n" +
+		"# Query_time: 2\nSELECT 1;\n"
+	events := parseAll(NewParser(WithStatementSize()), input)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if e := events[0]; e["StatementBytes"] != int64(30) || e["StatementLines"] != int64(2) || e["ValuesTuples"] != int64(2) {
+		t.Errorf("unexpected sizes %v", e)
+	}
+	if _, ok := events[1]["ValuesTuples"]; ok || events[1]["StatementLines"] != int64(1) {
+		t.Errorf("unexpected sizes %v", events[1])
+	}
+
+	events = parseAll(NewParser(WithStatementSize(), WithMetricsOnly()), input)
+	if _, ok := events[0]["Statement"]; ok {
+		t.Errorf("expected the statement to be dropped, got %v", events[0])
+	}
+	if events[0]["Fingerprint"] != "insert into t values (?+)" || events[0]["StatementBytes"] != int64(30) {
+		t.Errorf("expected a fingerprint and sizes, got %v", events[0])
+	}
+}
+
+func TestAggregatorStatementSize(t *testing.T) {
+	input := "# Query_time: 1\nINSERT INTO t VALUES (1), (2), (3);\n" +
+		"# Query_time: 1\nINSERT INTO t VALUES (1);\n"
+	for _, p := range []*Parser{{}, NewParser(WithStatementSize(), WithMetricsOnly())} {
+		a := NewAggregator()
+		for _, e := range parseAll(p, input) {
+			a.Add(e)
+		}
+		results := a.Results()
+		if len(results) != 1 {
+			t.Fatalf("expected 1 fingerprint, got %v", results)
+		}
+		s := results[0]
+		if s.MeanStatementBytes() != 30 || s.MaxValuesTuples != 3 {
+			t.Errorf("expected 30 bytes and 3 rows, got %v and %d", s.MeanStatementBytes(), s.MaxValuesTuples)
+		}
+
+		var buf bytes.Buffer
+		if err := WriteReport(&buf, results); err != nil {
+			t.Fatal(err)
+		}
+		if expected := "# Statement size: avg 30 bytes, up to 3 rows per insert\n"; !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected the report to contain %q, got\n%s", expected, buf.String())
+		}
+	}
+}
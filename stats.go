@@ -0,0 +1,44 @@
+package mysqllog
+
+// Stats holds counters describing the events a Parser has seen.
+type Stats struct {
+	// Bytes is the length of the lines consumed.
+	Bytes int64
+	// Events is the number of events emitted.
+	Events int64
+	// SampledOut is the number of events skipped by WithSampleRate.
+	SampledOut int64
+	// FingerprintLimited is the number of events dropped by WithFingerprintLimit.
+	FingerprintLimited int64
+	// RateLimited is the number of events dropped by WithRateLimit.
+	RateLimited int64
+	// NoConnectionID is the number of events without a connection id
+	// dropped by WithConnectionFilter, and ConnectionFiltered the number
+	// of events of other connections.
+	NoConnectionID     int64
+	ConnectionFiltered int64
+	// RowsFiltered is the number of events dropped by WithMinRowsExamined.
+	RowsFiltered int64
+	// OutOfOrder is the number of events flagged by
+	// WithOutOfOrderDetection.
+	OutOfOrder int64
+	// HookDropped is the number of events dropped by a WithEventHook.
+	HookDropped int64
+	// TrivialSkipped is the number of events dropped by WithSkipTrivial.
+	TrivialSkipped int64
+	// Incomplete is the number of incomplete events, and
+	// IncompleteDropped the number of them dropped by
+	// WithDropIncomplete.
+	Incomplete        int64
+	IncompleteDropped int64
+	// Presence is the number of events with each key, with
+	// WithPresenceStats.
+	Presence map[string]int64
+	// HeaderLinesCut is the number of header lines cut by
+	// WithHeaderLineLimit, and HeaderBytesCut the bytes ignored.
+	HeaderLinesCut int64
+	HeaderBytesCut int64
+	// ForeignLines is the number of error log lines skipped, see
+	// WithErrorLogLines.
+	ForeignLines int64
+}
@@ -0,0 +1,168 @@
+package mysqllog
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Summary describes everything in a log: how many events over what
+// time, how much Query_time and rows, and how many distinct users,
+// databases and fingerprints. It's a Sink, and an Aggregator keeps one
+// too (see Aggregator.Summary). The zero value is an empty Summary.
+type Summary struct {
+	Events int64
+	// FirstEvent and LastEvent are the earliest and latest event
+	// timestamps, zero if no event had one.
+	FirstEvent time.Time
+	LastEvent  time.Time
+
+	TotalTime    float64
+	MaxTime      float64
+	RowsExamined int64
+	RowsSent     int64
+
+	// Bytes is the size of the log read, which events don't tell. Set
+	// it from the Stats of the Parser.
+	Bytes int64
+
+	users        map[string]struct{}
+	databases    map[string]struct{}
+	fingerprints map[string]struct{}
+}
+
+// Span returns the time from the first to the last event.
+func (s *Summary) Span() time.Duration {
+	return s.LastEvent.Sub(s.FirstEvent)
+}
+
+// Users returns the number of distinct users.
+func (s *Summary) Users() int { return len(s.users) }
+
+// Databases returns the number of distinct databases.
+func (s *Summary) Databases() int { return len(s.databases) }
+
+// Fingerprints returns the number of distinct fingerprints.
+func (s *Summary) Fingerprints() int { return len(s.fingerprints) }
+
+// Add accumulates e into s.
+func (s *Summary) Add(e LogEvent) {
+	s.Events++
+	queryTime, _ := e.Float64("Query_time")
+	s.TotalTime += queryTime
+	if queryTime > s.MaxTime {
+		s.MaxTime = queryTime
+	}
+	rowsExamined, _ := e.Int64("Rows_examined")
+	s.RowsExamined += rowsExamined
+	rowsSent, _ := e.Int64("Rows_sent")
+	s.RowsSent += rowsSent
+	if ts, ok := EventTime(e); ok {
+		s.addTime(ts, ts)
+	}
+	if user, _ := e["User"].(string); user != "" {
+		addDistinct(&s.users, user)
+	}
+	if db, _ := e["Database"].(string); db != "" {
+		addDistinct(&s.databases, db)
+	}
+	addDistinct(&s.fingerprints, eventFingerprint(e))
+}
+
+func (s *Summary) addTime(first, last time.Time) {
+	if s.FirstEvent.IsZero() || first.Before(s.FirstEvent) {
+		s.FirstEvent = first
+	}
+	if last.After(s.LastEvent) {
+		s.LastEvent = last
+	}
+}
+
+func addDistinct(set *map[string]struct{}, value string) {
+	if *set == nil {
+		*set = map[string]struct{}{}
+	}
+	(*set)[value] = struct{}{}
+}
+
+// Merge adds other, such as the Summary of another file, to s.
+func (s *Summary) Merge(other *Summary) {
+	s.Events += other.Events
+	s.TotalTime += other.TotalTime
+	if other.MaxTime > s.MaxTime {
+		s.MaxTime = other.MaxTime
+	}
+	s.RowsExamined += other.RowsExamined
+	s.RowsSent += other.RowsSent
+	s.Bytes += other.Bytes
+	if !other.FirstEvent.IsZero() {
+		s.addTime(other.FirstEvent, other.LastEvent)
+	}
+	for _, set := range [][2]*map[string]struct{}{
+		{&s.users, &other.users},
+		{&s.databases, &other.databases},
+		{&s.fingerprints, &other.fingerprints},
+	} {
+		for value := range *set[1] {
+			addDistinct(set[0], value)
+		}
+	}
+}
+
+// Write implements Sink.
+func (s *Summary) Write(e LogEvent) error {
+	s.Add(e)
+	return nil
+}
+
+// Close implements Sink.
+func (s *Summary) Close() error {
+	return nil
+}
+
+// MarshalJSON writes s as an object with snake_case keys. Timestamps
+// are RFC 3339, or empty strings if no event had one.
+func (s *Summary) MarshalJSON() ([]byte, error) {
+	var first, last string
+	if !s.FirstEvent.IsZero() {
+		first = s.FirstEvent.Format(time.RFC3339Nano)
+		last = s.LastEvent.Format(time.RFC3339Nano)
+	}
+	return json.Marshal(struct {
+		Events       int64   `json:"events"`
+		FirstEvent   string  `json:"first_event"`
+		LastEvent    string  `json:"last_event"`
+		Span         float64 `json:"span_seconds"`
+		TotalTime    float64 `json:"total_query_time"`
+		MaxTime      float64 `json:"max_query_time"`
+		RowsExamined int64   `json:"rows_examined"`
+		RowsSent     int64   `json:"rows_sent"`
+		Users        int     `json:"users"`
+		Databases    int     `json:"databases"`
+		Fingerprints int     `json:"fingerprints"`
+		Bytes        int64   `json:"bytes"`
+	}{s.Events, first, last, s.Span().Seconds(), s.TotalTime, s.MaxTime, s.RowsExamined, s.RowsSent,
+		s.Users(), s.Databases(), s.Fingerprints(), s.Bytes})
+}
+
+// lines describes s for the reports, a line each.
+func (s *Summary) lines() []string {
+	lines := []string{fmt.Sprintf("%d events, %d bytes of log", s.Events, s.Bytes)}
+	if !s.FirstEvent.IsZero() {
+		lines = append(lines, fmt.Sprintf("Time range: %s to %s (%s)", s.FirstEvent.Format(DefaultTimestampLayout),
+			s.LastEvent.Format(DefaultTimestampLayout), s.Span()))
+	}
+	return append(lines,
+		fmt.Sprintf("Query_time: total %.6fs, max %.6fs", s.TotalTime, s.MaxTime),
+		fmt.Sprintf("Rows_examined: total %d, Rows_sent: total %d", s.RowsExamined, s.RowsSent),
+		fmt.Sprintf("Distinct: %d users, %d databases, %d fingerprints", s.Users(), s.Databases(), s.Fingerprints()),
+	)
+}
+
+// WithSummary starts the text, Markdown and HTML reports with summary,
+// such as from Aggregator.Summary.
+func WithSummary(summary *Summary) ReportOption {
+	return func(o *reportOptions) {
+		o.summary = summary
+	}
+}
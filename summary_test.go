@@ -0,0 +1,85 @@
+package mysqllog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummary(t *testing.T) {
+	input := "# Time: 2023-08-01T10:00:00.000000Z\n# User@Host: app[app] @ web1 []\n# Query_time: 1.5 Lock_time: 0 Rows_sent: 2 Rows_examined: 10\nuse shop;\nSET timestamp=1690884000;\nSELECT 1;\n" +
+		"# Time: 2023-08-01T10:05:00.000000Z\n# User@Host: batch[batch] @ web2 []\n# Query_time: 0.5 Lock_time: 0 Rows_sent: 1 Rows_examined: 5\nuse shop;\nSET timestamp=1690884300;\nSELECT 2;\n" +
+		"# Query_time: 2 Lock_time: 0 Rows_sent: 0 Rows_examined: 100\nuse other;\nDELETE FROM t;\n"
+	p := &Parser{}
+	s := &Summary{}
+	a := NewAggregator()
+	for _, e := range parseAll(p, input) {
+		s.Write(e)
+		a.Add(e)
+	}
+	s.Bytes = p.Stats().Bytes
+	if s.Events != 3 || s.TotalTime != 4 || s.MaxTime != 2 || s.RowsExamined != 115 || s.RowsSent != 3 {
+		t.Errorf("unexpected totals %+v", s)
+	}
+	if s.Span() != 5*time.Minute || s.Users() != 2 || s.Databases() != 2 || s.Fingerprints() != 2 {
+		t.Errorf("unexpected span %v or distinct values %d, %d, %d", s.Span(), s.Users(), s.Databases(), s.Fingerprints())
+	}
+	if s.Bytes != int64(len(input)) {
+		t.Errorf("expected %d bytes, got %d", len(input), s.Bytes)
+	}
+
+	merged := a.Summary()
+	merged.Bytes = s.Bytes
+	if b1, b2 := jsonPrint(s), jsonPrint(merged); b1 != b2 {
+		t.Errorf("expected the aggregator's summary %s, got %s", b1, b2)
+	}
+	merged.Merge(s)
+	if merged.Events != 6 || merged.Users() != 2 || merged.Bytes != 2*s.Bytes {
+		t.Errorf("unexpected merged summary %s", jsonPrint(merged))
+	}
+
+	results := a.Results()
+	for name, write := range map[string]func(*bytes.Buffer) error{
+		"text":     func(buf *bytes.Buffer) error { return WriteReport(buf, results, WithSummary(s)) },
+		"markdown": func(buf *bytes.Buffer) error { return WriteMarkdownReport(buf, results, WithSummary(s)) },
+		"html":     func(buf *bytes.Buffer) error { return WriteHTMLReport(buf, results, WithSummary(s)) },
+	} {
+		var buf bytes.Buffer
+		if err := write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range []string{
+			"Time range: " + time.Unix(1690884000, 0).Format(DefaultTimestampLayout) + " to " +
+				time.Unix(1690884300, 0).Format(DefaultTimestampLayout) + " (5m0s)",
+			"Distinct: 2 users, 2 databases, 2 fingerprints",
+		} {
+			if !strings.Contains(buf.String(), expected) {
+				t.Errorf("%s: expected the report to contain %q, got\n%s", name, expected, buf.String())
+			}
+		}
+		if summary, overall := strings.Index(buf.String(), "Summary"), strings.Index(buf.String(), "Profile"); summary < 0 || summary > overall {
+			t.Errorf("%s: expected the summary first, got\n%s", name, buf.String())
+		}
+	}
+}
+
+func TestSummaryEmpty(t *testing.T) {
+	b, err := json.Marshal(&Summary{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"events":0,"first_event":"","last_event":"","span_seconds":0,"total_query_time":0,"max_query_time":0,` +
+		`"rows_examined":0,"rows_sent":0,"users":0,"databases":0,"fingerprints":0,"bytes":0}`
+	if string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, nil, WithSummary(NewAggregator().Summary())); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "# Summary\n# 0 events, 0 bytes of log\n# Query_time: total 0.000000s") {
+		t.Errorf("unexpected report\n%s", buf.String())
+	}
+}
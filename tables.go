@@ -0,0 +1,146 @@
+package mysqllog
+
+import "strings"
+
+// WithTableExtraction sets "Tables" on each event to the Tables of its
+// statement, as a []string. Use WithRollups("Tables") to roll up by table.
+func WithTableExtraction() Option {
+	return func(p *Parser) {
+		p.tables = true
+	}
+}
+
+// Tables returns the tables referenced by statement after FROM, JOIN,
+// UPDATE, INTO, TABLE and DESCRIBE, in order of first appearance and without
+// duplicates. Schema-qualified names are kept qualified, as in "db.tbl",
+// and backticks are removed. Comma separated lists of tables and their
+// aliases are handled.
+//
+// It's a heuristic with a lightweight tokenizer rather than a SQL
+// parser. Tables in subqueries are found when they follow the same
+// keywords, but derived tables, table functions and tables named only
+// in USING or CTE references are not reported as such, and CTE names
+// are reported as tables. A list is cut short after a derived table or
+// a join condition, so b is missed in "FROM (SELECT 1 FROM a) t, b" and
+// c in "JOIN b ON a.id = b.id, c".
+func Tables(statement string) []string {
+	tokens := tokenize(statement)
+	var tables []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			tables = append(tables, name)
+		}
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		list := false
+		switch {
+		case t.is("from"):
+			if !inQuery(tokens, i) {
+				// Such as EXTRACT(YEAR FROM d).
+				continue
+			}
+			list = true
+		case t.is("update"):
+			if i > 0 && (tokens[i-1].is("key") || tokens[i-1].is("for")) {
+				// ON DUPLICATE KEY UPDATE and SELECT ... FOR UPDATE.
+				continue
+			}
+		case t.is("join"), t.is("into"), t.is("table"), t.is("describe"):
+		default:
+			continue
+		}
+		for j := i + 1; j < len(tokens); {
+			// UPDATE may be followed by modifiers.
+			for j < len(tokens) && (tokens[j].is("low_priority") || tokens[j].is("ignore") || tokens[j].is("only")) {
+				j++
+			}
+			name, next := tableName(tokens, j)
+			if name == "" {
+				break
+			}
+			add(name)
+			j = skipAlias(tokens, next)
+			if !(list || t.is("update")) || j >= len(tokens) || !tokens[j].isPunct(',') {
+				break
+			}
+			j++
+		}
+	}
+	return tables
+}
+
+// inQuery reports whether tokens[i] follows SELECT or DELETE at the
+// same nesting depth.
+func inQuery(tokens []token, i int) bool {
+	depth := tokens[i].depth
+	for k := i - 1; k >= 0 && tokens[k].depth >= depth; k-- {
+		if tokens[k].depth == depth && (tokens[k].is("select") || tokens[k].is("delete")) {
+			return true
+		}
+	}
+	return false
+}
+
+// tableName returns the possibly schema-qualified table name starting
+// at tokens[i] and the index after it, or "" if there's none.
+func tableName(tokens []token, i int) (string, int) {
+	part := func(i int) (string, bool) {
+		if i >= len(tokens) {
+			return "", false
+		}
+		switch t := tokens[i]; t.kind {
+		case tokenIdent:
+			return t.text, true
+		case tokenWord:
+			if tableKeywords[strings.ToLower(t.text)] {
+				return "", false
+			}
+			return t.text, true
+		}
+		return "", false
+	}
+	name, ok := part(i)
+	if !ok {
+		return "", i
+	}
+	i++
+	for i+1 < len(tokens) && tokens[i].isPunct('.') {
+		next, ok := part(i + 1)
+		if !ok {
+			break
+		}
+		name += "." + next
+		i += 2
+	}
+	return name, i
+}
+
+// skipAlias returns the index after an optional alias at tokens[i].
+func skipAlias(tokens []token, i int) int {
+	if i < len(tokens) && tokens[i].is("as") {
+		i++
+	}
+	if i < len(tokens) && (tokens[i].kind == tokenIdent ||
+		(tokens[i].kind == tokenWord && !tableKeywords[strings.ToLower(tokens[i].text)])) {
+		i++
+	}
+	return i
+}
+
+// tableKeywords are the keywords that can follow or stand in for a
+// table name, so they aren't mistaken for names or aliases.
+var tableKeywords = map[string]bool{
+	"select": true, "where": true, "set": true, "values": true, "value": true,
+	"on": true, "using": true, "join": true, "inner": true, "left": true,
+	"right": true, "outer": true, "cross": true, "natural": true,
+	"straight_join": true, "group": true, "order": true, "having": true,
+	"limit": true, "union": true, "for": true, "lock": true, "into": true,
+	"partition": true, "use": true, "force": true, "ignore": true,
+	"window": true, "as": true, "dual": true, "lateral": true, "with": true,
+	"procedure": true, "outfile": true, "dumpfile": true, "duplicate": true,
+	"returning": true, "default": true, "from": true,
+}
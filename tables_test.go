@@ -0,0 +1,61 @@
+package mysqllog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTables(t *testing.T) {
+	type TestCase struct {
+		Statement string
+		Tables    []string
+	}
+	for _, tc := range []TestCase{
+		{"SELECT * FROM t WHERE id = 1", []string{"t"}},
+		{"select * from `orders` o join `shop`.`customers` AS c on o.cid = c.id", []string{"orders", "shop.customers"}},
+		{"SELECT * FROM `weird``name` w JOIN `t\\` ON 1", []string{"weird`name", "t\\"}},
+		{"SELECT a FROM t1, t2 b, db.t3 WHERE t1.x = b.x", []string{"t1", "t2", "db.t3"}},
+		{"SELECT * FROM t1 LEFT OUTER JOIN t2 USING (id) INNER JOIN t1 ON 1", []string{"t1", "t2"}},
+		{"UPDATE LOW_PRIORITY t SET a = 1", []string{"t"}},
+		{"UPDATE t1, t2 SET t1.a = t2.a", []string{"t1", "t2"}},
+		{"INSERT INTO log (a, b) VALUES (1, 2) ON DUPLICATE KEY UPDATE b = 2", []string{"log"}},
+		{"INSERT INTO archive SELECT * FROM orders", []string{"archive", "orders"}},
+		{"DELETE FROM sessions WHERE expires < NOW()", []string{"sessions"}},
+		{"SELECT * FROM (SELECT id FROM a) x JOIN b ON b.id = x.id", []string{"a", "b"}},
+		{"SELECT * FROM t WHERE id IN (SELECT id FROM u)", []string{"t", "u"}},
+		{"SELECT EXTRACT(YEAR FROM created) FROM t FOR UPDATE", []string{"t"}},
+		{"SELECT 'from x' FROM t", []string{"t"}},
+		{"ALTER TABLE t ADD COLUMN b int", []string{"t"}},
+		{"SELECT 1", nil},
+		{"SELECT 1 FROM dual", nil},
+	} {
+		if tables := Tables(tc.Statement); !reflect.DeepEqual(tables, tc.Tables) {
+			t.Errorf("%q: expected %q, got %q", tc.Statement, tc.Tables, tables)
+		}
+	}
+}
+
+func TestWithTableExtraction(t *testing.T) {
+	events := parseAll(NewParser(WithTableExtraction()), generateEvents(4, 2))
+	if tables, _ := events[0]["Tables"].([]string); len(tables) != 1 || tables[0] != "t0" {
+		t.Errorf("expected Tables to be set, got %v", events[0]["Tables"])
+	}
+	a := NewAggregator(WithRollups("Tables"))
+	for _, e := range events {
+		a.Add(e)
+	}
+	rollups := a.Rollup("Tables")
+	if len(rollups) != 2 || rollups[0].Value != "t1" || rollups[0].Count != 2 || rollups[1].Value != "t0" {
+		t.Errorf("unexpected rollups %+v", rollups)
+	}
+}
+
+func TestAggregatorRollupTablesWithoutExtraction(t *testing.T) {
+	a := NewAggregator(WithRollups("Tables"))
+	a.Add(LogEvent{"Statement": "SELECT * FROM a JOIN b ON a.id = b.id", "Query_time": 1.0})
+	a.Add(LogEvent{"Statement": "SELECT 1", "Query_time": 1.0})
+	rollups := a.Rollup("Tables")
+	if len(rollups) != 3 || rollups[0].Value != NoneValue || rollups[1].Value != "a" || rollups[2].Value != "b" {
+		t.Errorf("unexpected rollups %+v", rollups)
+	}
+}
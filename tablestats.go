@@ -0,0 +1,218 @@
+package mysqllog
+
+import (
+	"sort"
+)
+
+// DefaultTableFingerprints is the number of fingerprints kept per table
+// in TableStats.Top.
+const DefaultTableFingerprints = 3
+
+// TableAttribution is how WithTableStats counts an event referencing
+// several tables.
+type TableAttribution int
+
+const (
+	// TableFullTime counts the whole Query_time and Rows_examined of an
+	// event for each of its tables, so a join's time is counted more
+	// than once, and the table totals can add up to more than the
+	// overall total.
+	TableFullTime TableAttribution = iota
+	// TableSplitTime divides them evenly between the tables.
+	TableSplitTime
+)
+
+// TableStats summarizes the events referencing a table.
+type TableStats struct {
+	Table        string
+	Count        int64
+	TotalTime    float64
+	RowsExamined int64
+	// Reads counts the SELECT events, and Writes the INSERT, UPDATE,
+	// DELETE and REPLACE ones. Verbs counts the events by Verb.
+	Reads  int64
+	Writes int64
+	Verbs  map[string]int64
+	// Percent is the share of the overall Query_time, from 0 to 100.
+	Percent float64
+	// Top are the DefaultTableFingerprints fingerprints with the most
+	// Query_time on the table, in descending order.
+	Top []TableFingerprint
+}
+
+// TableFingerprint is the part of a table's TableStats from one
+// fingerprint.
+type TableFingerprint struct {
+	Fingerprint string
+	Count       int64
+	TotalTime   float64
+}
+
+type tableEntry struct {
+	stats        TableStats
+	rowsExamined float64
+	fingerprints map[string]*TableFingerprint
+}
+
+// WithTableStats keeps TableStats for each table events reference (see
+// Tables), counting events referencing several tables as given by
+// attribution. Use Aggregator.ByTable to get them.
+func WithTableStats(attribution TableAttribution) AggregatorOption {
+	return func(a *Aggregator) {
+		a.tables = map[string]*tableEntry{}
+		a.tableAttribution = attribution
+	}
+}
+
+func (a *Aggregator) addTables(e LogEvent, fingerprint string, queryTime float64) {
+	tables := rollupValues(rollupAttribute(e, "Tables"))
+	if tables[0] == NoneValue {
+		return
+	}
+	rowsExamined, _ := e.Int64("Rows_examined")
+	rows := float64(rowsExamined)
+	if a.tableAttribution == TableSplitTime {
+		queryTime /= float64(len(tables))
+		rows /= float64(len(tables))
+	}
+	verb, _ := e["Verb"].(string)
+	if verb == "" {
+		statement, _ := e["Statement"].(string)
+		verb = Verb(statement)
+	}
+	for _, table := range tables {
+		t := a.tables[table]
+		if t == nil {
+			t = &tableEntry{stats: TableStats{Table: table, Verbs: map[string]int64{}}, fingerprints: map[string]*TableFingerprint{}}
+			a.tables[table] = t
+		}
+		t.add(verb, 1, queryTime, rows)
+		t.addFingerprint(TableFingerprint{Fingerprint: fingerprint, Count: 1, TotalTime: queryTime})
+	}
+}
+
+func (t *tableEntry) add(verb string, count int64, queryTime, rows float64) {
+	t.stats.Count += count
+	t.stats.TotalTime += queryTime
+	t.rowsExamined += rows
+	t.stats.Verbs[verb] += count
+	switch verb {
+	case VerbSelect:
+		t.stats.Reads += count
+	case VerbInsert, VerbUpdate, VerbDelete, VerbReplace:
+		t.stats.Writes += count
+	}
+}
+
+func (t *tableEntry) addFingerprint(other TableFingerprint) {
+	f := t.fingerprints[other.Fingerprint]
+	if f == nil {
+		f = &TableFingerprint{Fingerprint: other.Fingerprint}
+		t.fingerprints[other.Fingerprint] = f
+	}
+	f.Count += other.Count
+	f.TotalTime += other.TotalTime
+}
+
+func (a *Aggregator) mergeTables(other *Aggregator) {
+	if a.tables == nil {
+		return
+	}
+	for table, o := range other.tables {
+		t := a.tables[table]
+		if t == nil {
+			t = &tableEntry{stats: TableStats{Table: table, Verbs: map[string]int64{}}, fingerprints: map[string]*TableFingerprint{}}
+			a.tables[table] = t
+		}
+		t.stats.Count += o.stats.Count
+		t.stats.TotalTime += o.stats.TotalTime
+		t.stats.Reads += o.stats.Reads
+		t.stats.Writes += o.stats.Writes
+		t.rowsExamined += o.rowsExamined
+		for verb, n := range o.stats.Verbs {
+			t.stats.Verbs[verb] += n
+		}
+		for _, f := range o.fingerprints {
+			t.addFingerprint(*f)
+		}
+	}
+}
+
+// ByTable returns the TableStats of the topN tables with the most total
+// Query_time, or of all of them if topN is 0 or less, in descending
+// order. It's nil without WithTableStats.
+func (a *Aggregator) ByTable(topN int) []TableStats {
+	if a.tables == nil {
+		return nil
+	}
+	tables := make([]TableStats, 0, len(a.tables))
+	for _, t := range a.tables {
+		s := t.stats
+		s.RowsExamined = int64(t.rowsExamined + 0.5)
+		s.Percent = percent(s.TotalTime, a.totalTime)
+		s.Verbs = make(map[string]int64, len(t.stats.Verbs))
+		for verb, n := range t.stats.Verbs {
+			s.Verbs[verb] = n
+		}
+		for _, f := range t.fingerprints {
+			s.Top = append(s.Top, *f)
+		}
+		sort.Slice(s.Top, func(i, j int) bool {
+			if s.Top[i].TotalTime != s.Top[j].TotalTime {
+				return s.Top[i].TotalTime > s.Top[j].TotalTime
+			}
+			return s.Top[i].Fingerprint < s.Top[j].Fingerprint
+		})
+		if len(s.Top) > DefaultTableFingerprints {
+			s.Top = s.Top[:DefaultTableFingerprints]
+		}
+		tables = append(tables, s)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].TotalTime != tables[j].TotalTime {
+			return tables[i].TotalTime > tables[j].TotalTime
+		}
+		return tables[i].Table < tables[j].Table
+	})
+	if topN > 0 && len(tables) > topN {
+		tables = tables[:topN]
+	}
+	return tables
+}
+
+// WithTables adds a section for tables, from Aggregator.ByTable, to the
+// text, Markdown and HTML reports.
+func WithTables(tables []TableStats) ReportOption {
+	return func(o *reportOptions) {
+		o.tables = tables
+	}
+}
+
+func writeTableSection(ew *errWriter, tables []TableStats) {
+	ew.printf("\n# Tables\n")
+	ew.printf("# Rank Response time      Calls   Reads  Writes Rows examined Table\n")
+	for i, t := range tables {
+		ew.printf("# %4d %11.6fs %5.1f%% %7d %7d %7d %13d %s\n", i+1, t.TotalTime, t.Percent, t.Count, t.Reads, t.Writes,
+			t.RowsExamined, t.Table)
+		for _, f := range t.Top {
+			ew.printf("#      %11.6fs %7d calls: %s\n", f.TotalTime, f.Count, Truncate(f.Fingerprint, 60))
+		}
+	}
+}
+
+func writeMarkdownTableSection(ew *errWriter, tables []TableStats) {
+	ew.printf("\n### Tables\n\n")
+	ew.printf("| Rank | Table | Response time | %% | Calls | Reads | Writes | Rows examined | Top queries |\n")
+	ew.printf("| ---: | :---- | ------------: | --: | ----: | ----: | -----: | ------------: | :---------- |\n")
+	for i, t := range tables {
+		top := ""
+		for j, f := range t.Top {
+			if j > 0 {
+				top += "<br>"
+			}
+			top += markdownEscape(Truncate(f.Fingerprint, 60))
+		}
+		ew.printf("| %d | %s | %.6fs | %.1f | %d | %d | %d | %d | %s |\n", i+1, markdownEscape(t.Table), t.TotalTime, t.Percent,
+			t.Count, t.Reads, t.Writes, t.RowsExamined, top)
+	}
+}
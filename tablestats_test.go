@@ -0,0 +1,99 @@
+package mysqllog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func tableEvents() []LogEvent {
+	return []LogEvent{
+		{"Query_time": 2.0, "Rows_examined": int64(101), "Statement": "SELECT * FROM a JOIN b ON a.id = b.id WHERE a.x = 1"},
+		{"Query_time": 1.0, "Rows_examined": int64(10), "Statement": "UPDATE a SET x = 1 WHERE id = 1"},
+		{"Query_time": 0.5, "Rows_examined": int64(10), "Statement": "UPDATE a SET x = 2 WHERE id = 2"},
+		{"Query_time": 0.5, "Statement": "INSERT INTO c VALUES (1)"},
+		{"Query_time": 1.0, "Statement": "SELECT 1"},
+	}
+}
+
+func TestAggregatorByTable(t *testing.T) {
+	type TestCase struct {
+		attribution TableAttribution
+		time        float64
+		rows        int64
+	}
+	for _, c := range []TestCase{
+		{TableFullTime, 3.5, 121},
+		// Half of the join's time and rows.
+		{TableSplitTime, 2.5, 71},
+	} {
+		a := NewAggregator(WithTableStats(c.attribution))
+		for _, e := range tableEvents() {
+			a.Add(e)
+		}
+		tables := a.ByTable(0)
+		if len(tables) != 3 || tables[0].Table != "a" || tables[1].Table != "b" || tables[2].Table != "c" {
+			t.Fatalf("unexpected tables %v", tables)
+		}
+		s := tables[0]
+		if s.Count != 3 || s.TotalTime != c.time || s.RowsExamined != c.rows || s.Reads != 1 || s.Writes != 2 ||
+			s.Verbs[VerbUpdate] != 2 || s.Percent != 100*c.time/5 {
+			t.Errorf("%v: unexpected stats %+v", c.attribution, s)
+		}
+		update := TableFingerprint{Fingerprint: "update a set x = ? where id = ?", Count: 2, TotalTime: 1.5}
+		if len(s.Top) != 2 || (s.Top[0] != update && s.Top[1] != update) || s.Top[0].TotalTime < s.Top[1].TotalTime {
+			t.Errorf("%v: unexpected fingerprints %v", c.attribution, s.Top)
+		}
+		if top := a.ByTable(1); len(top) != 1 {
+			t.Errorf("expected 1 table, got %v", top)
+		}
+
+		merged := NewAggregator(WithTableStats(c.attribution))
+		merged.Merge(a)
+		merged.Merge(a)
+		if s := merged.ByTable(1)[0]; s.Count != 6 || s.TotalTime != 2*c.time || s.Verbs[VerbUpdate] != 4 {
+			t.Errorf("%v: unexpected merged stats %+v", c.attribution, s)
+		}
+	}
+	if tables := NewAggregator().ByTable(0); tables != nil {
+		t.Errorf("expected no tables without WithTableStats, got %v", tables)
+	}
+}
+
+func TestReportTables(t *testing.T) {
+	a := NewAggregator(WithTableStats(TableFullTime))
+	for _, e := range tableEvents() {
+		a.Add(e)
+	}
+	opt := WithTables(a.ByTable(2))
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, a.Results(), opt); err != nil {
+		t.Fatal(err)
+	}
+	expected := "\n# Tables\n" +
+		"# Rank Response time      Calls   Reads  Writes Rows examined Table\n" +
+		"#    1    3.500000s  70.0%       3       1       2           121 a\n" +
+		"#         2.000000s       1 calls: select * from a join b on a.id = b.id where a.x = ?\n" +
+		"#         1.500000s       2 calls: update a set x = ? where id = ?\n" +
+		"#    2    2.000000s  40.0%       1       1       0           101 b\n" +
+		"#         2.000000s       1 calls: select * from a join b on a.id = b.id where a.x = ?\n"
+	if !strings.HasSuffix(buf.String(), expected) {
+		t.Errorf("expected the report to end with\n%s\ngot\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteMarkdownReport(&buf, a.Results(), opt); err != nil {
+		t.Fatal(err)
+	}
+	if row := "| 1 | a | 3.500000s | 70.0 | 3 | 1 | 2 | 121 | select \\* from a join b on a.id = b.id where a.x = ?<br>update a set x = ? where id = ? |\n"; !strings.Contains(buf.String(), row) {
+		t.Errorf("expected the Markdown report to contain %q, got\n%s", row, buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteHTMLReport(&buf, a.Results(), opt); err != nil {
+		t.Fatal(err)
+	}
+	if row := `<tr><td>2</td><td class="query">b</td><td>2.000000</td>`; !strings.Contains(buf.String(), row) {
+		t.Errorf("expected the HTML report to contain %q, got\n%s", row, buf.String())
+	}
+}
@@ -0,0 +1,774 @@
+package mysqllog
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Defaults for following files with TailFile and WatchDir.
+const (
+	DefaultPollInterval = time.Second
+	DefaultIdleBackoff  = 10 * time.Second
+	DefaultMaxReadSize  = 1 << 20
+	// DefaultRecheckInterval is how often TailServer queries the
+	// server's log variables again.
+	DefaultRecheckInterval = time.Minute
+	// DefaultCheckpointEvents and DefaultCheckpointInterval are how
+	// often the checkpoint is saved while reading.
+	DefaultCheckpointEvents   = 1000
+	DefaultCheckpointInterval = 5 * time.Second
+)
+
+// fingerprintSize is how much of the start of a file its fingerprint
+// covers.
+const fingerprintSize = 4 << 10
+
+// TailOption configures TailFile and WatchDir.
+type TailOption func(*tailConfig)
+
+type tailConfig struct {
+	store        CheckpointStore
+	saveEvents   int
+	saveInterval time.Duration
+	parserOpts   []Option
+	pollInterval time.Duration
+	idleBackoff  time.Duration
+	maxRead      int64
+	recheck      time.Duration
+	rotation     RotationDetection
+	idleFlush    time.Duration
+	reconfigurer *Reconfigurer
+	clock        Clock
+	ack          AckSink
+}
+
+func newTailConfig(opts []TailOption) *tailConfig {
+	c := &tailConfig{
+		pollInterval: DefaultPollInterval,
+		idleBackoff:  DefaultIdleBackoff,
+		maxRead:      DefaultMaxReadSize,
+		recheck:      DefaultRecheckInterval,
+		saveEvents:   DefaultCheckpointEvents,
+		saveInterval: DefaultCheckpointInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.clock = clockOf(c.clock)
+	if c.pollInterval <= 0 {
+		c.pollInterval = DefaultPollInterval
+	}
+	if c.idleBackoff < c.pollInterval {
+		c.idleBackoff = c.pollInterval
+	}
+	return c
+}
+
+// WithPollInterval checks files for new data every d.
+func WithPollInterval(d time.Duration) TailOption {
+	return func(t *tailConfig) {
+		t.pollInterval = d
+	}
+}
+
+// WithIdleBackoff doubles the poll interval each time there's no new
+// data, up to max, and goes back to the poll interval once there is.
+// A max at or below the poll interval disables the backoff.
+func WithIdleBackoff(max time.Duration) TailOption {
+	return func(t *tailConfig) {
+		t.idleBackoff = max
+	}
+}
+
+// WithMaxReadSize reads about n bytes at a time before checking for
+// cancellation and saving the checkpoint, so catching up on a large
+// file doesn't hold them off. Zero or less reads until the end.
+func WithMaxReadSize(n int64) TailOption {
+	return func(t *tailConfig) {
+		t.maxRead = n
+	}
+}
+
+// WithIdleFlush flushes the pending event once no new data has come
+// for d, as incomplete with IncompleteIdleFlush, instead of holding it
+// until the next event shows where it ends. A statement written after
+// the flush is skipped as lines outside an event. Zero or less holds
+// events.
+func WithIdleFlush(d time.Duration) TailOption {
+	return func(t *tailConfig) {
+		t.idleFlush = d
+	}
+}
+
+// WithTailClock tells the time, for polling, WithIdleFlush, checkpoint
+// intervals and rechecking the server, with c in place of RealClock.
+func WithTailClock(c Clock) TailOption {
+	return func(t *tailConfig) {
+		t.clock = c
+	}
+}
+
+// poller waits between checks for new data, backing off while idle.
+type poller struct {
+	c    *tailConfig
+	wait time.Duration
+}
+
+// active resets the wait after new data.
+func (p *poller) active() {
+	p.wait = p.c.pollInterval
+}
+
+// idle waits for the next check, or until ctx is done.
+func (p *poller) idle(ctx context.Context) {
+	if p.wait < p.c.pollInterval {
+		p.wait = p.c.pollInterval
+	}
+	timer := p.c.clock.NewTimer(p.wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C():
+	}
+	if p.wait *= 2; p.wait > p.c.idleBackoff {
+		p.wait = p.c.idleBackoff
+	}
+}
+
+// WithRecheckInterval makes TailServer query the server's log
+// variables every d instead of DefaultRecheckInterval.
+func WithRecheckInterval(d time.Duration) TailOption {
+	return func(t *tailConfig) {
+		t.recheck = d
+	}
+}
+
+// WithCheckpoint resumes files from the offsets in c and records the
+// progress there, as WithCheckpointStore does with a FileCheckpointStore.
+func WithCheckpoint(c *Checkpoint) TailOption {
+	return WithCheckpointStore(&FileCheckpointStore{Checkpoint: c})
+}
+
+// WithCheckpointStore resumes files from the states in s and records the
+// progress there: the identity and fingerprint of each file, the offset
+// of its last complete event and the time of that event. s is saved
+// while reading, as set by WithCheckpointEvery, when there's no new data,
+// when switching files and when returning.
+//
+// A file is only resumed if it's the one the state was recorded for. If
+// that file is still in the same directory under another name, as after
+// rename rotation while nothing was following it, it's read to the end
+// first. Other files are read from the start.
+func WithCheckpointStore(s CheckpointStore) TailOption {
+	return func(t *tailConfig) {
+		t.store = s
+	}
+}
+
+// WithCheckpointEvery saves the checkpoint while reading after every n
+// events or every d, instead of DefaultCheckpointEvents and
+// DefaultCheckpointInterval. Zero or less disables either.
+func WithCheckpointEvery(n int, d time.Duration) TailOption {
+	return func(t *tailConfig) {
+		t.saveEvents = n
+		t.saveInterval = d
+	}
+}
+
+// WithAckSink writes the events read to s in batches, each acknowledged
+// before the checkpoint is saved past it: a batch is the events since
+// the last save, as set by WithCheckpointEvery, and is written to s with
+// WriteBatch before the next save, which only happens once it returned
+// without an error. Stopping at any point, even between the two, events
+// are read again from the last save, so at most one batch is written
+// twice, and none with sinks that ignore events they already have, such
+// as by their EventID. fn may be nil, as events are still passed to it
+// as they're read. An error from WriteBatch is returned, with the
+// checkpoint left before the batch.
+func WithAckSink(s AckSink) TailOption {
+	return func(t *tailConfig) {
+		t.ack = s
+	}
+}
+
+// checkpointer saves the position of the file being read in the store
+// of a tailConfig.
+type checkpointer struct {
+	c *tailConfig
+	// events is the number of events since the last save, at time
+	// last.
+	events int
+	last   time.Time
+	// path and saved are the last state saved.
+	path  string
+	saved CheckpointState
+	// batch is the events since the last save, for WithAckSink.
+	batch []LogEvent
+}
+
+func newCheckpointer(c *tailConfig) *checkpointer {
+	return &checkpointer{c: c, last: c.clock.Now()}
+}
+
+// count wraps fn to count the events passed to it.
+func (cp *checkpointer) count(fn func(LogEvent)) func(LogEvent) {
+	return func(e LogEvent) {
+		cp.events++
+		if cp.c.ack != nil {
+			cp.batch = append(cp.batch, e)
+		}
+		if fn != nil {
+			fn(e)
+		}
+	}
+}
+
+// ack writes the batch to the AckSink of WithAckSink.
+func (cp *checkpointer) ack() error {
+	if cp.c.ack == nil || len(cp.batch) == 0 {
+		return nil
+	}
+	if err := cp.c.ack.WriteBatch(cp.batch); err != nil {
+		return err
+	}
+	cp.batch = nil
+	return nil
+}
+
+// due saves the position of r if enough events or time went by since
+// the last save.
+func (cp *checkpointer) due(r *fileReader) error {
+	events, interval := cp.c.saveEvents, cp.c.saveInterval
+	if (events > 0 && cp.events >= events) || (interval > 0 && cp.c.clock.Now().Sub(cp.last) >= interval) {
+		return cp.save(r)
+	}
+	return nil
+}
+
+// save saves the position of r, if it changed, once the batch is
+// acknowledged.
+func (cp *checkpointer) save(r *fileReader) error {
+	cp.events, cp.last = 0, cp.c.clock.Now()
+	if err := cp.ack(); err != nil {
+		return err
+	}
+	if cp.c.store == nil || r == nil {
+		return nil
+	}
+	state := r.state()
+	if r.path == cp.path && state.equal(cp.saved) {
+		return nil
+	}
+	if err := cp.c.store.Save(r.path, state); err != nil {
+		return err
+	}
+	cp.path, cp.saved = r.path, state
+	return nil
+}
+
+// delete forgets path, once it has been read completely.
+func (cp *checkpointer) delete(path string) error {
+	if err := cp.ack(); err != nil {
+		return err
+	}
+	if cp.c.store == nil {
+		return nil
+	}
+	if cp.path == path {
+		cp.path, cp.saved = "", CheckpointState{}
+	}
+	return cp.c.store.Delete(path)
+}
+
+// load returns the state saved for path, if any.
+func (c *tailConfig) load(path string) (CheckpointState, bool, error) {
+	if c.store == nil {
+		return CheckpointState{}, false, nil
+	}
+	return c.store.Load(path)
+}
+
+// WithTailParserOptions configures the Parser of each file.
+func WithTailParserOptions(opts ...Option) TailOption {
+	return func(t *tailConfig) {
+		t.parserOpts = opts
+	}
+}
+
+// fileReader parses a file incrementally as it's written. Events get
+// the path in "SourceFile".
+type fileReader struct {
+	path   string
+	id     string
+	f      *os.File
+	r      *bufio.Reader
+	parser *Parser
+
+	// offset is the end of the last complete line read, and done the
+	// end of the last complete event, where reading can resume.
+	offset  int64
+	done    int64
+	partial string
+	// lastEvent is the time of the last event passed on.
+	lastEvent time.Time
+	// fingerprint is that of the first fingerprinted bytes of the file.
+	fingerprint   string
+	fingerprinted int64
+	// rotation tells whether the file at path was replaced.
+	rotation RotationDetection
+	// lastData is when data was last read, by clock.
+	lastData time.Time
+	clock    Clock
+}
+
+// openFileReader opens path to read from the offset of state, if it
+// isn't nil and matches the file. resumed is false if it doesn't, as
+// for a file replaced at the path or truncated below the offset, and
+// the file is read from the start.
+func openFileReader(c *tailConfig, path string, state *CheckpointState) (r *fileReader, resumed bool, err error) {
+	f, err := openShared(path)
+	if err != nil {
+		return nil, false, err
+	}
+	var id string
+	var offset int64
+	var lastEvent time.Time
+	var connections []ConnectionState
+	if info, err := f.Stat(); err == nil {
+		id = c.rotation.identity(info)
+		if state != nil && checkpointMatches(*state, f, info, id) {
+			offset, lastEvent, resumed = state.Offset, state.LastEvent, true
+			connections = state.Connections
+		}
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	parser := NewParser(c.parserOpts...)
+	// Offsets set by WithOffsets are from the start of the file.
+	parser.offset = offset
+	parser.RestoreConnections(connections)
+	parser.inputID = id
+	if id == "" {
+		parser.inputID = path
+	}
+	return &fileReader{
+		path:      path,
+		id:        id,
+		rotation:  c.rotation,
+		f:         f,
+		r:         bufio.NewReader(f),
+		parser:    parser,
+		offset:    offset,
+		done:      offset,
+		lastEvent: lastEvent,
+		lastData:  c.clock.Now(),
+		clock:     c.clock,
+	}, resumed, nil
+}
+
+// resumeFile opens path to read from its state in the store of c, if
+// it's for this file. If it isn't and the file it's for is still in the
+// same directory, old reads that one from the state, to be finished
+// before path is read from the start.
+func resumeFile(c *tailConfig, path string) (r, old *fileReader, err error) {
+	state, ok, err := c.load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		r, _, err := openFileReader(c, path, nil)
+		return r, nil, err
+	}
+	r, resumed, err := openFileReader(c, path, &state)
+	if err != nil || resumed || state.Offset == 0 {
+		return r, nil, err
+	}
+	if rotated := findRotated(path, state, c.rotation); rotated != "" {
+		old, resumed, err := openFileReader(c, rotated, &state)
+		if err == nil && resumed {
+			return r, old, nil
+		}
+		if err == nil {
+			old.close()
+		}
+	}
+	return r, nil, nil
+}
+
+// checkpointMatches reports whether state was recorded for f, with
+// identity id: it isn't shorter than the offset, and its identity and
+// fingerprint match, where they're known.
+func checkpointMatches(state CheckpointState, f *os.File, info os.FileInfo, id string) bool {
+	if info.Size() < state.Offset {
+		return false
+	}
+	if id != "" && state.ID != "" && id != state.ID {
+		return false
+	}
+	if state.Fingerprint != "" {
+		fingerprint, err := fileFingerprint(f, state.Offset)
+		if err != nil || fingerprint != state.Fingerprint {
+			return false
+		}
+	}
+	return true
+}
+
+// findRotated returns the other file in the directory of path that state
+// was recorded for, or "" if there's none. Only files whose identity, or
+// if identities aren't known or used by d, fingerprint is the recorded
+// one match.
+func findRotated(path string, state CheckpointState, d RotationDetection) string {
+	if state.ID == "" && state.Fingerprint == "" {
+		return ""
+	}
+	dir := filepath.Dir(path)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, info := range infos {
+		other := filepath.Join(dir, info.Name())
+		if other == filepath.Clean(path) || !info.Mode().IsRegular() {
+			continue
+		}
+		id := d.identity(info)
+		if id != "" || state.ID != "" {
+			if id != state.ID {
+				continue
+			}
+		} else if state.Fingerprint == "" {
+			continue
+		}
+		f, err := openShared(other)
+		if err != nil {
+			continue
+		}
+		matches := checkpointMatches(state, f, info, id)
+		f.Close()
+		if matches {
+			return other
+		}
+	}
+	return ""
+}
+
+// fileFingerprint returns a hash of the first bytes of f, up to
+// fingerprintSize and n, or "" for none.
+func fileFingerprint(f *os.File, n int64) (string, error) {
+	if n > fingerprintSize {
+		n = fingerprintSize
+	}
+	if n <= 0 {
+		return "", nil
+	}
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		return "", err
+	}
+	h := fnv.New64a()
+	h.Write(buf)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// emit passes e to fn with its "SourceFile".
+func (r *fileReader) emit(e LogEvent, fn func(LogEvent)) {
+	if e != nil {
+		if t, ok := EventTime(e); ok {
+			r.lastEvent = t
+		}
+		e["SourceFile"] = r.path
+		fn(e)
+	}
+}
+
+// state returns the position of r to record in a checkpoint.
+func (r *fileReader) state() CheckpointState {
+	n := r.done
+	if n > fingerprintSize {
+		n = fingerprintSize
+	}
+	if n != r.fingerprinted {
+		// The start of the file doesn't change once it's covered.
+		r.fingerprint, _ = fileFingerprint(r.f, n)
+		r.fingerprinted = n
+	}
+	return CheckpointState{ID: r.id, Fingerprint: r.fingerprint, Offset: r.done, LastEvent: r.lastEvent, Connections: r.parser.Connections()}
+}
+
+// read consumes the complete lines written so far, or about max bytes
+// of them if max is more than zero, calling fn with each event. It
+// reports whether there was new data.
+func (r *fileReader) read(fn func(LogEvent), max int64) (bool, error) {
+	var n int64
+	for max <= 0 || n < max {
+		line, err := r.r.ReadString('\n')
+		n += int64(len(line))
+		if len(line) > 0 {
+			r.lastData = r.clock.Now()
+		}
+		if err == io.EOF {
+			// Keeps a line that's still being written.
+			r.partial += line
+			return n > 0, nil
+		}
+		if err != nil {
+			return n > 0, err
+		}
+		line = r.partial + line
+		r.partial = ""
+		r.consume(line, fn)
+	}
+	return true, nil
+}
+
+// consume parses a complete line, keeping track of event boundaries.
+func (r *fileReader) consume(line string, fn func(LogEvent)) {
+	start := r.offset
+	r.offset += int64(len(line))
+	p := r.parser
+	inQuery := p.inQuery
+	idle := !p.inHeader && !p.inQuery
+	trailer := int64(-1)
+	if len(p.trailer) > 0 {
+		trailer = p.trailer[0].pos.offset
+	}
+	r.emit(p.ConsumeLine(line), fn)
+	switch {
+	case inQuery && !p.inQuery:
+		// The line ended an event and starts the next one.
+		r.done = start
+	case trailer >= 0 && len(p.trailer) == 0 && p.inQuery:
+		// The comment lines before the line started the next event.
+		r.done = trailer
+	case idle && !p.inHeader && !p.inQuery:
+		// The line is outside of any event.
+		r.done = r.offset
+	}
+}
+
+// idle flushes the pending event for WithIdleFlush if no data came for
+// d, and no line is being written.
+func (r *fileReader) idle(d time.Duration, fn func(LogEvent)) {
+	if d <= 0 || r.partial != "" || !r.parser.inQuery || r.clock.Now().Sub(r.lastData) < d {
+		return
+	}
+	r.emit(r.parser.flushIdle(), fn)
+	r.done = r.offset
+}
+
+// finish reads the rest of the file, including an unterminated last
+// line, and flushes the last event.
+func (r *fileReader) finish(fn func(LogEvent)) error {
+	if _, err := r.read(fn, 0); err != nil {
+		return err
+	}
+	if r.partial != "" {
+		line := r.partial
+		r.partial = ""
+		r.consume(line, fn)
+	}
+	r.emit(r.parser.Flush(), fn)
+	r.done = r.offset
+	return nil
+}
+
+func (r *fileReader) close() error {
+	return r.f.Close()
+}
+
+// truncated reports whether the file was truncated below what has been
+// read, as by copytruncate rotation.
+func (r *fileReader) truncated() (bool, error) {
+	info, err := r.f.Stat()
+	if err != nil {
+		return false, err
+	}
+	return info.Size() < r.offset+int64(len(r.partial)), nil
+}
+
+// restart flushes the pending event and reads the file again from the
+// start.
+func (r *fileReader) restart(fn func(LogEvent)) error {
+	r.emit(r.parser.Flush(), fn)
+	if _, err := r.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r.r.Reset(r.f)
+	r.offset, r.done, r.partial = 0, 0, ""
+	r.fingerprint, r.fingerprinted = "", 0
+	r.parser.offset = 0
+	return nil
+}
+
+// rotated passes a LogRotation to fn for WithControlEvents, r being
+// read from the start after rotation.
+func (r *fileReader) rotated(rotation string, fn func(LogEvent)) {
+	if r.parser.controlEvents {
+		fn(rotationEvent(r.path, rotation))
+	}
+}
+
+// replaced reports whether path is now a different file, as after
+// rename rotation. It's false while nothing has been created at path.
+func (r *fileReader) replaced() (bool, error) {
+	info, err := os.Stat(r.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	current, err := r.f.Stat()
+	if err != nil {
+		return false, err
+	}
+	return r.rotation.replaced(r.path, r.f, current, info)
+}
+
+// TailFile follows the slow query log at path, polling it for new data,
+// and calls fn with each event, like tail -F, with path in
+// "SourceFile". It starts at the beginning of the file, or at its offset
+// in the checkpoint given with WithCheckpoint or WithCheckpointStore.
+//
+// Rotation is followed both ways logrotate does it: when a new file is
+// created at path, the old one is read to the end and its last event
+// flushed before the new one is read from the start; and when the file
+// is truncated, the pending event is flushed and the file is read again
+// from the start. How a new file is told apart is set with
+// WithRotationDetection. Polling also works on filesystems without change
+// notifications, such as NFS and some FUSE and container mounts.
+//
+// TailFile returns when ctx is done or the file can't be read. An event
+// still being written when ctx is done isn't passed to fn, and is read
+// again on resuming from the checkpoint.
+func TailFile(ctx context.Context, path string, fn func(LogEvent), opts ...TailOption) error {
+	return tailFile(ctx, newTailConfig(opts), path, fn, nil)
+}
+
+// tailFile follows path as TailFile does. If moved isn't nil, it's
+// called while there's no new data, and a path it returns other than
+// the current one is followed instead once it can be opened, after the
+// current file is read to the end.
+func tailFile(ctx context.Context, c *tailConfig, path string, fn func(LogEvent), moved func(ctx context.Context) string) error {
+	cp := newCheckpointer(c)
+	fn = cp.count(fn)
+	r, old, err := resumeFile(c, path)
+	if err != nil {
+		return err
+	}
+	defer func() { r.close() }()
+	if err := finishRotated(old, fn); err != nil {
+		return err
+	}
+	if old != nil {
+		r.rotated("rename", fn)
+		if err := cp.save(r); err != nil {
+			return err
+		}
+	}
+
+	p := &poller{c: c}
+	for {
+		select {
+		case <-ctx.Done():
+			if err := cp.save(r); err != nil {
+				return err
+			}
+			return ctx.Err()
+		default:
+		}
+
+		c.reconfigure(r)
+		read, err := r.read(fn, c.maxRead)
+		if err != nil {
+			return err
+		}
+		if read {
+			if err := cp.due(r); err != nil {
+				return err
+			}
+			p.active()
+			continue
+		}
+
+		if truncated, err := r.truncated(); err != nil {
+			return err
+		} else if truncated {
+			if err := r.restart(fn); err != nil {
+				return err
+			}
+			r.rotated("truncate", fn)
+			continue
+		}
+		if replaced, err := r.replaced(); err != nil {
+			return err
+		} else if replaced {
+			if err := r.finish(fn); err != nil {
+				return err
+			}
+			next, _, err := openFileReader(c, r.path, nil)
+			if err != nil {
+				return err
+			}
+			r.close()
+			r = next
+			r.rotated("rename", fn)
+			if err := cp.save(r); err != nil {
+				return err
+			}
+			continue
+		}
+		if moved != nil {
+			if path := moved(ctx); path != "" && path != r.path {
+				next, old, err := resumeFile(c, path)
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				if err == nil {
+					if err := r.finish(fn); err != nil {
+						next.close()
+						return err
+					}
+					r.close()
+					if err := cp.delete(r.path); err != nil {
+						next.close()
+						return err
+					}
+					r = next
+					if err := finishRotated(old, fn); err != nil {
+						return err
+					}
+					if err := cp.save(r); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+		}
+
+		r.idle(c.idleFlush, fn)
+		if err := cp.save(r); err != nil {
+			return err
+		}
+		p.idle(ctx)
+	}
+}
+
+// finishRotated reads old, if not nil, to the end and closes it.
+func finishRotated(old *fileReader, fn func(LogEvent)) error {
+	if old == nil {
+		return nil
+	}
+	defer old.close()
+	return old.finish(fn)
+}
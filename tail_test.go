@@ -0,0 +1,472 @@
+package mysqllog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTailFile(t *testing.T) {
+	type TestCase struct {
+		name     string
+		initial  string
+		first    []string
+		update   func(t *testing.T, path string)
+		expected []string
+	}
+	testCases := []TestCase{
+		{
+			name:    "append",
+			initial: slowEvent(1) + slowEvent(2)[:40],
+			first:   []string{"SELECT 1;"},
+			update: func(t *testing.T, path string) {
+				appendFile(t, path, slowEvent(2)[40:]+slowEvent(3))
+			},
+			expected: []string{"SELECT 2;"},
+		},
+		{
+			name:    "rename rotation",
+			initial: slowEvent(1) + slowEvent(2),
+			first:   []string{"SELECT 1;"},
+			update: func(t *testing.T, path string) {
+				if err := os.Rename(path, path+".1"); err != nil {
+					t.Fatal(err)
+				}
+				// A late write to the rotated file is still read.
+				appendFile(t, path+".1", slowEvent(3))
+				appendFile(t, path, slowEvent(4)+slowEvent(5))
+			},
+			expected: []string{"SELECT 2;", "SELECT 3;", "SELECT 4;"},
+		},
+		{
+			name:    "remove and create",
+			initial: slowEvent(1) + slowEvent(2),
+			first:   []string{"SELECT 1;"},
+			update: func(t *testing.T, path string) {
+				if err := os.Remove(path); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(30 * time.Millisecond)
+				appendFile(t, path, slowEvent(3)+slowEvent(4))
+			},
+			expected: []string{"SELECT 2;", "SELECT 3;"},
+		},
+		{
+			name:    "copytruncate",
+			initial: slowEvent(1) + slowEvent(2),
+			first:   []string{"SELECT 1;"},
+			update: func(t *testing.T, path string) {
+				if err := os.Truncate(path, 0); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(60 * time.Millisecond)
+				appendFile(t, path, slowEvent(3)+slowEvent(4))
+			},
+			expected: []string{"SELECT 2;", "SELECT 3;"},
+		},
+	}
+	configs := map[string][]TailOption{
+		"poll":        {WithPollInterval(5 * time.Millisecond), WithIdleBackoff(5 * time.Millisecond)},
+		"backoff":     {WithPollInterval(2 * time.Millisecond), WithIdleBackoff(40 * time.Millisecond)},
+		"small reads": {WithPollInterval(5 * time.Millisecond), WithIdleBackoff(20 * time.Millisecond), WithMaxReadSize(16)},
+		"content":     append([]TailOption{WithRotationDetection(RotationContent)}, fastPoll...),
+		"created":     append([]TailOption{WithRotationDetection(RotationCreationTime)}, fastPoll...),
+	}
+
+	for name, opts := range configs {
+		for _, c := range testCases {
+			t.Run(name+"/"+c.name, func(t *testing.T) {
+				dir, err := ioutil.TempDir("", "mysqllog")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer os.RemoveAll(dir)
+				path := filepath.Join(dir, "slow.log")
+				appendFile(t, path, c.initial)
+
+				run := startFollow(func(ctx context.Context, fn func(LogEvent)) error {
+					return TailFile(ctx, path, fn, opts...)
+				})
+				run.expect(t, c.first...)
+				c.update(t, path)
+				run.expect(t, c.expected...)
+				time.Sleep(20 * time.Millisecond)
+				run.stop(t)
+			})
+		}
+	}
+}
+
+func TestTailFileCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+	appendFile(t, path, slowEvent(1)+slowEvent(2))
+	checkpoint, err := OpenCheckpoint(filepath.Join(dir, "checkpoint.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	follow := func(ctx context.Context, fn func(LogEvent)) error {
+		return TailFile(ctx, path, fn, append(fastPoll, WithCheckpoint(checkpoint))...)
+	}
+	run := startFollow(follow)
+	run.expect(t, "SELECT 1;")
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+
+	appendFile(t, path, slowEvent(3))
+	run = startFollow(follow)
+	run.expect(t, "SELECT 2;")
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+}
+
+func TestTailFileCheckpointIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+	appendFile(t, path, slowEvent(1)+slowEvent(2))
+	checkpoint, err := OpenCheckpoint(filepath.Join(dir, "checkpoint.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	follow := func(ctx context.Context, fn func(LogEvent)) error {
+		return TailFile(ctx, path, func(e LogEvent) {
+			if e["SourceFile"] != path {
+				t.Errorf("expected SourceFile %q, got %v", path, e["SourceFile"])
+			}
+			fn(e)
+		}, append(fastPoll, WithCheckpoint(checkpoint))...)
+	}
+	run := startFollow(follow)
+	run.expect(t, "SELECT 1;")
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+
+	// A new file at the same path is read from the start, even though
+	// it's larger than the recorded offset.
+	appendFile(t, path+".new", slowEvent(3)+slowEvent(4)+slowEvent(5))
+	if err := os.Rename(path+".new", path); err != nil {
+		t.Fatal(err)
+	}
+	if FileIdentity(mustStat(t, path)) == "" {
+		t.Skip("file identities aren't known on this platform")
+	}
+	run = startFollow(follow)
+	run.expect(t, "SELECT 3;", "SELECT 4;")
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+}
+
+func mustStat(t *testing.T, path string) os.FileInfo {
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestCheckpointFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint.json")
+	// Checkpoints used to have offsets only.
+	if err := ioutil.WriteFile(path, []byte(`{"old.log":42}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := OpenCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset, ok := checkpoint.FileOffset("old.log", "1:2"); !ok || offset != 42 {
+		t.Errorf("expected the old offset to match any file, got %d, %v", offset, ok)
+	}
+
+	checkpoint.SetFile("new.log", "1:2", 7)
+	if err := checkpoint.Save(); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err = OpenCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset, ok := checkpoint.FileOffset("new.log", "1:2"); !ok || offset != 7 {
+		t.Errorf("expected offset 7, got %d, %v", offset, ok)
+	}
+	if _, ok := checkpoint.FileOffset("new.log", "1:3"); ok {
+		t.Error("expected no offset for another file")
+	}
+	if offset, ok := checkpoint.Offset("new.log"); !ok || offset != 7 {
+		t.Errorf("expected Offset to ignore the identity, got %d, %v", offset, ok)
+	}
+}
+
+func TestTailFileCheckpointRotated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+	appendFile(t, path, slowEvent(1)+slowEvent(2))
+	if FileIdentity(mustStat(t, path)) == "" {
+		t.Skip("file identities aren't known on this platform")
+	}
+	store, err := OpenFileCheckpointStore(filepath.Join(dir, "checkpoint.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	follow := func(ctx context.Context, fn func(LogEvent)) error {
+		return TailFile(ctx, path, fn, append(fastPoll, WithCheckpointStore(store))...)
+	}
+	run := startFollow(follow)
+	run.expect(t, "SELECT 1;")
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+
+	// The log is rotated while nothing follows it: the rest of the old
+	// file is read before the new one.
+	appendFile(t, path, slowEvent(3))
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, slowEvent(4)+slowEvent(5))
+	run = startFollow(follow)
+	run.expect(t, "SELECT 2;", "SELECT 3;", "SELECT 4;")
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+
+	state, ok, err := store.Load(path)
+	if err != nil || !ok {
+		t.Fatalf("expected a state, got %v, %v", ok, err)
+	}
+	if state.ID != FileIdentity(mustStat(t, path)) || state.Offset != int64(len(slowEvent(4))) {
+		t.Errorf("expected the new file at the pending event, got %+v", state)
+	}
+}
+
+func TestTailFileCheckpointFingerprint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+	appendFile(t, path, slowEvent(1)+slowEvent(2))
+	store, err := OpenFileCheckpointStore(filepath.Join(dir, "checkpoint.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	follow := func(ctx context.Context, fn func(LogEvent)) error {
+		return TailFile(ctx, path, fn, append(fastPoll, WithCheckpointStore(store))...)
+	}
+	run := startFollow(follow)
+	run.expect(t, "SELECT 1;")
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+
+	// The same file is rewritten with other contents, longer than the
+	// offset, so only the fingerprint tells.
+	if err := ioutil.WriteFile(path, []byte(slowEvent(6)+slowEvent(7)+slowEvent(8)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run = startFollow(follow)
+	run.expect(t, "SELECT 6;", "SELECT 7;")
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+}
+
+// memoryStore is a CheckpointStore recording every save.
+type memoryStore struct {
+	mu     sync.Mutex
+	states map[string]CheckpointState
+	saves  int
+}
+
+func (s *memoryStore) Load(source string) (CheckpointState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[source]
+	return state, ok, nil
+}
+
+func (s *memoryStore) Save(source string, state CheckpointState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[source] = state
+	s.saves++
+	return nil
+}
+
+func (s *memoryStore) Delete(source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, source)
+	return nil
+}
+
+func TestTailFileCheckpointEvery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+	var data, last string
+	for i := 0; i < 10; i++ {
+		last = fmt.Sprintf("# Query_time: 1.0 Lock_time: 0.0 Rows_sent: 1 Rows_examined: 1\nSET timestamp=%d;\nSELECT %d;\n", 1690884000+i, i)
+		data += last
+	}
+	appendFile(t, path, data)
+	store := &memoryStore{states: map[string]CheckpointState{}}
+
+	// Reading a line at a time, the state is saved after every 3
+	// events while catching up.
+	opts := append(fastPoll, WithCheckpointStore(store), WithCheckpointEvery(3, 0), WithMaxReadSize(1))
+	run := startFollow(func(ctx context.Context, fn func(LogEvent)) error {
+		return TailFile(ctx, path, fn, opts...)
+	})
+	for i := 0; i < 9; i++ {
+		run.expect(t, fmt.Sprintf("SELECT %d;", i))
+	}
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	// The state doesn't change after the last one while reading.
+	if store.saves != 3 {
+		t.Errorf("expected 3 saves, got %d", store.saves)
+	}
+	state := store.states[path]
+	if state.Offset != int64(len(data)-len(last)) || state.Fingerprint == "" {
+		t.Errorf("expected the offset of the pending event, got %+v", state)
+	}
+	if expected := time.Unix(1690884008, 0); !state.LastEvent.Equal(expected) {
+		t.Errorf("expected the last event at %v, got %v", expected, state.LastEvent)
+	}
+}
+
+// ackSink records the statements of the batches acknowledged.
+type ackSink struct {
+	mu         sync.Mutex
+	statements []string
+	batches    int
+	err        error
+}
+
+func (s *ackSink) Write(e LogEvent) error {
+	return s.WriteBatch([]LogEvent{e})
+}
+
+func (s *ackSink) WriteBatch(events []LogEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	for _, e := range events {
+		s.statements = append(s.statements, e["Statement"].(string))
+	}
+	s.batches++
+	return nil
+}
+
+func (s *ackSink) Close() error {
+	return nil
+}
+
+func (s *ackSink) written() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.statements...)
+}
+
+// crashStore fails the saves after the first ones, as if the agent was
+// killed between writing to the sink and saving the checkpoint.
+type crashStore struct {
+	*memoryStore
+	saves int
+}
+
+var errCrash = errors.New("crash")
+
+func (s *crashStore) Save(source string, state CheckpointState) error {
+	if s.saves--; s.saves < 0 {
+		return errCrash
+	}
+	return s.memoryStore.Save(source, state)
+}
+
+func TestTailFileAckSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+	var data string
+	for i := 0; i < 10; i++ {
+		data += fmt.Sprintf("# Query_time: 1.0 Lock_time: 0.0 Rows_sent: 1 Rows_examined: 1\nSET timestamp=%d;\nSELECT %d;\n", 1690884000+i, i)
+	}
+	appendFile(t, path, data)
+	store := &memoryStore{states: map[string]CheckpointState{}}
+	sink := &ackSink{}
+	opts := append(fastPoll, WithCheckpointEvery(3, 0), WithMaxReadSize(1), WithAckSink(sink))
+
+	// The first batch and its checkpoint are saved, then the second
+	// batch is written but not its checkpoint.
+	err = TailFile(context.Background(), path, nil, append(opts, WithCheckpointStore(&crashStore{store, 1}))...)
+	if err != errCrash {
+		t.Fatalf("expected the crash, got %v", err)
+	}
+	if got := sink.written(); len(got) != 6 || sink.batches != 2 {
+		t.Fatalf("expected 2 batches of 3 events, got %q", got)
+	}
+
+	// Resuming writes the unsaved batch again, and nothing else.
+	run := startFollow(func(ctx context.Context, fn func(LogEvent)) error {
+		return TailFile(ctx, path, fn, append(opts, WithCheckpointStore(store))...)
+	})
+	for i := 3; i < 9; i++ {
+		run.expect(t, fmt.Sprintf("SELECT %d;", i))
+	}
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+	var expected []string
+	for _, i := range []int{0, 1, 2, 3, 4, 5, 3, 4, 5, 6, 7, 8} {
+		expected = append(expected, fmt.Sprintf("SELECT %d;", i))
+	}
+	if got := sink.written(); strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected exactly one batch twice, got %q", got)
+	}
+
+	// A batch that isn't acknowledged holds the checkpoint back.
+	saved := store.states[path]
+	appendFile(t, path, data)
+	sink.err = errors.New("unavailable")
+	err = TailFile(context.Background(), path, nil, append(opts, WithCheckpointStore(store))...)
+	if err != sink.err || !store.states[path].equal(saved) {
+		t.Errorf("expected the sink error and the checkpoint unchanged, got %v, %+v", err, store.states[path])
+	}
+}
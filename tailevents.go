@@ -0,0 +1,157 @@
+package mysqllog
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// tailEventsBlock is the size of the blocks TailEvents reads backward.
+var tailEventsBlock int64 = 64 << 10
+
+// TailEvents returns the last n events of the slow query log of size
+// bytes in ra, in order, parsed with a Parser configured with opts. It
+// reads backward from the end in blocks until it has found enough event
+// starts, so only the end of a large file is read.
+//
+// Events start where the Parser starts them, with their trailer lines,
+// and are parsed the same as reading the whole log from the start, except for options that depend on earlier events, such as
+// WithUserHostInheritance, and for a header-like line inside a string
+// literal, which the Parser takes as the start of an event too.
+func TailEvents(ra io.ReaderAt, size int64, n int, opts ...Option) ([]LogEvent, error) {
+	if n <= 0 || size <= 0 {
+		return nil, nil
+	}
+	var data []byte
+	pos := size
+	for {
+		start := pos - tailEventsBlock
+		if start < 0 {
+			start = 0
+		}
+		block := make([]byte, pos-start, int64(len(data))+pos-start)
+		if _, err := ra.ReadAt(block, start); err != nil && err != io.EOF {
+			return nil, &ReadError{Offset: start, Err: err}
+		}
+		data = append(block, data...)
+		pos = start
+
+		starts := eventStarts(data, pos == 0)
+		if len(starts) >= n || pos == 0 {
+			from := 0
+			if len(starts) > n {
+				from = starts[len(starts)-n]
+			} else if len(starts) > 0 {
+				from = starts[0]
+			}
+			events, err := parseEvents(data[from:], opts)
+			if err != nil {
+				return nil, err
+			}
+			if len(events) >= n || pos == 0 {
+				if len(events) > n {
+					events = events[len(events)-n:]
+				}
+				return events, nil
+			}
+		}
+	}
+}
+
+// eventStarts returns the offsets in data, which ends at the end of the
+// file or at an event start, of the lines where the Parser starts an
+// event. It follows the states of the Parser: after a statement, a
+// "# Time:" or "# User@Host:" line starts an event, while other comment
+// lines are the trailer of the event unless a statement follows them,
+// and a blank line ends it. Error log lines are skipped. Unless data is
+// at the start of the file, its first line may be partial and is
+// skipped, and no event starts until a line that isn't a header tells
+// where the lines are.
+func eventStarts(data []byte, atStart bool) []int {
+	const (
+		unknown = iota
+		outside
+		header
+		query
+		trailer
+	)
+	var starts []int
+	i := 0
+	state := outside
+	if !atStart {
+		j := bytes.IndexByte(data, '\n')
+		if j < 0 {
+			return nil
+		}
+		i = j + 1
+		state = unknown
+	}
+	// quote is the string literal open in the statement, and run the
+	// offset of the first trailer line.
+	var quote byte
+	run := 0
+	for i < len(data) {
+		end := len(data)
+		if j := bytes.IndexByte(data[i:], '\n'); j >= 0 {
+			end = i + j + 1
+		}
+		line := string(data[i:end])
+		if !(state == query && quote != 0) && isErrorLogLine(line) {
+			// Skipped by the Parser.
+			i = end
+			continue
+		}
+		blank := strings.TrimSpace(line) == ""
+		comment := line[0] == '#' && !(state != outside && isAdminCommand(line))
+		switch state {
+		case unknown:
+			if !blank && !comment {
+				state, quote = query, quoteState(0, line)
+			}
+		case outside:
+			if comment {
+				starts = append(starts, i)
+				state = header
+			}
+		case header:
+			if !blank && !comment {
+				state, quote = query, quoteState(0, line)
+			}
+		case query:
+			switch {
+			case blank && quote == 0:
+				state = outside
+			case comment && quote == 0 && !startsHeader(line):
+				state, run = trailer, i
+			case comment:
+				starts = append(starts, i)
+				state = header
+			case !blank:
+				quote = quoteState(quote, line)
+			}
+		case trailer:
+			switch {
+			case blank:
+				state = outside
+			case comment && startsHeader(line):
+				starts = append(starts, i)
+				state = header
+			case !comment:
+				// The trailer was the header of the next event.
+				starts = append(starts, run)
+				state, quote = query, quoteState(0, line)
+			}
+		}
+		i = end
+	}
+	return starts
+}
+
+// parseEvents parses all of data.
+func parseEvents(data []byte, opts []Option) ([]LogEvent, error) {
+	var events []LogEvent
+	err := ParseReader(bytes.NewReader(data), func(e LogEvent) {
+		events = append(events, e)
+	}, WithParser(NewParser(opts...)))
+	return events, err
+}
@@ -0,0 +1,81 @@
+package mysqllog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestTailEvents(t *testing.T) {
+	rds, err := ioutil.ReadFile("./_test/rds.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var generated strings.Builder
+	for i := 0; i < 200; i++ {
+		generated.WriteString(slowEvent(i % 60))
+	}
+	// A comment line in a statement starts an event for the Parser too.
+	generated.WriteString("# Time: 2023-08-01T10:01:00.000000Z\n# Query_time: 2\nSELECT a\n# not a header\nFROM t;\n")
+	generated.WriteString("# administrator command: Quit;\n\n")
+	generated.WriteString("junk\n" + slowEvent(7))
+
+	defer func(block int64) { tailEventsBlock = block }(tailEventsBlock)
+	for _, input := range []string{string(rds), generated.String()} {
+		expected := parseAll(&Parser{}, input)
+		for _, block := range []int64{7, 100, 4096, 64 << 10} {
+			tailEventsBlock = block
+			for _, n := range []int{1, 3, 50, len(expected), len(expected) + 10} {
+				events, err := TailEvents(strings.NewReader(input), int64(len(input)), n)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := expected
+				if n < len(want) {
+					want = want[len(want)-n:]
+				}
+				if len(events) != len(want) {
+					t.Fatalf("block %d, n %d: expected %d events, got %d", block, n, len(want), len(events))
+				}
+				for i := range want {
+					if !events[i].Equal(want[i]) {
+						t.Fatalf("block %d, n %d: event %d differs: %q", block, n, i, Diff(want[i], events[i]))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestTailEventsReadsTheEnd(t *testing.T) {
+	var input bytes.Buffer
+	for i := 0; i < 10000; i++ {
+		input.WriteString(slowEvent(i % 60))
+	}
+	r := &countingReaderAt{r: bytes.NewReader(input.Bytes())}
+	events, err := TailEvents(r, int64(input.Len()), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 5 || events[4]["Statement"] != "SELECT 39;" {
+		t.Errorf("unexpected events %v", events)
+	}
+	if r.n > tailEventsBlock {
+		t.Errorf("expected to read one block, read %d bytes", r.n)
+	}
+	if events, err := TailEvents(r, 0, 5); err != nil || len(events) != 0 {
+		t.Errorf("expected no events from an empty file, got %v, %v", events, err)
+	}
+}
+
+type countingReaderAt struct {
+	r *bytes.Reader
+	n int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.n += int64(n)
+	return n, err
+}
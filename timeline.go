@@ -0,0 +1,189 @@
+package mysqllog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Defaults for Timeline.
+const (
+	DefaultTimelineResolution      = time.Minute
+	DefaultTimelineBuckets         = 7 * 24 * 60
+	DefaultTimelineStatementLength = 200
+)
+
+// TimelineEntry is one of the slowest events of a Timeline bucket.
+type TimelineEntry struct {
+	Bucket      time.Time `json:"bucket"`
+	Timestamp   time.Time `json:"ts"`
+	QueryTime   float64   `json:"query_time"`
+	Fingerprint string    `json:"fingerprint"`
+	Statement   string    `json:"statement"`
+}
+
+// Timeline is a Sink keeping the slowest events of each period, such as
+// the slowest query of every minute, to plot latency spikes. Events
+// without a timestamp are ignored.
+//
+// Emit is called at Flush and Close with the entries in chronological
+// order of their buckets, slowest first within a bucket. At most
+// MaxBuckets buckets are kept; beyond that the oldest is emitted early,
+// and later events for it are dropped.
+type Timeline struct {
+	// Resolution is the length of a bucket, DefaultTimelineResolution
+	// if zero.
+	Resolution time.Duration
+	// TopK is the number of events kept per bucket, 1 if zero.
+	TopK int
+	// MaxBuckets is DefaultTimelineBuckets if zero.
+	MaxBuckets int
+	// StatementLength truncates statements, DefaultTimelineStatementLength
+	// if zero. Negative keeps them whole.
+	StatementLength int
+	Emit            func([]TimelineEntry)
+
+	buckets map[int64][]TimelineEntry
+	// evicted is set once a bucket has been emitted early, with the key
+	// of the newest one.
+	evicted    bool
+	evictedKey int64
+}
+
+// Write adds e to the bucket of its timestamp if it's among the slowest.
+func (t *Timeline) Write(e LogEvent) error {
+	ts, ok := EventTime(e)
+	if !ok {
+		return nil
+	}
+	resolution := t.Resolution
+	if resolution <= 0 {
+		resolution = DefaultTimelineResolution
+	}
+	bucket := ts.Truncate(resolution)
+	key := bucket.UnixNano()
+	if t.evicted && key <= t.evictedKey {
+		return nil
+	}
+	if t.buckets == nil {
+		t.buckets = map[int64][]TimelineEntry{}
+	}
+
+	k := t.TopK
+	if k <= 0 {
+		k = 1
+	}
+	queryTime, _ := e.Float64("Query_time")
+	entries, ok := t.buckets[key]
+	if len(entries) >= k && queryTime <= entries[len(entries)-1].QueryTime {
+		return nil
+	}
+	if !ok {
+		t.evictOldest()
+	}
+
+	statement, _ := e["Statement"].(string)
+	entry := TimelineEntry{
+		Bucket:      bucket,
+		Timestamp:   ts,
+		QueryTime:   queryTime,
+		Fingerprint: Fingerprint(statement),
+		Statement:   statement,
+	}
+	length := t.StatementLength
+	if length == 0 {
+		length = DefaultTimelineStatementLength
+	}
+	if length > 0 {
+		entry.Statement = Truncate(statement, length)
+	}
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].QueryTime < queryTime })
+	entries = append(entries, TimelineEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	if len(entries) > k {
+		entries = entries[:k]
+	}
+	t.buckets[key] = entries
+	return nil
+}
+
+// evictOldest emits the oldest bucket if a new one would exceed MaxBuckets.
+func (t *Timeline) evictOldest() {
+	max := t.MaxBuckets
+	if max <= 0 {
+		max = DefaultTimelineBuckets
+	}
+	if len(t.buckets) < max {
+		return
+	}
+	oldest := int64(0)
+	first := true
+	for key := range t.buckets {
+		if first || key < oldest {
+			oldest = key
+			first = false
+		}
+	}
+	entries := t.buckets[oldest]
+	delete(t.buckets, oldest)
+	t.evicted = true
+	t.evictedKey = oldest
+	if t.Emit != nil {
+		t.Emit(entries)
+	}
+}
+
+// Flush emits the buckets and starts over.
+func (t *Timeline) Flush() {
+	keys := make([]int64, 0, len(t.buckets))
+	for key := range t.buckets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	var entries []TimelineEntry
+	for _, key := range keys {
+		entries = append(entries, t.buckets[key]...)
+	}
+	t.buckets = nil
+	if t.Emit != nil && len(entries) > 0 {
+		t.Emit(entries)
+	}
+}
+
+// Close flushes the timeline.
+func (t *Timeline) Close() error {
+	t.Flush()
+	return nil
+}
+
+// WriteTimelineCSV writes entries as CSV with a header row. Times are
+// RFC 3339 in UTC.
+func WriteTimelineCSV(w io.Writer, entries []TimelineEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"bucket", "ts", "query_time", "fingerprint", "statement"})
+	for _, e := range entries {
+		cw.Write([]string{
+			e.Bucket.UTC().Format(time.RFC3339),
+			e.Timestamp.UTC().Format(time.RFC3339Nano),
+			strconv.FormatFloat(e.QueryTime, 'f', -1, 64),
+			e.Fingerprint,
+			e.Statement,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteTimelineJSON writes entries as a JSON array.
+func WriteTimelineJSON(w io.Writer, entries []TimelineEntry) error {
+	if entries == nil {
+		entries = []TimelineEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
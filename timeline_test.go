@@ -0,0 +1,68 @@
+package mysqllog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeline(t *testing.T) {
+	base := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	var emitted [][]TimelineEntry
+	timeline := &Timeline{
+		TopK:            2,
+		MaxBuckets:      2,
+		StatementLength: 12,
+		Emit:            func(entries []TimelineEntry) { emitted = append(emitted, entries) },
+	}
+	for _, e := range []LogEvent{
+		{"Statement": "SELECT a FROM t", "Query_time": 1.0, "Timestamp": base.Add(10 * time.Second)},
+		{"Statement": "SELECT b FROM t", "Query_time": 3.0, "Timestamp": base.Add(20 * time.Second)},
+		{"Statement": "SELECT c FROM t", "Query_time": 2.0, "Timestamp": base.Add(30 * time.Second)},
+		{"Statement": "SELECT d FROM t", "Query_time": 0.5, "Timestamp": base.Add(70 * time.Second)},
+		{"Statement": "SELECT e FROM t", "Query_time": 9.0},
+		// A third minute evicts the first one.
+		{"Statement": "SELECT f FROM t", "Query_time": 0.1, "Timestamp": base.Add(130 * time.Second)},
+		// Too late for the evicted minute.
+		{"Statement": "SELECT g FROM t", "Query_time": 5.0, "Timestamp": base.Add(40 * time.Second)},
+	} {
+		timeline.Write(e)
+	}
+	if len(emitted) != 1 {
+		t.Fatalf("expected the oldest bucket to be emitted, got %v", emitted)
+	}
+	first := emitted[0]
+	if len(first) != 2 || first[0].Statement != "SELECT b ..." || first[1].QueryTime != 2.0 || !first[0].Bucket.Equal(base) {
+		t.Errorf("unexpected first bucket %+v", first)
+	}
+	if first[0].Fingerprint != "select b from t" {
+		t.Errorf("expected the fingerprint of the whole statement, got %q", first[0].Fingerprint)
+	}
+
+	timeline.Close()
+	if len(emitted) != 2 || len(emitted[1]) != 2 || emitted[1][0].QueryTime != 0.5 || emitted[1][1].QueryTime != 0.1 {
+		t.Fatalf("expected the remaining buckets in order, got %+v", emitted[1:])
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTimelineCSV(&buf, emitted[1][:1]); err != nil {
+		t.Fatal(err)
+	}
+	expected := "bucket,ts,query_time,fingerprint,statement\n2018-03-01T10:01:00Z,2018-03-01T10:01:10Z,0.5,select d from t,SELECT d ...\n"
+	if buf.String() != expected {
+		t.Errorf("expected CSV %q, got %q", expected, buf.String())
+	}
+	buf.Reset()
+	if err := WriteTimelineJSON(&buf, emitted[0]); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0]["query_time"] != 3.0 || !strings.HasPrefix(decoded[0]["ts"].(string), "2018-03-01T10:00:20") {
+		t.Errorf("unexpected JSON %s", buf.String())
+	}
+}
@@ -0,0 +1,139 @@
+package mysqllog
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// DefaultTimestampLayout is the layout used for the "Timestamp" string
+// unless TimestampFormat is given.
+const DefaultTimestampLayout = "2006-01-02 15:04:05"
+
+// TimestampFormat stores "Timestamp" as a string formatted with layout.
+// The fraction of a second comes from the "# Time:" line when present,
+// so a layout such as "2006-01-02 15:04:05.000" keeps milliseconds.
+// Layouts without a time zone are in local time; with one, such as
+// time.RFC3339, the offset of the "# Time:" line is kept.
+// It overrides an earlier WithUnixTimestamps.
+func TimestampFormat(layout string) Option {
+	return func(p *Parser) {
+		p.timestampLayout = layout
+		p.unixTimestamps = false
+	}
+}
+
+// WithUnixTimestamps stores "Timestamp" as unix seconds instead of a
+// string: an int64 when the source has whole seconds only, or a float64
+// when the "# Time:" line provides a fraction.
+// It overrides an earlier TimestampFormat.
+func WithUnixTimestamps() Option {
+	return func(p *Parser) {
+		p.unixTimestamps = true
+	}
+}
+
+// formatTimestamp converts the parsed time.Time "Timestamp" of event
+// into the configured representation.
+func (p *Parser) formatTimestamp(event LogEvent) {
+	ts, ok := event["Timestamp"].(time.Time)
+	if !ok {
+		return
+	}
+	if p.unixTimestamps {
+		if ts.Nanosecond() == 0 {
+			event["Timestamp"] = ts.Unix()
+		} else {
+			event["Timestamp"] = float64(ts.Unix()) + float64(ts.Nanosecond())/1e9
+		}
+		return
+	}
+	layout := p.timestampLayout
+	if layout == "" {
+		layout = DefaultTimestampLayout
+	}
+	if !hasZone(layout) {
+		// Read back as local time by EventTime.
+		ts = ts.Local()
+	}
+	event["Timestamp"] = ts.Format(layout)
+}
+
+// EventTime returns the "Timestamp" of e as a time.Time. It understands
+// time.Time values, unix seconds stored as int64 or float64, and strings
+// in DefaultTimestampLayout (local time) or RFC 3339. ok is false for
+// missing timestamps and strings in other layouts.
+func EventTime(e LogEvent) (t time.Time, ok bool) {
+	switch ts := e["Timestamp"].(type) {
+	case time.Time:
+		return ts, true
+	case int64:
+		return time.Unix(ts, 0), true
+	case float64:
+		// A float64 holds unix seconds to about a microsecond, which
+		// is the precision of the slow log.
+		sec := math.Floor(ts)
+		return time.Unix(int64(sec), int64(math.Round((ts-sec)*1e6))*1e3), true
+	case string:
+		if t, err := time.ParseInLocation(DefaultTimestampLayout, ts, time.Local); err == nil {
+			return t, true
+		}
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// offsetTimeLayout is time.RFC3339Nano with the offset written without
+// a colon, such as "+0800", as some forks do.
+const offsetTimeLayout = "2006-01-02T15:04:05.999999999Z0700"
+
+// parseTimeLine parses the value of a "# Time:" line in ISO 8601, with
+// "Z" or a numeric offset, keeping the offset in the time.Time.
+func parseTimeLine(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		if t, err2 := time.Parse(offsetTimeLayout, value); err2 == nil {
+			return t, nil
+		}
+	}
+	return t, err
+}
+
+// reconcileTimeLine returns the "Timestamp" of event from its SET
+// timestamp and "# Time:" line, if any. SET timestamp has whole seconds
+// and no time zone, so when the two agree, the fraction and offset come
+// from the "# Time:" line. They agree when the "# Time:" line, which is
+// the start or the end of the query depending on the server, is within
+// the second of SET timestamp plus Query_time. Otherwise SET timestamp
+// wins, and the difference, in seconds, is stored as "TimestampSkew".
+func (p *Parser) reconcileTimeLine(event LogEvent, set, timeLine time.Time) time.Time {
+	if timeLine.IsZero() {
+		return set
+	}
+	if timeLine.Unix() == set.Unix() {
+		return set.Add(time.Duration(timeLine.Nanosecond())).In(timeLine.Location())
+	}
+	var queryTime time.Duration
+	switch v := event["Query_time"].(type) {
+	case time.Duration:
+		queryTime = v
+	case float64:
+		queryTime = time.Duration(v * float64(time.Second))
+	}
+	skew := timeLine.Sub(set)
+	if skew >= 0 && skew < time.Second+queryTime {
+		return set.In(timeLine.Location())
+	}
+	event["TimestampSkew"] = skew.Seconds()
+	if p.logger != nil {
+		p.logger.Printf("mysqllog: line %d: # Time: %s disagrees with SET timestamp=%d by %v", p.eventLine, timeLine.Format(time.RFC3339Nano), set.Unix(), skew)
+	}
+	return set
+}
+
+// hasZone reports whether layout formats a time zone or offset.
+func hasZone(layout string) bool {
+	return strings.Contains(layout, "MST") || strings.Contains(layout, "Z07") || strings.Contains(layout, "-07")
+}
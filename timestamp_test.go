@@ -0,0 +1,92 @@
+package mysqllog
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestTimestampFormat(t *testing.T) {
+	events := parseAll(NewParser(TimestampFormat("2006-01-02 15:04:05.000")), content)
+	expected := time.Unix(1514083320, 126000000).Format("2006-01-02 15:04:05.000")
+	if events[0]["Timestamp"] != expected {
+		t.Errorf("expected %q, got %v", expected, events[0]["Timestamp"])
+	}
+}
+
+func TestWithUnixTimestamps(t *testing.T) {
+	events := parseAll(NewParser(WithUnixTimestamps()), content)
+	if ts, ok := events[0]["Timestamp"].(float64); !ok || ts != 1514083320.126 {
+		t.Errorf("expected float unix timestamp 1514083320.126, got %#v", events[0]["Timestamp"])
+	}
+
+	// Without a fraction the timestamp is an int64.
+	events = parseAll(NewParser(WithUnixTimestamps()), "# Query_time: 0.1\nSET timestamp=1514083320;\nSELECT 1;\n")
+	if ts, ok := events[0]["Timestamp"].(int64); !ok || ts != 1514083320 {
+		t.Errorf("expected int unix timestamp 1514083320, got %#v", events[0]["Timestamp"])
+	}
+
+	// The last option wins.
+	events = parseAll(NewParser(WithUnixTimestamps(), TimestampFormat(time.RFC3339)), content)
+	if _, ok := events[0]["Timestamp"].(string); !ok {
+		t.Errorf("expected string timestamp, got %#v", events[0]["Timestamp"])
+	}
+}
+
+func TestTimestampMismatchedTimeLine(t *testing.T) {
+	// The # Time: line is for a different second, so its fraction is ignored.
+	events := parseAll(NewParser(TimestampFormat("15:04:05.000")), "# Time: 2017-12-24T02:41:00.500000Z\n# Query_time: 0.1\nSET timestamp=1514083320;\nSELECT 1;\n")
+	expected := time.Unix(1514083320, 0).Format("15:04:05.000")
+	if events[0]["Timestamp"] != expected {
+		t.Errorf("expected %q, got %v", expected, events[0]["Timestamp"])
+	}
+}
+
+func TestTimestampOffsets(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/time_offsets.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := parseAll(NewParser(TimestampFormat(time.RFC3339Nano)), string(b))
+	expected := []string{
+		"2023-08-01T10:36:57.123456+08:00",
+		"2023-08-01T03:00:00.25-07:00",
+		"2023-08-01T12:00:05.5+08:00",
+		// The # Time: line is the end of the query.
+		"2023-08-01T11:00:00+08:00",
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for i, want := range expected {
+		if events[i]["Timestamp"] != want {
+			t.Errorf("event %d: expected %q, got %v", i, want, events[i]["Timestamp"])
+		}
+		if skew, ok := events[i]["TimestampSkew"]; ok {
+			t.Errorf("event %d: expected no TimestampSkew, got %v", i, skew)
+		}
+		if ts, ok := EventTime(events[i]); !ok || ts.Format(time.RFC3339Nano) != want {
+			t.Errorf("event %d: expected EventTime to read %q back, got %v", i, want, ts)
+		}
+	}
+
+	// Layouts without a time zone stay in local time.
+	events = parseAll(NewParser(), string(b))
+	if want := time.Unix(1690857417, 0).Format(DefaultTimestampLayout); events[0]["Timestamp"] != want {
+		t.Errorf("expected %q, got %v", want, events[0]["Timestamp"])
+	}
+}
+
+func TestTimestampSkew(t *testing.T) {
+	b, err := ioutil.ReadFile("./_test/time_mismatch.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := parseAll(NewParser(TimestampFormat(time.RFC3339Nano)), string(b))
+	if want := time.Unix(1690866000, 0).Format(time.RFC3339Nano); events[0]["Timestamp"] != want {
+		t.Errorf("expected SET timestamp %q to win, got %v", want, events[0]["Timestamp"])
+	}
+	if skew := events[0]["TimestampSkew"]; skew != -3600.0 {
+		t.Errorf("expected a TimestampSkew of -3600, got %v", skew)
+	}
+}
@@ -0,0 +1,165 @@
+package mysqllog
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+)
+
+// TopKEntry is an approximate total for a fingerprint from TopK.
+type TopKEntry struct {
+	Fingerprint string
+	// TotalTime overestimates the fingerprint's total Query_time by at
+	// most Error, so the true total is between TotalTime-Error and
+	// TotalTime. Count is overestimated the same way.
+	TotalTime float64
+	Error     float64
+	Count     int64
+}
+
+// TopK tracks the fingerprints with the most total Query_time over a
+// stream using the space-saving algorithm, in memory proportional to
+// its capacity rather than to the number of fingerprints. Any
+// fingerprint with more than 1/capacity of the total Query_time is
+// guaranteed to be tracked. TopK is a Sink, and is safe for concurrent
+// use so dashboards can read it while events are written.
+type TopK struct {
+	k int
+
+	mu       sync.Mutex
+	capacity int
+	counters topKHeap
+}
+
+// NewTopK returns a TopK reporting the top k fingerprints. It tracks
+// 10*k candidates, which keeps errors small for typical skewed
+// workloads.
+func NewTopK(k int) *TopK {
+	return NewTopKWithCapacity(k, 10*k)
+}
+
+// NewTopKWithCapacity returns a TopK reporting the top k fingerprints
+// out of capacity tracked candidates. k is at least 1, and capacity at
+// least k.
+func NewTopKWithCapacity(k, capacity int) *TopK {
+	if k < 1 {
+		k = 1
+	}
+	if capacity < k {
+		capacity = k
+	}
+	return &TopK{
+		k:        k,
+		capacity: capacity,
+		counters: topKHeap{index: map[string]int{}},
+	}
+}
+
+// Add counts e toward its fingerprint.
+func (t *TopK) Add(e LogEvent) {
+	queryTime, _ := e.Float64("Query_time")
+	statement, _ := e["Statement"].(string)
+	fingerprint := Fingerprint(statement)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if i, ok := t.counters.index[fingerprint]; ok {
+		c := t.counters.entries[i]
+		c.TotalTime += queryTime
+		c.Count++
+		heap.Fix(&t.counters, i)
+		return
+	}
+	if t.counters.Len() < t.capacity {
+		heap.Push(&t.counters, &TopKEntry{Fingerprint: fingerprint, TotalTime: queryTime, Count: 1})
+		return
+	}
+	// Replace the smallest candidate, which may have been fingerprint.
+	c := t.counters.entries[0]
+	delete(t.counters.index, c.Fingerprint)
+	t.counters.index[fingerprint] = 0
+	*c = TopKEntry{
+		Fingerprint: fingerprint,
+		TotalTime:   c.TotalTime + queryTime,
+		Error:       c.TotalTime,
+		Count:       c.Count + 1,
+	}
+	heap.Fix(&t.counters, 0)
+}
+
+// Write implements Sink by calling Add.
+func (t *TopK) Write(e LogEvent) error {
+	t.Add(e)
+	return nil
+}
+
+// Close implements Sink. It does nothing.
+func (t *TopK) Close() error {
+	return nil
+}
+
+// Results returns the top k fingerprints by TotalTime, in descending order.
+func (t *TopK) Results() []TopKEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.results()
+}
+
+// Reset forgets all events.
+func (t *TopK) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.counters = topKHeap{index: map[string]int{}}
+}
+
+// ResultsAndReset returns the Results and resets t in one step, for
+// reporting fixed windows without losing events in between.
+func (t *TopK) ResultsAndReset() []TopKEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	results := t.results()
+	t.counters = topKHeap{index: map[string]int{}}
+	return results
+}
+
+func (t *TopK) results() []TopKEntry {
+	results := make([]TopKEntry, 0, len(t.counters.entries))
+	for _, c := range t.counters.entries {
+		results = append(results, *c)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].TotalTime != results[j].TotalTime {
+			return results[i].TotalTime > results[j].TotalTime
+		}
+		return results[i].Fingerprint < results[j].Fingerprint
+	})
+	if len(results) > t.k {
+		results = results[:t.k]
+	}
+	return results
+}
+
+// topKHeap is a min-heap of candidates by TotalTime.
+type topKHeap struct {
+	entries []*TopKEntry
+	index   map[string]int
+}
+
+func (h topKHeap) Len() int           { return len(h.entries) }
+func (h topKHeap) Less(i, j int) bool { return h.entries[i].TotalTime < h.entries[j].TotalTime }
+func (h topKHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.index[h.entries[i].Fingerprint] = i
+	h.index[h.entries[j].Fingerprint] = j
+}
+func (h *topKHeap) Push(x interface{}) {
+	c := x.(*TopKEntry)
+	h.index[c.Fingerprint] = len(h.entries)
+	h.entries = append(h.entries, c)
+}
+func (h *topKHeap) Pop() interface{} {
+	c := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	delete(h.index, c.Fingerprint)
+	return c
+}
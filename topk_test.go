@@ -0,0 +1,59 @@
+package mysqllog
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestTopK(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	top := NewTopK(5)
+	exact := map[string]float64{}
+	for i := 0; i < 100000; i++ {
+		// Five heavy hitters and a long tail of unique fingerprints.
+		statement := fmt.Sprintf("SELECT * FROM tail%d", i)
+		queryTime := r.Float64() / 100
+		if n := r.Intn(10); n < 5 {
+			statement = fmt.Sprintf("SELECT * FROM heavy%d", n)
+			queryTime = float64(n+1) * r.Float64()
+		}
+		e := LogEvent{"Statement": statement, "Query_time": queryTime}
+		exact[Fingerprint(statement)] += queryTime
+		top.Write(e)
+
+		if i%10000 == 9999 {
+			present := map[string]bool{}
+			for _, entry := range top.Results() {
+				present[entry.Fingerprint] = true
+				if truth := exact[entry.Fingerprint]; truth > entry.TotalTime+1e-9 || truth < entry.TotalTime-entry.Error-1e-9 {
+					t.Errorf("%q: true total %f outside [%f, %f]", entry.Fingerprint, truth, entry.TotalTime-entry.Error, entry.TotalTime)
+				}
+			}
+			for n := 0; n < 5; n++ {
+				if fingerprint := fmt.Sprintf("select * from heavy%d", n); !present[fingerprint] {
+					t.Errorf("after %d events expected %q in the top 5, got %+v", i+1, fingerprint, top.Results())
+				}
+			}
+		}
+	}
+	if results := top.Results(); len(results) != 5 || results[0].Fingerprint != "select * from heavy4" {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	if results := top.ResultsAndReset(); len(results) != 5 {
+		t.Errorf("expected 5 results before reset, got %d", len(results))
+	}
+	if results := top.Results(); len(results) != 0 {
+		t.Errorf("expected no results after reset, got %+v", results)
+	}
+}
+
+func TestTopKZero(t *testing.T) {
+	top := NewTopK(0)
+	top.Add(LogEvent{"Statement": "SELECT 1", "Query_time": 1.0})
+	top.Add(LogEvent{"Statement": "SELECT * FROM t", "Query_time": 2.0})
+	if results := top.Results(); len(results) != 1 || results[0].Fingerprint != "select * from t" {
+		t.Errorf("expected the top fingerprint, got %+v", results)
+	}
+}
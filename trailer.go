@@ -0,0 +1,42 @@
+package mysqllog
+
+import "strings"
+
+// WithTrailer sets "Trailer" on each event to the comment lines logged
+// after its statement, without their newlines. Some MariaDB versions and
+// configurations write such lines, like "# Tmp_tables:" footers,
+// optimizer trace snippets, or the "# Pager:" and "# Full" lines of
+// clients writing into the log. They belong to the event before them,
+// not to the header of the next one, and are dropped without this
+// option. Comment lines followed by a statement rather than by the next
+// "# Time:" or "# User@Host:" line are the header of an event of their
+// own, as before.
+func WithTrailer() Option {
+	return func(p *Parser) {
+		p.keepTrailer = true
+	}
+}
+
+// startsHeader reports whether line can only be the first line of a
+// header: one that can't follow a statement as part of its event.
+func startsHeader(line string) bool {
+	return strings.HasPrefix(line, "# Time:") || strings.HasPrefix(line, "# User@Host:")
+}
+
+// trailerLine is a comment line after the statement of the pending event.
+type trailerLine struct {
+	text string
+	pos  linePosition
+}
+
+// setTrailer sets "Trailer" on e for WithTrailer.
+func (p *Parser) setTrailer(e LogEvent) {
+	if !p.keepTrailer || len(p.trailer) == 0 {
+		return
+	}
+	lines := make([]string, len(p.trailer))
+	for i, line := range p.trailer {
+		lines[i] = strings.TrimRight(line.text, "\r\n")
+	}
+	e["Trailer"] = lines
+}
@@ -0,0 +1,68 @@
+package mysqllog
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestTrailer(t *testing.T) {
+	content, err := ioutil.ReadFile("./_test/mariadb_trailer.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := parseAll(NewParser(), string(content))
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	for i, e := range events {
+		for _, key := range []string{"Tmp_tables", "Tmp_disk_tables", "Pager", "Pager_rows", "Full_scan", "Trailer"} {
+			if _, ok := e[key]; ok {
+				t.Errorf("event %d: unexpected %s from a trailer", i, key)
+			}
+		}
+	}
+	if got := events[1]["User"]; got != "etl" {
+		t.Errorf("expected the second event of etl, got %v", got)
+	}
+	if got, _ := events[2].Float64("Rows_sent"); got != 10 {
+		t.Errorf("expected the third event to keep its header, got Rows_sent %v", got)
+	}
+
+	events = parseAll(NewParser(WithTrailer()), string(content))
+	expected := [][]string{
+		{"# Tmp_tables: 1  Tmp_disk_tables: 1  Tmp_table_sizes: 253952", "# Optimizer_trace: {"},
+		{"# Pager: stdout", "# Full scan: Yes  Pager_rows: 42"},
+		{"# Full"},
+	}
+	for i, e := range events {
+		lines, _ := e["Trailer"].([]string)
+		if len(lines) < len(expected[i]) || strings.Join(lines[:len(expected[i])], "\n") != strings.Join(expected[i], "\n") {
+			t.Errorf("event %d: unexpected trailer %q", i, lines)
+		}
+	}
+	if lines, _ := events[0]["Trailer"].([]string); len(lines) != 6 {
+		t.Errorf("expected the whole optimizer trace, got %q", lines)
+	}
+
+	// Comment lines followed by a statement are a header.
+	log := "# Query_time: 1.0  Lock_time: 0.0 Rows_sent: 1  Rows_examined: 1\nSELECT 1;\n# Query_time: 2.0  Lock_time: 0.0 Rows_sent: 2  Rows_examined: 2\nSELECT 2;\n"
+	events = parseAll(NewParser(WithTrailer()), log)
+	if rows, _ := events[len(events)-1].Float64("Rows_sent"); len(events) != 2 || rows != 2 || events[1]["Statement"] != "SELECT 2;" || events[0]["Trailer"] != nil {
+		t.Errorf("expected 2 events, got %v", events)
+	}
+
+	// Scanned events end at their statement.
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Split(ScanEvents)
+	var tokens []string
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Text())
+	}
+	if len(tokens) != 3 || strings.Contains(tokens[0], "# Tmp_tables:") || !strings.HasPrefix(tokens[2], "# User@Host:") {
+		t.Errorf("unexpected tokens %q", tokens)
+	}
+}
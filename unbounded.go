@@ -0,0 +1,55 @@
+package mysqllog
+
+// WithUnboundedWriteDetection sets "UnboundedWrite" to true on events
+// whose statement is an UnboundedWrite.
+func WithUnboundedWriteDetection() Option {
+	return func(p *Parser) {
+		p.unboundedWrites = true
+	}
+}
+
+// UnboundedWrite reports whether statement contains an UPDATE or DELETE
+// without a WHERE clause of its own, which touches every row of the
+// table. A WHERE inside a subquery or string literal doesn't count, and
+// each statement of a multi-statement body is checked separately.
+func UnboundedWrite(statement string) bool {
+	tokens := tokenize(statement)
+	for len(tokens) > 0 {
+		end := 0
+		for end < len(tokens) && !(tokens[end].isPunct(';') && tokens[end].depth == 0) {
+			end++
+		}
+		if unboundedWrite(tokens[:end]) {
+			return true
+		}
+		if end == len(tokens) {
+			break
+		}
+		tokens = tokens[end+1:]
+	}
+	return false
+}
+
+// unboundedWrite reports whether the tokens of a single statement are
+// an UPDATE or DELETE without a top-level WHERE.
+func unboundedWrite(tokens []token) bool {
+	if len(tokens) == 0 || !(tokens[0].is("update") || tokens[0].is("delete")) {
+		return false
+	}
+	for _, t := range tokens[1:] {
+		if t.depth == 0 && t.is("where") {
+			return false
+		}
+	}
+	return true
+}
+
+// isUnboundedWrite reports whether e is an unbounded write, using the
+// flag set by WithUnboundedWriteDetection if it's there.
+func isUnboundedWrite(e LogEvent) bool {
+	if flagged, ok := e["UnboundedWrite"].(bool); ok {
+		return flagged
+	}
+	statement, _ := e["Statement"].(string)
+	return UnboundedWrite(statement)
+}
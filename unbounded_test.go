@@ -0,0 +1,62 @@
+package mysqllog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUnboundedWrite(t *testing.T) {
+	type TestCase struct {
+		Statement string
+		Unbounded bool
+	}
+	for _, tc := range []TestCase{
+		{"DELETE FROM sessions", true},
+		{"delete from sessions where id = 1", false},
+		{"UPDATE t SET a = 1", true},
+		{"UPDATE t SET a = 1 WHERE b = 2", false},
+		{"DELETE FROM t ORDER BY id LIMIT 100", true},
+		{"DELETE FROM t WHERE id IN (SELECT id FROM u WHERE a = 1)", false},
+		{"DELETE FROM t USING t JOIN (SELECT id FROM u WHERE a = 1) x ON t.id = x.id", true},
+		{"UPDATE t SET a = (SELECT max(a) FROM u WHERE u.id = 1)", true},
+		{"UPDATE t SET note = 'where are we'", true},
+		{"UPDATE t SET note = 'x' WHERE note = 'no where'", false},
+		{"/* where */ DELETE FROM t -- where\n", true},
+		{"DELETE FROM a WHERE id = 1; DELETE FROM b", true},
+		{"DELETE FROM a WHERE id = 1; SELECT * FROM b", false},
+		{"SELECT * FROM t", false},
+		{"INSERT INTO t VALUES (1) ON DUPLICATE KEY UPDATE a = 1", false},
+	} {
+		if unbounded := UnboundedWrite(tc.Statement); unbounded != tc.Unbounded {
+			t.Errorf("%q: expected %v, got %v", tc.Statement, tc.Unbounded, unbounded)
+		}
+	}
+}
+
+func TestUnboundedWriteReport(t *testing.T) {
+	p := NewParser(WithUnboundedWriteDetection())
+	events := parseAll(p, "# Query_time: 1.5  Lock_time: 0 Rows_sent: 0  Rows_examined: 100\nDELETE FROM sessions;\n"+
+		"# Query_time: 0.5  Lock_time: 0 Rows_sent: 0  Rows_examined: 1\nDELETE FROM sessions WHERE id = 1;\n")
+	if len(events) != 2 || events[0]["UnboundedWrite"] != true || events[1]["UnboundedWrite"] != nil {
+		t.Fatalf("unexpected events %v", events)
+	}
+
+	results := aggregate(events)
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	expected := "\n# Unbounded writes\n# 1 calls, 1.500000s total: delete from sessions\n"
+	if !strings.HasSuffix(buf.String(), expected) {
+		t.Errorf("expected report to end with %q, got\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteMarkdownReport(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "### Unbounded writes\n\n| Calls | Total time | Query |\n| ----: | ---------: | :---- |\n| 1 | 1.500000s | delete from sessions |\n") {
+		t.Errorf("expected an unbounded writes section, got\n%s", buf.String())
+	}
+}
@@ -0,0 +1,144 @@
+package mysqllog
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// Unit is the unit a numeric header attribute is written in. Events
+// have times in seconds and sizes in bytes, the canonical units, as
+// Schema documents for each attribute.
+type Unit int
+
+const (
+	// Seconds, Milliseconds and Microseconds are times, stored in
+	// seconds.
+	Seconds Unit = iota + 1
+	Milliseconds
+	Microseconds
+	// Bytes and KiB are sizes, stored in bytes.
+	Bytes
+	KiB
+)
+
+func (u Unit) String() string {
+	switch u {
+	case Seconds:
+		return "seconds"
+	case Milliseconds:
+		return "milliseconds"
+	case Microseconds:
+		return "microseconds"
+	case Bytes:
+		return "bytes"
+	case KiB:
+		return "KiB"
+	}
+	return "unknown"
+}
+
+// canonical returns the unit values in u are stored in.
+func (u Unit) canonical() Unit {
+	switch u {
+	case Seconds, Milliseconds, Microseconds:
+		return Seconds
+	}
+	return Bytes
+}
+
+// normalize converts v, in u, to the canonical unit. Smaller units are
+// divided so exact values stay exact, such as 2400000 microseconds
+// being 2.4 seconds.
+func (u Unit) normalize(v float64) float64 {
+	switch u {
+	case Milliseconds:
+		return v / 1e3
+	case Microseconds:
+		return v / 1e6
+	case KiB:
+		return v * 1024
+	}
+	return v
+}
+
+// WithAttributeUnit reads the attribute name as written in unit, such as
+// Query_time in Microseconds for a proxy that writes "Query_time:
+// 2400000" for 2.4 seconds, and stores it in the canonical unit,
+// seconds or bytes, with the type it has otherwise: a float64, an int64
+// rounded to the nearest integer, or a time.Duration with
+// WithDurations. Events from sources with different units can then be
+// aggregated together. It doesn't apply to attributes converted by a
+// WithAttributeParser function.
+func WithAttributeUnit(name string, unit Unit) Option {
+	return func(p *Parser) {
+		p.attributes.setUnit(name, unit)
+	}
+}
+
+func (t *attributeTable) setUnit(key string, unit Unit) {
+	if t.units == nil {
+		t.units = map[string]Unit{}
+	}
+	t.units[key] = unit
+}
+
+// convertUnit converts value, written in unit, to kind in the canonical
+// unit. It returns nil if the value isn't a number, or kind isn't
+// numeric.
+func convertUnit(kind AttributeKind, unit Unit, value string) interface{} {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	v = unit.normalize(v)
+	switch kind {
+	case AttributeFloat:
+		return v
+	case AttributeInt:
+		return int64(math.Round(v))
+	case AttributeDuration:
+		return time.Duration(math.Round(v * float64(time.Second)))
+	}
+	return nil
+}
+
+// byteAttributes are the header attributes that are sizes.
+var byteAttributes = map[string]bool{
+	"Bytes_sent":        true,
+	"Bytes_received":    true,
+	"Tmp_table_sizes":   true,
+	"InnoDB_IO_r_bytes": true,
+	"Mem_max":           true,
+	"Disk_max":          true,
+	"Write_size":        true,
+}
+
+// secondAttributes are the float header attributes in seconds whose
+// names don't say so; see isDurationAttribute.
+var secondAttributes = map[string]bool{
+	"Wait_TS":                  true,
+	"Cop_proc_avg":             true,
+	"Cop_proc_p90":             true,
+	"Cop_proc_max":             true,
+	"Cop_wait_avg":             true,
+	"Cop_wait_p90":             true,
+	"Cop_wait_max":             true,
+	"Time_queued_by_rc":        true,
+	"KV_total":                 true,
+	"PD_total":                 true,
+	"Backoff_total":            true,
+	"Write_sql_response_total": true,
+}
+
+// attributeUnit returns the canonical unit of the attribute key of
+// kind, or 0 for counts and other values without a unit.
+func attributeUnit(key string, kind AttributeKind) Unit {
+	switch {
+	case (kind == AttributeFloat || kind == AttributeDuration) && (isDurationAttribute(key) || secondAttributes[key]):
+		return Seconds
+	case kind == AttributeInt && byteAttributes[key]:
+		return Bytes
+	}
+	return 0
+}
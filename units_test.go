@@ -0,0 +1,70 @@
+package mysqllog
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const microsecondsContent = `# Time: 2024-03-01T10:00:00.000000Z
+# User@Host: app[app] @ proxy1 []  Id:     7
+# Query_time: 2400000  Lock_time: 1500 Rows_sent: 1  Rows_examined: 10  Bytes_sent: 2
+SELECT * FROM orders WHERE id = 1;
+`
+
+const secondsContent = `# Time: 2024-03-01T10:00:01.000000Z
+# User@Host: app[app] @ web1 []  Id:     8
+# Query_time: 1.200000  Lock_time: 0.000500 Rows_sent: 1  Rows_examined: 10  Bytes_sent: 4096
+SELECT * FROM orders WHERE id = 2;
+`
+
+func TestAttributeUnit(t *testing.T) {
+	proxy := []Option{
+		WithAttributeUnit("Query_time", Microseconds),
+		WithAttributeUnit("Lock_time", Microseconds),
+		WithAttributeUnit("Bytes_sent", KiB),
+	}
+	events := parseAll(NewParser(proxy...), microsecondsContent)
+	expected := map[string]interface{}{"Query_time": 2.4, "Lock_time": 0.0015, "Bytes_sent": int64(2048), "Rows_examined": int64(10)}
+	for key, v := range expected {
+		if !reflect.DeepEqual(events[0][key], v) {
+			t.Errorf("expected %s %v (%T), got %v (%T)", key, v, v, events[0][key], events[0][key])
+		}
+	}
+
+	events = parseAll(NewParser(append(proxy, WithDurations())...), microsecondsContent)
+	if events[0]["Query_time"] != 2400*time.Millisecond {
+		t.Errorf("expected Query_time 2.4s, got %v", events[0]["Query_time"])
+	}
+
+	// Events of both sources aggregate alike.
+	a := NewAggregator()
+	for _, e := range parseAll(NewParser(proxy...), microsecondsContent) {
+		a.Add(e)
+	}
+	for _, e := range parseAll(NewParser(), secondsContent) {
+		a.Add(e)
+	}
+	results := a.Results()
+	if len(results) != 1 {
+		t.Fatalf("expected one fingerprint, got %d", len(results))
+	}
+	s := results[0]
+	if s.Count != 2 || math.Abs(s.TotalTime-3.6) > 1e-9 || s.MaxTime != 2.4 {
+		t.Errorf("expected 2 events taking 3.6s, at most 2.4s, got %d taking %v, at most %v", s.Count, s.TotalTime, s.MaxTime)
+	}
+	if d := s.Attribute("Bytes_sent"); d == nil || d.Sum != 6144 || d.Min != 2048 {
+		t.Errorf("expected 6144 bytes sent, at least 2048, got %+v", d)
+	}
+
+	fields := map[string]FieldSpec{}
+	for _, f := range NewParser(WithAttributeUnit("Net_wait", Milliseconds)).Schema() {
+		fields[f.Name] = f
+	}
+	for name, unit := range map[string]Unit{"Query_time": Seconds, "Bytes_sent": Bytes, "Rows_sent": 0, "Net_wait": Seconds, "Cop_wait_max": Seconds} {
+		if fields[name].Unit != unit {
+			t.Errorf("expected %s in %v, got %v", name, unit, fields[name].Unit)
+		}
+	}
+}
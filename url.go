@@ -0,0 +1,199 @@
+package mysqllog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// URLOption configures ParseURL.
+type URLOption func(*urlConfig)
+
+type urlConfig struct {
+	client     *http.Client
+	checkpoint *Checkpoint
+	parserOpts []Option
+}
+
+// WithHTTPClient makes requests with client instead of
+// http.DefaultClient.
+func WithHTTPClient(client *http.Client) URLOption {
+	return func(c *urlConfig) {
+		c.client = client
+	}
+}
+
+// WithURLCheckpoint resumes from the offset recorded for the URL in c,
+// and records there the end of the last complete event read, along with
+// the ETag of the response. c isn't saved.
+func WithURLCheckpoint(c *Checkpoint) URLOption {
+	return func(u *urlConfig) {
+		u.checkpoint = c
+	}
+}
+
+// WithURLParserOptions configures the Parser.
+func WithURLParserOptions(opts ...Option) URLOption {
+	return func(c *urlConfig) {
+		c.parserOpts = opts
+	}
+}
+
+// StatusError is a response to ParseURL other than 200 OK or 206
+// Partial Content, including a redirect that wasn't followed.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	// Location is where a redirect pointed.
+	Location string
+	// Message is the start of the response body.
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("mysqllog: GET %s: %s", e.URL, e.Status)
+	if e.Location != "" {
+		msg += " to " + e.Location
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// ParseURL streams the slow query log at the HTTP or HTTPS url, such as
+// an S3 presigned URL, through a Parser and calls fn with each event,
+// with url in "SourceFile". A body with Content-Encoding gzip, or gzip
+// content at a path ending in ".gz", is decompressed; zstd isn't
+// supported. Redirects are followed as by the client.
+//
+// A connection cut before the end of the body is returned as a
+// *ReadError, and the event being read is dropped rather than passed to
+// fn truncated. With WithURLCheckpoint, reading resumes at the recorded
+// offset with a Range request, or by skipping that much of the content
+// if the server doesn't support ranges or it's compressed. Content with
+// another ETag than the recorded one is read from the start.
+func ParseURL(ctx context.Context, url string, fn func(LogEvent), opts ...URLOption) error {
+	c := &urlConfig{client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	var offset int64
+	var etag string
+	if c.checkpoint != nil {
+		c.checkpoint.mu.Lock()
+		entry := c.checkpoint.offsets[url]
+		c.checkpoint.mu.Unlock()
+		offset, etag = entry.Offset, entry.ID
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	ranged := offset > 0 && !compressedPath(req.URL.Path)
+	if ranged {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		if etag != "" && !strings.HasPrefix(etag, "W/") {
+			req.Header.Set("If-Range", etag)
+		}
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	skip := offset
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		if id := resp.Header.Get("ETag"); id != "" && etag != "" && id != etag {
+			// Another file is at url.
+			skip = 0
+		}
+	case resp.StatusCode == http.StatusPartialContent && ranged:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), "bytes "+strconv.FormatInt(offset, 10)+"-") {
+			return fmt.Errorf("mysqllog: GET %s: unexpected Content-Range %q", url, resp.Header.Get("Content-Range"))
+		}
+		skip = 0
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && ranged:
+		// Nothing was added since the offset.
+		return nil
+	default:
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Location:   resp.Header.Get("Location"),
+			Message:    string(bytes.TrimSpace(msg)),
+		}
+	}
+
+	body, err := decodeBody(resp)
+	if err != nil {
+		return fmt.Errorf("mysqllog: GET %s: %v", url, err)
+	}
+	if skip > 0 {
+		if n, err := io.CopyN(ioutil.Discard, body, skip); err != nil {
+			if err == io.EOF {
+				// The content is shorter than what was read before.
+				if c.checkpoint != nil {
+					c.checkpoint.Delete(url)
+				}
+				return fmt.Errorf("mysqllog: GET %s: %d bytes is shorter than the checkpoint offset %d", url, n, skip)
+			}
+			return &ReadError{Offset: n, Err: err}
+		}
+	}
+
+	parser := NewParser(c.parserOpts...)
+	parser.offset = offset
+	r := &fileReader{path: url, r: bufio.NewReader(body), parser: parser, offset: offset, done: offset, clock: RealClock}
+	err = r.finish(fn)
+	if c.checkpoint != nil {
+		c.checkpoint.SetFile(url, resp.Header.Get("ETag"), r.done)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &ReadError{Offset: r.offset + int64(len(r.partial)), Err: err}
+	}
+	if errs := parser.Errors(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// compressedPath reports whether path names compressed content.
+func compressedPath(path string) bool {
+	return strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".zst")
+}
+
+// decodeBody returns the decompressed body of resp.
+func decodeBody(resp *http.Response) (io.Reader, error) {
+	if resp.Uncompressed {
+		// The client already decompressed it.
+		return resp.Body, nil
+	}
+	path := resp.Request.URL.Path
+	switch encoding := strings.ToLower(resp.Header.Get("Content-Encoding")); {
+	case encoding == "gzip" || encoding == "x-gzip" || (encoding == "" && strings.HasSuffix(path, ".gz")):
+		return gzip.NewReader(resp.Body)
+	case encoding == "zstd" || (encoding == "" && strings.HasSuffix(path, ".zst")):
+		return nil, fmt.Errorf("zstd content isn't supported")
+	case encoding != "" && encoding != "identity":
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,170 @@
+package mysqllog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func gzipString(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseURL(t *testing.T) {
+	var log strings.Builder
+	for i := 0; i < 200; i++ {
+		log.WriteString(slowEvent(i % 60))
+	}
+	content := log.String()
+	compressed := gzipString(t, content)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow.log":
+			w.Write([]byte(content))
+		case "/encoded.log":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(compressed)
+		case "/slow.log.gz":
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Write(compressed)
+		case "/cut.log.gz":
+			w.Header().Set("Content-Length", strconv.Itoa(len(compressed)))
+			w.Write(compressed[:len(compressed)/2])
+		case "/slow.log.zst":
+			w.Write([]byte("zstd"))
+		case "/moved":
+			http.Redirect(w, r, "/slow.log", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/slow.log", "/encoded.log", "/slow.log.gz", "/moved"} {
+		var events []LogEvent
+		err := ParseURL(context.Background(), server.URL+path, func(e LogEvent) { events = append(events, e) })
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if len(events) != 200 || events[199]["Statement"] != "SELECT 19;" || events[0]["SourceFile"] != server.URL+path {
+			t.Errorf("%s: unexpected events %d %v", path, len(events), events[0])
+		}
+	}
+
+	// A cut connection is an error, and the event being read is dropped.
+	var events []LogEvent
+	err := ParseURL(context.Background(), server.URL+"/cut.log.gz", func(e LogEvent) { events = append(events, e) })
+	if _, ok := err.(*ReadError); !ok {
+		t.Errorf("expected a *ReadError, got %v", err)
+	}
+	if len(events) == 0 || len(events) >= 200 {
+		t.Errorf("expected some of the events, got %d", len(events))
+	}
+	for _, e := range events {
+		if e["Statement"] == "" || e["Query_time"] != 1.0 {
+			t.Errorf("unexpected truncated event %v", e)
+		}
+	}
+
+	err = ParseURL(context.Background(), server.URL+"/slow.log.zst", func(LogEvent) {})
+	if err == nil || !strings.Contains(err.Error(), "zstd") {
+		t.Errorf("expected zstd to be unsupported, got %v", err)
+	}
+	err = ParseURL(context.Background(), server.URL+"/missing.log", func(LogEvent) {})
+	if se, ok := err.(*StatusError); !ok || se.StatusCode != http.StatusNotFound || se.Message != "404 page not found" {
+		t.Errorf("expected a 404 StatusError, got %v", err)
+	}
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	err = ParseURL(context.Background(), server.URL+"/moved", func(LogEvent) {}, WithHTTPClient(noRedirects))
+	if se, ok := err.(*StatusError); !ok || se.StatusCode != http.StatusFound || se.Location != "/slow.log" {
+		t.Errorf("expected a redirect StatusError, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ParseURL(ctx, server.URL+"/slow.log", func(LogEvent) {}); err == nil {
+		t.Error("expected an error after cancellation")
+	}
+}
+
+func TestParseURLCheckpoint(t *testing.T) {
+	content := slowEvent(1) + slowEvent(2)
+	two := "bytes=" + strconv.Itoa(len(content)) + "-"
+	three := "bytes=" + strconv.Itoa(len(content+slowEvent(3))) + "-"
+	etag := `"v1"`
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", etag)
+		if r.URL.Path == "/slow.log.gz" {
+			w.Write(gzipString(t, content))
+			return
+		}
+		http.ServeContent(w, r, "slow.log", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	checkpoint, err := OpenCheckpoint(filepath.Join(dir, "checkpoint.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parse := func(path string) []string {
+		var statements []string
+		err := ParseURL(context.Background(), server.URL+path, func(e LogEvent) {
+			statements = append(statements, e["Statement"].(string))
+		}, WithURLCheckpoint(checkpoint))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return statements
+	}
+	type TestCase struct {
+		path, append, etag string
+		statements         []string
+		rangeHeader        string
+	}
+	for i, c := range []TestCase{
+		{"/slow.log", "", `"v1"`, []string{"SELECT 1;", "SELECT 2;"}, ""},
+		// Nothing new.
+		{"/slow.log", "", `"v1"`, nil, two},
+		{"/slow.log", slowEvent(3), `"v1"`, []string{"SELECT 3;"}, two},
+		// Replaced, so If-Range gets the whole file.
+		{"/slow.log", "", `"v2"`, []string{"SELECT 1;", "SELECT 2;", "SELECT 3;"}, three},
+		{"/slow.log.gz", "", `"v2"`, []string{"SELECT 1;", "SELECT 2;", "SELECT 3;"}, ""},
+		// Compressed content is skipped up to the offset.
+		{"/slow.log.gz", slowEvent(4), `"v2"`, []string{"SELECT 4;"}, ""},
+	} {
+		content += c.append
+		etag = c.etag
+		if statements := parse(c.path); strings.Join(statements, " ") != strings.Join(c.statements, " ") {
+			t.Errorf("%d: expected %v, got %v", i, c.statements, statements)
+		}
+		if last := ranges[len(ranges)-1]; last != c.rangeHeader {
+			t.Errorf("%d: expected Range %q, got %q", i, c.rangeHeader, last)
+		}
+	}
+	if offset, ok := checkpoint.FileOffset(server.URL+"/slow.log.gz", `"v2"`); !ok || offset != int64(len(content)) {
+		t.Errorf("expected offset %d, got %d, %v", len(content), offset, ok)
+	}
+}
@@ -0,0 +1,140 @@
+package mysqllog
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of Problem.
+const (
+	ProblemMissing   = "missing"
+	ProblemType      = "type mismatch"
+	ProblemTruncated = "truncated"
+	ProblemInferred  = "inferred"
+)
+
+// Problem is an issue with an event found by Validate. Severity is one
+// of SeverityInfo, SeverityWarn and SeverityCritical.
+type Problem struct {
+	Key      string
+	Kind     string
+	Severity string
+}
+
+func (p Problem) String() string {
+	return p.Severity + ": " + p.Key + " " + p.Kind
+}
+
+// Validate returns the problems with e, in a fixed order:
+//
+//   - a missing Timestamp, User and Host, Query_time or Statement is
+//     critical, as is one of them with an unexpected type;
+//   - other attributes with a different type than the parser gives them
+//     are a warning, as is a statement ending in "...", which mysqllog
+//     sinks write for truncated statements;
+//   - values filled in by the parser rather than read, such as a User
+//     from WithUserHostInheritance, flagged by a "UserInferred" key, are
+//     info.
+func (e LogEvent) Validate() []Problem {
+	var problems []Problem
+	add := func(key, kind, severity string) {
+		problems = append(problems, Problem{Key: key, Kind: kind, Severity: severity})
+	}
+
+	switch e["Timestamp"].(type) {
+	case nil:
+		add("Timestamp", ProblemMissing, SeverityCritical)
+	case time.Time, string, int64, float64:
+	default:
+		add("Timestamp", ProblemType, SeverityCritical)
+	}
+	_, hasUser := e["User"]
+	_, hasHost := e["Host"]
+	if !hasUser && !hasHost {
+		add("User", ProblemMissing, SeverityCritical)
+	}
+	for _, key := range []string{"User", "Host"} {
+		if _, ok := e[key]; ok && !isString(e[key]) {
+			add(key, ProblemType, SeverityCritical)
+		}
+	}
+	switch e["Query_time"].(type) {
+	case nil:
+		add("Query_time", ProblemMissing, SeverityCritical)
+	case float64, int64, time.Duration:
+	default:
+		add("Query_time", ProblemType, SeverityCritical)
+	}
+	statement, ok := e["Statement"].(string)
+	switch {
+	case e["Statement"] != nil && !ok:
+		add("Statement", ProblemType, SeverityCritical)
+	case strings.TrimSpace(statement) == "" && e["AdminCommand"] == nil:
+		add("Statement", ProblemMissing, SeverityCritical)
+	case strings.HasSuffix(statement, "..."):
+		add("Statement", ProblemTruncated, SeverityWarn)
+	}
+
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "Query_time" {
+			continue
+		}
+		kind, ok := attributeTypes[key]
+		if !ok {
+			kind, ok = tidbAttributeTypes[key]
+		}
+		if ok && !hasKind(e[key], kind, key) {
+			add(key, ProblemType, SeverityWarn)
+		}
+	}
+	for _, key := range keys {
+		if strings.HasSuffix(key, "Inferred") && e[key] == true {
+			add(strings.TrimSuffix(key, "Inferred"), ProblemInferred, SeverityInfo)
+		}
+	}
+	return problems
+}
+
+// IsComplete reports whether e has no critical problems: it has a
+// timestamp, a user or host, a Query_time and a statement.
+func (e LogEvent) IsComplete() bool {
+	return complete(e.Validate())
+}
+
+func complete(problems []Problem) bool {
+	for _, p := range problems {
+		if p.Severity == SeverityCritical {
+			return false
+		}
+	}
+	return true
+}
+
+func isString(v interface{}) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+// hasKind reports whether v, the value of attribute key, is of kind.
+// Times in seconds are time.Durations with WithDurations.
+func hasKind(v interface{}, kind AttributeKind, key string) bool {
+	switch v.(type) {
+	case float64:
+		return kind == AttributeFloat
+	case time.Duration:
+		return kind == AttributeDuration || kind == AttributeFloat && isDurationAttribute(key)
+	case int64:
+		return kind == AttributeInt
+	case string:
+		return kind == AttributeString
+	case bool:
+		return kind == AttributeBool
+	}
+	return false
+}
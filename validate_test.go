@@ -0,0 +1,122 @@
+package mysqllog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	complete := func() LogEvent {
+		return LogEvent{
+			"Timestamp":     time.Date(2017, 12, 24, 2, 42, 0, 0, time.UTC),
+			"User":          "app",
+			"Host":          "web1",
+			"Query_time":    1.5,
+			"Rows_examined": int64(10),
+			"Statement":     "SELECT 1",
+		}
+	}
+	type TestCase struct {
+		Name     string
+		Change   func(e LogEvent)
+		Problems []Problem
+	}
+	for _, c := range []TestCase{
+		{"complete", func(LogEvent) {}, nil},
+		{"host only", func(e LogEvent) { delete(e, "User") }, nil},
+		{"admin command", func(e LogEvent) { e["Statement"] = ""; e["AdminCommand"] = "Quit" }, nil},
+		{"no timestamp", func(e LogEvent) { delete(e, "Timestamp") }, []Problem{{"Timestamp", ProblemMissing, SeverityCritical}}},
+		{"no user or host", func(e LogEvent) { delete(e, "User"); delete(e, "Host") }, []Problem{{"User", ProblemMissing, SeverityCritical}}},
+		{"no query time", func(e LogEvent) { delete(e, "Query_time") }, []Problem{{"Query_time", ProblemMissing, SeverityCritical}}},
+		{"empty statement", func(e LogEvent) { e["Statement"] = " \n" }, []Problem{{"Statement", ProblemMissing, SeverityCritical}}},
+		{"truncated", func(e LogEvent) { e["Statement"] = "SELECT * FROM ..." }, []Problem{{"Statement", ProblemTruncated, SeverityWarn}}},
+		{"wrong types", func(e LogEvent) {
+			e["Timestamp"] = []byte("now")
+			e["Host"] = 42
+			e["Query_time"] = "1.5"
+			e["Statement"] = 7
+			e["Rows_examined"] = 10.0
+			e["Full_scan"] = "Yes"
+		}, []Problem{
+			{"Timestamp", ProblemType, SeverityCritical},
+			{"Host", ProblemType, SeverityCritical},
+			{"Query_time", ProblemType, SeverityCritical},
+			{"Statement", ProblemType, SeverityCritical},
+			{"Full_scan", ProblemType, SeverityWarn},
+			{"Rows_examined", ProblemType, SeverityWarn},
+		}},
+		{"inferred", func(e LogEvent) { e["UserInferred"] = true }, []Problem{{"User", ProblemInferred, SeverityInfo}}},
+	} {
+		e := complete()
+		c.Change(e)
+		if problems := e.Validate(); !reflect.DeepEqual(problems, c.Problems) {
+			t.Errorf("%s: expected %v, got %v", c.Name, c.Problems, problems)
+		}
+		expected := true
+		for _, p := range c.Problems {
+			if p.Severity == SeverityCritical {
+				expected = false
+			}
+		}
+		if e.IsComplete() != expected {
+			t.Errorf("%s: expected IsComplete to be %v", c.Name, expected)
+		}
+	}
+	if s := (Problem{"Query_time", ProblemMissing, SeverityCritical}).String(); s != "critical: Query_time missing" {
+		t.Errorf("unexpected string %q", s)
+	}
+}
+
+func TestParseReaderInvalid(t *testing.T) {
+	events := parseAll(&Parser{}, content)
+	for _, e := range events {
+		if !e.IsComplete() {
+			t.Fatalf("expected the fixture events to be complete, got %v", e.Validate())
+		}
+	}
+
+	log := content + "# Time: 2017-12-24T02:42:00.126000Z\n# Query_time: 1.0\nSELECT 2;\n"
+	valid, invalid := 0, 0
+	err := ParseReader(strings.NewReader(log), func(LogEvent) { valid++ }, WithInvalid(func(e LogEvent, problems []Problem) {
+		invalid++
+		if len(problems) != 2 || problems[0].Key != "Timestamp" || problems[1].Key != "User" {
+			t.Errorf("unexpected problems %v", problems)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid != len(events) || invalid != 1 {
+		t.Errorf("expected %d valid events and 1 invalid, got %d and %d", len(events), valid, invalid)
+	}
+}
+
+func TestValidateDurations(t *testing.T) {
+	events := parseAll(NewParser(WithDurations()), content)
+	for _, e := range events {
+		if problems := e.Validate(); len(problems) != 0 {
+			t.Errorf("expected no problems with durations, got %v for %v", problems, e)
+		}
+	}
+	valid := 0
+	err := ParseReader(strings.NewReader(content), func(LogEvent) { valid++ }, WithParser(NewParser(WithDurations())),
+		WithInvalid(func(e LogEvent, problems []Problem) {
+			t.Errorf("unexpected problems %v", problems)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid != len(events) {
+		t.Errorf("expected %d valid events, got %d", len(events), valid)
+	}
+
+	e := LogEvent{"Query_time": 1500 * time.Millisecond}
+	if n, ok := e.Int64("Query_time"); !ok || n != 1 {
+		t.Errorf("expected 1 whole second, got %d", n)
+	}
+	if !e.Equal(LogEvent{"Query_time": 1.5}) {
+		t.Error("expected a duration to equal its seconds")
+	}
+}
@@ -0,0 +1,72 @@
+package mysqllog
+
+import "strings"
+
+// Statement verbs returned by Verb.
+const (
+	VerbSelect  = "SELECT"
+	VerbInsert  = "INSERT"
+	VerbUpdate  = "UPDATE"
+	VerbDelete  = "DELETE"
+	VerbReplace = "REPLACE"
+	VerbCall    = "CALL"
+	VerbExecute = "EXECUTE"
+	VerbPrepare = "PREPARE"
+	VerbSet     = "SET"
+	VerbShow    = "SHOW"
+	VerbDDL     = "DDL"
+	VerbCommit  = "COMMIT"
+	VerbComment = "COMMENT"
+	VerbOther   = "OTHER"
+)
+
+// WithVerbExtraction sets "Verb" on each event to the Verb of its statement.
+func WithVerbExtraction() Option {
+	return func(p *Parser) {
+		p.verbs = true
+	}
+}
+
+// WithSkipTrivial drops events whose statement is only comments, such as
+// the "/* ping */" of connection pools, which are VerbComment, counting
+// them in Stats.TrivialSkipped.
+func WithSkipTrivial() Option {
+	return func(p *Parser) {
+		p.skipTrivial = true
+	}
+}
+
+// Verb returns the leading verb of statement, such as VerbSelect, or
+// VerbOther if it isn't one of the Verb constants. Leading comments,
+// optimizer hints, parentheses and EXPLAIN are skipped, so
+// "EXPLAIN SELECT ..." is a VerbSelect. CREATE, ALTER, DROP, RENAME and
+// TRUNCATE are all VerbDDL. Statements with only comments, whitespace or
+// semicolons are VerbComment, and the empty statement VerbOther.
+func Verb(statement string) string {
+	if isCommentOnly(statement) {
+		return VerbComment
+	}
+	tokens := tokenize(statement)
+	for len(tokens) > 0 && tokens[0].isPunct('(') {
+		tokens = tokens[1:]
+	}
+	if len(tokens) > 0 && (tokens[0].is("explain") || tokens[0].is("describe") || tokens[0].is("desc")) {
+		tokens = tokens[1:]
+		for len(tokens) > 0 && (tokens[0].is("analyze") || tokens[0].is("extended") || tokens[0].is("partitions")) {
+			tokens = tokens[1:]
+		}
+		if len(tokens) >= 3 && tokens[0].is("format") && tokens[1].isPunct('=') {
+			tokens = tokens[3:]
+		}
+	}
+	if len(tokens) == 0 || tokens[0].kind != tokenWord {
+		return VerbOther
+	}
+	switch verb := strings.ToUpper(tokens[0].text); verb {
+	case VerbSelect, VerbInsert, VerbUpdate, VerbDelete, VerbReplace, VerbCall, VerbExecute, VerbPrepare, VerbSet, VerbShow, VerbCommit:
+		return verb
+	case "CREATE", "ALTER", "DROP", "RENAME", "TRUNCATE":
+		return VerbDDL
+	}
+	return VerbOther
+}
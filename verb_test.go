@@ -0,0 +1,81 @@
+package mysqllog
+
+import "testing"
+
+func TestVerb(t *testing.T) {
+	type TestCase struct {
+		Statement string
+		Verb      string
+	}
+	for _, tc := range []TestCase{
+		{"SELECT 1", VerbSelect},
+		{"  select * from t", VerbSelect},
+		{"/* app:checkout */ UPDATE t SET a = 1", VerbUpdate},
+		{"-- cleanup\nDELETE FROM t", VerbDelete},
+		{"# note\ninsert into t values (1)", VerbInsert},
+		{"SELECT /*+ MAX_EXECUTION_TIME(1000) */ * FROM t", VerbSelect},
+		{"/*+ hint */ replace into t values (1)", VerbReplace},
+		{"EXPLAIN FORMAT=JSON select * from t", VerbSelect},
+		{"explain analyze delete from t", VerbDelete},
+		{"(SELECT 1) UNION (SELECT 2)", VerbSelect},
+		{"call proc()", VerbCall},
+		{"EXECUTE stmt1 USING @a", VerbExecute},
+		{"prepare stmt1 FROM 'SELECT 1'", VerbPrepare},
+		{"SET NAMES utf8", VerbSet},
+		{"show tables", VerbShow},
+		{"CREATE TABLE t (a int)", VerbDDL},
+		{"alter table t add column b int", VerbDDL},
+		{"DROP TABLE t", VerbDDL},
+		{"commit", VerbCommit},
+		{"BEGIN", VerbOther},
+		{"", VerbOther},
+		{"/* only a comment */", VerbComment},
+		{"-- keepalive\n;", VerbComment},
+		{";", VerbComment},
+	} {
+		if verb := Verb(tc.Statement); verb != tc.Verb {
+			t.Errorf("%q: expected %s, got %s", tc.Statement, tc.Verb, verb)
+		}
+	}
+}
+
+func TestAggregatorRollupVerb(t *testing.T) {
+	events := parseAll(NewParser(WithVerbExtraction()), generateEvents(4, 2))
+	for _, e := range events {
+		if e["Verb"] != VerbSelect {
+			t.Errorf("expected Verb to be set, got %v", e["Verb"])
+		}
+	}
+
+	a := NewAggregator(WithRollups("Verb"))
+	for _, e := range []LogEvent{
+		{"Statement": "SELECT 1", "Query_time": 1.0},
+		{"Statement": "UPDATE t SET a = 1", "Query_time": 3.0, "Verb": VerbUpdate},
+	} {
+		a.Add(e)
+	}
+	rollups := a.Rollup("Verb")
+	if len(rollups) != 2 || rollups[0].Value != VerbUpdate || rollups[1].Value != VerbSelect {
+		t.Errorf("unexpected rollups %+v", rollups)
+	}
+}
+
+func TestWithSkipTrivial(t *testing.T) {
+	log := "# Query_time: 0.1\nSET timestamp=1514083320;\n/* ping */;\n" +
+		"# Query_time: 0.1\nSET timestamp=1514083320;\n-- keepalive\n" +
+		"# Query_time: 0.1\nSET timestamp=1514083320;\n;\n" +
+		"# Query_time: 0.2\nSET timestamp=1514083320;\n/* app */ SELECT 1;\n"
+	events := parseAll(NewParser(), log)
+	if len(events) != 4 || Fingerprint(events[0]["Statement"].(string)) != CommentOnlyFingerprint {
+		t.Fatalf("expected 4 events starting with a comment-only one, got %v", events)
+	}
+
+	p := NewParser(WithSkipTrivial())
+	events = parseAll(p, log)
+	if len(events) != 1 || events[0]["Statement"] != "/* app */ SELECT 1;" {
+		t.Errorf("expected only the SELECT, got %v", events)
+	}
+	if skipped := p.Stats().TrivialSkipped; skipped != 3 {
+		t.Errorf("expected 3 skipped events, got %d", skipped)
+	}
+}
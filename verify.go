@@ -0,0 +1,211 @@
+package mysqllog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// VerifyReport is what Verify found in a log.
+type VerifyReport struct {
+	Events int
+	Lines  int
+	Bytes  int64
+	// Incomplete is the number of events with a critical Problem (see
+	// LogEvent.Validate), and Missing the number of events missing each
+	// attribute.
+	Incomplete int
+	Missing    map[string]int
+	// Errors are the ParseErrors of the strict Parser, other than
+	// unknown attributes.
+	Errors []*ParseError
+	// UnknownAttributes are the header attributes the Parser has no type
+	// for, sorted, as a newer server version may add.
+	UnknownAttributes []string
+	Dialect           Dialect
+	// EndsMidEvent is set if the log ends in a header without a
+	// statement, inside a string literal, or without a line break, as
+	// when it was cut while being written or copied.
+	EndsMidEvent bool
+	// Presence is the number of events with each key, and Coverage its
+	// CoverageReport with DefaultCoverage, or the expectations given to
+	// CheckCoverage.
+	Presence map[string]int64
+	Coverage []AttributeCoverage
+}
+
+// CheckCoverage sets r.Coverage for the presence rates in expected
+// instead of DefaultCoverage.
+func (r *VerifyReport) CheckCoverage(expected map[string]float64) {
+	r.Coverage = coverageReport(int64(r.Events), r.Presence, expected)
+}
+
+// lowCoverage returns the keys with a low presence rate.
+func (r *VerifyReport) lowCoverage() []string {
+	var keys []string
+	for _, c := range r.Coverage {
+		if c.Low {
+			keys = append(keys, c.Key)
+		}
+	}
+	return keys
+}
+
+// HasErrors reports whether the Parser reported errors.
+func (r *VerifyReport) HasErrors() bool {
+	return len(r.Errors) > 0
+}
+
+// HasWarnings reports whether some events are incomplete, some
+// attributes are unknown or present in fewer events than expected, or
+// the log ends mid-event.
+func (r *VerifyReport) HasWarnings() bool {
+	return r.Incomplete > 0 || len(r.UnknownAttributes) > 0 || r.EndsMidEvent || len(r.lowCoverage()) > 0
+}
+
+// Verify checks that the slow query log read from r parses cleanly with
+// a strict Parser configured with opts, without emitting events. Unlike
+// ParseReader, it doesn't stop at the first ParseError. Errors reading r
+// are returned as a *ReadError along with the report so far.
+func Verify(r io.Reader, opts ...Option) (*VerifyReport, error) {
+	p := NewParser(append([]Option{WithStrict(), WithUnknownAttributes(KeepUnknown), WithPresenceStats()}, opts...)...)
+	report := &VerifyReport{Missing: map[string]int{}}
+	unknown := map[string]bool{}
+	check := func(e LogEvent) {
+		if e != nil {
+			report.Events++
+			problems := e.Validate()
+			if !complete(problems) {
+				report.Incomplete++
+			}
+			for _, problem := range problems {
+				if problem.Kind == ProblemMissing {
+					report.Missing[problem.Key]++
+				}
+			}
+			attributes, _ := e["Unknown"].(map[string]string)
+			for key := range attributes {
+				unknown[key] = true
+			}
+		}
+		for _, err := range p.Errors() {
+			if err.Reason != ErrUnknownAttribute {
+				report.Errors = append(report.Errors, err)
+			}
+		}
+	}
+
+	reader := bufio.NewReader(r)
+	var last string
+	var err error
+	for {
+		var line string
+		line, err = reader.ReadString('\n')
+		if line != "" {
+			last = line
+			check(p.ConsumeLine(line))
+		}
+		if err != nil {
+			break
+		}
+	}
+	report.EndsMidEvent = (last != "" && !strings.HasSuffix(last, "\n")) ||
+		(p.inHeader && !p.inQuery) || (p.inQuery && p.quote != 0)
+	check(p.Flush())
+	report.Lines = p.line
+	report.Bytes = p.stats.Bytes
+	report.Dialect = p.Dialect()
+	report.Presence = p.Stats().Presence
+	report.CheckCoverage(nil)
+	for key := range unknown {
+		report.UnknownAttributes = append(report.UnknownAttributes, key)
+	}
+	sort.Strings(report.UnknownAttributes)
+	if err != io.EOF {
+		return report, &ReadError{Offset: report.Bytes, Err: err}
+	}
+	return report, nil
+}
+
+// Write prints r for people, a line per finding.
+func (r *VerifyReport) Write(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d events, %d lines, %d bytes, dialect %s\n", r.Events, r.Lines, r.Bytes, r.Dialect)
+	if r.Incomplete > 0 {
+		keys := make([]string, 0, len(r.Missing))
+		for key := range r.Missing {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		missing := make([]string, len(keys))
+		for i, key := range keys {
+			missing[i] = fmt.Sprintf("%s %d", key, r.Missing[key])
+		}
+		fmt.Fprintf(&b, "warning: %d incomplete events, missing %s\n", r.Incomplete, strings.Join(missing, ", "))
+	}
+	if len(r.UnknownAttributes) > 0 {
+		fmt.Fprintf(&b, "warning: unknown attributes %s\n", strings.Join(r.UnknownAttributes, ", "))
+	}
+	if low := r.lowCoverage(); len(low) > 0 {
+		fmt.Fprintf(&b, "warning: attributes in fewer events than expected %s\n", strings.Join(low, ", "))
+	}
+	if r.EndsMidEvent {
+		b.WriteString("warning: the log ends mid-event\n")
+	}
+	for _, err := range r.Errors {
+		fmt.Fprintf(&b, "error: %s\n", strings.TrimPrefix(err.Error(), "mysqllog: "))
+	}
+	if len(r.Coverage) > 0 {
+		b.WriteByte('\n')
+		if err := WriteCoverage(&b, r.Coverage); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// MarshalJSON writes r as an object with snake_case keys.
+func (r *VerifyReport) MarshalJSON() ([]byte, error) {
+	type verifyError struct {
+		Line    int    `json:"line"`
+		Offset  int64  `json:"offset"`
+		Section string `json:"section"`
+		Reason  string `json:"reason"`
+		Text    string `json:"text"`
+	}
+	errs := make([]verifyError, len(r.Errors))
+	for i, err := range r.Errors {
+		errs[i] = verifyError{err.Line, err.Offset, err.Section, strings.TrimPrefix(err.Reason.Error(), "mysqllog: "), err.Text}
+	}
+	unknown := r.UnknownAttributes
+	if unknown == nil {
+		unknown = []string{}
+	}
+	type attributeCoverage struct {
+		Attribute string  `json:"attribute"`
+		Events    int64   `json:"events"`
+		Rate      float64 `json:"rate"`
+		Expected  float64 `json:"expected,omitempty"`
+		Low       bool    `json:"low,omitempty"`
+	}
+	coverage := make([]attributeCoverage, len(r.Coverage))
+	for i, c := range r.Coverage {
+		coverage[i] = attributeCoverage{c.Key, c.Events, c.Rate, c.Expected, c.Low}
+	}
+	return json.Marshal(struct {
+		Events            int                 `json:"events"`
+		Lines             int                 `json:"lines"`
+		Bytes             int64               `json:"bytes"`
+		Incomplete        int                 `json:"incomplete"`
+		Missing           map[string]int      `json:"missing"`
+		Errors            []verifyError       `json:"errors"`
+		UnknownAttributes []string            `json:"unknown_attributes"`
+		Dialect           string              `json:"dialect"`
+		EndsMidEvent      bool                `json:"ends_mid_event"`
+		Coverage          []attributeCoverage `json:"coverage"`
+	}{r.Events, r.Lines, r.Bytes, r.Incomplete, r.Missing, errs, unknown, r.Dialect.String(), r.EndsMidEvent, coverage})
+}
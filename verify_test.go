@@ -0,0 +1,83 @@
+package mysqllog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	event := "# Time: 2023-08-01T10:00:00.000000Z\n# User@Host: app[app] @ web1 []\n# Query_time: 1.0\n" +
+		"SET timestamp=1690884000;\nSELECT 1;\n"
+	report, err := Verify(strings.NewReader(event + event))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Events != 2 || report.Lines != 10 || report.HasErrors() || report.HasWarnings() {
+		t.Errorf("expected a clean report, got %+v", report)
+	}
+
+	input := "# User@Host: app[app] @ web1 []\n# Query_time: 1.0 Rows_sent: 1 Widgets: 3\nSET timestamp=1690884000;\nSELECT 1;\n" +
+		"# Time: yesterday\n# User@Host: app[app] @ web1 []\n# Query_time: 1.0\nSET timestamp=1690884001;\nSELECT 2;\n" +
+		"# User@Host: app[app] @ web1 []\nSET timestamp=1690884002;\nSELECT 3;\n" +
+		"# User@Host: app[app] @ web1 []\n# Query_time: 1.0\nSELECT 4;\n" +
+		"# User@Host: app[app] @ web1 []\n# Query_time: 1.0\n"
+	report, err = Verify(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last event has no statement, so it isn't parsed.
+	if report.Events != 4 || report.Incomplete != 2 || report.Missing["Query_time"] != 1 || report.Missing["Timestamp"] != 1 {
+		t.Errorf("unexpected counts %+v", report)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Line != 5 || report.Errors[0].Reason != ErrBadTimestamp {
+		t.Errorf("unexpected errors %v", report.Errors)
+	}
+	if strings.Join(report.UnknownAttributes, ",") != "Widgets" || !report.EndsMidEvent || report.Dialect != MySQL {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	var buf bytes.Buffer
+	report.Write(&buf)
+	expected := `4 events, 17 lines, 392 bytes, dialect MySQL
+warning: 2 incomplete events, missing Query_time 1, Timestamp 1
+warning: unknown attributes Widgets
+warning: attributes in fewer events than expected Query_time, Timestamp
+warning: the log ends mid-event
+error: line 5 (header): bad timestamp: "# Time: yesterday"
+
+attribute    events    rate expected
+Host              4  100.0%    99.0%
+Query_time        3   75.0%    99.0% low
+Rows_sent         1   25.0%        -
+Statement         4  100.0%    99.0%
+Timestamp         3   75.0%    99.0% low
+Unknown           1   25.0%        -
+User              4  100.0%    99.0%
+`
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Errors []struct {
+			Line   int    `json:"line"`
+			Reason string `json:"reason"`
+		} `json:"errors"`
+		EndsMidEvent bool `json:"ends_mid_event"`
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil || len(decoded.Errors) != 1 || decoded.Errors[0].Reason != "bad timestamp" || !decoded.EndsMidEvent {
+		t.Errorf("unexpected JSON %s", b)
+	}
+
+	// A cut line or string literal.
+	for _, input := range []string{event + "# Time: 2023", "# Query_time: 1\nSELECT 'a\n\nb"} {
+		if report, _ := Verify(strings.NewReader(input)); !report.EndsMidEvent {
+			t.Errorf("%q: expected the log to end mid-event", input)
+		}
+	}
+}
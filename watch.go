@@ -0,0 +1,158 @@
+package mysqllog
+
+import (
+	"context"
+	"path/filepath"
+)
+
+// WatchDir follows the slow query logs in dir whose names match pattern
+// (see filepath.Match), such as per-day files like "slow-*.log", and
+// calls fn with each event, with the path of its file in "SourceFile".
+// It tails the file that sorts last. When a
+// file sorting after it appears, the current file is read to the end,
+// its last event is flushed, and the watcher moves on to the next file.
+//
+// Without a checkpoint, the newest file is read from the start. With
+// WithCheckpoint or WithCheckpointStore, the watcher resumes from the oldest file that has an
+// offset recorded, and forgets files it has read completely.
+//
+// Each file is parsed by a Parser of its own, so an event never mixes
+// lines of two files; logs read concurrently need a ParserPool instead.
+// Files are polled as with TailFile. WatchDir returns when ctx is done
+// or a file can't be read. An event still being written when ctx is
+// done isn't passed to fn, and is read again on resuming from the
+// checkpoint.
+func WatchDir(ctx context.Context, dir string, pattern string, fn func(LogEvent), opts ...TailOption) error {
+	c := newTailConfig(opts)
+	matches := func() ([]string, error) {
+		return filepath.Glob(filepath.Join(dir, pattern))
+	}
+
+	files, err := matches()
+	if err != nil {
+		return err
+	}
+	cp := newCheckpointer(c)
+	fn = cp.count(fn)
+	var current *fileReader
+	defer func() {
+		if current != nil {
+			current.close()
+		}
+	}()
+	open := func(path string) error {
+		r, old, err := resumeFile(c, path)
+		if err != nil {
+			return err
+		}
+		current = r
+		if old == nil {
+			return nil
+		}
+		if err := finishRotated(old, fn); err != nil {
+			return err
+		}
+		return cp.save(current)
+	}
+	start, err := watchStart(files, c)
+	if err != nil {
+		return err
+	}
+	if start != "" {
+		if err := open(start); err != nil {
+			return err
+		}
+	}
+
+	p := &poller{c: c}
+	for {
+		select {
+		case <-ctx.Done():
+			if err := cp.save(current); err != nil {
+				return err
+			}
+			return ctx.Err()
+		default:
+		}
+		c.reconfigure(current)
+		if current != nil {
+			read, err := current.read(fn, c.maxRead)
+			if err != nil {
+				return err
+			}
+			if read {
+				if err := cp.due(current); err != nil {
+					return err
+				}
+				p.active()
+				continue
+			}
+		}
+
+		files, err := matches()
+		if err != nil {
+			return err
+		}
+		if next := nextFile(files, current); next != "" {
+			if current != nil {
+				if err := current.finish(fn); err != nil {
+					return err
+				}
+				current.close()
+				done := current.path
+				current = nil
+				if err := cp.delete(done); err != nil {
+					return err
+				}
+			}
+			if err := open(next); err != nil {
+				return err
+			}
+			if err := cp.save(current); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if current != nil {
+			current.idle(c.idleFlush, fn)
+		}
+		if err := cp.save(current); err != nil {
+			return err
+		}
+		p.idle(ctx)
+	}
+}
+
+// watchStart returns the file to start watching: the oldest one with a
+// checkpoint state, or else the newest.
+func watchStart(files []string, c *tailConfig) (string, error) {
+	if len(files) == 0 {
+		return "", nil
+	}
+	for _, file := range files {
+		if _, ok, err := c.load(file); err != nil {
+			return "", err
+		} else if ok {
+			return file, nil
+		}
+	}
+	return files[len(files)-1], nil
+}
+
+// nextFile returns the first of the sorted files after current, or the
+// newest one if there's no current file.
+func nextFile(files []string, current *fileReader) string {
+	if current == nil {
+		if len(files) == 0 {
+			return ""
+		}
+		return files[len(files)-1]
+	}
+	for _, file := range files {
+		if file > current.path {
+			return file
+		}
+	}
+	return ""
+}
@@ -0,0 +1,145 @@
+package mysqllog
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func slowEvent(n int) string {
+	return fmt.Sprintf("# Time: 2023-08-01T10:00:%02d.000000Z\n# User@Host: app[app] @ web1 []\n# Query_time: 1.0 Lock_time: 0.0 Rows_sent: 1 Rows_examined: 1\nSELECT %d;\n", n, n)
+}
+
+func appendFile(t *testing.T, path, data string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fastPoll polls every few milliseconds, backing off up to 20ms.
+var fastPoll = []TailOption{WithPollInterval(5 * time.Millisecond), WithIdleBackoff(20 * time.Millisecond)}
+
+type watchRun struct {
+	events chan string
+	cancel context.CancelFunc
+	err    chan error
+}
+
+// startFollow runs follow in the background, collecting statements.
+func startFollow(follow func(ctx context.Context, fn func(LogEvent)) error) *watchRun {
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &watchRun{events: make(chan string, 100), cancel: cancel, err: make(chan error, 1)}
+	go func() {
+		run.err <- follow(ctx, func(e LogEvent) {
+			run.events <- e["Statement"].(string)
+		})
+	}()
+	return run
+}
+
+func startWatch(t *testing.T, dir string, opts ...TailOption) *watchRun {
+	return startFollow(func(ctx context.Context, fn func(LogEvent)) error {
+		return WatchDir(ctx, dir, "slow-*.log", fn, append(opts, fastPoll...)...)
+	})
+}
+
+// expect waits for the next statements.
+func (w *watchRun) expect(t *testing.T, statements ...string) {
+	for _, expected := range statements {
+		select {
+		case got := <-w.events:
+			if got != expected {
+				t.Fatalf("expected %q, got %q", expected, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %q", expected)
+		}
+	}
+}
+
+func (w *watchRun) stop(t *testing.T) {
+	w.cancel()
+	if err := <-w.err; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if len(w.events) > 0 {
+		t.Errorf("unexpected event %q", <-w.events)
+	}
+}
+
+func TestWatchDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	day1 := filepath.Join(dir, "slow-2023-08-01.log")
+	day2 := filepath.Join(dir, "slow-2023-08-02.log")
+	checkpointPath := filepath.Join(dir, "checkpoint.json")
+
+	// An older day that's already complete is skipped.
+	appendFile(t, filepath.Join(dir, "slow-2023-07-31.log"), slowEvent(0))
+	third := slowEvent(3)
+	appendFile(t, day1, slowEvent(1)+slowEvent(2)+third[:40])
+
+	checkpoint, err := OpenCheckpoint(checkpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := startWatch(t, dir, WithCheckpoint(checkpoint))
+	run.expect(t, "SELECT 1;", "SELECT 2;")
+
+	// The next day is written elsewhere and renamed into place while
+	// the end of the previous day is still unread.
+	appendFile(t, day1, third[40:])
+	tmp := filepath.Join(dir, "next.tmp")
+	appendFile(t, tmp, slowEvent(5)+slowEvent(6))
+	if err := os.Rename(tmp, day2); err != nil {
+		t.Fatal(err)
+	}
+	run.expect(t, "SELECT 3;", "SELECT 5;")
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+
+	checkpoint, err = OpenCheckpoint(checkpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := checkpoint.Files(); len(files) != 1 || files[0] != day2 {
+		t.Errorf("expected only the current file in the checkpoint, got %v", files)
+	}
+	if offset, _ := checkpoint.Offset(day2); offset != int64(len(slowEvent(5))) {
+		t.Errorf("expected the offset at the start of the pending event, got %d", offset)
+	}
+
+	// Restarting resumes with the pending event, without duplicates.
+	appendFile(t, day2, slowEvent(7))
+	run = startWatch(t, dir, WithCheckpoint(checkpoint))
+	run.expect(t, "SELECT 6;")
+	time.Sleep(20 * time.Millisecond)
+	run.stop(t)
+}
+
+func TestWatchDirWithoutFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqllog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	run := startWatch(t, dir)
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, filepath.Join(dir, "slow-2023-08-01.log"), strings.Repeat(slowEvent(1), 2))
+	run.expect(t, "SELECT 1;")
+	run.stop(t)
+}
@@ -0,0 +1,179 @@
+package mysqllog
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultWindows are the windows tracked by NewWindowStats when none are given.
+var DefaultWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// FingerprintTotal is the activity of a fingerprint within a window.
+type FingerprintTotal struct {
+	Fingerprint string
+	Count       int64
+	TotalTime   float64
+}
+
+// WindowSnapshot summarizes the events within a window ending now.
+type WindowSnapshot struct {
+	Window    time.Duration
+	Events    int64
+	TotalTime float64
+	// P95Time is approximate; see Sketch.
+	P95Time float64
+	// Top holds the fingerprints with the most total Query_time.
+	Top []FingerprintTotal
+}
+
+// WindowStats is a Sink keeping rolling statistics over recent windows
+// for live tailing. It stores one aggregate per second of the longest
+// window, so memory doesn't grow with the number of events. Events are
+// attributed to the second of their timestamp rather than the time they
+// arrive, and events older than the longest window are ignored.
+// Snapshot may be called from any goroutine.
+type WindowStats struct {
+	// TopN is the number of fingerprints in each snapshot; 5 if zero.
+	TopN int
+	// Clock tells the time, RealClock if nil. See WithEventClock to
+	// replay a log.
+	Clock Clock
+
+	mu      sync.Mutex
+	windows []time.Duration
+	slots   []windowSlot
+}
+
+type windowSlot struct {
+	second       int64
+	events       int64
+	totalTime    float64
+	latencies    *Sketch
+	fingerprints map[string]*FingerprintTotal
+}
+
+// NewWindowStats returns WindowStats for windows, or DefaultWindows if
+// none are given. Windows are rounded up to whole seconds.
+func NewWindowStats(windows ...time.Duration) *WindowStats {
+	if len(windows) == 0 {
+		windows = DefaultWindows
+	}
+	var longest time.Duration
+	for _, w := range windows {
+		if w > longest {
+			longest = w
+		}
+	}
+	size := int((longest + time.Second - 1) / time.Second)
+	if size < 1 {
+		size = 1
+	}
+	return &WindowStats{
+		windows: append([]time.Duration(nil), windows...),
+		slots:   make([]windowSlot, size),
+	}
+}
+
+func (w *WindowStats) clock() time.Time {
+	return clockOf(w.Clock).Now()
+}
+
+// Write records e in the slot for its timestamp, or for the current
+// second if it has none.
+func (w *WindowStats) Write(e LogEvent) error {
+	ts, ok := EventTime(e)
+	if !ok {
+		ts = w.clock()
+	}
+	second := ts.Unix()
+	queryTime, _ := e.Float64("Query_time")
+	statement, _ := e["Statement"].(string)
+	fingerprint := Fingerprint(statement)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.clock().Unix()-second >= int64(len(w.slots)) {
+		return nil
+	}
+	slot := &w.slots[int(second%int64(len(w.slots)))]
+	if slot.second != second {
+		if slot.second > second {
+			// The slot already holds a newer second.
+			return nil
+		}
+		*slot = windowSlot{
+			second:       second,
+			latencies:    NewSketch(DefaultSketchAccuracy),
+			fingerprints: map[string]*FingerprintTotal{},
+		}
+	}
+	slot.events++
+	slot.totalTime += queryTime
+	slot.latencies.Add(queryTime)
+	f := slot.fingerprints[fingerprint]
+	if f == nil {
+		f = &FingerprintTotal{Fingerprint: fingerprint}
+		slot.fingerprints[fingerprint] = f
+	}
+	f.Count++
+	f.TotalTime += queryTime
+	return nil
+}
+
+// Close implements Sink. It does nothing.
+func (w *WindowStats) Close() error {
+	return nil
+}
+
+// Snapshot returns statistics for each window ending now.
+func (w *WindowStats) Snapshot() []WindowSnapshot {
+	topN := w.TopN
+	if topN <= 0 {
+		topN = 5
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := w.clock().Unix()
+	snapshots := make([]WindowSnapshot, 0, len(w.windows))
+	for _, window := range w.windows {
+		seconds := int64((window + time.Second - 1) / time.Second)
+		snapshot := WindowSnapshot{Window: window}
+		latencies := NewSketch(DefaultSketchAccuracy)
+		fingerprints := map[string]*FingerprintTotal{}
+		for i := range w.slots {
+			slot := &w.slots[i]
+			if slot.events == 0 || now-slot.second >= seconds || slot.second > now {
+				continue
+			}
+			snapshot.Events += slot.events
+			snapshot.TotalTime += slot.totalTime
+			latencies.Merge(slot.latencies)
+			for fingerprint, f := range slot.fingerprints {
+				total := fingerprints[fingerprint]
+				if total == nil {
+					total = &FingerprintTotal{Fingerprint: fingerprint}
+					fingerprints[fingerprint] = total
+				}
+				total.Count += f.Count
+				total.TotalTime += f.TotalTime
+			}
+		}
+		snapshot.P95Time = latencies.Quantile(0.95)
+		for _, f := range fingerprints {
+			snapshot.Top = append(snapshot.Top, *f)
+		}
+		sort.Slice(snapshot.Top, func(i, j int) bool {
+			if snapshot.Top[i].TotalTime != snapshot.Top[j].TotalTime {
+				return snapshot.Top[i].TotalTime > snapshot.Top[j].TotalTime
+			}
+			return snapshot.Top[i].Fingerprint < snapshot.Top[j].Fingerprint
+		})
+		if len(snapshot.Top) > topN {
+			snapshot.Top = snapshot.Top[:topN]
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
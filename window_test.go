@@ -0,0 +1,69 @@
+package mysqllog
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWindowStats(t *testing.T) {
+	now := time.Date(2017, 12, 24, 10, 0, 0, 0, time.UTC)
+	w := NewWindowStats(time.Minute, 5*time.Minute)
+	w.TopN = 2
+	clock := NewManualClock(now)
+	w.Clock = clock
+
+	// Catch-up events with old timestamps land in their own seconds.
+	for i := 0; i < 600; i++ {
+		w.Write(LogEvent{
+			"Statement":  fmt.Sprintf("SELECT * FROM t%d WHERE id = %d", i%3, i),
+			"Query_time": float64(i%3 + 1),
+			"Timestamp":  now.Add(-time.Duration(i) * time.Second),
+		})
+	}
+
+	snapshots := w.Snapshot()
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(snapshots))
+	}
+	minute, five := snapshots[0], snapshots[1]
+	if minute.Window != time.Minute || minute.Events != 60 || minute.TotalTime != 120 {
+		t.Errorf("unexpected one minute snapshot %+v", minute)
+	}
+	if five.Events != 300 || five.TotalTime != 600 {
+		t.Errorf("unexpected five minute snapshot %+v", five)
+	}
+	if len(five.Top) != 2 || five.Top[0].Fingerprint != "select * from t2 where id = ?" || five.Top[0].Count != 100 {
+		t.Errorf("unexpected top fingerprints %+v", five.Top)
+	}
+	if math.Abs(five.P95Time-3)/3 > 0.05 {
+		t.Errorf("expected p95 near 3, got %f", five.P95Time)
+	}
+
+	// As time passes, old seconds fall out of the windows.
+	clock.Add(30 * time.Second)
+	if snapshot := w.Snapshot()[0]; snapshot.Events != 30 {
+		t.Errorf("expected 30 events in the last minute, got %d", snapshot.Events)
+	}
+}
+
+func TestWindowStatsConcurrentSnapshot(t *testing.T) {
+	w := NewWindowStats()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			w.Snapshot()
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		w.Write(LogEvent{"Statement": "SELECT 1", "Query_time": 0.1})
+	}
+	wg.Wait()
+	if events := w.Snapshot()[0].Events; events != 1000 {
+		t.Errorf("expected 1000 events, got %d", events)
+	}
+}